@@ -24,6 +24,7 @@ import (
 	"github.com/spf13/cobra/doc"
 	"github.com/spf13/viper"
 
+	"istio.io/istio/istioctl/pkg/accesslog"
 	"istio.io/istio/istioctl/pkg/admin"
 	"istio.io/istio/istioctl/pkg/analyze"
 	"istio.io/istio/istioctl/pkg/authz"
@@ -44,11 +45,13 @@ import (
 	"istio.io/istio/istioctl/pkg/proxystatus"
 	"istio.io/istio/istioctl/pkg/revision"
 	"istio.io/istio/istioctl/pkg/root"
+	"istio.io/istio/istioctl/pkg/snapshot"
 	"istio.io/istio/istioctl/pkg/tag"
 	"istio.io/istio/istioctl/pkg/util"
 	"istio.io/istio/istioctl/pkg/validate"
 	"istio.io/istio/istioctl/pkg/version"
 	"istio.io/istio/istioctl/pkg/wait"
+	"istio.io/istio/istioctl/pkg/wasm"
 	"istio.io/istio/istioctl/pkg/waypoint"
 	"istio.io/istio/istioctl/pkg/workload"
 	"istio.io/istio/operator/cmd/mesh"
@@ -211,6 +214,10 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(proxyconfig.StatsConfigCmd(ctx))
 	experimentalCmd.AddCommand(checkinject.Cmd(ctx))
 	experimentalCmd.AddCommand(waypoint.Cmd(ctx))
+	experimentalCmd.AddCommand(snapshot.Cmd(ctx))
+	experimentalCmd.AddCommand(snapshot.DiffCmd(ctx))
+	experimentalCmd.AddCommand(wasm.Cmd(ctx))
+	experimentalCmd.AddCommand(accesslog.Cmd(ctx))
 
 	analyzeCmd := analyze.Analyze(ctx)
 	hideInheritedFlags(analyzeCmd, cli.FlagIstioNamespace)
@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
+)
+
+const (
+	jsonOutput  = "json"
+	shortOutput = "short"
+)
+
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var outputFormat string
+	var tail int
+
+	cmd := &cobra.Command{
+		Use:   "access-log [<type>/]<name>[.<namespace>]",
+		Short: "Prints the last access logs a proxy streamed to Istiod",
+		Long: `
+Prints the last access logs a proxy streamed to the access log collector of Istiod, from the oldest to the newest,
+without exec'ing into the pod of the proxy. The collector is enabled by setting PILOT_ACCESS_LOG_COLLECTOR_BUFFER_SIZE
+on Istiod, to the number of access logs kept by proxy, and the proxy is pointed at it with an envoyHttpAls or
+envoyTcpAls extension provider of the mesh config, such as:
+
+  extensionProviders:
+  - name: istiod-als
+    envoyHttpAls:
+      service: istiod.istio-system.svc.cluster.local
+      port: 15010
+
+enabled for the proxy by a Telemetry resource with the accessLogging provider istiod-als.
+`,
+		Example: `  # Print the last access logs of a gateway pod
+  istioctl x access-log istio-ingressgateway-59585c5b9c-ndc59.istio-system
+
+  # Print the last 20 access logs of a pod under a deployment, with all their fields
+  istioctl x access-log deployment/productpage-v1 --tail 20 -o json
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("access-log requires [<type>/]<name>[.<namespace>]")
+			}
+			if outputFormat != jsonOutput && outputFormat != shortOutput {
+				return fmt.Errorf("unknown output format %q, expected one of %s or %s", outputFormat, shortOutput, jsonOutput)
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			podName, ns, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+			if err != nil {
+				return err
+			}
+			proxyID := fmt.Sprintf("%s.%s", podName, ns)
+			entries, err := fetchAccessLogs(kubeClient, ctx.IstioNamespace(), proxyID, tail)
+			if err != nil {
+				return err
+			}
+			if outputFormat == jsonOutput {
+				out, err := json.MarshalIndent(entries, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(c.OutOrStdout(), string(out))
+				return nil
+			}
+			if len(entries) == 0 {
+				_, _ = fmt.Fprintf(c.OutOrStdout(), "No access logs of %s were collected by Istiod\n", proxyID)
+				return nil
+			}
+			printEntries(c.OutOrStdout(), entries)
+			return nil
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", shortOutput, "Output format: one of json|short")
+	cmd.PersistentFlags().IntVar(&tail, "tail", 0, "Number of the last access logs to print, all of them if zero")
+
+	return cmd
+}
+
+// fetchAccessLogs returns the last access logs of the proxy collected by the Istiod instances, from the oldest to
+// the newest. The proxy streams its access logs to any instance behind the service, and reconnects to another
+// one on restarts, so those of all instances are merged.
+func fetchAccessLogs(kubeClient kube.CLIClient, istioNamespace, proxyID string, tail int) ([]xds.AccessLogEntry, error) {
+	istiods, err := kubeClient.GetIstioPods(context.TODO(), istioNamespace, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+		FieldSelector: kube.RunningStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(istiods) == 0 {
+		return nil, fmt.Errorf("no running Istiod instance found in %s", istioNamespace)
+	}
+	path := "debug/accesslogz?proxyID=" + proxyID
+	if tail > 0 {
+		path += "&tail=" + strconv.Itoa(tail)
+	}
+	entries := []xds.AccessLogEntry{}
+	var errs []string
+	for _, istiod := range istiods {
+		res, err := kubeClient.EnvoyDoWithPort(context.TODO(), istiod.Name, istiod.Namespace, http.MethodGet, path, 15014)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", istiod.Name, err))
+			continue
+		}
+		var got []xds.AccessLogEntry
+		if err := json.Unmarshal(res, &got); err != nil {
+			// The collector is disabled, or the instance is too old to have one.
+			errs = append(errs, fmt.Sprintf("%s: %s", istiod.Name, strings.TrimSpace(string(res))))
+			continue
+		}
+		entries = append(entries, got...)
+	}
+	if len(errs) == len(istiods) {
+		return nil, fmt.Errorf("failed to fetch the access logs of %s: %s", proxyID, strings.Join(errs, "; "))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	if tail > 0 && len(entries) > tail {
+		entries = entries[len(entries)-tail:]
+	}
+	return entries, nil
+}
+
+// printEntries prints an access log by line, in a format close to the default text format of the proxies.
+func printEntries(w io.Writer, entries []xds.AccessLogEntry) {
+	for _, e := range entries {
+		var line string
+		if e.Protocol == "HTTP" {
+			line = fmt.Sprintf("[%s] %q %d %s %d %d %s %q %q %s %s %s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+				e.Method+" "+e.Path, e.ResponseCode, dash(e.ResponseCodeDetails), e.BytesReceived, e.BytesSent,
+				dash(e.Duration), e.Authority, e.RequestID, dash(e.UpstreamHost), dash(e.UpstreamCluster), dash(e.DownstreamRemote))
+		} else {
+			line = fmt.Sprintf("[%s] %s %d %d %s %s %s %s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.Protocol,
+				e.BytesReceived, e.BytesSent, dash(e.Duration), dash(e.UpstreamHost), dash(e.UpstreamCluster), dash(e.DownstreamRemote))
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
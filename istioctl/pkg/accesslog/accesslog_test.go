@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+)
+
+func TestAccessLog(t *testing.T) {
+	ctx := cli.NewFakeContext(&cli.NewFakeContextOption{
+		Namespace:      "default",
+		IstioNamespace: "istio-system",
+		Results: map[string][]byte{
+			"istiod-1": []byte(`[{"time":"2024-01-01T00:00:02Z","protocol":"HTTP","method":"GET","path":"/b","responseCode":503,` +
+				`"responseCodeDetails":"upstream_reset_before_response_started","authority":"b.example.com",` +
+				`"upstreamCluster":"outbound|80||b.example.com"}]`),
+			"istiod-2": []byte(`[{"time":"2024-01-01T00:00:01Z","protocol":"TCP","bytesReceived":10,"bytesSent":20,` +
+				`"upstreamHost":"10.0.0.2:3306","upstreamCluster":"outbound|3306||db.example.com"}]`),
+			"istiod-3": []byte("the access log collector is disabled, set PILOT_ACCESS_LOG_COLLECTOR_BUFFER_SIZE to enable it\n"),
+		},
+	})
+	client, err := ctx.CLIClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"istiod-1", "istiod-2", "istiod-3"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if _, err := client.Kube().CoreV1().Pods("istio-system").Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := func(args ...string) string {
+		cmd := Cmd(ctx)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	want := `[2024-01-01T00:00:01.000Z] TCP 10 20 - 10.0.0.2:3306 outbound|3306||db.example.com -
+[2024-01-01T00:00:02.000Z] "GET /b" 503 upstream_reset_before_response_started 0 0 - "b.example.com" "" - ` +
+		"outbound|80||b.example.com -\n"
+	if got := run("gateway-a.istio-system"); got != want {
+		t.Errorf("got output\n%s\nwant\n%s", got, want)
+	}
+	if got := run("gateway-a.istio-system", "--tail", "1", "-o", "json"); !bytes.Contains([]byte(got), []byte(`"path": "/b"`)) ||
+		bytes.Contains([]byte(got), []byte("TCP")) {
+		t.Errorf("got output %s, want the last access log", got)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/spf13/cobra"
@@ -1310,6 +1311,7 @@ func secretConfigCmd(ctx cli.Context) *cobra.Command {
 		},
 		RunE: func(c *cobra.Command, args []string) error {
 			var newWriter writer.ConfigDumpWriter
+			var envoyPod bool
 			kubeClient, err := ctx.CLIClient()
 			if err != nil {
 				return err
@@ -1322,6 +1324,7 @@ func secretConfigCmd(ctx cli.Context) *cobra.Command {
 				if ztunnelPod {
 					newWriter, err = setupZtunnelConfigDumpWriter(kubeClient, podName, podNamespace, c.OutOrStdout())
 				} else {
+					envoyPod = true
 					newWriter, err = setupPodConfigdumpWriter(kubeClient, podName, podNamespace, false, c.OutOrStdout())
 				}
 			} else {
@@ -1341,7 +1344,21 @@ func secretConfigCmd(ctx cli.Context) *cobra.Command {
 			}
 			switch outputFormat {
 			case summaryOutput:
-				return newWriter.PrintSecretSummary()
+				if err := newWriter.PrintSecretSummary(); err != nil {
+					return err
+				}
+				// Added by ingress
+				if envoyPod {
+					secrets, err := getProxySecrets(kubeClient, ctx.IstioNamespace(), fmt.Sprintf("%s.%s", podName, podNamespace))
+					if err != nil {
+						log.Warnf("couldn't get the secrets served by Istiod: %v", err)
+						return nil
+					}
+					_, _ = fmt.Fprintln(c.OutOrStdout(), "\nServed by Istiod:")
+					return writeProxySecrets(c.OutOrStdout(), secrets, time.Now())
+				}
+				// End added by ingress
+				return nil
 			case jsonOutput, yamlOutput:
 				return newWriter.PrintSecretDump(outputFormat)
 			default:
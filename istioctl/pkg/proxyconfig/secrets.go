@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/kube"
+)
+
+// getProxySecrets returns the status of the secrets of the proxy from the Istiod instance it is connected to.
+func getProxySecrets(kubeClient kube.CLIClient, istioNamespace, proxyID string) ([]xds.ProxySecret, error) {
+	istiods, err := kubeClient.GetIstioPods(context.TODO(), istioNamespace, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+		FieldSelector: kube.RunningStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	path := "debug/proxy_secrets?proxyID=" + proxyID
+	for _, istiod := range istiods {
+		// Only the instance the proxy is connected to knows its secrets.
+		res, err := kubeClient.EnvoyDoWithPort(context.TODO(), istiod.Name, istiod.Namespace, http.MethodGet, path, 15014)
+		if err != nil {
+			continue
+		}
+		secrets := []xds.ProxySecret{}
+		if err := json.Unmarshal(res, &secrets); err != nil {
+			return nil, fmt.Errorf("failed to parse the secrets of %s from %s: %v", proxyID, istiod.Name, err)
+		}
+		return secrets, nil
+	}
+	return nil, fmt.Errorf("no Istiod instance returned the secrets of %s", proxyID)
+}
+
+// writeProxySecrets prints the source, private key provider and leaf certificate of the secrets served by Istiod.
+func writeProxySecrets(out io.Writer, secrets []xds.ProxySecret, now time.Time) error {
+	w := new(tabwriter.Writer).Init(out, 0, 5, 5, ' ', 0)
+	_, _ = fmt.Fprintln(w, "RESOURCE NAME\tCREDENTIAL\tPRIVATE KEY PROVIDER\tSUBJECT\tISSUER\tCHAIN LENGTH\tNOT AFTER\tEXPIRES IN")
+	for _, s := range secrets {
+		credential := "-"
+		if s.CredentialName != "" {
+			credential = s.Namespace + "/" + s.CredentialName
+		}
+		provider := s.PrivateKeyProvider
+		if provider == "" {
+			provider = "-"
+		}
+		if s.Error != "" {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t0\t-\t%s\n", s.ResourceName, credential, provider, s.Error)
+			continue
+		}
+		subject, issuer, notAfter, expiresIn := "-", "-", "-", "-"
+		if len(s.Certificates) > 0 {
+			leaf := s.Certificates[0]
+			subject, issuer = leaf.Subject, leaf.Issuer
+			notAfter = leaf.NotAfter.UTC().Format(time.RFC3339)
+			if remaining := leaf.NotAfter.Sub(now); remaining > 0 {
+				expiresIn = remaining.Truncate(time.Minute).String()
+			} else {
+				expiresIn = "expired"
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			s.ResourceName, credential, provider, subject, issuer, len(s.Certificates), notAfter, expiresIn)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/xds"
+)
+
+func TestWriteProxySecrets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	secrets := []xds.ProxySecret{
+		{
+			ResourceName:       "kubernetes://gateway-cert",
+			CredentialName:     "gateway-cert",
+			Namespace:          "istio-system",
+			PrivateKeyProvider: "cryptomb",
+			Certificates: []xds.SecretCertificate{
+				{Subject: "CN=example.com", Issuer: "CN=ca", NotAfter: now.Add(48 * time.Hour)},
+				{Subject: "CN=ca", Issuer: "CN=root", NotAfter: now.Add(480 * time.Hour)},
+			},
+		},
+		{
+			ResourceName:   "kubernetes://missing",
+			CredentialName: "missing",
+			Namespace:      "istio-system",
+			Error:          "not served",
+		},
+	}
+	var out bytes.Buffer
+	if err := writeProxySecrets(&out, secrets, now); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 secrets, got:\n%s", out.String())
+	}
+	for _, want := range []string{"istio-system/gateway-cert", "cryptomb", "CN=example.com", "2024-01-03T00:00:00Z", "48h0m0s"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("expected %q in %q", want, lines[1])
+		}
+	}
+	if !strings.Contains(lines[2], "not served") {
+		t.Errorf("expected the error of the missing secret in %q", lines[2])
+	}
+}
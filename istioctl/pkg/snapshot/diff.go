@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	jsonOutput    = "json"
+	summaryOutput = "short"
+)
+
+func DiffCmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var outputFormat string
+	var types []string
+
+	cmd := &cobra.Command{
+		Use:   "proxy-diff [<type>/]<name>[.<namespace>] [<type>/]<name>[.<namespace>]",
+		Short: "Compares the xDS resources generated by Istiod for two proxies",
+		Long: `
+Compares the xDS resources Istiod generates for two proxies, rather than the ones the proxies applied, type by type.
+Resources are matched by name, and the fields of the resources of both proxies which differ are shown, the ones of
+the first proxy prefixed with - and the ones of the second prefixed with +. Secrets are not compared.
+Snapshot archives exported by 'istioctl x snapshot' can be compared in place of connected proxies.
+`,
+		Example: `  # Compare the resources generated for two gateway pods
+  istioctl x proxy-diff istio-ingressgateway-59585c5b9c-ndc59.istio-system istio-ingressgateway-59585c5b9c-qcxg4.istio-system
+
+  # Compare the listeners and routes of a gateway pod with the ones of an exported snapshot
+  istioctl x proxy-diff deployment/istio-ingressgateway.istio-system gateway.tar --type lds,rds
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("proxy-diff requires two proxies")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			if outputFormat != jsonOutput && outputFormat != summaryOutput {
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			snapshots := make([]*xds.Snapshot, 0, len(args))
+			for _, arg := range args {
+				snap, err := loadSnapshot(ctx, kubeClient, arg)
+				if err != nil {
+					return err
+				}
+				snapshots = append(snapshots, snap)
+			}
+			diffs := filterDiffs(xds.DiffSnapshots(snapshots[0], snapshots[1]), types)
+			if outputFormat == jsonOutput {
+				out, err := json.MarshalIndent(diffs, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(c.OutOrStdout(), string(out))
+				return nil
+			}
+			printDiffs(c.OutOrStdout(), snapshots[0].Proxy, snapshots[1].Proxy, diffs)
+			return nil
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	cmd.PersistentFlags().StringSliceVar(&types, "type", nil,
+		"Types of the resources to compare, such as cds, lds, rds, eds or ecds, defaults to all")
+
+	return cmd
+}
+
+// loadSnapshot reads the snapshot of an archive, or fetches the one of a proxy from the Istiod it is connected to.
+func loadSnapshot(ctx cli.Context, kubeClient kube.CLIClient, arg string) (*xds.Snapshot, error) {
+	if strings.HasSuffix(arg, ".tar") {
+		if f, err := os.Open(arg); err == nil {
+			defer f.Close()
+			return xds.ReadSnapshot(f)
+		}
+	}
+	podName, ns, err := ctx.InferPodInfoFromTypedResource(arg, ctx.Namespace())
+	if err != nil {
+		return nil, err
+	}
+	archive, _, err := fetchSnapshot(kubeClient, ctx.IstioNamespace(), fmt.Sprintf("%s.%s", podName, ns))
+	if err != nil {
+		return nil, err
+	}
+	return xds.ReadSnapshot(bytes.NewReader(archive))
+}
+
+// filterDiffs keeps the diffs of the given short types, or all of them if none is given.
+func filterDiffs(diffs []xds.DryRunDiff, types []string) []xds.DryRunDiff {
+	if len(types) == 0 {
+		return diffs
+	}
+	keep := sets.New[string]()
+	for _, t := range types {
+		keep.Insert(strings.ToUpper(t))
+	}
+	out := []xds.DryRunDiff{}
+	for _, d := range diffs {
+		if keep.Contains(v3.GetShortType(d.TypeURL)) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func printDiffs(w io.Writer, a, b string, diffs []xds.DryRunDiff) {
+	if len(diffs) == 0 {
+		_, _ = fmt.Fprintf(w, "The resources generated for %s and %s are identical\n", a, b)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "--- %s\n+++ %s\n", a, b)
+	for _, d := range diffs {
+		_, _ = fmt.Fprintf(w, "\n%s (%s):\n", v3.GetShortType(d.TypeURL), d.TypeURL)
+		for _, name := range d.Removed {
+			_, _ = fmt.Fprintf(w, "  only in %s: %s\n", a, name)
+		}
+		for _, name := range d.Added {
+			_, _ = fmt.Fprintf(w, "  only in %s: %s\n", b, name)
+		}
+		for _, m := range d.Modified {
+			_, _ = fmt.Fprintf(w, "  modified: %s\n", m.Name)
+			for _, line := range strings.Split(strings.TrimRight(m.Diff, "\n"), "\n") {
+				_, _ = fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func writeSnapshot(t *testing.T, snap *xds.Snapshot) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), snap.Proxy+".tar")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := xds.WriteSnapshot(f, snap); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runDiff(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := DiffCmd(cli.NewFakeContext(nil))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestProxyDiff(t *testing.T) {
+	resource := func(name string, timeout int64) *discovery.Resource {
+		return &discovery.Resource{Name: name, Resource: protoconv.MessageToAny(&cluster.Cluster{
+			Name:           name,
+			ConnectTimeout: durationpb.New(time.Duration(timeout) * time.Second),
+		})}
+	}
+	a := writeSnapshot(t, &xds.Snapshot{Proxy: "gateway-a.istio-system", Resources: map[string]model.Resources{
+		v3.ClusterType:  {resource("outbound|80||a.com", 10), resource("outbound|80||shared.com", 10)},
+		v3.ListenerType: {{Name: "0.0.0.0_8080", Resource: protoconv.MessageToAny(&listener.Listener{Name: "0.0.0.0_8080"})}},
+	}})
+	b := writeSnapshot(t, &xds.Snapshot{Proxy: "gateway-b.istio-system", Resources: map[string]model.Resources{
+		v3.ClusterType:  {resource("outbound|80||b.com", 10), resource("outbound|80||shared.com", 5)},
+		v3.ListenerType: {{Name: "0.0.0.0_8080", Resource: protoconv.MessageToAny(&listener.Listener{Name: "0.0.0.0_8080"})}},
+	}})
+
+	out := runDiff(t, a, b)
+	for _, want := range []string{
+		"--- gateway-a.istio-system\n+++ gateway-b.istio-system\n",
+		"CDS (" + v3.ClusterType + "):\n",
+		"  only in gateway-a.istio-system: outbound|80||a.com\n",
+		"  only in gateway-b.istio-system: outbound|80||b.com\n",
+		"  modified: outbound|80||shared.com\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "LDS") {
+		t.Errorf("got identical listeners in output:\n%s", out)
+	}
+
+	var diffs []xds.DryRunDiff
+	if err := json.Unmarshal([]byte(runDiff(t, a, b, "-o", "json", "--type", "lds")), &diffs); err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("got listener diffs %+v, want none", diffs)
+	}
+
+	if out := runDiff(t, a, a); out != "The resources generated for gateway-a.istio-system and gateway-a.istio-system are identical\n" {
+		t.Errorf("got output %q for identical snapshots", out)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/kube"
+)
+
+func Cmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "snapshot [<type>/]<name>[.<namespace>]",
+		Short: "Exports the xDS resources generated by Istiod for a proxy to a tar archive",
+		Long: `
+Exports the full set of xDS resources Istiod generates for a proxy, for every type the proxy watches, to a tar
+archive. Secrets are not exported. The archive can be loaded in a fake discovery server to replay the configs
+of the proxy offline.
+`,
+		Example: `  # Export the resources generated for a gateway pod to istio-ingressgateway-59585c5b9c-ndc59.istio-system.tar
+  istioctl x snapshot istio-ingressgateway-59585c5b9c-ndc59.istio-system
+
+  # Export the resources generated for a pod under a deployment to a given file
+  istioctl x snapshot deployment/productpage-v1 -o productpage.tar
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("snapshot requires [<type>/]<name>[.<namespace>]")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			podName, ns, err := ctx.InferPodInfoFromTypedResource(args[0], ctx.Namespace())
+			if err != nil {
+				return err
+			}
+			proxyID := fmt.Sprintf("%s.%s", podName, ns)
+			snapshot, istiod, err := fetchSnapshot(kubeClient, ctx.IstioNamespace(), proxyID)
+			if err != nil {
+				return err
+			}
+			if outputFile == "" {
+				outputFile = proxyID + ".tar"
+			}
+			if err := os.WriteFile(outputFile, snapshot, 0o644); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(c.OutOrStdout(), "Wrote the snapshot of %s from %s to %s\n", proxyID, istiod, outputFile)
+			return nil
+		},
+	}
+
+	opts.AttachControlPlaneFlags(cmd)
+	cmd.PersistentFlags().StringVarP(&outputFile, "output", "o", "",
+		"File to write the snapshot to, defaults to <name>.<namespace>.tar")
+
+	return cmd
+}
+
+// fetchSnapshot returns the snapshot archive of the proxy, along with the name of the Istiod instance it is
+// connected to, which is the only one able to generate it.
+func fetchSnapshot(kubeClient kube.CLIClient, istioNamespace, proxyID string) ([]byte, string, error) {
+	istiods, err := kubeClient.GetIstioPods(context.TODO(), istioNamespace, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+		FieldSelector: kube.RunningStatus,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	path := "debug/snapshot?proxyID=" + proxyID
+	for _, istiod := range istiods {
+		res, err := kubeClient.EnvoyDoWithPort(context.TODO(), istiod.Name, istiod.Namespace, http.MethodGet, path, 15014)
+		if err != nil || len(res) == 0 {
+			continue
+		}
+		return res, istiod.Name, nil
+	}
+	return nil, "", fmt.Errorf("no Istiod instance returned a snapshot of %s", proxyID)
+}
@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pkg/kube"
+)
+
+func refreshCmd(ctx cli.Context) *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var selector string
+	cmd := &cobra.Command{
+		Use:   "refresh <plugin>[.<namespace>]",
+		Short: "Makes the selected proxies pull the module of a WasmPlugin again",
+		Long: `Makes the proxies matching the selector pull the module of a WasmPlugin again, without editing the
+WasmPlugin. Istiod changes the resource version of the extension config of the plugin for the selected proxies and
+pushes it to them, so their agents pull a mutable tag again. This only applies to the plugins pulled on each
+resource version change: those with the Always pull policy, or images tagged latest without a pull policy.`,
+		Example: `  # Make the gateways pull the module of the basic-auth plugin of istio-system again
+  istioctl x wasm refresh basic-auth.istio-system -l app=istio-ingressgateway
+
+  # Make all the proxies of the plugin in the current namespace pull its module again
+  istioctl x wasm refresh basic-auth`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("refresh requires exactly one WasmPlugin")
+			}
+			if _, err := klabels.Parse(selector); err != nil {
+				return fmt.Errorf("invalid selector %q: %v", selector, err)
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			kubeClient, err := ctx.CLIClientWithRevision(opts.Revision)
+			if err != nil {
+				return err
+			}
+			name, ns := splitPluginName(args[0], ctx.NamespaceOrDefault(ctx.Namespace()))
+			plugin, err := kubeClient.Istio().ExtensionsV1alpha1().WasmPlugins(ns).Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if !pulledOnRefresh(&plugin.Spec) {
+				_, _ = fmt.Fprintf(c.ErrOrStderr(), "Warning: WasmPlugin %s/%s keeps using its cached module on refresh, "+
+					"set its imagePullPolicy to Always to pull it again\n", ns, name)
+			}
+			// The istiods share the token, so the proxies do not pull the module again when they reconnect.
+			token := strconv.FormatInt(time.Now().Unix(), 10)
+			proxies, err := refreshModule(kubeClient, ctx.IstioNamespace(), ns, name, selector, token)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(c.OutOrStdout(), "Refreshed the module of WasmPlugin %s/%s on %d proxies\n", ns, name, len(proxies))
+			for _, p := range proxies {
+				_, _ = fmt.Fprintf(c.OutOrStdout(), "  %s\n", p)
+			}
+			return nil
+		},
+	}
+	opts.AttachControlPlaneFlags(cmd)
+	cmd.Flags().StringVarP(&selector, "selector", "l", "",
+		"Label selector of the proxies to refresh the module on, defaults to all the proxies of the plugin")
+	return cmd
+}
+
+// refreshModule records the refresh on all the Istiod instances, which push it to the selected proxies connected to
+// them. It returns the IDs of the proxies pushed to.
+func refreshModule(kubeClient kube.CLIClient, istioNamespace, namespace, name, selector, token string) ([]string, error) {
+	istiods, err := kubeClient.GetIstioPods(context.TODO(), istioNamespace, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+		FieldSelector: kube.RunningStatus,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(istiods) == 0 {
+		return nil, fmt.Errorf("unable to find any Istiod instances")
+	}
+	query := url.Values{}
+	query.Set("plugin", namespace+"/"+name)
+	query.Set("selector", selector)
+	query.Set("token", token)
+	path := "debug/wasm_refresh?" + query.Encode()
+	var proxies []string
+	for _, istiod := range istiods {
+		res, err := kubeClient.EnvoyDoWithPort(context.TODO(), istiod.Name, istiod.Namespace, http.MethodPost, path, 15014)
+		if err != nil {
+			return nil, fmt.Errorf("failed to refresh the module on %s: %v", istiod.Name, err)
+		}
+		pushed := []string{}
+		if err := json.Unmarshal(res, &pushed); err != nil {
+			return nil, fmt.Errorf("failed to parse the response of %s: %v", istiod.Name, err)
+		}
+		proxies = append(proxies, pushed...)
+	}
+	sort.Strings(proxies)
+	return proxies, nil
+}
+
+// splitPluginName splits a <name>[.<namespace>] reference to a WasmPlugin.
+func splitPluginName(ref, defaultNamespace string) (string, string) {
+	if i := strings.LastIndex(ref, "."); i > 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, defaultNamespace
+}
+
+// pulledOnRefresh returns true if the agents pull the module of the plugin again when its resource version changes.
+func pulledOnRefresh(plugin *extensions.WasmPlugin) bool {
+	switch plugin.GetImagePullPolicy() {
+	case extensions.PullPolicy_Always:
+		return true
+	case extensions.PullPolicy_IfNotPresent:
+		return false
+	default:
+		// The images tagged latest are pulled the same way as with Always.
+		u := plugin.GetUrl()
+		return (strings.HasPrefix(u, "oci://") || !strings.Contains(u, "://")) && strings.HasSuffix(u, ":latest")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	clientextensions "istio.io/client-go/pkg/apis/extensions/v1alpha1"
+	"istio.io/istio/istioctl/pkg/cli"
+)
+
+func TestRefresh(t *testing.T) {
+	ctx := cli.NewFakeContext(&cli.NewFakeContextOption{
+		Namespace:      "default",
+		IstioNamespace: "istio-system",
+		Results: map[string][]byte{
+			"istiod-1": []byte(`["gateway-1.istio-system"]`),
+			"istiod-2": []byte(`["gateway-2.istio-system"]`),
+		},
+	})
+	client, err := ctx.CLIClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"istiod-1", "istiod-2"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", Labels: map[string]string{"app": "istiod"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if _, err := client.Kube().CoreV1().Pods("istio-system").Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plugin := &clientextensions.WasmPlugin{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-auth", Namespace: "istio-system"},
+		Spec:       extensions.WasmPlugin{Url: "oci://example.com/basic-auth:1.0"},
+	}
+	if _, err := client.Istio().ExtensionsV1alpha1().WasmPlugins("istio-system").Create(context.TODO(), plugin,
+		metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := Cmd(ctx)
+	var out, errOut bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{"refresh", "basic-auth.istio-system", "-l", "app=istio-ingressgateway"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	want := "Refreshed the module of WasmPlugin istio-system/basic-auth on 2 proxies\n" +
+		"  gateway-1.istio-system\n  gateway-2.istio-system\n"
+	if out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+	// The module of the plugin is cached regardless of the resource version.
+	if !strings.Contains(errOut.String(), "Warning: WasmPlugin istio-system/basic-auth keeps using its cached module") {
+		t.Errorf("got no warning in %q", errOut.String())
+	}
+
+	cmd = Cmd(ctx)
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs([]string{"refresh", "missing"})
+	if err := cmd.Execute(); err == nil {
+		t.Error("expected missing plugin to fail")
+	}
+}
+
+func TestPulledOnRefresh(t *testing.T) {
+	cases := []struct {
+		plugin *extensions.WasmPlugin
+		want   bool
+	}{
+		{plugin: &extensions.WasmPlugin{Url: "oci://example.com/plugin:1.0", ImagePullPolicy: extensions.PullPolicy_Always}, want: true},
+		{plugin: &extensions.WasmPlugin{Url: "oci://example.com/plugin:latest", ImagePullPolicy: extensions.PullPolicy_IfNotPresent}},
+		{plugin: &extensions.WasmPlugin{Url: "oci://example.com/plugin:latest"}, want: true},
+		{plugin: &extensions.WasmPlugin{Url: "example.com/plugin:latest"}, want: true},
+		{plugin: &extensions.WasmPlugin{Url: "https://example.com/plugin:latest"}},
+		{plugin: &extensions.WasmPlugin{Url: "oci://example.com/plugin:1.0"}},
+	}
+	for _, tt := range cases {
+		if got := pulledOnRefresh(tt.plugin); got != tt.want {
+			t.Errorf("pulledOnRefresh(%v) = %v, want %v", tt.plugin, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPluginName(t *testing.T) {
+	if name, ns := splitPluginName("basic-auth.istio-system", "default"); name != "basic-auth" || ns != "istio-system" {
+		t.Errorf("got %s/%s", ns, name)
+	}
+	if name, ns := splitPluginName("basic-auth", "default"); name != "basic-auth" || ns != "default" {
+		t.Errorf("got %s/%s", ns, name)
+	}
+}
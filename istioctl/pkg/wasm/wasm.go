@@ -0,0 +1,263 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/wasm"
+)
+
+const (
+	jsonOutput    = "json"
+	summaryOutput = "short"
+)
+
+// fetchOptions are the flags of the commands fetching a module.
+type fetchOptions struct {
+	insecure   bool
+	pullSecret string
+	timeout    time.Duration
+}
+
+func (o *fetchOptions) attachFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.insecure, "insecure", false,
+		"Allow fetching the module from registries and servers with untrusted certificates or over plain HTTP")
+	cmd.Flags().StringVar(&o.pullSecret, "pull-secret", "",
+		"Docker config JSON file with the credentials to pull the image, defaults to the local docker config")
+	cmd.Flags().DurationVar(&o.timeout, "timeout", 30*time.Second, "Timeout of each request fetching the module")
+}
+
+// fetch reads the module of a local file, or fetches it from a http, https or oci URL. References without a scheme
+// which are not local files are pulled as images.
+func (o *fetchOptions) fetch(source string) ([]byte, error) {
+	if !strings.Contains(source, "://") {
+		if b, err := os.ReadFile(source); err == nil {
+			return b, nil
+		}
+		source = "oci://" + source
+	}
+	opts := wasm.FetchOptions{Insecure: o.insecure, Timeout: o.timeout, MaxRetries: 3}
+	if o.pullSecret != "" {
+		b, err := os.ReadFile(o.pullSecret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pull secret: %v", err)
+		}
+		opts.PullSecret = b
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	return wasm.Fetch(ctx, source, opts)
+}
+
+func Cmd(ctx cli.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wasm",
+		Short: "Inspect, pull, verify and refresh Wasm modules",
+		Long: `A group of commands to inspect, pull and verify the Wasm modules of WasmPlugins, fetched and parsed the
+same way the Istio agent does before handing them to the proxy, and to make the proxies pull them again.`,
+	}
+	cmd.AddCommand(inspectCmd(), pullCmd(), verifyCmd(), refreshCmd(ctx))
+	return cmd
+}
+
+func inspectCmd() *cobra.Command {
+	var opts fetchOptions
+	var outputFormat, proxyVersion string
+	cmd := &cobra.Command{
+		Use:   "inspect <image|url|file>",
+		Short: "Prints the size, checksum, ABI versions, exports and custom sections of a Wasm module",
+		Example: `  # Inspect the module of an image
+  istioctl x wasm inspect oci://ghcr.io/istio-ecosystem/wasm-extensions/basic_auth:1.12.0
+
+  # Inspect a local module, checking the proxy-wasm ABI versions it declares are supported by a proxy version
+  istioctl x wasm inspect plugin.wasm --proxy-version 1.19.0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			b, err := opts.fetch(args[0])
+			if err != nil {
+				return err
+			}
+			info, err := wasm.InspectModule(b)
+			if err != nil {
+				return err
+			}
+			var abiErr error
+			if proxyVersion != "" {
+				abiErr = wasm.CheckABIVersions(info.ABIVersions, proxyVersion)
+			}
+			switch outputFormat {
+			case jsonOutput:
+				out, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(c.OutOrStdout(), string(out))
+			case summaryOutput:
+				printModuleInfo(c.OutOrStdout(), info)
+			default:
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			return abiErr
+		},
+	}
+	opts.attachFlags(cmd)
+	cmd.Flags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+	cmd.Flags().StringVar(&proxyVersion, "proxy-version", "",
+		"Istio version of the proxy to check the proxy-wasm ABI versions of the module against")
+	return cmd
+}
+
+func printModuleInfo(writer io.Writer, info *wasm.ModuleInfo) {
+	w := new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Size:\t%d bytes\n", info.Size)
+	_, _ = fmt.Fprintf(w, "SHA256:\t%s\n", info.SHA256)
+	abiVersions := "-"
+	if len(info.ABIVersions) > 0 {
+		abiVersions = strings.Join(info.ABIVersions, ", ")
+	}
+	_, _ = fmt.Fprintf(w, "ABI versions:\t%s\n", abiVersions)
+	_, _ = fmt.Fprintf(w, "WAMR AOT:\t%v\n", info.WamrAot)
+	_, _ = fmt.Fprintf(w, "Config schema:\t%v\n", info.ConfigSchema != "")
+	_ = w.Flush()
+
+	_, _ = fmt.Fprintln(writer, "\nCustom sections:")
+	w = new(tabwriter.Writer).Init(writer, 0, 8, 1, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tSIZE")
+	for _, s := range info.CustomSections {
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", s.Name, s.Size)
+	}
+	_ = w.Flush()
+
+	_, _ = fmt.Fprintln(writer, "\nExports:")
+	for _, e := range info.Exports {
+		_, _ = fmt.Fprintf(writer, "%s\n", e)
+	}
+}
+
+func pullCmd() *cobra.Command {
+	var opts fetchOptions
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:   "pull <image|url>",
+		Short: "Pulls a Wasm module to a local file",
+		Example: `  # Pull the module of an image to basic_auth.wasm
+  istioctl x wasm pull oci://ghcr.io/istio-ecosystem/wasm-extensions/basic_auth:1.12.0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			b, err := opts.fetch(args[0])
+			if err != nil {
+				return err
+			}
+			info, err := wasm.InspectModule(b)
+			if err != nil {
+				return err
+			}
+			if outputFile == "" {
+				outputFile = moduleFileName(args[0])
+			}
+			if err := os.WriteFile(outputFile, b, 0o644); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(c.OutOrStdout(), "Wrote the module %s with sha256 %s to %s\n", args[0], info.SHA256, outputFile)
+			return nil
+		},
+	}
+	opts.attachFlags(cmd)
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "",
+		"File to write the module to, defaults to the name of the module with the .wasm extension")
+	return cmd
+}
+
+// moduleFileName returns the local file name of the module of the reference, without its tag or digest.
+func moduleFileName(ref string) string {
+	name := path.Base(ref)
+	if i := strings.IndexAny(name, ":@"); i > 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".wasm") + ".wasm"
+}
+
+func verifyCmd() *cobra.Command {
+	var opts fetchOptions
+	var key, signature, checksum string
+	cmd := &cobra.Command{
+		Use:   "verify <image|url|file>",
+		Short: "Verifies the checksum and the signature of a Wasm module",
+		Long: `Verifies a Wasm module against the sha256 checksum a WasmPlugin pins it with, and against a detached
+signature of the module, such as the ones produced by 'cosign sign-blob'.`,
+		Example: `  # Verify the signature of the module of an image
+  istioctl x wasm verify oci://ghcr.io/istio-ecosystem/wasm-extensions/basic_auth:1.12.0 --key cosign.pub --signature basic_auth.sig
+
+  # Verify the checksum of a module
+  istioctl x wasm verify https://example.com/plugin.wasm --sha256 8a5f0c...`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("verify requires exactly one module")
+			}
+			if key == "" && checksum == "" {
+				return fmt.Errorf("at least one of --key or --sha256 must be set")
+			}
+			if (key == "") != (signature == "") {
+				return fmt.Errorf("--key and --signature must be set together")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			b, err := opts.fetch(args[0])
+			if err != nil {
+				return err
+			}
+			info, err := wasm.InspectModule(b)
+			if err != nil {
+				return err
+			}
+			if checksum != "" && !strings.EqualFold(checksum, info.SHA256) {
+				return fmt.Errorf("module %s has checksum %s, which does not match %s", args[0], info.SHA256, checksum)
+			}
+			if key != "" {
+				publicKey, err := os.ReadFile(key)
+				if err != nil {
+					return fmt.Errorf("failed to read public key: %v", err)
+				}
+				sig, err := os.ReadFile(signature)
+				if err != nil {
+					return fmt.Errorf("failed to read signature: %v", err)
+				}
+				if err := wasm.VerifySignature(b, sig, publicKey); err != nil {
+					return fmt.Errorf("module %s failed signature verification: %v", args[0], err)
+				}
+			}
+			_, _ = fmt.Fprintf(c.OutOrStdout(), "Verified the module %s with sha256 %s\n", args[0], info.SHA256)
+			return nil
+		},
+	}
+	opts.attachFlags(cmd)
+	cmd.Flags().StringVar(&key, "key", "", "PEM encoded public key to verify the signature of the module with")
+	cmd.Flags().StringVar(&signature, "signature", "", "File holding the detached signature of the module")
+	cmd.Flags().StringVar(&checksum, "sha256", "", "Expected hex-encoded sha256 checksum of the module")
+	return cmd
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"istio.io/istio/istioctl/pkg/cli"
+	"istio.io/istio/pkg/wasm"
+)
+
+// emptyModule is a Wasm module without any section.
+var emptyModule = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+func runCmd(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := Cmd(cli.NewFakeContext(nil))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestInspectAndVerify(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "plugin.wasm")
+	if err := os.WriteFile(module, emptyModule, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sha := sha256.Sum256(emptyModule)
+	checksum := hex.EncodeToString(sha[:])
+
+	out, err := runCmd(t, "inspect", module, "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &wasm.ModuleInfo{}
+	if err := json.Unmarshal([]byte(out), info); err != nil {
+		t.Fatal(err)
+	}
+	if info.SHA256 != checksum || info.Size != len(emptyModule) {
+		t.Errorf("unexpected module info %+v", info)
+	}
+
+	if out, err := runCmd(t, "verify", module, "--sha256", checksum); err != nil || !strings.Contains(out, "Verified") {
+		t.Errorf("expected the checksum to be verified, got %q, %v", out, err)
+	}
+	if _, err := runCmd(t, "verify", module, "--sha256", strings.Repeat("0", 64)); err == nil {
+		t.Errorf("expected a mismatching checksum to fail verification")
+	}
+	if _, err := runCmd(t, "verify", module); err == nil {
+		t.Errorf("expected verify without a checksum or a key to be rejected")
+	}
+}
+
+func TestModuleFileName(t *testing.T) {
+	cases := map[string]string{
+		"oci://ghcr.io/istio-ecosystem/wasm-extensions/basic_auth:1.12.0": "basic_auth.wasm",
+		"ghcr.io/plugins/block@sha256:0123":                               "block.wasm",
+		"https://example.com/plugins/plugin.wasm":                         "plugin.wasm",
+	}
+	for ref, want := range cases {
+		if got := moduleFileName(ref); got != want {
+			t.Errorf("moduleFileName(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
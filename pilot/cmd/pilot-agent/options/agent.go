@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/bootstrap/platform"
+	"istio.io/istio/pkg/config/constants"
 	istioagent "istio.io/istio/pkg/istio-agent"
 	"istio.io/istio/pkg/util/sets"
 	"istio.io/istio/pkg/wasm"
@@ -69,6 +70,14 @@ func NewAgentOptions(proxy *model.Proxy, cfg *meshconfig.ProxyConfig) *istioagen
 		UseExternalWorkloadSDS:      useExternalWorkloadSDSEnv,
 	}
 	extractXDSHeadersFromEnv(o)
+	// Added by ingress
+	if ecdsFallbackEnv {
+		o.ECDSFallbackPath = filepath.Join(constants.IstioDataDir, "ecds.pb")
+	}
+	o.OutlierEventReportURL = outlierEventReportURLEnv
+	o.WasmStatsReportURL = wasmStatsReportURLEnv
+	o.DNSOverTLSResolvers = dnsOverTLSResolversEnv
+	// End added by ingress
 	return o
 }
 
@@ -0,0 +1,43 @@
+package options
+
+import (
+	"os"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/env"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/security/pkg/nodeagent/keyprovider"
+)
+
+// HardwareKeySocketMetadata is the proxyMetadata of a ProxyConfig, or of the defaultConfig of the mesh config,
+// setting the unix socket of the key service holding the private keys of the workload certificates.
+const HardwareKeySocketMetadata = "HARDWARE_KEY_SOCKET"
+
+var hardwareKeySocketEnv = env.Register(HardwareKeySocketMetadata, "",
+	"The unix socket of a key service holding the private keys of the workload certificates, such as in a PKCS#11 "+
+		"token or a TPM, instead of the agent. Envoy signs with them through the private key provider the service "+
+		"configures. The proxyMetadata of the ProxyConfig of the proxy takes precedence. Proxies on nodes where the "+
+		"socket does not exist, such as node pools without the key service, keep software keys").Get()
+
+// setupKeyProvider holds the private keys of the workload certificates in the key service set by the ProxyConfig,
+// or else by HARDWARE_KEY_SOCKET, if it exists on the node.
+func setupKeyProvider(proxyConfig *meshconfig.ProxyConfig, o *security.Options) {
+	socket := hardwareKeySocketEnv
+	if s, ok := proxyConfig.GetProxyMetadata()[HardwareKeySocketMetadata]; ok {
+		socket = s
+	}
+	if socket == "" {
+		return
+	}
+	if o.FileMountedCerts || o.OutputKeyCertToDir != "" {
+		log.Warnf("hardware keys are not supported with FILE_MOUNTED_CERTS or OUTPUT_CERTS, using software keys")
+		return
+	}
+	if _, err := os.Stat(socket); err != nil {
+		log.Infof("no key service on this node, using software keys: %v", err)
+		return
+	}
+	log.Infof("holding workload keys in the key service of %s", socket)
+	o.KeyProvider = keyprovider.NewService(socket, keyprovider.Algorithm(o))
+}
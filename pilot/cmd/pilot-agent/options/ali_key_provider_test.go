@@ -0,0 +1,69 @@
+package options
+
+import (
+	"path/filepath"
+	"testing"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/file"
+	"istio.io/istio/pkg/security"
+	"istio.io/istio/pkg/test"
+)
+
+func TestSetupKeyProvider(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "keys.sock")
+	if err := file.AtomicWrite(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.sock")
+	withSocket := func(s string) *meshconfig.ProxyConfig {
+		return &meshconfig.ProxyConfig{ProxyMetadata: map[string]string{HardwareKeySocketMetadata: s}}
+	}
+	cases := []struct {
+		name        string
+		env         string
+		proxyConfig *meshconfig.ProxyConfig
+		options     security.Options
+		want        bool
+	}{
+		{
+			name:        "unset",
+			proxyConfig: &meshconfig.ProxyConfig{},
+		},
+		{
+			name:        "proxy config",
+			proxyConfig: withSocket(socket),
+			want:        true,
+		},
+		{
+			name:        "env",
+			env:         socket,
+			proxyConfig: &meshconfig.ProxyConfig{},
+			want:        true,
+		},
+		{
+			name:        "proxy config overrides env",
+			env:         socket,
+			proxyConfig: withSocket(""),
+		},
+		{
+			name:        "no key service on the node",
+			proxyConfig: withSocket(missing),
+		},
+		{
+			name:        "file mounted certs",
+			proxyConfig: withSocket(socket),
+			options:     security.Options{FileMountedCerts: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &hardwareKeySocketEnv, tt.env)
+			o := tt.options
+			setupKeyProvider(tt.proxyConfig, &o)
+			if got := o.KeyProvider != nil; got != tt.want {
+				t.Fatalf("got key provider %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -121,6 +121,25 @@ var (
 	wasmHTTPRequestMaxRetries = env.Register("WASM_HTTP_REQUEST_MAX_RETRIES", wasm.DefaultHTTPRequestMaxRetries,
 		"maximum number of HTTP/HTTPS request retries for pulling a Wasm module via http/https").Get()
 
+	// Added by ingress
+	ecdsFallbackEnv = env.Register("ECDS_FALLBACK_ENABLED", false,
+		"If set to true, agent persists the last ECDS resources ACKed by Envoy, and serves them to Envoy "+
+			"when istiod is unreachable, so the Wasm filter configs are restored on restarts during istiod outages").Get()
+
+	outlierEventReportURLEnv = env.Register("OUTLIER_EVENT_REPORT_URL", "",
+		"If set, the URL of the /debug/outlierz endpoint of istiod, such as http://istiod.istio-system:15014/debug/outlierz, "+
+			"the outlier detection events written by Envoy to the file of --outlierLogPath are reported to").Get()
+
+	wasmStatsReportURLEnv = env.Register("WASM_STATS_REPORT_URL", "",
+		"If set, the URL of the /debug/wasm_statsz endpoint of istiod, such as http://istiod.istio-system:15014/debug/wasm_statsz, "+
+			"the Wasm stats of Envoy are periodically reported to").Get()
+
+	dnsOverTLSResolversEnv = env.Register("DNS_OVER_TLS_RESOLVERS", "",
+		"If set, the agent forwards the DNS queries of the clusters resolving by \"dot\", as set by PILOT_DNS_RESOLVERS "+
+			"or the higress.io/dns-resolvers annotation of the ServiceEntries, to these DNS-over-TLS resolvers, as a "+
+			"comma separated list of host:port#server-name, the port being 853 and the server name the host by default").Get()
+	// End added by ingress
+
 	// Ability of istio-agent to retrieve bootstrap via XDS
 	enableBootstrapXdsEnv = env.Register("BOOTSTRAP_XDS_AGENT", false,
 		"If set to true, agent retrieves the bootstrap configuration prior to starting Envoy").Get()
@@ -64,6 +64,9 @@ func NewSecurityOptions(proxyConfig *meshconfig.ProxyConfig, stsPort int, tokenM
 	if err != nil {
 		return o, err
 	}
+	// Added by ingress
+	setupKeyProvider(proxyConfig, o)
+	// End added by ingress
 
 	var tokenManager security.TokenManager
 	if stsPort > 0 || xdsAuthProvider.Get() != "" {
@@ -37,6 +37,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/networking/util"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
 	"istio.io/istio/pkg/kube/controllers"
@@ -156,6 +157,11 @@ func (c *Controller) Run(stop <-chan struct{}) {
 
 	go c.queue.Run(stop)
 	go c.healthController.Run(stop)
+	// Added by ingress
+	if features.WorkloadEntryHealthChecks && alifeatures.WorkloadEntryActiveHealthChecks {
+		go health.NewProber(c.store, c.stateStore, c.IsControllerOf).Run(stop)
+	}
+	// End added by ingress
 	<-stop
 }
 
@@ -474,9 +480,29 @@ func (c *Controller) QueueWorkloadEntryHealth(proxy *model.Proxy, event HealthEv
 	if !features.WorkloadEntryHealthChecks {
 		return
 	}
+	// Added by ingress
+	if c.activelyProbed(proxy) {
+		// The health of the WorkloadEntry is the one probed by pilot.
+		return
+	}
+	// End added by ingress
 	c.healthController.QueueWorkloadEntryHealth(proxy, event)
 }
 
+// Added by ingress
+
+// activelyProbed returns true if the WorkloadEntry auto-registered for a proxy is health checked by the prober of
+// pilot rather than by its agent.
+func (c *Controller) activelyProbed(proxy *model.Proxy) bool {
+	if !alifeatures.WorkloadEntryActiveHealthChecks || proxy.Metadata.AutoRegisterGroup == "" {
+		return false
+	}
+	groupCfg := c.store.Get(gvk.WorkloadGroup, proxy.Metadata.AutoRegisterGroup, proxy.Metadata.Namespace)
+	return groupCfg != nil && health.ActiveProbe(groupCfg.Spec.(*v1alpha3.WorkloadGroup)) != nil
+}
+
+// End added by ingress
+
 // periodicWorkloadEntryCleanup checks lists all WorkloadEntry
 func (c *Controller) periodicWorkloadEntryCleanup(stopCh <-chan struct{}) {
 	if !features.WorkloadEntryAutoRegistration && !features.WorkloadEntryHealthChecks {
@@ -667,6 +693,11 @@ func workloadEntryFromGroup(name string, proxy *model.Proxy, groupCfg *config.Co
 	if proxy.Metadata.ProxyConfig != nil && proxy.Metadata.ProxyConfig.ReadinessProbe != nil {
 		annotations[status.WorkloadEntryHealthCheckAnnotation] = "true"
 	}
+	// Added by ingress
+	if alifeatures.WorkloadEntryActiveHealthChecks && health.ActiveProbe(group) != nil {
+		annotations[status.WorkloadEntryHealthCheckAnnotation] = "true"
+	}
+	// End added by ingress
 	return &config.Config{
 		Meta: config.Meta{
 			GroupVersionKind: gvk.WorkloadEntry,
@@ -0,0 +1,273 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"istio.io/api/annotation"
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/autoregistration/internal/state"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+const (
+	// proberTick is how often the prober looks for the WorkloadEntries due to be probed.
+	proberTick = time.Second
+
+	defaultProbePeriod  = 10 * time.Second
+	defaultProbeTimeout = time.Second
+)
+
+// ActiveProbe returns the probe of a WorkloadGroup pilot runs itself, its HTTP or TCP readiness probe, or nil if it
+// has none or an exec probe only the agents can run.
+func ActiveProbe(group *v1alpha3.WorkloadGroup) *v1alpha3.ReadinessProbe {
+	probe := group.GetProbe()
+	if probe.GetHttpGet() == nil && probe.GetTcpSocket() == nil {
+		return nil
+	}
+	return probe
+}
+
+// probeState is the state of the probes of a WorkloadEntry.
+type probeState struct {
+	next      time.Time
+	successes int32
+	failures  int32
+	// healthy is the health of the entry last reported, nil until the thresholds are first reached.
+	healthy *bool
+}
+
+// Prober actively health checks the WorkloadEntries auto-registered by this istiod with the HTTP and TCP readiness
+// probes of their WorkloadGroup, rather than relying on the health reported by their agents. It updates the health
+// condition of the entries once the success or failure threshold of the probe is reached.
+type Prober struct {
+	store      model.ConfigStoreController
+	stateStore *state.Store
+	isOwned    func(wle *config.Config) bool
+
+	mu     sync.Mutex
+	states map[string]*probeState
+
+	// probe runs a probe against an address, replaced by the tests.
+	probe func(ctx context.Context, address string, probe *v1alpha3.ReadinessProbe) error
+}
+
+// NewProber creates a Prober of the WorkloadEntries of the store for which isOwned is true.
+func NewProber(store model.ConfigStoreController, stateStore *state.Store, isOwned func(wle *config.Config) bool) *Prober {
+	return &Prober{
+		store:      store,
+		stateStore: stateStore,
+		isOwned:    isOwned,
+		states:     map[string]*probeState{},
+		probe:      runProbe,
+	}
+}
+
+// Run probes the WorkloadEntries until the stop channel is closed.
+func (p *Prober) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(proberTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			p.probeDue(now)
+		}
+	}
+}
+
+// probeDue starts the probes of the WorkloadEntries due, forgetting the entries gone.
+func (p *Prober) probeDue(now time.Time) {
+	seen := map[string]struct{}{}
+	for _, wle := range p.store.List(gvk.WorkloadEntry, "") {
+		wle := wle
+		group := wle.Annotations[annotation.IoIstioAutoRegistrationGroup.Name]
+		if group == "" || !p.isOwned(&wle) {
+			continue
+		}
+		groupCfg := p.store.Get(gvk.WorkloadGroup, group, wle.Namespace)
+		if groupCfg == nil {
+			continue
+		}
+		probe := ActiveProbe(groupCfg.Spec.(*v1alpha3.WorkloadGroup))
+		if probe == nil {
+			continue
+		}
+		key := wle.Namespace + "/" + wle.Name
+		seen[key] = struct{}{}
+		p.mu.Lock()
+		s, ok := p.states[key]
+		if !ok {
+			s = &probeState{next: now.Add(time.Duration(probe.InitialDelaySeconds) * time.Second)}
+			p.states[key] = s
+		}
+		due := !now.Before(s.next)
+		if due {
+			// The next probe is only scheduled once this one completes.
+			s.next = now.Add(24 * time.Hour)
+		}
+		p.mu.Unlock()
+		if due {
+			go p.probeEntry(key, wle, probe)
+		}
+	}
+	p.mu.Lock()
+	for key := range p.states {
+		if _, ok := seen[key]; !ok {
+			delete(p.states, key)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// probeEntry probes a WorkloadEntry, and updates its health condition when a threshold is reached.
+func (p *Prober) probeEntry(key string, wle config.Config, probe *v1alpha3.ReadinessProbe) {
+	timeout := durationOrDefault(probe.TimeoutSeconds, defaultProbeTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	err := p.probe(ctx, wle.Spec.(*v1alpha3.WorkloadEntry).Address, probe)
+	cancel()
+
+	p.mu.Lock()
+	s, ok := p.states[key]
+	if !ok {
+		p.mu.Unlock()
+		return
+	}
+	s.next = time.Now().Add(durationOrDefault(probe.PeriodSeconds, defaultProbePeriod))
+	var report *bool
+	if err == nil {
+		s.successes, s.failures = s.successes+1, 0
+		if s.successes >= thresholdOrDefault(probe.SuccessThreshold) && (s.healthy == nil || !*s.healthy) {
+			healthy := true
+			s.healthy, report = &healthy, &healthy
+		}
+	} else {
+		s.successes, s.failures = 0, s.failures+1
+		if s.failures >= thresholdOrDefault(probe.FailureThreshold) && (s.healthy == nil || *s.healthy) {
+			healthy := false
+			s.healthy, report = &healthy, &healthy
+		}
+	}
+	p.mu.Unlock()
+	if report == nil {
+		return
+	}
+
+	condition := &v1alpha1.IstioCondition{
+		Type:               status.ConditionHealthy,
+		Status:             status.StatusTrue,
+		LastProbeTime:      timestamppb.Now(),
+		LastTransitionTime: timestamppb.Now(),
+	}
+	if !*report {
+		condition.Status = status.StatusFalse
+		condition.Message = err.Error()
+	}
+	if err := p.stateStore.UpdateHealth(key, wle.Name, wle.Namespace, condition); err != nil {
+		log.Warnf("failed to update the health of WorkloadEntry %s: %v", key, err)
+		// Report again after the next probe.
+		p.mu.Lock()
+		s.healthy = nil
+		p.mu.Unlock()
+	}
+}
+
+func durationOrDefault(seconds int32, d time.Duration) time.Duration {
+	if seconds <= 0 {
+		return d
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func thresholdOrDefault(threshold int32) int32 {
+	if threshold <= 0 {
+		return 1
+	}
+	return threshold
+}
+
+// runProbe runs the HTTP or TCP probe against the address of a WorkloadEntry.
+func runProbe(ctx context.Context, address string, probe *v1alpha3.ReadinessProbe) error {
+	if tcp := probe.GetTcpSocket(); tcp != nil {
+		host := tcp.Host
+		if host == "" {
+			host = address
+		}
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(int(tcp.Port))))
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	httpGet := probe.GetHttpGet()
+	host := httpGet.Host
+	if host == "" {
+		host = address
+	}
+	scheme := "http"
+	if httpGet.Scheme == "HTTPS" || httpGet.Scheme == "https" {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, strconv.Itoa(int(httpGet.Port))), Path: httpGet.Path}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	for _, h := range httpGet.HttpHeaders {
+		if h.Name == "Host" || h.Name == "host" {
+			req.Host = h.Value
+			continue
+		}
+		req.Header.Add(h.Name, h.Value)
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			// As the kubelet, the certificates of the workloads are not verified.
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true}, // nolint: gosec
+			DisableKeepAlives: true,
+		},
+		// The redirects are not followed.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("HTTP probe failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"istio.io/api/annotation"
+	"istio.io/api/meta/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/autoregistration/internal/state"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model/status"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+type ownsAll struct{}
+
+func (ownsAll) IsControllerOf(*config.Config) bool { return true }
+
+func TestRunProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" || r.Header.Get("X-Probe") != "pilot" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	host, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	cases := []struct {
+		name    string
+		probe   *v1alpha3.ReadinessProbe
+		healthy bool
+	}{
+		{
+			name: "http ready",
+			probe: &v1alpha3.ReadinessProbe{HealthCheckMethod: &v1alpha3.ReadinessProbe_HttpGet{HttpGet: &v1alpha3.HTTPHealthCheckConfig{
+				Path: "/ready", Port: uint32(port), HttpHeaders: []*v1alpha3.HTTPHeader{{Name: "X-Probe", Value: "pilot"}},
+			}}},
+			healthy: true,
+		},
+		{
+			name: "http not ready",
+			probe: &v1alpha3.ReadinessProbe{HealthCheckMethod: &v1alpha3.ReadinessProbe_HttpGet{HttpGet: &v1alpha3.HTTPHealthCheckConfig{
+				Path: "/ready", Port: uint32(port),
+			}}},
+		},
+		{
+			name: "tcp open",
+			probe: &v1alpha3.ReadinessProbe{HealthCheckMethod: &v1alpha3.ReadinessProbe_TcpSocket{TcpSocket: &v1alpha3.TCPHealthCheckConfig{
+				Port: uint32(port),
+			}}},
+			healthy: true,
+		},
+		{
+			name: "tcp closed",
+			probe: &v1alpha3.ReadinessProbe{HealthCheckMethod: &v1alpha3.ReadinessProbe_TcpSocket{TcpSocket: &v1alpha3.TCPHealthCheckConfig{
+				Port: uint32(closedPort),
+			}}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := runProbe(ctx, host, tt.probe)
+			if (err == nil) != tt.healthy {
+				t.Fatalf("expected healthy %v, got error %v", tt.healthy, err)
+			}
+		})
+	}
+}
+
+func TestActiveProbe(t *testing.T) {
+	if ActiveProbe(&v1alpha3.WorkloadGroup{}) != nil {
+		t.Fatalf("expected no probe without a probe")
+	}
+	exec := &v1alpha3.WorkloadGroup{Probe: &v1alpha3.ReadinessProbe{
+		HealthCheckMethod: &v1alpha3.ReadinessProbe_Exec{Exec: &v1alpha3.ExecHealthCheckConfig{Command: []string{"true"}}},
+	}}
+	if ActiveProbe(exec) != nil {
+		t.Fatalf("expected no probe for an exec probe")
+	}
+}
+
+func TestProberThresholds(t *testing.T) {
+	store := memory.NewController(memory.Make(collections.All))
+	probe := &v1alpha3.ReadinessProbe{
+		// The probes are run by the test rather than by probeDue.
+		InitialDelaySeconds: 60,
+		SuccessThreshold:    2,
+		FailureThreshold:    2,
+		HealthCheckMethod: &v1alpha3.ReadinessProbe_TcpSocket{TcpSocket: &v1alpha3.TCPHealthCheckConfig{
+			Port: 8080,
+		}},
+	}
+	if _, err := store.Create(config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.WorkloadGroup, Name: "group", Namespace: "ns"},
+		Spec: &v1alpha3.WorkloadGroup{Template: &v1alpha3.WorkloadEntry{}, Probe: probe},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wle := config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.WorkloadEntry, Name: "group-10.0.0.1", Namespace: "ns",
+			Annotations: map[string]string{annotation.IoIstioAutoRegistrationGroup.Name: "group"},
+		},
+		Spec:   &v1alpha3.WorkloadEntry{Address: "10.0.0.1"},
+		Status: &v1alpha1.IstioStatus{},
+	}
+	if _, err := store.Create(wle); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewProber(store, state.NewStore(store, ownsAll{}), func(*config.Config) bool { return true })
+	var probeErr error
+	p.probe = func(_ context.Context, address string, _ *v1alpha3.ReadinessProbe) error {
+		if address != "10.0.0.1" {
+			t.Errorf("unexpected address %s", address)
+		}
+		return probeErr
+	}
+	health := func() *v1alpha1.IstioCondition {
+		cfg := store.Get(gvk.WorkloadEntry, wle.Name, wle.Namespace)
+		return status.GetCondition(cfg.Status.(*v1alpha1.IstioStatus).Conditions, status.ConditionHealthy)
+	}
+	key := wle.Namespace + "/" + wle.Name
+
+	p.probeDue(time.Now())
+	if _, ok := p.states[key]; !ok {
+		t.Fatalf("expected the WorkloadEntry to be probed")
+	}
+	p.probeEntry(key, wle, probe)
+	if health() != nil {
+		t.Fatalf("expected no health before the success threshold, got %v", health())
+	}
+	p.probeEntry(key, wle, probe)
+	if c := health(); c == nil || c.Status != status.StatusTrue {
+		t.Fatalf("expected healthy, got %v", c)
+	}
+
+	probeErr = errors.New("connection refused")
+	p.probeEntry(key, wle, probe)
+	if c := health(); c.Status != status.StatusTrue {
+		t.Fatalf("expected healthy before the failure threshold, got %v", c)
+	}
+	p.probeEntry(key, wle, probe)
+	if c := health(); c.Status != status.StatusFalse || c.Message != "connection refused" {
+		t.Fatalf("expected unhealthy, got %v", c)
+	}
+
+	if err := store.Delete(gvk.WorkloadEntry, wle.Name, wle.Namespace, nil); err != nil {
+		t.Fatal(err)
+	}
+	p.probeDue(time.Now())
+	if _, ok := p.states[key]; ok {
+		t.Fatalf("expected the state of the deleted WorkloadEntry to be forgotten")
+	}
+}
@@ -17,6 +17,7 @@ package bootstrap
 import (
 	"fmt"
 	"net/url"
+	"strconv"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -24,11 +25,13 @@ import (
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	"istio.io/istio/pilot/pkg/autoregistration"
 	configaggregate "istio.io/istio/pilot/pkg/config/aggregate"
+	"istio.io/istio/pilot/pkg/config/failover"
 	"istio.io/istio/pilot/pkg/config/kube/crdclient"
 	"istio.io/istio/pilot/pkg/config/kube/gateway"
 	ingress "istio.io/istio/pilot/pkg/config/kube/ingress"
 	"istio.io/istio/pilot/pkg/config/memory"
 	configmonitor "istio.io/istio/pilot/pkg/config/monitor"
+	"istio.io/istio/pilot/pkg/config/transform"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/model"
@@ -116,6 +119,12 @@ func (s *Server) initConfigController(args *PilotArgs) error {
 		})
 	}
 
+	// Added by ingress
+	if err := s.initExternalRegistries(args); err != nil {
+		return err
+	}
+	// End added by ingress
+
 	// Wrap the config controller with a cache.
 	aggregateConfigController, err := configaggregate.MakeCache(s.ConfigStores)
 	if err != nil {
@@ -210,7 +219,14 @@ func (s *Server) initK8SConfigStore(args *PilotArgs) error {
 // initConfigSources will process mesh config 'configSources' and initialize
 // associated configs.
 func (s *Server) initConfigSources(args *PilotArgs) (err error) {
-	for _, configSource := range s.environment.Mesh().ConfigSources {
+	// Added by ingress
+	var failoverSources []failover.Source
+	transforms, err := transform.ParseRules(alifeatures.ConfigSourceTransforms)
+	if err != nil {
+		return err
+	}
+	// End added by ingress
+	for i, configSource := range s.environment.Mesh().ConfigSources {
 		srcAddress, err := url.Parse(configSource.Address)
 		if err != nil {
 			return fmt.Errorf("invalid config URL %s %v", configSource.Address, err)
@@ -228,7 +244,9 @@ func (s *Server) initConfigSources(args *PilotArgs) (err error) {
 			if err != nil {
 				return err
 			}
-			s.ConfigStores = append(s.ConfigStores, configController)
+			// Modified by ingress
+			s.ConfigStores = append(s.ConfigStores, transform.Wrap(configSource.Address, configController, transforms))
+			// End modified by ingress
 			log.Infof("Started File configSource %s", configSource.Address)
 		case XDS:
 			xdsMCP, err := adsc.New(srcAddress.Host, &adsc.Config{
@@ -259,11 +277,33 @@ func (s *Server) initConfigSources(args *PilotArgs) (err error) {
 			configController := memory.NewController(store)
 			configController.RegisterHasSyncedHandler(xdsMCP.HasSynced)
 			xdsMCP.Store = configController
+			// Added by ingress
+			if alifeatures.XDSConfigSourceFailover {
+				priority, err := configSourcePriority(srcAddress, i)
+				if err != nil {
+					return err
+				}
+				// The sources failing over tolerate the outages of their servers, at startup as well.
+				xdsMCP.RunWithRetry()
+				failoverSources = append(failoverSources, failover.Source{
+					Name:     configSource.Address,
+					Priority: priority,
+					Store:    transform.Wrap(configSource.Address, configController, transforms),
+					Healthy: func() bool {
+						return xdsMCP.Connected() && xdsMCP.HasSynced()
+					},
+				})
+				log.Infof("Started XDS configSource %s with priority %d", configSource.Address, priority)
+				continue
+			}
+			// End added by ingress
 			err = xdsMCP.Run()
 			if err != nil {
 				return fmt.Errorf("MCP: failed running %v", err)
 			}
-			s.ConfigStores = append(s.ConfigStores, configController)
+			// Modified by ingress
+			s.ConfigStores = append(s.ConfigStores, transform.Wrap(configSource.Address, configController, transforms))
+			// End modified by ingress
 			log.Infof("Started XDS configSource %s", configSource.Address)
 		case Kubernetes:
 			if srcAddress.Path == "" || srcAddress.Path == "/" {
@@ -282,9 +322,33 @@ func (s *Server) initConfigSources(args *PilotArgs) (err error) {
 			log.Warnf("Ignoring unsupported config source: %v", configSource.Address)
 		}
 	}
+	// Added by ingress
+	if len(failoverSources) > 0 {
+		s.ConfigStores = append(s.ConfigStores,
+			failover.NewStore(failoverSources, alifeatures.XDSConfigSourceHealthCheckInterval))
+	}
+	// End added by ingress
 	return nil
 }
 
+// Added by ingress
+
+// configSourcePriority returns the priority of a xds:// config source failing over, set by the priority query
+// parameter of its address, else its index in the config sources.
+func configSourcePriority(address *url.URL, index int) (int, error) {
+	priority := address.Query().Get("priority")
+	if priority == "" {
+		return index, nil
+	}
+	p, err := strconv.Atoi(priority)
+	if err != nil {
+		return 0, fmt.Errorf("invalid priority of config source %s: %v", address, err)
+	}
+	return p, nil
+}
+
+// End added by ingress
+
 // initInprocessAnalysisController spins up an instance of Galley which serves no purpose other than
 // running Analyzers for status updates.  The Status Updater will eventually need to allow input from istiod
 // to support config distribution status as well.
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"time"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/serviceregistry/consul"
+	"istio.io/istio/pilot/pkg/serviceregistry/eureka"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/nacos"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pilot/pkg/serviceregistry/zookeeper"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// initExternalRegistries adds a config store for each registry external to the mesh, the services of which are
+// synced into it as ServiceEntries, of the system namespace unless merged with a Kubernetes service.
+func (s *Server) initExternalRegistries(args *PilotArgs) error {
+	for _, r := range args.RegistryOptions.Registries {
+		var source external.Source
+		var interval time.Duration
+		switch provider.ID(r) {
+		case provider.Nacos:
+			opts, err := nacos.ParseOptions(alifeatures.NacosRegistry)
+			if err != nil {
+				return err
+			}
+			source, interval = nacos.NewSource(opts), opts.Interval()
+		case provider.Consul:
+			opts, err := consul.ParseOptions(alifeatures.ConsulRegistry)
+			if err != nil {
+				return err
+			}
+			source, interval = consul.NewSource(opts), opts.Interval()
+		case provider.Eureka:
+			opts, err := eureka.ParseOptions(alifeatures.EurekaRegistry)
+			if err != nil {
+				return err
+			}
+			source, interval = eureka.NewSource(opts), opts.Interval()
+		case provider.Zookeeper:
+			opts, err := zookeeper.ParseOptions(alifeatures.ZookeeperRegistry)
+			if err != nil {
+				return err
+			}
+			source, interval = zookeeper.NewSource(opts), opts.Interval()
+		default:
+			continue
+		}
+		s.addExternalRegistry(args, source, interval)
+	}
+	return nil
+}
+
+func (s *Server) addExternalRegistry(args *PilotArgs, source external.Source, interval time.Duration) {
+	store := memory.NewController(memory.Make(collections.Pilot))
+	controller := external.NewController(source, store, args.Namespace, interval)
+	store.RegisterHasSyncedHandler(controller.HasSynced)
+	s.ConfigStores = append(s.ConfigStores, store)
+	s.addStartFunc(source.Provider().String()+" registry", func(stop <-chan struct{}) error {
+		go controller.Run(stop)
+		return nil
+	})
+}
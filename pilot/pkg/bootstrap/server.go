@@ -35,14 +35,20 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"istio.io/api/security/v1beta1"
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	cloudcredentials "istio.io/istio/pilot/pkg/credentials/cloud"
+	filecredentials "istio.io/istio/pilot/pkg/credentials/file"
 	kubecredentials "istio.io/istio/pilot/pkg/credentials/kube"
+	"istio.io/istio/pilot/pkg/descriptorset"
 	"istio.io/istio/pilot/pkg/features"
 	istiogrpc "istio.io/istio/pilot/pkg/grpc"
 	"istio.io/istio/pilot/pkg/keycertbundle"
 	"istio.io/istio/pilot/pkg/model"
+	credsmodel "istio.io/istio/pilot/pkg/model/credentials"
 	"istio.io/istio/pilot/pkg/server"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
@@ -51,6 +57,7 @@ import (
 	"istio.io/istio/pilot/pkg/status/distribution"
 	tb "istio.io/istio/pilot/pkg/trustbundle"
 	"istio.io/istio/pilot/pkg/xds"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
@@ -239,6 +246,14 @@ func NewServer(args *PilotArgs, initFuncs ...func(*Server)) (*Server, error) {
 	// Initialize workload Trust Bundle before XDS Server
 	e.TrustBundle = s.workloadTrustBundle
 	s.XDSServer = xds.NewDiscoveryServer(e, args.PodName, s.clusterID, args.RegistryOptions.KubeOptions.ClusterAliases)
+	// Added by Ingress
+	if err := s.initXdsResourceCache(); err != nil {
+		return nil, fmt.Errorf("error initializing xds resource cache: %v", err)
+	}
+	if err := s.initPushTracing(); err != nil {
+		return nil, fmt.Errorf("error initializing push tracing: %v", err)
+	}
+	// End added by Ingress
 
 	grpcprom.EnableHandlingTimeHistogram()
 
@@ -486,6 +501,9 @@ func (s *Server) WaitUntilCompletion() {
 
 // initSDSServer starts the SDS server
 func (s *Server) initSDSServer() {
+	// Added by ingress
+	s.initExternalCredentials()
+	// End added by ingress
 	if s.kubeClient == nil {
 		return
 	}
@@ -507,6 +525,94 @@ func (s *Server) initSDSServer() {
 	}
 }
 
+// Added by ingress
+
+// initExternalCredentials serves the credentials stored outside of Kubernetes: the file:// credentials of the
+// credential directory, and the ones of the cloud secret managers enabled.
+func (s *Server) initExternalCredentials() {
+	if alifeatures.SDSCredentialDir == "" && !alifeatures.SDSAWSSecretsManager && alifeatures.SDSAlibabaKMSRegion == "" {
+		return
+	}
+	if !features.EnableXDSIdentityCheck {
+		log.Warnf("skipping external credential readers; PILOT_ENABLE_XDS_IDENTITY_CHECK must be set to true for this feature.")
+		return
+	}
+	if alifeatures.SDSCredentialDir != "" {
+		files := filecredentials.NewController(alifeatures.SDSCredentialDir)
+		s.addExternalCredentials(credsmodel.FileSecretType, files, files.Run)
+	}
+	if alifeatures.SDSAWSSecretsManager {
+		if provider, err := cloudcredentials.NewAWSSecretsManager(); err != nil {
+			log.Errorf("failed to read credentials from AWS Secrets Manager: %v", err)
+		} else {
+			s.addCloudCredentials(credsmodel.AWSSecretsManagerSecretType, provider)
+		}
+	}
+	if alifeatures.SDSAlibabaKMSRegion != "" {
+		if provider, err := cloudcredentials.NewAlibabaKMS(alifeatures.SDSAlibabaKMSRegion); err != nil {
+			log.Errorf("failed to read credentials from Alibaba Cloud KMS: %v", err)
+		} else {
+			s.addCloudCredentials(credsmodel.AlibabaKMSSecretType, provider)
+		}
+	}
+}
+
+func (s *Server) addCloudCredentials(secretType string, provider cloudcredentials.Provider) {
+	c := cloudcredentials.NewController(secretType, provider, alifeatures.SDSCloudCredentialRefreshInterval)
+	s.addExternalCredentials(secretType, c, c.Run)
+}
+
+// addExternalCredentials serves the credentials of the type from the controller, run until pilot stops.
+func (s *Server) addExternalCredentials(secretType string, c credscontroller.Controller, run func(stop <-chan struct{}) error) {
+	c.AddEventHandler(func(name string, namespace string) {
+		s.XDSServer.ConfigUpdate(&model.PushRequest{
+			Full:           false,
+			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Secret, Name: name, Namespace: namespace}),
+			Reason:         model.NewReasonStats(model.SecretTrigger),
+		})
+	})
+	s.addStartFunc(secretType+" credentials", func(stop <-chan struct{}) error {
+		go func() {
+			if err := run(stop); err != nil {
+				log.Errorf("failed to watch %s credentials: %v", secretType, err)
+			}
+		}()
+		return nil
+	})
+	if s.environment.ExternalCredentialsControllers == nil {
+		s.environment.ExternalCredentialsControllers = map[string]credscontroller.Controller{}
+	}
+	s.environment.ExternalCredentialsControllers[secretType] = c
+}
+
+// initDescriptorSets serves the proto descriptor sets of the gRPC-JSON transcoding of the virtual services, pushing
+// the routes again when they are fetched or change.
+func (s *Server) initDescriptorSets() {
+	if !alifeatures.EnableGrpcJSONTranscoding {
+		return
+	}
+	var client kubernetes.Interface
+	if s.kubeClient != nil {
+		client = s.kubeClient.Kube()
+	}
+	c := descriptorset.NewController(client, alifeatures.GrpcDescriptorSetRefreshInterval)
+	c.AddEventHandler(func(source string) {
+		s.XDSServer.ConfigUpdate(&model.PushRequest{
+			Full:   true,
+			Reason: model.NewReasonStats(model.GlobalUpdate),
+		})
+	})
+	s.addStartFunc("descriptor sets", func(stop <-chan struct{}) error {
+		go func() {
+			_ = c.Run(stop)
+		}()
+		return nil
+	})
+	s.environment.DescriptorSets = c
+}
+
+// End added by ingress
+
 // initKubeClient creates the k8s client if running in a k8s environment.
 // This is determined by the presence of a kube registry, which
 // uses in-context k8s, or a config source of type k8s.
@@ -571,6 +677,12 @@ func (s *Server) istiodReadyHandler(w http.ResponseWriter, _ *http.Request) {
 		}
 	}
 	w.WriteHeader(http.StatusOK)
+	// Added by Ingress
+	if state := s.XDSServer.ServingState(); state != xds.ServingLive {
+		// Ready, but degraded.
+		_, _ = fmt.Fprintf(w, "discovery: %s\n", state)
+	}
+	// End added by Ingress
 }
 
 // initServers initializes http and grpc servers
@@ -1113,6 +1225,9 @@ func (s *Server) initControllers(args *PilotArgs) error {
 	s.initMulticluster(args)
 
 	s.initSDSServer()
+	// Added by ingress
+	s.initDescriptorSets()
+	// End added by ingress
 
 	if features.EnableEnhancedResourceScoping {
 		// setup namespace filter
@@ -1336,7 +1451,8 @@ func (s *Server) serveHTTP() error {
 func (s *Server) initReadinessProbes() {
 	probes := map[string]readinessProbe{
 		"discovery": func() bool {
-			return s.XDSServer.IsServerReady()
+			// Modified by Ingress
+			return s.XDSServer.IsServerReady() || s.XDSServer.IsStandby()
 		},
 		"sidecar injector": func() bool {
 			return s.readinessFlags.sidecarInjectorReady.Load()
@@ -52,6 +52,10 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 			if err := s.initKubeRegistry(args); err != nil {
 				return err
 			}
+		// Added by ingress
+		case provider.Nacos, provider.Consul, provider.Eureka, provider.Zookeeper:
+			// Synced as ServiceEntries by initExternalRegistries.
+		// End added by ingress
 		default:
 			return fmt.Errorf("service registry %s is not supported", r)
 		}
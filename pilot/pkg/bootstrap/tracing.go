@@ -0,0 +1,39 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/tracing"
+)
+
+// initPushTracing sets up the OpenTelemetry exporter of the push traces, if enabled.
+func (s *Server) initPushTracing() error {
+	if !alifeatures.EnablePushTracing {
+		return nil
+	}
+	shutdown, err := tracing.Initialize()
+	if err != nil {
+		return err
+	}
+	log.Info("xds push tracing enabled")
+	s.addTerminatingStartFunc("push tracing", func(stop <-chan struct{}) error {
+		<-stop
+		shutdown()
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"strings"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/cache"
+	"istio.io/istio/pkg/log"
+)
+
+// initXdsResourceCache creates the cache of the last ACKed xDS responses, if enabled, and
+// warms it up before the discovery server starts serving.
+func (s *Server) initXdsResourceCache() error {
+	opts := cache.XdsCacheOptions{
+		DefaultTTL:        alifeatures.XdsResourceCacheTTL,
+		MaxBytes:          alifeatures.XdsResourceCacheMaxBytes,
+		WarmupConcurrency: alifeatures.XdsResourceCacheWarmupConcurrency,
+	}
+	var rc cache.XdsResourceCache
+	var err error
+	switch {
+	case alifeatures.XdsResourceCacheRedisAddrs != "":
+		rc, err = cache.NewRedisXdsResourceCache(cache.RedisOptions{
+			Addrs:    strings.Split(alifeatures.XdsResourceCacheRedisAddrs, ","),
+			Cluster:  alifeatures.XdsResourceCacheRedisCluster,
+			Password: alifeatures.XdsResourceCacheRedisPassword,
+		}, opts)
+	case alifeatures.XdsResourceCacheDir != "":
+		rc, err = cache.NewFileXdsResourceCache(alifeatures.XdsResourceCacheDir, opts)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.XDSServer.ResourceCache = rc
+	cache.RegisterMetrics("xds_resource", rc)
+	// Start components run in order, so the cache is warm before the xds server is started.
+	s.addStartFunc("xds resource cache", func(stop <-chan struct{}) error {
+		log.Info("warming up xds resource cache")
+		rc.Initialize()
+		return nil
+	})
+	// The ACKed responses are written asynchronously, write the queued ones before exiting.
+	s.addTerminatingStartFunc("xds resource cache flush", func(stop <-chan struct{}) error {
+		<-stop
+		rc.Flush()
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failover implements a read-only config store serving the configs of the most preferred healthy of several
+// config sources, failing over to the next ones while it is unhealthy.
+package failover
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collection"
+	istiolog "istio.io/istio/pkg/log"
+)
+
+var log = istiolog.RegisterScope("failover", "config source failover")
+
+// defaultInterval is the interval of the health checks if none is set.
+const defaultInterval = 5 * time.Second
+
+var errorUnsupported = errors.New("unsupported operation: the failover config store is read-only")
+
+// Source is a config source of a failover Store.
+type Source struct {
+	// Name identifies the source in the logs.
+	Name string
+	// Priority orders the sources, the lowest first.
+	Priority int
+	// Store has the configs of the source, kept while it is unhealthy.
+	Store model.ConfigStoreController
+	// Healthy returns true while the source is connected and synced.
+	Healthy func() bool
+}
+
+// Store serves the configs of the first healthy of its sources by priority. When the active source becomes unhealthy,
+// it fails over to the next healthy source; and when a preferred source becomes healthy again, it fails back to it.
+// On each switch, the handlers receive the events reconciling the configs of the previous source with the configs of
+// the new one. While no source is healthy, the configs of the last active source are served.
+type Store struct {
+	schemas  collection.Schemas
+	sources  []Source
+	interval time.Duration
+
+	mu     sync.RWMutex
+	active int
+
+	// eventMu serializes the events of the active source and the events of the switches.
+	eventMu  sync.Mutex
+	handlers map[config.GroupVersionKind][]model.EventHandler
+}
+
+var _ model.ConfigStoreController = &Store{}
+
+// NewStore creates a failover Store of the sources with the same schemas, checking their health at the interval.
+func NewStore(sources []Source, interval time.Duration) *Store {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	sorted := append([]Source{}, sources...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	s := &Store{
+		sources:  sorted,
+		interval: interval,
+		handlers: map[config.GroupVersionKind][]model.EventHandler{},
+	}
+	if len(sorted) > 0 {
+		s.schemas = sorted[0].Store.Schemas()
+	}
+	for i := range sorted {
+		i := i
+		for _, schema := range s.schemas.All() {
+			sorted[i].Store.RegisterEventHandler(schema.GroupVersionKind(), func(old, curr config.Config, event model.Event) {
+				s.eventMu.Lock()
+				defer s.eventMu.Unlock()
+				if s.activeIndex() != i {
+					return
+				}
+				for _, h := range s.handlersOf(curr.GroupVersionKind) {
+					h(old, curr, event)
+				}
+			})
+		}
+	}
+	return s
+}
+
+func (s *Store) activeIndex() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+func (s *Store) activeStore() model.ConfigStoreController {
+	return s.sources[s.activeIndex()].Store
+}
+
+func (s *Store) handlersOf(kind config.GroupVersionKind) []model.EventHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlers[kind]
+}
+
+// Active returns the name of the active source.
+func (s *Store) Active() string {
+	return s.sources[s.activeIndex()].Name
+}
+
+func (s *Store) Schemas() collection.Schemas {
+	return s.schemas
+}
+
+func (s *Store) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	return s.activeStore().Get(typ, name, namespace)
+}
+
+func (s *Store) List(typ config.GroupVersionKind, namespace string) []config.Config {
+	return s.activeStore().List(typ, namespace)
+}
+
+func (s *Store) Create(config.Config) (string, error) {
+	return "", errorUnsupported
+}
+
+func (s *Store) Update(config.Config) (string, error) {
+	return "", errorUnsupported
+}
+
+func (s *Store) UpdateStatus(config.Config) (string, error) {
+	return "", errorUnsupported
+}
+
+func (s *Store) Patch(config.Config, config.PatchFunc) (string, error) {
+	return "", errorUnsupported
+}
+
+func (s *Store) Delete(config.GroupVersionKind, string, string, *string) error {
+	return errorUnsupported
+}
+
+func (s *Store) RegisterEventHandler(kind config.GroupVersionKind, handler model.EventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[kind] = append(s.handlers[kind], handler)
+}
+
+// HasSynced returns true once the active source has synced.
+func (s *Store) HasSynced() bool {
+	return s.activeStore().HasSynced()
+}
+
+// Run runs the stores of the sources, and checks the health of the sources until the stop channel is closed.
+func (s *Store) Run(stop <-chan struct{}) {
+	for _, src := range s.sources {
+		go src.Store.Run(stop)
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.checkHealth()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth switches to the first healthy source by priority, if it is not the active one.
+func (s *Store) checkHealth() {
+	next := -1
+	for i, src := range s.sources {
+		if src.Healthy() {
+			next = i
+			break
+		}
+	}
+	if next < 0 {
+		// Keep serving the configs of the last active source.
+		return
+	}
+	s.eventMu.Lock()
+	defer s.eventMu.Unlock()
+	prev := s.activeIndex()
+	if next == prev {
+		return
+	}
+	s.mu.Lock()
+	s.active = next
+	s.mu.Unlock()
+	log.Infof("config source switched from %s to %s", s.sources[prev].Name, s.sources[next].Name)
+	s.reconcile(s.sources[prev].Store, s.sources[next].Store)
+}
+
+// reconcile sends the events turning the configs of the previous source into the configs of the next one.
+func (s *Store) reconcile(prev, next model.ConfigStore) {
+	for _, schema := range s.schemas.All() {
+		handlers := s.handlersOf(schema.GroupVersionKind())
+		if len(handlers) == 0 {
+			continue
+		}
+		previous := map[types.NamespacedName]config.Config{}
+		for _, c := range prev.List(schema.GroupVersionKind(), model.NamespaceAll) {
+			previous[config.NamespacedName(c)] = c
+		}
+		for _, c := range next.List(schema.GroupVersionKind(), model.NamespaceAll) {
+			key := config.NamespacedName(c)
+			old, ok := previous[key]
+			delete(previous, key)
+			if !ok {
+				for _, h := range handlers {
+					h(config.Config{}, c, model.EventAdd)
+				}
+			} else if !sameConfig(old, c) {
+				for _, h := range handlers {
+					h(old, c, model.EventUpdate)
+				}
+			}
+		}
+		for _, c := range previous {
+			for _, h := range handlers {
+				h(c, c, model.EventDelete)
+			}
+		}
+	}
+}
+
+// sameConfig returns true if two configs from different sources are the same, regardless of their resource versions.
+func sameConfig(a, b config.Config) bool {
+	if !reflect.DeepEqual(a.Labels, b.Labels) || !reflect.DeepEqual(a.Annotations, b.Annotations) {
+		return false
+	}
+	am, aok := a.Spec.(proto.Message)
+	bm, bok := b.Spec.(proto.Message)
+	if aok && bok {
+		return proto.Equal(am, bm)
+	}
+	return reflect.DeepEqual(a.Spec, b.Spec)
+}
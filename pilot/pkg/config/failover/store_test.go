@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failover
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"go.uber.org/atomic"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func gateway(name, host string) config.Config {
+	return config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.Gateway, Name: name, Namespace: "ns"},
+		Spec: &networking.Gateway{Servers: []*networking.Server{{
+			Hosts: []string{host},
+			Port:  &networking.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+		}}},
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handle(_, curr config.Config, event model.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf("%s %s", event, curr.Name))
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.events
+	r.events = nil
+	sort.Strings(out)
+	return out
+}
+
+func TestFailover(t *testing.T) {
+	primary := memory.NewSyncController(memory.MakeSkipValidation(collections.Pilot))
+	secondary := memory.NewSyncController(memory.MakeSkipValidation(collections.Pilot))
+	primaryHealthy, secondaryHealthy := atomic.NewBool(true), atomic.NewBool(true)
+	for _, c := range []config.Config{gateway("shared", "a.example.com"), gateway("primary-only", "b.example.com")} {
+		if _, err := primary.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []config.Config{gateway("shared", "a.example.com"), gateway("secondary-only", "c.example.com")} {
+		if _, err := secondary.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewStore([]Source{
+		{Name: "secondary", Priority: 1, Store: secondary, Healthy: secondaryHealthy.Load},
+		{Name: "primary", Priority: 0, Store: primary, Healthy: primaryHealthy.Load},
+	}, 0)
+	r := &recorder{}
+	s.RegisterEventHandler(gvk.Gateway, r.handle)
+
+	expect := func(active string, names ...string) {
+		t.Helper()
+		if s.Active() != active {
+			t.Fatalf("expected %s to be active, got %s", active, s.Active())
+		}
+		got := []string{}
+		for _, c := range s.List(gvk.Gateway, "") {
+			got = append(got, c.Name)
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(names) {
+			t.Fatalf("expected configs %v, got %v", names, got)
+		}
+	}
+	expectEvents := func(events ...string) {
+		t.Helper()
+		if got := r.take(); fmt.Sprint(got) != fmt.Sprint(events) {
+			t.Fatalf("expected events %v, got %v", events, got)
+		}
+	}
+
+	s.checkHealth()
+	expect("primary", "primary-only", "shared")
+	expectEvents()
+
+	// The events of the inactive sources are not forwarded.
+	if _, err := secondary.Create(gateway("secondary-new", "d.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.Create(gateway("primary-new", "e.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents("add primary-new")
+
+	// Failover to the secondary, the shared config being the same.
+	primaryHealthy.Store(false)
+	s.checkHealth()
+	expect("secondary", "secondary-new", "secondary-only", "shared")
+	expectEvents("add secondary-new", "add secondary-only", "delete primary-new", "delete primary-only")
+
+	// Keep the configs of the last active source while no source is healthy.
+	secondaryHealthy.Store(false)
+	s.checkHealth()
+	expect("secondary", "secondary-new", "secondary-only", "shared")
+	expectEvents()
+
+	// Fail back to the primary once it recovers, reconciling the updated config.
+	shared := gateway("shared", "updated.example.com")
+	shared.ResourceVersion = primary.Get(gvk.Gateway, "shared", "ns").ResourceVersion
+	if _, err := primary.Update(shared); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents()
+	primaryHealthy.Store(true)
+	s.checkHealth()
+	expect("primary", "primary-new", "primary-only", "shared")
+	expectEvents("add primary-new", "add primary-only", "delete secondary-new", "delete secondary-only", "update shared")
+
+	if _, err := s.Create(gateway("write", "f.example.com")); err == nil {
+		t.Fatalf("expected the store to be read-only")
+	}
+}
@@ -339,13 +339,25 @@ func getLabelKeys(ns *corev1.Namespace) []string {
 
 func (c *Controller) secretEvent(name, namespace string) {
 	var impactedConfigs []model.ConfigKey
-	c.stateMu.RLock()
-	impactedConfigs = c.state.ResourceReferences[model.ConfigKey{
+	// Modified by ingress
+	secret := model.ConfigKey{
 		Kind:      kind.Secret,
 		Namespace: namespace,
 		Name:      name,
-	}]
+	}
+	c.stateMu.RLock()
+	impactedConfigs = c.state.ResourceReferences[secret]
+	lastErr, validated := c.state.SecretErrors[secret]
 	c.stateMu.RUnlock()
+	// End modified by ingress
+	// Added by ingress
+	// A rotation keeping the certificate valid only changes its content, pushed over SDS, so the gateways are not
+	// regenerated, and their listeners are left as they are rather than draining connections.
+	if len(impactedConfigs) > 0 && validated && !c.secretValidityChanged(secret, lastErr) {
+		log.Debugf("secret %s/%s content changed, leaving the gateways to SDS", namespace, name)
+		return
+	}
+	// End added by ingress
 	if len(impactedConfigs) > 0 {
 		log.Debugf("secret %s/%s changed, triggering secret handler", namespace, name)
 		for _, cfg := range impactedConfigs {
@@ -361,6 +373,22 @@ func (c *Controller) secretEvent(name, namespace string) {
 	}
 }
 
+// Added by ingress
+
+// secretValidityChanged returns true if validating the secret no longer gives the result of the last conversion.
+func (c *Controller) secretValidityChanged(secret model.ConfigKey, lastErr string) bool {
+	if c.credentialsController == nil {
+		return true
+	}
+	secrets, err := c.credentialsController.ForCluster(c.cluster)
+	if err != nil {
+		return true
+	}
+	return errorString(validateSecret(secrets, secret)) != lastErr
+}
+
+// End added by ingress
+
 // deepCopyStatus creates a copy of all configs, with a copy of the status field that we can mutate.
 // This allows our functions to call Status.Mutate, and then we can later persist all changes into the
 // API server.
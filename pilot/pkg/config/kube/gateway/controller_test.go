@@ -27,6 +27,7 @@ import (
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
+	kubecredentials "istio.io/istio/pilot/pkg/credentials/kube"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
@@ -35,9 +36,12 @@ import (
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/kclient/clienttest"
+	"istio.io/istio/pkg/kube/multicluster"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/testcerts"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -200,3 +204,54 @@ func TestNamespaceEvent(t *testing.T) {
 	ns.Update(ns2)
 	s.WaitOrFail(t, "xds full")
 }
+
+func TestSecretEvent(t *testing.T) {
+	clientSet := kube.NewFakeClient()
+	creds := kubecredentials.NewMulticluster("Kubernetes")
+	creds.ClusterAdded(&multicluster.Cluster{ID: "Kubernetes", Client: clientSet}, nil)
+	store := memory.NewController(memory.Make(collections.All))
+	c := NewController(clientSet, store, AlwaysReady, creds, controller.Options{ClusterID: "Kubernetes"})
+	s := xdsfake.NewFakeXDS()
+
+	c.RegisterEventHandler(gvk.Secret, func(_, cfg config.Config, _ model.Event) {
+		s.ConfigUpdate(&model.PushRequest{
+			Full:   true,
+			Reason: model.NewReasonStats(model.SecretTrigger),
+		})
+	})
+
+	stop := test.NewStop(t)
+	clientSet.RunAndWait(stop)
+	secretKey := model.ConfigKey{Kind: kind.Secret, Name: "cert", Namespace: "istio-system"}
+	c.state.ResourceReferences = map[model.ConfigKey][]model.ConfigKey{
+		secretKey: {{Kind: kind.KubernetesGateway, Name: "gateway", Namespace: "istio-system"}},
+	}
+	c.state.SecretErrors = map[model.ConfigKey]string{secretKey: ""}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "cert", Namespace: "istio-system"},
+		Data: map[string][]byte{
+			"tls.crt": testcerts.ServerCert,
+			"tls.key": testcerts.ServerKey,
+		},
+	}
+	secrets := clienttest.NewWriter[*v1.Secret](t, clientSet)
+	secrets.Create(secret)
+	s.AssertEmpty(t, time.Millisecond*10)
+
+	// Rotating the certificate only changes what is served over SDS.
+	secret.Data = map[string][]byte{
+		"tls.crt": testcerts.RotatedCert,
+		"tls.key": testcerts.RotatedKey,
+	}
+	secrets.Update(secret)
+	s.AssertEmpty(t, time.Millisecond*10)
+
+	// An invalid certificate changes the gateways.
+	secret.Data = map[string][]byte{
+		"tls.crt": testcerts.RotatedCert,
+		"tls.key": testcerts.ServerKey,
+	}
+	secrets.Update(secret)
+	s.WaitOrFail(t, "xds full")
+}
@@ -28,6 +28,7 @@ import (
 	k8sbeta "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	istio "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/credentials"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	creds "istio.io/istio/pilot/pkg/model/credentials"
@@ -61,6 +62,9 @@ func convertResources(r GatewayResources) IstioResources {
 		GatewayResources:   r,
 		AllowedReferences:  convertReferencePolicies(r),
 		resourceReferences: make(map[model.ConfigKey][]model.ConfigKey),
+		// Added by ingress
+		secretErrors: make(map[model.ConfigKey]string),
+		// End added by ingress
 	}
 
 	gw, gwMap, nsReferences := convertGateways(ctx)
@@ -81,6 +85,9 @@ func convertResources(r GatewayResources) IstioResources {
 	result.AllowedReferences = ctx.AllowedReferences
 	result.ReferencedNamespaceKeys = nsReferences
 	result.ResourceReferences = ctx.resourceReferences
+	// Added by ingress
+	result.SecretErrors = ctx.secretErrors
+	// End added by ingress
 	return result
 }
 
@@ -373,6 +380,9 @@ func buildHTTPVirtualServices(
 				// append parents
 				cfg.Annotations[constants.InternalParentNames] = fmt.Sprintf("%s,%s/%s.%s",
 					cfg.Annotations[constants.InternalParentNames], obj.GroupVersionKind.Kind, obj.Name, obj.Namespace)
+				// Added by ingress
+				model.MergeWasmPluginsAnnotation(cfg.Annotations, obj.Annotations)
+				// End added by ingress
 			} else {
 				name := fmt.Sprintf("%s-%d-%s", obj.Name, count, constants.KubernetesGatewayName)
 				routeMap[routeKey][h] = &config.Config{
@@ -488,6 +498,11 @@ func augmentTLSPortMatch(routes []*istio.TLSRoute, port *k8sbeta.PortNumber) ([]
 func routeMeta(obj config.Config) map[string]string {
 	m := parentMeta(obj, nil)
 	m[constants.InternalRouteSemantics] = constants.RouteSemanticsGateway
+	// Added by ingress
+	if selected, ok := obj.Annotations[constants.WasmPluginsAnnotation]; ok {
+		m[constants.WasmPluginsAnnotation] = selected
+	}
+	// End added by ingress
 	return m
 }
 
@@ -1443,6 +1458,11 @@ type configContext struct {
 
 	// key: referenced resources(e.g. secrets), value: gateway-api resources(e.g. gateways)
 	resourceReferences map[model.ConfigKey][]model.ConfigKey
+
+	// Added by ingress
+	// secretErrors are the results of validating the referenced secrets, empty for a valid certificate.
+	secretErrors map[model.ConfigKey]string
+	// End added by ingress
 }
 
 // parentInfo holds info about a "parent" - something that can be referenced as a ParentRef in the API.
@@ -1553,6 +1573,11 @@ func convertGateways(r configContext) ([]config.Config, map[parentKey][]*parentI
 			}
 			meta := parentMeta(obj, &l.Name)
 			meta[model.InternalGatewayServiceAnnotation] = strings.Join(gatewayServices, ",")
+			// Added by ingress
+			if selected, ok := obj.Annotations[constants.WasmPluginsAnnotation]; ok {
+				meta[constants.WasmPluginsAnnotation] = selected
+			}
+			// End added by ingress
 			// Each listener generates an Istio Gateway with a single Server. This allows binding to a specific listener.
 			gatewayConfig := config.Config{
 				Meta: config.Meta{
@@ -1965,22 +1990,44 @@ func buildSecretReference(ctx configContext, ref k8s.SecretObjectReference, gw c
 	})
 
 	if ctx.Credentials != nil {
-		if certInfo, err := ctx.Credentials.GetCertInfo(secret.Name, secret.Namespace); err != nil {
+		// Modified by ingress
+		err := validateSecret(ctx.Credentials, secret)
+		ctx.secretErrors[secret] = errorString(err)
+		if err != nil {
 			return "", &ConfigError{
 				Reason:  InvalidTLS,
 				Message: fmt.Sprintf("invalid certificate reference %v, %v", objectReferenceString(ref), err),
 			}
-		} else if _, err = tls.X509KeyPair(certInfo.Cert, certInfo.Key); err != nil {
-			return "", &ConfigError{
-				Reason:  InvalidTLS,
-				Message: fmt.Sprintf("invalid certificate reference %v, the certificate is malformed: %v", objectReferenceString(ref), err),
-			}
 		}
 	}
 
 	return creds.ToKubernetesGatewayResource(secret.Namespace, secret.Name), nil
 }
 
+// Added by ingress
+
+// validateSecret returns why the secret can't be used as the certificate of a gateway, if so. The generated
+// gateways only depend on the secret through this result: its content is served over SDS.
+func validateSecret(secrets credentials.Controller, secret model.ConfigKey) error {
+	certInfo, err := secrets.GetCertInfo(secret.Name, secret.Namespace)
+	if err != nil {
+		return err
+	}
+	if _, err := tls.X509KeyPair(certInfo.Cert, certInfo.Key); err != nil {
+		return fmt.Errorf("the certificate is malformed: %v", err)
+	}
+	return nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// End added by ingress
+
 func objectReferenceString(ref k8s.SecretObjectReference) string {
 	return fmt.Sprintf("%s/%s/%s.%s",
 		ptr.OrEmpty(ref.Group),
@@ -412,6 +412,7 @@ func TestConvertResources(t *testing.T) {
 			output.AllowedReferences = AllowedReferences{} // Not tested here
 			output.ReferencedNamespaceKeys = nil           // Not tested here
 			output.ResourceReferences = nil                // Not tested here
+			output.SecretErrors = nil                      // Tested by TestSecretEvent
 
 			// sort virtual services to make the order deterministic
 			sort.Slice(output.VirtualService, func(i, j int) bool {
@@ -104,6 +104,12 @@ type IstioResources struct {
 	// determine if a resource update could have impacted any Gateways.
 	// key: referenced resources(e.g. secrets), value: gateway-api resources(e.g. gateways)
 	ResourceReferences map[model.ConfigKey][]model.ConfigKey
+
+	// Added by ingress
+	// SecretErrors stores the results of validating the referenced secrets, empty for a valid certificate. A secret
+	// update keeping the same result can't change the gateways, see secretEvent.
+	SecretErrors map[model.ConfigKey]string
+	// End added by ingress
 }
 
 // Reference stores a reference to a namespaced GVK, as used by ReferencePolicy
@@ -28,6 +28,8 @@ import (
 	"istio.io/api/annotation"
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/labels"
@@ -161,13 +163,27 @@ func ConvertIngressVirtualService(ingress knetworking.Ingress, domainSuffix stri
 					httpMatch.Uri = &networking.StringMatch{
 						MatchType: &networking.StringMatch_Prefix{Prefix: httpPath.Path},
 					}
+				// Modified by ingress
+				case knetworking.PathTypeImplementationSpecific:
+					if useRegexPaths(ingress) {
+						httpMatch.Uri = createRegexStringMatch(httpPath.Path)
+					} else {
+						httpMatch.Uri = createFallbackStringMatch(httpPath.Path)
+					}
+				// End modified by ingress
 				default:
 					// Fallback to the legacy string matching
 					// If the httpPath.Path is a wildcard path, Uri will be nil
 					httpMatch.Uri = createFallbackStringMatch(httpPath.Path)
 				}
 			} else {
-				httpMatch.Uri = createFallbackStringMatch(httpPath.Path)
+				// Modified by ingress
+				if useRegexPaths(ingress) {
+					httpMatch.Uri = createRegexStringMatch(httpPath.Path)
+				} else {
+					httpMatch.Uri = createFallbackStringMatch(httpPath.Path)
+				}
+				// End modified by ingress
 			}
 
 			httpRoute := ingressBackendToHTTPRoute(&httpPath.Backend, ingress.Namespace, domainSuffix, services)
@@ -195,10 +211,19 @@ func ConvertIngressVirtualService(ingress knetworking.Ingress, domainSuffix stri
 			Spec: virtualService,
 		}
 
+		// Added by ingress
+		if selected, ok := ingress.Annotations[constants.WasmPluginsAnnotation]; ok {
+			virtualServiceConfig.Annotations[constants.WasmPluginsAnnotation] = selected
+		}
+		// End added by ingress
+
 		old, f := ingressByHost[host]
 		if f {
 			vs := old.Spec.(*networking.VirtualService)
 			vs.Http = append(vs.Http, httpRoutes...)
+			// Added by ingress
+			model.MergeWasmPluginsAnnotation(old.Annotations, ingress.Annotations)
+			// End added by ingress
 		} else {
 			ingressByHost[host] = &virtualServiceConfig
 		}
@@ -206,6 +231,12 @@ func ConvertIngressVirtualService(ingress knetworking.Ingress, domainSuffix stri
 		// sort routes to meet ingress route precedence requirements
 		// see https://kubernetes.io/docs/concepts/services-networking/ingress/#multiple-matches
 		vs := ingressByHost[host].Spec.(*networking.VirtualService)
+		// Added by ingress
+		if alifeatures.IngressRoutePrecedence == RoutePrecedenceNginx {
+			sortNginxRoutes(vs.Http)
+			continue
+		}
+		// End added by ingress
 		sort.SliceStable(vs.Http, func(i, j int) bool {
 			var r1Len, r2Len int
 			var r1Ex, r2Ex bool
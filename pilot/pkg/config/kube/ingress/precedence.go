@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"sort"
+	"strings"
+
+	knetworking "k8s.io/api/networking/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+)
+
+const (
+	// RoutePrecedenceIstio sorts the routes of a host by decreasing length of their path, the exact path first for
+	// the same length.
+	RoutePrecedenceIstio = "istio"
+	// RoutePrecedenceNginx sorts the routes of a host as ingress-nginx matches them: the exact paths first, then the
+	// prefix paths by decreasing length, then the regex paths in the order of the Ingresses and their paths.
+	RoutePrecedenceNginx = "nginx"
+
+	// nginxUseRegexAnnotation makes the ImplementationSpecific paths of an Ingress regular expressions, with the
+	// nginx route precedence.
+	nginxUseRegexAnnotation = "nginx.ingress.kubernetes.io/use-regex"
+)
+
+// useRegexPaths returns true if the ImplementationSpecific paths of the Ingress are regular expressions.
+func useRegexPaths(ingress knetworking.Ingress) bool {
+	return alifeatures.IngressRoutePrecedence == RoutePrecedenceNginx &&
+		strings.EqualFold(ingress.Annotations[nginxUseRegexAnnotation], "true")
+}
+
+// createRegexStringMatch converts an ingress-nginx regex path, matched as a prefix of the path, to a regex match of
+// the whole path.
+func createRegexStringMatch(s string) *networking.StringMatch {
+	return &networking.StringMatch{
+		MatchType: &networking.StringMatch_Regex{Regex: strings.TrimPrefix(s, "^") + ".*"},
+	}
+}
+
+// sortNginxRoutes sorts the routes of a host with the nginx route precedence.
+func sortNginxRoutes(routes []*networking.HTTPRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		r1Rank, r1Len := nginxRouteRank(routes[i])
+		r2Rank, r2Len := nginxRouteRank(routes[j])
+		if r1Rank != r2Rank {
+			return r1Rank < r2Rank
+		}
+		// The regex paths keep their order.
+		return r1Rank != nginxRegexRank && r1Len > r2Len
+	})
+}
+
+const (
+	nginxExactRank = iota
+	nginxPrefixRank
+	nginxRegexRank
+	// nginxDefaultRank is the rank of the routes without path, matching all the requests.
+	nginxDefaultRank
+)
+
+// nginxRouteRank returns the rank of the route in the nginx route precedence, and the length of its path.
+func nginxRouteRank(route *networking.HTTPRoute) (int, int) {
+	if len(route.Match) == 0 {
+		return nginxDefaultRank, 0
+	}
+	uri := route.Match[0].GetUri()
+	switch uri.GetMatchType().(type) {
+	case *networking.StringMatch_Exact:
+		return nginxExactRank, len(uri.GetExact())
+	case *networking.StringMatch_Prefix:
+		return nginxPrefixRank, len(uri.GetPrefix())
+	case *networking.StringMatch_Regex:
+		return nginxRegexRank, len(uri.GetRegex())
+	}
+	return nginxDefaultRank, 0
+}
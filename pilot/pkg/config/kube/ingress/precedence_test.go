@@ -0,0 +1,136 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"testing"
+
+	knetworking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/test"
+)
+
+func TestNginxRoutePrecedence(t *testing.T) {
+	exact := knetworking.PathTypeExact
+	prefix := knetworking.PathTypePrefix
+	implementationSpecific := knetworking.PathTypeImplementationSpecific
+	backend := knetworking.IngressBackend{
+		Service: &knetworking.IngressServiceBackend{
+			Name: "foo",
+			Port: knetworking.ServiceBackendPort{Number: 8000},
+		},
+	}
+	newIngress := func(name string, annotations map[string]string, paths ...knetworking.HTTPIngressPath) knetworking.Ingress {
+		for i := range paths {
+			paths[i].Backend = backend
+		}
+		return knetworking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "mock",
+				Annotations: annotations,
+			},
+			Spec: knetworking.IngressSpec{
+				Rules: []knetworking.IngressRule{
+					{
+						Host: "my.host.com",
+						IngressRuleValue: knetworking.IngressRuleValue{
+							HTTP: &knetworking.HTTPIngressRuleValue{Paths: paths},
+						},
+					},
+				},
+			},
+		}
+	}
+	regexIngress := newIngress("regex", map[string]string{nginxUseRegexAnnotation: "true"},
+		knetworking.HTTPIngressPath{Path: "/api/v[0-9]+", PathType: &implementationSpecific},
+		knetworking.HTTPIngressPath{Path: "^/a.*"},
+	)
+	plainIngress := newIngress("plain", nil,
+		knetworking.HTTPIngressPath{Path: "/api", PathType: &prefix},
+		knetworking.HTTPIngressPath{Path: "/api/v1/users", PathType: &prefix},
+		knetworking.HTTPIngressPath{Path: "/login", PathType: &exact},
+		knetworking.HTTPIngressPath{Path: "/static.*", PathType: &implementationSpecific},
+	)
+
+	uris := func(cfgs map[string]*config.Config) []string {
+		var out []string
+		for _, route := range cfgs["my.host.com"].Spec.(*networking.VirtualService).Http {
+			uri := route.Match[0].GetUri()
+			switch {
+			case uri.GetExact() != "":
+				out = append(out, "exact:"+uri.GetExact())
+			case uri.GetPrefix() != "":
+				out = append(out, "prefix:"+uri.GetPrefix())
+			default:
+				out = append(out, "regex:"+uri.GetRegex())
+			}
+		}
+		return out
+	}
+
+	cases := []struct {
+		name       string
+		precedence string
+		want       []string
+	}{
+		{
+			name:       "istio",
+			precedence: RoutePrecedenceIstio,
+			want: []string{
+				"prefix:/api/v1/users",
+				"exact:/api/v[0-9]+",
+				"prefix:/static",
+				"exact:/login",
+				"prefix:/api",
+				"prefix:^/a",
+			},
+		},
+		{
+			name:       "nginx",
+			precedence: RoutePrecedenceNginx,
+			want: []string{
+				"exact:/login",
+				"prefix:/api/v1/users",
+				"prefix:/static",
+				"prefix:/api",
+				"regex:/api/v[0-9]+.*",
+				"regex:/a.*.*",
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.IngressRoutePrecedence, tt.precedence)
+			cfgs := map[string]*config.Config{}
+			serviceLister := createFakeClient(t)
+			ConvertIngressVirtualService(regexIngress, "mydomain", cfgs, serviceLister)
+			ConvertIngressVirtualService(plainIngress, "mydomain", cfgs, serviceLister)
+			got := uris(cfgs)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got routes %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got routes %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/monitoring"
+)
+
+var log = istiolog.RegisterScope("transform", "config source transformations")
+
+var (
+	sourceTag      = monitoring.CreateLabel("source")
+	typeTag        = monitoring.CreateLabel("type")
+	transformerTag = monitoring.CreateLabel("transformer")
+
+	configsTransformed = monitoring.NewSum(
+		"pilot_config_source_transformed",
+		"Total number of configs of the external config sources transformed, by transformer.",
+	)
+
+	configsDropped = monitoring.NewSum(
+		"pilot_config_source_dropped",
+		"Total number of configs of the external config sources dropped, by transformer.",
+	)
+)
+
+// cachedConfig is the transformation of a version of a config.
+type cachedConfig struct {
+	resourceVersion string
+	cfg             config.Config
+	keep            bool
+}
+
+// Store serves the configs of the store of a config source transformed by a pipeline. The writes are made to the
+// store of the config source as is.
+type Store struct {
+	model.ConfigStoreController
+	source   string
+	pipeline Pipeline
+	remaps   bool
+
+	mu sync.Mutex
+	// cache has the transformations of the configs by their key in the config source.
+	cache map[config.GroupVersionKind]map[types.NamespacedName]cachedConfig
+}
+
+// Wrap returns the store of a config source with its configs transformed by the pipeline, or the store itself if
+// the pipeline does not apply to the config source.
+func Wrap(source string, store model.ConfigStoreController, pipeline Pipeline) model.ConfigStoreController {
+	if !pipeline.AppliesTo(source) {
+		return store
+	}
+	s := &Store{
+		ConfigStoreController: store,
+		source:                source,
+		pipeline:              pipeline,
+		remaps:                pipeline.remapsNamespaces(source),
+		cache:                 map[config.GroupVersionKind]map[types.NamespacedName]cachedConfig{},
+	}
+	for _, schema := range store.Schemas().All() {
+		store.RegisterEventHandler(schema.GroupVersionKind(), func(_, curr config.Config, event model.Event) {
+			if event == model.EventDelete {
+				s.evict(curr)
+			}
+		})
+	}
+	return s
+}
+
+// evict removes the transformation of a config from the cache.
+func (s *Store) evict(cfg config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache[cfg.GroupVersionKind], config.NamespacedName(cfg))
+}
+
+// transform returns the transformation of a config of the config source, and whether it is kept.
+func (s *Store) transform(cfg config.Config) (config.Config, bool) {
+	key := config.NamespacedName(cfg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.cache[cfg.GroupVersionKind][key]; ok && cached.resourceVersion == cfg.ResourceVersion {
+		return cached.cfg, cached.keep
+	}
+	out, transformed, keep := s.pipeline.apply(s.source, cfg)
+	for i, name := range transformed {
+		if !keep && i == len(transformed)-1 {
+			configsDropped.With(sourceTag.Value(s.source), typeTag.Value(cfg.GroupVersionKind.Kind),
+				transformerTag.Value(name)).Increment()
+			continue
+		}
+		configsTransformed.With(sourceTag.Value(s.source), typeTag.Value(cfg.GroupVersionKind.Kind),
+			transformerTag.Value(name)).Increment()
+	}
+	if s.cache[cfg.GroupVersionKind] == nil {
+		s.cache[cfg.GroupVersionKind] = map[types.NamespacedName]cachedConfig{}
+	}
+	s.cache[cfg.GroupVersionKind][key] = cachedConfig{resourceVersion: cfg.ResourceVersion, cfg: out, keep: keep}
+	return out, keep
+}
+
+// apply returns the transformation of a config out of the cache, such as a previous version of a config.
+func (s *Store) apply(cfg config.Config) (config.Config, bool) {
+	out, _, keep := s.pipeline.apply(s.source, cfg)
+	return out, keep
+}
+
+func (s *Store) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	if s.remaps {
+		// The namespace in the config source is not known.
+		for _, c := range s.List(typ, namespace) {
+			if c.Name == name {
+				return &c
+			}
+		}
+		return nil
+	}
+	cfg := s.ConfigStoreController.Get(typ, name, namespace)
+	if cfg == nil {
+		return nil
+	}
+	out, keep := s.transform(*cfg)
+	if !keep {
+		return nil
+	}
+	return &out
+}
+
+func (s *Store) List(typ config.GroupVersionKind, namespace string) []config.Config {
+	sourceNamespace := namespace
+	if s.remaps {
+		sourceNamespace = model.NamespaceAll
+	}
+	configs := s.ConfigStoreController.List(typ, sourceNamespace)
+	out := make([]config.Config, 0, len(configs))
+	for _, c := range configs {
+		t, keep := s.transform(c)
+		if !keep || (namespace != model.NamespaceAll && t.Namespace != namespace) {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (s *Store) RegisterEventHandler(kind config.GroupVersionKind, handler model.EventHandler) {
+	s.ConfigStoreController.RegisterEventHandler(kind, func(old, curr config.Config, event model.Event) {
+		switch event {
+		case model.EventAdd:
+			if c, keep := s.transform(curr); keep {
+				handler(config.Config{}, c, model.EventAdd)
+			}
+		case model.EventDelete:
+			// The deleted config is evicted from the cache.
+			if o, keep := s.apply(curr); keep {
+				handler(o, o, model.EventDelete)
+			}
+		default:
+			// The version of an updated config is not always changed in the event, so it is transformed again.
+			s.evict(curr)
+			o, oldKept := s.apply(old)
+			c, keep := s.transform(curr)
+			switch {
+			case oldKept && keep:
+				handler(o, c, event)
+			case keep:
+				handler(config.Config{}, c, model.EventAdd)
+			case oldKept:
+				handler(o, o, model.EventDelete)
+			}
+		}
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transform implements the transformations of the configs ingested from the external config sources, such
+// as relabeling, namespace remapping and field defaulting, before they reach the push context.
+package transform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// Transformer transforms the configs of some config sources.
+type Transformer interface {
+	// Name identifies the transformer in the metrics.
+	Name() string
+	// AppliesTo returns true if the transformer may transform the configs of a config source.
+	AppliesTo(source string) bool
+	// RemapsNamespaces returns true if the transformer may change the namespaces of the configs.
+	RemapsNamespaces() bool
+	// Transform transforms a config of a config source in place, returning whether it transformed it and whether
+	// the config is kept rather than dropped.
+	Transform(source string, cfg *config.Config) (transformed bool, keep bool, err error)
+}
+
+// Pipeline is a sequence of transformers, each transforming the result of the previous ones.
+type Pipeline []Transformer
+
+// AppliesTo returns true if any transformer of the pipeline may transform the configs of a config source.
+func (p Pipeline) AppliesTo(source string) bool {
+	for _, t := range p {
+		if t.AppliesTo(source) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Pipeline) remapsNamespaces(source string) bool {
+	for _, t := range p {
+		if t.AppliesTo(source) && t.RemapsNamespaces() {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule is a declarative transformer, transforming the configs of the config sources, kinds, namespaces and labels it
+// matches. All the conditions of a rule must match, an empty condition matching all configs.
+type Rule struct {
+	// RuleName identifies the rule in the metrics.
+	RuleName string `json:"name"`
+	// Sources are the addresses of the config sources, or their prefixes such as xds://.
+	Sources []string `json:"sources,omitempty"`
+	// Kinds are the kinds of the configs, such as VirtualService.
+	Kinds []string `json:"kinds,omitempty"`
+	// Namespaces are the namespaces of the configs in their config source.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector are the labels of the configs.
+	Selector map[string]string `json:"selector,omitempty"`
+
+	// Drop drops the configs matched.
+	Drop bool `json:"drop,omitempty"`
+	// Labels are set on the configs, the labels with an empty value being removed.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Namespace is the namespace the configs are moved to.
+	Namespace string `json:"namespace,omitempty"`
+	// Defaults are the JSON fields set on the specs of the configs when unset, objects being merged recursively.
+	Defaults map[string]any `json:"defaults,omitempty"`
+}
+
+var _ Transformer = &Rule{}
+
+// ParseRules parses the JSON array of transformation rules.
+func ParseRules(rules string) (Pipeline, error) {
+	if strings.TrimSpace(rules) == "" {
+		return nil, nil
+	}
+	var parsed []*Rule
+	if err := json.Unmarshal([]byte(rules), &parsed); err != nil {
+		return nil, fmt.Errorf("invalid config transformation rules: %v", err)
+	}
+	out := make(Pipeline, 0, len(parsed))
+	for i, r := range parsed {
+		if r.RuleName == "" {
+			r.RuleName = fmt.Sprintf("rule-%d", i)
+		}
+		if r.Drop && (len(r.Labels) > 0 || r.Namespace != "" || len(r.Defaults) > 0) {
+			return nil, fmt.Errorf("config transformation rule %s drops the configs it transforms", r.RuleName)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (r *Rule) Name() string {
+	return r.RuleName
+}
+
+func (r *Rule) AppliesTo(source string) bool {
+	if len(r.Sources) == 0 {
+		return true
+	}
+	for _, s := range r.Sources {
+		if source == s || strings.HasPrefix(source, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) RemapsNamespaces() bool {
+	return r.Namespace != ""
+}
+
+func (r *Rule) matches(cfg *config.Config) bool {
+	if len(r.Kinds) > 0 && !contains(r.Kinds, cfg.GroupVersionKind.Kind) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, cfg.Namespace) {
+		return false
+	}
+	return labels.Instance(r.Selector).SubsetOf(cfg.Labels)
+}
+
+func (r *Rule) Transform(source string, cfg *config.Config) (bool, bool, error) {
+	if !r.AppliesTo(source) || !r.matches(cfg) {
+		return false, true, nil
+	}
+	if r.Drop {
+		return true, false, nil
+	}
+	if len(r.Labels) > 0 {
+		l := make(map[string]string, len(cfg.Labels)+len(r.Labels))
+		for k, v := range cfg.Labels {
+			l[k] = v
+		}
+		for k, v := range r.Labels {
+			if v == "" {
+				delete(l, k)
+			} else {
+				l[k] = v
+			}
+		}
+		cfg.Labels = l
+	}
+	if r.Namespace != "" {
+		cfg.Namespace = r.Namespace
+	}
+	if len(r.Defaults) > 0 {
+		spec, err := applyDefaults(cfg.Spec, r.Defaults)
+		if err != nil {
+			return false, true, fmt.Errorf("failed to default %s %s/%s: %v",
+				cfg.GroupVersionKind.Kind, cfg.Namespace, cfg.Name, err)
+		}
+		cfg.Spec = spec
+	}
+	return true, true, nil
+}
+
+// applyDefaults returns a copy of a spec with the unset fields of the defaults set.
+func applyDefaults(spec config.Spec, defaults map[string]any) (config.Spec, error) {
+	msg, ok := spec.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("the spec of type %T cannot be defaulted", spec)
+	}
+	current, err := protomarshal.ToJSONMap(msg)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(mergeDefaults(current, defaults))
+	if err != nil {
+		return nil, err
+	}
+	out := msg.ProtoReflect().New().Interface()
+	if err := protomarshal.ApplyJSON(string(b), out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// mergeDefaults sets the unset fields of the defaults in the values, merging the objects recursively.
+func mergeDefaults(values, defaults map[string]any) map[string]any {
+	if values == nil {
+		values = map[string]any{}
+	}
+	for k, d := range defaults {
+		v, ok := values[k]
+		if !ok {
+			values[k] = d
+			continue
+		}
+		vm, vok := v.(map[string]any)
+		dm, dok := d.(map[string]any)
+		if vok && dok {
+			values[k] = mergeDefaults(vm, dm)
+		}
+	}
+	return values
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// apply transforms a copy of a config with the transformers of the pipeline applying to the config source. It returns
+// the names of the transformers which transformed it, and whether the config is kept.
+func (p Pipeline) apply(source string, cfg config.Config) (config.Config, []string, bool) {
+	out := cfg.DeepCopy()
+	var transformed []string
+	for _, t := range p {
+		applied, keep, err := t.Transform(source, &out)
+		if err != nil {
+			log.Warnf("config source %s: %v", source, err)
+			continue
+		}
+		if applied {
+			transformed = append(transformed, t.Name())
+		}
+		if !keep {
+			return out, transformed, false
+		}
+	}
+	return out, transformed, true
+}
@@ -0,0 +1,183 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+const rules = `[
+	{"name": "drop-internal", "kinds": ["VirtualService"], "selector": {"visibility": "internal"}, "drop": true},
+	{"name": "remap", "sources": ["xds://"], "namespaces": ["upstream"], "namespace": "higress-system",
+		"labels": {"source": "mcp", "team": ""}},
+	{"name": "timeout", "kinds": ["VirtualService"], "defaults": {"http": [{"timeout": "5s"}], "exportTo": ["."]}}
+]`
+
+func virtualService(name, namespace string, l map[string]string) config.Config {
+	return config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: name, Namespace: namespace, Labels: l},
+		Spec: &networking.VirtualService{Hosts: []string{name + ".example.com"}},
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	p, err := ParseRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 3 || p[0].Name() != "drop-internal" {
+		t.Fatalf("unexpected pipeline %v", p)
+	}
+	if !p.AppliesTo("fs:///etc/config") || !p.remapsNamespaces("xds://mcp:15010") || p.remapsNamespaces("fs:///etc/config") {
+		t.Fatalf("unexpected sources of the pipeline")
+	}
+	if p, err := ParseRules(""); err != nil || p != nil {
+		t.Fatalf("expected no pipeline, got %v %v", p, err)
+	}
+	if _, err := ParseRules(`[{"drop": true, "namespace": "ns"}]`); err == nil {
+		t.Fatalf("expected an error for a rule dropping the configs it transforms")
+	}
+	if _, err := ParseRules(`{`); err == nil {
+		t.Fatalf("expected an error for invalid JSON")
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	spec := &networking.VirtualService{Hosts: []string{"a"}, ExportTo: []string{"*"}}
+	out, err := applyDefaults(spec, map[string]any{"exportTo": []any{"."}, "gateways": []any{"gw"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := out.(*networking.VirtualService)
+	if vs.ExportTo[0] != "*" || len(vs.Gateways) != 1 || vs.Gateways[0] != "gw" || vs.Hosts[0] != "a" {
+		t.Fatalf("unexpected defaulted spec %v", vs)
+	}
+	if len(spec.Gateways) != 0 {
+		t.Fatalf("expected the spec not to be modified")
+	}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handle(_, curr config.Config, event model.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf("%s %s/%s", event, curr.Namespace, curr.Name))
+}
+
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.events
+	r.events = nil
+	return out
+}
+
+func TestStore(t *testing.T) {
+	pipeline, err := ParseRules(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := memory.NewSyncController(memory.MakeSkipValidation(collections.Pilot))
+	if got := Wrap("k8s://", source, Pipeline{&Rule{Sources: []string{"xds://"}}}); got != source {
+		t.Fatalf("expected the store not to be wrapped for the sources not transformed")
+	}
+	s := Wrap("xds://mcp:15010", source, pipeline)
+	r := &recorder{}
+	s.RegisterEventHandler(gvk.VirtualService, r.handle)
+
+	for _, c := range []config.Config{
+		virtualService("public", "upstream", map[string]string{"team": "a"}),
+		virtualService("internal", "upstream", map[string]string{"visibility": "internal"}),
+		virtualService("other", "other", nil),
+	} {
+		if _, err := source.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := r.take(); fmt.Sprint(got) != "[add higress-system/public add other/other]" {
+		t.Fatalf("unexpected events %v", got)
+	}
+
+	names := func(namespace string) []string {
+		out := []string{}
+		for _, c := range s.List(gvk.VirtualService, namespace) {
+			out = append(out, c.Namespace+"/"+c.Name)
+		}
+		sort.Strings(out)
+		return out
+	}
+	if got := fmt.Sprint(names("")); got != "[higress-system/public other/other]" {
+		t.Fatalf("unexpected configs %v", got)
+	}
+	if got := fmt.Sprint(names("higress-system")); got != "[higress-system/public]" {
+		t.Fatalf("unexpected configs in higress-system %v", got)
+	}
+	if got := fmt.Sprint(names("upstream")); got != "[]" {
+		t.Fatalf("unexpected configs in upstream %v", got)
+	}
+
+	public := s.Get(gvk.VirtualService, "public", "higress-system")
+	if public == nil {
+		t.Fatalf("expected the remapped config")
+	}
+	if public.Labels["source"] != "mcp" || public.Labels["team"] != "" {
+		t.Fatalf("unexpected labels %v", public.Labels)
+	}
+	vs := public.Spec.(*networking.VirtualService)
+	if len(vs.Http) != 1 || vs.Http[0].Timeout.AsDuration().String() != "5s" || vs.ExportTo[0] != "." {
+		t.Fatalf("expected the defaults, got %v", vs)
+	}
+	if s.Get(gvk.VirtualService, "internal", "upstream") != nil || s.Get(gvk.VirtualService, "public", "upstream") != nil {
+		t.Fatalf("expected the dropped and the remapped configs not to be found by their key in the source")
+	}
+	if source.Get(gvk.VirtualService, "public", "upstream").Labels["source"] != "" {
+		t.Fatalf("expected the config of the source not to be modified")
+	}
+
+	// A config becoming dropped is deleted, and a config no longer dropped is added.
+	internal := virtualService("public", "upstream", map[string]string{"visibility": "internal"})
+	internal.ResourceVersion = source.Get(gvk.VirtualService, "public", "upstream").ResourceVersion
+	if _, err := source.Update(internal); err != nil {
+		t.Fatal(err)
+	}
+	exposed := virtualService("internal", "upstream", nil)
+	exposed.ResourceVersion = source.Get(gvk.VirtualService, "internal", "upstream").ResourceVersion
+	if _, err := source.Update(exposed); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Delete(gvk.VirtualService, "other", "other", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.take(); fmt.Sprint(got) != "[delete higress-system/public add higress-system/internal delete other/other]" {
+		t.Fatalf("unexpected events %v", got)
+	}
+	if got := fmt.Sprint(names("")); got != "[higress-system/internal]" {
+		t.Fatalf("unexpected configs %v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SecretAuthorizationRequest is posted by the HTTPSecretAuthorizer to ask whether a proxy identity may receive a
+// secret.
+type SecretAuthorizationRequest struct {
+	ServiceAccount  string `json:"serviceAccount"`
+	Namespace       string `json:"namespace"`
+	SecretName      string `json:"secretName"`
+	SecretNamespace string `json:"secretNamespace"`
+}
+
+// SecretAuthorizationResponse is the decision of the policy service.
+type SecretAuthorizationResponse struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type secretDecision struct {
+	expiration time.Time
+	err        error
+}
+
+// HTTPSecretAuthorizer asks a policy service whether proxy identities may receive secrets, by posting a
+// SecretAuthorizationRequest as JSON to its URL. Decisions are cached, allowed ones for ttl and denied ones for
+// negativeTTL. A service failing or responding other than 200 OK denies access.
+type HTTPSecretAuthorizer struct {
+	url         string
+	client      *http.Client
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu        sync.Mutex
+	decisions map[SecretAuthorizationRequest]secretDecision
+}
+
+var _ SecretAuthorizer = &HTTPSecretAuthorizer{}
+
+func NewHTTPSecretAuthorizer(url string, timeout, ttl, negativeTTL time.Duration) *HTTPSecretAuthorizer {
+	return &HTTPSecretAuthorizer{
+		url:         url,
+		client:      &http.Client{Timeout: timeout},
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		decisions:   make(map[SecretAuthorizationRequest]secretDecision),
+	}
+}
+
+func (a *HTTPSecretAuthorizer) AuthorizeSecret(serviceAccount, namespace, secretName, secretNamespace string) error {
+	req := SecretAuthorizationRequest{
+		ServiceAccount:  serviceAccount,
+		Namespace:       namespace,
+		SecretName:      secretName,
+		SecretNamespace: secretNamespace,
+	}
+	now := time.Now()
+	a.mu.Lock()
+	d, f := a.decisions[req]
+	a.mu.Unlock()
+	if f && now.Before(d.expiration) {
+		return d.err
+	}
+
+	err := a.authorize(req)
+	ttl := a.ttl
+	if err != nil {
+		ttl = a.negativeTTL
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for k, v := range a.decisions {
+		if v.expiration.Before(now) {
+			delete(a.decisions, k)
+		}
+	}
+	if ttl > 0 {
+		a.decisions[req] = secretDecision{expiration: now.Add(ttl), err: err}
+	}
+	return err
+}
+
+func (a *HTTPSecretAuthorizer) authorize(req SecretAuthorizationRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("secret authorizer %s failed: %v", a.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("secret authorizer %s returned %s", a.url, resp.Status)
+	}
+	var decision SecretAuthorizationResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decision); err != nil {
+		return fmt.Errorf("secret authorizer %s returned an invalid response: %v", a.url, err)
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("%s/%s is not authorized to read secret %s/%s: %s",
+			req.ServiceAccount, req.Namespace, req.SecretNamespace, req.SecretName, decision.Reason)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package credentials
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPSecretAuthorizer(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req SecretAuthorizationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.SecretNamespace == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(SecretAuthorizationResponse{
+			Allowed: req.Namespace == "gateway" && req.SecretName == "allowed",
+			Reason:  "denied by policy",
+		})
+	}))
+	defer srv.Close()
+	a := NewHTTPSecretAuthorizer(srv.URL, time.Second, time.Minute, time.Minute)
+
+	cases := []struct {
+		name            string
+		namespace       string
+		secretName      string
+		secretNamespace string
+		allowed         bool
+	}{
+		{"allowed", "gateway", "allowed", "certs", true},
+		{"denied secret", "gateway", "other", "certs", false},
+		{"denied identity", "other", "allowed", "certs", false},
+		{"failing service", "gateway", "allowed", "broken", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				err := a.AuthorizeSecret("sa", tt.namespace, tt.secretName, tt.secretNamespace)
+				if (err == nil) != tt.allowed {
+					t.Fatalf("expected allowed=%v, got error=%v", tt.allowed, err)
+				}
+			}
+		})
+	}
+	// Both allowed and denied decisions are cached.
+	if got := calls.Load(); got != int32(len(cases)) {
+		t.Fatalf("expected %d requests, got %d", len(cases), got)
+	}
+}
@@ -0,0 +1,46 @@
+package cloud
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// accessKeyRefreshMargin is how long before they expire temporary access keys are refreshed.
+const accessKeyRefreshMargin = 5 * time.Minute
+
+// accessKeys are the keys signing the requests to a cloud API.
+type accessKeys struct {
+	ID           string
+	Secret       string
+	SessionToken string
+	// Expiration is zero for long-lived keys.
+	Expiration time.Time
+}
+
+// accessKeyProvider returns the access keys of pilot: the long-lived keys it is configured with, or the temporary
+// keys of its role, assumed with the identity token of its service account.
+type accessKeyProvider struct {
+	static *accessKeys
+	assume func(ctx context.Context) (accessKeys, error)
+
+	mu   sync.Mutex
+	keys accessKeys
+}
+
+func (p *accessKeyProvider) get(ctx context.Context) (accessKeys, error) {
+	if p.static != nil {
+		return *p.static, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys.ID != "" && time.Until(p.keys.Expiration) > accessKeyRefreshMargin {
+		return p.keys, nil
+	}
+	keys, err := p.assume(ctx)
+	if err != nil {
+		return accessKeys{}, err
+	}
+	p.keys = keys
+	return keys, nil
+}
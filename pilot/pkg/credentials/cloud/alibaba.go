@@ -0,0 +1,223 @@
+package cloud
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AlibabaKMS reads the secrets of the secrets manager of Alibaba Cloud KMS by name, from the endpoint of the region
+// of pilot.
+// Requests are signed with the keys of ALIBABA_CLOUD_ACCESS_KEY_ID and ALIBABA_CLOUD_ACCESS_KEY_SECRET, or with the
+// keys of the role of ALIBABA_CLOUD_ROLE_ARN, assumed with the token of ALIBABA_CLOUD_OIDC_TOKEN_FILE for the
+// provider of ALIBABA_CLOUD_OIDC_PROVIDER_ARN as set up by RAM roles for service accounts.
+type AlibabaKMS struct {
+	region     string
+	keys       *accessKeyProvider
+	httpClient *http.Client
+	// endpoint returns the endpoint of a region.
+	endpoint func(region string) string
+}
+
+var _ Provider = &AlibabaKMS{}
+
+func NewAlibabaKMS(region string) (*AlibabaKMS, error) {
+	httpClient := &http.Client{Timeout: requestTimeout}
+	keys, err := alibabaAccessKeys(httpClient, "https://sts."+region+".aliyuncs.com")
+	if err != nil {
+		return nil, err
+	}
+	return &AlibabaKMS{
+		region:     region,
+		keys:       keys,
+		httpClient: httpClient,
+		endpoint: func(region string) string {
+			return "https://kms." + region + ".aliyuncs.com"
+		},
+	}, nil
+}
+
+// alibabaAccessKeys returns the access keys set in the environment.
+func alibabaAccessKeys(httpClient *http.Client, stsEndpoint string) (*accessKeyProvider, error) {
+	id, secret := os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_ID"), os.Getenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET")
+	if id != "" && secret != "" {
+		return &accessKeyProvider{static: &accessKeys{ID: id, Secret: secret, SessionToken: os.Getenv("ALIBABA_CLOUD_SECURITY_TOKEN")}}, nil
+	}
+	roleARN, providerARN, tokenFile := os.Getenv("ALIBABA_CLOUD_ROLE_ARN"), os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN"),
+		os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE")
+	if roleARN == "" || providerARN == "" || tokenFile == "" {
+		return nil, errors.New("no Alibaba Cloud credentials: set ALIBABA_CLOUD_ACCESS_KEY_ID and ALIBABA_CLOUD_ACCESS_KEY_SECRET, " +
+			"or ALIBABA_CLOUD_ROLE_ARN, ALIBABA_CLOUD_OIDC_PROVIDER_ARN and ALIBABA_CLOUD_OIDC_TOKEN_FILE")
+	}
+	sessionName := os.Getenv("ALIBABA_CLOUD_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "higress-pilot"
+	}
+	return &accessKeyProvider{assume: func(ctx context.Context) (accessKeys, error) {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return accessKeys{}, fmt.Errorf("failed to read OIDC token: %v", err)
+		}
+		return assumeAlibabaRole(ctx, httpClient, stsEndpoint, roleARN, providerARN, sessionName, strings.TrimSpace(string(token)))
+	}}, nil
+}
+
+// alibabaError is the body of the errors of the Alibaba Cloud APIs.
+type alibabaError struct {
+	Code    string
+	Message string
+}
+
+// assumeAlibabaRole returns the temporary keys of the role, assumed with the OIDC token.
+func assumeAlibabaRole(ctx context.Context, httpClient *http.Client, stsEndpoint, roleARN, providerARN, sessionName,
+	token string,
+) (accessKeys, error) {
+	form := url.Values{
+		"Action":          {"AssumeRoleWithOIDC"},
+		"Version":         {"2015-04-01"},
+		"Format":          {"JSON"},
+		"Timestamp":       {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+		"RoleArn":         {roleARN},
+		"OIDCProviderArn": {providerARN},
+		"OIDCToken":       {token},
+		"RoleSessionName": {sessionName},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stsEndpoint+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return accessKeys{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	b, status, err := do(httpClient, req)
+	if err != nil {
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+	}
+	if status != http.StatusOK {
+		var e alibabaError
+		_ = json.Unmarshal(b, &e)
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %d %s %s", roleARN, status, e.Code, e.Message)
+	}
+	var out struct {
+		Credentials struct {
+			AccessKeyID     string `json:"AccessKeyId"`
+			AccessKeySecret string
+			SecurityToken   string
+			Expiration      time.Time
+		}
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+	}
+	c := out.Credentials
+	return accessKeys{ID: c.AccessKeyID, Secret: c.AccessKeySecret, SessionToken: c.SecurityToken, Expiration: c.Expiration}, nil
+}
+
+// Region returns the region of pilot, as secrets are named without their region.
+func (p *AlibabaKMS) Region(_ string) (string, error) {
+	return p.region, nil
+}
+
+func (p *AlibabaKMS) NewClient(region string) (Client, error) {
+	return &alibabaClient{provider: p, endpoint: p.endpoint(region)}, nil
+}
+
+type alibabaClient struct {
+	provider *AlibabaKMS
+	endpoint string
+}
+
+func (c *alibabaClient) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	keys, err := c.provider.keys.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"Action":           {"GetSecretValue"},
+		"Version":          {"2016-01-20"},
+		"Format":           {"JSON"},
+		"SecretName":       {name},
+		"AccessKeyId":      {keys.ID},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureVersion": {"1.0"},
+		"SignatureNonce":   {hex.EncodeToString(nonce)},
+		"Timestamp":        {time.Now().UTC().Format("2006-01-02T15:04:05Z")},
+	}
+	if keys.SessionToken != "" {
+		form.Set("SecurityToken", keys.SessionToken)
+	}
+	form.Set("Signature", signAlibabaRequest(http.MethodPost, form, keys.Secret))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	b, status, err := do(c.provider.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		var e alibabaError
+		_ = json.Unmarshal(b, &e)
+		if e.Code == "Forbidden.ResourceNotFound" {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, e.Message)
+		}
+		return nil, fmt.Errorf("%d %s %s", status, e.Code, e.Message)
+	}
+	var out struct {
+		SecretData     string
+		SecretDataType string
+		VersionID      string `json:"VersionId"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	value := []byte(out.SecretData)
+	if out.SecretDataType == "binary" {
+		if value, err = base64.StdEncoding.DecodeString(out.SecretData); err != nil {
+			return nil, fmt.Errorf("invalid binary secret: %v", err)
+		}
+	}
+	return &Secret{Value: value, Version: out.VersionID}, nil
+}
+
+// signAlibabaRequest returns the signature of the parameters of a request to an RPC API of Alibaba Cloud, using
+// HMAC-SHA1.
+func signAlibabaRequest(method string, params url.Values, secret string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, alibabaEscape(k)+"="+alibabaEscape(params.Get(k)))
+	}
+	stringToSign := method + "&" + alibabaEscape("/") + "&" + alibabaEscape(strings.Join(pairs, "&"))
+	h := hmac.New(sha1.New, []byte(secret+"&"))
+	h.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// alibabaEscape percent-encodes the string as the signatures of Alibaba Cloud expect: as RFC 3986, so spaces are %20,
+// asterisks %2A and tildes are left unencoded.
+func alibabaEscape(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
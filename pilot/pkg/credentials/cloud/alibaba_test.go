@@ -0,0 +1,94 @@
+package cloud
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignAlibabaRequest(t *testing.T) {
+	// The example of the signature documentation of the RPC APIs.
+	params := url.Values{
+		"AccessKeyId":      {"testid"},
+		"Action":           {"DescribeRegions"},
+		"Format":           {"XML"},
+		"SignatureMethod":  {"HMAC-SHA1"},
+		"SignatureNonce":   {"3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"},
+		"SignatureVersion": {"1.0"},
+		"Timestamp":        {"2016-02-23T12:46:24Z"},
+		"Version":          {"2014-05-26"},
+	}
+	if got := signAlibabaRequest(http.MethodGet, params, "testsecret"); got != "OLeaidS1JvxuMvnyHOwuJ+uX5qY=" {
+		t.Fatalf("unexpected signature %q", got)
+	}
+}
+
+func TestAlibabaKMS(t *testing.T) {
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("Action") != "AssumeRoleWithOIDC" || r.FormValue("OIDCToken") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `{"Credentials":{"AccessKeyId":"STS.example","AccessKeySecret":"secret","SecurityToken":"session","Expiration":%q}}`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer sts.Close()
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		params := r.PostForm
+		signature := params.Get("Signature")
+		params.Del("Signature")
+		if params.Get("AccessKeyId") != "STS.example" || params.Get("SecurityToken") != "session" ||
+			signature != signAlibabaRequest(http.MethodPost, params, "secret") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch params.Get("SecretName") {
+		case "gateway-cert":
+			fmt.Fprint(w, `{"SecretData":"{\"tls.crt\":\"cert\"}","SecretDataType":"text","VersionId":"v1"}`)
+		case "binary-cert":
+			fmt.Fprintf(w, `{"SecretData":%q,"SecretDataType":"binary","VersionId":"v2"}`,
+				base64.StdEncoding.EncodeToString([]byte(`{"tls.crt":"cert"}`)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"Code":"Forbidden.ResourceNotFound","Message":"The resource not exists."}`)
+		}
+	}))
+	defer kms.Close()
+
+	p := &AlibabaKMS{
+		region: "cn-hangzhou",
+		keys: &accessKeyProvider{assume: func(ctx context.Context) (accessKeys, error) {
+			return assumeAlibabaRole(ctx, sts.Client(), sts.URL, "acs:ram::123:role/gateway", "acs:ram::123:oidc-provider/ack", "test", "token")
+		}},
+		httpClient: kms.Client(),
+		endpoint: func(region string) string {
+			return kms.URL
+		},
+	}
+	client, err := p.NewClient("cn-hangzhou")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, version := range map[string]string{"gateway-cert": "v1", "binary-cert": "v2"} {
+		s, err := client.GetSecret(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(s.Value) != `{"tls.crt":"cert"}` || s.Version != version {
+			t.Fatalf("unexpected secret %+v", s)
+		}
+	}
+	if _, err := client.GetSecret(context.Background(), "missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected secret not found, got %v", err)
+	}
+}
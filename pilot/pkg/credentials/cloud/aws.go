@@ -0,0 +1,249 @@
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSSecretsManager reads the secrets of AWS Secrets Manager by ARN, arn:aws:secretsmanager:region:account:secret:name,
+// from the endpoint of the region of the secret.
+// Requests are signed with the keys of AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or with the keys of the role
+// of AWS_ROLE_ARN, assumed with the token of AWS_WEB_IDENTITY_TOKEN_FILE as set up by IAM roles for service
+// accounts.
+type AWSSecretsManager struct {
+	keys       *accessKeyProvider
+	httpClient *http.Client
+	// endpoint returns the endpoint of a region.
+	endpoint func(region string) string
+}
+
+var _ Provider = &AWSSecretsManager{}
+
+func NewAWSSecretsManager() (*AWSSecretsManager, error) {
+	httpClient := &http.Client{Timeout: requestTimeout}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	keys, err := awsAccessKeys(httpClient, awsEndpoint("sts", region))
+	if err != nil {
+		return nil, err
+	}
+	return &AWSSecretsManager{
+		keys:       keys,
+		httpClient: httpClient,
+		endpoint: func(region string) string {
+			return awsEndpoint("secretsmanager", region)
+		},
+	}, nil
+}
+
+// awsEndpoint returns the endpoint of the service in the region, or the global one if there is no region.
+func awsEndpoint(service, region string) string {
+	if region == "" {
+		return "https://" + service + ".amazonaws.com"
+	}
+	domain := "amazonaws.com"
+	if strings.HasPrefix(region, "cn-") {
+		domain += ".cn"
+	}
+	return fmt.Sprintf("https://%s.%s.%s", service, region, domain)
+}
+
+// awsAccessKeys returns the access keys set in the environment.
+func awsAccessKeys(httpClient *http.Client, stsEndpoint string) (*accessKeyProvider, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &accessKeyProvider{static: &accessKeys{ID: id, Secret: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}}, nil
+	}
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN == "" || tokenFile == "" {
+		return nil, errors.New("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, " +
+			"or AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE")
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "higress-pilot"
+	}
+	return &accessKeyProvider{assume: func(ctx context.Context) (accessKeys, error) {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return accessKeys{}, fmt.Errorf("failed to read web identity token: %v", err)
+		}
+		return assumeAWSRole(ctx, httpClient, stsEndpoint, roleARN, sessionName, strings.TrimSpace(string(token)))
+	}}, nil
+}
+
+// assumeAWSRole returns the temporary keys of the role, assumed with the web identity token.
+func assumeAWSRole(ctx context.Context, httpClient *http.Client, stsEndpoint, roleARN, sessionName, token string) (accessKeys, error) {
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, stsEndpoint+"/?"+query.Encode(), nil)
+	if err != nil {
+		return accessKeys{}, err
+	}
+	b, status, err := do(httpClient, req)
+	if err != nil {
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+	}
+	if status != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		_ = xml.Unmarshal(b, &e)
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %d %s %s", roleARN, status, e.Code, e.Message)
+	}
+	var out struct {
+		AccessKeyID     string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>AccessKeyId"`
+		SecretAccessKey string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>SecretAccessKey"`
+		SessionToken    string    `xml:"AssumeRoleWithWebIdentityResult>Credentials>SessionToken"`
+		Expiration      time.Time `xml:"AssumeRoleWithWebIdentityResult>Credentials>Expiration"`
+	}
+	if err := xml.Unmarshal(b, &out); err != nil {
+		return accessKeys{}, fmt.Errorf("failed to assume role %s: %v", roleARN, err)
+	}
+	return accessKeys{ID: out.AccessKeyID, Secret: out.SecretAccessKey, SessionToken: out.SessionToken, Expiration: out.Expiration}, nil
+}
+
+// Region returns the region of the ARN of the secret.
+func (p *AWSSecretsManager) Region(name string) (string, error) {
+	parts := strings.SplitN(name, ":", 7)
+	if len(parts) != 7 || parts[0] != "arn" || parts[2] != "secretsmanager" || parts[3] == "" || parts[5] != "secret" {
+		return "", fmt.Errorf("invalid secret %q, expected an ARN arn:aws:secretsmanager:region:account:secret:name", name)
+	}
+	return parts[3], nil
+}
+
+func (p *AWSSecretsManager) NewClient(region string) (Client, error) {
+	return &awsClient{provider: p, region: region, endpoint: p.endpoint(region)}, nil
+}
+
+type awsClient struct {
+	provider *AWSSecretsManager
+	region   string
+	endpoint string
+}
+
+func (c *awsClient) GetSecret(ctx context.Context, name string) (*Secret, error) {
+	keys, err := c.provider.keys.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, keys, c.region, "secretsmanager", time.Now())
+	b, status, err := do(c.provider.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(b, &e)
+		if strings.HasSuffix(e.Type, "ResourceNotFoundException") {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, e.Message)
+		}
+		return nil, fmt.Errorf("%d %s %s", status, e.Type, e.Message)
+	}
+	var out struct {
+		SecretString string
+		SecretBinary []byte
+		VersionID    string `json:"VersionId"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	value := []byte(out.SecretString)
+	if out.SecretString == "" {
+		value = out.SecretBinary
+	}
+	return &Secret{Value: value, Version: out.VersionID}, nil
+}
+
+// signAWSRequest signs the request with the access keys, using AWS Signature Version 4. All the headers of the
+// request are signed.
+func signAWSRequest(req *http.Request, body []byte, keys accessKeys, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if keys.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", keys.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+keys.Secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		keys.ID, scope, signedHeaders, signature))
+}
+
+func hexSHA256(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,92 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// The get-vanilla case of the AWS Signature Version 4 test suite.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	signAWSRequest(req, nil, accessKeys{ID: "AKIDEXAMPLE", Secret: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		"us-east-1", "service", now)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestAWSSecretsManager(t *testing.T) {
+	const arn = "arn:aws:secretsmanager:us-west-2:123456789012:secret:gateway/cert-AbCdEf"
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("Action") != "AssumeRoleWithWebIdentity" || r.URL.Query().Get("WebIdentityToken") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIAEXAMPLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session</SessionToken>
+<Expiration>%s</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`,
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	defer sts.Close()
+	sm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential=ASIAEXAMPLE/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/us-west-2/secretsmanager/aws4_request") ||
+			r.Header.Get("X-Amz-Security-Token") != "session" || r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var in struct{ SecretId string }
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.SecretId != arn {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"ResourceNotFoundException","message":"Secrets Manager can't find the specified secret."}`)
+			return
+		}
+		fmt.Fprint(w, `{"SecretString":"{\"tls.crt\":\"cert\"}","VersionId":"v1"}`)
+	}))
+	defer sm.Close()
+
+	p := &AWSSecretsManager{
+		keys: &accessKeyProvider{assume: func(ctx context.Context) (accessKeys, error) {
+			return assumeAWSRole(ctx, sts.Client(), sts.URL, "arn:aws:iam::123456789012:role/gateway", "test", "token")
+		}},
+		httpClient: sm.Client(),
+		endpoint: func(region string) string {
+			return sm.URL
+		},
+	}
+	region, err := p.Region(arn)
+	if err != nil || region != "us-west-2" {
+		t.Fatalf("unexpected region %q: %v", region, err)
+	}
+	if _, err := p.Region("gateway/cert"); err == nil {
+		t.Fatalf("expected an error for a name other than an ARN")
+	}
+	client, err := p.NewClient(region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := client.GetSecret(context.Background(), arn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Value) != `{"tls.crt":"cert"}` || s.Version != "v1" {
+		t.Fatalf("unexpected secret %+v", s)
+	}
+	if _, err := client.GetSecret(context.Background(), arn+"-missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("expected secret not found, got %v", err)
+	}
+}
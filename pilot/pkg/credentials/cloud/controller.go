@@ -0,0 +1,262 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/credentials/kube"
+	securitymodel "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pkg/log"
+)
+
+// ErrSecretNotFound is returned by the clients of the secret managers for the secrets that do not exist.
+var ErrSecretNotFound = errors.New("secret not found")
+
+const (
+	// requestTimeout bounds the requests to the secret managers.
+	requestTimeout = 10 * time.Second
+	// maxResponseSize bounds the responses of the secret managers.
+	maxResponseSize = 1 << 20
+)
+
+// Secret is a version of a secret of a secret manager.
+type Secret struct {
+	// Value is a JSON object of the keys of a Kubernetes secret, such as tls.crt and tls.key, to their values.
+	Value []byte
+	// Version changes when the secret is rotated.
+	Version string
+}
+
+// Client reads the secrets of a secret manager in a region.
+type Client interface {
+	GetSecret(ctx context.Context, name string) (*Secret, error)
+}
+
+// Provider is a secret manager, with a client per region.
+type Provider interface {
+	// Region returns the region of the secret.
+	Region(name string) (string, error)
+	// NewClient returns a client of the region.
+	NewClient(region string) (Client, error)
+}
+
+type cachedSecret struct {
+	secret  *v1.Secret
+	version string
+}
+
+// Controller serves the credentials of a secret manager, such as aws-sm://arn ones. The value of a secret is a JSON
+// object of the keys of a Kubernetes secret: tls.crt, tls.key, ca.crt and ca.crl, or cert, key, cacert and crl.
+// Secrets are cached once read, and polled for rotations until they are deleted.
+// Secrets have no owner, so all the proxies are authorized to read them, and SDS only serves them to the gateways
+// of the namespace of the proxy referencing them.
+type Controller struct {
+	secretType string
+	provider   Provider
+	refresh    time.Duration
+
+	mu       sync.Mutex
+	clients  map[string]Client
+	secrets  map[string]cachedSecret
+	handlers []func(name, namespace string)
+	fetches  singleflight.Group
+}
+
+var _ credentials.Controller = &Controller{}
+
+// NewController returns a controller of the credentials of the type, read from the provider and polled every
+// refresh interval.
+func NewController(secretType string, provider Provider, refresh time.Duration) *Controller {
+	return &Controller{
+		secretType: secretType,
+		provider:   provider,
+		refresh:    refresh,
+		clients:    map[string]Client{},
+		secrets:    map[string]cachedSecret{},
+	}
+}
+
+// client returns the client of the region of the secret, shared by the secrets of the region.
+func (c *Controller) client(name string) (Client, error) {
+	region, err := c.provider.Region(name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if client, f := c.clients[region]; f {
+		return client, nil
+	}
+	client, err := c.provider.NewClient(region)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[region] = client
+	return client, nil
+}
+
+// fetch reads the secret from the secret manager.
+func (c *Controller) fetch(name string) (cachedSecret, error) {
+	client, err := c.client(name)
+	if err != nil {
+		return cachedSecret{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	s, err := client.GetSecret(ctx, name)
+	if err != nil {
+		return cachedSecret{}, fmt.Errorf("failed to read secret %s://%s: %w", c.secretType, name, err)
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal(s.Value, &values); err != nil {
+		return cachedSecret{}, fmt.Errorf("secret %s://%s is not a JSON object of the keys of a kubernetes secret: %v",
+			c.secretType, name, err)
+	}
+	data := make(map[string][]byte, len(values))
+	for k, v := range values {
+		data[k] = []byte(v)
+	}
+	return cachedSecret{
+		secret:  &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}, Data: data},
+		version: s.Version,
+	}, nil
+}
+
+// get returns the secret, from the cache if it was read before.
+func (c *Controller) get(name string) (*v1.Secret, error) {
+	c.mu.Lock()
+	cached, f := c.secrets[name]
+	c.mu.Unlock()
+	if f {
+		return cached.secret, nil
+	}
+	res, err, _ := c.fetches.Do(name, func() (any, error) {
+		cached, err := c.fetch(name)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.secrets[name] = cached
+		return cached.secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*v1.Secret), nil
+}
+
+func (c *Controller) GetCertInfo(name, _ string) (certInfo *credentials.CertInfo, err error) {
+	scrt, err := c.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return kube.ExtractCertInfo(scrt)
+}
+
+func (c *Controller) GetCaCert(name, _ string) (certInfo *credentials.CertInfo, err error) {
+	scrt, err := c.get(name)
+	if err != nil {
+		// Could not fetch cert, look for secret without -cacert suffix
+		if scrt, err = c.get(strings.TrimSuffix(name, securitymodel.SdsCaSuffix)); err != nil {
+			return nil, err
+		}
+	}
+	return kube.ExtractRoot(scrt)
+}
+
+func (c *Controller) GetDockerCredential(name, _ string) ([]byte, error) {
+	return nil, fmt.Errorf("docker credential %v is not supported from %s", name, c.secretType)
+}
+
+func (c *Controller) Authorize(_, _ string) error {
+	return nil
+}
+
+// AddEventHandler adds a handler called with the name of a secret, and no namespace, when it is rotated or deleted.
+func (c *Controller) AddEventHandler(h func(name, namespace string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+func (c *Controller) notify(name string) {
+	c.mu.Lock()
+	handlers := c.handlers
+	c.mu.Unlock()
+	log.Debugf("credential %s://%s changed", c.secretType, name)
+	for _, h := range handlers {
+		h(name, "")
+	}
+}
+
+// Run polls the cached secrets every refresh interval until stopped.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	ticker := time.NewTicker(c.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			c.poll()
+		}
+	}
+}
+
+// poll reads the cached secrets again, notifying the handlers of the rotated and deleted ones. Secrets failing to
+// be read are served from the cache until the next poll.
+func (c *Controller) poll() {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.secrets))
+	for name := range c.secrets {
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	for _, name := range names {
+		cached, err := c.fetch(name)
+		switch {
+		case errors.Is(err, ErrSecretNotFound):
+			c.mu.Lock()
+			delete(c.secrets, name)
+			c.mu.Unlock()
+			c.notify(name)
+		case err != nil:
+			log.Warnf("failed to refresh credential %s://%s: %v", c.secretType, name, err)
+		default:
+			c.mu.Lock()
+			previous := c.secrets[name]
+			c.secrets[name] = cached
+			c.mu.Unlock()
+			if previous.version != cached.version {
+				c.notify(name)
+			}
+		}
+	}
+}
+
+// do sends the request, returning the body and status of the response.
+func do(httpClient *http.Client, req *http.Request) ([]byte, int, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, resp.StatusCode, nil
+}
@@ -0,0 +1,110 @@
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"istio.io/istio/pkg/testcerts"
+)
+
+type fakeProvider struct {
+	mu      sync.Mutex
+	secrets map[string]*Secret
+	regions []string
+	reads   int
+}
+
+func (p *fakeProvider) Region(name string) (string, error) {
+	return "region-" + name[:1], nil
+}
+
+func (p *fakeProvider) NewClient(region string) (Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.regions = append(p.regions, region)
+	return p, nil
+}
+
+func (p *fakeProvider) GetSecret(_ context.Context, name string) (*Secret, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reads++
+	s, f := p.secrets[name]
+	if !f {
+		return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+	}
+	return s, nil
+}
+
+func (p *fakeProvider) set(name, version string, values map[string][]byte) {
+	data := map[string]string{}
+	for k, v := range values {
+		data[k] = string(v)
+	}
+	b, _ := json.Marshal(data)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secrets[name] = &Secret{Value: b, Version: version}
+}
+
+func TestController(t *testing.T) {
+	p := &fakeProvider{secrets: map[string]*Secret{}}
+	p.set("a-cert", "v1", map[string][]byte{"tls.crt": testcerts.ServerCert, "tls.key": testcerts.ServerKey, "ca.crt": testcerts.CACert})
+	p.set("b-cert", "v1", map[string][]byte{"cert": testcerts.ServerCert, "key": testcerts.ServerKey})
+	p.secrets["invalid"] = &Secret{Value: []byte("not json")}
+	c := NewController("fake", p, 0)
+	var events []string
+	c.AddEventHandler(func(name, namespace string) {
+		events = append(events, name)
+	})
+
+	for _, name := range []string{"a-cert", "b-cert", "a-cert"} {
+		certInfo, err := c.GetCertInfo(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(certInfo.Cert) != string(testcerts.ServerCert) || string(certInfo.Key) != string(testcerts.ServerKey) {
+			t.Fatalf("unexpected certificate %v", certInfo)
+		}
+	}
+	caInfo, err := c.GetCaCert("a-cert-cacert", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caInfo.Cert) != string(testcerts.CACert) {
+		t.Fatalf("unexpected CA certificate %v", caInfo)
+	}
+	for _, name := range []string{"missing", "invalid"} {
+		if _, err := c.GetCertInfo(name, ""); err == nil {
+			t.Fatalf("expected an error reading %q", name)
+		}
+	}
+	// Secrets are read once, with a client per region.
+	if p.reads != 5 {
+		t.Fatalf("expected 5 reads, got %d", p.reads)
+	}
+	if len(p.regions) != 4 {
+		t.Fatalf("expected a client per region, got %v", p.regions)
+	}
+
+	// Rotated and deleted secrets are notified.
+	p.set("a-cert", "v2", map[string][]byte{"tls.crt": testcerts.RotatedCert, "tls.key": testcerts.RotatedKey})
+	delete(p.secrets, "b-cert")
+	c.poll()
+	if len(events) != 2 {
+		t.Fatalf("expected events for the rotated and deleted secrets, got %v", events)
+	}
+	certInfo, err := c.GetCertInfo("a-cert", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(certInfo.Cert) != string(testcerts.RotatedCert) {
+		t.Fatalf("expected the rotated certificate, got %v", certInfo)
+	}
+	if _, err := c.GetCertInfo("b-cert", ""); err == nil {
+		t.Fatalf("expected an error reading the deleted secret")
+	}
+}
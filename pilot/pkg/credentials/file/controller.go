@@ -0,0 +1,213 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/credentials/kube"
+	securitymodel "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/log"
+)
+
+// Controller serves the file://name credentials from the directory name under a root directory, such as the one
+// where a secret manager CSI driver mounts them. The files of the directory are read as the keys of a Kubernetes
+// secret: tls.crt, tls.key, ca.crt and ca.crl, or cert, key, cacert and crl. Hidden files, such as the ..data
+// directories of the atomic writers of the CSI drivers, are ignored.
+// Files have no owner, so all the proxies are authorized to read them, and SDS only serves them to the gateways
+// of the namespace of the proxy referencing them.
+type Controller struct {
+	root string
+
+	mu       sync.RWMutex
+	handlers []func(name, namespace string)
+}
+
+var _ credentials.Controller = &Controller{}
+
+func NewController(root string) *Controller {
+	return &Controller{root: root}
+}
+
+// read reads the files of the credential as the data of a secret.
+func (c *Controller) read(name string) (*v1.Secret, error) {
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("invalid credential %q, expected a path relative to %s", name, c.root)
+	}
+	dir := filepath.Join(c.root, name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("credential %v not found: %v", name, err)
+	}
+	data := map[string][]byte{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		// Follow the symbolic links of the atomic writers.
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credential %v: %v", name, err)
+		}
+		data[e.Name()] = b
+	}
+	return &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name}, Data: data}, nil
+}
+
+func (c *Controller) GetCertInfo(name, _ string) (certInfo *credentials.CertInfo, err error) {
+	scrt, err := c.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return kube.ExtractCertInfo(scrt)
+}
+
+func (c *Controller) GetCaCert(name, _ string) (certInfo *credentials.CertInfo, err error) {
+	scrt, err := c.read(name)
+	if err != nil {
+		// Could not fetch cert, look for credential without -cacert suffix
+		if scrt, err = c.read(strings.TrimSuffix(name, securitymodel.SdsCaSuffix)); err != nil {
+			return nil, err
+		}
+	}
+	return kube.ExtractRoot(scrt)
+}
+
+func (c *Controller) GetDockerCredential(name, _ string) ([]byte, error) {
+	return nil, fmt.Errorf("docker credential %v is not supported from files", name)
+}
+
+func (c *Controller) Authorize(_, _ string) error {
+	return nil
+}
+
+// AddEventHandler adds a handler called with the name of a credential, and no namespace, when its files change.
+func (c *Controller) AddEventHandler(h func(name, namespace string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+func (c *Controller) notify(name string) {
+	c.mu.RLock()
+	handlers := c.handlers
+	c.mu.RUnlock()
+	log.Debugf("credential file://%s changed", name)
+	for _, h := range handlers {
+		h(name, "")
+	}
+}
+
+// Run watches the root directory and its subdirectories until stopped, notifying the handlers of the changes of
+// the credentials.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := c.watch(watcher, c.root); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			c.handle(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("error watching credentials in %s: %v", c.root, err)
+		}
+	}
+}
+
+// watch adds the directory and its subdirectories, other than hidden ones, to the watcher.
+func (c *Controller) watch(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+func (c *Controller) handle(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	credentialDir := filepath.Dir(event.Name)
+	if event.Has(fsnotify.Create) && !strings.HasPrefix(filepath.Base(event.Name), ".") {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if err := c.watch(watcher, event.Name); err != nil {
+				log.Warnf("failed to watch credentials in %s: %v", event.Name, err)
+			}
+			credentialDir = event.Name
+		}
+	}
+	name, err := filepath.Rel(c.root, credentialDir)
+	if err != nil || name == "." {
+		return
+	}
+	c.notify(filepath.ToSlash(name))
+}
+
+// kubernetesUnavailable is the credentials controller of the clusters of a control plane without Kubernetes: it has
+// no secret, and authorizes no proxy to read them.
+type kubernetesUnavailable struct{}
+
+var _ credentials.Controller = kubernetesUnavailable{}
+
+var errKubernetesUnavailable = errors.New("kubernetes credentials are not available")
+
+func (kubernetesUnavailable) GetCertInfo(_, _ string) (*credentials.CertInfo, error) {
+	return nil, errKubernetesUnavailable
+}
+
+func (kubernetesUnavailable) GetCaCert(_, _ string) (*credentials.CertInfo, error) {
+	return nil, errKubernetesUnavailable
+}
+
+func (kubernetesUnavailable) GetDockerCredential(_, _ string) ([]byte, error) {
+	return nil, errKubernetesUnavailable
+}
+
+func (kubernetesUnavailable) Authorize(_, _ string) error {
+	return errKubernetesUnavailable
+}
+
+func (kubernetesUnavailable) AddEventHandler(func(name, namespace string)) {}
+
+// KubernetesUnavailable is the MulticlusterController of a control plane without Kubernetes, serving only the
+// credentials stored outside of Kubernetes, such as files.
+type KubernetesUnavailable struct{}
+
+var _ credentials.MulticlusterController = KubernetesUnavailable{}
+
+func (KubernetesUnavailable) ForCluster(_ cluster.ID) (credentials.Controller, error) {
+	return kubernetesUnavailable{}, nil
+}
+
+func (KubernetesUnavailable) AddSecretHandler(func(name, namespace string)) {}
@@ -0,0 +1,99 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/testcerts"
+)
+
+func writeCredential(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	root := t.TempDir()
+	writeCredential(t, filepath.Join(root, "gateway", "example"), map[string][]byte{
+		"tls.crt": testcerts.ServerCert,
+		"tls.key": testcerts.ServerKey,
+		"ca.crt":  testcerts.CACert,
+	})
+	c := NewController(root)
+
+	certInfo, err := c.GetCertInfo("gateway/example", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(certInfo.Cert) != string(testcerts.ServerCert) || string(certInfo.Key) != string(testcerts.ServerKey) {
+		t.Fatalf("unexpected certificate %v", certInfo)
+	}
+	caInfo, err := c.GetCaCert("gateway/example-cacert", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caInfo.Cert) != string(testcerts.CACert) {
+		t.Fatalf("unexpected CA certificate %v", caInfo)
+	}
+	for _, name := range []string{"missing", "../etc", "/etc"} {
+		if _, err := c.GetCertInfo(name, ""); err == nil {
+			t.Fatalf("expected an error reading %q", name)
+		}
+	}
+}
+
+func TestControllerEvents(t *testing.T) {
+	root := t.TempDir()
+	writeCredential(t, filepath.Join(root, "existing"), map[string][]byte{"tls.crt": testcerts.ServerCert})
+	c := NewController(root)
+	events := make(chan string, 10)
+	c.AddEventHandler(func(name, namespace string) {
+		events <- name
+	})
+	stop := test.NewStop(t)
+	go func() {
+		_ = c.Run(stop)
+	}()
+	expect := func(name string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case got := <-events:
+				if got == name {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("expected an event for %q", name)
+			}
+		}
+	}
+
+	// The watcher may not be started yet, so keep rotating until notified.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+				_ = os.WriteFile(filepath.Join(root, "existing", "tls.crt"), testcerts.RotatedCert, 0o644)
+			}
+		}
+	}()
+	expect("existing")
+
+	writeCredential(t, filepath.Join(root, "added"), map[string][]byte{"tls.crt": testcerts.ServerCert})
+	expect("added")
+}
@@ -0,0 +1,129 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	sa "k8s.io/apiserver/pkg/authentication/serviceaccount"
+
+	"istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// authorizationWildcard matches any namespace or service account in the authorization allowlist.
+const authorizationWildcard = "*"
+
+// parseAuthorizationAllowlist parses a comma separated list of namespace/service-account entries, where either
+// part may be a wildcard. It returns nil if the list is empty.
+func parseAuthorizationAllowlist(value string) sets.String {
+	allowlist := sets.New[string]()
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if parts := strings.Split(entry, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Warnf("ignoring secret authorization allowlist entry %q, expected namespace/service-account", entry)
+			continue
+		}
+		allowlist.Insert(entry)
+	}
+	if allowlist.IsEmpty() {
+		return nil
+	}
+	return allowlist
+}
+
+// authorizeByAllowlist returns an error unless the service account is allowed to read the secrets of its namespace
+// by the allowlist.
+func authorizeByAllowlist(allowlist sets.String, serviceAccount, namespace string) error {
+	if allowlist.Contains(namespace+"/"+serviceAccount) || allowlist.Contains(namespace+"/"+authorizationWildcard) ||
+		allowlist.Contains(authorizationWildcard+"/"+serviceAccount) ||
+		allowlist.Contains(authorizationWildcard+"/"+authorizationWildcard) {
+		return nil
+	}
+	return fmt.Errorf("%s/%s is not allowed to read secrets by the authorization allowlist", serviceAccount, namespace)
+}
+
+var (
+	_ credentials.NamespaceSecretAuthorizer = &CredentialsController{}
+	_ credentials.NamespaceSecretAuthorizer = &AggregateController{}
+)
+
+// AuthorizeNamespaceSecret returns an error unless the service accounts of the namespace are allowed to get the
+// secret, which platform teams grant with a RoleBinding to the system:serviceaccounts:<namespace> group in the
+// namespace of the secret.
+func (s *CredentialsController) AuthorizeNamespaceSecret(namespace, secretName, secretNamespace string) error {
+	key := "namespace:" + namespace + ":" + secretNamespace + "/" + secretName
+	if cached, f := s.cachedAuthorization(key); f {
+		return cached
+	}
+	_, err, _ := s.authorizations.Do(key, func() (any, error) {
+		if cached, f := s.cachedAuthorization(key); f {
+			return nil, cached
+		}
+		start := time.Now()
+		err := s.reviewNamespaceSecretAuthorization(namespace, secretName, secretNamespace)
+		recordAuthorizationReview(err, time.Since(start))
+		s.insertCache(key, err)
+		return nil, err
+	})
+	return err
+}
+
+// reviewNamespaceSecretAuthorization sends a SubjectAccessReview checking the service accounts of the namespace are
+// allowed to get the secret.
+func (s *CredentialsController) reviewNamespaceSecretAuthorization(namespace, secretName, secretNamespace string) error {
+	resp, err := s.sar.Create(context.Background(), &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: secretNamespace,
+				Verb:      "get",
+				Resource:  "secrets",
+				Name:      secretName,
+			},
+			Groups: []string{sa.AllServiceAccountsGroup, sa.MakeNamespaceGroupName(namespace)},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !resp.Status.Allowed {
+		return fmt.Errorf("service accounts of %s are not authorized to get secret %s/%s: %v", namespace,
+			secretNamespace, secretName, resp.Status.Reason)
+	}
+	return nil
+}
+
+// AuthorizeNamespaceSecret authorizes the namespace in the cluster of the proxy, like Authorize.
+func (a *AggregateController) AuthorizeNamespaceSecret(namespace, secretName, secretNamespace string) error {
+	return a.authController.AuthorizeNamespaceSecret(namespace, secretName, secretNamespace)
+}
+
+var (
+	allowedTag = monitoring.CreateLabel("allowed")
+
+	authorizationReviews = monitoring.NewSum(
+		"pilot_sds_authorization_reviews_total",
+		"Total number of SubjectAccessReviews sent to authorize proxies to read secrets, labeled by whether "+
+			"they allowed it. Failed reviews are not allowed.",
+	)
+
+	authorizationReviewTime = monitoring.NewDistribution(
+		"pilot_sds_authorization_review_seconds",
+		"Time in seconds taken by the SubjectAccessReviews authorizing proxies to read secrets.",
+		[]float64{.005, .01, .05, .1, .5, 1, 5},
+	)
+)
+
+func recordAuthorizationReview(err error, took time.Duration) {
+	authorizationReviews.With(allowedTag.Value(strconv.FormatBool(err == nil))).Increment()
+	authorizationReviewTime.Record(took.Seconds())
+}
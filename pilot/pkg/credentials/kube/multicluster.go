@@ -176,3 +176,25 @@ func (a *AggregateController) GetDockerCredential(name, namespace string) ([]byt
 	}
 	return nil, firstError
 }
+
+// Added by ingress
+
+var _ credentials.GenericSecretController = &AggregateController{}
+
+func (a *AggregateController) GetGenericSecret(name, namespace, key string) ([]byte, error) {
+	// Search through all clusters, find first non-empty result
+	var firstError error
+	for _, c := range a.controllers {
+		k, err := c.GetGenericSecret(name, namespace, key)
+		if err != nil {
+			if firstError == nil {
+				firstError = err
+			}
+		} else {
+			return k, nil
+		}
+	}
+	return nil, firstError
+}
+
+// End added by ingress
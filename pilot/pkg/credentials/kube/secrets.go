@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
 	authorizationv1 "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,10 +32,12 @@ import (
 
 	"istio.io/istio/pilot/pkg/credentials"
 	securitymodel "istio.io/istio/pilot/pkg/security/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
 )
 
 const (
@@ -65,6 +68,14 @@ type CredentialsController struct {
 
 	mu                 sync.RWMutex
 	authorizationCache map[authorizationKey]authorizationResponse
+
+	// Added by ingress
+	// authorizationAllowlist, if set, authorizes the proxies instead of SubjectAccessReviews.
+	authorizationAllowlist sets.String
+	// authorizations coalesces the concurrent authorizations of a service account, such as those of the replicas
+	// of a gateway getting their secrets in a push, into a single SubjectAccessReview.
+	authorizations singleflight.Group
+	// End added by ingress
 }
 
 type authorizationKey string
@@ -95,11 +106,12 @@ func NewCredentialsController(kc kube.Client) *CredentialsController {
 		secrets:            secrets,
 		sar:                kc.Kube().AuthorizationV1().SubjectAccessReviews(),
 		authorizationCache: make(map[authorizationKey]authorizationResponse),
+		// Added by ingress
+		authorizationAllowlist: parseAuthorizationAllowlist(alifeatures.SecretAuthorizationAllowlist),
+		// End added by ingress
 	}
 }
 
-const cacheTTL = time.Minute
-
 // clearExpiredCache iterates through the cache and removes all expired entries. Should be called with mutex held.
 func (s *CredentialsController) clearExpiredCache() {
 	for k, v := range s.authorizationCache {
@@ -129,11 +141,16 @@ func (s *CredentialsController) insertCache(user string, response error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	key := authorizationKey(user)
-	expDelta := cacheTTL
+	// Modified by ingress
+	expDelta := alifeatures.SecretAuthorizationNegativeCacheTTL
 	if response == nil {
 		// Cache success a bit longer, there is no need to quickly revoke access
-		expDelta *= 5
+		expDelta = alifeatures.SecretAuthorizationCacheTTL
 	}
+	if expDelta <= 0 {
+		return
+	}
+	// End modified by ingress
 	log.Debugf("cached authorization for user %s: %v", user, response)
 	s.authorizationCache[key] = authorizationResponse{
 		expiration: time.Now().Add(expDelta),
@@ -142,32 +159,51 @@ func (s *CredentialsController) insertCache(user string, response error) {
 }
 
 func (s *CredentialsController) Authorize(serviceAccount, namespace string) error {
+	// Added by ingress
+	if s.authorizationAllowlist != nil {
+		return authorizeByAllowlist(s.authorizationAllowlist, serviceAccount, namespace)
+	}
+	// End added by ingress
 	user := sa.MakeUsername(namespace, serviceAccount)
 	if cached, f := s.cachedAuthorization(user); f {
 		return cached
 	}
-	err := func() error {
-		resp, err := s.sar.Create(context.Background(), &authorizationv1.SubjectAccessReview{
-			ObjectMeta: metav1.ObjectMeta{},
-			Spec: authorizationv1.SubjectAccessReviewSpec{
-				ResourceAttributes: &authorizationv1.ResourceAttributes{
-					Namespace: namespace,
-					Verb:      "list",
-					Resource:  "secrets",
-				},
-				User: user,
-			},
-		}, metav1.CreateOptions{})
-		if err != nil {
-			return err
-		}
-		if !resp.Status.Allowed {
-			return fmt.Errorf("%s/%s is not authorized to read secrets: %v", serviceAccount, namespace, resp.Status.Reason)
+	// Modified by ingress
+	_, err, _ := s.authorizations.Do(user, func() (any, error) {
+		// The review may have completed since the cache was checked.
+		if cached, f := s.cachedAuthorization(user); f {
+			return nil, cached
 		}
-		return nil
-	}()
-	s.insertCache(user, err)
+		start := time.Now()
+		err := s.reviewAuthorization(serviceAccount, namespace, user)
+		recordAuthorizationReview(err, time.Since(start))
+		s.insertCache(user, err)
+		return nil, err
+	})
 	return err
+	// End modified by ingress
+}
+
+// reviewAuthorization sends a SubjectAccessReview checking the user is allowed to read the secrets of its namespace.
+func (s *CredentialsController) reviewAuthorization(serviceAccount, namespace, user string) error {
+	resp, err := s.sar.Create(context.Background(), &authorizationv1.SubjectAccessReview{
+		ObjectMeta: metav1.ObjectMeta{},
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Resource:  "secrets",
+			},
+			User: user,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !resp.Status.Allowed {
+		return fmt.Errorf("%s/%s is not authorized to read secrets: %v", serviceAccount, namespace, resp.Status.Reason)
+	}
+	return nil
 }
 
 func (s *CredentialsController) GetCertInfo(name, namespace string) (certInfo *credentials.CertInfo, err error) {
@@ -207,6 +243,24 @@ func (s *CredentialsController) GetDockerCredential(name, namespace string) ([]b
 	return nil, fmt.Errorf("cannot find docker config at secret %v/%v", namespace, name)
 }
 
+// Added by ingress
+
+var _ credentials.GenericSecretController = &CredentialsController{}
+
+// GetGenericSecret returns the data of a key of a secret.
+func (s *CredentialsController) GetGenericSecret(name, namespace, key string) ([]byte, error) {
+	k8sSecret := s.secrets.Get(name, namespace)
+	if k8sSecret == nil {
+		return nil, fmt.Errorf("secret %v/%v not found", namespace, name)
+	}
+	if data := k8sSecret.Data[key]; len(data) > 0 {
+		return data, nil
+	}
+	return nil, fmt.Errorf("cannot find key %v in secret %v/%v", key, namespace, name)
+}
+
+// End added by ingress
+
 func hasKeys(d map[string][]byte, keys ...string) bool {
 	for _, k := range keys {
 		_, f := d[k]
@@ -292,6 +346,15 @@ func extractRoot(scrt *v1.Secret) (certInfo *credentials.CertInfo, err error) {
 		GenericScrtCaCert, TLSSecretCaCert, found)
 }
 
+// Added by ingress
+
+// ExtractRoot extracts the root certificate and CRL of a secret.
+func ExtractRoot(scrt *v1.Secret) (certInfo *credentials.CertInfo, err error) {
+	return extractRoot(scrt)
+}
+
+// End added by ingress
+
 func (s *CredentialsController) AddEventHandler(h func(name string, namespace string)) {
 	// register handler before informer starts
 	s.secrets.AddEventHandler(controllers.ObjectHandler(func(o controllers.Object) {
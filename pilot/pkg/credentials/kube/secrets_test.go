@@ -17,6 +17,7 @@ package kube
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	authorizationv1 "k8s.io/api/authorization/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -25,6 +26,8 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
 
+	"istio.io/istio/pilot/pkg/credentials"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	cluster2 "istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/multicluster"
@@ -421,6 +424,140 @@ func TestAuthorize(t *testing.T) {
 	}
 }
 
+func TestAuthorizeAllowlist(t *testing.T) {
+	test.SetForTest(t, &alifeatures.SecretAuthorizationAllowlist, "ns-a/sa-a, ns-b/*")
+	client := kube.NewFakeClient()
+	allowIdentities(client, "system:serviceaccount:ns-c:sa-c")
+	sc := NewMulticluster("local")
+	sc.ClusterAdded(&multicluster.Cluster{ID: "local", Client: client}, nil)
+	con, err := sc.ForCluster("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		sa      string
+		ns      string
+		allowed bool
+	}{
+		{"sa-a", "ns-a", true},
+		{"sa-other", "ns-a", false},
+		{"sa-other", "ns-b", true},
+		{"sa-c", "ns-c", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.sa+"/"+tt.ns, func(t *testing.T) {
+			if got := con.Authorize(tt.sa, tt.ns); (got == nil) != tt.allowed {
+				t.Fatalf("expected allowed=%v, got error=%v", tt.allowed, got)
+			}
+		})
+	}
+	if got := countSubjectAccessReviews(client); got != 0 {
+		t.Fatalf("expected no SubjectAccessReview, got %d", got)
+	}
+}
+
+func TestAuthorizeNegativeCache(t *testing.T) {
+	cases := []struct {
+		name string
+		ttl  time.Duration
+		want int
+	}{
+		{"cached", time.Minute, 1},
+		{"not cached", 0, 2},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.SecretAuthorizationNegativeCacheTTL, tt.ttl)
+			client := kube.NewFakeClient()
+			allowIdentities(client)
+			con := NewCredentialsController(client)
+			for i := 0; i < 2; i++ {
+				if err := con.Authorize("sa-denied", "ns"); err == nil {
+					t.Fatal("expected the service account to be denied")
+				}
+			}
+			if got := countSubjectAccessReviews(client); got != tt.want {
+				t.Fatalf("expected %d SubjectAccessReviews, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCoalesced(t *testing.T) {
+	client := kube.NewFakeClient()
+	allowIdentities(client, "system:serviceaccount:ns:sa")
+	release := make(chan struct{})
+	client.Kube().(*fake.Clientset).Fake.PrependReactor("create", "subjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			<-release
+			return false, nil, nil
+		})
+	con := NewCredentialsController(client)
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- con.Authorize("sa", "ns")
+		}()
+	}
+	close(release)
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := countSubjectAccessReviews(client); got != 1 {
+		t.Fatalf("expected a single SubjectAccessReview, got %d", got)
+	}
+}
+
+func countSubjectAccessReviews(c kube.Client) int {
+	n := 0
+	for _, action := range c.Kube().(*fake.Clientset).Fake.Actions() {
+		if action.GetVerb() == "create" && action.GetResource().Resource == "subjectaccessreviews" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestAuthorizeNamespaceSecret(t *testing.T) {
+	client := kube.NewFakeClient()
+	client.Kube().(*fake.Clientset).Fake.PrependReactor("create", "subjectaccessreviews",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			a := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+			attrs := a.Spec.ResourceAttributes
+			allowed := sets.New(a.Spec.Groups...).Contains("system:serviceaccounts:tenant-a") &&
+				attrs.Verb == "get" && attrs.Namespace == "central" && attrs.Name == "registry"
+			return true, &authorizationv1.SubjectAccessReview{
+				Status: authorizationv1.SubjectAccessReviewStatus{Allowed: allowed},
+			}, nil
+		})
+	sc := NewMulticluster("local")
+	sc.ClusterAdded(&multicluster.Cluster{ID: "local", Client: client}, nil)
+	con, err := sc.ForCluster("local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		namespace string
+		name      string
+		allowed   bool
+	}{
+		{"tenant-a", "registry", true},
+		{"tenant-a", "other", false},
+		{"tenant-b", "registry", false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.namespace+"/"+tt.name, func(t *testing.T) {
+			got := con.(credentials.NamespaceSecretAuthorizer).AuthorizeNamespaceSecret(tt.namespace, tt.name, "central")
+			if (got == nil) != tt.allowed {
+				t.Fatalf("expected allowed=%v, got error=%v", tt.allowed, got)
+			}
+		})
+	}
+}
+
 func TestSecretsControllerMulticluster(t *testing.T) {
 	stop := make(chan struct{})
 	defer close(stop)
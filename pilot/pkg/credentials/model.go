@@ -42,3 +42,26 @@ type MulticlusterController interface {
 	ForCluster(cluster cluster.ID) (Controller, error)
 	AddSecretHandler(func(name, namespace string))
 }
+
+// Added by ingress
+
+// SecretAuthorizer decides whether a proxy identity may receive a secret. It is an alternative to the Authorize
+// method of the Controller, which allows identities to read all the secrets of their namespace, for users whose
+// policies are defined outside of Kubernetes, such as for other secret stores.
+type SecretAuthorizer interface {
+	AuthorizeSecret(serviceAccount, namespace, secretName, secretNamespace string) error
+}
+
+// NamespaceSecretAuthorizer decides whether the workloads of a namespace may reference a secret of another namespace,
+// such as a registry credential managed by a platform team in a central namespace for the WasmPlugins of all tenants.
+type NamespaceSecretAuthorizer interface {
+	AuthorizeNamespaceSecret(namespace, secretName, secretNamespace string) error
+}
+
+// GenericSecretController gets the data of a key of a secret, served to the proxies as a generic secret, such as the
+// client secret of OAuth2. It is implemented by the controllers of the stores of such secrets.
+type GenericSecretController interface {
+	GetGenericSecret(name, namespace, key string) ([]byte, error)
+}
+
+// End added by ingress
@@ -0,0 +1,279 @@
+package descriptorset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/grpctranscoding"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/wasm"
+)
+
+const (
+	// requestTimeout bounds the fetches of the descriptor sets.
+	requestTimeout = 10 * time.Second
+	// requestMaxRetry is the number of attempts of the fetches of the http(s):// descriptor sets.
+	requestMaxRetry = 3
+	// maxDescriptorSetSize bounds the size of the oci:// descriptor sets.
+	maxDescriptorSetSize = 16 << 20
+)
+
+type cachedDescriptorSet struct {
+	set *model.DescriptorSet
+	// version is the resource version of a ConfigMap, the digest of an OCI artifact, or the hash of the content.
+	version string
+}
+
+// fetchFunc fetches the descriptor set of a source, returning a nil set if its version is still the previous one.
+type fetchFunc func(ctx context.Context, source, previous string) ([]byte, string, error)
+
+// Controller serves the proto descriptor sets of the gRPC-JSON transcoding of the virtual services. A source is
+// fetched asynchronously the first time it is requested, the handlers being notified once it is, and then polled
+// every refresh interval, so that the routes are built again with the descriptor sets updated.
+type Controller struct {
+	fetchers map[string]fetchFunc
+	refresh  time.Duration
+
+	mu          sync.Mutex
+	descriptors map[string]cachedDescriptorSet
+	handlers    []func(source string)
+}
+
+var _ model.DescriptorSetStore = &Controller{}
+
+// NewController returns a controller of the descriptor sets, reading the ConfigMaps with the client, if any, and
+// polling the sources every refresh interval.
+func NewController(client kubernetes.Interface, refresh time.Duration) *Controller {
+	c := &Controller{
+		refresh:     refresh,
+		descriptors: map[string]cachedDescriptorSet{},
+	}
+	httpFetcher := wasm.NewHTTPFetcher(requestTimeout, requestMaxRetry)
+	c.fetchers = map[string]fetchFunc{
+		"http://":                 httpFetch(httpFetcher),
+		"https://":                httpFetch(httpFetcher),
+		grpctranscoding.OCIScheme: ociFetch(remote.WithAuthFromKeychain(authn.DefaultKeychain)),
+	}
+	if client != nil {
+		c.fetchers[grpctranscoding.ConfigMapScheme] = configMapFetch(client)
+	}
+	return c
+}
+
+// DescriptorSet returns the descriptor set of the source if it was fetched, else starts fetching it.
+func (c *Controller) DescriptorSet(source string) *model.DescriptorSet {
+	c.mu.Lock()
+	cached, f := c.descriptors[source]
+	if !f {
+		c.descriptors[source] = cachedDescriptorSet{}
+	}
+	c.mu.Unlock()
+	if !f {
+		go c.update(source)
+	}
+	return cached.set
+}
+
+// AddEventHandler adds a handler called with the source of a descriptor set when it is fetched or changes.
+func (c *Controller) AddEventHandler(h func(source string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+// Run polls the descriptor sets every refresh interval until stopped.
+func (c *Controller) Run(stop <-chan struct{}) error {
+	ticker := time.NewTicker(c.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			c.poll()
+		}
+	}
+}
+
+// poll fetches the descriptor sets again, including the ones which failed to be fetched.
+func (c *Controller) poll() {
+	c.mu.Lock()
+	sources := make([]string, 0, len(c.descriptors))
+	for source := range c.descriptors {
+		sources = append(sources, source)
+	}
+	c.mu.Unlock()
+	for _, source := range sources {
+		c.update(source)
+	}
+}
+
+// update fetches the descriptor set of the source, notifying the handlers if it changed. Descriptor sets failing
+// to be fetched are served from the cache until the next poll.
+func (c *Controller) update(source string) {
+	c.mu.Lock()
+	previous := c.descriptors[source]
+	c.mu.Unlock()
+	set, version, err := c.fetch(source, previous.version)
+	if err != nil {
+		log.Warnf("failed to fetch descriptor set %s: %v", source, err)
+		return
+	}
+	if set == nil {
+		return
+	}
+	c.mu.Lock()
+	c.descriptors[source] = cachedDescriptorSet{set: set, version: version}
+	handlers := c.handlers
+	c.mu.Unlock()
+	log.Infof("descriptor set %s updated to version %s", source, version)
+	for _, h := range handlers {
+		h(source)
+	}
+}
+
+// fetch fetches and parses the descriptor set of the source, returning a nil set if its version did not change.
+func (c *Controller) fetch(source, previous string) (*model.DescriptorSet, string, error) {
+	var fetcher fetchFunc
+	for scheme, f := range c.fetchers {
+		if strings.HasPrefix(source, scheme) {
+			fetcher = f
+			break
+		}
+	}
+	if fetcher == nil {
+		return nil, "", fmt.Errorf("unsupported source")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	bin, version, err := fetcher(ctx, source, previous)
+	if err != nil {
+		return nil, "", err
+	}
+	if bin == nil || version == previous {
+		return nil, version, nil
+	}
+	services, err := parseServices(bin)
+	if err != nil {
+		return nil, "", err
+	}
+	return &model.DescriptorSet{Bin: bin, Services: services}, version, nil
+}
+
+// parseServices returns the fully qualified names of the services of a serialized FileDescriptorSet.
+func parseServices(bin []byte) (sets.String, error) {
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(bin, fds); err != nil {
+		return nil, fmt.Errorf("invalid FileDescriptorSet: %v", err)
+	}
+	services := sets.New[string]()
+	for _, file := range fds.File {
+		for _, service := range file.Service {
+			if file.GetPackage() == "" {
+				services.Insert(service.GetName())
+			} else {
+				services.Insert(file.GetPackage() + "." + service.GetName())
+			}
+		}
+	}
+	if services.Len() == 0 {
+		return nil, fmt.Errorf("the FileDescriptorSet has no service")
+	}
+	return services, nil
+}
+
+// configMapFetch reads the descriptor sets of the configmap://<namespace>/<name>/<key> sources, from the binary
+// data of the key, else its data.
+func configMapFetch(client kubernetes.Interface) fetchFunc {
+	return func(ctx context.Context, source, previous string) ([]byte, string, error) {
+		parts := strings.Split(strings.TrimPrefix(source, grpctranscoding.ConfigMapScheme), "/")
+		if len(parts) != 3 {
+			return nil, "", fmt.Errorf("expected configmap://<namespace>/<name>/<key>")
+		}
+		cm, err := client.CoreV1().ConfigMaps(parts[0]).Get(ctx, parts[1], metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil, "", fmt.Errorf("configmap %s/%s not found", parts[0], parts[1])
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if cm.ResourceVersion != "" && cm.ResourceVersion == previous {
+			return nil, previous, nil
+		}
+		if bin, f := cm.BinaryData[parts[2]]; f {
+			return bin, cm.ResourceVersion, nil
+		}
+		if data, f := cm.Data[parts[2]]; f {
+			return []byte(data), cm.ResourceVersion, nil
+		}
+		return nil, "", fmt.Errorf("configmap %s/%s has no key %s", parts[0], parts[1], parts[2])
+	}
+}
+
+// httpFetch downloads the descriptor sets of the http(s):// sources, versioned by the hash of their content.
+func httpFetch(fetcher *wasm.HTTPFetcher) fetchFunc {
+	return func(ctx context.Context, source, previous string) ([]byte, string, error) {
+		bin, err := fetcher.Fetch(ctx, source, false)
+		if err != nil {
+			return nil, "", err
+		}
+		sum := sha256.Sum256(bin)
+		return bin, hex.EncodeToString(sum[:]), nil
+	}
+}
+
+// ociFetch pulls the descriptor sets of the oci://<reference> sources, the single layer of an artifact such as
+// pushed by oras, versioned by the digest of its manifest so that the layer is only pulled when it changes.
+func ociFetch(options ...remote.Option) fetchFunc {
+	return func(ctx context.Context, source, previous string) ([]byte, string, error) {
+		ref, err := name.ParseReference(strings.TrimPrefix(source, grpctranscoding.OCIScheme))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid reference: %v", err)
+		}
+		img, err := remote.Image(ref, append(options, remote.WithContext(ctx))...)
+		if err != nil {
+			return nil, "", err
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return nil, "", err
+		}
+		if digest.String() == previous {
+			return nil, previous, nil
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			return nil, "", err
+		}
+		if len(layers) != 1 {
+			return nil, "", fmt.Errorf("expected an artifact of a single layer, got %d layers", len(layers))
+		}
+		rc, err := layers[0].Compressed()
+		if err != nil {
+			return nil, "", err
+		}
+		defer rc.Close()
+		bin, err := io.ReadAll(io.LimitReader(rc, maxDescriptorSetSize))
+		if err != nil {
+			return nil, "", err
+		}
+		return bin, digest.String(), nil
+	}
+}
@@ -0,0 +1,128 @@
+package descriptorset
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func descriptorSet(t *testing.T, pkg string, services ...string) []byte {
+	t.Helper()
+	file := &descriptorpb.FileDescriptorProto{Name: proto.String(pkg + ".proto"), Package: proto.String(pkg)}
+	for _, service := range services {
+		file.Service = append(file.Service, &descriptorpb.ServiceDescriptorProto{Name: proto.String(service)})
+	}
+	bin, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestConfigMapDescriptorSet(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "greeter", Namespace: "default", ResourceVersion: "1"},
+		BinaryData: map[string][]byte{"greeter.pb": descriptorSet(t, "helloworld", "Greeter")},
+	})
+	c := NewController(client, time.Hour)
+	var notified atomic.Int32
+	c.AddEventHandler(func(source string) {
+		notified.Add(1)
+	})
+
+	source := "configmap://default/greeter/greeter.pb"
+	if set := c.DescriptorSet(source); set != nil {
+		t.Fatalf("got descriptor set %v before it was fetched", set)
+	}
+	retry.UntilOrFail(t, func() bool {
+		return c.DescriptorSet(source) != nil
+	}, retry.Timeout(5*time.Second))
+	if set := c.DescriptorSet(source); !set.Services.Contains("helloworld.Greeter") {
+		t.Fatalf("got services %v", set.Services)
+	}
+	if notified.Load() != 1 {
+		t.Fatalf("got %d notifications, want 1", notified.Load())
+	}
+
+	// An unchanged ConfigMap is not notified.
+	c.poll()
+	if notified.Load() != 1 {
+		t.Fatalf("got %d notifications, want 1", notified.Load())
+	}
+
+	_, err := client.CoreV1().ConfigMaps("default").Update(context.Background(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "greeter", Namespace: "default", ResourceVersion: "2"},
+		BinaryData: map[string][]byte{"greeter.pb": descriptorSet(t, "helloworld", "Greeter", "Farewell")},
+	}, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.poll()
+	if notified.Load() != 2 {
+		t.Fatalf("got %d notifications, want 2", notified.Load())
+	}
+	if set := c.DescriptorSet(source); !set.Services.Contains("helloworld.Farewell") {
+		t.Fatalf("got services %v", set.Services)
+	}
+
+	// A deleted ConfigMap keeps being served from the cache.
+	if err := client.CoreV1().ConfigMaps("default").Delete(context.Background(), "greeter", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c.poll()
+	if c.DescriptorSet(source) == nil {
+		t.Fatal("got no descriptor set after the ConfigMap was deleted")
+	}
+}
+
+func TestHTTPDescriptorSet(t *testing.T) {
+	bin := descriptorSet(t, "helloworld", "Greeter")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bin)
+	}))
+	defer server.Close()
+	c := NewController(nil, time.Hour)
+
+	set, version, err := c.fetch(server.URL+"/greeter.pb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !set.Services.Contains("helloworld.Greeter") {
+		t.Fatalf("got services %v", set.Services)
+	}
+	if set, _, err := c.fetch(server.URL+"/greeter.pb", version); err != nil || set != nil {
+		t.Fatalf("got descriptor set %v and error %v for an unchanged version", set, err)
+	}
+}
+
+func TestInvalidDescriptorSet(t *testing.T) {
+	cases := []struct {
+		name string
+		bin  []byte
+	}{
+		{name: "not a descriptor set", bin: []byte("not a descriptor set")},
+		{name: "no service", bin: descriptorSet(t, "helloworld")},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseServices(tt.bin); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+	c := NewController(nil, time.Hour)
+	if _, _, err := c.fetch("configmap://default/greeter/greeter.pb", ""); err == nil {
+		t.Fatal("expected an error reading a ConfigMap without client")
+	}
+}
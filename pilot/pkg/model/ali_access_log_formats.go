@@ -0,0 +1,167 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	celformatter "github.com/envoyproxy/go-control-plane/envoy/extensions/formatter/cel/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+const celCommandOperator = "%CEL"
+
+// celFormatter configures the formatter of the CEL command operators, such as %CEL(request.headers['x-user'])%.
+var celFormatter = &core.TypedExtensionConfig{
+	Name:        "envoy.formatter.cel",
+	TypedConfig: protoconv.MessageToAny(&celformatter.Cel{}),
+}
+
+// AccessLogFormat is a named JSON access log format of the mesh, referenced by the Telemetry resources with the
+// higress.io/access-log-format annotation.
+type AccessLogFormat struct {
+	// IncludeDefaults starts the format from the fields of the default JSON format of Istio, which the fields
+	// override.
+	IncludeDefaults bool             `json:"includeDefaults,omitempty"`
+	Fields          []AccessLogField `json:"fields"`
+	// OmitEmptyValues leaves out the fields whose value is empty, instead of logging them as "-".
+	OmitEmptyValues bool `json:"omitEmptyValues,omitempty"`
+}
+
+// AccessLogField is a field of an AccessLogFormat, either the value of an Envoy command operator format string or a
+// CEL expression evaluated on the request attributes.
+type AccessLogField struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	CEL   string `json:"cel,omitempty"`
+}
+
+// ParseAccessLogFormats parses the named access log formats, as a JSON object of the formats by name.
+func ParseAccessLogFormats(s string) (map[string]*AccessLogFormat, error) {
+	out := map[string]*AccessLogFormat{}
+	if s == "" {
+		return out, nil
+	}
+	if err := json.Unmarshal([]byte(s), &out); err != nil {
+		return nil, err
+	}
+	for name, format := range out {
+		if err := format.validate(); err != nil {
+			return nil, fmt.Errorf("invalid access log format %s: %v", name, err)
+		}
+	}
+	return out, nil
+}
+
+func (f *AccessLogFormat) validate() error {
+	if f == nil || (len(f.Fields) == 0 && !f.IncludeDefaults) {
+		return fmt.Errorf("no fields")
+	}
+	names := map[string]bool{}
+	for _, field := range f.Fields {
+		if field.Name == "" {
+			return fmt.Errorf("field without name")
+		}
+		if names[field.Name] {
+			return fmt.Errorf("duplicate field %s", field.Name)
+		}
+		names[field.Name] = true
+		if (field.Value == "") == (field.CEL == "") {
+			return fmt.Errorf("field %s must have either a value or a cel expression", field.Name)
+		}
+		if strings.Contains(field.CEL, ")%") {
+			return fmt.Errorf("cel expression of field %s cannot contain )%%", field.Name)
+		}
+	}
+	return nil
+}
+
+// jsonFormat returns the JSON format of the access logs, with the formatters its command operators need.
+func (f *AccessLogFormat) jsonFormat() *core.SubstitutionFormatString {
+	fields := map[string]*structpb.Value{}
+	if f.IncludeDefaults {
+		for name, value := range EnvoyJSONLogFormatIstio.Fields {
+			fields[name] = value
+		}
+	}
+	usesCEL := false
+	for _, field := range f.Fields {
+		value := field.Value
+		if field.CEL != "" {
+			value = celCommandOperator + "(" + field.CEL + ")%"
+			usesCEL = true
+		}
+		fields[field.Name] = structpb.NewStringValue(value)
+	}
+	jsonStruct := &structpb.Struct{Fields: fields}
+	formatters := accessLogJSONFormatters(jsonStruct)
+	if usesCEL {
+		formatters = append(formatters, celFormatter)
+	}
+	return &core.SubstitutionFormatString{
+		Format:          &core.SubstitutionFormatString_JsonFormat{JsonFormat: jsonStruct},
+		OmitEmptyValues: f.OmitEmptyValues,
+		Formatters:      formatters,
+	}
+}
+
+var (
+	accessLogFormatsOnce sync.Once
+	accessLogFormats     map[string]*AccessLogFormat
+)
+
+// meshAccessLogFormats returns the formats of PILOT_ACCESS_LOG_FORMATS.
+func meshAccessLogFormats() map[string]*AccessLogFormat {
+	accessLogFormatsOnce.Do(func() {
+		formats, err := ParseAccessLogFormats(alifeatures.AccessLogFormats)
+		if err != nil {
+			log.Errorf("ignoring invalid PILOT_ACCESS_LOG_FORMATS: %v", err)
+		}
+		accessLogFormats = formats
+	})
+	return accessLogFormats
+}
+
+// telemetryAccessLogFormat returns the name of the access log format referenced by the
+// higress.io/access-log-format annotation of the Telemetry, if any.
+func telemetryAccessLogFormat(cfg config.Config) string {
+	return strings.TrimSpace(cfg.Annotations[constants.AccessLogFormatAnnotation])
+}
+
+// namedFormatAccessLog returns the access log of the provider formatted with the named format of the mesh, or nil
+// if the provider is not a file access log provider, or the format does not exist.
+func (t *Telemetries) namedFormatAccessLog(fp *meshconfig.MeshConfig_ExtensionProvider, name string) *accesslog.AccessLog {
+	prov := fp.GetEnvoyFileAccessLog()
+	if prov == nil {
+		log.Debugf("ignoring access log format %s of provider %s, only file access logs are formatted", name, fp.Name)
+		return nil
+	}
+	format := t.accessLogFormats[name]
+	if format == nil {
+		log.Warnf("unknown access log format %s referenced for provider %s, using the format of the provider", name, fp.Name)
+		return nil
+	}
+	path := prov.Path
+	if path == "" {
+		path = DevStdout
+	}
+	fl := &fileaccesslog.FileAccessLog{
+		Path:            path,
+		AccessLogFormat: &fileaccesslog.FileAccessLog_LogFormat{LogFormat: format.jsonFormat()},
+	}
+	return &accesslog.AccessLog{
+		Name:       wellknown.FileAccessLog,
+		ConfigType: &accesslog.AccessLog_TypedConfig{TypedConfig: protoconv.MessageToAny(fl)},
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"testing"
+
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+
+	tpb "istio.io/api/telemetry/v1alpha1"
+	"istio.io/api/type/v1beta1"
+	"istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestParseAccessLogFormats(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		err   bool
+	}{
+		{name: "empty", input: ""},
+		{name: "valid", input: `{"gateway": {"fields": [{"name": "code", "value": "%RESPONSE_CODE%"}, ` +
+			`{"name": "tenant", "cel": "request.headers['x-tenant']"}]}}`},
+		{name: "defaults only", input: `{"istio": {"includeDefaults": true}}`},
+		{name: "invalid json", input: `{"gateway": [`, err: true},
+		{name: "no fields", input: `{"gateway": {}}`, err: true},
+		{name: "unnamed field", input: `{"gateway": {"fields": [{"value": "%RESPONSE_CODE%"}]}}`, err: true},
+		{name: "duplicate field", input: `{"gateway": {"fields": [{"name": "code", "value": "%RESPONSE_CODE%"}, ` +
+			`{"name": "code", "value": "%RESPONSE_FLAGS%"}]}}`, err: true},
+		{name: "value and cel", input: `{"gateway": {"fields": [{"name": "code", "value": "%RESPONSE_CODE%", ` +
+			`"cel": "response.code"}]}}`, err: true},
+		{name: "cel ending the operator", input: `{"gateway": {"fields": [{"name": "code", "cel": "response.code)%"}]}}`, err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAccessLogFormats(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestAccessLoggingWithNamedFormat(t *testing.T) {
+	sidecar := &Proxy{
+		ConfigNamespace: "default",
+		Labels:          map[string]string{"app": "test"},
+		Metadata:        &NodeMetadata{},
+	}
+	formats, err := ParseAccessLogFormats(`{"gateway": {"includeDefaults": true, "omitEmptyValues": true, "fields": [` +
+		`{"name": "code", "value": "%RESPONSE_CODE%"}, {"name": "tenant", "cel": "request.headers['x-tenant']"}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envoy := &tpb.Telemetry{
+		AccessLogging: []*tpb.AccessLogging{{Providers: []*tpb.ProviderRef{{Name: "envoy"}}}},
+	}
+	withFormat := func(cfg config.Config, format string) config.Config {
+		cfg.Annotations = map[string]string{constants.AccessLogFormatAnnotation: format}
+		return cfg
+	}
+	workload := newTelemetry("default", &tpb.Telemetry{
+		Selector:      &v1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "test"}},
+		AccessLogging: []*tpb.AccessLogging{{Providers: []*tpb.ProviderRef{{Name: "envoy"}}}},
+	})
+	workload.Name = "workload"
+
+	cases := []struct {
+		name string
+		cfgs []config.Config
+		// format is the expected JSON format, or nil for the format of the provider.
+		format map[string]string
+	}{
+		{
+			name: "no annotation",
+			cfgs: []config.Config{newTelemetry("default", envoy)},
+		},
+		{
+			name:   "annotation",
+			cfgs:   []config.Config{withFormat(newTelemetry("default", envoy), "gateway")},
+			format: map[string]string{"code": "%RESPONSE_CODE%", "tenant": "%CEL(request.headers['x-tenant'])%"},
+		},
+		{
+			name:   "inherited by the workload",
+			cfgs:   []config.Config{withFormat(newTelemetry("istio-system", envoy), "gateway"), workload},
+			format: map[string]string{"code": "%RESPONSE_CODE%", "tenant": "%CEL(request.headers['x-tenant'])%"},
+		},
+		{
+			name: "unknown format",
+			cfgs: []config.Config{withFormat(newTelemetry("default", envoy), "unknown")},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetry, ctx := createTestTelemetries(tt.cfgs, t)
+			telemetry.accessLogFormats = formats
+			got := telemetry.AccessLogging(ctx, sidecar, networking.ListenerClassSidecarOutbound)
+			if len(got) != 1 {
+				t.Fatalf("got %d logging configs, want 1", len(got))
+			}
+			fl := &fileaccesslog.FileAccessLog{}
+			if err := got[0].AccessLog.GetTypedConfig().UnmarshalTo(fl); err != nil {
+				t.Fatal(err)
+			}
+			logFormat := fl.GetLogFormat()
+			if tt.format == nil {
+				if logFormat.GetJsonFormat() != nil && logFormat.GetJsonFormat().Fields["tenant"] != nil {
+					t.Fatalf("got the named format %v, want the format of the provider", logFormat)
+				}
+				return
+			}
+			fields := logFormat.GetJsonFormat().GetFields()
+			for name, value := range tt.format {
+				if fields[name].GetStringValue() != value {
+					t.Errorf("got field %s %q, want %q", name, fields[name].GetStringValue(), value)
+				}
+			}
+			if fields["start_time"] == nil {
+				t.Error("expected the default fields to be included")
+			}
+			if !logFormat.GetOmitEmptyValues() {
+				t.Error("expected empty values to be omitted")
+			}
+			formatters := logFormat.GetFormatters()
+			if len(formatters) != 1 || formatters[0].Name != "envoy.formatter.cel" {
+				t.Errorf("got formatters %v, want the CEL formatter", formatters)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"istio.io/istio/pkg/util/sets"
+)
+
+// DescriptorSet is a serialized FileDescriptorSet referenced by the gRPC-JSON transcoding of the virtual services.
+type DescriptorSet struct {
+	// Bin is the serialized FileDescriptorSet.
+	Bin []byte
+	// Services are the fully qualified names of the services it describes.
+	Services sets.String
+}
+
+// DescriptorSetStore serves the descriptor sets of the sources referenced by the virtual services, such as
+// configmap://<namespace>/<name>/<key>, oci:// and http(s):// ones, fetching them the first time they are requested.
+type DescriptorSetStore interface {
+	// DescriptorSet returns the descriptor set of the source, or nil while it was not fetched or failed to be.
+	DescriptorSet(source string) *DescriptorSet
+}
+
+// DescriptorSet returns the descriptor set of the source, or nil if it is not available.
+func (ps *PushContext) DescriptorSet(source string) *DescriptorSet {
+	if ps.descriptorSets == nil {
+		return nil
+	}
+	return ps.descriptorSets.DescriptorSet(source)
+}
@@ -0,0 +1,289 @@
+package model
+
+import (
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	envoyExtensionsWasmV3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/util/hash"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// wasmPullSecretNamespaces are the central namespaces whose secrets may be referenced by the WasmPlugins of other
+// namespaces as image pull secrets.
+var wasmPullSecretNamespaces = parseNamespaces(alifeatures.WasmPullSecretNamespaces)
+
+func parseNamespaces(value string) sets.String {
+	namespaces := sets.New[string]()
+	for _, ns := range strings.Split(value, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces.Insert(ns)
+		}
+	}
+	return namespaces
+}
+
+// initialFetchTimeout returns the initial fetch timeout set by the higress.io/wasm-initial-fetch-timeout annotation
+// of the WasmPlugin, or nil if it is not set or invalid.
+func initialFetchTimeout(plugin *config.Config) *durationpb.Duration {
+	value, ok := plugin.Annotations[constants.WasmInitialFetchTimeoutAnnotation]
+	if !ok {
+		return nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		log.Warnf("wasmplugin %v/%v has an invalid %s annotation %q, waiting for its config forever",
+			plugin.Namespace, plugin.Name, constants.WasmInitialFetchTimeoutAnnotation, value)
+		return nil
+	}
+	return durationpb.New(timeout)
+}
+
+// AuthenticationMatch is an authentication state of the requests a WasmPlugin runs for.
+type AuthenticationMatch string
+
+const (
+	// AuthenticationMatchAuthenticated matches the requests of peers authenticated by mTLS, or carrying a JWT bearer
+	// token, which is verified by the authentication filters after the AUTHN phase.
+	AuthenticationMatchAuthenticated AuthenticationMatch = "authenticated"
+	// AuthenticationMatchUnauthenticated matches the requests not matched by AuthenticationMatchAuthenticated.
+	AuthenticationMatchUnauthenticated AuthenticationMatch = "unauthenticated"
+	// AuthenticationMatchPeerAuthenticated matches the requests of peers authenticated by mTLS.
+	AuthenticationMatchPeerAuthenticated AuthenticationMatch = "peer-authenticated"
+	// AuthenticationMatchPeerUnauthenticated matches the requests of peers not authenticated by mTLS.
+	AuthenticationMatchPeerUnauthenticated AuthenticationMatch = "peer-unauthenticated"
+)
+
+// authenticationMatch returns the authentication state set by the higress.io/wasm-match-authentication annotation of
+// the WasmPlugin, or empty if it is not set or invalid. The JWT bearer tokens are stripped once verified, so the
+// states they are part of are only valid in the AUTHN phase, which runs before the authentication filters.
+func authenticationMatch(plugin *config.Config, phase extensions.PluginPhase) AuthenticationMatch {
+	value, ok := plugin.Annotations[constants.WasmMatchAuthenticationAnnotation]
+	if !ok {
+		return ""
+	}
+	switch match := AuthenticationMatch(value); match {
+	case AuthenticationMatchPeerAuthenticated, AuthenticationMatchPeerUnauthenticated:
+		return match
+	case AuthenticationMatchAuthenticated, AuthenticationMatchUnauthenticated:
+		if phase == extensions.PluginPhase_AUTHN {
+			return match
+		}
+		log.Warnf("wasmplugin %v/%v has a %s annotation %q outside of the AUTHN phase, running for all requests",
+			plugin.Namespace, plugin.Name, constants.WasmMatchAuthenticationAnnotation, value)
+		return ""
+	}
+	log.Warnf("wasmplugin %v/%v has an invalid %s annotation %q, running for all requests",
+		plugin.Namespace, plugin.Name, constants.WasmMatchAuthenticationAnnotation, value)
+	return ""
+}
+
+// WasmMatchRule overrides the configuration of a WasmPlugin on some domains or routes of the gateways.
+type WasmMatchRule struct {
+	// Domains are the domains of the virtual hosts the rule applies to, possibly wildcards such as *.example.com.
+	Domains []string
+	// RoutePrefixes are the path prefixes of the routes the rule applies to.
+	RoutePrefixes []string
+	// Configuration is the plugin configuration merged with the config of the rule.
+	Configuration *anypb.Any
+}
+
+type wasmMatchRuleSpec struct {
+	Domain      []string       `json:"domain,omitempty"`
+	RoutePrefix []string       `json:"routePrefix,omitempty"`
+	Config      map[string]any `json:"config,omitempty"`
+}
+
+// wasmMatchRules returns the rules set by the higress.io/wasm-match-rules annotation of the WasmPlugin, with their
+// config merged into the plugin config, or nil if it is not set or invalid. The plugins matching an authentication
+// state are wrapped by a matcher which takes no per-route plugin configuration, so they have no rules.
+func wasmMatchRules(plugin *config.Config, pluginConfig *structpb.Struct, authMatch AuthenticationMatch) []*WasmMatchRule {
+	value, ok := plugin.Annotations[constants.WasmMatchRulesAnnotation]
+	if !ok {
+		return nil
+	}
+	if authMatch != "" {
+		log.Warnf("wasmplugin %v/%v has both %s and %s annotations, ignoring its match rules", plugin.Namespace,
+			plugin.Name, constants.WasmMatchRulesAnnotation, constants.WasmMatchAuthenticationAnnotation)
+		return nil
+	}
+	var specs []wasmMatchRuleSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		log.Warnf("wasmplugin %v/%v has an invalid %s annotation, ignoring its match rules: %v",
+			plugin.Namespace, plugin.Name, constants.WasmMatchRulesAnnotation, err)
+		return nil
+	}
+	rules := make([]*WasmMatchRule, 0, len(specs))
+	for i, spec := range specs {
+		if (len(spec.Domain) == 0) == (len(spec.RoutePrefix) == 0) {
+			log.Warnf("wasmplugin %v/%v match rule %d must have either a domain or a routePrefix, ignoring it",
+				plugin.Namespace, plugin.Name, i)
+			continue
+		}
+		merged := pluginConfig.AsMap()
+		for k, v := range spec.Config {
+			merged[k] = v
+		}
+		cfgJSON, err := json.Marshal(merged)
+		if err != nil {
+			log.Warnf("wasmplugin %v/%v match rule %d has an invalid config, ignoring it: %v",
+				plugin.Namespace, plugin.Name, i, err)
+			continue
+		}
+		rules = append(rules, &WasmMatchRule{
+			Domains:       spec.Domain,
+			RoutePrefixes: spec.RoutePrefix,
+			Configuration: protoconv.MessageToAny(&wrapperspb.StringValue{Value: string(cfgJSON)}),
+		})
+	}
+	return rules
+}
+
+// wasmSharedServiceScheme prefixes the plugin config values referencing a shared service by name.
+const wasmSharedServiceScheme = "shared-service://"
+
+// WasmSharedService is a service called by a WasmPlugin, such as a Redis or a rate limit service, whose cluster is
+// generated on the proxies of the plugin.
+type WasmSharedService struct {
+	// ClusterName is the name of the cluster of the service, which the plugin config references.
+	ClusterName string
+	Host        string
+	Port        uint32
+	// TLS originates TLS to the service, with SNI as the server name, or Host if empty.
+	TLS bool
+	SNI string
+}
+
+type wasmSharedServiceSpec struct {
+	Address string `json:"address"`
+	TLS     bool   `json:"tls,omitempty"`
+	SNI     string `json:"sni,omitempty"`
+}
+
+func wasmSharedServiceClusterName(namespace, name string) string {
+	return "wasm-shared|" + namespace + "|" + name
+}
+
+// wasmSharedServices returns the shared services set by the higress.io/wasm-shared-services annotation of the
+// WasmPlugin keyed by name, or nil if it is not set or invalid. The services with an invalid address are ignored.
+func wasmSharedServices(plugin *config.Config) map[string]*WasmSharedService {
+	value, ok := plugin.Annotations[constants.WasmSharedServicesAnnotation]
+	if !ok {
+		return nil
+	}
+	var specs map[string]wasmSharedServiceSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		log.Warnf("wasmplugin %v/%v has an invalid %s annotation, ignoring its shared services: %v",
+			plugin.Namespace, plugin.Name, constants.WasmSharedServicesAnnotation, err)
+		return nil
+	}
+	services := make(map[string]*WasmSharedService, len(specs))
+	for name, spec := range specs {
+		h, p, err := net.SplitHostPort(spec.Address)
+		var port uint64
+		if err == nil {
+			port, err = strconv.ParseUint(p, 10, 16)
+		}
+		if err != nil || h == "" || port == 0 {
+			log.Warnf("wasmplugin %v/%v shared service %s has an invalid address %q, ignoring it",
+				plugin.Namespace, plugin.Name, name, spec.Address)
+			continue
+		}
+		services[name] = &WasmSharedService{
+			ClusterName: wasmSharedServiceClusterName(plugin.Namespace, name),
+			Host:        h,
+			Port:        uint32(port),
+			TLS:         spec.TLS,
+			SNI:         spec.SNI,
+		}
+	}
+	return services
+}
+
+// resolveSharedServices replaces the string values of the plugin config referencing a shared service by the name of
+// its cluster. The references to undeclared services are left as is.
+func resolveSharedServices(v *structpb.Value, services map[string]*WasmSharedService) {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		if name, ok := strings.CutPrefix(kind.StringValue, wasmSharedServiceScheme); ok {
+			if svc, ok := services[name]; ok {
+				kind.StringValue = svc.ClusterName
+			}
+		}
+	case *structpb.Value_StructValue:
+		for _, field := range kind.StructValue.GetFields() {
+			resolveSharedServices(field, services)
+		}
+	case *structpb.Value_ListValue:
+		for _, value := range kind.ListValue.GetValues() {
+			resolveSharedServices(value, services)
+		}
+	}
+}
+
+// vmResourceVersion returns the resource version set to the VM config of the WasmPlugin. It is a hash of its spec
+// except its pluginConfig, so that the VM config stays the same when only the pluginConfig changes: Envoy then keeps
+// the VM along with its in-memory state and only configures the plugin again, and the module is not pulled again.
+func vmResourceVersion(plugin *config.Config, wasmPlugin *extensions.WasmPlugin) string {
+	if !alifeatures.WasmKeepVMOnConfigChange {
+		return plugin.ResourceVersion
+	}
+	spec := proto.Clone(wasmPlugin).(*extensions.WasmPlugin)
+	spec.PluginConfig = nil
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(spec)
+	if err != nil {
+		log.Warnf("wasmplugin %v/%v failed to marshal to hash its VM config: %v", plugin.Namespace, plugin.Name, err)
+		return plugin.ResourceVersion
+	}
+	h := hash.New()
+	h.Write(b)
+	return h.Sum()
+}
+
+const (
+	// WasmMetricPrefixEnv is the VM environment variable of the prefix the plugin defines its metrics under.
+	WasmMetricPrefixEnv = "HIGRESS_WASM_METRIC_PREFIX"
+	// WasmMaxMetricsEnv is the VM environment variable of the maximum number of metrics the plugin may define.
+	WasmMaxMetricsEnv = "HIGRESS_WASM_MAX_METRICS"
+	// WasmAllowedMetricsEnv is the VM environment variable of the regular expression the names of the metrics the
+	// plugin may define must match.
+	WasmAllowedMetricsEnv = "HIGRESS_WASM_ALLOWED_METRICS"
+)
+
+// setMetricsEnv sets the VM environment variables isolating the metrics of the WasmPlugin under a prefix of its own
+// and limiting the metrics it may define, which are enforced by the plugin SDKs. They are set after the environment
+// variables of the plugin, which can not override them.
+func setMetricsEnv(vm *envoyExtensionsWasmV3.VmConfig, namespace, name string) {
+	envs := vm.GetEnvironmentVariables()
+	if envs == nil {
+		return
+	}
+	if alifeatures.WasmIsolateMetrics {
+		envs.KeyValues[WasmMetricPrefixEnv] = "wasm." + namespace + "." + name + "."
+	}
+	if alifeatures.WasmMaxMetrics > 0 {
+		envs.KeyValues[WasmMaxMetricsEnv] = strconv.Itoa(alifeatures.WasmMaxMetrics)
+	}
+	if allowed := alifeatures.WasmAllowedMetrics; allowed != "" {
+		if _, err := regexp.Compile(allowed); err != nil {
+			log.Warnf("invalid PILOT_WASM_ALLOWED_METRICS %q, allowing all metrics: %v", allowed, err)
+			return
+		}
+		envs.KeyValues[WasmAllowedMetricsEnv] = allowed
+	}
+}
@@ -0,0 +1,334 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+)
+
+func TestInitialFetchTimeout(t *testing.T) {
+	cases := []struct {
+		desc        string
+		annotations map[string]string
+		want        time.Duration
+	}{
+		{
+			desc: "unset",
+		},
+		{
+			desc:        "valid",
+			annotations: map[string]string{constants.WasmInitialFetchTimeoutAnnotation: "10s"},
+			want:        10 * time.Second,
+		},
+		{
+			desc:        "invalid",
+			annotations: map[string]string{constants.WasmInitialFetchTimeoutAnnotation: "ten seconds"},
+		},
+		{
+			desc:        "negative",
+			annotations: map[string]string{constants.WasmInitialFetchTimeoutAnnotation: "-1s"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := convertToWasmPluginWrapper(config.Config{
+				Meta: config.Meta{Name: "plugin", Namespace: "default", Annotations: tc.annotations},
+				Spec: &extensions.WasmPlugin{Url: "file://fake.wasm"},
+			})
+			if out == nil {
+				t.Fatalf("must not get nil")
+			}
+			if got := out.InitialFetchTimeout; (got == nil) != (tc.want == 0) || got.AsDuration() != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToSecretResourceNameCentralNamespace(t *testing.T) {
+	old := wasmPullSecretNamespaces
+	wasmPullSecretNamespaces = parseNamespaces("central, shared")
+	t.Cleanup(func() { wasmPullSecretNamespaces = old })
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"sec", credentials.KubernetesSecretTypeURI + "nm/sec"},
+		{"central/sec", credentials.KubernetesSecretTypeURI + "central/sec"},
+		{credentials.KubernetesSecretTypeURI + "shared/sec", credentials.KubernetesSecretTypeURI + "shared/sec"},
+		// Other namespaces are still resolved in the plugin namespace.
+		{"nm2/sec", credentials.KubernetesSecretTypeURI + "nm/sec"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toSecretResourceName(tt.name, "nm"); got != tt.want {
+				t.Errorf("got secret name %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticationMatch(t *testing.T) {
+	cases := []struct {
+		desc  string
+		value string
+		phase extensions.PluginPhase
+		want  AuthenticationMatch
+	}{
+		{
+			desc:  "peer in any phase",
+			value: "peer-unauthenticated",
+			phase: extensions.PluginPhase_STATS,
+			want:  AuthenticationMatchPeerUnauthenticated,
+		},
+		{
+			desc:  "authn phase",
+			value: "unauthenticated",
+			phase: extensions.PluginPhase_AUTHN,
+			want:  AuthenticationMatchUnauthenticated,
+		},
+		{
+			desc:  "after authn phase",
+			value: "authenticated",
+			phase: extensions.PluginPhase_AUTHZ,
+		},
+		{
+			desc:  "invalid",
+			value: "jwt",
+			phase: extensions.PluginPhase_AUTHN,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := convertToWasmPluginWrapper(config.Config{
+				Meta: config.Meta{
+					Name: "plugin", Namespace: "default",
+					Annotations: map[string]string{constants.WasmMatchAuthenticationAnnotation: tc.value},
+				},
+				Spec: &extensions.WasmPlugin{Url: "file://fake.wasm", Phase: tc.phase},
+			})
+			if out == nil {
+				t.Fatalf("must not get nil")
+			}
+			if out.AuthenticationMatch != tc.want {
+				t.Errorf("got %q, want %q", out.AuthenticationMatch, tc.want)
+			}
+		})
+	}
+}
+
+func TestWasmMatchRules(t *testing.T) {
+	pluginConfig, _ := structpb.NewStruct(map[string]any{"limit": 10, "key": "global"})
+	convert := func(annotations map[string]string) *WasmPluginWrapper {
+		t.Helper()
+		out := convertToWasmPluginWrapper(config.Config{
+			Meta: config.Meta{Name: "plugin", Namespace: "default", Annotations: annotations},
+			Spec: &extensions.WasmPlugin{Url: "file://fake.wasm", PluginConfig: pluginConfig},
+		})
+		if out == nil {
+			t.Fatalf("must not get nil")
+		}
+		return out
+	}
+
+	out := convert(map[string]string{constants.WasmMatchRulesAnnotation: `[
+		{"domain": ["*.example.com"], "config": {"limit": 100}},
+		{"routePrefix": ["/api"], "config": {"key": "api"}},
+		{"domain": ["example.com"], "routePrefix": ["/"]}
+	]`})
+	if len(out.MatchRules) != 2 {
+		t.Fatalf("got %d match rules, want 2", len(out.MatchRules))
+	}
+	expected := []struct {
+		domains  []string
+		prefixes []string
+		config   string
+	}{
+		{[]string{"*.example.com"}, nil, `{"key":"global","limit":100}`},
+		{nil, []string{"/api"}, `{"key":"api","limit":10}`},
+	}
+	for i, want := range expected {
+		got := out.MatchRules[i]
+		value := &wrapperspb.StringValue{}
+		if err := got.Configuration.UnmarshalTo(value); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Domains, want.domains) || !reflect.DeepEqual(got.RoutePrefixes, want.prefixes) ||
+			value.Value != want.config {
+			t.Errorf("got match rule %d %v %v %s, want %v %v %s", i, got.Domains, got.RoutePrefixes, value.Value,
+				want.domains, want.prefixes, want.config)
+		}
+	}
+
+	if out := convert(map[string]string{constants.WasmMatchRulesAnnotation: "not json"}); out.MatchRules != nil {
+		t.Errorf("got match rules %v for an invalid annotation", out.MatchRules)
+	}
+	out = convert(map[string]string{
+		constants.WasmMatchRulesAnnotation:          `[{"domain": ["example.com"]}]`,
+		constants.WasmMatchAuthenticationAnnotation: "peer-authenticated",
+	})
+	if out.MatchRules != nil {
+		t.Errorf("got match rules %v for a plugin matching an authentication state", out.MatchRules)
+	}
+}
+
+func TestWasmSharedServices(t *testing.T) {
+	pluginConfig, _ := structpb.NewStruct(map[string]any{
+		"redis":    "shared-service://redis",
+		"backends": []any{"shared-service://rls", "shared-service://unknown"},
+		"nested":   map[string]any{"cluster": "shared-service://redis"},
+	})
+	out := convertToWasmPluginWrapper(config.Config{
+		Meta: config.Meta{Name: "plugin", Namespace: "default", Annotations: map[string]string{
+			constants.WasmSharedServicesAnnotation: `{
+				"redis": {"address": "redis.example.com:6379"},
+				"rls": {"address": "rls.example.com:443", "tls": true, "sni": "rls"},
+				"invalid": {"address": "rls.example.com"}
+			}`,
+		}},
+		Spec: &extensions.WasmPlugin{Url: "file://fake.wasm", PluginConfig: pluginConfig},
+	})
+	if out == nil {
+		t.Fatalf("must not get nil")
+	}
+	want := map[string]*WasmSharedService{
+		"redis": {ClusterName: "wasm-shared|default|redis", Host: "redis.example.com", Port: 6379},
+		"rls":   {ClusterName: "wasm-shared|default|rls", Host: "rls.example.com", Port: 443, TLS: true, SNI: "rls"},
+	}
+	if !reflect.DeepEqual(out.SharedServices, want) {
+		t.Errorf("got shared services %v, want %v", out.SharedServices, want)
+	}
+	value := &wrapperspb.StringValue{}
+	if err := out.WasmExtensionConfig.GetConfig().GetConfiguration().UnmarshalTo(value); err != nil {
+		t.Fatal(err)
+	}
+	var gotConfig map[string]any
+	if err := json.Unmarshal([]byte(value.Value), &gotConfig); err != nil {
+		t.Fatal(err)
+	}
+	wantConfig := map[string]any{
+		"redis":    "wasm-shared|default|redis",
+		"backends": []any{"wasm-shared|default|rls", "shared-service://unknown"},
+		"nested":   map[string]any{"cluster": "wasm-shared|default|redis"},
+	}
+	if !reflect.DeepEqual(gotConfig, wantConfig) {
+		t.Errorf("got plugin config %v, want %v", gotConfig, wantConfig)
+	}
+	if got := pluginConfig.Fields["redis"].GetStringValue(); got != "shared-service://redis" {
+		t.Errorf("got the plugin config of the informer cache mutated to %q", got)
+	}
+
+	out = convertToWasmPluginWrapper(config.Config{
+		Meta: config.Meta{Name: "plugin", Namespace: "default", Annotations: map[string]string{
+			constants.WasmSharedServicesAnnotation: "not json",
+		}},
+		Spec: &extensions.WasmPlugin{Url: "file://fake.wasm"},
+	})
+	if out.SharedServices != nil {
+		t.Errorf("got shared services %v for an invalid annotation", out.SharedServices)
+	}
+}
+
+func TestVMResourceVersion(t *testing.T) {
+	vmVersion := func(resourceVersion, url string, pluginConfig map[string]any) string {
+		t.Helper()
+		cfg, _ := structpb.NewStruct(pluginConfig)
+		out := convertToWasmPluginWrapper(config.Config{
+			Meta: config.Meta{Name: "plugin", Namespace: "default", ResourceVersion: resourceVersion},
+			Spec: &extensions.WasmPlugin{Url: url, PluginConfig: cfg},
+		})
+		if out == nil {
+			t.Fatalf("must not get nil")
+		}
+		return out.WasmExtensionConfig.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()[WasmResourceVersionEnv]
+	}
+
+	base := vmVersion("1", "oci://ghcr.io/istio/fake-wasm:latest", map[string]any{"limit": 10})
+	if got := vmVersion("2", "oci://ghcr.io/istio/fake-wasm:latest", map[string]any{"limit": 100}); got != base {
+		t.Errorf("got VM resource version %q changed with the plugin config, want %q", got, base)
+	}
+	if got := vmVersion("2", "oci://ghcr.io/istio/other-wasm:latest", map[string]any{"limit": 10}); got == base {
+		t.Errorf("got VM resource version %q unchanged with the module", got)
+	}
+
+	test.SetForTest(t, &alifeatures.WasmKeepVMOnConfigChange, false)
+	if got := vmVersion("2", "oci://ghcr.io/istio/fake-wasm:latest", map[string]any{"limit": 10}); got != "2" {
+		t.Errorf("got VM resource version %q, want the resource version of the plugin", got)
+	}
+}
+
+func TestSetMetricsEnv(t *testing.T) {
+	cases := []struct {
+		desc     string
+		isolate  bool
+		max      int
+		allowed  string
+		vmEnv    map[string]string
+		expected map[string]string
+	}{
+		{
+			desc:     "disabled",
+			expected: map[string]string{"KEY": "value"},
+		},
+		{
+			desc:    "enabled",
+			isolate: true,
+			max:     10,
+			allowed: "^requests_.*",
+			expected: map[string]string{
+				"KEY":                 "value",
+				WasmMetricPrefixEnv:   "wasm.default.plugin.",
+				WasmMaxMetricsEnv:     "10",
+				WasmAllowedMetricsEnv: "^requests_.*",
+			},
+		},
+		{
+			desc:     "invalid allowed metrics",
+			allowed:  "(",
+			expected: map[string]string{"KEY": "value"},
+		},
+		{
+			desc:    "not overridden by the plugin",
+			isolate: true,
+			vmEnv:   map[string]string{WasmMetricPrefixEnv: ""},
+			expected: map[string]string{
+				"KEY":               "value",
+				WasmMetricPrefixEnv: "wasm.default.plugin.",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.WasmIsolateMetrics, tc.isolate)
+			test.SetForTest(t, &alifeatures.WasmMaxMetrics, tc.max)
+			test.SetForTest(t, &alifeatures.WasmAllowedMetrics, tc.allowed)
+			env := []*extensions.EnvVar{{Name: "KEY", Value: "value"}}
+			for k, v := range tc.vmEnv {
+				env = append(env, &extensions.EnvVar{Name: k, Value: v})
+			}
+			out := convertToWasmPluginWrapper(config.Config{
+				Meta: config.Meta{Name: "plugin", Namespace: "default"},
+				Spec: &extensions.WasmPlugin{Url: "file://fake.wasm", VmConfig: &extensions.VmConfig{Env: env}},
+			})
+			if out == nil {
+				t.Fatalf("must not get nil")
+			}
+			got := out.WasmExtensionConfig.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()
+			delete(got, WasmResourceVersionEnv)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("got VM environment variables %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
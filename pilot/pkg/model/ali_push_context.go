@@ -2,7 +2,10 @@ package model
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,9 +18,17 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	. "istio.io/istio/pilot/pkg/config/alikube/ingress/log"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/ali/config/clientcert"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/ali/config/ipaccess"
+	"istio.io/istio/pkg/ali/config/oidc"
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
+	"istio.io/istio/pkg/ali/config/sniforwardproxy"
 	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/gateway"
+	"istio.io/istio/pkg/util/sets"
 )
 
 type DestinationType string
@@ -314,6 +325,187 @@ func (ps *PushContext) GetGatewayByName(name string) *config.Config {
 	return nil
 }
 
+// FallbackCredentialName returns the credential of the certificate served by the gateway to the clients whose SNI
+// matches none of its servers, as set by its annotation or else globally. It is empty if there is none.
+func (ps *PushContext) FallbackCredentialName(gatewayName string) string {
+	if gw := ps.GetGatewayByName(gatewayName); gw != nil {
+		if name, ok := gw.Annotations[constants.FallbackCredentialNameAnnotation]; ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return alifeatures.GatewayFallbackCredentialName
+}
+
+// GatewayPatch returns the patch of the listeners and routes of the servers of a gateway set by its annotation, or nil
+// if there is none or it is invalid.
+func (ps *PushContext) GatewayPatch(gatewayName string) *gatewaypatch.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.GatewayPatchAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := gatewaypatch.Parse(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring gateway patch of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// SNIForwardProxy returns the dynamic forward proxy of the passthrough servers of a gateway set by its annotation, or
+// nil if there is none or it is invalid.
+func (ps *PushContext) SNIForwardProxy(gatewayName string) *sniforwardproxy.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.SNIForwardProxyAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := sniforwardproxy.Parse(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring sni forward proxy of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// ProxyProtocol returns the PROXY protocol of the listeners of the servers of a gateway set by its annotation, or nil
+// if there is none or it is invalid.
+func (ps *PushContext) ProxyProtocol(gatewayName string) *proxyprotocol.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.ProxyProtocolAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := proxyprotocol.Parse(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring proxy protocol of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// IPAccess returns the IP access control of the listeners of the servers of a gateway set by its annotation, or nil
+// if there is none or it is invalid.
+func (ps *PushContext) IPAccess(gatewayName string) *ipaccess.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.IPAccessAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := ipaccess.ParseGateway(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring ip access of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// ClientCert returns how the client certificates of the servers of a gateway are passed to the upstreams as set by
+// its annotation, or nil if there is none or it is invalid.
+func (ps *PushContext) ClientCert(gatewayName string) *clientcert.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.ClientCertAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := clientcert.Parse(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring client cert of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// OIDC returns the OIDC login to the hosts of the servers of a gateway set by its annotation, or nil if there is none
+// or it is invalid.
+func (ps *PushContext) OIDC(gatewayName string) *oidc.Spec {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return nil
+	}
+	value, ok := gw.Annotations[constants.OIDCAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := oidc.Parse(value)
+	if err != nil {
+		IngressLog.Warnf("ignoring oidc of gateway %s: %v", gatewayName, err)
+		return nil
+	}
+	return spec
+}
+
+// gatewayReferencedHosts are the hosts referenced by a gateway proxy, computed for its merged gateway.
+type gatewayReferencedHosts struct {
+	gateway *MergedGateway
+	hosts   sets.String
+}
+
+// addHostsFromGatewayFilters adds the hosts of the services called by the filters of the gateway proxy: the shared
+// services of its WasmPlugins, the global rate limit service, the external processor, and the token endpoints of the
+// OIDC logins of its gateways.
+func (ps *PushContext) addHostsFromGatewayFilters(proxy *Proxy, gateways sets.String, hosts sets.String) {
+	for _, plugins := range ps.WasmPlugins(proxy) {
+		for _, plugin := range plugins {
+			for _, svc := range plugin.SharedServices {
+				hosts.Insert(svc.Host)
+			}
+		}
+	}
+	for _, address := range []string{alifeatures.RateLimitServiceAddress, alifeatures.ExtProcServiceAddress} {
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			hosts.Insert(host)
+		}
+	}
+	for gw := range gateways {
+		if spec := ps.OIDC(gw); spec != nil {
+			if u, err := url.Parse(spec.TokenEndpoint); err == nil {
+				hosts.Insert(u.Hostname())
+			}
+		}
+	}
+}
+
+// HasWasmPluginsAnnotation returns true if a virtual service or a gateway selects the WasmPlugins applying to its
+// routes, which then depend on the WasmPlugins.
+func (ps *PushContext) HasWasmPluginsAnnotation() bool {
+	return ps.virtualServiceIndex.wasmPluginsAnnotated || ps.gatewayIndex.wasmPluginsAnnotated
+}
+
+// MergeWasmPluginsAnnotation merges the WasmPlugins selected by a config into the annotations of a virtual service
+// converted from several configs, such as the ingresses of a host. The selection applies to all the routes of the
+// virtual service, so a plugin selected by any of the configs is selected, and a config without the annotation keeps
+// all the plugins enabled.
+func MergeWasmPluginsAnnotation(annotations, other map[string]string) {
+	selected, ok := annotations[constants.WasmPluginsAnnotation]
+	if !ok {
+		return
+	}
+	otherSelected, ok := other[constants.WasmPluginsAnnotation]
+	if !ok {
+		delete(annotations, constants.WasmPluginsAnnotation)
+		return
+	}
+	if otherSelected != "" {
+		annotations[constants.WasmPluginsAnnotation] = selected + "," + otherSelected
+	}
+}
+
 func (ps *PushContext) GetHTTPFiltersFromEnvoyFilter(node *Proxy) []*httpConn.HttpFilter {
 	var out []*httpConn.HttpFilter
 	envoyFilterWrapper := ps.EnvoyFilters(node)
@@ -364,3 +556,34 @@ func enableH3(push *PushContext) bool {
 
 	return push.Mesh.MseIngressGlobalConfig.EnableH3
 }
+
+// http3Enabled returns true if a server of a gateway is mirrored by an HTTP/3 server listening on QUIC, as set by the
+// HTTP/3 annotation of the gateway, or else mesh wide.
+func http3Enabled(push *PushContext, gatewayConfig config.Config, server *networking.Server) bool {
+	switch gatewayConfig.Annotations[constants.HTTP3Annotation] {
+	case "true":
+		return gateway.IsHTTP3CapableServer(server)
+	case "false":
+		return false
+	}
+	return enableH3(push) && gateway.IsEligibleForHTTP3Upgrade(server)
+}
+
+// HTTP3AltSvcMaxAge returns the max age in seconds of the alt-svc header advertising HTTP/3 on the routes of a
+// gateway, or zero for the default.
+func (ps *PushContext) HTTP3AltSvcMaxAge(gatewayName string) uint32 {
+	gw := ps.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return 0
+	}
+	value, ok := gw.Annotations[constants.HTTP3AltSvcMaxAgeAnnotation]
+	if !ok {
+		return 0
+	}
+	maxAge, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || maxAge == 0 {
+		IngressLog.Warnf("ignoring invalid alt-svc max age %q of gateway %s", value, gatewayName)
+		return 0
+	}
+	return uint32(maxAge)
+}
@@ -1390,3 +1390,114 @@ func TestGetExtensionConfigsFromEnvoyFilter(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeWasmPluginsAnnotation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		other       map[string]string
+		expected    map[string]string
+	}{
+		{
+			name:        "not selecting",
+			annotations: map[string]string{},
+			other:       map[string]string{constants.WasmPluginsAnnotation: "a"},
+			expected:    map[string]string{},
+		},
+		{
+			name:        "other not selecting",
+			annotations: map[string]string{constants.WasmPluginsAnnotation: "a"},
+			other:       map[string]string{},
+			expected:    map[string]string{},
+		},
+		{
+			name:        "both selecting",
+			annotations: map[string]string{constants.WasmPluginsAnnotation: "a"},
+			other:       map[string]string{constants.WasmPluginsAnnotation: "ns/b"},
+			expected:    map[string]string{constants.WasmPluginsAnnotation: "a,ns/b"},
+		},
+		{
+			name:        "other selecting none",
+			annotations: map[string]string{constants.WasmPluginsAnnotation: "a"},
+			other:       map[string]string{constants.WasmPluginsAnnotation: ""},
+			expected:    map[string]string{constants.WasmPluginsAnnotation: "a"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			MergeWasmPluginsAnnotation(tc.annotations, tc.other)
+			if !reflect.DeepEqual(tc.annotations, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, tc.annotations)
+			}
+		})
+	}
+}
+
+func TestMergeGatewaysHTTP3Annotation(t *testing.T) {
+	https := makeConfig("foo", "not-default", "*.example.com", "https", "HTTPS", 443, "ingressgateway", "",
+		networking.ServerTLSSettings_SIMPLE)
+	http := makeConfig("bar", "not-default", "*.example.com", "http", "HTTP", 80, "ingressgateway", "",
+		networking.ServerTLSSettings_SIMPLE)
+	passthrough := makeConfig("baz", "not-default", "foo.example.com", "tls", "TLS", 8443, "ingressgateway", "",
+		networking.ServerTLSSettings_PASSTHROUGH)
+
+	testCases := []struct {
+		name     string
+		http3    string
+		wantQUIC int
+	}{
+		{name: "not annotated", wantQUIC: 0},
+		{name: "enabled", http3: "true", wantQUIC: 1},
+		{name: "disabled", http3: "false", wantQUIC: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			instances := []gatewayWithInstances{}
+			for _, c := range []config.Config{https, http, passthrough} {
+				c = c.DeepCopy()
+				if tc.http3 != "" {
+					c.Annotations = map[string]string{constants.HTTP3Annotation: tc.http3}
+				}
+				instances = append(instances, gatewayWithInstances{c, true, nil})
+			}
+			mgw := MergeGateways(instances, &Proxy{}, nil)
+			if len(mgw.MergedQUICTransportServers) != tc.wantQUIC {
+				t.Fatalf("expected %d QUIC servers, got %v", tc.wantQUIC, mgw.MergedQUICTransportServers)
+			}
+			if len(mgw.HTTP3AdvertisingRoutes) != tc.wantQUIC {
+				t.Fatalf("expected %d HTTP/3 advertising routes, got %v", tc.wantQUIC, mgw.HTTP3AdvertisingRoutes)
+			}
+		})
+	}
+}
+
+func TestHTTP3AltSvcMaxAge(t *testing.T) {
+	gateway := func(maxAge string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.Gateway,
+				Name:             "gateway",
+				Namespace:        "default",
+				Annotations:      map[string]string{constants.HTTP3AltSvcMaxAgeAnnotation: maxAge},
+			},
+			Spec: &networking.Gateway{},
+		}
+	}
+	testCases := []struct {
+		maxAge string
+		want   uint32
+	}{
+		{maxAge: "300", want: 300},
+		{maxAge: "0", want: 0},
+		{maxAge: "5m", want: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.maxAge, func(t *testing.T) {
+			ps := NewPushContext()
+			ps.gatewayIndex.all = []config.Config{gateway(tc.maxAge)}
+			if got := ps.HTTP3AltSvcMaxAge("default/gateway"); got != tc.want {
+				t.Fatalf("expected max age %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
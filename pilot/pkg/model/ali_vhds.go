@@ -0,0 +1,12 @@
+package model
+
+import (
+	alifeatures "istio.io/istio/pkg/ali/features"
+)
+
+// VHDSEnabled returns true if the route configurations of the gateway are sent without their virtual hosts, which
+// it requests on demand through VHDS. Envoy only serves VHDS over delta xDS, so the gateways connected with the state
+// of the world protocol get their virtual hosts with the route configurations.
+func (node *Proxy) VHDSEnabled() bool {
+	return alifeatures.EnableVHDS && !alifeatures.EnableScopedRDS && node.Type == Router && node.DeltaXds
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/cache"
+)
+
+// xdsCacheStore holds the entries of the XDS cache. It is the subset of simplelru.LRUCache used by
+// lruCache, so the entries can be displaced by another policy.
+type xdsCacheStore[K comparable] interface {
+	Add(key K, value cacheValue) bool
+	Get(key K) (cacheValue, bool)
+	Remove(key K) bool
+	Len() int
+	Keys() []K
+}
+
+// xdsCacheEvictionPolicy is the policy displacing the entries of the XDS cache when it is full.
+var xdsCacheEvictionPolicy = func() cache.EvictionPolicy {
+	policy, err := cache.ParseEvictionPolicy(alifeatures.XDSCacheEvictionPolicy)
+	if err != nil {
+		log.Warnf("invalid PILOT_XDS_CACHE_EVICTION_POLICY, using lru: %v", err)
+	}
+	return policy
+}()
+
+// tinyLFUStore displaces the entries of the XDS cache with the W-TinyLFU policy, so the entries shared by
+// many proxies are kept through the pushes generating many entries used by a single proxy.
+type tinyLFUStore[K comparable] struct {
+	cache keyedCache
+}
+
+// keyedCache is the W-TinyLFU cache of pkg/cache, which also tells its entries.
+type keyedCache interface {
+	cache.ExpiringCache
+	Len() int
+	Keys() []any
+}
+
+var _ xdsCacheStore[uint64] = &tinyLFUStore[uint64]{}
+
+func newTinyLFUStore[K comparable](size int, evictCallback simplelru.EvictCallback[K, cacheValue]) xdsCacheStore[K] {
+	// Entries never expire, they are only displaced or cleared.
+	c := cache.NewTinyLFUWithListener(0, 0, int32(size), func(key, value any, _ cache.EvictionReason) {
+		evictCallback(key.(K), value.(cacheValue))
+	})
+	return &tinyLFUStore[K]{cache: c.(keyedCache)}
+}
+
+func (s *tinyLFUStore[K]) Add(key K, value cacheValue) bool {
+	s.cache.Set(key, value)
+	return false
+}
+
+func (s *tinyLFUStore[K]) Get(key K) (cacheValue, bool) {
+	value, ok := s.cache.Get(key)
+	if !ok {
+		return cacheValue{}, false
+	}
+	return value.(cacheValue), true
+}
+
+func (s *tinyLFUStore[K]) Remove(key K) bool {
+	n := s.cache.Len()
+	s.cache.Remove(key)
+	return s.cache.Len() < n
+}
+
+func (s *tinyLFUStore[K]) Len() int {
+	return s.cache.Len()
+}
+
+func (s *tinyLFUStore[K]) Keys() []K {
+	keys := s.cache.Keys()
+	res := make([]K, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, key.(K))
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/cache"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestTinyLFUXdsCache(t *testing.T) {
+	test.SetForTest(t, &features.XDSCacheMaxSize, 100)
+	test.SetForTest(t, &xdsCacheEvictionPolicy, cache.TinyLFUPolicy)
+	res := &discovery.Resource{Name: "test"}
+	req := &PushRequest{Start: time.Time{}.Add(time.Duration(1))}
+	newEntry := func(key string) entry {
+		return entry{
+			key:              key,
+			dependentConfigs: []ConfigHash{ConfigKey{Kind: kind.Service, Name: key, Namespace: "namespace"}.HashCode()},
+		}
+	}
+
+	c := newTypedXdsCache[uint64]()
+	store := c.(*lruCache[uint64])
+	if _, ok := store.store.(*tinyLFUStore[uint64]); !ok {
+		t.Fatalf("got store %T, want the W-TinyLFU store", store.store)
+	}
+	hot := newEntry("hot")
+	c.Add(hot.Key(), hot, req, res)
+	// The hot entry is used by many proxies once it left the admission window.
+	for i := 0; i < 10; i++ {
+		e := newEntry(fmt.Sprint("warm", i))
+		c.Add(e.Key(), e, req, res)
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, c.Get(hot.Key()), res)
+	}
+
+	// A scan over many entries used once does not displace the hot entry.
+	for i := 0; i < 1000; i++ {
+		e := newEntry(fmt.Sprint(i))
+		c.Add(e.Key(), e, req, res)
+	}
+	c.Flush()
+	assert.Equal(t, c.Get(hot.Key()), res)
+	assert.Equal(t, store.store.Len() <= 100, true)
+	// The indexes of the displaced entries are cleared.
+	assert.Equal(t, store.indexLength(), store.store.Len())
+
+	c.Clear(map[ConfigKey]struct{}{{Kind: kind.Service, Name: "hot", Namespace: "namespace"}: {}})
+	c.Flush()
+	if c.Get(hot.Key()) != nil {
+		t.Fatalf("expected the cleared entry to be removed")
+	}
+	assert.Equal(t, len(c.Keys()), store.store.Len())
+}
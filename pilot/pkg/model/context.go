@@ -111,6 +111,14 @@ type Environment struct {
 
 	CredentialsController credentials.MulticlusterController
 
+	// Added by ingress
+	// ExternalCredentialsControllers serve the credentials stored outside of Kubernetes, such as file:// or
+	// aws-sm:// ones, keyed by their secret type.
+	ExternalCredentialsControllers map[string]credentials.Controller
+	// DescriptorSets serves the proto descriptor sets of the gRPC-JSON transcoding of the virtual services.
+	DescriptorSets DescriptorSetStore
+	// End added by ingress
+
 	GatewayAPIController GatewayController
 
 	// EndpointShards for a service. This is a global (per-server) list, built from
@@ -222,6 +230,34 @@ func (e *Environment) SetLedger(l ledger.Ledger) {
 	e.ledger = l
 }
 
+// Added by ingress
+
+// WithConfigStore returns a copy of the environment reading configs from the given store, sharing everything
+// else with e. The copy has no XDS cache nor Gateway API controller, so the push contexts it initializes do not
+// change any state of e; it is meant for push contexts which are never used for pushes.
+func (e *Environment) WithConfigStore(store ConfigStore) *Environment {
+	return &Environment{
+		ServiceDiscovery:      e.ServiceDiscovery,
+		ConfigStore:           store,
+		Watcher:               e.Watcher,
+		NetworksWatcher:       e.NetworksWatcher,
+		NetworkManager:        e.NetworkManager,
+		DomainSuffix:          e.DomainSuffix,
+		ledger:                e.ledger,
+		TrustBundle:           e.TrustBundle,
+		clusterLocalServices:  e.clusterLocalServices,
+		CredentialsController: e.CredentialsController,
+		EndpointIndex:         e.EndpointIndex,
+		Cache:                 DisabledCache{},
+		IngressStore:          e.IngressStore,
+
+		ExternalCredentialsControllers: e.ExternalCredentialsControllers,
+		DescriptorSets:                 e.DescriptorSets,
+	}
+}
+
+// End added by ingress
+
 func (e *Environment) GetProxyConfigOrDefault(ns string, labels, annotations map[string]string, meshConfig *meshconfig.MeshConfig) *meshconfig.ProxyConfig {
 	push := e.PushContext()
 	if push != nil && push.ProxyConfigs != nil {
@@ -389,6 +425,11 @@ type Proxy struct {
 	// Added by Higress
 	CachedListeners []*listener.Listener
 	// End added by Higress
+
+	// Added by ingress
+	// DeltaXds is set if the proxy is connected with the delta xDS protocol.
+	DeltaXds bool
+	// End added by ingress
 }
 
 // WatchedResource tracks an active DiscoveryRequest subscription.
@@ -424,6 +465,24 @@ type WatchedResource struct {
 	// LastResources tracks the contents of the last push.
 	// This field is extremely expensive to maintain and is typically disabled
 	LastResources Resources
+
+	// Added by Ingress
+	// VersionSent is the version of the last sent response, and SentAt the time it was sent.
+	VersionSent string
+	SentAt      time.Time
+
+	// VersionAcked is the version of the last ACKed response, and AckedAt the time it was ACKed.
+	VersionAcked string
+	AckedAt      time.Time
+
+	// PendingResources are the names of the resources of the last sent response, until it is ACKed.
+	PendingResources []string
+
+	// OrphanedResources are the names of the resources subscribed to which are no longer generated, such as the
+	// extension configs of deleted WasmPlugins. They are kept apart from ResourceNames, to be restored once generated
+	// again.
+	OrphanedResources []string
+	// End added by Ingress
 }
 
 var istioVersionRegexp = regexp.MustCompile(`^([1-9]+)\.([0-9]+)(\.([0-9]+))?`)
@@ -723,6 +782,13 @@ type NodeMetadata struct {
 	// The istiod address when running ASM Managed Control Plane.
 	CloudrunAddr string `json:"CLOUDRUN_ADDR,omitempty"`
 
+	// Added by ingress
+	// WildcardSDS, if set, makes a wildcard delta SDS subscription receive all the secrets referenced by the
+	// gateways of the proxy, rather than only the ones it explicitly subscribes to. It is typically set for a
+	// class of proxies through the ISTIO_META_WILDCARD_SDS proxy metadata of the mesh config.
+	WildcardSDS StringBool `json:"WILDCARD_SDS,omitempty"`
+	// End added by ingress
+
 	// Contains a copy of the raw metadata. This is needed to lookup arbitrary values.
 	// If a value is known ahead of time it should be added to the struct rather than reading from here,
 	Raw map[string]any `json:"-"`
@@ -11,8 +11,55 @@ import (
 const (
 	KubernetesIngressSecretType    = "kubernetes-ingress"
 	KubernetesIngressSecretTypeURI = KubernetesIngressSecretType + "://"
+	// FileSecretType is the name of a SDS secret read from files, such as those mounted by a secret manager CSI
+	// driver. Secrets here take the form file://path, relative to the credential directory of pilot. They have no
+	// namespace.
+	FileSecretType    = "file"
+	FileSecretTypeURI = FileSecretType + "://"
+	// AWSSecretsManagerSecretType is the name of a SDS secret read from AWS Secrets Manager. Secrets here take the
+	// form aws-sm://arn, where arn is the ARN of the secret, naming its region.
+	AWSSecretsManagerSecretType    = "aws-sm"
+	AWSSecretsManagerSecretTypeURI = AWSSecretsManagerSecretType + "://"
+	// AlibabaKMSSecretType is the name of a SDS secret read from the secrets manager of Alibaba Cloud KMS. Secrets
+	// here take the form alikms://secret-name, read from the region configured for pilot.
+	AlibabaKMSSecretType    = "alikms"
+	AlibabaKMSSecretTypeURI = AlibabaKMSSecretType + "://"
+	// GenericSecretKeySeparator separates the name of a Kubernetes secret from the key of its data served as a
+	// generic secret, such as kubernetes://oidc-client#client-secret.
+	GenericSecretKeySeparator = "#"
 )
 
+// ToGenericSecretResourceName returns the resource name of the data of a key of a Kubernetes secret served as a
+// generic secret.
+func ToGenericSecretResourceName(namespace, name, key string) string {
+	return fmt.Sprintf("%s%s/%s%s%s", KubernetesSecretTypeURI, namespace, name, GenericSecretKeySeparator, key)
+}
+
+// externalSecretTypes are the types of the secrets stored outside of Kubernetes. They have no namespace.
+var externalSecretTypes = []string{FileSecretType, AWSSecretsManagerSecretType, AlibabaKMSSecretType}
+
+// IsExternalSecretType returns true if the secrets of the type are stored outside of Kubernetes, and so have no
+// namespace nor owner.
+func IsExternalSecretType(resourceType string) bool {
+	for _, t := range externalSecretTypes {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// externalSecretType returns the type of the resource name, if it is the one of a secret stored outside of
+// Kubernetes.
+func externalSecretType(resourceName string) (string, bool) {
+	for _, t := range externalSecretTypes {
+		if strings.HasPrefix(resourceName, t+"://") {
+			return t, true
+		}
+	}
+	return "", false
+}
+
 func ToKubernetesIngressResourceBasedFullName(fullName string) string {
 	return fmt.Sprintf("%s://%s", KubernetesIngressSecretType, fullName)
 }
@@ -46,3 +93,34 @@ func createSecretResourceForIngress(resourceName string) (SecretResource, error)
 	}
 	return SecretResource{ResourceType: KubernetesIngressSecretType, Name: name, Namespace: namespace, ResourceName: resourceName, Cluster: cluster.ID(clusterId)}, nil
 }
+
+// createClusterSecretResource creates the resource of a kubernetes://cluster-id/secret-namespace/secret-name
+// reference, whose secret is read from the cluster, such as a remote cluster holding the certificates served by the
+// gateways of the config cluster. The proxy must be authorized to read the secrets of its namespace in the cluster.
+func createClusterSecretResource(resourceName string, split []string) (SecretResource, error) {
+	clusterID, namespace, name := split[0], split[1], split[2]
+	if len(clusterID) == 0 {
+		return SecretResource{}, fmt.Errorf("invalid resource name %q. Expected clusterId", resourceName)
+	}
+	if len(namespace) == 0 {
+		return SecretResource{}, fmt.Errorf("invalid resource name %q. Expected namespace", resourceName)
+	}
+	if len(name) == 0 {
+		return SecretResource{}, fmt.Errorf("invalid resource name %q. Expected name", resourceName)
+	}
+	return SecretResource{
+		ResourceType: KubernetesSecretType,
+		Name:         name,
+		Namespace:    namespace,
+		ResourceName: resourceName,
+		Cluster:      cluster.ID(clusterID),
+	}, nil
+}
+
+func createExternalSecretResource(resourceType, resourceName string, configCluster cluster.ID) (SecretResource, error) {
+	name := strings.TrimPrefix(resourceName, resourceType+"://")
+	if len(name) == 0 {
+		return SecretResource{}, fmt.Errorf("invalid resource name %q. Expected name", resourceName)
+	}
+	return SecretResource{ResourceType: resourceType, Name: name, ResourceName: resourceName, Cluster: configCluster}, nil
+}
@@ -50,9 +50,19 @@ type SecretResource struct {
 	ResourceName string
 	// Cluster is the cluster the secret should be fetched from.
 	Cluster cluster.ID
+	// Added by ingress
+	// DataKey is the key of the data of the secret served as a generic secret, such as the client secret of OAuth2,
+	// instead of a certificate.
+	DataKey string
+	// End added by ingress
 }
 
 func (sr SecretResource) Key() string {
+	// Added by ingress
+	if sr.DataKey != "" {
+		return sr.ResourceType + "/" + sr.Name + "/" + sr.Namespace + "/" + string(sr.Cluster) + "/" + sr.DataKey
+	}
+	// End added by ingress
 	return sr.ResourceType + "/" + sr.Name + "/" + sr.Namespace + "/" + string(sr.Cluster)
 }
 
@@ -77,6 +87,11 @@ func ToResourceName(name string) string {
 		strings.HasPrefix(name, KubernetesIngressSecretTypeURI) {
 		return name
 	}
+	// Added by ingress
+	if _, ok := externalSecretType(name); ok {
+		return name
+	}
+	// End added by ingress
 	// Otherwise, to kubernetes://
 	return KubernetesSecretTypeURI + name
 }
@@ -88,17 +103,37 @@ func ParseResourceName(resourceName string, proxyNamespace string, proxyCluster
 		// Valid formats:
 		// * kubernetes://secret-name
 		// * kubernetes://secret-namespace/secret-name
+		// * kubernetes://cluster-id/secret-namespace/secret-name
 		// If namespace is not set, we will fetch from the namespace of the proxy. The secret will be read from
 		// the cluster the proxy resides in. This mirrors the legacy behavior mounting a secret as a file
 		res := strings.TrimPrefix(resourceName, KubernetesSecretTypeURI)
+		// Added by ingress
+		// * kubernetes://secret-name#key, serving the data of the key as a generic secret
+		res, dataKey, generic := strings.Cut(res, GenericSecretKeySeparator)
+		if generic && dataKey == "" {
+			return SecretResource{}, fmt.Errorf("invalid resource name %q. Expected key", resourceName)
+		}
+		// End added by ingress
 		split := strings.Split(res, sep)
+		// Added by ingress
+		if len(split) == 3 {
+			sr, err := createClusterSecretResource(resourceName, split)
+			sr.DataKey = dataKey
+			return sr, err
+		}
+		// End added by ingress
 		namespace := proxyNamespace
 		name := split[0]
 		if len(split) > 1 {
 			namespace = split[0]
 			name = split[1]
 		}
-		return SecretResource{ResourceType: KubernetesSecretType, Name: name, Namespace: namespace, ResourceName: resourceName, Cluster: proxyCluster}, nil
+		// Modified by ingress
+		return SecretResource{
+			ResourceType: KubernetesSecretType, Name: name, Namespace: namespace, ResourceName: resourceName, Cluster: proxyCluster,
+			DataKey: dataKey,
+		}, nil
+		// End modified by ingress
 	} else if strings.HasPrefix(resourceName, kubernetesGatewaySecretTypeURI) {
 		// Valid formats:
 		// * kubernetes-gateway://secret-namespace/secret-name
@@ -119,6 +154,8 @@ func ParseResourceName(resourceName string, proxyNamespace string, proxyCluster
 		return SecretResource{ResourceType: KubernetesGatewaySecretType, Name: name, Namespace: namespace, ResourceName: resourceName, Cluster: configCluster}, nil
 	} else if strings.HasPrefix(resourceName, KubernetesIngressSecretTypeURI) {
 		return createSecretResourceForIngress(resourceName)
+	} else if resourceType, ok := externalSecretType(resourceName); ok {
+		return createExternalSecretResource(resourceType, resourceName, configCluster)
 	}
 	return SecretResource{}, fmt.Errorf("unknown resource type: %v", resourceName)
 }
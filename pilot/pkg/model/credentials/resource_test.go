@@ -50,6 +50,45 @@ func TestParseResourceName(t *testing.T) {
 				Cluster:      "cluster",
 			},
 		},
+		// Added by ingress
+		{
+			name:             "generic secret",
+			resource:         "kubernetes://namespace/oidc#client-secret",
+			defaultNamespace: "default",
+			expected: SecretResource{
+				ResourceType: KubernetesSecretType,
+				Name:         "oidc",
+				Namespace:    "namespace",
+				ResourceName: "kubernetes://namespace/oidc#client-secret",
+				Cluster:      "cluster",
+				DataKey:      "client-secret",
+			},
+		},
+		{
+			name:             "generic secret without key",
+			resource:         "kubernetes://namespace/oidc#",
+			defaultNamespace: "default",
+			err:              true,
+		},
+		// End added by ingress
+		{
+			name:             "with cluster",
+			resource:         "kubernetes://remote/namespace/cert",
+			defaultNamespace: "default",
+			expected: SecretResource{
+				ResourceType: KubernetesSecretType,
+				Name:         "cert",
+				Namespace:    "namespace",
+				ResourceName: "kubernetes://remote/namespace/cert",
+				Cluster:      "remote",
+			},
+		},
+		{
+			name:             "with empty cluster",
+			resource:         "kubernetes:///namespace/cert",
+			defaultNamespace: "default",
+			err:              true,
+		},
 		{
 			name:             "kubernetes-gateway",
 			resource:         "kubernetes-gateway://namespace/cert",
@@ -80,6 +119,45 @@ func TestParseResourceName(t *testing.T) {
 			defaultNamespace: "default",
 			err:              true,
 		},
+		{
+			name:             "file",
+			resource:         "file://gateway/cert",
+			defaultNamespace: "default",
+			expected: SecretResource{
+				ResourceType: FileSecretType,
+				Name:         "gateway/cert",
+				ResourceName: "file://gateway/cert",
+				Cluster:      "config",
+			},
+		},
+		{
+			name:             "aws secrets manager",
+			resource:         "aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:gateway/cert-AbCdEf",
+			defaultNamespace: "default",
+			expected: SecretResource{
+				ResourceType: AWSSecretsManagerSecretType,
+				Name:         "arn:aws:secretsmanager:us-east-1:123456789012:secret:gateway/cert-AbCdEf",
+				ResourceName: "aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:gateway/cert-AbCdEf",
+				Cluster:      "config",
+			},
+		},
+		{
+			name:             "alibaba kms",
+			resource:         "alikms://gateway-cert",
+			defaultNamespace: "default",
+			expected: SecretResource{
+				ResourceType: AlibabaKMSSecretType,
+				Name:         "gateway-cert",
+				ResourceName: "alikms://gateway-cert",
+				Cluster:      "config",
+			},
+		},
+		{
+			name:             "alibaba kms without name",
+			resource:         "alikms://",
+			defaultNamespace: "default",
+			err:              true,
+		},
 		{
 			name:             "plain",
 			resource:         "cert",
@@ -113,6 +191,9 @@ func TestToResourceName(t *testing.T) {
 	}{
 		{"foo", "kubernetes://foo"},
 		{"kubernetes://bar", "kubernetes://bar"},
+		{"file://bar", "file://bar"},
+		{"aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:bar", "aws-sm://arn:aws:secretsmanager:us-east-1:123456789012:secret:bar"},
+		{"alikms://bar", "alikms://bar"},
 		{"kubernetes-gateway://bar", "kubernetes-gateway://bar"},
 		{"builtin://", "default"},
 		{"builtin://extra", "default"},
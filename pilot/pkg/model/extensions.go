@@ -24,6 +24,7 @@ import (
 	envoyExtensionsWasmV3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	extensions "istio.io/api/extensions/v1alpha1"
@@ -72,6 +73,17 @@ type WasmPluginWrapper struct {
 	ResourceName string
 
 	WasmExtensionConfig *envoyWasmFilterV3.Wasm
+
+	// Added by ingress
+	// InitialFetchTimeout is the initial fetch timeout of the ECDS config of the plugin, nil to wait forever.
+	InitialFetchTimeout *durationpb.Duration
+	// AuthenticationMatch is the authentication state of the requests the plugin runs for, empty for all.
+	AuthenticationMatch AuthenticationMatch
+	// MatchRules override the plugin configuration on some domains or routes of the gateways.
+	MatchRules []*WasmMatchRule
+	// SharedServices are the services the plugin calls whose clusters are generated, keyed by name.
+	SharedServices map[string]*WasmSharedService
+	// End added by ingress
 }
 
 func (p *WasmPluginWrapper) MatchListener(proxyLabels map[string]string, li WasmPluginListenerInfo) bool {
@@ -136,6 +148,13 @@ func convertToWasmPluginWrapper(originPlugin config.Config) *WasmPluginWrapper {
 		return nil
 	}
 
+	// Added by ingress
+	sharedServices := wasmSharedServices(&plugin)
+	if wasmPlugin.PluginConfig != nil && len(sharedServices) > 0 {
+		resolveSharedServices(structpb.NewStructValue(wasmPlugin.PluginConfig), sharedServices)
+	}
+	// End added by ingress
+
 	cfg := &anypb.Any{}
 	if wasmPlugin.PluginConfig != nil && len(wasmPlugin.PluginConfig.Fields) > 0 {
 		cfgJSON, err := protomarshal.ToJSON(wasmPlugin.PluginConfig)
@@ -166,7 +185,7 @@ func convertToWasmPluginWrapper(originPlugin config.Config) *WasmPluginWrapper {
 			Name:          resourceName,
 			RootId:        wasmPlugin.PluginName,
 			Configuration: cfg,
-			Vm:            buildVMConfig(datasource, plugin.ResourceVersion, wasmPlugin),
+			Vm:            buildVMConfig(datasource, vmResourceVersion(&plugin, wasmPlugin), wasmPlugin), // Modified by ingress
 			FailOpen:      wasmPlugin.FailStrategy == extensions.FailStrategy_FAIL_OPEN,
 		},
 	}
@@ -174,12 +193,22 @@ func convertToWasmPluginWrapper(originPlugin config.Config) *WasmPluginWrapper {
 		log.Warnf("WasmPlugin %s/%s failed to marshal to TypedExtensionConfig: %s", plugin.Namespace, plugin.Name, err)
 		return nil
 	}
+	// Added by ingress
+	setMetricsEnv(wasmExtensionConfig.Config.GetVmConfig(), plugin.Namespace, plugin.Name)
+	authMatch := authenticationMatch(&plugin, wasmPlugin.Phase)
+	// End added by ingress
 	return &WasmPluginWrapper{
 		Name:                plugin.Name,
 		Namespace:           plugin.Namespace,
 		ResourceName:        resourceName,
 		WasmPlugin:          wasmPlugin,
 		WasmExtensionConfig: wasmExtensionConfig,
+		// Added by ingress
+		InitialFetchTimeout: initialFetchTimeout(&plugin),
+		AuthenticationMatch: authMatch,
+		MatchRules:          wasmMatchRules(&plugin, wasmPlugin.PluginConfig, authMatch),
+		SharedServices:      sharedServices,
+		// End added by ingress
 	}
 }
 
@@ -201,7 +230,12 @@ func toSecretResourceName(name, pluginNamespace string) string {
 	}
 	// Forcely rewrite secret namespace to plugin namespace, since we require secret resource
 	// referenced by WasmPlugin co-located with WasmPlugin in the same namespace.
-	sr.Namespace = pluginNamespace
+	// Modified by ingress
+	// The secrets of the central pull secret namespaces are kept, and authorized at xds generation time.
+	if !wasmPullSecretNamespaces.Contains(sr.Namespace) {
+		sr.Namespace = pluginNamespace
+	}
+	// End modified by ingress
 	return sr.KubernetesResourceName()
 }
 
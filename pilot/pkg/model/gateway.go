@@ -173,6 +173,15 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 					if s.GetTls().GetMode() == networking.ServerTLSSettings_MUTUAL {
 						verifiedCertificateReferences.Insert(rn + credentials.SdsCaSuffix)
 					}
+					// Added by ingress
+				} else if credentials.IsExternalSecretType(parse.ResourceType) && gatewayConfig.Namespace == proxy.VerifiedIdentity.Namespace {
+					// Secrets stored outside of Kubernetes have no namespace, and are allowed to the gateways of the
+					// namespace of the proxy
+					verifiedCertificateReferences.Insert(rn)
+					if s.GetTls().GetMode() == networking.ServerTLSSettings_MUTUAL {
+						verifiedCertificateReferences.Insert(rn + credentials.SdsCaSuffix)
+					}
+					// End added by ingress
 				} else if ps.ReferenceAllowed(gvk.Secret, rn, proxy.VerifiedIdentity.Namespace) {
 					// Explicitly allowed by some policy
 					verifiedCertificateReferences.Insert(rn)
@@ -292,7 +301,9 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 						// We have TLS settings defined and we have already taken care of unique route names
 						// if it is HTTPS. So we can construct a QUIC server on the same port. It is okay as
 						// QUIC listens on UDP port, not TCP
-						if enableH3(ps) && gateway.IsEligibleForHTTP3Upgrade(s) {
+						// Modified by ingress
+						if http3Enabled(ps, gatewayConfig, s) {
+							// End modified by ingress
 							log.Debugf("Server at port %d eligible for HTTP3 upgrade. Add UDP listener for QUIC", serverPort.Number)
 							if mergedQUICServers[serverPort] == nil {
 								mergedQUICServers[serverPort] = &MergedServers{Servers: []*networking.Server{}}
@@ -310,7 +321,9 @@ func MergeGateways(gateways []gatewayWithInstances, proxy *Proxy, ps *PushContex
 					if gateway.IsHTTPServer(s) {
 						serversByRouteName[routeName] = []*networking.Server{s}
 
-						if enableH3(ps) && gateway.IsEligibleForHTTP3Upgrade(s) {
+						// Modified by ingress
+						if http3Enabled(ps, gatewayConfig, s) {
+							// End modified by ingress
 							log.Debugf("Server at port %d eligible for HTTP3 upgrade. So QUIC listener will be added", serverPort.Number)
 							http3AdvertisingRoutes.Insert(routeName)
 
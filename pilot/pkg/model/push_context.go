@@ -112,6 +112,8 @@ type virtualServiceIndex struct {
 
 	// Added by ingress
 	byHost map[string][]config.Config
+	// wasmPluginsAnnotated is true if a virtual service selects WasmPlugins by annotation.
+	wasmPluginsAnnotated bool
 	// End added by ingress
 }
 
@@ -185,6 +187,10 @@ type gatewayIndex struct {
 	namespace map[string][]config.Config
 	// all contains all gateways.
 	all []config.Config
+	// Added by ingress
+	// wasmPluginsAnnotated is true if a gateway selects WasmPlugins by annotation.
+	wasmPluginsAnnotated bool
+	// End added by ingress
 }
 
 func newGatewayIndex() gatewayIndex {
@@ -281,6 +287,15 @@ type PushContext struct {
 	InitDone        atomic.Bool
 	initializeMutex sync.Mutex
 	ambientIndex    AmbientIndexes
+
+	// Added by ingress
+	// descriptorSets serves the proto descriptor sets of the gRPC-JSON transcoding of the virtual services.
+	descriptorSets DescriptorSetStore
+
+	// gatewayReferencedHosts caches the hosts referenced by the gateways in this push, by proxy ID.
+	gatewayReferencedHostsMutex sync.Mutex
+	gatewayReferencedHosts      map[string]gatewayReferencedHosts
+	// End added by ingress
 }
 
 type consolidatedDestRules struct {
@@ -381,6 +396,11 @@ type PushRequest struct {
 	// Delta defines the resources that were added or removed as part of this push request.
 	// This is set only on requests from the client which change the set of resources they (un)subscribe from.
 	Delta ResourceDelta
+
+	// Added by Ingress
+	// AuditIDs are the IDs of the audited pushes merged into this request.
+	AuditIDs []uint64
+	// End added by Ingress
 }
 
 // ResourceDelta records the difference in requested resources by an XDS client
@@ -506,6 +526,10 @@ func (pr *PushRequest) Merge(other *PushRequest) *PushRequest {
 		pr.Push = other.Push
 	}
 
+	// Added by Ingress
+	pr.AuditIDs = append(pr.AuditIDs, other.AuditIDs...)
+	// End added by Ingress
+
 	// Do not merge when any one is empty
 	if len(pr.ConfigsUpdated) == 0 || len(other.ConfigsUpdated) == 0 {
 		pr.ConfigsUpdated = nil
@@ -549,6 +573,13 @@ func (pr *PushRequest) CopyMerge(other *PushRequest) *PushRequest {
 		Reason: reason,
 	}
 
+	// Added by Ingress
+	if len(pr.AuditIDs)+len(other.AuditIDs) > 0 {
+		merged.AuditIDs = make([]uint64, 0, len(pr.AuditIDs)+len(other.AuditIDs))
+		merged.AuditIDs = append(append(merged.AuditIDs, pr.AuditIDs...), other.AuditIDs...)
+	}
+	// End added by Ingress
+
 	// Do not merge when any one is empty
 	if len(pr.ConfigsUpdated) > 0 && len(other.ConfigsUpdated) > 0 {
 		merged.ConfigsUpdated = make(sets.Set[ConfigKey], len(pr.ConfigsUpdated)+len(other.ConfigsUpdated))
@@ -862,6 +893,53 @@ func (ps *PushContext) GatewayServices(proxy *Proxy) []*Service {
 	return gwSvcs
 }
 
+// Added by ingress
+
+// GatewayReferencedHosts returns the hosts the proxy gateways route to, which are the destinations of the virtual
+// services bound to the gateways, the hosts of the extension providers of the mesh config, and the hosts of the
+// services called by the filters of the gateways. They are computed once per push for each proxy, and must not be
+// modified.
+func (ps *PushContext) GatewayReferencedHosts(proxy *Proxy) sets.String {
+	if proxy.MergedGateway == nil {
+		return sets.New[string]()
+	}
+	ps.gatewayReferencedHostsMutex.Lock()
+	cached, f := ps.gatewayReferencedHosts[proxy.ID]
+	ps.gatewayReferencedHostsMutex.Unlock()
+	if f && cached.gateway == proxy.MergedGateway {
+		return cached.hosts
+	}
+
+	hosts := sets.New[string]()
+	gateways := sets.New[string]()
+	for _, gw := range proxy.MergedGateway.GatewayNameForServer {
+		gateways.Insert(gw)
+	}
+	for gw := range gateways {
+		for _, vsConfig := range ps.VirtualServicesForGateway(proxy.ConfigNamespace, gw) {
+			vs, ok := vsConfig.Spec.(*networking.VirtualService)
+			if !ok { // should never happen
+				continue
+			}
+			for host := range virtualServiceDestinations(vs) {
+				hosts.Insert(host)
+			}
+		}
+	}
+	addHostsFromMeshConfig(ps, hosts)
+	ps.addHostsFromGatewayFilters(proxy, gateways, hosts)
+
+	ps.gatewayReferencedHostsMutex.Lock()
+	if ps.gatewayReferencedHosts == nil {
+		ps.gatewayReferencedHosts = map[string]gatewayReferencedHosts{}
+	}
+	ps.gatewayReferencedHosts[proxy.ID] = gatewayReferencedHosts{gateway: proxy.MergedGateway, hosts: hosts}
+	ps.gatewayReferencedHostsMutex.Unlock()
+	return hosts
+}
+
+// End added by ingress
+
 func (ps *PushContext) ServicesAttachedToMesh() map[string]sets.String {
 	return ps.virtualServiceIndex.referencedDestinations
 }
@@ -1296,6 +1374,10 @@ func (ps *PushContext) InitContext(env *Environment, oldPushContext *PushContext
 
 	ps.networkMgr = env.NetworkManager
 
+	// Added by ingress
+	ps.descriptorSets = env.DescriptorSets
+	// End added by ingress
+
 	ps.clusterLocalHosts = env.ClusterLocal().GetClusterLocalHosts()
 
 	ps.InitDone.Store(true)
@@ -1612,6 +1694,9 @@ func (ps *PushContext) initVirtualServices(env *Environment) {
 		ns := virtualService.Namespace
 		rule := virtualService.Spec.(*networking.VirtualService)
 		// Added by ingress
+		if _, f := virtualService.Annotations[constants.WasmPluginsAnnotation]; f {
+			ps.virtualServiceIndex.wasmPluginsAnnotated = true
+		}
 		if len(rule.Gateways) > 0 {
 			if len(rule.Hosts) == 0 {
 				ps.virtualServiceIndex.byHost[constants.GlobalWildcardHost] = append(ps.virtualServiceIndex.byHost[constants.GlobalWildcardHost], virtualService)
@@ -2125,6 +2210,11 @@ func (ps *PushContext) initGateways(env *Environment) {
 		gateways[i] = gatewayConfigs[i].DeepCopy()
 	}
 	gatewayConfigs = GatewayFilter(gateways)
+	for _, gatewayConfig := range gatewayConfigs {
+		if _, f := gatewayConfig.Annotations[constants.WasmPluginsAnnotation]; f {
+			ps.gatewayIndex.wasmPluginsAnnotated = true
+		}
+	}
 	// End added by ingress
 
 	sortConfigByCreationTime(gatewayConfigs)
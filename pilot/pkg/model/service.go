@@ -638,6 +638,13 @@ type ServiceAttributes struct {
 	ClusterExternalPorts map[cluster.ID]map[uint32]uint32
 
 	K8sAttributes
+
+	// Added by ingress
+	// BackendTLS is the TLS policy of the gateways connecting to the service, as set by its annotation.
+	BackendTLS string
+	// DNSResolvers are the DNS resolvers of the clusters of the service, as set by its annotation.
+	DNSResolvers string
+	// End added by ingress
 }
 
 type K8sAttributes struct {
@@ -743,7 +750,8 @@ func (s *ServiceAttributes) Equals(other *ServiceAttributes) bool {
 		}
 	}
 	return s.Name == other.Name && s.Namespace == other.Namespace &&
-		s.ServiceRegistry == other.ServiceRegistry && s.K8sAttributes == other.K8sAttributes
+		s.ServiceRegistry == other.ServiceRegistry && s.K8sAttributes == other.K8sAttributes &&
+		s.BackendTLS == other.BackendTLS && s.DNSResolvers == other.DNSResolvers // Modified by ingress
 }
 
 // ServiceDiscovery enumerates Istio service instances.
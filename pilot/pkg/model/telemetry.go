@@ -54,6 +54,10 @@ type Telemetry struct {
 	Name      string         `json:"name"`
 	Namespace string         `json:"namespace"`
 	Spec      *tpb.Telemetry `json:"spec"`
+	// Added by ingress
+	// AccessLogFormat is the named access log format referenced by the higress.io/access-log-format annotation.
+	AccessLogFormat string `json:"accessLogFormat,omitempty"`
+	// End added by ingress
 }
 
 // Telemetries organizes Telemetry configuration by namespace.
@@ -80,6 +84,11 @@ type Telemetries struct {
 	computedMetricsFilters map[metricsKey]any
 	computedLoggingConfig  map[loggingKey][]LoggingConfig
 	mu                     sync.Mutex
+
+	// Added by ingress
+	// accessLogFormats are the named access log formats of the mesh.
+	accessLogFormats map[string]*AccessLogFormat
+	// End added by ingress
 }
 
 // telemetryKey defines a key into the computedMetricsFilters cache.
@@ -115,6 +124,9 @@ func getTelemetries(env *Environment) *Telemetries {
 		meshConfig:             env.Mesh(),
 		computedMetricsFilters: map[metricsKey]any{},
 		computedLoggingConfig:  map[loggingKey][]LoggingConfig{},
+		// Added by ingress
+		accessLogFormats: meshAccessLogFormats(),
+		// End added by ingress
 	}
 
 	fromEnv := env.List(gvk.Telemetry, NamespaceAll)
@@ -124,6 +136,9 @@ func getTelemetries(env *Environment) *Telemetries {
 			Name:      config.Name,
 			Namespace: config.Namespace,
 			Spec:      config.Spec.(*tpb.Telemetry),
+			// Added by ingress
+			AccessLogFormat: telemetryAccessLogFormat(config),
+			// End added by ingress
 		}
 		telemetries.NamespaceToTelemetries[config.Namespace] = append(telemetries.NamespaceToTelemetries[config.Namespace], telemetry)
 	}
@@ -194,6 +209,9 @@ type computedTelemetries struct {
 type computedAccessLogging struct {
 	telemetryKey
 	Logging []*tpb.AccessLogging
+	// Added by ingress
+	AccessLogFormat string
+	// End added by ingress
 }
 
 type TracingConfig struct {
@@ -219,6 +237,10 @@ type LoggingConfig struct {
 type loggingSpec struct {
 	Disabled bool
 	Filter   *tpb.AccessLogging_Filter
+	// Added by ingress
+	// Format is the named access log format of the provider, referenced by the most specific Telemetry.
+	Format string
+	// End added by ingress
 }
 
 func workloadMode(class networking.ListenerClass) tpb.WorkloadMode {
@@ -273,6 +295,13 @@ func (t *Telemetries) AccessLogging(push *PushContext, proxy *Proxy, class netwo
 		}
 
 		al := telemetryAccessLog(push, fp)
+		// Added by ingress
+		if v.Format != "" {
+			if formatted := t.namedFormatAccessLog(fp, v.Format); formatted != nil {
+				al = formatted
+			}
+		}
+		// End added by ingress
 		if al == nil {
 			// stackdriver will be handled in HTTPFilters/TCPFilters
 			continue
@@ -412,6 +441,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 						Root: key.Root,
 					},
 					Logging: telemetry.Spec.GetAccessLogging(),
+					// Added by ingress
+					AccessLogFormat: telemetry.AccessLogFormat,
+					// End added by ingress
 				})
 			}
 			ts = append(ts, telemetry.Spec.GetTracing()...)
@@ -429,6 +461,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 						Namespace: key.Namespace,
 					},
 					Logging: telemetry.Spec.GetAccessLogging(),
+					// Added by ingress
+					AccessLogFormat: telemetry.AccessLogFormat,
+					// End added by ingress
 				})
 			}
 			ts = append(ts, telemetry.Spec.GetTracing()...)
@@ -450,6 +485,9 @@ func (t *Telemetries) applicableTelemetries(proxy *Proxy) computedTelemetries {
 						Workload: types.NamespacedName{Name: telemetry.Name, Namespace: telemetry.Namespace},
 					},
 					Logging: telemetry.Spec.GetAccessLogging(),
+					// Added by ingress
+					AccessLogFormat: telemetry.AccessLogFormat,
+					// End added by ingress
 				})
 			}
 			ts = append(ts, spec.GetTracing()...)
@@ -588,9 +626,16 @@ func mergeLogs(logs []*computedAccessLogging, mesh *meshconfig.MeshConfig, mode
 			names.InsertAll(subProviders...)
 
 			for _, prov := range subProviders {
+				// Modified by ingress
+				format := m.AccessLogFormat
+				if format == "" {
+					format = filters[prov].Format
+				}
 				filters[prov] = loggingSpec{
 					Filter: p.Filter,
+					Format: format,
 				}
+				// End modified by ingress
 			}
 		}
 
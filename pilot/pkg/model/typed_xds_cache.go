@@ -25,6 +25,7 @@ import (
 	"google.golang.org/protobuf/testing/protocmp"
 
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/cache"
 	"istio.io/istio/pkg/monitoring"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/sets"
@@ -123,7 +124,9 @@ type evictKeyConfigs[K comparable] struct {
 
 type lruCache[K comparable] struct {
 	enableAssertions bool
-	store            simplelru.LRUCache[K, cacheValue]
+	// Modified by ingress
+	store xdsCacheStore[K]
+	// End modified by ingress
 	// token stores the latest token of the store, used to prevent stale data overwrite.
 	// It is refreshed when Clear or ClearAll are called
 	token       CacheToken
@@ -138,11 +141,18 @@ type lruCache[K comparable] struct {
 
 var _ typedXdsCache[uint64] = &lruCache[uint64]{}
 
-func newLru[K comparable](evictCallback simplelru.EvictCallback[K, cacheValue]) simplelru.LRUCache[K, cacheValue] {
+// Modified by ingress
+func newLru[K comparable](evictCallback simplelru.EvictCallback[K, cacheValue]) xdsCacheStore[K] {
+	// End modified by ingress
 	sz := features.XDSCacheMaxSize
 	if sz <= 0 {
 		sz = 20000
 	}
+	// Added by ingress
+	if xdsCacheEvictionPolicy == cache.TinyLFUPolicy {
+		return newTinyLFUStore(sz, evictCallback)
+	}
+	// End added by ingress
 	l, err := simplelru.NewLRU(sz, evictCallback)
 	if err != nil {
 		panic(fmt.Errorf("invalid lru configuration: %v", err))
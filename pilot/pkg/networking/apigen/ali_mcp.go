@@ -0,0 +1,166 @@
+package apigen
+
+import (
+	"fmt"
+	"strings"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// MCPGeneratorName is the generator of the downstream control planes consuming the configs served by the
+	// MCPGenerator.
+	MCPGeneratorName = "mcp"
+
+	// MCPNamespacesMetadata is the node metadata of a downstream control plane with the comma separated namespaces
+	// of the configs it consumes, all by default.
+	MCPNamespacesMetadata = "MCP_NAMESPACES"
+	// MCPLabelSelectorMetadata is the node metadata of a downstream control plane with the Kubernetes label
+	// selector of the configs it consumes, all by default.
+	MCPLabelSelectorMetadata = "MCP_LABEL_SELECTOR"
+)
+
+// ParseMCPKinds parses the comma separated kinds of the configs served to the downstream control planes, such as
+// VirtualService,WasmPlugin,ServiceEntry.
+func ParseMCPKinds(kinds string) ([]config.GroupVersionKind, error) {
+	var out []config.GroupVersionKind
+	for _, k := range strings.Split(kinds, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		found := false
+		for _, s := range collections.Pilot.All() {
+			if s.Kind() == k {
+				out = append(out, s.GroupVersionKind())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown config kind %q", k)
+		}
+	}
+	return out, nil
+}
+
+// MCPGenerator serves the configs of some kinds to downstream control planes over xDS, as the APIGenerator does but
+// without the synthetic ServiceEntries, filtered by the namespaces and the label selector in the node metadata of
+// each of the downstream control planes.
+type MCPGenerator struct {
+	store model.ConfigStore
+	kinds sets.Set[kind.Kind]
+}
+
+var _ model.XdsResourceGenerator = &MCPGenerator{}
+
+// NewMCPGenerator creates a MCPGenerator serving the configs of the kinds from the store. It serves no config if
+// there are no kinds.
+func NewMCPGenerator(store model.ConfigStore, kinds []config.GroupVersionKind) *MCPGenerator {
+	g := &MCPGenerator{store: store, kinds: sets.New[kind.Kind]()}
+	for _, k := range kinds {
+		g.kinds.Insert(kind.MustFromGVK(k))
+	}
+	return g
+}
+
+// mcpFilter is the filter of the configs consumed by a downstream control plane.
+type mcpFilter struct {
+	namespaces sets.String
+	selector   klabels.Selector
+}
+
+func newMCPFilter(proxy *model.Proxy) (*mcpFilter, error) {
+	f := &mcpFilter{}
+	if ns, _ := proxy.Metadata.Raw[MCPNamespacesMetadata].(string); ns != "" {
+		f.namespaces = sets.New[string]()
+		for _, n := range strings.Split(ns, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				f.namespaces.Insert(n)
+			}
+		}
+	}
+	if selector, _ := proxy.Metadata.Raw[MCPLabelSelectorMetadata].(string); selector != "" {
+		s, err := klabels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", MCPLabelSelectorMetadata, err)
+		}
+		f.selector = s
+	}
+	return f, nil
+}
+
+func (f *mcpFilter) matches(c config.Config) bool {
+	if f.namespaces != nil && !f.namespaces.Contains(c.Namespace) {
+		return false
+	}
+	return f.selector == nil || f.selector.Matches(klabels.Set(c.Labels))
+}
+
+// Generate implements the generate method of the configs served to the downstream control planes.
+func (g *MCPGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	resp := model.Resources{}
+	parts := strings.SplitN(w.TypeUrl, "/", 3)
+	if len(parts) != 3 {
+		log.Warnf("MCP: Unknown watched resources %s", w.TypeUrl)
+		return resp, model.DefaultXdsLogDetails, nil
+	}
+	rgvk := config.GroupVersionKind{Group: parts[0], Version: parts[1], Kind: parts[2]}
+	if _, ok := collections.Pilot.FindByGroupVersionKind(rgvk); !ok {
+		// An empty set for the kinds not known, as the APIGenerator.
+		return resp, model.DefaultXdsLogDetails, nil
+	}
+	k := kind.MustFromGVK(rgvk)
+	if !g.kinds.Contains(k) {
+		// An empty set for the kinds not served.
+		return resp, model.DefaultXdsLogDetails, nil
+	}
+	if !req.IsRequest() && !configsUpdated(req, k) {
+		return nil, model.DefaultXdsLogDetails, nil
+	}
+
+	filter, err := newMCPFilter(proxy)
+	if err != nil {
+		return nil, model.DefaultXdsLogDetails, err
+	}
+	for _, c := range g.store.List(rgvk, "") {
+		if !filter.matches(c) {
+			continue
+		}
+		b, err := config.PilotConfigToResource(&c)
+		if err != nil {
+			log.WithLabels("resource", config.NamespacedName(c)).Warnf("resource error: %v", err)
+			continue
+		}
+		resp = append(resp, &discovery.Resource{
+			Name:     c.Namespace + "/" + c.Name,
+			Resource: protoconv.MessageToAny(b),
+		})
+	}
+	return resp, model.DefaultXdsLogDetails, nil
+}
+
+// configsUpdated returns true if the push updates the configs of a kind.
+func configsUpdated(req *model.PushRequest, k kind.Kind) bool {
+	if !req.Full {
+		return false
+	}
+	if len(req.ConfigsUpdated) == 0 {
+		return true
+	}
+	for key := range req.ConfigsUpdated {
+		if key.Kind == k {
+			return true
+		}
+	}
+	return false
+}
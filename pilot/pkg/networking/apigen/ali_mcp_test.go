@@ -0,0 +1,112 @@
+package apigen
+
+import (
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestParseMCPKinds(t *testing.T) {
+	kinds, err := ParseMCPKinds("VirtualService, WasmPlugin,ServiceEntry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kinds) != 3 || kinds[0] != gvk.VirtualService || kinds[1] != gvk.WasmPlugin || kinds[2] != gvk.ServiceEntry {
+		t.Fatalf("unexpected kinds %v", kinds)
+	}
+	if kinds, err := ParseMCPKinds(""); err != nil || len(kinds) != 0 {
+		t.Fatalf("expected no kinds, got %v %v", kinds, err)
+	}
+	if _, err := ParseMCPKinds("VirtualService,Unknown"); err == nil {
+		t.Fatalf("expected an error for an unknown kind")
+	}
+}
+
+func TestMCPGenerator(t *testing.T) {
+	store := memory.MakeSkipValidation(collections.Pilot)
+	for _, c := range []config.Config{
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "a", Namespace: "team-a", Labels: map[string]string{"exported": "true"}},
+			Spec: &networking.VirtualService{Hosts: []string{"a.example.com"}},
+		},
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "b", Namespace: "team-a"},
+			Spec: &networking.VirtualService{Hosts: []string{"b.example.com"}},
+		},
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: "c", Namespace: "team-c", Labels: map[string]string{"exported": "true"}},
+			Spec: &networking.VirtualService{Hosts: []string{"c.example.com"}},
+		},
+		{
+			Meta: config.Meta{GroupVersionKind: gvk.DestinationRule, Name: "a", Namespace: "team-a"},
+			Spec: &networking.DestinationRule{Host: "a.example.com"},
+		},
+	} {
+		if _, err := store.Create(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g := NewMCPGenerator(store, []config.GroupVersionKind{gvk.VirtualService})
+	request := &model.PushRequest{Full: true, Reason: model.NewReasonStats(model.ProxyRequest)}
+
+	names := func(metadata map[string]any, typeURL string, req *model.PushRequest) []string {
+		t.Helper()
+		proxy := &model.Proxy{Metadata: &model.NodeMetadata{Generator: MCPGeneratorName, Raw: metadata}}
+		res, _, err := g.Generate(proxy, &model.WatchedResource{TypeUrl: typeURL}, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res == nil {
+			return nil
+		}
+		out := []string{}
+		for _, r := range res {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+	expect := func(got []string, want ...string) {
+		t.Helper()
+		if !sets.New(got...).Equals(sets.New(want...)) || len(got) != len(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	expect(names(nil, gvk.VirtualService.String(), request), "team-a/a", "team-a/b", "team-c/c")
+	expect(names(map[string]any{MCPNamespacesMetadata: "team-a"}, gvk.VirtualService.String(), request),
+		"team-a/a", "team-a/b")
+	expect(names(map[string]any{MCPLabelSelectorMetadata: "exported=true"}, gvk.VirtualService.String(), request),
+		"team-a/a", "team-c/c")
+	expect(names(map[string]any{MCPNamespacesMetadata: "team-a", MCPLabelSelectorMetadata: "exported=true"},
+		gvk.VirtualService.String(), request), "team-a/a")
+	// The kinds not served are empty.
+	expect(names(nil, gvk.DestinationRule.String(), request))
+
+	// The pushes not updating the kind are skipped.
+	drUpdate := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.DestinationRule, Name: "a", Namespace: "team-a"}),
+		Reason:         model.NewReasonStats(model.ConfigUpdate),
+	}
+	if got := names(nil, gvk.VirtualService.String(), drUpdate); got != nil {
+		t.Fatalf("expected the push to be skipped, got %v", got)
+	}
+	vsUpdate := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.VirtualService, Name: "a", Namespace: "team-a"}),
+		Reason:         model.NewReasonStats(model.ConfigUpdate),
+	}
+	expect(names(nil, gvk.VirtualService.String(), vsUpdate), "team-a/a", "team-a/b", "team-c/c")
+
+	proxy := &model.Proxy{Metadata: &model.NodeMetadata{Raw: map[string]any{MCPLabelSelectorMetadata: "a in ("}}}
+	if _, _, err := g.Generate(proxy, &model.WatchedResource{TypeUrl: gvk.VirtualService.String()}, request); err == nil {
+		t.Fatalf("expected an error for an invalid label selector")
+	}
+}
@@ -48,6 +48,10 @@ type ConfigGenerator interface {
 
 	// Added by ingress
 	BuildScopedRoutes(node *model.Proxy, push *model.PushContext) []*route.ScopedRouteConfiguration
+
+	// BuildVirtualHosts returns the route configurations of the given gateway with all their virtual hosts, which
+	// are left out of its RDS output when it requests them on demand. This is the VHDS output.
+	BuildVirtualHosts(node *model.Proxy, req *model.PushRequest, routeNames []string) []*route.RouteConfiguration
 	// End added by ingress
 
 	// BuildNameTable returns list of hostnames and the associated IPs
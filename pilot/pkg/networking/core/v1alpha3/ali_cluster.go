@@ -0,0 +1,161 @@
+package v1alpha3
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
+	"istio.io/istio/pilot/pkg/networking/util"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/log"
+)
+
+// buildWasmSharedServiceClusters generates the clusters of the shared services called by the WasmPlugins, sorted by
+// name. The services of a namespace with the same name share a cluster, built from the first plugin declaring it.
+func (cb *ClusterBuilder) buildWasmSharedServiceClusters(
+	wasmPlugins map[extensions.PluginPhase][]*model.WasmPluginWrapper,
+) []*cluster.Cluster {
+	services := map[string]*model.WasmSharedService{}
+	for _, list := range wasmPlugins {
+		for _, p := range list {
+			for _, svc := range p.SharedServices {
+				if _, f := services[svc.ClusterName]; !f {
+					services[svc.ClusterName] = svc
+				}
+			}
+		}
+	}
+	clusters := make([]*cluster.Cluster, 0, len(services))
+	for _, svc := range services {
+		if c := cb.buildWasmSharedServiceCluster(svc); c != nil {
+			clusters = append(clusters, c)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters
+}
+
+func (cb *ClusterBuilder) buildWasmSharedServiceCluster(svc *model.WasmSharedService) *cluster.Cluster {
+	lbEndpoints := []*endpoint.LocalityLbEndpoints{{
+		LbEndpoints: []*endpoint.LbEndpoint{{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+				Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(svc.Host, svc.Port)},
+			},
+		}},
+	}}
+	port := &model.Port{Port: int(svc.Port), Protocol: protocol.TCP}
+	mc := cb.buildDefaultCluster(svc.ClusterName, cluster.Cluster_STRICT_DNS, lbEndpoints,
+		model.TrafficDirectionOutbound, port, nil, nil)
+	if mc == nil {
+		return nil
+	}
+	cb.applyDefaultConnectionPool(mc.cluster)
+	if svc.TLS {
+		sni := svc.SNI
+		if sni == "" {
+			sni = svc.Host
+		}
+		mc.cluster.TransportSocket = &core.TransportSocket{
+			Name: wellknown.TransportSocketTls,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(&auth.UpstreamTlsContext{
+				CommonTlsContext: defaultUpstreamCommonTLSContext(),
+				Sni:              sni,
+			})},
+		}
+	}
+	return mc.build()
+}
+
+// buildRateLimitServiceCluster generates the cluster of the global rate limit service called by the rate limit filter
+// of the gateways, or nil if there is none. It is connected by mTLS with the workload certificate and the root
+// certificate served by SDS, unless disabled.
+func (cb *ClusterBuilder) buildRateLimitServiceCluster() *cluster.Cluster {
+	if alifeatures.RateLimitServiceAddress == "" {
+		return nil
+	}
+	return cb.buildGRPCServiceCluster("rate limit service", mseingress.RateLimitServiceClusterName,
+		alifeatures.RateLimitServiceAddress, alifeatures.RateLimitServiceMTLS, alifeatures.RateLimitServiceSubjectAltNames)
+}
+
+// buildExtProcServiceCluster generates the cluster of the external processor called by the ext_proc filter of the
+// gateways, or nil if there is none. It is connected by mTLS with the workload certificate and the root certificate
+// served by SDS, unless disabled.
+func (cb *ClusterBuilder) buildExtProcServiceCluster() *cluster.Cluster {
+	if alifeatures.ExtProcServiceAddress == "" {
+		return nil
+	}
+	return cb.buildGRPCServiceCluster("external processor", mseingress.ExtProcServiceClusterName,
+		alifeatures.ExtProcServiceAddress, alifeatures.ExtProcServiceMTLS, alifeatures.ExtProcServiceSubjectAltNames)
+}
+
+// buildGRPCServiceCluster generates the cluster of a gRPC service called by the filters of the gateways at the
+// host:port address, connected by mTLS if enabled, accepting the comma separated subject alternative names if set.
+func (cb *ClusterBuilder) buildGRPCServiceCluster(service, clusterName, address string, mtls bool,
+	subjectAltNames string,
+) *cluster.Cluster {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		log.Warnf("invalid %s address %q: %v", service, address, err)
+		return nil
+	}
+	portNum, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil || portNum == 0 {
+		log.Warnf("invalid %s port %q", service, portStr)
+		return nil
+	}
+	lbEndpoints := []*endpoint.LocalityLbEndpoints{{
+		LbEndpoints: []*endpoint.LbEndpoint{{
+			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+				Endpoint: &endpoint.Endpoint{Address: util.BuildAddress(host, uint32(portNum))},
+			},
+		}},
+	}}
+	port := &model.Port{Port: int(portNum), Protocol: protocol.GRPC}
+	mc := cb.buildDefaultCluster(clusterName, cluster.Cluster_STRICT_DNS, lbEndpoints,
+		model.TrafficDirectionOutbound, port, nil, nil)
+	if mc == nil {
+		return nil
+	}
+	cb.applyDefaultConnectionPool(mc.cluster)
+	cb.setH2Options(mc)
+	if mtls {
+		tlsContext := &auth.UpstreamTlsContext{
+			CommonTlsContext: defaultUpstreamCommonTLSContext(),
+			Sni:              host,
+		}
+		tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs = []*auth.SdsSecretConfig{
+			authn_model.ConstructSdsSecretConfig(authn_model.SDSDefaultResourceName),
+		}
+		var sans []string
+		if subjectAltNames != "" {
+			sans = strings.Split(subjectAltNames, ",")
+		}
+		tlsContext.CommonTlsContext.ValidationContextType = &auth.CommonTlsContext_CombinedValidationContext{
+			CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
+				DefaultValidationContext:         &auth.CertificateValidationContext{MatchSubjectAltNames: util.StringToExactMatch(sans)},
+				ValidationContextSdsSecretConfig: authn_model.ConstructSdsSecretConfig(authn_model.SDSRootResourceName),
+			},
+		}
+		tlsContext.CommonTlsContext.AlpnProtocols = util.ALPNH2Only
+		mc.cluster.TransportSocket = &core.TransportSocket{
+			Name:       wellknown.TransportSocketTls,
+			ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(tlsContext)},
+		}
+	}
+	return mc.build()
+}
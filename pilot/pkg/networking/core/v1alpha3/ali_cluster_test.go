@@ -0,0 +1,131 @@
+package v1alpha3
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	auth "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test"
+)
+
+func TestBuildWasmSharedServiceClusters(t *testing.T) {
+	plugin := func(name, namespace, services string) config.Config {
+		return config.Config{
+			Meta: config.Meta{
+				GroupVersionKind: gvk.WasmPlugin,
+				Name:             name,
+				Namespace:        namespace,
+				Annotations:      map[string]string{constants.WasmSharedServicesAnnotation: services},
+			},
+			Spec: &extensions.WasmPlugin{Url: "file:///etc/plugin.wasm"},
+		}
+	}
+	cg := NewConfigGenTest(t, TestOptions{
+		Configs: []config.Config{
+			plugin("ratelimit", "default", `{"redis": {"address": "redis.example.com:6379"}, `+
+				`"rls": {"address": "rls.example.com:443", "tls": true}}`),
+			plugin("cache", "default", `{"redis": {"address": "redis.example.com:6379"}}`),
+			plugin("other", "other", `{"redis": {"address": "redis.other.com:6379"}}`),
+		},
+	})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{})))
+
+	redis := clusters["wasm-shared|default|redis"]
+	if redis == nil {
+		t.Fatalf("missing cluster of the redis shared service")
+	}
+	if got := redis.GetType(); got != cluster.Cluster_STRICT_DNS {
+		t.Errorf("got redis cluster type %v, want STRICT_DNS", got)
+	}
+	addr := redis.GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetSocketAddress()
+	if addr.GetAddress() != "redis.example.com" || addr.GetPortValue() != 6379 {
+		t.Errorf("got redis address %v, want redis.example.com:6379", addr)
+	}
+	if redis.GetTransportSocket() != nil {
+		t.Errorf("got redis transport socket %v, want none", redis.GetTransportSocket())
+	}
+
+	rls := clusters["wasm-shared|default|rls"]
+	if rls == nil {
+		t.Fatalf("missing cluster of the rls shared service")
+	}
+	tlsContext := xdstest.UnmarshalAny[auth.UpstreamTlsContext](t, rls.GetTransportSocket().GetTypedConfig())
+	if got := tlsContext.GetSni(); got != "rls.example.com" {
+		t.Errorf("got rls SNI %q, want rls.example.com", got)
+	}
+
+	if _, f := clusters["wasm-shared|other|redis"]; f {
+		t.Errorf("got cluster of a shared service of a plugin in another namespace")
+	}
+}
+
+func TestBuildRateLimitServiceCluster(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{Type: model.Router})))
+	if _, f := clusters[mseingress.RateLimitServiceClusterName]; f {
+		t.Fatalf("got rate limit service cluster without a rate limit service")
+	}
+
+	test.SetForTest(t, &alifeatures.RateLimitServiceAddress, "rls.example.com:8081")
+	test.SetForTest(t, &alifeatures.RateLimitServiceSubjectAltNames, "spiffe://cluster.local/ns/rls/sa/rls")
+	clusters = xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{Type: model.Router})))
+	rls := clusters[mseingress.RateLimitServiceClusterName]
+	if rls == nil {
+		t.Fatalf("missing rate limit service cluster")
+	}
+	addr := rls.GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetSocketAddress()
+	if addr.GetAddress() != "rls.example.com" || addr.GetPortValue() != 8081 {
+		t.Errorf("got rate limit service address %v, want rls.example.com:8081", addr)
+	}
+	tlsContext := xdstest.UnmarshalAny[auth.UpstreamTlsContext](t, rls.GetTransportSocket().GetTypedConfig())
+	common := tlsContext.GetCommonTlsContext()
+	if got := common.GetTlsCertificateSdsSecretConfigs(); len(got) != 1 || got[0].Name != authn_model.SDSDefaultResourceName {
+		t.Errorf("got certificate SDS configs %v, want the workload certificate", got)
+	}
+	validation := common.GetCombinedValidationContext()
+	if validation.GetValidationContextSdsSecretConfig().GetName() != authn_model.SDSRootResourceName {
+		t.Errorf("got validation context %v, want the root certificate", validation)
+	}
+	if sans := validation.GetDefaultValidationContext().GetMatchSubjectAltNames(); len(sans) != 1 ||
+		sans[0].GetExact() != "spiffe://cluster.local/ns/rls/sa/rls" {
+		t.Errorf("got subject alt names %v", sans)
+	}
+
+	clusters = xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{})))
+	if _, f := clusters[mseingress.RateLimitServiceClusterName]; f {
+		t.Errorf("got rate limit service cluster for a sidecar")
+	}
+}
+
+func TestBuildExtProcServiceCluster(t *testing.T) {
+	cg := NewConfigGenTest(t, TestOptions{})
+	clusters := xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{Type: model.Router})))
+	if _, f := clusters[mseingress.ExtProcServiceClusterName]; f {
+		t.Fatalf("got external processor cluster without an external processor")
+	}
+
+	test.SetForTest(t, &alifeatures.ExtProcServiceAddress, "ext-proc.example.com:9002")
+	test.SetForTest(t, &alifeatures.ExtProcServiceMTLS, false)
+	clusters = xdstest.ExtractClusters(cg.Clusters(cg.SetupProxy(&model.Proxy{Type: model.Router})))
+	extProc := clusters[mseingress.ExtProcServiceClusterName]
+	if extProc == nil {
+		t.Fatalf("missing external processor cluster")
+	}
+	addr := extProc.GetLoadAssignment().GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().GetSocketAddress()
+	if addr.GetAddress() != "ext-proc.example.com" || addr.GetPortValue() != 9002 {
+		t.Errorf("got external processor address %v, want ext-proc.example.com:9002", addr)
+	}
+	if extProc.GetTransportSocket() != nil {
+		t.Errorf("got transport socket %v without mTLS", extProc.GetTransportSocket())
+	}
+}
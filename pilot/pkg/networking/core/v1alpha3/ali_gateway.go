@@ -3,13 +3,33 @@ package v1alpha3
 import (
 	"strconv"
 
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tcp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/tcp_proxy/v3"
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 	"istio.io/istio/pkg/log"
 
+	extensions "istio.io/api/extensions/v1alpha1"
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/extension"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/ali/config/clientcert"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/ali/config/ipaccess"
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
+	"istio.io/istio/pkg/ali/config/sniforwardproxy"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	gatewaytool "istio.io/istio/pkg/config/gateway"
+	"istio.io/istio/pkg/util/sets"
 )
 
 const enableH2 = "mse.ingress.alibabacloud.com/enable-h2"
@@ -125,3 +145,387 @@ func applyTls(tlsSettings *networking.ServerTLSSettings, extraOpts *buildListene
 func convertServerTLSSettings(protocol string) networking.ServerTLSSettings_TLSProtocol {
 	return tlsProtocol[TLSProtocolVersion(protocol)]
 }
+
+// wasmPluginSelector disables on the routes of a virtual service the WasmPlugins not selected by its
+// higress.io/wasm-plugins annotation, or else by the annotation of its gateway. It also overrides the configuration
+// of the WasmPlugins on the virtual hosts and routes matched by their higress.io/wasm-match-rules annotation.
+type wasmPluginSelector struct {
+	node *model.Proxy
+	push *model.PushContext
+	// plugins are the WasmPlugins of the proxy, loaded on first use.
+	plugins map[extensions.PluginPhase][]*model.WasmPluginWrapper
+	loaded  bool
+	// matchRules is true if any of the plugins has match rules.
+	matchRules bool
+}
+
+func newWasmPluginSelector(node *model.Proxy, push *model.PushContext) *wasmPluginSelector {
+	return &wasmPluginSelector{node: node, push: push}
+}
+
+func (s *wasmPluginSelector) wasmPlugins() map[extensions.PluginPhase][]*model.WasmPluginWrapper {
+	if !s.loaded {
+		s.plugins = s.push.WasmPlugins(s.node)
+		s.loaded = true
+		for _, list := range s.plugins {
+			for _, p := range list {
+				if len(p.MatchRules) > 0 {
+					s.matchRules = true
+				}
+			}
+		}
+	}
+	return s.plugins
+}
+
+// selection returns the value of the annotation selecting the WasmPlugins of the virtual service bound to the
+// gateway, if any.
+func (s *wasmPluginSelector) selection(virtualService config.Config, gatewayName string) (string, bool) {
+	if value, ok := virtualService.Annotations[constants.WasmPluginsAnnotation]; ok {
+		return value, true
+	}
+	return s.gatewaySelection(gatewayName)
+}
+
+func (s *wasmPluginSelector) gatewaySelection(gatewayName string) (string, bool) {
+	gw := s.push.GetGatewayByName(gatewayName)
+	if gw == nil {
+		return "", false
+	}
+	value, ok := gw.Annotations[constants.WasmPluginsAnnotation]
+	return value, ok
+}
+
+// selects returns true if the virtual service or one of its gateways selects WasmPlugins.
+func (s *wasmPluginSelector) selects(virtualService config.Config) bool {
+	if _, ok := virtualService.Annotations[constants.WasmPluginsAnnotation]; ok {
+		return true
+	}
+	for _, gatewayName := range virtualService.Spec.(*networking.VirtualService).Gateways {
+		if _, ok := s.gatewaySelection(gatewayName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// apply disables on the routes of the virtual service bound to the gateway the WasmPlugins it does not select, and
+// overrides the configuration of the others on the routes matched by their match rules.
+func (s *wasmPluginSelector) apply(virtualService config.Config, gatewayName string, routes []*route.Route) {
+	plugins := s.wasmPlugins()
+	var disabled map[string]*anypb.Any
+	if value, ok := s.selection(virtualService, gatewayName); ok {
+		disabled = extension.DisabledWasmPluginFilters(plugins, extension.ParseWasmPluginSelection(value))
+	}
+	if len(disabled) == 0 && !s.matchRules {
+		return
+	}
+	for _, r := range routes {
+		for name, filterConfig := range disabled {
+			if r.TypedPerFilterConfig == nil {
+				r.TypedPerFilterConfig = make(map[string]*anypb.Any, len(disabled))
+			}
+			r.TypedPerFilterConfig[name] = filterConfig
+		}
+		if s.matchRules {
+			r.TypedPerFilterConfig = addFilterConfigs(r.TypedPerFilterConfig,
+				extension.RouteWasmPluginConfigs(plugins, r))
+		}
+	}
+}
+
+// applyDomain overrides the configuration of the WasmPlugins on the virtual host matched by their match rules.
+func (s *wasmPluginSelector) applyDomain(vHost *route.VirtualHost) {
+	plugins := s.wasmPlugins()
+	if !s.matchRules {
+		return
+	}
+	for _, domain := range vHost.Domains {
+		vHost.TypedPerFilterConfig = addFilterConfigs(vHost.TypedPerFilterConfig,
+			extension.DomainWasmPluginConfigs(plugins, domain))
+	}
+}
+
+// bodyTransformationPlugin returns the WasmPlugin of the proxy transforming the bodies, or nil if none.
+func (s *wasmPluginSelector) bodyTransformationPlugin() *model.WasmPluginWrapper {
+	if alifeatures.BodyTransformationPlugin == "" {
+		return nil
+	}
+	for _, list := range s.wasmPlugins() {
+		for _, p := range list {
+			if p.ResourceName == alifeatures.BodyTransformationPlugin {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// addFilterConfigs adds the per-filter configs to the ones of a route or virtual host, keeping the ones already set,
+// such as those disabling the filters.
+func addFilterConfigs(perFilter, configs map[string]*anypb.Any) map[string]*anypb.Any {
+	for name, filterConfig := range configs {
+		if perFilter == nil {
+			perFilter = make(map[string]*anypb.Any, len(configs))
+		}
+		if _, ok := perFilter[name]; !ok {
+			perFilter[name] = filterConfig
+		}
+	}
+	return perFilter
+}
+
+// fallbackServerName is the stat prefix of the filter chains serving the fallback certificate.
+const fallbackServerName = "fallback"
+
+// buildGatewayFallbackFilterChainOpts returns a filter chain serving the fallback certificate of the gateway to the
+// clients whose SNI matches none of the filter chains of the port, which would otherwise be reset. It is routed as
+// the first HTTPS server of the port using simple TLS, whose TLS settings it shares. It is nil if the gateway has no
+// fallback certificate, if a filter chain already matches all the clients or if all the servers require mutual TLS.
+func (configgen *ConfigGeneratorImpl) buildGatewayFallbackFilterChainOpts(builder *ListenerBuilder,
+	serversForPort *model.MergedServers, mergedGateway *model.MergedGateway, proxyConfig *meshconfig.ProxyConfig,
+	chainOpts []*filterChainOpts,
+) *filterChainOpts {
+	for _, opt := range chainOpts {
+		if opt.isMatchAll() {
+			return nil
+		}
+	}
+	var server *networking.Server
+	for _, s := range serversForPort.Servers {
+		if gatewaytool.IsHTTPSServerWithTLSTermination(s) && s.Tls.Mode == networking.ServerTLSSettings_SIMPLE {
+			server = s
+			break
+		}
+	}
+	if server == nil {
+		return nil
+	}
+	gatewayName := mergedGateway.GatewayNameForServer[server]
+	credentialName := builder.push.FallbackCredentialName(gatewayName)
+	if credentialName == "" {
+		return nil
+	}
+
+	fallbackTLS := proto.Clone(server.Tls).(*networking.ServerTLSSettings)
+	fallbackTLS.CredentialName = credentialName
+	fallbackTLS.ServerCertificate = ""
+	fallbackTLS.PrivateKey = ""
+	fallbackTLS.CaCertificates = ""
+	fallback := &networking.Server{
+		Port:  server.Port,
+		Hosts: []string{constants.GlobalWildcardHost},
+		Tls:   fallbackTLS,
+		Name:  fallbackServerName,
+	}
+	extraOpts := &buildListenerFilterChainExtraOpts{
+		gatewayConfig: builder.push.GetGatewayByName(gatewayName),
+		meshConfig:    builder.push.Mesh,
+		proxyConfig:   proxyConfig,
+	}
+	opt := configgen.createGatewayHTTPFilterChainOpts(builder.node, server.Port, server,
+		mergedGateway.TLSServerInfo[server].RouteName, proxyConfig, istionetworking.TransportProtocolTCP, builder.push, extraOpts)
+	opt.sniHosts = nil
+	opt.tlsContext = buildGatewayListenerTLSContext(builder.push.Mesh, fallback, builder.node,
+		istionetworking.TransportProtocolTCP, extraOpts)
+	opt.httpOpts.statPrefix = fallback.Name
+	return opt
+}
+
+// gatewayPatchForServers returns the patch of the connection manager shared by the servers, the one of the oldest
+// of their gateways that has one.
+func gatewayPatchForServers(node *model.Proxy, push *model.PushContext, servers []*networking.Server) *gatewaypatch.Spec {
+	for _, server := range servers {
+		if spec := push.GatewayPatch(node.MergedGateway.GatewayNameForServer[server]); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
+
+// gatewayClientCertForServers returns how the client certificates of the servers of an HCM are passed to the
+// upstreams, as set by the oldest of their gateways that sets it.
+func gatewayClientCertForServers(node *model.Proxy, push *model.PushContext, servers []*networking.Server) *clientcert.Spec {
+	for _, server := range servers {
+		if spec := push.ClientCert(node.MergedGateway.GatewayNameForServer[server]); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
+
+// gatewayOAuth2Filters returns the filters of the OIDC logins of the gateways of the servers of an HCM, one per
+// gateway, each to its hosts or by default to the hosts of its servers.
+func gatewayOAuth2Filters(node *model.Proxy, push *model.PushContext, servers []*networking.Server) []*hcm.HttpFilter {
+	var gatewayNames []string
+	hostsByGateway := map[string][]string{}
+	for _, server := range servers {
+		name := node.MergedGateway.GatewayNameForServer[server]
+		if _, ok := hostsByGateway[name]; !ok {
+			gatewayNames = append(gatewayNames, name)
+		}
+		hostsByGateway[name] = append(hostsByGateway[name], server.Hosts...)
+	}
+	var filters []*hcm.HttpFilter
+	for _, name := range gatewayNames {
+		spec := push.OIDC(name)
+		if spec == nil {
+			continue
+		}
+		hosts := spec.Hosts
+		if len(hosts) == 0 {
+			hosts = hostsByGateway[name]
+		}
+		filters = append(filters, mseingress.BuildOAuth2Filter(push, name, spec, hosts))
+	}
+	return filters
+}
+
+// gatewayPatchBufferLimit returns the smallest connection buffer limit set by the patches of the gateways of a
+// listener, or zero if there is none.
+func gatewayPatchBufferLimit(push *model.PushContext, gateways []*config.Config) uint32 {
+	var limit uint32
+	for _, gw := range gateways {
+		spec := push.GatewayPatch(gw.Namespace + "/" + gw.Name)
+		if spec == nil || spec.Buffers == nil || spec.Buffers.PerConnectionLimitBytes == 0 {
+			continue
+		}
+		if limit == 0 || spec.Buffers.PerConnectionLimitBytes < limit {
+			limit = spec.Buffers.PerConnectionLimitBytes
+		}
+	}
+	return limit
+}
+
+// gatewayPatchesRoutes returns true if one of the gateways of the proxy patches the headers of its routes, which
+// then depend on the gateway.
+func gatewayPatchesRoutes(node *model.Proxy, push *model.PushContext) bool {
+	seen := sets.New[string]()
+	for _, gatewayName := range node.MergedGateway.GatewayNameForServer {
+		if seen.InsertContains(gatewayName) {
+			continue
+		}
+		if spec := push.GatewayPatch(gatewayName); spec != nil && spec.Headers != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewaySetsAltSvcMaxAge returns true if a gateway of the proxy sets the max age of the alt-svc header of its routes.
+func gatewaySetsAltSvcMaxAge(node *model.Proxy, push *model.PushContext) bool {
+	seen := sets.New[string]()
+	for _, gatewayName := range node.MergedGateway.GatewayNameForServer {
+		if seen.InsertContains(gatewayName) {
+			continue
+		}
+		if push.HTTP3AltSvcMaxAge(gatewayName) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sniForwardProxyServers returns the PASSTHROUGH servers of the proxy whose gateways forward the connections matching no
+// TLS route by a dynamic forward proxy, with the forward proxy of their gateway.
+func sniForwardProxyServers(node *model.Proxy, push *model.PushContext) map[*networking.Server]*sniforwardproxy.Spec {
+	out := map[*networking.Server]*sniforwardproxy.Spec{}
+	for server, gatewayName := range node.MergedGateway.GatewayNameForServer {
+		if server.GetTls().GetMode() != networking.ServerTLSSettings_PASSTHROUGH {
+			continue
+		}
+		if spec := push.SNIForwardProxy(gatewayName); spec != nil {
+			out[server] = spec
+		}
+	}
+	return out
+}
+
+// buildGatewaySNIForwardProxyFilterChainOpts builds the filter chain forwarding the TLS connections to the PASSTHROUGH
+// servers of a port whose SNI is allowed but matches no other filter chain to the host it names, or nil if no gateway
+// of the servers has a forward proxy. The servers of the oldest such gateway set the forward proxy.
+func buildGatewaySNIForwardProxyFilterChainOpts(builder *ListenerBuilder, serversForPort *model.MergedServers,
+	mergedGateway *model.MergedGateway, chainOpts []*filterChainOpts,
+) *filterChainOpts {
+	forwardProxies := sniForwardProxyServers(builder.node, builder.push)
+	var server *networking.Server
+	var spec *sniforwardproxy.Spec
+	for _, s := range serversForPort.Servers {
+		if spec = forwardProxies[s]; spec != nil {
+			server = s
+			break
+		}
+	}
+	if server == nil {
+		return nil
+	}
+
+	var sniHosts []string
+	if !spec.AllowsAnyDomain() {
+		// Envoy rejects the filter chains with the same server names, the explicit TLS routes take precedence.
+		matched := sets.New[string]()
+		for _, opt := range chainOpts {
+			matched.InsertAll(opt.sniHosts...)
+		}
+		for _, domain := range spec.AllowedDomains {
+			if !matched.Contains(domain) {
+				sniHosts = append(sniHosts, domain)
+			}
+		}
+		if len(sniHosts) == 0 {
+			return nil
+		}
+	}
+	opt := &filterChainOpts{
+		sniHosts:          sniHosts,
+		transportProtocol: xdsfilters.TLSTransportProtocol,
+	}
+	for _, other := range chainOpts {
+		if opt.conflictsWith(other) {
+			log.Warnf("skipping sni forward proxy of gateway %s on port %d: conflicting filter chain",
+				mergedGateway.GatewayNameForServer[server], server.Port.Number)
+			return nil
+		}
+	}
+
+	port := spec.Port
+	if port == 0 {
+		port = server.Port.Number
+	}
+	tcpProxy := &tcp.TcpProxy{
+		StatPrefix:       mseingress.SNIForwardProxyClusterName,
+		ClusterSpecifier: &tcp.TcpProxy_Cluster{Cluster: mseingress.SNIForwardProxyClusterName},
+		IdleTimeout:      parseDuration(builder.node.Metadata.IdleTimeout),
+	}
+	opt.networkFilters = []*listener.Filter{
+		mseingress.BuildSNIForwardProxyFilter(port),
+		setAccessLogAndBuildTCPFilter(builder.push, builder.node, tcpProxy, istionetworking.ListenerClassGateway),
+	}
+	return opt
+}
+
+// gatewayProxyProtocol returns the PROXY protocol of the listener of the servers of a port, the one of the oldest of
+// their gateways that has one.
+func gatewayProxyProtocol(push *model.PushContext, mergedGateway *model.MergedGateway,
+	serversForPort *model.MergedServers,
+) *proxyprotocol.Spec {
+	for _, server := range serversForPort.Servers {
+		if spec := push.ProxyProtocol(mergedGateway.GatewayNameForServer[server]); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
+
+// gatewayIPAccess returns the IP access control of the listener of the servers of a port, the one of the oldest of
+// their gateways that has one.
+func gatewayIPAccess(push *model.PushContext, mergedGateway *model.MergedGateway,
+	serversForPort *model.MergedServers,
+) *ipaccess.Spec {
+	for _, server := range serversForPort.Servers {
+		if spec := push.IPAccess(mergedGateway.GatewayNameForServer[server]); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package v1alpha3
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// vhdsConfigSource is the config source of the virtual hosts of the route configurations sent without them.
+var vhdsConfigSource = &route.Vhds{
+	ConfigSource: &core.ConfigSource{
+		ConfigSourceSpecifier: &core.ConfigSource_Ads{
+			Ads: &core.AggregatedConfigSource{},
+		},
+		ResourceApiVersion: core.ApiVersion_V3,
+	},
+}
+
+// BuildVirtualHosts builds the route configurations of the gateway with all their virtual hosts, which VHDS serves
+// on demand. The route configurations not found are skipped.
+func (configgen *ConfigGeneratorImpl) BuildVirtualHosts(node *model.Proxy, req *model.PushRequest,
+	routeNames []string,
+) []*route.RouteConfiguration {
+	if node.Type != model.Router || node.MergedGateway == nil {
+		return nil
+	}
+	vsCache := make(map[int][]virtualServiceContext)
+	efw := req.Push.EnvoyFilters(node)
+	out := make([]*route.RouteConfiguration, 0, len(routeNames))
+	for _, routeName := range routeNames {
+		if rc := configgen.buildGatewayRouteConfiguration(node, req, routeName, vsCache, efw); rc != nil {
+			out = append(out, rc)
+		}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package v1alpha3
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/test"
+)
+
+func TestBuildVirtualHosts(t *testing.T) {
+	test.SetForTest(t, &alifeatures.EnableVHDS, true)
+	test.SetForTest(t, &alifeatures.EnableScopedRDS, false)
+	cg := NewConfigGenTest(t, TestOptions{
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: not-default
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: vs
+  namespace: not-default
+spec:
+  hosts:
+  - www.example.com
+  gateways:
+  - gateway
+  http:
+  - route:
+    - destination:
+        host: backend.example.com
+        port:
+          number: 80
+`,
+	})
+	routes := func(proxy *model.Proxy) *route.RouteConfiguration {
+		t.Helper()
+		resources, _ := cg.ConfigGen.BuildHTTPRoutes(proxy, &model.PushRequest{Push: cg.PushContext()}, []string{"http.80"})
+		return xdstest.UnmarshalAny[route.RouteConfiguration](t, resources[0].GetResource())
+	}
+
+	// The gateways connected with the state of the world protocol get their virtual hosts with the route
+	// configurations.
+	sotw := proxyGateway
+	if rc := routes(cg.SetupProxy(&sotw)); len(rc.VirtualHosts) == 0 || rc.Vhds != nil {
+		t.Fatalf("got route configuration %v, want its virtual hosts", rc)
+	}
+
+	delta := proxyGateway
+	delta.DeltaXds = true
+	proxy := cg.SetupProxy(&delta)
+	if rc := routes(proxy); len(rc.VirtualHosts) != 0 || rc.Vhds == nil {
+		t.Fatalf("got route configuration %v, want its virtual hosts left to VHDS", rc)
+	}
+	rcs := cg.ConfigGen.BuildVirtualHosts(proxy, &model.PushRequest{Push: cg.PushContext()}, []string{"http.80", "http.81"})
+	if len(rcs) != 1 || rcs[0].Name != "http.80" || len(rcs[0].VirtualHosts) == 0 {
+		t.Fatalf("got route configurations %v, want http.80 with its virtual hosts", rcs)
+	}
+}
@@ -38,11 +38,14 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
 	"istio.io/istio/pilot/pkg/networking/telemetry"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/ali/config/clusterdefaults"
+	"istio.io/istio/pkg/ali/config/lbpolicy"
 	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
@@ -285,6 +288,22 @@ func (configgen *ConfigGeneratorImpl) buildClusters(proxy *model.Proxy, req *mod
 	if proxy.Metadata != nil && proxy.Metadata.Raw[security.CredentialMetaDataName] == "true" {
 		clusters = append(clusters, cb.buildExternalSDSCluster(security.CredentialNameSocketPath))
 	}
+
+	// Added by ingress
+	clusters = append(clusters, cb.buildWasmSharedServiceClusters(req.Push.WasmPlugins(proxy))...)
+	if proxy.Type == model.Router {
+		if c := cb.buildRateLimitServiceCluster(); c != nil {
+			clusters = append(clusters, c)
+		}
+		if c := cb.buildExtProcServiceCluster(); c != nil {
+			clusters = append(clusters, c)
+		}
+		if proxy.MergedGateway != nil && len(sniForwardProxyServers(proxy, req.Push)) > 0 {
+			clusters = append(clusters, mseingress.BuildSNIForwardProxyCluster(req.Push.Mesh.ConnectTimeout))
+		}
+	}
+	// End added by ingress
+
 	for _, c := range clusters {
 		resources = append(resources, &discovery.Resource{Name: c.Name, Resource: protoconv.MessageToAny(c)})
 	}
@@ -766,6 +785,14 @@ type buildClusterOpts struct {
 	serviceRegistry provider.ID
 	// Indicates if the destionationRule has a workloadSelector
 	isDrWithSelector bool
+	// Added by ingress
+	// The retry budget of the outbound clusters, replacing the max retries of their circuit breakers.
+	retryBudget *clusterdefaults.RetryBudget
+	// The version of the PROXY protocol header sent to the hosts of the outbound clusters, if any.
+	upstreamProxyProtocol string
+	// The load balancing policy of the outbound clusters replacing their simple load balancer, if any.
+	lbPolicy *lbpolicy.Spec
+	// End added by ingress
 }
 
 func applyTCPKeepalive(mesh *meshconfig.MeshConfig, c *cluster.Cluster, tcp *networking.ConnectionPoolSettings_TCPSettings) {
@@ -883,7 +910,10 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 	if features.SendUnhealthyEndpoints.Load() {
 		c.CommonLbConfig.HealthyPanicThreshold = &xdstype.Percent{Value: 0}
 	}
-	localityLbSetting := loadbalancer.GetLocalityLbSetting(meshConfig.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+	// Modified by ingress
+	_, _, hostname, _ := model.ParseSubsetKey(c.Name)
+	localityLbSetting := loadbalancer.GetLocalityLbSetting(loadbalancer.GetMeshLocalityLbSetting(meshConfig, hostname), lb.GetLocalityLbSetting())
+	// End modified by ingress
 	if localityLbSetting != nil {
 		c.CommonLbConfig.LocalityConfigSpecifier = &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig_{
 			LocalityWeightedLbConfig: &cluster.Cluster_CommonLbConfig_LocalityWeightedLbConfig{},
@@ -38,6 +38,7 @@ import (
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
 	"istio.io/istio/pilot/pkg/networking/telemetry"
 	"istio.io/istio/pilot/pkg/networking/util"
 	authn_model "istio.io/istio/pilot/pkg/security/model"
@@ -257,6 +258,12 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *MutableCluster, clusterMode C
 	destinationRule := CastDestinationRule(destRule)
 	// merge applicable port level traffic policy settings
 	trafficPolicy := MergeTrafficPolicy(nil, destinationRule.GetTrafficPolicy(), port)
+	// Added by ingress
+	if cb.proxyType == model.Router {
+		trafficPolicy = mseingress.ApplyBackendTLSPolicy(trafficPolicy, service, port)
+	}
+	trafficPolicy = mseingress.ApplyClusterDefaults(mseingress.ClusterDefaults(), trafficPolicy)
+	// End added by ingress
 	opts := buildClusterOpts{
 		mesh:             cb.req.Push.Mesh,
 		serviceInstances: cb.serviceInstances,
@@ -265,6 +272,11 @@ func (cb *ClusterBuilder) applyDestinationRule(mc *MutableCluster, clusterMode C
 		port:             port,
 		clusterMode:      clusterMode,
 		direction:        model.TrafficDirectionOutbound,
+		// Added by ingress
+		retryBudget:           mseingress.RetryBudget(mseingress.ClusterDefaults(), destRule),
+		upstreamProxyProtocol: mseingress.UpstreamProxyProtocol(destRule),
+		lbPolicy:              mseingress.LoadBalancerPolicy(destRule),
+		// End added by ingress
 	}
 
 	if clusterMode == DefaultClusterMode {
@@ -399,6 +411,9 @@ func (cb *ClusterBuilder) buildDefaultCluster(name string, discoveryType cluster
 		dnsRate := cb.req.Push.Mesh.DnsRefreshRate
 		c.DnsRefreshRate = dnsRate
 		c.RespectDnsTtl = true
+		// Added by ingress
+		mseingress.ApplyDNSResolvers(mseingress.DNSResolvers(service), c)
+		// End added by ingress
 		fallthrough
 	case cluster.Cluster_STATIC:
 		if len(localityLbEndpoints) == 0 {
@@ -789,14 +804,23 @@ func (cb *ClusterBuilder) applyTrafficPolicy(opts buildClusterOpts) {
 	cb.applyConnectionPool(opts.mesh, opts.mutable, connectionPool)
 	if opts.direction != model.TrafficDirectionInbound {
 		cb.applyH2Upgrade(opts, connectionPool)
+		// Added by ingress
+		mseingress.ApplyRetryBudget(opts.retryBudget, opts.mutable.cluster)
+		// End added by ingress
 		applyOutlierDetection(opts.mutable.cluster, outlierDetection)
 		applyLoadBalancer(opts.mutable.cluster, loadBalancer, opts.port, cb.locality, cb.proxyLabels, opts.mesh)
+		// Added by ingress
+		mseingress.ApplyLoadBalancerPolicy(opts.lbPolicy, opts.mutable.cluster)
+		// End added by ingress
 		if opts.clusterMode != SniDnatClusterMode {
 			autoMTLSEnabled := opts.mesh.GetEnableAutoMtls().Value
 			tls, mtlsCtxType := cb.buildAutoMtlsSettings(tls, opts.serviceAccounts, opts.istioMtlsSni,
 				autoMTLSEnabled, opts.meshExternal, opts.serviceMTLSMode)
 			cb.applyUpstreamTLSSettings(&opts, tls, mtlsCtxType)
 		}
+		// Added by ingress
+		mseingress.ApplyUpstreamProxyProtocol(opts.upstreamProxyProtocol, opts.mutable.cluster)
+		// End added by ingress
 	}
 
 	if opts.mutable.cluster.GetType() == cluster.Cluster_ORIGINAL_DST {
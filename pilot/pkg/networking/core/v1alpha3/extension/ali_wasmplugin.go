@@ -0,0 +1,187 @@
+package extension
+
+import (
+	"strings"
+
+	xds "github.com/cncf/xds/go/xds/core/v3"
+	matcher "github.com/cncf/xds/go/xds/type/matcher/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	action "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/matcher/action/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	ssl "github.com/envoyproxy/go-control-plane/envoy/extensions/matching/common_inputs/ssl/v3"
+	envoywasm "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	envoymatcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config/host"
+)
+
+var (
+	peerAuthenticated = singlePredicate(&xds.TypedExtensionConfig{
+		Name:        "uri-san",
+		TypedConfig: protoconv.MessageToAny(&ssl.UriSanInput{}),
+	}, ".+")
+	// bearerToken matches the requests carrying a JWT where the JWTs are looked for by default. Invalid JWTs are
+	// rejected by the authentication filters after the AUTHN phase.
+	bearerToken = singlePredicate(&xds.TypedExtensionConfig{
+		Name:        "authorization",
+		TypedConfig: protoconv.MessageToAny(&envoymatcher.HttpRequestHeaderMatchInput{HeaderName: "authorization"}),
+	}, "(?i)^bearer .+")
+	authenticated = &matcher.Matcher_MatcherList_Predicate{
+		MatchType: &matcher.Matcher_MatcherList_Predicate_OrMatcher{
+			OrMatcher: &matcher.Matcher_MatcherList_Predicate_PredicateList{
+				Predicate: []*matcher.Matcher_MatcherList_Predicate{peerAuthenticated, bearerToken},
+			},
+		},
+	}
+)
+
+func singlePredicate(input *xds.TypedExtensionConfig, regex string) *matcher.Matcher_MatcherList_Predicate {
+	return &matcher.Matcher_MatcherList_Predicate{
+		MatchType: &matcher.Matcher_MatcherList_Predicate_SinglePredicate_{
+			SinglePredicate: &matcher.Matcher_MatcherList_Predicate_SinglePredicate{
+				Input: input,
+				Matcher: &matcher.Matcher_MatcherList_Predicate_SinglePredicate_ValueMatch{
+					ValueMatch: &matcher.StringMatcher{
+						MatchPattern: &matcher.StringMatcher_SafeRegex{
+							SafeRegex: &matcher.RegexMatcher{
+								EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+								Regex:      regex,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func notPredicate(predicate *matcher.Matcher_MatcherList_Predicate) *matcher.Matcher_MatcherList_Predicate {
+	return &matcher.Matcher_MatcherList_Predicate{
+		MatchType: &matcher.Matcher_MatcherList_Predicate_NotMatcher{NotMatcher: predicate},
+	}
+}
+
+// skipPredicate returns the predicate of the requests a plugin does not run for, or nil if it runs for all.
+func skipPredicate(match model.AuthenticationMatch) *matcher.Matcher_MatcherList_Predicate {
+	switch match {
+	case model.AuthenticationMatchAuthenticated:
+		return notPredicate(authenticated)
+	case model.AuthenticationMatchUnauthenticated:
+		return authenticated
+	case model.AuthenticationMatchPeerAuthenticated:
+		return notPredicate(peerAuthenticated)
+	case model.AuthenticationMatchPeerUnauthenticated:
+		return peerAuthenticated
+	}
+	return nil
+}
+
+// withAuthenticationMatch wraps the extension config of the plugin with a matcher skipping it for the requests not
+// in the authentication state it runs for, if any.
+func withAuthenticationMatch(wasmPlugin *model.WasmPluginWrapper, ec *core.TypedExtensionConfig) *core.TypedExtensionConfig {
+	predicate := skipPredicate(wasmPlugin.AuthenticationMatch)
+	if predicate == nil {
+		return ec
+	}
+	return &core.TypedExtensionConfig{
+		Name: ec.Name,
+		TypedConfig: protoconv.MessageToAny(&matching.ExtensionWithMatcher{
+			ExtensionConfig: ec,
+			XdsMatcher: &matcher.Matcher{
+				MatcherType: &matcher.Matcher_MatcherList_{
+					MatcherList: &matcher.Matcher_MatcherList{
+						Matchers: []*matcher.Matcher_MatcherList_FieldMatcher{{
+							Predicate: predicate,
+							OnMatch: &matcher.Matcher_OnMatch{
+								OnMatch: &matcher.Matcher_OnMatch_Action{
+									Action: &xds.TypedExtensionConfig{
+										Name:        "skip",
+										TypedConfig: protoconv.MessageToAny(&action.SkipFilter{}),
+									},
+								},
+							},
+						}},
+					},
+				},
+			},
+		}),
+	}
+}
+
+// DomainWasmPluginConfigs returns the per-filter configs overriding the configuration of the WasmPlugins on the
+// virtual host of the domain, from the first of their match rules matching it.
+func DomainWasmPluginConfigs(
+	wasmPlugins map[extensions.PluginPhase][]*model.WasmPluginWrapper,
+	domain string,
+) map[string]*anypb.Any {
+	return matchRuleConfigs(wasmPlugins, func(rule *model.WasmMatchRule) bool {
+		for _, d := range rule.Domains {
+			if host.Name(domain).SubsetOf(host.Name(d)) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RouteWasmPluginConfigs returns the per-filter configs overriding the configuration of the WasmPlugins on the
+// route, from the first of their match rules with a prefix of the path it matches. Regex routes match no rule.
+func RouteWasmPluginConfigs(
+	wasmPlugins map[extensions.PluginPhase][]*model.WasmPluginWrapper,
+	r *route.Route,
+) map[string]*anypb.Any {
+	var path string
+	switch m := r.GetMatch().GetPathSpecifier().(type) {
+	case *route.RouteMatch_Prefix:
+		path = m.Prefix
+	case *route.RouteMatch_Path:
+		path = m.Path
+	case *route.RouteMatch_PathSeparatedPrefix:
+		path = m.PathSeparatedPrefix
+	default:
+		return nil
+	}
+	return matchRuleConfigs(wasmPlugins, func(rule *model.WasmMatchRule) bool {
+		for _, prefix := range rule.RoutePrefixes {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func matchRuleConfigs(
+	wasmPlugins map[extensions.PluginPhase][]*model.WasmPluginWrapper,
+	matches func(rule *model.WasmMatchRule) bool,
+) map[string]*anypb.Any {
+	var configs map[string]*anypb.Any
+	for _, list := range wasmPlugins {
+		for _, p := range list {
+			for _, rule := range p.MatchRules {
+				if !matches(rule) {
+					continue
+				}
+				if configs == nil {
+					configs = map[string]*anypb.Any{}
+				}
+				configs[p.ResourceName] = protoconv.MessageToAny(&wasm.Wasm{
+					Config: &envoywasm.PluginConfig{
+						Name:          p.ResourceName,
+						RootId:        p.PluginName,
+						Configuration: rule.Configuration,
+						FailOpen:      p.FailStrategy == extensions.FailStrategy_FAIL_OPEN,
+					},
+				})
+				break
+			}
+		}
+	}
+	return configs
+}
@@ -15,7 +15,10 @@
 package extension
 
 import (
+	"strings"
+
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	composite_v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/composite/v3"
 	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
@@ -67,13 +70,14 @@ func toEnvoyHTTPFilter(wasmPlugin *model.WasmPluginWrapper) *hcm.HttpFilter {
 			Name: wasmPlugin.ResourceName,
 			ConfigType: &hcm.HttpFilter_ConfigDiscovery{
 				ConfigDiscovery: &core.ExtensionConfigSource{
-					ConfigSource:                     defaultConfigSource,
+					ConfigSource:                     configSource(wasmPlugin),
 					ApplyDefaultConfigWithoutWarming: false,
 					DefaultConfig:                    defaultConfig,
 					TypeUrls: []string{
 						xds.WasmHTTPFilterType,
 						xds.RBACHTTPFilterType,
 						"type.googleapis.com/" + compositeFilterType,
+						xds.ExtensionWithMatcherType,
 					},
 				},
 			},
@@ -84,10 +88,11 @@ func toEnvoyHTTPFilter(wasmPlugin *model.WasmPluginWrapper) *hcm.HttpFilter {
 		Name: wasmPlugin.ResourceName,
 		ConfigType: &hcm.HttpFilter_ConfigDiscovery{
 			ConfigDiscovery: &core.ExtensionConfigSource{
-				ConfigSource: defaultConfigSource,
+				ConfigSource: configSource(wasmPlugin), // Modified by ingress
 				TypeUrls: []string{
 					xds.WasmHTTPFilterType,
 					xds.RBACHTTPFilterType,
+					xds.ExtensionWithMatcherType, // Added by ingress
 				},
 			},
 		},
@@ -130,8 +135,57 @@ func InsertedExtensionConfigurations(
 				Name:        p.ResourceName,
 				TypedConfig: typedConfig,
 			}
+			ec = withAuthenticationMatch(p, ec) // Added by ingress
 			result = append(result, ec)
 		}
 	}
 	return result
 }
+
+// Added by Ingress
+
+// configSource returns the config source of the ECDS config of the plugin, which blocks the listeners forever
+// unless the plugin sets an initial fetch timeout.
+func configSource(wasmPlugin *model.WasmPluginWrapper) *core.ConfigSource {
+	if wasmPlugin.InitialFetchTimeout == nil {
+		return defaultConfigSource
+	}
+	source := proto.Clone(defaultConfigSource).(*core.ConfigSource)
+	source.InitialFetchTimeout = wasmPlugin.InitialFetchTimeout
+	return source
+}
+
+// ParseWasmPluginSelection parses the WasmPlugins selected by the higress.io/wasm-plugins annotation: a comma
+// separated list of names, each optionally qualified by its namespace as namespace/name.
+func ParseWasmPluginSelection(value string) sets.String {
+	selected := sets.New[string]()
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected.Insert(name)
+		}
+	}
+	return selected
+}
+
+// DisabledWasmPluginFilters returns the per-filter configs disabling the WasmPlugins not selected, so that only the
+// selected plugins apply to the routes or virtual hosts they are set on.
+func DisabledWasmPluginFilters(
+	wasmPlugins map[extensions.PluginPhase][]*model.WasmPluginWrapper,
+	selected sets.String,
+) map[string]*anypb.Any {
+	var disabled map[string]*anypb.Any
+	for _, list := range wasmPlugins {
+		for _, p := range list {
+			if selected.Contains(p.Name) || selected.Contains(p.Namespace+"/"+p.Name) {
+				continue
+			}
+			if disabled == nil {
+				disabled = map[string]*anypb.Any{}
+			}
+			disabled[p.ResourceName] = protoconv.MessageToAny(&route.FilterConfig{Disabled: true})
+		}
+	}
+	return disabled
+}
+
+// End added by Ingress
@@ -16,16 +16,25 @@ package extension
 
 import (
 	"testing"
+	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
 	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	wasmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	matcherv3 "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	extensions "istio.io/api/extensions/v1alpha1"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config/xds"
 )
 
 var (
@@ -87,3 +96,190 @@ func TestInsertedExtensionConfigurations(t *testing.T) {
 		})
 	}
 }
+
+func TestDisabledWasmPluginFilters(t *testing.T) {
+	wasmPlugins := map[extensions.PluginPhase][]*model.WasmPluginWrapper{
+		extensions.PluginPhase_AUTHN: {someAuthNFilter},
+		extensions.PluginPhase_AUTHZ: {someAuthZFilter},
+	}
+	disabled := protoconv.MessageToAny(&route.FilterConfig{Disabled: true})
+	testCases := []struct {
+		name     string
+		selected string
+		expected map[string]*anypb.Any
+	}{
+		{
+			name:     "none selected",
+			selected: "",
+			expected: map[string]*anypb.Any{
+				"istio-system.someAuthNFilter": disabled,
+				"istio-system.someAuthZFilter": disabled,
+			},
+		},
+		{
+			name:     "selected by name",
+			selected: "someAuthNFilter, unknown",
+			expected: map[string]*anypb.Any{
+				"istio-system.someAuthZFilter": disabled,
+			},
+		},
+		{
+			name:     "selected by namespace and name",
+			selected: "istio-system/someAuthZFilter,default/someAuthNFilter",
+			expected: map[string]*anypb.Any{
+				"istio-system.someAuthNFilter": disabled,
+			},
+		},
+		{
+			name:     "all selected",
+			selected: "someAuthNFilter,istio-system/someAuthZFilter",
+			expected: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DisabledWasmPluginFilters(wasmPlugins, ParseWasmPluginSelection(tc.selected))
+			if diff := cmp.Diff(tc.expected, got, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestInitialFetchTimeout(t *testing.T) {
+	plugin := &model.WasmPluginWrapper{
+		Name:                "plugin",
+		Namespace:           "istio-system",
+		ResourceName:        "istio-system.plugin",
+		WasmPlugin:          &extensions.WasmPlugin{FailStrategy: extensions.FailStrategy_FAIL_OPEN},
+		InitialFetchTimeout: durationpb.New(5 * time.Second),
+	}
+	got := toEnvoyHTTPFilter(plugin).GetConfigDiscovery().GetConfigSource().GetInitialFetchTimeout()
+	if got.AsDuration() != 5*time.Second {
+		t.Errorf("got initial fetch timeout %v, want 5s", got)
+	}
+	plugin.WasmPlugin.FailStrategy = extensions.FailStrategy_FAIL_CLOSE
+	got = toEnvoyHTTPFilter(plugin).GetConfigDiscovery().GetConfigSource().GetInitialFetchTimeout()
+	if got.AsDuration() != 5*time.Second {
+		t.Errorf("got initial fetch timeout %v, want 5s", got)
+	}
+	// The default config source is shared by the plugins without a timeout, and must not be changed.
+	if defaultConfigSource.InitialFetchTimeout.AsDuration() != 0 {
+		t.Errorf("default config source changed to %v", defaultConfigSource.InitialFetchTimeout)
+	}
+	plugin.InitialFetchTimeout = nil
+	if got := toEnvoyHTTPFilter(plugin).GetConfigDiscovery().GetConfigSource(); got != defaultConfigSource {
+		t.Errorf("got config source %v, want the default one", got)
+	}
+}
+
+func TestAuthenticationMatch(t *testing.T) {
+	plugin := &model.WasmPluginWrapper{
+		Name:                "plugin",
+		Namespace:           "istio-system",
+		ResourceName:        "istio-system.plugin",
+		WasmPlugin:          &extensions.WasmPlugin{Phase: extensions.PluginPhase_AUTHN},
+		WasmExtensionConfig: &wasm.Wasm{},
+		AuthenticationMatch: model.AuthenticationMatchUnauthenticated,
+	}
+	wasmPlugins := map[extensions.PluginPhase][]*model.WasmPluginWrapper{extensions.PluginPhase_AUTHN: {plugin}}
+	got := InsertedExtensionConfigurations(wasmPlugins, []string{plugin.ResourceName}, nil)
+	if len(got) != 1 || got[0].Name != plugin.ResourceName {
+		t.Fatalf("got extension configs %v", got)
+	}
+	m := &matching.ExtensionWithMatcher{}
+	if err := got[0].TypedConfig.UnmarshalTo(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.ExtensionConfig.TypedConfig.TypeUrl != xds.WasmHTTPFilterType {
+		t.Errorf("got wrapped config %v, want the Wasm config", m.ExtensionConfig)
+	}
+	// The plugin is skipped for the authenticated requests.
+	predicate := m.XdsMatcher.GetMatcherList().GetMatchers()[0].GetPredicate()
+	if !proto.Equal(predicate, authenticated) {
+		t.Errorf("got skip predicate %v, want %v", predicate, authenticated)
+	}
+
+	plugin.AuthenticationMatch = ""
+	got = InsertedExtensionConfigurations(wasmPlugins, []string{plugin.ResourceName}, nil)
+	if got[0].TypedConfig.TypeUrl != xds.WasmHTTPFilterType {
+		t.Errorf("got extension config %v, want the Wasm config", got[0])
+	}
+}
+
+func TestMatchRuleWasmPluginConfigs(t *testing.T) {
+	domainConfig := protoconv.MessageToAny(&wrappers.StringValue{Value: `{"domain":true}`})
+	routeConfig := protoconv.MessageToAny(&wrappers.StringValue{Value: `{"route":true}`})
+	plugin := &model.WasmPluginWrapper{
+		Name:         "plugin",
+		Namespace:    "istio-system",
+		ResourceName: "istio-system.plugin",
+		WasmPlugin:   &extensions.WasmPlugin{PluginName: "root"},
+		MatchRules: []*model.WasmMatchRule{
+			{Domains: []string{"*.example.com"}, Configuration: domainConfig},
+			{RoutePrefixes: []string{"/api"}, Configuration: routeConfig},
+		},
+	}
+	wasmPlugins := map[extensions.PluginPhase][]*model.WasmPluginWrapper{
+		extensions.PluginPhase_AUTHN: {plugin, someAuthNFilter},
+	}
+	perFilterConfig := func(configuration *anypb.Any) map[string]*anypb.Any {
+		return map[string]*anypb.Any{
+			"istio-system.plugin": protoconv.MessageToAny(&wasm.Wasm{
+				Config: &wasmv3.PluginConfig{Name: "istio-system.plugin", RootId: "root", Configuration: configuration},
+			}),
+		}
+	}
+
+	domains := []struct {
+		domain   string
+		expected map[string]*anypb.Any
+	}{
+		{"foo.example.com", perFilterConfig(domainConfig)},
+		{"*.foo.example.com", perFilterConfig(domainConfig)},
+		{"example.com", nil},
+	}
+	for _, tc := range domains {
+		t.Run(tc.domain, func(t *testing.T) {
+			got := DomainWasmPluginConfigs(wasmPlugins, tc.domain)
+			if diff := cmp.Diff(tc.expected, got, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	routes := []struct {
+		name     string
+		match    *route.RouteMatch
+		expected map[string]*anypb.Any
+	}{
+		{
+			name:     "prefix",
+			match:    &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/api/v1"}},
+			expected: perFilterConfig(routeConfig),
+		},
+		{
+			name:     "path",
+			match:    &route.RouteMatch{PathSpecifier: &route.RouteMatch_Path{Path: "/api"}},
+			expected: perFilterConfig(routeConfig),
+		},
+		{
+			name:  "other prefix",
+			match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+		},
+		{
+			name: "regex",
+			match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_SafeRegex{
+				SafeRegex: &matcherv3.RegexMatcher{Regex: "/api/.*"},
+			}},
+		},
+	}
+	for _, tc := range routes {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RouteWasmPluginConfigs(wasmPlugins, &route.Route{Match: tc.match})
+			if diff := cmp.Diff(tc.expected, got, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	anypb "github.com/golang/protobuf/ptypes/any"
 	"github.com/hashicorp/go-multierror"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
@@ -232,6 +233,9 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 			if serversForPort == nil {
 				continue
 			}
+			// Added by ingress
+			opts.proxyProtocol = gatewayProxyProtocol(builder.push, mergedGateway, serversForPort)
+			// End added by ingress
 
 			var gateways []*config.Config
 			for _, s := range serversForPort.Servers {
@@ -284,7 +288,15 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 				newFilterChains = configgen.buildGatewayHTTP3FilterChains(builder, serversForPort, mergedGateway, proxyConfig, opts)
 			}
 
+			// Added by ingress
+			ipAccessFilter := mseingress.BuildIPAccessNetworkFilter(gatewayIPAccess(builder.push, mergedGateway, serversForPort))
+			// End added by ingress
 			for cnum := range newFilterChains {
+				// Added by ingress
+				if ipAccessFilter != nil && newFilterChains[cnum].TransportProtocol == istionetworking.TransportProtocolTCP {
+					newFilterChains[cnum].TCP = append([]*listener.Filter{ipAccessFilter}, newFilterChains[cnum].TCP...)
+				}
+				// End added by ingress
 				// update by ingress
 				if util.IsIstioVersionGE117(builder.node.IstioVersion) && alifeatures.EnableLDSAuthnFilter {
 					newFilterChains[cnum].TCP = append(newFilterChains[cnum].TCP, xdsfilters.IstioNetworkAuthenticationFilter)
@@ -312,6 +324,11 @@ func (configgen *ConfigGeneratorImpl) buildGatewayListeners(builder *ListenerBui
 	}
 	for _, ml := range mutableopts {
 		ml.mutable.Listener = buildGatewayListener(*ml.opts, ml.transport)
+		// Added by ingress
+		if limit := gatewayPatchBufferLimit(builder.push, gatewaysByListenerName[ml.mutable.Listener.Name]); limit > 0 {
+			ml.mutable.Listener.PerConnectionBufferLimitBytes = wrappers.UInt32(limit)
+		}
+		// End added by ingress
 		log.Debugf("buildGatewayListeners: marshaling listener %q with %d filter chains",
 			ml.mutable.Listener.GetName(), len(ml.mutable.Listener.GetFilterChains()))
 
@@ -413,6 +430,23 @@ func (configgen *ConfigGeneratorImpl) buildGatewayTCPBasedFilterChains(
 			}
 		}
 
+		// Added by ingress
+		if forwardProxy := buildGatewaySNIForwardProxyFilterChainOpts(builder, serversForPort, mergedGateway,
+			tcpFilterChainOpts); forwardProxy != nil {
+			tcpFilterChainOpts = append(tcpFilterChainOpts, forwardProxy)
+			newFilterChains = append(newFilterChains, istionetworking.FilterChain{
+				ListenerProtocol: istionetworking.ListenerProtocolTCP,
+			})
+		}
+		if fallback := configgen.buildGatewayFallbackFilterChainOpts(builder, serversForPort, mergedGateway,
+			proxyConfig, tcpFilterChainOpts); fallback != nil {
+			tcpFilterChainOpts = append(tcpFilterChainOpts, fallback)
+			newFilterChains = append(newFilterChains, istionetworking.FilterChain{
+				ListenerProtocol: istionetworking.ListenerProtocolHTTP,
+			})
+		}
+		// End added by ingress
+
 		opts.filterChainOpts = tcpFilterChainOpts
 	}
 	return newFilterChains
@@ -627,8 +661,18 @@ func (configgen *ConfigGeneratorImpl) buildHostRDSConfig(
 	var vsDependent []config.Config
 
 	cacheable := true
+	wasmPlugins := newWasmPluginSelector(node, push)
+	// The routes of gateways patching their headers or setting the max age of their alt-svc header change with the
+	// gateways, which are not tracked.
+	if gatewayPatchesRoutes(node, push) || gatewaySetsAltSvcMaxAge(node, push) {
+		cacheable = false
+	}
 
 	for _, vs := range hostVs {
+		// The routes of virtual services selecting WasmPlugins change with the WasmPlugins, which are not tracked.
+		if wasmPlugins.selects(vs) {
+			cacheable = false
+		}
 		vsSpec := vs.Spec.(*networking.VirtualService)
 		for _, vsHttpRoute := range vsSpec.Http {
 			// check if dynamic port exists, we should not cache RDS
@@ -814,6 +858,9 @@ func (configgen *ConfigGeneratorImpl) buildHostRDSConfig(
 				IsTLS:                     server.Tls != nil,
 				IsHTTP3AltSvcHeaderNeeded: isH3DiscoveryNeeded,
 				Mesh:                      push.Mesh,
+				// Added by ingress
+				HTTP3AltSvcMaxAge: push.HTTP3AltSvcMaxAge(gatewayName),
+				// End added by ingress
 			}
 			hashByDestination := istio_route.GetConsistentHashForVirtualService(push, node, virtualService)
 			routes, err = istio_route.BuildHTTPRoutesForVirtualServiceWithHTTPFilters(node, virtualService, nameToServiceMap,
@@ -822,6 +869,20 @@ func (configgen *ConfigGeneratorImpl) buildHostRDSConfig(
 				log.Debugf("%s omitting routes for virtual service %v/%v due to error: %v", node.ID, virtualService.Namespace, virtualService.Name, err)
 				continue
 			}
+			wasmPlugins.apply(virtualService, gatewayName, routes)
+			// Added by ingress
+			mseingress.ApplyLocalRateLimitAnnotation(virtualService, routes)
+			mseingress.ApplyRateLimitAnnotation(virtualService, routes)
+			mseingress.ApplyExtProcAnnotation(virtualService, routes)
+			mseingress.ApplyGrpcJSONTranscodingAnnotation(virtualService, push, routes)
+			mseingress.ApplyTracingSamplingAnnotation(virtualService, routes)
+			mseingress.ApplyStreamingTimeouts(virtualService, routes)
+			mseingress.ApplyRequestBufferingAnnotation(virtualService, routes)
+			mseingress.ApplyIPAccessAnnotation(globalHTTPFilters, virtualService, routes)
+			routes = mseingress.ApplyCanaryAnnotation(virtualService, routes)
+			mseingress.ApplyBodyTransformationAnnotation(virtualService, wasmPlugins.bodyTransformationPlugin(), routes)
+			mseingress.ApplyGatewayPatchToRoutes(push.GatewayPatch(gatewayName), routes)
+			// End added by ingress
 			gatewayRoutes[gatewayName][vskey] = routes
 		}
 
@@ -858,6 +919,7 @@ func (configgen *ConfigGeneratorImpl) buildHostRDSConfig(
 				TypedPerFilterConfig:       mseingress.ConstructTypedPerFilterConfigForVHost(globalHTTPFilters, virtualService),
 				IncludeRequestAttemptCount: ph.IncludeRequestAttemptCount,
 			}
+			wasmPlugins.applyDomain(vHost) // Added by ingress
 			if server.Tls != nil && server.Tls.HttpsRedirect {
 				vHost.RequireTls = route.VirtualHost_ALL
 			}
@@ -960,7 +1022,6 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 		return nil, false
 	}
 	// Added by ingress
-	push := req.Push
 	if strings.HasPrefix(routeName, constants.HigressHostRDSNamePrefix) {
 		resource, cacheHit := configgen.buildHostRDSConfig(node, req, routeName, vsCache, efw, efKeys)
 		if resource == nil {
@@ -968,6 +1029,31 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 		}
 		return resource, cacheHit
 	}
+	routeCfg := configgen.buildGatewayRouteConfiguration(node, req, routeName, vsCache, efw)
+	if routeCfg == nil {
+		return nil, false
+	}
+	if node.VHDSEnabled() {
+		// The virtual hosts are requested on demand through VHDS.
+		routeCfg.VirtualHosts = nil
+		routeCfg.Vhds = vhdsConfigSource
+	}
+	resource := &discovery.Resource{
+		Name:     routeName,
+		Resource: protoconv.MessageToAny(routeCfg),
+	}
+	return resource, false
+}
+
+// buildGatewayRouteConfiguration builds the route configuration of the gateway with all its virtual hosts.
+func (configgen *ConfigGeneratorImpl) buildGatewayRouteConfiguration(
+	node *model.Proxy,
+	req *model.PushRequest,
+	routeName string,
+	vsCache map[int][]virtualServiceContext,
+	efw *model.EnvoyFilterWrapper,
+) *route.RouteConfiguration {
+	push := req.Push
 	// End added by ingress
 
 	ph := GetProxyHeaders(node, push, istionetworking.ListenerClassGateway)
@@ -980,13 +1066,14 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 
 		// This can happen when a gateway has recently been deleted. Envoy will still request route
 		// information due to the draining of listeners, so we should not return an error.
-		return nil, false
+		return nil // Modified by ingress
 	}
 
 	servers := merged.ServersByRouteName[routeName]
 
 	// Add by ingress
 	globalHTTPFilters := mseingress.ExtractGlobalHTTPFilters(node, push)
+	wasmPlugins := newWasmPluginSelector(node, push)
 	// End add by ingress
 
 	// When this is true, we add alt-svc header to the response to tell the client
@@ -1039,6 +1126,9 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 					IsTLS:                     server.Tls != nil,
 					IsHTTP3AltSvcHeaderNeeded: isH3DiscoveryNeeded,
 					Mesh:                      push.Mesh,
+					// Added by ingress
+					HTTP3AltSvcMaxAge: push.HTTP3AltSvcMaxAge(gatewayName),
+					// End added by ingress
 				}
 				hashByDestination := istio_route.GetConsistentHashForVirtualService(push, node, virtualService)
 				// update by ingress
@@ -1049,6 +1139,20 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 					log.Debugf("%s omitting routes for virtual service %v/%v due to error: %v", node.ID, virtualService.Namespace, virtualService.Name, err)
 					continue
 				}
+				// Added by ingress
+				wasmPlugins.apply(virtualService, gatewayName, routes)
+				mseingress.ApplyLocalRateLimitAnnotation(virtualService, routes)
+				mseingress.ApplyRateLimitAnnotation(virtualService, routes)
+				mseingress.ApplyExtProcAnnotation(virtualService, routes)
+				mseingress.ApplyGrpcJSONTranscodingAnnotation(virtualService, push, routes)
+				mseingress.ApplyTracingSamplingAnnotation(virtualService, routes)
+				mseingress.ApplyStreamingTimeouts(virtualService, routes)
+				mseingress.ApplyRequestBufferingAnnotation(virtualService, routes)
+				mseingress.ApplyIPAccessAnnotation(globalHTTPFilters, virtualService, routes)
+				routes = mseingress.ApplyCanaryAnnotation(virtualService, routes)
+				mseingress.ApplyBodyTransformationAnnotation(virtualService, wasmPlugins.bodyTransformationPlugin(), routes)
+				mseingress.ApplyGatewayPatchToRoutes(push.GatewayPatch(gatewayName), routes)
+				// End added by ingress
 				gatewayRoutes[gatewayName][vskey] = routes
 			}
 			// This is the service that is exposed on gateway using VirtualService.
@@ -1085,6 +1189,7 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 						TypedPerFilterConfig:       mseingress.ConstructTypedPerFilterConfigForVHost(globalHTTPFilters, virtualService),
 						IncludeRequestAttemptCount: ph.IncludeRequestAttemptCount,
 					}
+					wasmPlugins.applyDomain(newVHost) // Added by ingress
 					if server.Tls != nil && server.Tls.HttpsRedirect {
 						newVHost.RequireTls = route.VirtualHost_ALL
 					}
@@ -1143,12 +1248,9 @@ func (configgen *ConfigGeneratorImpl) buildGatewayHTTPRouteConfig(
 		MaxDirectResponseBodySizeBytes: istio_route.DefaultMaxDirectResponseBodySizeBytes,
 	}
 
-	routeCfg = envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_GATEWAY, node, efw, routeCfg)
-	resource := &discovery.Resource{
-		Name:     routeName,
-		Resource: protoconv.MessageToAny(routeCfg),
-	}
-	return resource, false
+	// Modified by ingress
+	return envoyfilter.ApplyRouteConfigurationPatches(networking.EnvoyFilter_GATEWAY, node, efw, routeCfg)
+	// End modified by ingress
 }
 
 // End modified by ingress
@@ -1257,6 +1359,11 @@ func (configgen *ConfigGeneratorImpl) createGatewayHTTPFilterChainOpts(node *mod
 				suppressEnvoyDebugHeaders: ph.SuppressDebugHeaders,
 				protocol:                  serverProto,
 				class:                     istionetworking.ListenerClassGateway,
+				// Added by ingress
+				gatewayPatch:  gatewayPatchForServers(node, push, node.MergedGateway.ServersByRouteName[routeName]),
+				oauth2Filters: gatewayOAuth2Filters(node, push, node.MergedGateway.ServersByRouteName[routeName]),
+				clientCert:    gatewayClientCertForServers(node, push, node.MergedGateway.ServersByRouteName[routeName]),
+				// End added by ingress
 			},
 		}
 	}
@@ -1280,6 +1387,11 @@ func (configgen *ConfigGeneratorImpl) createGatewayHTTPFilterChainOpts(node *mod
 			statPrefix:                server.Name,
 			http3Only:                 http3Enabled,
 			class:                     istionetworking.ListenerClassGateway,
+			// Added by ingress
+			gatewayPatch:  gatewayPatchForServers(node, push, []*networking.Server{server}),
+			oauth2Filters: gatewayOAuth2Filters(node, push, []*networking.Server{server}),
+			clientCert:    gatewayClientCertForServers(node, push, []*networking.Server{server}),
+			// End added by ingress
 		},
 	}
 }
@@ -44,7 +44,9 @@ import (
 	"istio.io/istio/pilot/pkg/security/model"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
 	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	config "istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/config/schema/gvk"
@@ -3952,3 +3954,103 @@ func TestGatewayHCMInternalAddressConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestGatewayFallbackCertificate(t *testing.T) {
+	server := func(name, host string, mode networking.ServerTLSSettings_TLSmode) *networking.Server {
+		return &networking.Server{
+			Port:  &networking.Port{Name: name, Number: 443, Protocol: "HTTPS"},
+			Hosts: []string{host},
+			Tls:   &networking.ServerTLSSettings{Mode: mode, CredentialName: name},
+		}
+	}
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		flag        string
+		servers     []*networking.Server
+		want        string
+	}{
+		{
+			name:        "annotation",
+			annotations: map[string]string{constants.FallbackCredentialNameAnnotation: "fallback"},
+			servers: []*networking.Server{
+				server("a", "a.example.com", networking.ServerTLSSettings_SIMPLE),
+				server("b", "b.example.com", networking.ServerTLSSettings_SIMPLE),
+			},
+			want: "kubernetes://fallback",
+		},
+		{
+			name: "global default",
+			flag: "default-fallback",
+			servers: []*networking.Server{
+				server("a", "a.example.com", networking.ServerTLSSettings_SIMPLE),
+			},
+			want: "kubernetes://default-fallback",
+		},
+		{
+			name:        "annotation disables global default",
+			annotations: map[string]string{constants.FallbackCredentialNameAnnotation: ""},
+			flag:        "default-fallback",
+			servers: []*networking.Server{
+				server("a", "a.example.com", networking.ServerTLSSettings_SIMPLE),
+			},
+		},
+		{
+			name:        "wildcard server",
+			annotations: map[string]string{constants.FallbackCredentialNameAnnotation: "fallback"},
+			servers: []*networking.Server{
+				server("a", "a.example.com", networking.ServerTLSSettings_SIMPLE),
+				server("wildcard", "*", networking.ServerTLSSettings_SIMPLE),
+			},
+			want: "kubernetes://wildcard",
+		},
+		{
+			name:        "mutual tls only",
+			annotations: map[string]string{constants.FallbackCredentialNameAnnotation: "fallback"},
+			servers: []*networking.Server{
+				server("a", "a.example.com", networking.ServerTLSSettings_MUTUAL),
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.GatewayFallbackCredentialName, tt.flag)
+			cg := NewConfigGenTest(t, TestOptions{
+				Configs: []config.Config{{
+					Meta: config.Meta{
+						Name: "gw", Namespace: "testns", GroupVersionKind: gvk.Gateway,
+						Annotations: tt.annotations,
+					},
+					Spec: &networking.Gateway{Servers: tt.servers},
+				}},
+			})
+			proxy := cg.SetupProxy(&proxyGateway)
+			proxy.Metadata = &proxyGatewayMetadata
+
+			req := &pilot_model.PushRequest{Full: true, Push: cg.PushContext()}
+			builder, _ := cg.ConfigGen.buildGatewayListeners(NewListenerBuilder(proxy, req.Push), req, nil)
+			xdstest.ValidateListeners(t, builder.gatewayListeners)
+			l := xdstest.ExtractListener("0.0.0.0_443", builder.gatewayListeners)
+			if l == nil {
+				t.Fatal("expected a listener on port 443")
+			}
+			got := ""
+			for _, fc := range l.FilterChains {
+				if fc.FilterChainMatch != nil {
+					continue
+				}
+				if got != "" {
+					t.Fatal("expected a single filter chain matching all the clients")
+				}
+				tlsContext := &auth.DownstreamTlsContext{}
+				if err := fc.TransportSocket.GetTypedConfig().UnmarshalTo(tlsContext); err != nil {
+					t.Fatal(err)
+				}
+				got = tlsContext.CommonTlsContext.TlsCertificateSdsSecretConfigs[0].Name
+			}
+			if got != tt.want {
+				t.Fatalf("expected the clients matching no server to get %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
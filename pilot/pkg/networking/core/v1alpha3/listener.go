@@ -35,11 +35,15 @@ import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	istionetworking "istio.io/istio/pilot/pkg/networking"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
 	"istio.io/istio/pilot/pkg/networking/util"
 	authnmodel "istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/ali/config/clientcert"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
@@ -1026,6 +1030,15 @@ type httpListenerOpts struct {
 
 	// Waypoint-specific modifications in HCM
 	isWaypoint bool
+
+	// Added by ingress
+	// gatewayPatch is the patch of the gateway servers of the HCM.
+	gatewayPatch *gatewaypatch.Spec
+	// oauth2Filters are the filters of the OIDC logins of the gateway servers of the HCM.
+	oauth2Filters []*hcm.HttpFilter
+	// clientCert is how the client certificates of the gateway servers of the HCM are passed to the upstreams.
+	clientCert *clientcert.Spec
+	// End added by ingress
 }
 
 // filterChainOpts describes a filter chain: a set of filters with the same TLS context
@@ -1062,6 +1075,8 @@ type gatewayListenerOpts struct {
 	needPROXYProtocol bool
 	// Added by ingress
 	enableProxyProtocol bool
+	// The PROXY protocol of the listener set by its gateways, overriding enableProxyProtocol.
+	proxyProtocol *proxyprotocol.Spec
 	// End added by ingress
 }
 
@@ -1090,7 +1105,11 @@ func buildGatewayListener(opts gatewayListenerOpts, transport istionetworking.Tr
 	}
 
 	// Added by ingress
-	if transport == istionetworking.TransportProtocolTCP && opts.enableProxyProtocol {
+	if transport == istionetworking.TransportProtocolTCP && opts.proxyProtocol != nil && !opts.needPROXYProtocol {
+		if filter := mseingress.BuildProxyProtocolListenerFilter(opts.proxyProtocol); filter != nil {
+			listenerFilters = append(listenerFilters, filter)
+		}
+	} else if transport == istionetworking.TransportProtocolTCP && opts.enableProxyProtocol {
 		listenerFilters = append(listenerFilters, xdsfilters.ProxyProtocolInspector)
 	}
 	// End added by ingress
@@ -1100,7 +1119,9 @@ func buildGatewayListener(opts gatewayListenerOpts, transport istionetworking.Tr
 	if transport == istionetworking.TransportProtocolTCP {
 		for _, chain := range opts.filterChainOpts {
 			needsALPN := chain.tlsContext != nil && chain.tlsContext.CommonTlsContext != nil && len(chain.tlsContext.CommonTlsContext.AlpnProtocols) > 0
-			if len(chain.sniHosts) > 0 || needsALPN {
+			// Modified by ingress
+			if len(chain.sniHosts) > 0 || needsALPN || chain.transportProtocol == xdsfilters.TLSTransportProtocol {
+				// End modified by ingress
 				listenerFilters = append(listenerFilters, xdsfilters.TLSInspector)
 				break
 			}
@@ -34,6 +34,7 @@ import (
 	istionetworking "istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/extension"
+	gatewaypatching "istio.io/istio/pilot/pkg/networking/core/v1alpha3/mseingress"
 	istio_route "istio.io/istio/pilot/pkg/networking/core/v1alpha3/route"
 	"istio.io/istio/pilot/pkg/networking/plugin/authn"
 	"istio.io/istio/pilot/pkg/networking/plugin/authz"
@@ -423,13 +424,19 @@ func (lb *ListenerBuilder) buildHTTPConnectionManager(httpOpts *httpListenerOpts
 	}
 
 	accessLogBuilder.setHTTPAccessLog(lb.push, lb.node, connectionManager, httpOpts.class)
+	// Added by ingress
+	gatewaypatching.ApplyGatewayPatchToConnectionManager(httpOpts.gatewayPatch, lb.push.Mesh, connectionManager)
+	gatewaypatching.ApplyClientCertToConnectionManager(httpOpts.clientCert, connectionManager)
+	// End added by ingress
 
 	startChildSpan, reqIDExtensionCtx := configureTracing(lb.push, lb.node, connectionManager, httpOpts.class)
 
 	filters := []*hcm.HttpFilter{}
 	// Added by ingress
 	// Now only support onDemandRDS when enable SRDS
-	if alifeatures.OnDemandRDS && enableSRDS {
+	// The virtual hosts left to VHDS are requested by the on demand filter too.
+	vhds := httpOpts.rds != "" && lb.node.VHDSEnabled()
+	if (alifeatures.OnDemandRDS && enableSRDS) || vhds {
 		filters = append([]*hcm.HttpFilter{xdsfilters.OnDemand, xdsfilters.Cors}, filters...)
 	} else {
 		// End added by ingress
@@ -460,6 +467,14 @@ func (lb *ListenerBuilder) buildHTTPConnectionManager(httpOpts *httpListenerOpts
 				filters = append(filters, xdsfilters.HTTPMx)
 			}
 		}
+		// Added by ingress
+		// The client certificate headers are set before any filter reads them, and the OIDC logins run before the
+		// authentication filters, so that they verify the bearer tokens the logins forward.
+		if filter := gatewaypatching.BuildClientCertHeadersFilter(httpOpts.clientCert); filter != nil {
+			filters = append(filters, filter)
+		}
+		filters = append(filters, httpOpts.oauth2Filters...)
+		// End added by ingress
 		// TODO: how to deal with ext-authz? It will be in the ordering twice
 		filters = append(filters, lb.authzCustomBuilder.BuildHTTP(httpOpts.class)...)
 		filters = extension.PopAppend(filters, wasm, extensions.PluginPhase_AUTHN)
@@ -0,0 +1,126 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/host"
+	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+var log = istiolog.RegisterScope("loadbalancer", "locality load balancing")
+
+// Sources of the locality load balancer setting of a service.
+const (
+	SettingSourceDestinationRule = "destinationrule"
+	SettingSourceService         = "service"
+	SettingSourceMesh            = "mesh"
+)
+
+// ServiceSettings are the locality load balancer settings of the mesh for some services, by host. Hosts may be
+// wildcards, such as *.ns.svc.cluster.local, the most specific one matching a service applies.
+type ServiceSettings struct {
+	specific map[host.Name]*v1alpha3.LocalityLoadBalancerSetting
+	wildcard map[host.Name]*v1alpha3.LocalityLoadBalancerSetting
+}
+
+// ParseServiceSettings parses the locality load balancer settings of services, as a JSON object of the settings,
+// in the format of the localityLbSetting of the mesh config, by service host.
+func ParseServiceSettings(s string) (*ServiceSettings, error) {
+	out := &ServiceSettings{
+		specific: map[host.Name]*v1alpha3.LocalityLoadBalancerSetting{},
+		wildcard: map[host.Name]*v1alpha3.LocalityLoadBalancerSetting{},
+	}
+	if s == "" {
+		return out, nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, err
+	}
+	for h, setting := range raw {
+		lb := &v1alpha3.LocalityLoadBalancerSetting{}
+		if err := protomarshal.Unmarshal(setting, lb); err != nil {
+			return nil, fmt.Errorf("invalid locality load balancer setting of %s: %v", h, err)
+		}
+		if len(lb.Distribute) > 0 && (len(lb.Failover) > 0 || len(lb.FailoverPriority) > 0) {
+			return nil, fmt.Errorf("invalid locality load balancer setting of %s: distribute and failover are exclusive", h)
+		}
+		if name := host.Name(h); name.IsWildCarded() {
+			out.wildcard[name] = lb
+		} else {
+			out.specific[name] = lb
+		}
+	}
+	return out, nil
+}
+
+// Setting returns the setting of the most specific host matching the service, if any.
+func (s *ServiceSettings) Setting(hostname host.Name) *v1alpha3.LocalityLoadBalancerSetting {
+	if s == nil || len(s.specific)+len(s.wildcard) == 0 {
+		return nil
+	}
+	_, lb, _ := model.MostSpecificHostMatch(hostname, s.specific, s.wildcard)
+	return lb
+}
+
+var (
+	serviceSettingsOnce sync.Once
+	serviceSettings     *ServiceSettings
+)
+
+// meshServiceSettings returns the settings of PILOT_SERVICE_LOCALITY_LB_SETTINGS.
+func meshServiceSettings() *ServiceSettings {
+	serviceSettingsOnce.Do(func() {
+		settings, err := ParseServiceSettings(alifeatures.ServiceLocalityLbSettings)
+		if err != nil {
+			log.Errorf("ignoring invalid PILOT_SERVICE_LOCALITY_LB_SETTINGS: %v", err)
+		}
+		serviceSettings = settings
+	})
+	return serviceSettings
+}
+
+// GetMeshLocalityLbSetting returns the locality load balancer setting of the mesh for a service, which its
+// destination rules override: the one of the service in PILOT_SERVICE_LOCALITY_LB_SETTINGS, or else the
+// localityLbSetting of the mesh config.
+func GetMeshLocalityLbSetting(mesh *meshconfig.MeshConfig, hostname host.Name) *v1alpha3.LocalityLoadBalancerSetting {
+	if lb := meshServiceSettings().Setting(hostname); lb != nil {
+		return lb
+	}
+	return mesh.GetLocalityLbSetting()
+}
+
+// LocalityLbSettingSource returns where the locality load balancer setting of a service comes from, or an empty
+// string if it has none.
+func LocalityLbSettingSource(mesh *meshconfig.MeshConfig, hostname host.Name, destrule *v1alpha3.LocalityLoadBalancerSetting) string {
+	switch {
+	case destrule != nil:
+		return SettingSourceDestinationRule
+	case meshServiceSettings().Setting(hostname) != nil:
+		return SettingSourceService
+	case mesh.GetLocalityLbSetting() != nil:
+		return SettingSourceMesh
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadbalancer
+
+import (
+	"reflect"
+	"testing"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/host"
+)
+
+func TestServiceSettings(t *testing.T) {
+	settings, err := ParseServiceSettings(`{
+		"*.ns.svc.cluster.local": {"failoverPriority": ["topology.istio.io/network", "topology.kubernetes.io/region"]},
+		"reviews.ns.svc.cluster.local": {"failover": [{"from": "us-east", "to": "us-west"}]},
+		"ratings.ns.svc.cluster.local": {"enabled": false}
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serviceSettingsOnce.Do(func() {})
+	serviceSettings = settings
+	t.Cleanup(func() {
+		serviceSettings = nil
+	})
+
+	mesh := &meshconfig.MeshConfig{LocalityLbSetting: &networking.LocalityLoadBalancerSetting{
+		Failover: []*networking.LocalityLoadBalancerSetting_Failover{{From: "us-east", To: "eu-west"}},
+	}}
+	destrule := &networking.LocalityLoadBalancerSetting{FailoverPriority: []string{"topology.kubernetes.io/zone"}}
+
+	if got := GetMeshLocalityLbSetting(mesh, "details.ns.svc.cluster.local").GetFailoverPriority(); !reflect.DeepEqual(got,
+		[]string{"topology.istio.io/network", "topology.kubernetes.io/region"}) {
+		t.Fatalf("expected the setting of the wildcard host, got %v", got)
+	}
+	if got := GetMeshLocalityLbSetting(mesh, "reviews.ns.svc.cluster.local").GetFailover(); len(got) != 1 || got[0].To != "us-west" {
+		t.Fatalf("expected the setting of the host, got %v", got)
+	}
+	if got := GetMeshLocalityLbSetting(mesh, "details.other.svc.cluster.local"); got != mesh.LocalityLbSetting {
+		t.Fatalf("expected the setting of the mesh, got %v", got)
+	}
+	if got := GetLocalityLbSetting(GetMeshLocalityLbSetting(mesh, "ratings.ns.svc.cluster.local"), nil); got != nil {
+		t.Fatalf("expected locality load balancing to be disabled, got %v", got)
+	}
+	if got := GetLocalityLbSetting(GetMeshLocalityLbSetting(mesh, "reviews.ns.svc.cluster.local"), destrule); got != destrule {
+		t.Fatalf("expected the destination rule to override the setting of the service, got %v", got)
+	}
+
+	for _, tc := range []struct {
+		hostname string
+		destrule *networking.LocalityLoadBalancerSetting
+		want     string
+	}{
+		{"reviews.ns.svc.cluster.local", destrule, SettingSourceDestinationRule},
+		{"reviews.ns.svc.cluster.local", nil, SettingSourceService},
+		{"details.other.svc.cluster.local", nil, SettingSourceMesh},
+	} {
+		if got := LocalityLbSettingSource(mesh, host.Name(tc.hostname), tc.destrule); got != tc.want {
+			t.Fatalf("got source %q of %s, want %q", got, tc.hostname, tc.want)
+		}
+	}
+	if got := LocalityLbSettingSource(nil, "details.other.svc.cluster.local", nil); got != "" {
+		t.Fatalf("expected no source, got %q", got)
+	}
+
+	if _, err := ParseServiceSettings(`{"a.com": {"distribute": [{"from": "a", "to": {"b": 100}}], "failoverPriority": ["x"]}}`); err == nil {
+		t.Fatalf("expected distribute and failover to be exclusive")
+	}
+	if _, err := ParseServiceSettings(`{"a.com": {"unknown": true}}`); err == nil {
+		t.Fatalf("expected an error for an unknown field")
+	}
+}
@@ -0,0 +1,37 @@
+package mseingress
+
+import (
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/backendtls"
+	"istio.io/istio/pkg/log"
+)
+
+// ApplyBackendTLSPolicy returns the traffic policy of a port of a service with the TLS settings of its backend TLS
+// policy, if any. The gateways then originate TLS to the backend and verify its certificate against the CA certificate
+// of the credential, served by SDS as its -cacert resource. The TLS settings of a DestinationRule take precedence.
+func ApplyBackendTLSPolicy(policy *networking.TrafficPolicy, service *model.Service, port *model.Port) *networking.TrafficPolicy {
+	if service.Attributes.BackendTLS == "" || policy.GetTls() != nil {
+		return policy
+	}
+	spec, err := backendtls.Parse(service.Attributes.BackendTLS)
+	if err != nil {
+		log.Warnf("ignoring backend tls policy of service %s: %v", service.Hostname, err)
+		return policy
+	}
+	if !spec.AppliesTo(port.Name) {
+		return policy
+	}
+	if policy == nil {
+		policy = &networking.TrafficPolicy{}
+	} else {
+		policy = policy.DeepCopy()
+	}
+	policy.Tls = &networking.ClientTLSSettings{
+		Mode:            networking.ClientTLSSettings_SIMPLE,
+		CredentialName:  spec.CredentialName,
+		Sni:             spec.Hostname,
+		SubjectAltNames: []string{spec.Hostname},
+	}
+	return policy
+}
@@ -0,0 +1,89 @@
+package mseingress
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestApplyBackendTLSPolicy(t *testing.T) {
+	service := &model.Service{
+		Hostname: "backend.default.svc.cluster.local",
+		Attributes: model.ServiceAttributes{
+			BackendTLS: `{"credentialName": "backend-ca", "hostname": "backend.example.com", "ports": ["https"]}`,
+		},
+	}
+	https := &model.Port{Name: "https", Port: 443}
+	drTLS := &networking.TrafficPolicy{Tls: &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_DISABLE}}
+	drLB := &networking.TrafficPolicy{LoadBalancer: &networking.LoadBalancerSettings{}}
+	wantTLS := &networking.ClientTLSSettings{
+		Mode:            networking.ClientTLSSettings_SIMPLE,
+		CredentialName:  "backend-ca",
+		Sni:             "backend.example.com",
+		SubjectAltNames: []string{"backend.example.com"},
+	}
+
+	cases := []struct {
+		name    string
+		policy  *networking.TrafficPolicy
+		service *model.Service
+		port    *model.Port
+		wantTLS *networking.ClientTLSSettings
+	}{
+		{
+			name:    "no destination rule",
+			service: service,
+			port:    https,
+			wantTLS: wantTLS,
+		},
+		{
+			name:    "destination rule without tls",
+			policy:  drLB,
+			service: service,
+			port:    https,
+			wantTLS: wantTLS,
+		},
+		{
+			name:    "destination rule tls takes precedence",
+			policy:  drTLS,
+			service: service,
+			port:    https,
+			wantTLS: drTLS.Tls,
+		},
+		{
+			name:    "other port",
+			service: service,
+			port:    &model.Port{Name: "http", Port: 80},
+		},
+		{
+			name:    "no policy",
+			service: &model.Service{Hostname: "backend.default.svc.cluster.local"},
+			port:    https,
+		},
+		{
+			name: "invalid policy",
+			service: &model.Service{
+				Hostname:   "backend.default.svc.cluster.local",
+				Attributes: model.ServiceAttributes{BackendTLS: `{"credentialName": "backend-ca"}`},
+			},
+			port: https,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyBackendTLSPolicy(tt.policy, tt.service, tt.port)
+			if !proto.Equal(got.GetTls(), tt.wantTLS) {
+				t.Errorf("got tls %v, want %v", got.GetTls(), tt.wantTLS)
+			}
+		})
+	}
+	if drLB.Tls != nil {
+		t.Error("the traffic policy of the destination rule was modified")
+	}
+	if got := ApplyBackendTLSPolicy(drLB, service, https); got.LoadBalancer == nil {
+		t.Error("the traffic policy of the destination rule was not kept")
+	}
+}
@@ -0,0 +1,69 @@
+package mseingress
+
+import (
+	"encoding/json"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	envoywasm "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/bodytransformation"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+// ApplyBodyTransformationAnnotation configures the body transforming WasmPlugin on the routes of the virtual service
+// with the transformations of its higress.io/body-transformation annotation, overriding the configuration of the
+// plugin. The routes are left as is if the proxy does not run the plugin.
+func ApplyBodyTransformationAnnotation(virtualService config.Config, plugin *model.WasmPluginWrapper, routes []*route.Route) {
+	value, ok := virtualService.Annotations[constants.BodyTransformationAnnotation]
+	if !ok {
+		return
+	}
+	if plugin == nil {
+		log.Debugf("ignoring body transformation of virtual service %s/%s: the body transformation plugin is not "+
+			"applied to the proxy", virtualService.Namespace, virtualService.Name)
+		return
+	}
+	spec, err := bodytransformation.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring body transformation of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	configs := map[*bodytransformation.Rule]*anypb.Any{}
+	for _, r := range routes {
+		rule := spec.RouteRule(r.Name)
+		if rule == nil {
+			continue
+		}
+		filterConfig, ok := configs[rule]
+		if !ok {
+			filterConfig = bodyTransformationConfig(plugin, rule)
+			configs[rule] = filterConfig
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*anypb.Any{}
+		}
+		r.TypedPerFilterConfig[plugin.ResourceName] = filterConfig
+	}
+}
+
+// bodyTransformationConfig returns the per-route config of the plugin applying the transformations of the rule.
+func bodyTransformationConfig(plugin *model.WasmPluginWrapper, rule *bodytransformation.Rule) *anypb.Any {
+	// The rule was parsed from JSON, so it marshals back.
+	configuration, _ := json.Marshal(rule)
+	return protoconv.MessageToAny(&wasm.Wasm{
+		Config: &envoywasm.PluginConfig{
+			Name:          plugin.ResourceName,
+			RootId:        plugin.PluginName,
+			Configuration: protoconv.MessageToAny(&wrapperspb.StringValue{Value: string(configuration)}),
+			FailOpen:      plugin.FailStrategy == extensions.FailStrategy_FAIL_OPEN,
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package mseingress
+
+import (
+	"encoding/json"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestApplyBodyTransformationAnnotation(t *testing.T) {
+	plugin := &model.WasmPluginWrapper{
+		ResourceName: "higress-system.transformer",
+		WasmPlugin: &extensions.WasmPlugin{
+			PluginName:   "transformer",
+			FailStrategy: extensions.FailStrategy_FAIL_OPEN,
+		},
+	}
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.BodyTransformationAnnotation: `{"routes": {"api": {"request": {"add": {"user.id": "${header.x-user-id}"}}}}}`,
+			},
+		},
+	}
+	routes := []*route.Route{{Name: "api.v1"}, {Name: "catalog"}}
+	ApplyBodyTransformationAnnotation(virtualService, plugin, routes)
+	if routes[1].TypedPerFilterConfig != nil {
+		t.Errorf("got per-filter configs %v of a route without transformation", routes[1].TypedPerFilterConfig)
+	}
+	filterConfig := routes[0].TypedPerFilterConfig[plugin.ResourceName]
+	if filterConfig == nil {
+		t.Fatalf("got per-filter configs %v, want the config of the plugin", routes[0].TypedPerFilterConfig)
+	}
+	wasmConfig := &wasm.Wasm{}
+	if err := filterConfig.UnmarshalTo(wasmConfig); err != nil {
+		t.Fatal(err)
+	}
+	if wasmConfig.Config.RootId != "transformer" || !wasmConfig.Config.FailOpen {
+		t.Errorf("got plugin config %v", wasmConfig.Config)
+	}
+	configuration := &wrapperspb.StringValue{}
+	if err := wasmConfig.Config.Configuration.UnmarshalTo(configuration); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]any{}
+	if err := json.Unmarshal([]byte(configuration.Value), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"request": map[string]any{"add": map[string]any{"user.id": "${header.x-user-id}"}}}
+	if gotJSON, wantJSON := mustMarshal(t, got), mustMarshal(t, want); gotJSON != wantJSON {
+		t.Errorf("got configuration %s, want %s", gotJSON, wantJSON)
+	}
+
+	routes = []*route.Route{{Name: "api"}}
+	ApplyBodyTransformationAnnotation(virtualService, nil, routes)
+	if routes[0].TypedPerFilterConfig != nil {
+		t.Errorf("got per-filter configs %v without the plugin", routes[0].TypedPerFilterConfig)
+	}
+
+	virtualService.Annotations[constants.BodyTransformationAnnotation] = `{"request": {"add": {"id": "${cookie.id}"}}}`
+	ApplyBodyTransformationAnnotation(virtualService, plugin, routes)
+	if routes[0].TypedPerFilterConfig != nil {
+		t.Errorf("got per-filter configs %v with an invalid annotation", routes[0].TypedPerFilterConfig)
+	}
+}
+
+func mustMarshal(t *testing.T, v any) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,193 @@
+package mseingress
+
+import (
+	"fmt"
+	"regexp"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/proto"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pkg/ali/config/canary"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	stickyCanary = "canary"
+	stickyStable = "stable"
+)
+
+// ApplyCanaryAnnotation releases the weighted routes of the virtual service as canaries with the policies of its
+// higress.io/canary annotation. The last destination of such a route is the canary and the others the stable ones.
+// Each route is preceded by the routes forcing the canary or stable destinations with a header, query parameter or
+// cookie, and those pinning the destination picked for a session with the sticky cookie. The routes falling back to
+// other clusters are left as is.
+func ApplyCanaryAnnotation(virtualService config.Config, routes []*route.Route) []*route.Route {
+	value, ok := virtualService.Annotations[constants.CanaryAnnotation]
+	if !ok {
+		return routes
+	}
+	spec, err := canary.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring canary of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return routes
+	}
+	out := make([]*route.Route, 0, len(routes))
+	for _, r := range routes {
+		policy := spec.RoutePolicy(r.Name)
+		weighted := r.GetRoute().GetWeightedClusters()
+		if policy == nil || len(weighted.GetClusters()) < 2 || weighted.GetInlineClusterSpecifierPlugin() != nil {
+			out = append(out, r)
+			continue
+		}
+		out = append(out, canaryRoutes(r, policy)...)
+	}
+	return out
+}
+
+// canaryRoutes returns the routes releasing the weighted route as a canary, ending with the route itself.
+func canaryRoutes(r *route.Route, policy *canary.Policy) []*route.Route {
+	clusters := r.GetRoute().GetWeightedClusters().GetClusters()
+	canaryCluster := clusters[len(clusters)-1:]
+	stableClusters := clusters[:len(clusters)-1]
+
+	var out []*route.Route
+	forced := func(match func(*route.RouteMatch, string)) {
+		for _, value := range []string{canary.Always, canary.Never} {
+			dst := canaryCluster
+			if value == canary.Never {
+				dst = stableClusters
+			}
+			forcedRoute := routeToClusters(r, dst)
+			match(forcedRoute.Match, value)
+			out = append(out, forcedRoute)
+		}
+	}
+	if policy.Header != "" {
+		forced(func(m *route.RouteMatch, value string) {
+			m.Headers = append(m.Headers, exactHeaderMatcher(policy.Header, value))
+		})
+	}
+	if policy.QueryParameter != "" {
+		forced(func(m *route.RouteMatch, value string) {
+			m.QueryParameters = append(m.QueryParameters, &route.QueryParameterMatcher{
+				Name: policy.QueryParameter,
+				QueryParameterMatchSpecifier: &route.QueryParameterMatcher_StringMatch{
+					StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: value}},
+				},
+			})
+		})
+	}
+	if policy.Cookie != "" {
+		forced(func(m *route.RouteMatch, value string) {
+			m.Headers = append(m.Headers, cookieMatcher(policy.Cookie, value))
+		})
+	}
+
+	if policy.Sticky == nil {
+		r = routeToClusters(r, clusters)
+	} else {
+		cookie := policy.Sticky.CookieName()
+		for _, variant := range []string{stickyCanary, stickyStable} {
+			dst := canaryCluster
+			if variant == stickyStable {
+				dst = stableClusters
+			}
+			stickyRoute := routeToClusters(r, dst)
+			stickyRoute.Match.Headers = append(stickyRoute.Match.Headers, cookieMatcher(cookie, variant))
+			stickyRoute.ResponseHeadersToAdd = append(stickyRoute.ResponseHeadersToAdd, setCookie(policy.Sticky, variant))
+			out = append(out, stickyRoute)
+		}
+		r = routeToClusters(r, clusters)
+		for i, cw := range r.GetRoute().GetWeightedClusters().GetClusters() {
+			variant := stickyStable
+			if i == len(clusters)-1 {
+				variant = stickyCanary
+			}
+			cw.ResponseHeadersToAdd = append(cw.ResponseHeadersToAdd, setCookie(policy.Sticky, variant))
+		}
+	}
+	if policy.HashHeader != "" {
+		r.GetRoute().GetWeightedClusters().RandomValueSpecifier = &route.WeightedCluster_HeaderName{
+			HeaderName: policy.HashHeader,
+		}
+	}
+	return append(out, r)
+}
+
+// routeToClusters returns a copy of the route to the clusters, as a single cluster if there is only one.
+func routeToClusters(r *route.Route, clusters []*route.WeightedCluster_ClusterWeight) *route.Route {
+	out := proto.Clone(r).(*route.Route)
+	if out.Match == nil {
+		out.Match = &route.RouteMatch{}
+	}
+	action := out.GetRoute()
+	if len(clusters) > 1 {
+		var totalWeight uint32
+		weighted := make([]*route.WeightedCluster_ClusterWeight, 0, len(clusters))
+		for _, cw := range clusters {
+			totalWeight += cw.GetWeight().GetValue()
+			weighted = append(weighted, proto.Clone(cw).(*route.WeightedCluster_ClusterWeight))
+		}
+		action.ClusterSpecifier = &route.RouteAction_WeightedClusters{
+			WeightedClusters: &route.WeightedCluster{
+				Clusters:    weighted,
+				TotalWeight: wrappers.UInt32(totalWeight),
+			},
+		}
+		return out
+	}
+	cw := clusters[0]
+	action.ClusterSpecifier = &route.RouteAction_Cluster{Cluster: cw.Name}
+	out.RequestHeadersToAdd = append(out.RequestHeadersToAdd, cw.RequestHeadersToAdd...)
+	out.RequestHeadersToRemove = append(out.RequestHeadersToRemove, cw.RequestHeadersToRemove...)
+	out.ResponseHeadersToAdd = append(out.ResponseHeadersToAdd, cw.ResponseHeadersToAdd...)
+	out.ResponseHeadersToRemove = append(out.ResponseHeadersToRemove, cw.ResponseHeadersToRemove...)
+	if cw.HostRewriteSpecifier != nil && action.HostRewriteSpecifier == nil {
+		action.HostRewriteSpecifier = &route.RouteAction_HostRewriteLiteral{
+			HostRewriteLiteral: cw.GetHostRewriteLiteral(),
+		}
+	}
+	return out
+}
+
+func exactHeaderMatcher(name, value string) *route.HeaderMatcher {
+	return &route.HeaderMatcher{
+		Name: name,
+		HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+			StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: value}},
+		},
+	}
+}
+
+// cookieMatcher matches the requests with the cookie set to the value.
+func cookieMatcher(name, value string) *route.HeaderMatcher {
+	return &route.HeaderMatcher{
+		Name: "cookie",
+		HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+			StringMatch: &matcher.StringMatcher{
+				MatchPattern: &matcher.StringMatcher_SafeRegex{
+					SafeRegex: &matcher.RegexMatcher{
+						Regex: fmt.Sprintf(`(.*;\s*)?%s=%s(;.*)?`, regexp.QuoteMeta(name), regexp.QuoteMeta(value)),
+					},
+				},
+			},
+		},
+	}
+}
+
+// setCookie sets the sticky cookie to the variant on the response, renewing its lifetime.
+func setCookie(sticky *canary.Sticky, variant string) *core.HeaderValueOption {
+	value := fmt.Sprintf("%s=%s; Path=/; HttpOnly", sticky.CookieName(), variant)
+	if maxAge := sticky.MaxAge(); maxAge > 0 {
+		value += fmt.Sprintf("; Max-Age=%d", int64(maxAge.Seconds()))
+	}
+	return &core.HeaderValueOption{
+		Header:       &core.HeaderValue{Key: "set-cookie", Value: value},
+		AppendAction: core.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD,
+	}
+}
@@ -0,0 +1,88 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestApplyCanaryAnnotation(t *testing.T) {
+	weightedRoute := func(name string) *route.Route {
+		return &route.Route{
+			Name:  name,
+			Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+			Action: &route.Route_Route{Route: &route.RouteAction{
+				ClusterSpecifier: &route.RouteAction_WeightedClusters{WeightedClusters: &route.WeightedCluster{
+					Clusters: []*route.WeightedCluster_ClusterWeight{
+						{Name: "stable", Weight: wrappers.UInt32(90)},
+						{Name: "canary", Weight: wrappers.UInt32(10)},
+					},
+					TotalWeight: wrappers.UInt32(100),
+				}},
+			}},
+		}
+	}
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.CanaryAnnotation: `{"header": "x-canary", "sticky": {"ttl": "1h"}, ` +
+					`"routes": {"checkout": {"hashHeader": "x-user-id"}}}`,
+			},
+		},
+	}
+	single := &route.Route{Name: "catalog", Action: &route.Route_Route{Route: &route.RouteAction{
+		ClusterSpecifier: &route.RouteAction_Cluster{Cluster: "catalog"},
+	}}}
+	routes := ApplyCanaryAnnotation(virtualService, []*route.Route{weightedRoute("api"), single, weightedRoute("checkout")})
+
+	// The api route is preceded by the routes forced by the header and pinned by the sticky cookie, while the
+	// checkout route only hashes on the header.
+	if len(routes) != 7 {
+		t.Fatalf("got %d routes, want 7", len(routes))
+	}
+	wantClusters := []string{"canary", "stable", "canary", "stable"}
+	for i, want := range wantClusters {
+		r := routes[i]
+		if got := r.GetRoute().GetCluster(); got != want {
+			t.Errorf("got cluster %q of route %d, want %q", got, i, want)
+		}
+		if len(r.Match.Headers) != 1 {
+			t.Fatalf("got header matchers %v of route %d, want 1", r.Match.Headers, i)
+		}
+	}
+	if got := routes[0].Match.Headers[0].GetStringMatch().GetExact(); routes[0].Match.Headers[0].Name != "x-canary" ||
+		got != "always" {
+		t.Errorf("got header matcher %v of the forced canary route", routes[0].Match.Headers[0])
+	}
+	if got := routes[2].Match.Headers[0].GetStringMatch().GetSafeRegex().GetRegex(); got != `(.*;\s*)?higress-canary=canary(;.*)?` {
+		t.Errorf("got cookie regex %q of the sticky canary route", got)
+	}
+	if got := routes[2].ResponseHeadersToAdd[0].GetHeader().GetValue(); got != "higress-canary=canary; Path=/; HttpOnly; Max-Age=3600" {
+		t.Errorf("got set-cookie %q of the sticky canary route", got)
+	}
+	weighted := routes[4].GetRoute().GetWeightedClusters()
+	if got := weighted.Clusters[0].ResponseHeadersToAdd[0].GetHeader().GetValue(); got != "higress-canary=stable; Path=/; HttpOnly; Max-Age=3600" {
+		t.Errorf("got set-cookie %q of the stable cluster", got)
+	}
+	if routes[5] != single {
+		t.Errorf("got route %v, want the single cluster route untouched", routes[5])
+	}
+	if got := routes[6].GetRoute().GetWeightedClusters().GetHeaderName(); got != "x-user-id" {
+		t.Errorf("got hash header %q of the checkout route, want x-user-id", got)
+	}
+	if got := routes[4].GetRoute().GetWeightedClusters().GetHeaderName(); got != "" {
+		t.Errorf("got hash header %q of the api route, want none", got)
+	}
+
+	virtualService.Annotations[constants.CanaryAnnotation] = `{"header": "x canary"}`
+	routes = ApplyCanaryAnnotation(virtualService, []*route.Route{weightedRoute("api")})
+	if len(routes) != 1 || len(routes[0].Match.Headers) != 0 {
+		t.Errorf("got routes %v with an invalid annotation", routes)
+	}
+}
@@ -0,0 +1,89 @@
+package mseingress
+
+import (
+	mutation_rules "github.com/envoyproxy/go-control-plane/envoy/config/common/mutation_rules/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	header_mutation "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_mutation/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/clientcert"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/proto"
+	"istio.io/istio/pkg/slices"
+)
+
+// ClientCertHeadersFilterName is the name of the header mutation filter copying the attributes of the client
+// certificates to the request headers.
+const ClientCertHeadersFilterName = "higress.filters.http.client_cert_headers"
+
+var forwardClientCertDetails = map[string]http_conn.HttpConnectionManager_ForwardClientCertDetails{
+	clientcert.Sanitize:          http_conn.HttpConnectionManager_SANITIZE,
+	clientcert.ForwardOnly:       http_conn.HttpConnectionManager_FORWARD_ONLY,
+	clientcert.AppendForward:     http_conn.HttpConnectionManager_APPEND_FORWARD,
+	clientcert.SanitizeSet:       http_conn.HttpConnectionManager_SANITIZE_SET,
+	clientcert.AlwaysForwardOnly: http_conn.HttpConnectionManager_ALWAYS_FORWARD_ONLY,
+}
+
+// clientCertAttributeFormats are the header formats of the attributes of the client certificates.
+var clientCertAttributeFormats = map[string]string{
+	clientcert.AttributeHash:    "%DOWNSTREAM_PEER_FINGERPRINT_256%",
+	clientcert.AttributeSubject: "%DOWNSTREAM_PEER_SUBJECT%",
+	clientcert.AttributeIssuer:  "%DOWNSTREAM_PEER_ISSUER%",
+	clientcert.AttributeSerial:  "%DOWNSTREAM_PEER_SERIAL%",
+	clientcert.AttributeURISAN:  "%DOWNSTREAM_PEER_URI_SAN%",
+	clientcert.AttributeDNSSAN:  "%DOWNSTREAM_PEER_DNS_SAN%",
+	clientcert.AttributeCert:    "%DOWNSTREAM_PEER_CERT%",
+}
+
+// ApplyClientCertToConnectionManager applies the forward mode of the x-forwarded-client-cert header and its details
+// of the client certificates of a gateway to the HTTP connection manager of its servers.
+func ApplyClientCertToConnectionManager(spec *clientcert.Spec, connectionManager *http_conn.HttpConnectionManager) {
+	if spec == nil || spec.Forward == "" {
+		return
+	}
+	connectionManager.ForwardClientCertDetails = forwardClientCertDetails[spec.Forward]
+	if spec.Forward != clientcert.AppendForward && spec.Forward != clientcert.SanitizeSet {
+		return
+	}
+	details := &http_conn.HttpConnectionManager_SetCurrentClientCertDetails{
+		Cert:  spec.HasDetail(clientcert.DetailCert),
+		Chain: spec.HasDetail(clientcert.DetailChain),
+		Dns:   spec.HasDetail(clientcert.DetailDNS),
+		Uri:   spec.HasDetail(clientcert.DetailURI),
+	}
+	if spec.HasDetail(clientcert.DetailSubject) {
+		details.Subject = proto.BoolTrue
+	}
+	connectionManager.SetCurrentClientCertDetails = details
+}
+
+// BuildClientCertHeadersFilter returns the filter copying the attributes of the client certificates of a gateway to
+// the request headers, after removing the ones sent by the clients, or nil if there are none.
+func BuildClientCertHeadersFilter(spec *clientcert.Spec) *http_conn.HttpFilter {
+	if spec == nil || len(spec.Headers) == 0 {
+		return nil
+	}
+	headers := maps.Keys(spec.Headers)
+	slices.Sort(headers)
+	mutations := &header_mutation.Mutations{}
+	for _, header := range headers {
+		mutations.RequestMutations = append(mutations.RequestMutations, &mutation_rules.HeaderMutation{
+			Action: &mutation_rules.HeaderMutation_Remove{Remove: header},
+		})
+	}
+	for _, header := range headers {
+		mutations.RequestMutations = append(mutations.RequestMutations, &mutation_rules.HeaderMutation{
+			Action: &mutation_rules.HeaderMutation_Append{Append: &core.HeaderValueOption{
+				Header:       &core.HeaderValue{Key: header, Value: clientCertAttributeFormats[spec.Headers[header]]},
+				AppendAction: core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+			}},
+		})
+	}
+	return &http_conn.HttpFilter{
+		Name: ClientCertHeadersFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(&header_mutation.HeaderMutation{
+			Mutations: mutations,
+		})},
+	}
+}
@@ -0,0 +1,107 @@
+package mseingress
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	header_mutation "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_mutation/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/istio/pkg/ali/config/clientcert"
+)
+
+func TestApplyClientCertToConnectionManager(t *testing.T) {
+	defaultDetails := &http_conn.HttpConnectionManager_SetCurrentClientCertDetails{Cert: true}
+	cases := []struct {
+		name        string
+		value       string
+		wantForward http_conn.HttpConnectionManager_ForwardClientCertDetails
+		wantDetails *http_conn.HttpConnectionManager_SetCurrentClientCertDetails
+	}{
+		{
+			name:        "default forward",
+			value:       `{"headers": {"x-client-cert-hash": "hash"}}`,
+			wantForward: http_conn.HttpConnectionManager_SANITIZE_SET,
+			wantDetails: defaultDetails,
+		},
+		{
+			name:        "forward only",
+			value:       `{"forward": "forward_only"}`,
+			wantForward: http_conn.HttpConnectionManager_FORWARD_ONLY,
+			wantDetails: defaultDetails,
+		},
+		{
+			name:        "default details",
+			value:       `{"forward": "append_forward"}`,
+			wantForward: http_conn.HttpConnectionManager_APPEND_FORWARD,
+			wantDetails: &http_conn.HttpConnectionManager_SetCurrentClientCertDetails{Cert: true, Dns: true, Uri: true},
+		},
+		{
+			name:        "details",
+			value:       `{"forward": "sanitize_set", "details": ["uri", "chain"]}`,
+			wantForward: http_conn.HttpConnectionManager_SANITIZE_SET,
+			wantDetails: &http_conn.HttpConnectionManager_SetCurrentClientCertDetails{Chain: true, Uri: true},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := clientcert.Parse(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			connectionManager := &http_conn.HttpConnectionManager{
+				ForwardClientCertDetails:    http_conn.HttpConnectionManager_SANITIZE_SET,
+				SetCurrentClientCertDetails: &http_conn.HttpConnectionManager_SetCurrentClientCertDetails{Cert: true},
+			}
+			ApplyClientCertToConnectionManager(spec, connectionManager)
+			if connectionManager.ForwardClientCertDetails != tt.wantForward {
+				t.Errorf("got forward %v, want %v", connectionManager.ForwardClientCertDetails, tt.wantForward)
+			}
+			got := connectionManager.SetCurrentClientCertDetails
+			want := tt.wantDetails
+			if got.Cert != want.Cert || got.Chain != want.Chain || got.Dns != want.Dns || got.Uri != want.Uri {
+				t.Errorf("got details %v, want %v", got, want)
+			}
+			if wantSubject := tt.name == "default details"; got.GetSubject().GetValue() != wantSubject {
+				t.Errorf("got subject %v, want %v", got.GetSubject().GetValue(), wantSubject)
+			}
+		})
+	}
+}
+
+func TestBuildClientCertHeadersFilter(t *testing.T) {
+	if filter := BuildClientCertHeadersFilter(&clientcert.Spec{Forward: clientcert.Sanitize}); filter != nil {
+		t.Fatalf("got filter %v without headers", filter)
+	}
+
+	spec, err := clientcert.Parse(`{"headers": {"X-Client-URI": "uri_san", "x-client-cert-hash": "hash"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := BuildClientCertHeadersFilter(spec)
+	if filter.Name != ClientCertHeadersFilterName {
+		t.Errorf("got filter %s, want %s", filter.Name, ClientCertHeadersFilterName)
+	}
+	mutation := &header_mutation.HeaderMutation{}
+	if err := filter.GetTypedConfig().UnmarshalTo(mutation); err != nil {
+		t.Fatal(err)
+	}
+	mutations := mutation.GetMutations().GetRequestMutations()
+	if len(mutations) != 4 {
+		t.Fatalf("got mutations %v, want the headers removed then set", mutations)
+	}
+	for i, header := range []string{"x-client-cert-hash", "x-client-uri"} {
+		if got := mutations[i].GetRemove(); got != header {
+			t.Errorf("got removed header %s, want %s", got, header)
+		}
+	}
+	for i, want := range []*core.HeaderValue{
+		{Key: "x-client-cert-hash", Value: "%DOWNSTREAM_PEER_FINGERPRINT_256%"},
+		{Key: "x-client-uri", Value: "%DOWNSTREAM_PEER_URI_SAN%"},
+	} {
+		got := mutations[i+2].GetAppend().GetHeader()
+		if got.GetKey() != want.Key || got.GetValue() != want.Value {
+			t.Errorf("got header %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package mseingress
+
+import (
+	"sync"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/ali/config/clusterdefaults"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+var (
+	clusterDefaultsOnce sync.Once
+	clusterDefaults     *clusterdefaults.Spec
+)
+
+// ClusterDefaults returns the default resilience settings of the outbound clusters set by PILOT_CLUSTER_DEFAULTS,
+// or nil if unset or invalid.
+func ClusterDefaults() *clusterdefaults.Spec {
+	clusterDefaultsOnce.Do(func() {
+		if alifeatures.ClusterDefaults == "" {
+			return
+		}
+		spec, err := clusterdefaults.Parse(alifeatures.ClusterDefaults)
+		if err != nil {
+			log.Errorf("ignoring PILOT_CLUSTER_DEFAULTS: %v", err)
+			return
+		}
+		clusterDefaults = spec
+	})
+	return clusterDefaults
+}
+
+// ApplyClusterDefaults returns the traffic policy of a cluster with the default connection pool merged under its own,
+// so that the fields set by the DestinationRule take precedence.
+func ApplyClusterDefaults(defaults *clusterdefaults.Spec, policy *networking.TrafficPolicy) *networking.TrafficPolicy {
+	if defaults == nil || defaults.ConnectionPool == nil {
+		return policy
+	}
+	if policy == nil {
+		policy = &networking.TrafficPolicy{}
+	} else {
+		policy = policy.DeepCopy()
+	}
+	connectionPool := proto.Clone(defaults.ConnectionPool).(*networking.ConnectionPoolSettings)
+	if policy.ConnectionPool != nil {
+		proto.Merge(connectionPool, policy.ConnectionPool)
+	}
+	policy.ConnectionPool = connectionPool
+	return policy
+}
+
+// RetryBudget returns the retry budget of the clusters of a DestinationRule, set by its higress.io/retry-budget
+// annotation or else by the defaults.
+func RetryBudget(defaults *clusterdefaults.Spec, destinationRule *config.Config) *clusterdefaults.RetryBudget {
+	if destinationRule != nil {
+		if value, ok := destinationRule.Annotations[constants.RetryBudgetAnnotation]; ok {
+			budget, err := clusterdefaults.ParseRetryBudget(value)
+			if err == nil {
+				return budget
+			}
+			log.Warnf("ignoring retry budget of destination rule %s/%s: %v", destinationRule.Namespace, destinationRule.Name, err)
+		}
+	}
+	if defaults == nil {
+		return nil
+	}
+	return defaults.RetryBudget
+}
+
+// ApplyRetryBudget sets the retry budget of the circuit breaker thresholds of a cluster, replacing their max retries.
+func ApplyRetryBudget(budget *clusterdefaults.RetryBudget, c *cluster.Cluster) {
+	if budget == nil || c.CircuitBreakers == nil {
+		return
+	}
+	for _, threshold := range c.CircuitBreakers.Thresholds {
+		retryBudget := &cluster.CircuitBreakers_Thresholds_RetryBudget{}
+		if budget.BudgetPercent > 0 {
+			retryBudget.BudgetPercent = &typev3.Percent{Value: budget.BudgetPercent}
+		}
+		if budget.MinRetryConcurrency > 0 {
+			retryBudget.MinRetryConcurrency = &wrappers.UInt32Value{Value: budget.MinRetryConcurrency}
+		}
+		threshold.RetryBudget = retryBudget
+	}
+}
@@ -0,0 +1,107 @@
+package mseingress
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/ali/config/clusterdefaults"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestApplyClusterDefaults(t *testing.T) {
+	defaults, err := clusterdefaults.Parse(`{"connectionPool": {"tcp": {"maxConnections": 1024, "connectTimeout": "5s"},
+		"http": {"http2MaxRequests": 2048}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drPolicy := &networking.TrafficPolicy{
+		ConnectionPool: &networking.ConnectionPoolSettings{
+			Tcp: &networking.ConnectionPoolSettings_TCPSettings{MaxConnections: 10},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		defaults *clusterdefaults.Spec
+		policy   *networking.TrafficPolicy
+		want     *networking.ConnectionPoolSettings
+	}{
+		{
+			name:   "no defaults",
+			policy: drPolicy,
+			want:   drPolicy.ConnectionPool,
+		},
+		{
+			name:     "no destination rule",
+			defaults: defaults,
+			want:     defaults.ConnectionPool,
+		},
+		{
+			name:     "destination rule overrides",
+			defaults: defaults,
+			policy:   drPolicy,
+			want: &networking.ConnectionPoolSettings{
+				Tcp:  &networking.ConnectionPoolSettings_TCPSettings{MaxConnections: 10, ConnectTimeout: durationpb.New(5e9)},
+				Http: &networking.ConnectionPoolSettings_HTTPSettings{Http2MaxRequests: 2048},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyClusterDefaults(tt.defaults, tt.policy)
+			if !proto.Equal(got.GetConnectionPool(), tt.want) {
+				t.Errorf("got connection pool %v, want %v", got.GetConnectionPool(), tt.want)
+			}
+		})
+	}
+	if drPolicy.ConnectionPool.Tcp.ConnectTimeout != nil {
+		t.Errorf("the traffic policy of the destination rule was modified")
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	defaults := &clusterdefaults.Spec{RetryBudget: &clusterdefaults.RetryBudget{BudgetPercent: 20}}
+	annotated := &config.Config{Meta: config.Meta{
+		Name:        "dr",
+		Namespace:   "default",
+		Annotations: map[string]string{constants.RetryBudgetAnnotation: `{"budgetPercent": 50}`},
+	}}
+	invalid := &config.Config{Meta: config.Meta{
+		Name:        "dr",
+		Namespace:   "default",
+		Annotations: map[string]string{constants.RetryBudgetAnnotation: `{"budgetPercent": 500}`},
+	}}
+
+	if got := RetryBudget(nil, nil); got != nil {
+		t.Errorf("got %+v without defaults, want none", got)
+	}
+	if got := RetryBudget(defaults, nil); got != defaults.RetryBudget {
+		t.Errorf("got %+v without destination rule, want the defaults", got)
+	}
+	if got := RetryBudget(defaults, annotated); got.BudgetPercent != 50 {
+		t.Errorf("got %+v, want the budget of the annotation", got)
+	}
+	if got := RetryBudget(defaults, invalid); got != defaults.RetryBudget {
+		t.Errorf("got %+v with an invalid annotation, want the defaults", got)
+	}
+}
+
+func TestApplyRetryBudget(t *testing.T) {
+	c := &cluster.Cluster{CircuitBreakers: &cluster.CircuitBreakers{
+		Thresholds: []*cluster.CircuitBreakers_Thresholds{{MaxRetries: &wrappers.UInt32Value{Value: 3}}},
+	}}
+	ApplyRetryBudget(&clusterdefaults.RetryBudget{BudgetPercent: 25}, c)
+	want := &cluster.CircuitBreakers_Thresholds_RetryBudget{BudgetPercent: &typev3.Percent{Value: 25}}
+	if got := c.CircuitBreakers.Thresholds[0].RetryBudget; !proto.Equal(got, want) {
+		t.Errorf("got retry budget %v, want %v", got, want)
+	}
+
+	ApplyRetryBudget(&clusterdefaults.RetryBudget{}, &cluster.Cluster{})
+}
@@ -0,0 +1,41 @@
+package mseingress
+
+import (
+	"sync"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/corsdefaults"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/log"
+)
+
+var (
+	corsDefaultsOnce sync.Once
+	corsDefaults     *corsdefaults.Spec
+)
+
+// CORSDefaults returns the default CORS policy of the gateway routes set by PILOT_CORS_DEFAULTS, or nil if unset or
+// invalid.
+func CORSDefaults() *corsdefaults.Spec {
+	corsDefaultsOnce.Do(func() {
+		if alifeatures.CORSDefaults == "" {
+			return
+		}
+		spec, err := corsdefaults.Parse(alifeatures.CORSDefaults)
+		if err != nil {
+			log.Errorf("ignoring PILOT_CORS_DEFAULTS: %v", err)
+			return
+		}
+		corsDefaults = spec
+	})
+	return corsDefaults
+}
+
+// ApplyCORSDefaults returns the CORS policy of an HTTP route with the defaults applied on the gateways.
+func ApplyCORSDefaults(defaults *corsdefaults.Spec, node *model.Proxy, policy *networking.CorsPolicy) *networking.CorsPolicy {
+	if defaults == nil || node == nil || node.Type != model.Router {
+		return policy
+	}
+	return defaults.Apply(policy)
+}
@@ -0,0 +1,55 @@
+package mseingress
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/corsdefaults"
+)
+
+func TestApplyCORSDefaults(t *testing.T) {
+	defaults, err := corsdefaults.Parse(`{"policy": {"allowOrigins": [{"exact": "https://example.com"}], "allowMethods": ["GET"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := &networking.CorsPolicy{AllowHeaders: []string{"x-token"}}
+	cases := []struct {
+		name     string
+		defaults *corsdefaults.Spec
+		node     *model.Proxy
+		policy   *networking.CorsPolicy
+		want     *networking.CorsPolicy
+	}{
+		{name: "no defaults", node: &model.Proxy{Type: model.Router}, policy: route, want: route},
+		{name: "sidecar", defaults: defaults, node: &model.Proxy{Type: model.SidecarProxy}, want: nil},
+		{name: "gateway without policy", defaults: defaults, node: &model.Proxy{Type: model.Router}, want: defaults.Policy},
+		{
+			name:     "gateway",
+			defaults: defaults,
+			node:     &model.Proxy{Type: model.Router},
+			policy:   route,
+			want: &networking.CorsPolicy{
+				AllowOrigins: defaults.Policy.AllowOrigins,
+				AllowMethods: []string{"GET"},
+				AllowHeaders: []string{"x-token"},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyCORSDefaults(tt.defaults, tt.node, tt.policy)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got %v, want nil", got)
+				}
+				return
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
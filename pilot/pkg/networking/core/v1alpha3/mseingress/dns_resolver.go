@@ -0,0 +1,85 @@
+package mseingress
+
+import (
+	"sync"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	cares "github.com/envoyproxy/go-control-plane/envoy/extensions/network/dns_resolver/cares/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/dnsresolver"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/log"
+)
+
+const caresDNSResolver = "envoy.network.dns_resolvers.cares"
+
+var (
+	dnsResolversOnce sync.Once
+	dnsResolvers     *dnsresolver.Spec
+)
+
+// DNSResolvers returns the DNS resolvers of the clusters of a service, set by its annotation or else by
+// PILOT_DNS_RESOLVERS, or nil to use the resolvers of the system of the proxies.
+func DNSResolvers(service *model.Service) *dnsresolver.Spec {
+	if service != nil && service.Attributes.DNSResolvers != "" {
+		spec, err := dnsresolver.Parse(service.Attributes.DNSResolvers)
+		if err == nil {
+			return spec
+		}
+		log.Warnf("ignoring dns resolvers of service %s: %v", service.Hostname, err)
+	}
+	dnsResolversOnce.Do(func() {
+		if alifeatures.DNSResolvers == "" {
+			return
+		}
+		spec, err := dnsresolver.Parse(alifeatures.DNSResolvers)
+		if err != nil {
+			log.Errorf("ignoring PILOT_DNS_RESOLVERS: %v", err)
+			return
+		}
+		dnsResolvers = spec
+	})
+	return dnsResolvers
+}
+
+// ApplyDNSResolvers sets the c-ares DNS resolver of a STRICT_DNS or LOGICAL_DNS cluster to query the resolvers.
+func ApplyDNSResolvers(spec *dnsresolver.Spec, c *cluster.Cluster) {
+	if spec == nil {
+		return
+	}
+	switch c.GetType() {
+	case cluster.Cluster_STRICT_DNS, cluster.Cluster_LOGICAL_DNS:
+	default:
+		return
+	}
+	resolvers := make([]*core.Address, 0, len(spec.Resolvers))
+	for _, r := range spec.Resolvers {
+		protocol := core.SocketAddress_UDP
+		if spec.TCP {
+			protocol = core.SocketAddress_TCP
+		}
+		resolvers = append(resolvers, &core.Address{
+			Address: &core.Address_SocketAddress{
+				SocketAddress: &core.SocketAddress{
+					Protocol:      protocol,
+					Address:       r.IP,
+					PortSpecifier: &core.SocketAddress_PortValue{PortValue: r.Port},
+				},
+			},
+		})
+	}
+	c.TypedDnsResolverConfig = &core.TypedExtensionConfig{
+		Name: caresDNSResolver,
+		TypedConfig: protoconv.MessageToAny(&cares.CaresDnsResolverConfig{
+			Resolvers: resolvers,
+			DnsResolverOptions: &core.DnsResolverOptions{
+				UseTcpForDnsLookups: spec.TCP,
+				// The hostnames of the ServiceEntries are fully qualified.
+				NoDefaultSearchDomain: true,
+			},
+		}),
+	}
+}
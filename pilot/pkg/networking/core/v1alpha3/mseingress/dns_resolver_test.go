@@ -0,0 +1,65 @@
+package mseingress
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	cares "github.com/envoyproxy/go-control-plane/envoy/extensions/network/dns_resolver/cares/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/dnsresolver"
+)
+
+func TestDNSResolvers(t *testing.T) {
+	service := &model.Service{Attributes: model.ServiceAttributes{DNSResolvers: "tcp://10.0.0.10"}}
+	got := DNSResolvers(service)
+	if got == nil || !got.TCP || got.Resolvers[0].IP != "10.0.0.10" {
+		t.Errorf("unexpected resolvers %+v", got)
+	}
+	// Without annotation nor PILOT_DNS_RESOLVERS, the resolvers of the system are used.
+	if got := DNSResolvers(&model.Service{}); got != nil {
+		t.Errorf("expected no resolvers, got %+v", got)
+	}
+	if got := DNSResolvers(&model.Service{Attributes: model.ServiceAttributes{DNSResolvers: "invalid"}}); got != nil {
+		t.Errorf("expected invalid resolvers ignored, got %+v", got)
+	}
+}
+
+func TestApplyDNSResolvers(t *testing.T) {
+	spec := &dnsresolver.Spec{
+		Resolvers: []dnsresolver.Address{{IP: "127.0.0.1", Port: dnsresolver.DoTForwarderPort}, {IP: "10.0.0.10", Port: 53}},
+		TCP:       true,
+	}
+	c := &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_STRICT_DNS}}
+	ApplyDNSResolvers(spec, c)
+	if c.TypedDnsResolverConfig.GetName() != caresDNSResolver {
+		t.Fatalf("unexpected dns resolver %v", c.TypedDnsResolverConfig)
+	}
+	config := &cares.CaresDnsResolverConfig{}
+	if err := c.TypedDnsResolverConfig.TypedConfig.UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if !config.DnsResolverOptions.UseTcpForDnsLookups || !config.DnsResolverOptions.NoDefaultSearchDomain {
+		t.Errorf("unexpected options %v", config.DnsResolverOptions)
+	}
+	if len(config.Resolvers) != 2 {
+		t.Fatalf("got %d resolvers, want 2", len(config.Resolvers))
+	}
+	first := config.Resolvers[0].GetSocketAddress()
+	if first.Address != "127.0.0.1" || first.GetPortValue() != dnsresolver.DoTForwarderPort || first.Protocol != core.SocketAddress_TCP {
+		t.Errorf("unexpected resolver %v", first)
+	}
+
+	// The other clusters resolve nothing.
+	eds := &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_EDS}}
+	ApplyDNSResolvers(spec, eds)
+	if eds.TypedDnsResolverConfig != nil {
+		t.Errorf("unexpected dns resolver on EDS cluster")
+	}
+	logical := &cluster.Cluster{ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_LOGICAL_DNS}}
+	ApplyDNSResolvers(nil, logical)
+	if logical.TypedDnsResolverConfig != nil {
+		t.Errorf("unexpected dns resolver without resolvers")
+	}
+}
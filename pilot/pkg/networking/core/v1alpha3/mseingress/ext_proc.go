@@ -0,0 +1,132 @@
+package mseingress
+
+import (
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extprocpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/extproc"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	ExtProcFilterName = "envoy.filters.http.ext_proc"
+
+	// ExtProcServiceClusterName is the cluster of the external processor on the gateways.
+	ExtProcServiceClusterName = "outbound|higress-ext-proc-service"
+)
+
+// skipAll is the processing mode of the ext_proc filter, sending nothing to the external processor but on the routes
+// enabling it, which override it.
+var skipAll = &extprocpb.ProcessingMode{
+	RequestHeaderMode:   extprocpb.ProcessingMode_SKIP,
+	ResponseHeaderMode:  extprocpb.ProcessingMode_SKIP,
+	RequestTrailerMode:  extprocpb.ProcessingMode_SKIP,
+	ResponseTrailerMode: extprocpb.ProcessingMode_SKIP,
+}
+
+// BuildExtProcFilter returns the filter calling the external processor, or nil if there is none. It skips all the
+// requests, but on the routes of the virtual services enabling it with the higress.io/ext-proc annotation.
+func BuildExtProcFilter() *http_conn.HttpFilter {
+	if alifeatures.ExtProcServiceAddress == "" {
+		return nil
+	}
+	extProc := &extprocpb.ExternalProcessor{
+		GrpcService: &core.GrpcService{
+			TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+				EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: ExtProcServiceClusterName},
+			},
+		},
+		FailureModeAllow: alifeatures.ExtProcFailureModeAllow,
+		ProcessingMode:   skipAll,
+		StatPrefix:       "higress",
+	}
+	if alifeatures.ExtProcMessageTimeout > 0 {
+		extProc.MessageTimeout = durationpb.New(alifeatures.ExtProcMessageTimeout)
+	}
+	return &http_conn.HttpFilter{
+		Name:       ExtProcFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(extProc)},
+	}
+}
+
+// ApplyExtProcAnnotation enables the external processor on the routes of the virtual service with the policies of
+// its higress.io/ext-proc annotation, overriding the processing mode of the filter with the one of the mesh
+// overridden by the one of the annotation.
+func ApplyExtProcAnnotation(virtualService config.Config, routes []*route.Route) {
+	if alifeatures.ExtProcServiceAddress == "" {
+		return
+	}
+	value, ok := virtualService.Annotations[constants.ExtProcAnnotation]
+	if !ok {
+		return
+	}
+	spec, err := extproc.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring ext proc of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	meshMode := meshProcessingMode()
+	configs := map[*extproc.Policy]*anypb.Any{}
+	for _, r := range routes {
+		policy := spec.RoutePolicy(r.Name)
+		if policy.Disabled {
+			continue
+		}
+		filterConfig, ok := configs[policy]
+		if !ok {
+			mode := meshMode.Merge(spec.ProcessingMode).Merge(policy.ProcessingMode)
+			filterConfig = protoconv.MessageToAny(&extprocpb.ExtProcPerRoute{
+				Override: &extprocpb.ExtProcPerRoute_Overrides{
+					Overrides: &extprocpb.ExtProcOverrides{ProcessingMode: buildProcessingMode(mode)},
+				},
+			})
+			configs[policy] = filterConfig
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*anypb.Any{}
+		}
+		r.TypedPerFilterConfig[ExtProcFilterName] = filterConfig
+	}
+}
+
+// meshProcessingMode returns the processing mode of PILOT_EXT_PROC_PROCESSING_MODE, if valid.
+func meshProcessingMode() extproc.ProcessingMode {
+	if alifeatures.ExtProcProcessingMode == "" {
+		return extproc.ProcessingMode{}
+	}
+	mode, err := extproc.ParseProcessingMode(alifeatures.ExtProcProcessingMode)
+	if err != nil {
+		log.Warnf("ignoring PILOT_EXT_PROC_PROCESSING_MODE: %v", err)
+		return extproc.ProcessingMode{}
+	}
+	return *mode
+}
+
+func buildProcessingMode(mode extproc.ProcessingMode) *extprocpb.ProcessingMode {
+	return &extprocpb.ProcessingMode{
+		RequestHeaderMode:   headerSendMode(mode.RequestHeaders),
+		ResponseHeaderMode:  headerSendMode(mode.ResponseHeaders),
+		RequestBodyMode:     bodySendMode(mode.RequestBody),
+		ResponseBodyMode:    bodySendMode(mode.ResponseBody),
+		RequestTrailerMode:  headerSendMode(mode.RequestTrailers),
+		ResponseTrailerMode: headerSendMode(mode.ResponseTrailers),
+	}
+}
+
+func headerSendMode(mode string) extprocpb.ProcessingMode_HeaderSendMode {
+	return extprocpb.ProcessingMode_HeaderSendMode(extprocpb.ProcessingMode_HeaderSendMode_value[strings.ToUpper(mode)])
+}
+
+func bodySendMode(mode string) extprocpb.ProcessingMode_BodySendMode {
+	return extprocpb.ProcessingMode_BodySendMode(extprocpb.ProcessingMode_BodySendMode_value[strings.ToUpper(mode)])
+}
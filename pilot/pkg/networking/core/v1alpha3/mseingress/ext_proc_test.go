@@ -0,0 +1,104 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extprocpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+)
+
+func TestBuildExtProcFilter(t *testing.T) {
+	if filter := BuildExtProcFilter(); filter != nil {
+		t.Fatalf("got filter %v without an external processor", filter)
+	}
+
+	test.SetForTest(t, &alifeatures.ExtProcServiceAddress, "ext-proc.example.com:9002")
+	test.SetForTest(t, &alifeatures.ExtProcFailureModeAllow, true)
+	filter := BuildExtProcFilter()
+	if filter == nil {
+		t.Fatal("missing ext_proc filter")
+	}
+	extProc := &extprocpb.ExternalProcessor{}
+	if err := filter.GetTypedConfig().UnmarshalTo(extProc); err != nil {
+		t.Fatal(err)
+	}
+	if got := extProc.GetGrpcService().GetEnvoyGrpc().GetClusterName(); got != ExtProcServiceClusterName {
+		t.Errorf("got cluster %q, want %q", got, ExtProcServiceClusterName)
+	}
+	if !extProc.FailureModeAllow {
+		t.Error("got failure mode deny, want allow")
+	}
+	if mode := extProc.GetProcessingMode(); mode.RequestHeaderMode != extprocpb.ProcessingMode_SKIP ||
+		mode.ResponseHeaderMode != extprocpb.ProcessingMode_SKIP {
+		t.Errorf("got processing mode %v, want all skipped", mode)
+	}
+}
+
+func TestApplyExtProcAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.ExtProcAnnotation: `{"processingMode": {"responseHeaders": "skip"}, ` +
+					`"routes": {"healthz": {"disabled": true}, "upload": {"processingMode": {"requestBody": "streamed"}}}}`,
+			},
+		},
+	}
+	newRoutes := func() []*route.Route {
+		return []*route.Route{{Name: "catalog"}, {Name: "healthz"}, {Name: "upload"}}
+	}
+
+	routes := newRoutes()
+	ApplyExtProcAnnotation(virtualService, routes)
+	if got := routes[0].TypedPerFilterConfig; got != nil {
+		t.Errorf("got per-filter configs %v without an external processor", got)
+	}
+
+	test.SetForTest(t, &alifeatures.ExtProcServiceAddress, "ext-proc.example.com:9002")
+	test.SetForTest(t, &alifeatures.ExtProcProcessingMode, `{"requestBody": "buffered"}`)
+	routes = newRoutes()
+	ApplyExtProcAnnotation(virtualService, routes)
+	if got := routes[1].TypedPerFilterConfig; got != nil {
+		t.Errorf("got per-filter configs %v of a disabled route", got)
+	}
+	cases := []struct {
+		route *route.Route
+		want  *extprocpb.ProcessingMode
+	}{
+		{
+			route: routes[0],
+			want: &extprocpb.ProcessingMode{
+				ResponseHeaderMode: extprocpb.ProcessingMode_SKIP,
+				RequestBodyMode:    extprocpb.ProcessingMode_BUFFERED,
+			},
+		},
+		{
+			route: routes[2],
+			want: &extprocpb.ProcessingMode{
+				ResponseHeaderMode: extprocpb.ProcessingMode_SKIP,
+				RequestBodyMode:    extprocpb.ProcessingMode_STREAMED,
+			},
+		},
+	}
+	for _, tt := range cases {
+		filterConfig := tt.route.TypedPerFilterConfig[ExtProcFilterName]
+		if filterConfig == nil {
+			t.Fatalf("missing ext_proc config of route %s", tt.route.Name)
+		}
+		perRoute := &extprocpb.ExtProcPerRoute{}
+		if err := filterConfig.UnmarshalTo(perRoute); err != nil {
+			t.Fatal(err)
+		}
+		got := perRoute.GetOverrides().GetProcessingMode()
+		if got.RequestHeaderMode != tt.want.RequestHeaderMode || got.ResponseHeaderMode != tt.want.ResponseHeaderMode ||
+			got.RequestBodyMode != tt.want.RequestBodyMode || got.ResponseBodyMode != tt.want.ResponseBodyMode {
+			t.Errorf("got processing mode %v of route %s, want %v", got, tt.route.Name, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mseingress
+
+import (
+	"sort"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+)
+
+// DefaultGatewayPatchAccessLogPath is the path of the access log of a gateway patch setting an access log format when
+// no file access log is configured.
+const DefaultGatewayPatchAccessLogPath = "/dev/stdout"
+
+// ApplyGatewayPatchToConnectionManager applies the timeouts, the request headers limit and the access log format of a
+// gateway patch to the HTTP connection manager of its servers.
+func ApplyGatewayPatchToConnectionManager(spec *gatewaypatch.Spec, mesh *meshconfig.MeshConfig,
+	connectionManager *http_conn.HttpConnectionManager,
+) {
+	if spec == nil {
+		return
+	}
+	if t := spec.Timeouts; t != nil {
+		if t.Idle != "" {
+			if connectionManager.CommonHttpProtocolOptions == nil {
+				connectionManager.CommonHttpProtocolOptions = &core.HttpProtocolOptions{}
+			}
+			connectionManager.CommonHttpProtocolOptions.IdleTimeout = durationpb.New(gatewaypatch.Duration(t.Idle))
+		}
+		if t.Request != "" {
+			connectionManager.RequestTimeout = durationpb.New(gatewaypatch.Duration(t.Request))
+		}
+		if t.RequestHeaders != "" {
+			connectionManager.RequestHeadersTimeout = durationpb.New(gatewaypatch.Duration(t.RequestHeaders))
+		}
+		if t.StreamIdle != "" {
+			connectionManager.StreamIdleTimeout = durationpb.New(gatewaypatch.Duration(t.StreamIdle))
+		}
+	}
+	if b := spec.Buffers; b != nil && b.MaxRequestHeadersKb > 0 {
+		connectionManager.MaxRequestHeadersKb = wrapperspb.UInt32(b.MaxRequestHeadersKb)
+	}
+	if spec.AccessLogFormat != "" {
+		connectionManager.AccessLog = buildGatewayPatchAccessLogs(spec.AccessLogFormat, mesh, connectionManager.AccessLog)
+	}
+}
+
+// buildGatewayPatchAccessLogs replaces the file access logs with ones in the text format, or adds one if there is
+// none. The access logs are shared by the listeners, so they are replaced rather than modified.
+func buildGatewayPatchAccessLogs(format string, mesh *meshconfig.MeshConfig, accessLogs []*accesslog.AccessLog) []*accesslog.AccessLog {
+	textMesh := proto.Clone(mesh).(*meshconfig.MeshConfig)
+	textMesh.AccessLogEncoding = meshconfig.MeshConfig_TEXT
+	textMesh.AccessLogFormat = format
+
+	var out []*accesslog.AccessLog
+	replaced := false
+	for _, al := range accessLogs {
+		fl := &fileaccesslog.FileAccessLog{}
+		if al.GetName() != wellknown.FileAccessLog || al.GetTypedConfig().UnmarshalTo(fl) != nil {
+			out = append(out, al)
+			continue
+		}
+		patched := model.FileAccessLogFromMeshConfig(fl.Path, textMesh)
+		patched.Filter = al.Filter
+		out = append(out, patched)
+		replaced = true
+	}
+	if !replaced {
+		out = append(out, model.FileAccessLogFromMeshConfig(DefaultGatewayPatchAccessLogPath, textMesh))
+	}
+	return out
+}
+
+// ApplyGatewayPatchToRoutes applies the header operations of a gateway patch to the routes of its servers.
+func ApplyGatewayPatchToRoutes(spec *gatewaypatch.Spec, routes []*route.Route) {
+	if spec == nil || spec.Headers == nil {
+		return
+	}
+	request, response := spec.Headers.Request, spec.Headers.Response
+	for _, r := range routes {
+		if request != nil {
+			r.RequestHeadersToAdd = append(r.RequestHeadersToAdd, buildHeaderValueOptions(request)...)
+			r.RequestHeadersToRemove = append(r.RequestHeadersToRemove, request.Remove...)
+		}
+		if response != nil {
+			r.ResponseHeadersToAdd = append(r.ResponseHeadersToAdd, buildHeaderValueOptions(response)...)
+			r.ResponseHeadersToRemove = append(r.ResponseHeadersToRemove, response.Remove...)
+		}
+	}
+}
+
+func buildHeaderValueOptions(operations *gatewaypatch.HeaderOperations) []*core.HeaderValueOption {
+	var options []*core.HeaderValueOption
+	add := func(headers map[string]string, action core.HeaderValueOption_HeaderAppendAction) {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			options = append(options, &core.HeaderValueOption{
+				Header:       &core.HeaderValue{Key: name, Value: headers[name]},
+				AppendAction: action,
+			})
+		}
+	}
+	add(operations.Set, core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD)
+	add(operations.Add, core.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD)
+	return options
+}
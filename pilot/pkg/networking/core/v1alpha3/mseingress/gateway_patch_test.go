@@ -0,0 +1,122 @@
+package mseingress
+
+import (
+	"testing"
+	"time"
+
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	fileaccesslog "github.com/envoyproxy/go-control-plane/envoy/extensions/access_loggers/file/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/config/mesh"
+)
+
+func TestApplyGatewayPatchToConnectionManager(t *testing.T) {
+	spec, err := gatewaypatch.Parse(`{"timeouts": {"idle": "1h", "request": "30s", "requestHeaders": "10s", "streamIdle": "5m"},
+"buffers": {"maxRequestHeadersKb": 96}, "accessLogFormat": "%RESPONSE_CODE%\n"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mesh.DefaultMeshConfig()
+	m.AccessLogFile = "/var/log/access.log"
+	m.AccessLogEncoding = meshconfig.MeshConfig_JSON
+	shared := model.FileAccessLogFromMeshConfig(m.AccessLogFile, m)
+	connectionManager := &http_conn.HttpConnectionManager{
+		StreamIdleTimeout: durationpb.New(0),
+		AccessLog:         []*accesslog.AccessLog{shared},
+	}
+
+	ApplyGatewayPatchToConnectionManager(spec, m, connectionManager)
+	if got := connectionManager.CommonHttpProtocolOptions.GetIdleTimeout().AsDuration(); got != time.Hour {
+		t.Errorf("got idle timeout %v, want 1h", got)
+	}
+	if got := connectionManager.RequestTimeout.AsDuration(); got != 30*time.Second {
+		t.Errorf("got request timeout %v, want 30s", got)
+	}
+	if got := connectionManager.RequestHeadersTimeout.AsDuration(); got != 10*time.Second {
+		t.Errorf("got request headers timeout %v, want 10s", got)
+	}
+	if got := connectionManager.StreamIdleTimeout.AsDuration(); got != 5*time.Minute {
+		t.Errorf("got stream idle timeout %v, want 5m", got)
+	}
+	if got := connectionManager.MaxRequestHeadersKb.GetValue(); got != 96 {
+		t.Errorf("got max request headers %d, want 96", got)
+	}
+	if len(connectionManager.AccessLog) != 1 {
+		t.Fatalf("got access logs %v, want 1", connectionManager.AccessLog)
+	}
+	fl := &fileaccesslog.FileAccessLog{}
+	if err := connectionManager.AccessLog[0].GetTypedConfig().UnmarshalTo(fl); err != nil {
+		t.Fatal(err)
+	}
+	if fl.Path != m.AccessLogFile {
+		t.Errorf("got access log path %q, want %q", fl.Path, m.AccessLogFile)
+	}
+	if got := fl.GetLogFormat().GetTextFormatSource().GetInlineString(); got != "%RESPONSE_CODE%\n" {
+		t.Errorf("got access log format %q", got)
+	}
+	if err := shared.GetTypedConfig().UnmarshalTo(fl); err != nil {
+		t.Fatal(err)
+	}
+	if fl.GetLogFormat().GetJsonFormat() == nil {
+		t.Error("the shared access log was modified")
+	}
+
+	connectionManager = &http_conn.HttpConnectionManager{}
+	ApplyGatewayPatchToConnectionManager(spec, mesh.DefaultMeshConfig(), connectionManager)
+	if len(connectionManager.AccessLog) != 1 || connectionManager.AccessLog[0].Name != wellknown.FileAccessLog {
+		t.Fatalf("got access logs %v, want a file access log", connectionManager.AccessLog)
+	}
+	if err := connectionManager.AccessLog[0].GetTypedConfig().UnmarshalTo(fl); err != nil {
+		t.Fatal(err)
+	}
+	if fl.Path != DefaultGatewayPatchAccessLogPath {
+		t.Errorf("got access log path %q, want %q", fl.Path, DefaultGatewayPatchAccessLogPath)
+	}
+
+	connectionManager = &http_conn.HttpConnectionManager{StreamIdleTimeout: durationpb.New(0)}
+	ApplyGatewayPatchToConnectionManager(nil, m, connectionManager)
+	if connectionManager.StreamIdleTimeout.AsDuration() != 0 || len(connectionManager.AccessLog) != 0 {
+		t.Errorf("got %v without a patch", connectionManager)
+	}
+}
+
+func TestApplyGatewayPatchToRoutes(t *testing.T) {
+	spec, err := gatewaypatch.Parse(`{"headers": {
+"request": {"set": {"x-b": "2", "x-a": "1"}, "remove": ["x-internal"]},
+"response": {"add": {"x-served-by": "higress"}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []*route.Route{{
+		Name:                "route",
+		RequestHeadersToAdd: []*core.HeaderValueOption{{Header: &core.HeaderValue{Key: "x-route", Value: "route"}}},
+	}}
+
+	ApplyGatewayPatchToRoutes(spec, routes)
+	r := routes[0]
+	if len(r.RequestHeadersToAdd) != 3 {
+		t.Fatalf("got request headers %v, want 3", r.RequestHeadersToAdd)
+	}
+	for i, want := range []string{"x-route", "x-a", "x-b"} {
+		if got := r.RequestHeadersToAdd[i].Header.Key; got != want {
+			t.Errorf("got request header %d %q, want %q", i, got, want)
+		}
+	}
+	if got := r.RequestHeadersToAdd[1].AppendAction; got != core.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD {
+		t.Errorf("got append action %v of a set header", got)
+	}
+	if len(r.RequestHeadersToRemove) != 1 || r.RequestHeadersToRemove[0] != "x-internal" {
+		t.Errorf("got removed request headers %v", r.RequestHeadersToRemove)
+	}
+	if len(r.ResponseHeadersToAdd) != 1 || r.ResponseHeadersToAdd[0].AppendAction != core.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD {
+		t.Errorf("got response headers %v", r.ResponseHeadersToAdd)
+	}
+}
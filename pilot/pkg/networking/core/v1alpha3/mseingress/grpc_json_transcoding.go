@@ -0,0 +1,108 @@
+package mseingress
+
+import (
+	"encoding/json"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/grpctranscoding"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const GrpcJSONTranscoderFilterName = "envoy.filters.http.grpc_json_transcoder"
+
+// BuildGrpcJSONTranscoderFilter returns the filter transcoding the JSON requests to gRPC, or nil if disabled. It
+// transcodes no service, but on the routes of the virtual services enabling it with the
+// higress.io/grpc-json-transcoding annotation, which override it.
+func BuildGrpcJSONTranscoderFilter() *http_conn.HttpFilter {
+	if !alifeatures.EnableGrpcJSONTranscoding {
+		return nil
+	}
+	// The descriptor set is required, but not read without services.
+	disabled := &transcoder.GrpcJsonTranscoder{
+		DescriptorSet: &transcoder.GrpcJsonTranscoder_ProtoDescriptorBin{ProtoDescriptorBin: []byte{}},
+	}
+	return &http_conn.HttpFilter{
+		Name:       GrpcJSONTranscoderFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(disabled)},
+	}
+}
+
+// ApplyGrpcJSONTranscodingAnnotation transcodes the routes of the virtual service with the transcoding of its
+// higress.io/grpc-json-transcoding annotation. The routes whose descriptor set is not fetched yet are left as is
+// until it is, and those transcoding services missing from their descriptor set are left as is, as Envoy would
+// reject them.
+func ApplyGrpcJSONTranscodingAnnotation(virtualService config.Config, descriptorSets model.DescriptorSetStore, routes []*route.Route) {
+	if !alifeatures.EnableGrpcJSONTranscoding {
+		return
+	}
+	value, ok := virtualService.Annotations[constants.GrpcJSONTranscodingAnnotation]
+	if !ok {
+		return
+	}
+	spec, err := grpctranscoding.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring grpc json transcoding of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	configs := map[string]*anypb.Any{}
+	for _, r := range routes {
+		transcoding := spec.RouteTranscoding(r.Name)
+		if !transcoding.Enabled() {
+			continue
+		}
+		// The transcoding was parsed from JSON, so it marshals back.
+		b, _ := json.Marshal(transcoding)
+		key := string(b)
+		filterConfig, ok := configs[key]
+		if !ok {
+			source := grpctranscoding.ResolveSource(transcoding.Descriptor, virtualService.Namespace)
+			set := descriptorSets.DescriptorSet(source)
+			if set == nil {
+				log.Debugf("skipping grpc json transcoding of route %s of virtual service %s/%s: descriptor set %s "+
+					"is not available", r.Name, virtualService.Namespace, virtualService.Name, source)
+				continue
+			}
+			if missing := missingServices(set, transcoding.Services); len(missing) > 0 {
+				log.Warnf("skipping grpc json transcoding of route %s of virtual service %s/%s: descriptor set %s "+
+					"has no service %v", r.Name, virtualService.Namespace, virtualService.Name, source, missing)
+				continue
+			}
+			filterConfig = protoconv.MessageToAny(buildGrpcJSONTranscoder(set, transcoding))
+			configs[key] = filterConfig
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*anypb.Any{}
+		}
+		r.TypedPerFilterConfig[GrpcJSONTranscoderFilterName] = filterConfig
+	}
+}
+
+func missingServices(set *model.DescriptorSet, services []string) []string {
+	var missing []string
+	for _, service := range services {
+		if !set.Services.Contains(service) {
+			missing = append(missing, service)
+		}
+	}
+	return missing
+}
+
+func buildGrpcJSONTranscoder(set *model.DescriptorSet, transcoding grpctranscoding.Transcoding) *transcoder.GrpcJsonTranscoder {
+	return &transcoder.GrpcJsonTranscoder{
+		DescriptorSet:                &transcoder.GrpcJsonTranscoder_ProtoDescriptorBin{ProtoDescriptorBin: set.Bin},
+		Services:                     transcoding.Services,
+		AutoMapping:                  transcoding.AutoMapping,
+		ConvertGrpcStatus:            transcoding.ConvertGrpcStatus,
+		IgnoreUnknownQueryParameters: transcoding.IgnoreUnknownQueryParameters,
+		IgnoredQueryParameters:       transcoding.IgnoredQueryParameters,
+	}
+}
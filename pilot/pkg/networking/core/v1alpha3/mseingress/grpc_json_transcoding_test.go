@@ -0,0 +1,97 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/grpc_json_transcoder/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type fakeDescriptorSets map[string]*model.DescriptorSet
+
+func (f fakeDescriptorSets) DescriptorSet(source string) *model.DescriptorSet {
+	return f[source]
+}
+
+func TestBuildGrpcJSONTranscoderFilter(t *testing.T) {
+	if filter := BuildGrpcJSONTranscoderFilter(); filter != nil {
+		t.Fatalf("got filter %v with transcoding disabled", filter)
+	}
+
+	test.SetForTest(t, &alifeatures.EnableGrpcJSONTranscoding, true)
+	filter := BuildGrpcJSONTranscoderFilter()
+	if filter == nil {
+		t.Fatal("missing grpc_json_transcoder filter")
+	}
+	transcoding := &transcoder.GrpcJsonTranscoder{}
+	if err := filter.GetTypedConfig().UnmarshalTo(transcoding); err != nil {
+		t.Fatal(err)
+	}
+	if err := transcoding.Validate(); err != nil {
+		t.Errorf("invalid filter config: %v", err)
+	}
+	if len(transcoding.Services) != 0 {
+		t.Errorf("got services %v, want none", transcoding.Services)
+	}
+}
+
+func TestApplyGrpcJSONTranscodingAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.GrpcJSONTranscodingAnnotation: `{"descriptor": "configmap://greeter/greeter.pb", ` +
+					`"services": ["helloworld.Greeter"], "routes": {"healthz": {"disabled": true}, ` +
+					`"echo": {"services": ["echo.Echo"]}, "legacy": {"descriptor": "https://example.com/legacy.pb"}}}`,
+			},
+		},
+	}
+	descriptorSets := fakeDescriptorSets{
+		"configmap://default/greeter/greeter.pb": {
+			Bin:      []byte("greeter"),
+			Services: sets.New("helloworld.Greeter"),
+		},
+	}
+	newRoutes := func() []*route.Route {
+		return []*route.Route{{Name: "greeter"}, {Name: "healthz"}, {Name: "echo"}, {Name: "legacy"}}
+	}
+
+	routes := newRoutes()
+	ApplyGrpcJSONTranscodingAnnotation(virtualService, descriptorSets, routes)
+	if got := routes[0].TypedPerFilterConfig; got != nil {
+		t.Errorf("got per-filter configs %v with transcoding disabled", got)
+	}
+
+	test.SetForTest(t, &alifeatures.EnableGrpcJSONTranscoding, true)
+	routes = newRoutes()
+	ApplyGrpcJSONTranscodingAnnotation(virtualService, descriptorSets, routes)
+	filterConfig := routes[0].TypedPerFilterConfig[GrpcJSONTranscoderFilterName]
+	if filterConfig == nil {
+		t.Fatal("missing transcoding of route greeter")
+	}
+	transcoding := &transcoder.GrpcJsonTranscoder{}
+	if err := filterConfig.UnmarshalTo(transcoding); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(transcoding.GetProtoDescriptorBin()); got != "greeter" {
+		t.Errorf("got descriptor set %q, want greeter", got)
+	}
+	if len(transcoding.Services) != 1 || transcoding.Services[0] != "helloworld.Greeter" {
+		t.Errorf("got services %v, want helloworld.Greeter", transcoding.Services)
+	}
+	// healthz is disabled, echo transcodes a service missing from the descriptor set, and the descriptor set of
+	// legacy is not fetched.
+	for _, r := range routes[1:] {
+		if got := r.TypedPerFilterConfig; got != nil {
+			t.Errorf("got per-filter configs %v on route %s", got, r.Name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mseingress
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbachttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	rbacnetworkpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/ipaccess"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// IPAccessPolicyName is the name of the RBAC policies of the higress.io/ip-access annotations, reported as the
+	// effective policy in the dynamic metadata of the RBAC filters.
+	IPAccessPolicyName = "ip-access"
+	// IPAccessStatPrefix prefixes the stats of the RBAC network filters of the gateways, counting the connections
+	// denied as ip_access.rbac.denied, or ip_access.rbac.shadow_denied in shadow mode.
+	IPAccessStatPrefix = "ip_access."
+
+	networkRBACFilterName = "envoy.filters.network.rbac"
+)
+
+// ipAccessPrincipal returns the principal matching the clients of a CIDR by the source address of the policy.
+func ipAccessPrincipal(policy *ipaccess.Policy, cidr *core.CidrRange) *rbacpb.Principal {
+	if policy.Source == ipaccess.SourceDirect {
+		return &rbacpb.Principal{Identifier: &rbacpb.Principal_DirectRemoteIp{DirectRemoteIp: cidr}}
+	}
+	return &rbacpb.Principal{Identifier: &rbacpb.Principal_RemoteIp{RemoteIp: cidr}}
+}
+
+// buildIPAccessRBAC returns the DENY rules of the RBAC filters matching the clients denied by an IP access policy.
+func buildIPAccessRBAC(policy *ipaccess.Policy) *rbacpb.RBAC {
+	var principals []*rbacpb.Principal
+	for _, prefix := range policy.DenyCIDRs() {
+		principals = append(principals, ipAccessPrincipal(policy, &core.CidrRange{
+			AddressPrefix: prefix.Addr().String(),
+			PrefixLen:     wrapperspb.UInt32(uint32(prefix.Bits())),
+		}))
+	}
+	var allowed []*rbacpb.Principal
+	for _, prefix := range policy.AllowCIDRs() {
+		allowed = append(allowed, ipAccessPrincipal(policy, &core.CidrRange{
+			AddressPrefix: prefix.Addr().String(),
+			PrefixLen:     wrapperspb.UInt32(uint32(prefix.Bits())),
+		}))
+	}
+	if len(allowed) > 0 {
+		principals = append(principals, principalNot(principalOr(allowed)))
+	}
+	return &rbacpb.RBAC{
+		Action: rbacpb.RBAC_DENY,
+		Policies: map[string]*rbacpb.Policy{
+			IPAccessPolicyName: {
+				Permissions: []*rbacpb.Permission{permissionAny()},
+				Principals:  principals,
+			},
+		},
+	}
+}
+
+// BuildIPAccessNetworkFilter returns the RBAC network filter closing the connections of the clients denied by the
+// higress.io/ip-access annotation of a gateway, before any other filter of its listeners, or only counting them in
+// shadow mode.
+func BuildIPAccessNetworkFilter(spec *ipaccess.Spec) *listener.Filter {
+	if spec == nil || !spec.Enabled() {
+		return nil
+	}
+	rbac := &rbacnetworkpb.RBAC{StatPrefix: IPAccessStatPrefix}
+	if spec.Shadow {
+		rbac.ShadowRules = buildIPAccessRBAC(&spec.Policy)
+	} else {
+		rbac.Rules = buildIPAccessRBAC(&spec.Policy)
+	}
+	return &listener.Filter{
+		Name:       networkRBACFilterName,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(rbac)},
+	}
+}
+
+// ApplyIPAccessAnnotation denies the clients of the routes of the virtual service with the policies of its
+// higress.io/ip-access annotation, added to the per-route config of the RBAC filter of the route, else of its virtual
+// host, else of the gateway, so that their policies still apply.
+func ApplyIPAccessAnnotation(globalHTTPFilters *GlobalHTTPFilters, virtualService config.Config, routes []*route.Route) {
+	value, ok := virtualService.Annotations[constants.IPAccessAnnotation]
+	if !ok {
+		return
+	}
+	spec, err := ipaccess.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring ip access of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	var base *rbachttppb.RBACPerRoute
+	configs := map[*ipaccess.Policy]*anypb.Any{}
+	for _, r := range routes {
+		policy := spec.RoutePolicy(r.Name)
+		if !policy.Enabled() {
+			continue
+		}
+		if existing := r.TypedPerFilterConfig[wellknown.HTTPRoleBasedAccessControl]; existing != nil {
+			perRoute := &rbachttppb.RBACPerRoute{}
+			if err := existing.UnmarshalTo(perRoute); err != nil {
+				log.Warnf("ignoring ip access of route %s of virtual service %s/%s: %v",
+					r.Name, virtualService.Namespace, virtualService.Name, err)
+				continue
+			}
+			addIPAccessPolicy(perRoute, policy)
+			r.TypedPerFilterConfig[wellknown.HTTPRoleBasedAccessControl] = protoconv.MessageToAny(perRoute)
+			continue
+		}
+		filterConfig, ok := configs[policy]
+		if !ok {
+			if base == nil {
+				base = ipAccessBaseConfig(globalHTTPFilters, virtualService)
+			}
+			perRoute := proto.Clone(base).(*rbachttppb.RBACPerRoute)
+			addIPAccessPolicy(perRoute, policy)
+			filterConfig = protoconv.MessageToAny(perRoute)
+			configs[policy] = filterConfig
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*anypb.Any{}
+		}
+		r.TypedPerFilterConfig[wellknown.HTTPRoleBasedAccessControl] = filterConfig
+	}
+}
+
+// ipAccessBaseConfig returns the per-route config of the RBAC filter that the routes of the virtual service without
+// one inherit: the one of its virtual host, else the policies of the gateway.
+func ipAccessBaseConfig(globalHTTPFilters *GlobalHTTPFilters, virtualService config.Config) *rbachttppb.RBACPerRoute {
+	if vhost := ConstructTypedPerFilterConfigForVHost(globalHTTPFilters, virtualService); vhost != nil {
+		if existing := vhost[wellknown.HTTPRoleBasedAccessControl]; existing != nil {
+			perRoute := &rbachttppb.RBACPerRoute{}
+			if err := existing.UnmarshalTo(perRoute); err == nil {
+				return perRoute
+			}
+		}
+	}
+	perRoute := &rbachttppb.RBACPerRoute{Rbac: &rbachttppb.RBAC{}}
+	if !globalHTTPFilters.isRBACEmpty() {
+		perRoute.Rbac.Rules = globalHTTPFilters.rbac.Rules
+		perRoute.Rbac.ShadowRules = globalHTTPFilters.rbac.ShadowRules
+	}
+	return perRoute
+}
+
+// addIPAccessPolicy adds the IP access policy to the DENY rules of a per-route config of the RBAC filter, or to its
+// shadow rules in shadow mode.
+func addIPAccessPolicy(perRoute *rbachttppb.RBACPerRoute, policy *ipaccess.Policy) {
+	if perRoute.Rbac == nil {
+		perRoute.Rbac = &rbachttppb.RBAC{}
+	}
+	rules := &perRoute.Rbac.Rules
+	if policy.Shadow {
+		rules = &perRoute.Rbac.ShadowRules
+	}
+	if *rules == nil {
+		*rules = &rbacpb.RBAC{Action: rbacpb.RBAC_DENY, Policies: map[string]*rbacpb.Policy{}}
+	}
+	if (*rules).Policies == nil {
+		(*rules).Policies = map[string]*rbacpb.Policy{}
+	}
+	(*rules).Policies[IPAccessPolicyName] = buildIPAccessRBAC(policy).Policies[IPAccessPolicyName]
+}
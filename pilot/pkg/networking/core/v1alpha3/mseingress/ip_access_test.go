@@ -0,0 +1,118 @@
+package mseingress
+
+import (
+	"testing"
+
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	rbachttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	rbacnetworkpb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/ipaccess"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestBuildIPAccessNetworkFilter(t *testing.T) {
+	if filter := BuildIPAccessNetworkFilter(nil); filter != nil {
+		t.Fatalf("got filter %v without ip access", filter)
+	}
+	spec, err := ipaccess.ParseGateway(`{"allow": ["10.0.0.0/8"], "deny": ["10.0.0.1"], "source": "direct", "shadow": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := BuildIPAccessNetworkFilter(spec)
+	rbac := &rbacnetworkpb.RBAC{}
+	if err := filter.GetTypedConfig().UnmarshalTo(rbac); err != nil {
+		t.Fatal(err)
+	}
+	if rbac.StatPrefix != IPAccessStatPrefix {
+		t.Errorf("got stat prefix %q, want %q", rbac.StatPrefix, IPAccessStatPrefix)
+	}
+	if rbac.Rules != nil {
+		t.Errorf("got rules %v in shadow mode", rbac.Rules)
+	}
+	principals := rbac.GetShadowRules().GetPolicies()[IPAccessPolicyName].GetPrincipals()
+	if len(principals) != 2 {
+		t.Fatalf("got principals %v, want the denied CIDR and the CIDRs not allowed", principals)
+	}
+	if got := principals[0].GetDirectRemoteIp(); got.GetAddressPrefix() != "10.0.0.1" || got.GetPrefixLen().GetValue() != 32 {
+		t.Errorf("got denied principal %v, want 10.0.0.1/32", principals[0])
+	}
+	allowed := principals[1].GetNotId().GetOrIds().GetIds()
+	if len(allowed) != 1 || allowed[0].GetDirectRemoteIp().GetAddressPrefix() != "10.0.0.0" {
+		t.Errorf("got principal %v, want any but 10.0.0.0/8", principals[1])
+	}
+}
+
+func TestApplyIPAccessAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.IPAccessAnnotation: `{"deny": ["192.168.0.0/16"], "routes": {"admin": {"allow": ["10.0.0.0/8"], "shadow": true}, "public": {"disabled": true}}}`,
+			},
+		},
+		Spec: &networking.VirtualService{},
+	}
+	globalHTTPFilters := &GlobalHTTPFilters{rbac: &rbachttppb.RBAC{
+		Rules: &rbacpb.RBAC{Action: rbacpb.RBAC_DENY, Policies: map[string]*rbacpb.Policy{"global": rbacPolicyMatchNever}},
+	}}
+	routes := []*route.Route{
+		headerRoute("api", "", ""),
+		headerRoute("admin", "", ""),
+		headerRoute("public", "", ""),
+		headerRoute("filtered", "", ""),
+	}
+	routes[3].TypedPerFilterConfig = map[string]*anypb.Any{
+		wellknown.HTTPRoleBasedAccessControl: protoconv.MessageToAny(&rbachttppb.RBACPerRoute{Rbac: &rbachttppb.RBAC{
+			Rules: &rbacpb.RBAC{Action: rbacpb.RBAC_DENY, Policies: map[string]*rbacpb.Policy{IPAccessControl: rbacPolicyMatchNever}},
+		}}),
+	}
+	ApplyIPAccessAnnotation(globalHTTPFilters, virtualService, routes)
+
+	perRoute := func(r *route.Route) *rbachttppb.RBAC {
+		filterConfig := r.TypedPerFilterConfig[wellknown.HTTPRoleBasedAccessControl]
+		if filterConfig == nil {
+			t.Fatalf("missing rbac config on route %s", r.Name)
+		}
+		out := &rbachttppb.RBACPerRoute{}
+		if err := filterConfig.UnmarshalTo(out); err != nil {
+			t.Fatal(err)
+		}
+		return out.Rbac
+	}
+	api := perRoute(routes[0])
+	for _, name := range []string{"global", IPAccessPolicyName} {
+		if api.GetRules().GetPolicies()[name] == nil {
+			t.Errorf("missing policy %s on route api", name)
+		}
+	}
+	admin := perRoute(routes[1])
+	if admin.GetRules().GetPolicies()[IPAccessPolicyName] != nil {
+		t.Error("got ip access enforced on route admin, want shadow")
+	}
+	if admin.GetShadowRules().GetPolicies()[IPAccessPolicyName] == nil {
+		t.Error("missing shadow ip access on route admin")
+	}
+	if admin.GetRules().GetPolicies()["global"] == nil {
+		t.Error("missing global policy on route admin")
+	}
+	if got := routes[2].TypedPerFilterConfig; got != nil {
+		t.Errorf("got per-filter configs %v on route public, want none", got)
+	}
+	filtered := perRoute(routes[3])
+	for _, name := range []string{IPAccessControl, IPAccessPolicyName} {
+		if filtered.GetRules().GetPolicies()[name] == nil {
+			t.Errorf("missing policy %s on route filtered", name)
+		}
+	}
+	if globalHTTPFilters.rbac.Rules.Policies[IPAccessPolicyName] != nil {
+		t.Error("the global policies were modified")
+	}
+}
@@ -0,0 +1,119 @@
+package mseingress
+
+import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	cswrr "github.com/envoyproxy/go-control-plane/envoy/extensions/load_balancing_policies/client_side_weighted_round_robin/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/lbpolicy"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	clientSideWeightedRoundRobinPolicy = "envoy.load_balancing_policies.client_side_weighted_round_robin"
+
+	activeRequestBiasRuntimeKey   = "upstream.least_request.active_request_bias"
+	slowStartAggressionRuntimeKey = "upstream.slow_start.aggression"
+)
+
+// LoadBalancerPolicy returns the load balancing policy of the clusters of a DestinationRule set by its
+// higress.io/load-balancer-policy annotation, or nil.
+func LoadBalancerPolicy(destinationRule *config.Config) *lbpolicy.Spec {
+	if destinationRule == nil {
+		return nil
+	}
+	value, ok := destinationRule.Annotations[constants.LoadBalancerPolicyAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := lbpolicy.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring load balancer policy of destination rule %s/%s: %v", destinationRule.Namespace,
+			destinationRule.Name, err)
+		return nil
+	}
+	return spec
+}
+
+// ApplyLoadBalancerPolicy replaces the load balancer of a cluster with the policy, unless the cluster hashes
+// consistently or balances the load itself.
+func ApplyLoadBalancerPolicy(spec *lbpolicy.Spec, c *cluster.Cluster) {
+	if spec == nil {
+		return
+	}
+	switch c.GetLbPolicy() {
+	case cluster.Cluster_CLUSTER_PROVIDED, cluster.Cluster_MAGLEV, cluster.Cluster_RING_HASH:
+		return
+	}
+	switch spec.Policy {
+	case lbpolicy.LeastRequest:
+		c.LbPolicy = cluster.Cluster_LEAST_REQUEST
+		config := &cluster.Cluster_LeastRequestLbConfig{}
+		if spec.ChoiceCount != 0 {
+			config.ChoiceCount = wrapperspb.UInt32(spec.ChoiceCount)
+		}
+		if spec.ActiveRequestBias != nil {
+			config.ActiveRequestBias = &core.RuntimeDouble{
+				DefaultValue: *spec.ActiveRequestBias,
+				RuntimeKey:   activeRequestBiasRuntimeKey,
+			}
+		}
+		if spec.SlowStart != nil {
+			config.SlowStartConfig = buildSlowStartConfig(spec.SlowStart)
+		} else if existing := c.GetLeastRequestLbConfig(); existing != nil {
+			// Keep the slow start of the warmup duration of the traffic policy.
+			config.SlowStartConfig = existing.SlowStartConfig
+		}
+		c.LbConfig = &cluster.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: config}
+	case lbpolicy.ClientSideWeightedRoundRobin:
+		config := &cswrr.ClientSideWeightedRoundRobin{
+			EnableOobLoadReport:    wrapperspb.Bool(spec.EnableOOBLoadReport),
+			OobReportingPeriod:     buildDuration(spec.OOBReportingPeriod),
+			BlackoutPeriod:         buildDuration(spec.BlackoutPeriod),
+			WeightExpirationPeriod: buildDuration(spec.WeightExpirationPeriod),
+			WeightUpdatePeriod:     buildDuration(spec.WeightUpdatePeriod),
+		}
+		if spec.ErrorUtilizationPenalty != nil {
+			config.ErrorUtilizationPenalty = wrapperspb.Float(float32(*spec.ErrorUtilizationPenalty))
+		}
+		// The load balancing policy takes precedence over the lb_policy of the cluster.
+		c.LbConfig = nil
+		c.LoadBalancingPolicy = &cluster.LoadBalancingPolicy{
+			Policies: []*cluster.LoadBalancingPolicy_Policy{{
+				TypedExtensionConfig: &core.TypedExtensionConfig{
+					Name:        clientSideWeightedRoundRobinPolicy,
+					TypedConfig: protoconv.MessageToAny(config),
+				},
+			}},
+		}
+	}
+}
+
+func buildSlowStartConfig(slowStart *lbpolicy.SlowStart) *cluster.Cluster_SlowStartConfig {
+	config := &cluster.Cluster_SlowStartConfig{
+		SlowStartWindow: buildDuration(slowStart.Window),
+	}
+	if slowStart.Aggression != nil {
+		config.Aggression = &core.RuntimeDouble{
+			DefaultValue: *slowStart.Aggression,
+			RuntimeKey:   slowStartAggressionRuntimeKey,
+		}
+	}
+	if slowStart.MinWeightPercent != nil {
+		config.MinWeightPercent = &xdstype.Percent{Value: *slowStart.MinWeightPercent}
+	}
+	return config
+}
+
+func buildDuration(value string) *durationpb.Duration {
+	if value == "" {
+		return nil
+	}
+	return durationpb.New(lbpolicy.Duration(value))
+}
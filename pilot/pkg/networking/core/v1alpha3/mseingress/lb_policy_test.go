@@ -0,0 +1,102 @@
+package mseingress
+
+import (
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	cswrr "github.com/envoyproxy/go-control-plane/envoy/extensions/load_balancing_policies/client_side_weighted_round_robin/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pkg/ali/config/lbpolicy"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestLoadBalancerPolicy(t *testing.T) {
+	destinationRule := &config.Config{
+		Meta: config.Meta{
+			Name:        "reviews",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.LoadBalancerPolicyAnnotation: `{"policy": "least_request"}`},
+		},
+	}
+	if spec := LoadBalancerPolicy(destinationRule); spec == nil || spec.Policy != lbpolicy.LeastRequest {
+		t.Errorf("got policy %v, want least_request", spec)
+	}
+	destinationRule.Annotations[constants.LoadBalancerPolicyAnnotation] = `{"policy": "peak"}`
+	if spec := LoadBalancerPolicy(destinationRule); spec != nil {
+		t.Errorf("got policy %v for an invalid annotation", spec)
+	}
+	if spec := LoadBalancerPolicy(nil); spec != nil {
+		t.Errorf("got policy %v without destination rule", spec)
+	}
+}
+
+func TestApplyLoadBalancerPolicy(t *testing.T) {
+	bias := 0.5
+	aggression := 2.0
+	c := &cluster.Cluster{
+		LbPolicy: cluster.Cluster_ROUND_ROBIN,
+	}
+	ApplyLoadBalancerPolicy(&lbpolicy.Spec{
+		Policy:            lbpolicy.LeastRequest,
+		ChoiceCount:       3,
+		ActiveRequestBias: &bias,
+		SlowStart:         &lbpolicy.SlowStart{Window: "30s", Aggression: &aggression},
+	}, c)
+	if c.LbPolicy != cluster.Cluster_LEAST_REQUEST {
+		t.Errorf("got lb policy %v, want LEAST_REQUEST", c.LbPolicy)
+	}
+	lrConfig := c.GetLeastRequestLbConfig()
+	if lrConfig.GetChoiceCount().GetValue() != 3 || lrConfig.GetActiveRequestBias().GetDefaultValue() != 0.5 {
+		t.Errorf("unexpected least request config %v", lrConfig)
+	}
+	if lrConfig.GetSlowStartConfig().GetSlowStartWindow().AsDuration() != 30*time.Second ||
+		lrConfig.GetSlowStartConfig().GetAggression().GetDefaultValue() != 2.0 {
+		t.Errorf("unexpected slow start config %v", lrConfig.GetSlowStartConfig())
+	}
+
+	// The slow start of the warmup duration of the traffic policy is kept.
+	c = &cluster.Cluster{
+		LbPolicy: cluster.Cluster_LEAST_REQUEST,
+		LbConfig: &cluster.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{
+			SlowStartConfig: &cluster.Cluster_SlowStartConfig{SlowStartWindow: durationpb.New(time.Minute)},
+		}},
+	}
+	ApplyLoadBalancerPolicy(&lbpolicy.Spec{Policy: lbpolicy.LeastRequest, ChoiceCount: 4}, c)
+	if c.GetLeastRequestLbConfig().GetSlowStartConfig().GetSlowStartWindow().AsDuration() != time.Minute {
+		t.Errorf("expected the warmup duration to be kept, got %v", c.GetLeastRequestLbConfig())
+	}
+
+	c = &cluster.Cluster{
+		LbPolicy: cluster.Cluster_LEAST_REQUEST,
+		LbConfig: &cluster.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: &cluster.Cluster_LeastRequestLbConfig{}},
+	}
+	ApplyLoadBalancerPolicy(&lbpolicy.Spec{
+		Policy:             lbpolicy.ClientSideWeightedRoundRobin,
+		BlackoutPeriod:     "5s",
+		WeightUpdatePeriod: "500ms",
+	}, c)
+	if c.LbConfig != nil {
+		t.Errorf("expected the lb config to be cleared, got %v", c.LbConfig)
+	}
+	policies := c.GetLoadBalancingPolicy().GetPolicies()
+	if len(policies) != 1 || policies[0].TypedExtensionConfig.Name != clientSideWeightedRoundRobinPolicy {
+		t.Fatalf("unexpected load balancing policies %v", policies)
+	}
+	wrrConfig := &cswrr.ClientSideWeightedRoundRobin{}
+	if err := policies[0].TypedExtensionConfig.TypedConfig.UnmarshalTo(wrrConfig); err != nil {
+		t.Fatal(err)
+	}
+	if wrrConfig.BlackoutPeriod.AsDuration() != 5*time.Second ||
+		wrrConfig.WeightUpdatePeriod.AsDuration() != 500*time.Millisecond || wrrConfig.WeightExpirationPeriod != nil {
+		t.Errorf("unexpected client side weighted round robin config %v", wrrConfig)
+	}
+
+	c = &cluster.Cluster{LbPolicy: cluster.Cluster_RING_HASH}
+	ApplyLoadBalancerPolicy(&lbpolicy.Spec{Policy: lbpolicy.LeastRequest}, c)
+	if c.LbPolicy != cluster.Cluster_RING_HASH {
+		t.Errorf("expected a consistent hash cluster to be kept, got %v", c.LbPolicy)
+	}
+}
@@ -1,16 +1,24 @@
 package mseingress
 
 import (
+	"sort"
+
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
 	lrlhttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	types "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	any "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/localratelimit"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/log"
 )
 
@@ -91,3 +99,96 @@ func AddLocalRateLimitFilter(perFilterConfig map[string]*any.Any, filter *networ
 
 	perFilterConfig[LocalRateLimitFilterName] = protoconv.MessageToAny(localRateLimit)
 }
+
+// ApplyLocalRateLimitAnnotation sets the local rate limit of the higress.io/local-rate-limit annotation of the virtual
+// service on its routes without one from their HTTP filters. The descriptors are matched by a rate limit action of
+// the routes on their request header.
+func ApplyLocalRateLimitAnnotation(virtualService config.Config, routes []*route.Route) {
+	value, ok := virtualService.Annotations[constants.LocalRateLimitAnnotation]
+	if !ok {
+		return
+	}
+	spec, err := localratelimit.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring local rate limit of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	localRateLimit := buildLocalRateLimit(spec)
+	rateLimits := buildDescriptorRateLimits(spec)
+	for _, r := range routes {
+		if _, exists := r.TypedPerFilterConfig[LocalRateLimitFilterName]; exists {
+			continue
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*any.Any{}
+		}
+		r.TypedPerFilterConfig[LocalRateLimitFilterName] = protoconv.MessageToAny(localRateLimit)
+		if action := r.GetRoute(); action != nil {
+			action.RateLimits = append(action.RateLimits, rateLimits...)
+		}
+	}
+}
+
+func buildTokenBucket(bucket *localratelimit.TokenBucket) *types.TokenBucket {
+	return &types.TokenBucket{
+		MaxTokens:     bucket.MaxTokens,
+		TokensPerFill: &wrappers.UInt32Value{Value: bucket.TokensPerFill},
+		FillInterval:  durationpb.New(bucket.Interval()),
+	}
+}
+
+func buildLocalRateLimit(spec *localratelimit.Spec) *lrlhttppb.LocalRateLimit {
+	localRateLimit := &lrlhttppb.LocalRateLimit{
+		StatPrefix:           DefaultLocalRateLimitStatPrefix,
+		FilterEnabled:        filterEnable,
+		FilterEnforced:       filterEnforce,
+		ResponseHeadersToAdd: responseHeadersToAdd,
+		TokenBucket:          buildTokenBucket(&spec.TokenBucket),
+	}
+	if spec.StatusCode != 0 {
+		localRateLimit.Status = &types.HttpStatus{Code: types.StatusCode(spec.StatusCode)}
+	}
+	if len(spec.ResponseHeaders) > 0 {
+		localRateLimit.ResponseHeadersToAdd = make([]*core.HeaderValueOption, 0, len(spec.ResponseHeaders))
+		for name, value := range spec.ResponseHeaders {
+			localRateLimit.ResponseHeadersToAdd = append(localRateLimit.ResponseHeadersToAdd, &core.HeaderValueOption{
+				Append: &wrappers.BoolValue{Value: false},
+				Header: &core.HeaderValue{Key: name, Value: value},
+			})
+		}
+		sort.Slice(localRateLimit.ResponseHeadersToAdd, func(i, j int) bool {
+			return localRateLimit.ResponseHeadersToAdd[i].Header.Key < localRateLimit.ResponseHeadersToAdd[j].Header.Key
+		})
+	}
+	for _, d := range spec.Descriptors {
+		localRateLimit.Descriptors = append(localRateLimit.Descriptors, &ratelimitv3.LocalRateLimitDescriptor{
+			Entries:     []*ratelimitv3.RateLimitDescriptor_Entry{{Key: d.Header, Value: d.Value}},
+			TokenBucket: buildTokenBucket(&d.TokenBucket),
+		})
+	}
+	return localRateLimit
+}
+
+// buildDescriptorRateLimits returns a rate limit generating a descriptor from each header of the descriptors.
+func buildDescriptorRateLimits(spec *localratelimit.Spec) []*route.RateLimit {
+	var rateLimits []*route.RateLimit
+	seen := map[string]bool{}
+	for _, d := range spec.Descriptors {
+		if seen[d.Header] {
+			continue
+		}
+		seen[d.Header] = true
+		rateLimits = append(rateLimits, &route.RateLimit{
+			Actions: []*route.RateLimit_Action{{
+				ActionSpecifier: &route.RateLimit_Action_RequestHeaders_{
+					RequestHeaders: &route.RateLimit_Action_RequestHeaders{
+						HeaderName:    d.Header,
+						DescriptorKey: d.Header,
+						SkipIfAbsent:  true,
+					},
+				},
+			}},
+		})
+	}
+	return rateLimits
+}
@@ -2,15 +2,20 @@ package mseingress
 
 import (
 	"testing"
+	"time"
 
 	v3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	local_ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/local_ratelimit/v3"
 	rbachttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 )
 
 func TestGetLocalRateLimitFilter(t *testing.T) {
@@ -55,3 +60,50 @@ func TestGetLocalRateLimitFilter(t *testing.T) {
 		t.Fatal("should not have")
 	}
 }
+
+func TestApplyLocalRateLimitAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.LocalRateLimitAnnotation: `{"maxTokens": 10, "fillInterval": "1s", "statusCode": 503,
+					"descriptors": [{"header": "x-user", "value": "vip", "maxTokens": 100, "fillInterval": "1s"}]}`,
+			},
+		},
+	}
+	existing := protoconv.MessageToAny(&local_ratelimitv3.LocalRateLimit{StatPrefix: "filter"})
+	routes := []*route.Route{
+		{Name: "annotated", Action: &route.Route_Route{Route: &route.RouteAction{}}},
+		{Name: "filter", Action: &route.Route_Route{Route: &route.RouteAction{}}, TypedPerFilterConfig: map[string]*anypb.Any{
+			LocalRateLimitFilterName: existing,
+		}},
+	}
+	ApplyLocalRateLimitAnnotation(virtualService, routes)
+
+	got := &local_ratelimitv3.LocalRateLimit{}
+	if err := routes[0].TypedPerFilterConfig[LocalRateLimitFilterName].UnmarshalTo(got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TokenBucket.MaxTokens != 10 || got.TokenBucket.TokensPerFill.GetValue() != 10 ||
+		got.TokenBucket.FillInterval.AsDuration() != time.Second {
+		t.Errorf("got token bucket %v", got.TokenBucket)
+	}
+	if got.Status.GetCode() != 503 {
+		t.Errorf("got status %v, want 503", got.Status)
+	}
+	if len(got.Descriptors) != 1 || got.Descriptors[0].Entries[0].Key != "x-user" || got.Descriptors[0].TokenBucket.MaxTokens != 100 {
+		t.Errorf("got descriptors %v", got.Descriptors)
+	}
+	rateLimits := routes[0].GetRoute().RateLimits
+	if len(rateLimits) != 1 || rateLimits[0].Actions[0].GetRequestHeaders().GetHeaderName() != "x-user" {
+		t.Errorf("got rate limits %v", rateLimits)
+	}
+
+	if !proto.Equal(routes[1].TypedPerFilterConfig[LocalRateLimitFilterName], existing) {
+		t.Error("local rate limit of the route HTTP filters should take precedence")
+	}
+	if len(routes[1].GetRoute().RateLimits) != 0 {
+		t.Errorf("got rate limits %v on route with local rate limit filter", routes[1].GetRoute().RateLimits)
+	}
+}
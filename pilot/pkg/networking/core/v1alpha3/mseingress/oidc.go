@@ -0,0 +1,148 @@
+package mseingress
+
+import (
+	"regexp"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	oauth2 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	securitymodel "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/oidc"
+	"istio.io/istio/pkg/config/security"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	OAuth2FilterName = "envoy.filters.http.oauth2"
+
+	// oauth2RedirectHost is the scheme and the host of the redirect URI of the OAuth2 filter, the ones of the request.
+	oauth2RedirectHost = "%REQ(x-forwarded-proto)%://%REQ(:authority)%"
+)
+
+// BuildOAuth2Filter returns the filter requiring the browsers to log in with the OIDC identity provider of a gateway
+// to the hosts, such as the hosts of its servers. The requests to the other hosts pass through. The client secret
+// and the HMAC secret are served by SDS from the secret of the namespace of the gateway, and the token endpoint is
+// called through the cluster of its service, which must be in the registry, such as a ServiceEntry.
+func BuildOAuth2Filter(push *model.PushContext, gatewayName string, spec *oidc.Spec, hosts []string) *http_conn.HttpFilter {
+	namespace, _, _ := strings.Cut(gatewayName, "/")
+	secretConfig := func(key string) *tls.SdsSecretConfig {
+		return &tls.SdsSecretConfig{
+			Name:      credentials.ToGenericSecretResourceName(namespace, spec.CredentialName, key),
+			SdsConfig: securitymodel.SDSAdsConfig,
+		}
+	}
+	config := &oauth2.OAuth2Config{
+		TokenEndpoint: &core.HttpUri{
+			Uri:              spec.TokenEndpoint,
+			HttpUpstreamType: &core.HttpUri_Cluster{Cluster: tokenEndpointCluster(push, gatewayName, spec.TokenEndpoint)},
+			Timeout:          durationpb.New(spec.Timeout()),
+		},
+		AuthorizationEndpoint: spec.AuthorizationEndpoint,
+		Credentials: &oauth2.OAuth2Credentials{
+			ClientId:       spec.ClientID,
+			TokenSecret:    secretConfig(spec.ClientSecretKey),
+			TokenFormation: &oauth2.OAuth2Credentials_HmacSecret{HmacSecret: secretConfig(spec.HMACSecretKey)},
+		},
+		RedirectUri:         oauth2RedirectHost + spec.RedirectPath,
+		RedirectPathMatcher: exactPathMatcher(spec.RedirectPath),
+		ForwardBearerToken:  spec.ForwardBearerToken,
+		AuthScopes:          spec.Scopes,
+		Resources:           spec.Resources,
+	}
+	if spec.LogoutPath != "" {
+		config.SignoutPath = exactPathMatcher(spec.LogoutPath)
+	}
+	if spec.AuthType == oidc.AuthTypeBasic {
+		config.AuthType = oauth2.OAuth2Config_BASIC_AUTH
+	}
+	if names := spec.CookieNames; names != nil {
+		config.Credentials.CookieNames = &oauth2.OAuth2Credentials_CookieNames{
+			BearerToken:  names.BearerToken,
+			OauthHmac:    names.HMAC,
+			OauthExpires: names.Expires,
+			IdToken:      names.IDToken,
+			RefreshToken: names.RefreshToken,
+		}
+	}
+	if regex := hostsRegex(hosts); regex != "" {
+		config.PassThroughMatcher = append(config.PassThroughMatcher, &route.HeaderMatcher{
+			Name: ":authority",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &matcher.StringMatcher{
+					MatchPattern: &matcher.StringMatcher_SafeRegex{SafeRegex: &matcher.RegexMatcher{Regex: regex}},
+				},
+			},
+			InvertMatch: true,
+		})
+	}
+	for _, path := range spec.PassThroughPaths {
+		config.PassThroughMatcher = append(config.PassThroughMatcher, &route.HeaderMatcher{
+			Name: ":path",
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Prefix{Prefix: path}},
+			},
+		})
+	}
+	return &http_conn.HttpFilter{
+		Name:       OAuth2FilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(&oauth2.OAuth2{Config: config})},
+	}
+}
+
+// tokenEndpointCluster returns the cluster of the service of the token endpoint, or the cluster it would have if
+// there is none, so that the filter is valid but fails the logins until the service is registered.
+func tokenEndpointCluster(push *model.PushContext, gatewayName, endpoint string) string {
+	info, err := security.ParseJwksURI(endpoint)
+	if err != nil {
+		log.Warnf("invalid token endpoint of the oidc of gateway %s: %v", gatewayName, err)
+		return ""
+	}
+	_, cluster, err := model.LookupCluster(push, info.Hostname.String(), info.Port)
+	if err != nil || cluster == "" {
+		model.IncLookupClusterFailures("oidc")
+		log.Warnf("failed to look up the cluster of the token endpoint of the oidc of gateway %s, "+
+			"create a ServiceEntry for %s: %v", gatewayName, info.Hostname, err)
+		return model.BuildSubsetKey(model.TrafficDirectionOutbound, "", info.Hostname, info.Port)
+	}
+	return cluster
+}
+
+func exactPathMatcher(path string) *matcher.PathMatcher {
+	return &matcher.PathMatcher{
+		Rule: &matcher.PathMatcher_Path{
+			Path: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: path}},
+		},
+	}
+}
+
+// hostsRegex returns the regex matching the authorities of the hosts, with any port, or an empty string if a host
+// matches all of them.
+func hostsRegex(hosts []string) string {
+	patterns := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if _, name, ok := strings.Cut(host, "/"); ok {
+			host = name
+		}
+		if host == "*" {
+			return ""
+		}
+		if suffix, ok := strings.CutPrefix(host, "*"); ok {
+			patterns = append(patterns, ".+"+regexp.QuoteMeta(suffix))
+		} else {
+			patterns = append(patterns, regexp.QuoteMeta(host))
+		}
+	}
+	if len(patterns) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(patterns, "|") + ")(:[0-9]+)?"
+}
@@ -0,0 +1,87 @@
+package mseingress
+
+import (
+	"regexp"
+	"testing"
+
+	oauth2 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/oauth2/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/ali/config/oidc"
+)
+
+func TestBuildOAuth2Filter(t *testing.T) {
+	spec, err := oidc.Parse(`{"authorizationEndpoint": "https://idp.example.com/authorize",
+		"tokenEndpoint": "https://idp.example.com/token", "clientID": "gateway", "credentialName": "oidc",
+		"authType": "basic", "logoutPath": "/logout", "passThroughPaths": ["/healthz"], "cookieNames": {"bearerToken": "token"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := BuildOAuth2Filter(model.NewPushContext(), "higress-system/gateway", spec, []string{"*/app.example.com"})
+	if filter.Name != OAuth2FilterName {
+		t.Errorf("got filter %s, want %s", filter.Name, OAuth2FilterName)
+	}
+	out := &oauth2.OAuth2{}
+	if err := filter.GetTypedConfig().UnmarshalTo(out); err != nil {
+		t.Fatal(err)
+	}
+	config := out.Config
+	if got, want := config.GetTokenEndpoint().GetCluster(), "outbound|443||idp.example.com"; got != want {
+		t.Errorf("got token endpoint cluster %s, want %s", got, want)
+	}
+	if got, want := config.GetCredentials().GetTokenSecret().GetName(), "kubernetes://higress-system/oidc#client-secret"; got != want {
+		t.Errorf("got token secret %s, want %s", got, want)
+	}
+	if got, want := config.GetCredentials().GetHmacSecret().GetName(), "kubernetes://higress-system/oidc#hmac-secret"; got != want {
+		t.Errorf("got hmac secret %s, want %s", got, want)
+	}
+	if got, want := config.RedirectUri, "%REQ(x-forwarded-proto)%://%REQ(:authority)%/oauth2/callback"; got != want {
+		t.Errorf("got redirect uri %s, want %s", got, want)
+	}
+	if got := config.GetSignoutPath().GetPath().GetExact(); got != "/logout" {
+		t.Errorf("got signout path %s, want /logout", got)
+	}
+	if config.AuthType != oauth2.OAuth2Config_BASIC_AUTH {
+		t.Errorf("got auth type %v, want basic", config.AuthType)
+	}
+	if got := config.GetCredentials().GetCookieNames().GetBearerToken(); got != "token" {
+		t.Errorf("got bearer token cookie %s, want token", got)
+	}
+	if len(config.PassThroughMatcher) != 2 {
+		t.Fatalf("got pass through matchers %v, want the other hosts and the paths", config.PassThroughMatcher)
+	}
+	if host := config.PassThroughMatcher[0]; host.Name != ":authority" || !host.InvertMatch ||
+		host.GetStringMatch().GetSafeRegex().GetRegex() != hostsRegex([]string{"app.example.com"}) {
+		t.Errorf("got host matcher %v, want the other hosts", host)
+	}
+	if path := config.PassThroughMatcher[1]; path.Name != ":path" || path.GetStringMatch().GetPrefix() != "/healthz" {
+		t.Errorf("got path matcher %v, want /healthz", path)
+	}
+
+	filter = BuildOAuth2Filter(model.NewPushContext(), "higress-system/gateway", spec, []string{"*"})
+	if err := filter.GetTypedConfig().UnmarshalTo(out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Config.PassThroughMatcher) != 1 {
+		t.Errorf("got pass through matchers %v, want the paths only", out.Config.PassThroughMatcher)
+	}
+}
+
+func TestHostsRegex(t *testing.T) {
+	regex := regexp.MustCompile("^" + hostsRegex([]string{"app.example.com", "ns/*.internal.example.com"}) + "$")
+	for authority, want := range map[string]bool{
+		"app.example.com":          true,
+		"app.example.com:8443":     true,
+		"a.b.internal.example.com": true,
+		"internal.example.com":     false,
+		"appxexample.com":          false,
+		"other.example.com":        false,
+	} {
+		if got := regex.MatchString(authority); got != want {
+			t.Errorf("got match %v for %s, want %v", got, authority, want)
+		}
+	}
+	if got := hostsRegex([]string{"app.example.com", "*"}); got != "" {
+		t.Errorf("got regex %s for all the hosts, want none", got)
+	}
+}
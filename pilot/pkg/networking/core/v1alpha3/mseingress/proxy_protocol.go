@@ -0,0 +1,107 @@
+package mseingress
+
+import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	proxyprotocolfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
+	proxyprotocolsocket "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	xdsfilters "istio.io/istio/pilot/pkg/xds/filters"
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// ProxyProtocolMetadataNamespace is the namespace of the dynamic metadata set from the PROXY protocol TLVs.
+	ProxyProtocolMetadataNamespace = wellknown.ProxyProtocol
+
+	upstreamProxyProtocolTransportSocket = "envoy.transport_sockets.upstream_proxy_protocol"
+)
+
+// BuildProxyProtocolListenerFilter generates the PROXY protocol listener filter of the listeners of a gateway, or nil
+// if it is disabled. The filter replaces the downstream address of the connections with the source address of their
+// PROXY protocol header, and sets the dynamic metadata of the spec from their TLVs.
+func BuildProxyProtocolListenerFilter(spec *proxyprotocol.Spec) *listener.ListenerFilter {
+	if spec == nil || spec.Mode == proxyprotocol.ModeDisabled {
+		return nil
+	}
+	config := &proxyprotocolfilter.ProxyProtocol{
+		AllowRequestsWithoutProxyProtocol: spec.Mode == proxyprotocol.ModeOptional,
+	}
+	for _, key := range sets.SortedList(sets.New(maps.Keys(spec.Metadata)...)) {
+		config.Rules = append(config.Rules, &proxyprotocolfilter.ProxyProtocol_Rule{
+			TlvType: spec.Metadata[key],
+			OnTlvPresent: &proxyprotocolfilter.ProxyProtocol_KeyValuePair{
+				MetadataNamespace: ProxyProtocolMetadataNamespace,
+				Key:               key,
+			},
+		})
+	}
+	if spec.PassThroughTLVs {
+		config.PassThroughTlvs = &core.ProxyProtocolPassThroughTLVs{
+			MatchType: core.ProxyProtocolPassThroughTLVs_INCLUDE_ALL,
+		}
+	}
+	return &listener.ListenerFilter{
+		Name:       wellknown.ProxyProtocol,
+		ConfigType: &listener.ListenerFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(config)},
+	}
+}
+
+// UpstreamProxyProtocol returns the version of the PROXY protocol header sent to the hosts of the clusters of a
+// DestinationRule set by its higress.io/upstream-proxy-protocol annotation, or an empty string.
+func UpstreamProxyProtocol(destinationRule *config.Config) string {
+	if destinationRule == nil {
+		return ""
+	}
+	version, ok := destinationRule.Annotations[constants.UpstreamProxyProtocolAnnotation]
+	if !ok {
+		return ""
+	}
+	if err := proxyprotocol.ValidateVersion(version); err != nil {
+		log.Warnf("ignoring upstream proxy protocol of destination rule %s/%s: %v", destinationRule.Namespace,
+			destinationRule.Name, err)
+		return ""
+	}
+	return version
+}
+
+// ApplyUpstreamProxyProtocol wraps the transport sockets of a cluster to send a PROXY protocol header of the version to
+// its hosts, with the address of the downstream clients and the TLVs passed through by the listeners.
+func ApplyUpstreamProxyProtocol(version string, c *cluster.Cluster) {
+	if version == "" {
+		return
+	}
+	config := &core.ProxyProtocolConfig{Version: core.ProxyProtocolConfig_V1}
+	if version == proxyprotocol.V2 {
+		config.Version = core.ProxyProtocolConfig_V2
+		config.PassThroughTlvs = &core.ProxyProtocolPassThroughTLVs{
+			MatchType: core.ProxyProtocolPassThroughTLVs_INCLUDE_ALL,
+		}
+	}
+	c.TransportSocket = wrapUpstreamProxyProtocol(config, c.TransportSocket)
+	for _, match := range c.TransportSocketMatches {
+		match.TransportSocket = wrapUpstreamProxyProtocol(config, match.TransportSocket)
+	}
+}
+
+func wrapUpstreamProxyProtocol(config *core.ProxyProtocolConfig, socket *core.TransportSocket) *core.TransportSocket {
+	if socket == nil {
+		socket = xdsfilters.RawBufferTransportSocket
+	}
+	return &core.TransportSocket{
+		Name: upstreamProxyProtocolTransportSocket,
+		ConfigType: &core.TransportSocket_TypedConfig{TypedConfig: protoconv.MessageToAny(&proxyprotocolsocket.ProxyProtocolUpstreamTransport{
+			Config:          proto.Clone(config).(*core.ProxyProtocolConfig),
+			TransportSocket: socket,
+		})},
+	}
+}
@@ -0,0 +1,68 @@
+package mseingress
+
+import (
+	"testing"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	proxyprotocolfilter "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/listener/proxy_protocol/v3"
+	proxyprotocolsocket "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/proxy_protocol/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
+)
+
+func TestBuildProxyProtocolListenerFilter(t *testing.T) {
+	if f := BuildProxyProtocolListenerFilter(&proxyprotocol.Spec{Mode: proxyprotocol.ModeDisabled}); f != nil {
+		t.Errorf("got filter %v for a disabled proxy protocol", f)
+	}
+
+	f := BuildProxyProtocolListenerFilter(&proxyprotocol.Spec{
+		Mode:            proxyprotocol.ModeOptional,
+		Metadata:        map[string]uint32{"vpce_id": 234, "authority": 2},
+		PassThroughTLVs: true,
+	})
+	config := &proxyprotocolfilter.ProxyProtocol{}
+	if err := f.GetTypedConfig().UnmarshalTo(config); err != nil {
+		t.Fatal(err)
+	}
+	if !config.AllowRequestsWithoutProxyProtocol {
+		t.Errorf("expected the optional proxy protocol to allow requests without it")
+	}
+	if len(config.Rules) != 2 || config.Rules[0].OnTlvPresent.Key != "authority" || config.Rules[0].TlvType != 2 ||
+		config.Rules[1].OnTlvPresent.MetadataNamespace != ProxyProtocolMetadataNamespace {
+		t.Errorf("got rules %v, want the sorted metadata keys", config.Rules)
+	}
+	if config.PassThroughTlvs.GetMatchType() != core.ProxyProtocolPassThroughTLVs_INCLUDE_ALL {
+		t.Errorf("expected all the TLVs to be passed through")
+	}
+}
+
+func TestApplyUpstreamProxyProtocol(t *testing.T) {
+	tlsSocket := &core.TransportSocket{Name: wellknown.TransportSocketTls}
+	c := &cluster.Cluster{
+		TransportSocketMatches: []*cluster.Cluster_TransportSocketMatch{{Name: "tlsMode-istio", TransportSocket: tlsSocket}},
+	}
+	ApplyUpstreamProxyProtocol(proxyprotocol.V2, c)
+
+	socket := &proxyprotocolsocket.ProxyProtocolUpstreamTransport{}
+	if err := c.TransportSocket.GetTypedConfig().UnmarshalTo(socket); err != nil {
+		t.Fatal(err)
+	}
+	if socket.Config.Version != core.ProxyProtocolConfig_V2 || socket.TransportSocket.Name != wellknown.TransportSocketRawBuffer {
+		t.Errorf("got %v, want a v2 header over a raw buffer", socket)
+	}
+	match := &proxyprotocolsocket.ProxyProtocolUpstreamTransport{}
+	if err := c.TransportSocketMatches[0].TransportSocket.GetTypedConfig().UnmarshalTo(match); err != nil {
+		t.Fatal(err)
+	}
+	if match.TransportSocket.GetName() != wellknown.TransportSocketTls {
+		t.Errorf("got %v, want the TLS socket of the match to be wrapped", match.TransportSocket)
+	}
+
+	unchanged := &cluster.Cluster{}
+	ApplyUpstreamProxyProtocol("", unchanged)
+	if unchanged.TransportSocket != nil {
+		t.Errorf("got transport socket %v without proxy protocol", unchanged.TransportSocket)
+	}
+}
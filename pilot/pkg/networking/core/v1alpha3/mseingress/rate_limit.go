@@ -0,0 +1,106 @@
+package mseingress
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitconfig "github.com/envoyproxy/go-control-plane/envoy/config/ratelimit/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	ratelimithttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ratelimit/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/ratelimit"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	RateLimitFilterName = wellknown.HTTPRateLimit
+
+	// RateLimitServiceClusterName is the cluster of the global rate limit service on the gateways.
+	RateLimitServiceClusterName = "outbound|higress-rate-limit-service"
+
+	// RateLimitStage is the stage of the rate limits generated for the global rate limit service, so that the
+	// descriptors of the local rate limits, always of the stage 0, are not sent to it.
+	RateLimitStage = 1
+)
+
+// BuildRateLimitFilter returns the filter calling the global rate limit service, or nil if there is none.
+func BuildRateLimitFilter() *http_conn.HttpFilter {
+	if alifeatures.RateLimitServiceAddress == "" {
+		return nil
+	}
+	rateLimit := &ratelimithttppb.RateLimit{
+		Domain:          alifeatures.RateLimitServiceDomain,
+		Stage:           RateLimitStage,
+		FailureModeDeny: alifeatures.RateLimitFailureModeDeny,
+		RateLimitService: &ratelimitconfig.RateLimitServiceConfig{
+			GrpcService: &core.GrpcService{
+				TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+					EnvoyGrpc: &core.GrpcService_EnvoyGrpc{ClusterName: RateLimitServiceClusterName},
+				},
+			},
+			TransportApiVersion: core.ApiVersion_V3,
+		},
+	}
+	if alifeatures.RateLimitServiceTimeout > 0 {
+		rateLimit.Timeout = durationpb.New(alifeatures.RateLimitServiceTimeout)
+	}
+	return &http_conn.HttpFilter{
+		Name:       RateLimitFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(rateLimit)},
+	}
+}
+
+// ApplyRateLimitAnnotation adds the rate limits generating the descriptors of the higress.io/rate-limit annotation
+// of the virtual service to its routes.
+func ApplyRateLimitAnnotation(virtualService config.Config, routes []*route.Route) {
+	if alifeatures.RateLimitServiceAddress == "" {
+		return
+	}
+	value, ok := virtualService.Annotations[constants.RateLimitAnnotation]
+	if !ok {
+		return
+	}
+	descriptors, err := ratelimit.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring rate limit of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	rateLimits := make([]*route.RateLimit, 0, len(descriptors))
+	for _, d := range descriptors {
+		rateLimits = append(rateLimits, buildRateLimit(d))
+	}
+	for _, r := range routes {
+		if action := r.GetRoute(); action != nil {
+			action.RateLimits = append(action.RateLimits, rateLimits...)
+		}
+	}
+}
+
+func buildRateLimit(descriptor ratelimit.Descriptor) *route.RateLimit {
+	rateLimit := &route.RateLimit{Stage: &wrappers.UInt32Value{Value: RateLimitStage}}
+	for _, e := range descriptor {
+		action := &route.RateLimit_Action{}
+		switch {
+		case e.Header != "":
+			action.ActionSpecifier = &route.RateLimit_Action_RequestHeaders_{
+				RequestHeaders: &route.RateLimit_Action_RequestHeaders{HeaderName: e.Header, DescriptorKey: e.Key},
+			}
+		case e.RemoteAddress:
+			action.ActionSpecifier = &route.RateLimit_Action_RemoteAddress_{
+				RemoteAddress: &route.RateLimit_Action_RemoteAddress{},
+			}
+		default:
+			action.ActionSpecifier = &route.RateLimit_Action_GenericKey_{
+				GenericKey: &route.RateLimit_Action_GenericKey{DescriptorKey: e.Key, DescriptorValue: e.Value},
+			}
+		}
+		rateLimit.Actions = append(rateLimit.Actions, action)
+	}
+	return rateLimit
+}
@@ -0,0 +1,58 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+)
+
+func TestApplyRateLimitAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.RateLimitAnnotation: `[[{"value": "api"}, {"key": "user", "header": "x-user"}], [{"remoteAddress": true}]]`,
+			},
+		},
+	}
+	newRoutes := func() []*route.Route {
+		return []*route.Route{{Name: "route", Action: &route.Route_Route{Route: &route.RouteAction{}}}}
+	}
+
+	routes := newRoutes()
+	ApplyRateLimitAnnotation(virtualService, routes)
+	if got := routes[0].GetRoute().RateLimits; len(got) != 0 {
+		t.Errorf("got rate limits %v without a rate limit service", got)
+	}
+
+	test.SetForTest(t, &alifeatures.RateLimitServiceAddress, "rls.example.com:8081")
+	routes = newRoutes()
+	ApplyRateLimitAnnotation(virtualService, routes)
+	rateLimits := routes[0].GetRoute().RateLimits
+	if len(rateLimits) != 2 {
+		t.Fatalf("got rate limits %v, want 2", rateLimits)
+	}
+	for _, r := range rateLimits {
+		if r.Stage.GetValue() != RateLimitStage {
+			t.Errorf("got stage %v, want %d", r.Stage, RateLimitStage)
+		}
+	}
+	actions := rateLimits[0].Actions
+	if len(actions) != 2 || actions[0].GetGenericKey().GetDescriptorValue() != "api" ||
+		actions[1].GetRequestHeaders().GetHeaderName() != "x-user" || actions[1].GetRequestHeaders().GetDescriptorKey() != "user" {
+		t.Errorf("got actions %v", actions)
+	}
+	if rateLimits[1].Actions[0].GetRemoteAddress() == nil {
+		t.Errorf("got actions %v, want remote address", rateLimits[1].Actions)
+	}
+
+	if BuildRateLimitFilter() == nil {
+		t.Error("missing rate limit filter with a rate limit service")
+	}
+}
@@ -0,0 +1,112 @@
+package mseingress
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/requestbuffering"
+	"istio.io/istio/pkg/ali/config/streaming"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+const BufferFilterName = "envoy.filters.http.buffer"
+
+// disabledBuffer is the per-route config of the buffer filter streaming the requests of a route.
+var disabledBuffer = protoconv.MessageToAny(&buffer.BufferPerRoute{
+	Override: &buffer.BufferPerRoute_Disabled{Disabled: true},
+})
+
+// requestBufferMaxBytes returns the max request size of PILOT_REQUEST_BUFFER_MAX_SIZE, or 0 if unset or invalid.
+func requestBufferMaxBytes() uint32 {
+	if alifeatures.RequestBufferMaxSize == "" {
+		return 0
+	}
+	size, err := requestbuffering.ParseSize(alifeatures.RequestBufferMaxSize)
+	if err != nil {
+		log.Warnf("ignoring PILOT_REQUEST_BUFFER_MAX_SIZE: %v", err)
+		return 0
+	}
+	return size
+}
+
+// BuildBufferFilter returns the filter buffering the requests up to PILOT_REQUEST_BUFFER_MAX_SIZE, rejecting the
+// larger ones with 413, or nil if it is not set. The virtual services override it on their routes with the
+// higress.io/request-buffering annotation.
+func BuildBufferFilter() *http_conn.HttpFilter {
+	size := requestBufferMaxBytes()
+	if size == 0 {
+		return nil
+	}
+	return &http_conn.HttpFilter{
+		Name: BufferFilterName,
+		ConfigType: &http_conn.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(&buffer.Buffer{
+			MaxRequestBytes: wrapperspb.UInt32(size),
+		})},
+	}
+}
+
+// ApplyRequestBufferingAnnotation overrides the buffering of the requests of the routes of the virtual service with
+// the policies of its higress.io/request-buffering annotation. The streaming routes, whose requests do not end
+// before their responses, are never buffered.
+func ApplyRequestBufferingAnnotation(virtualService config.Config, routes []*route.Route) {
+	if requestBufferMaxBytes() == 0 {
+		return
+	}
+	var spec *requestbuffering.Spec
+	if value, ok := virtualService.Annotations[constants.RequestBufferingAnnotation]; ok {
+		var err error
+		if spec, err = requestbuffering.Parse(value); err != nil {
+			log.Warnf("ignoring request buffering of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+			spec = nil
+		}
+	}
+	streams := streamingSpec(virtualService)
+	configs := map[*requestbuffering.Policy]*anypb.Any{}
+	for _, r := range routes {
+		filterConfig := requestBufferingConfig(spec, streams, r, configs)
+		if filterConfig == nil {
+			continue
+		}
+		if r.TypedPerFilterConfig == nil {
+			r.TypedPerFilterConfig = map[string]*anypb.Any{}
+		}
+		r.TypedPerFilterConfig[BufferFilterName] = filterConfig
+	}
+}
+
+// requestBufferingConfig returns the per-route config of the buffer filter overriding the buffering of the route,
+// or nil if the route is buffered as set by the filter. The configs are shared by the routes of the same policy.
+func requestBufferingConfig(spec *requestbuffering.Spec, streams *streaming.Spec, r *route.Route,
+	configs map[*requestbuffering.Policy]*anypb.Any,
+) *anypb.Any {
+	if _, ok := routeStreamingPolicy(streams, r); ok {
+		return disabledBuffer
+	}
+	if spec == nil {
+		return nil
+	}
+	policy := spec.RoutePolicy(r.Name)
+	if policy.Disabled {
+		return disabledBuffer
+	}
+	if policy.MaxRequestBytes() == 0 {
+		return nil
+	}
+	filterConfig, ok := configs[policy]
+	if !ok {
+		filterConfig = protoconv.MessageToAny(&buffer.BufferPerRoute{
+			Override: &buffer.BufferPerRoute_Buffer{Buffer: &buffer.Buffer{
+				MaxRequestBytes: wrapperspb.UInt32(policy.MaxRequestBytes()),
+			}},
+		})
+		configs[policy] = filterConfig
+	}
+	return filterConfig
+}
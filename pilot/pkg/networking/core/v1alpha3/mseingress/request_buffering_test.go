@@ -0,0 +1,91 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/buffer/v3"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+)
+
+func TestBuildBufferFilter(t *testing.T) {
+	if filter := BuildBufferFilter(); filter != nil {
+		t.Fatalf("got filter %v without max request size", filter)
+	}
+	test.SetForTest(t, &alifeatures.RequestBufferMaxSize, "1MB")
+	if filter := BuildBufferFilter(); filter != nil {
+		t.Fatalf("got filter %v with an invalid max request size", filter)
+	}
+
+	test.SetForTest(t, &alifeatures.RequestBufferMaxSize, "1Mi")
+	filter := BuildBufferFilter()
+	if filter == nil {
+		t.Fatal("missing buffer filter")
+	}
+	b := &buffer.Buffer{}
+	if err := filter.GetTypedConfig().UnmarshalTo(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.GetMaxRequestBytes().GetValue(); got != 1<<20 {
+		t.Errorf("got max request bytes %d, want 1Mi", got)
+	}
+}
+
+func TestApplyRequestBufferingAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.RequestBufferingAnnotation: `{"routes": {"upload": {"maxRequestSize": "100Mi"}, "proxy": {"disabled": true}}}`,
+			},
+		},
+	}
+	newRoutes := func() []*route.Route {
+		return []*route.Route{
+			headerRoute("api", "", ""),
+			headerRoute("upload", "", ""),
+			headerRoute("proxy", "", ""),
+			headerRoute("chat", "upgrade", "websocket"),
+		}
+	}
+
+	routes := newRoutes()
+	ApplyRequestBufferingAnnotation(virtualService, routes)
+	for _, r := range routes {
+		if got := r.TypedPerFilterConfig; got != nil {
+			t.Errorf("got per-filter configs %v on route %s without buffer filter", got, r.Name)
+		}
+	}
+
+	test.SetForTest(t, &alifeatures.RequestBufferMaxSize, "1Mi")
+	test.SetForTest(t, &alifeatures.EnableStreamingDetection, true)
+	routes = newRoutes()
+	ApplyRequestBufferingAnnotation(virtualService, routes)
+	if got := routes[0].TypedPerFilterConfig; got != nil {
+		t.Errorf("got per-filter configs %v on route api, want the buffering of the filter", got)
+	}
+	perRoute := func(r *route.Route) *buffer.BufferPerRoute {
+		filterConfig := r.TypedPerFilterConfig[BufferFilterName]
+		if filterConfig == nil {
+			t.Fatalf("missing buffer config on route %s", r.Name)
+		}
+		out := &buffer.BufferPerRoute{}
+		if err := filterConfig.UnmarshalTo(out); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if got := perRoute(routes[1]).GetBuffer().GetMaxRequestBytes().GetValue(); got != 100<<20 {
+		t.Errorf("got max request bytes %d on route upload, want 100Mi", got)
+	}
+	for _, r := range routes[2:] {
+		if !perRoute(r).GetDisabled() {
+			t.Errorf("got route %s buffered, want disabled", r.Name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package mseingress
+
+import (
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	dfpcluster "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/dynamic_forward_proxy/v3"
+	dfpcommon "github.com/envoyproxy/go-control-plane/envoy/extensions/common/dynamic_forward_proxy/v3"
+	snidfp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/sni_dynamic_forward_proxy/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+const (
+	// SNIForwardProxyClusterName is the cluster of the dynamic forward proxy of the gateways, resolving the SNI of the
+	// forwarded connections.
+	SNIForwardProxyClusterName = "outbound|higress-sni-forward-proxy"
+
+	sniForwardProxyDNSCacheName = "higress_sni_forward_proxy"
+	sniForwardProxyFilterName   = "envoy.filters.network.sni_dynamic_forward_proxy"
+	dynamicForwardProxyCluster  = "envoy.clusters.dynamic_forward_proxy"
+)
+
+// sniForwardProxyDNSCacheConfig returns the DNS cache shared by the filters and the cluster of the dynamic forward
+// proxy, which Envoy requires to be configured identically.
+func sniForwardProxyDNSCacheConfig() *dfpcommon.DnsCacheConfig {
+	return &dfpcommon.DnsCacheConfig{
+		Name:            sniForwardProxyDNSCacheName,
+		DnsLookupFamily: cluster.Cluster_V4_PREFERRED,
+	}
+}
+
+// BuildSNIForwardProxyFilter generates the network filter resolving the SNI of the connections, forwarded by the
+// following TCP proxy to the SNIForwardProxyClusterName cluster on the port.
+func BuildSNIForwardProxyFilter(port uint32) *listener.Filter {
+	return &listener.Filter{
+		Name: sniForwardProxyFilterName,
+		ConfigType: &listener.Filter_TypedConfig{TypedConfig: protoconv.MessageToAny(&snidfp.FilterConfig{
+			DnsCacheConfig: sniForwardProxyDNSCacheConfig(),
+			PortSpecifier:  &snidfp.FilterConfig_PortValue{PortValue: port},
+		})},
+	}
+}
+
+// BuildSNIForwardProxyCluster generates the dynamic forward proxy cluster connecting to the hosts resolved by the
+// SNI forward proxy filters.
+func BuildSNIForwardProxyCluster(connectTimeout *durationpb.Duration) *cluster.Cluster {
+	return &cluster.Cluster{
+		Name:           SNIForwardProxyClusterName,
+		ConnectTimeout: proto.Clone(connectTimeout).(*durationpb.Duration),
+		LbPolicy:       cluster.Cluster_CLUSTER_PROVIDED,
+		ClusterDiscoveryType: &cluster.Cluster_ClusterType{ClusterType: &cluster.Cluster_CustomClusterType{
+			Name: dynamicForwardProxyCluster,
+			TypedConfig: protoconv.MessageToAny(&dfpcluster.ClusterConfig{
+				ClusterImplementationSpecifier: &dfpcluster.ClusterConfig_DnsCacheConfig{
+					DnsCacheConfig: sniForwardProxyDNSCacheConfig(),
+				},
+			}),
+		}},
+	}
+}
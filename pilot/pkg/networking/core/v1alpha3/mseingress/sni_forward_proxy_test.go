@@ -0,0 +1,32 @@
+package mseingress
+
+import (
+	"testing"
+
+	dfpcluster "github.com/envoyproxy/go-control-plane/envoy/extensions/clusters/dynamic_forward_proxy/v3"
+	snidfp "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/sni_dynamic_forward_proxy/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestSNIForwardProxySharesDNSCache(t *testing.T) {
+	filter := &snidfp.FilterConfig{}
+	if err := BuildSNIForwardProxyFilter(443).GetTypedConfig().UnmarshalTo(filter); err != nil {
+		t.Fatal(err)
+	}
+	if filter.GetPortValue() != 443 {
+		t.Errorf("got port %d, want 443", filter.GetPortValue())
+	}
+
+	c := BuildSNIForwardProxyCluster(durationpb.New(1e9))
+	if c.Name != SNIForwardProxyClusterName {
+		t.Errorf("got cluster %s, want %s", c.Name, SNIForwardProxyClusterName)
+	}
+	clusterConfig := &dfpcluster.ClusterConfig{}
+	if err := c.GetClusterType().GetTypedConfig().UnmarshalTo(clusterConfig); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(filter.DnsCacheConfig, clusterConfig.GetDnsCacheConfig()) {
+		t.Errorf("the filter DNS cache %v differs from the cluster one %v", filter.DnsCacheConfig, clusterConfig.GetDnsCacheConfig())
+	}
+}
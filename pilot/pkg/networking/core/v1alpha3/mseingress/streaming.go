@@ -0,0 +1,113 @@
+package mseingress
+
+import (
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"istio.io/istio/pkg/ali/config/streaming"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+// ApplyStreamingTimeouts sets the timeouts of the streaming routes of the virtual service: all its routes if it has
+// a higress.io/streaming annotation, else the routes matching WebSocket upgrades or server-sent events with
+// PILOT_ENABLE_STREAMING_DETECTION. Their streams are not bounded by the timeout of the route, which would reset
+// them, but by an idle timeout and a max stream duration.
+func ApplyStreamingTimeouts(virtualService config.Config, routes []*route.Route) {
+	spec := streamingSpec(virtualService)
+	for _, r := range routes {
+		action := r.GetRoute()
+		if action == nil {
+			continue
+		}
+		policy, ok := routeStreamingPolicy(spec, r)
+		if !ok {
+			continue
+		}
+		action.Timeout = durationpb.New(0)
+		action.IdleTimeout = durationpb.New(streaming.Duration(policy.IdleTimeout, alifeatures.StreamingIdleTimeout))
+		if d := streaming.Duration(policy.MaxStreamDuration, alifeatures.StreamingMaxStreamDuration); d > 0 {
+			action.MaxStreamDuration = &route.RouteAction_MaxStreamDuration{MaxStreamDuration: durationpb.New(d)}
+		}
+	}
+}
+
+// streamingSpec returns the parsed higress.io/streaming annotation of the virtual service, or nil if it has none or
+// it is invalid.
+func streamingSpec(virtualService config.Config) *streaming.Spec {
+	value, ok := virtualService.Annotations[constants.StreamingAnnotation]
+	if !ok {
+		return nil
+	}
+	spec, err := streaming.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring streaming of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return nil
+	}
+	return spec
+}
+
+// routeStreamingPolicy returns the streaming policy of the route, with its type detected from its matches if unset,
+// and false if the route is not streaming.
+func routeStreamingPolicy(spec *streaming.Spec, r *route.Route) (streaming.Policy, bool) {
+	if spec == nil {
+		if !alifeatures.EnableStreamingDetection {
+			return streaming.Policy{}, false
+		}
+		streamingType := detectStreamingType(r.GetMatch())
+		return streaming.Policy{Type: streamingType}, streamingType != ""
+	}
+	policy := *spec.RoutePolicy(r.Name)
+	if policy.Disabled {
+		return streaming.Policy{}, false
+	}
+	if policy.Type == "" {
+		policy.Type = detectStreamingType(r.GetMatch())
+	}
+	return policy, true
+}
+
+// detectStreamingType returns websocket if the match requires an Upgrade header to websocket, sse if it requires an
+// Accept header of text/event-stream, else an empty string.
+func detectStreamingType(match *route.RouteMatch) string {
+	for _, header := range match.GetHeaders() {
+		value := strings.ToLower(headerMatcherValue(header))
+		switch strings.ToLower(header.Name) {
+		case "upgrade":
+			if strings.Contains(value, "websocket") {
+				return streaming.WebSocket
+			}
+		case "accept":
+			if strings.Contains(value, "text/event-stream") {
+				return streaming.SSE
+			}
+		}
+	}
+	return ""
+}
+
+// headerMatcherValue returns the value matched by the header matcher, or its regex, empty if it matches any value.
+func headerMatcherValue(header *route.HeaderMatcher) string {
+	if header.GetInvertMatch() {
+		return ""
+	}
+	sm := header.GetStringMatch()
+	switch sm.GetMatchPattern().(type) {
+	case *matcher.StringMatcher_Exact:
+		return sm.GetExact()
+	case *matcher.StringMatcher_Prefix:
+		return sm.GetPrefix()
+	case *matcher.StringMatcher_Suffix:
+		return sm.GetSuffix()
+	case *matcher.StringMatcher_Contains:
+		return sm.GetContains()
+	case *matcher.StringMatcher_SafeRegex:
+		return sm.GetSafeRegex().GetRegex()
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package mseingress
+
+import (
+	"testing"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test"
+)
+
+func headerRoute(name, header, value string) *route.Route {
+	r := &route.Route{
+		Name:   name,
+		Match:  &route.RouteMatch{},
+		Action: &route.Route_Route{Route: &route.RouteAction{Timeout: durationpb.New(30 * time.Second)}},
+	}
+	if header != "" {
+		r.Match.Headers = []*route.HeaderMatcher{{
+			Name: header,
+			HeaderMatchSpecifier: &route.HeaderMatcher_StringMatch{
+				StringMatch: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: value}},
+			},
+		}}
+	}
+	return r
+}
+
+func TestApplyStreamingTimeouts(t *testing.T) {
+	newRoutes := func() []*route.Route {
+		return []*route.Route{
+			headerRoute("chat", "Upgrade", "WebSocket"),
+			headerRoute("events", "accept", "text/event-stream"),
+			headerRoute("api", "", ""),
+			headerRoute("healthz", "", ""),
+		}
+	}
+	annotated := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.StreamingAnnotation: `{"idleTimeout": "10m", "routes": {"healthz": {"disabled": true}, ` +
+					`"events": {"type": "sse", "maxStreamDuration": "24h"}}}`,
+			},
+		},
+	}
+	plain := config.Config{Meta: config.Meta{Name: "vs", Namespace: "default"}}
+
+	cases := []struct {
+		name      string
+		vs        config.Config
+		detection bool
+		// idle are the idle timeouts of the streaming routes, by name.
+		idle map[string]time.Duration
+		// maxStream are the max stream durations of the streaming routes bounding them, by name.
+		maxStream map[string]time.Duration
+	}{
+		{name: "no annotation", vs: plain},
+		{
+			name:      "detection",
+			vs:        plain,
+			detection: true,
+			idle:      map[string]time.Duration{"chat": time.Hour, "events": time.Hour},
+		},
+		{
+			name:      "annotation",
+			vs:        annotated,
+			idle:      map[string]time.Duration{"chat": 10 * time.Minute, "events": time.Hour, "api": 10 * time.Minute},
+			maxStream: map[string]time.Duration{"events": 24 * time.Hour},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.EnableStreamingDetection, tt.detection)
+			routes := newRoutes()
+			ApplyStreamingTimeouts(tt.vs, routes)
+			for _, r := range routes {
+				action := r.GetRoute()
+				idle, streaming := tt.idle[r.Name]
+				if !streaming {
+					if action.Timeout.AsDuration() != 30*time.Second || action.IdleTimeout != nil {
+						t.Errorf("got timeout %v and idle timeout %v on route %s, want them untouched", action.Timeout,
+							action.IdleTimeout, r.Name)
+					}
+					continue
+				}
+				if action.Timeout.AsDuration() != 0 {
+					t.Errorf("got timeout %v on streaming route %s, want none", action.Timeout.AsDuration(), r.Name)
+				}
+				if got := action.IdleTimeout.AsDuration(); got != idle {
+					t.Errorf("got idle timeout %v on route %s, want %v", got, r.Name, idle)
+				}
+				maxStream, bounded := tt.maxStream[r.Name]
+				if got := action.GetMaxStreamDuration(); bounded != (got != nil) ||
+					(bounded && got.MaxStreamDuration.AsDuration() != maxStream) {
+					t.Errorf("got max stream duration %v on route %s, want %v", got, r.Name, maxStream)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectStreamingType(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		want   string
+	}{
+		{name: "websocket", header: "upgrade", value: "websocket", want: "websocket"},
+		{name: "sse", header: "Accept", value: "text/event-stream", want: "sse"},
+		{name: "json", header: "accept", value: "application/json"},
+		{name: "no header"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectStreamingType(headerRoute("r", tt.header, tt.value).Match); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package mseingress
+
+import (
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	types "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"istio.io/istio/pkg/ali/config/tracingsampling"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/log"
+)
+
+// ApplyTracingSamplingAnnotation sets the random sampling percentage of the higress.io/tracing-sampling annotation of
+// the virtual service on its routes, overriding the one of the tracing of the connection manager. The routes are
+// only sampled if tracing is enabled on the gateways, such as by a Telemetry resource.
+func ApplyTracingSamplingAnnotation(virtualService config.Config, routes []*route.Route) {
+	value, ok := virtualService.Annotations[constants.TracingSamplingAnnotation]
+	if !ok {
+		return
+	}
+	spec, err := tracingsampling.Parse(value)
+	if err != nil {
+		log.Warnf("ignoring tracing sampling of virtual service %s/%s: %v", virtualService.Namespace, virtualService.Name, err)
+		return
+	}
+	for _, r := range routes {
+		percentage, ok := spec.RoutePercentage(r.Name)
+		if !ok {
+			continue
+		}
+		if r.Tracing == nil {
+			r.Tracing = &route.Tracing{}
+		}
+		r.Tracing.RandomSampling = &types.FractionalPercent{
+			Numerator:   uint32(percentage * 10000),
+			Denominator: types.FractionalPercent_MILLION,
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package mseingress
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestApplyTracingSamplingAnnotation(t *testing.T) {
+	virtualService := config.Config{
+		Meta: config.Meta{
+			Name:      "vs",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.TracingSamplingAnnotation: `{"percentage": 1.5, "routes": {"healthz": 0, "checkout": 100}}`,
+			},
+		},
+	}
+	routes := []*route.Route{{Name: "healthz"}, {Name: "checkout.errors"}, {Name: "catalog"}}
+	ApplyTracingSamplingAnnotation(virtualService, routes)
+	for i, want := range []uint32{0, 1000000, 15000} {
+		if got := routes[i].GetTracing().GetRandomSampling().GetNumerator(); routes[i].GetTracing() == nil || got != want {
+			t.Errorf("got sampling %v of route %s, want %d per million", routes[i].GetTracing(), routes[i].Name, want)
+		}
+	}
+
+	virtualService.Annotations[constants.TracingSamplingAnnotation] = `{"percentage": 200}`
+	routes = []*route.Route{{Name: "catalog"}}
+	ApplyTracingSamplingAnnotation(virtualService, routes)
+	if routes[0].Tracing != nil {
+		t.Errorf("got tracing %v with an invalid annotation", routes[0].Tracing)
+	}
+}
@@ -3,7 +3,12 @@ package route
 import (
 	"testing"
 
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
 )
 
 func TestSupportFallback(t *testing.T) {
@@ -137,3 +142,75 @@ func TestSupportFallback(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildConditionalMirrorRoutes(t *testing.T) {
+	in := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: "reviews.default.svc.cluster.local"},
+		}},
+		Mirror: &networking.Destination{Host: "shadow.default.svc.cluster.local"},
+		Mirrors: []*networking.HTTPMirrorPolicy{
+			{
+				Destination: &networking.Destination{Host: "canary.default.svc.cluster.local"},
+				Percentage:  &networking.Percent{Value: 50},
+			},
+			{
+				Destination: &networking.Destination{Host: "disabled.default.svc.cluster.local"},
+				Percentage:  &networking.Percent{Value: 0},
+			},
+		},
+	}
+	vs := config.Config{
+		Meta: config.Meta{
+			Name:      "reviews",
+			Namespace: "default",
+			Annotations: map[string]string{
+				constants.MirrorHeadersAnnotation: `{"canary.default.svc.cluster.local": {"x-canary": {"exact": "true"}}}`,
+			},
+		},
+	}
+
+	targets := mirrorTargets(in, vs)
+	if len(targets) != 2 {
+		t.Fatalf("mirrorTargets() returned %d targets, want 2", len(targets))
+	}
+	if targets[0].destination.Host != "shadow.default.svc.cluster.local" || len(targets[0].headers) != 0 {
+		t.Errorf("unexpected first target %v", targets[0])
+	}
+	if targets[1].destination.Host != "canary.default.svc.cluster.local" || len(targets[1].headers) != 1 {
+		t.Errorf("unexpected second target %v", targets[1])
+	}
+	if got := targets[1].percent.GetDefaultValue().GetNumerator(); got != 500000 {
+		t.Errorf("got canary percent numerator %d, want 500000", got)
+	}
+
+	r := &route.Route{
+		Name:  "reviews",
+		Match: &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+		Action: &route.Route_Route{Route: &route.RouteAction{
+			RequestMirrorPolicies: []*route.RouteAction_RequestMirrorPolicy{
+				buildMirrorPolicy(targets[0], nil, 80),
+			},
+		}},
+	}
+	mirrored := buildConditionalMirrorRoutes(r, in, vs, nil, 80)
+	if len(mirrored) != 1 {
+		t.Fatalf("buildConditionalMirrorRoutes() returned %d routes, want 1", len(mirrored))
+	}
+	headers := mirrored[0].GetMatch().GetHeaders()
+	if len(headers) != 1 || headers[0].Name != "x-canary" || headers[0].GetStringMatch().GetExact() != "true" {
+		t.Errorf("unexpected header matchers %v", headers)
+	}
+	policies := mirrored[0].GetRoute().GetRequestMirrorPolicies()
+	if len(policies) != 2 || policies[0].Cluster != "outbound|80||shadow.default.svc.cluster.local" ||
+		policies[1].Cluster != "outbound|80||canary.default.svc.cluster.local" {
+		t.Errorf("unexpected mirror policies %v", policies)
+	}
+	if len(r.GetMatch().GetHeaders()) != 0 || len(r.GetRoute().GetRequestMirrorPolicies()) != 1 {
+		t.Errorf("the original route was modified: %v", r)
+	}
+
+	if got := buildConditionalMirrorRoutes(&route.Route{Action: &route.Route_DirectResponse{}}, in, vs, nil, 80); got != nil {
+		t.Errorf("got conditional mirror routes %v for a direct response route", got)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/hashicorp/go-version"
+	"google.golang.org/protobuf/proto"
 	anypb "google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/durationpb"
 	wrappers "google.golang.org/protobuf/types/known/wrapperspb"
@@ -43,12 +44,14 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	authz "istio.io/istio/pilot/pkg/security/authz/model"
 	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/mirror"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/labels"
 	"istio.io/istio/pkg/jwt"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
 	"istio.io/istio/pkg/util/grpc"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -316,6 +319,10 @@ type RouteOptions struct {
 	// IsHTTP3AltSvcHeaderNeeded indicates if HTTP3 alt-svc header needs to be inserted
 	IsHTTP3AltSvcHeaderNeeded bool
 	Mesh                      *meshconfig.MeshConfig
+	// Added by ingress
+	// HTTP3AltSvcMaxAge is the max age in seconds of the HTTP3 alt-svc header, one day if zero
+	HTTP3AltSvcMaxAge uint32
+	// End added by ingress
 }
 
 // BuildHTTPRoutesForVirtualService creates data plane HTTP routes from the virtual service spec.
@@ -347,6 +354,9 @@ func BuildHTTPRoutesForVirtualService(
 		if len(http.Match) == 0 {
 			if r := translateRoute(node, http, nil, listenPort, virtualService, serviceRegistry,
 				hashByDestination, gatewayNames, opts); r != nil {
+				// Added by ingress
+				out = append(out, buildConditionalMirrorRoutes(r, http, virtualService, serviceRegistry, listenPort)...)
+				// End added by ingress
 				out = append(out, r)
 			}
 			catchall = true
@@ -354,6 +364,9 @@ func BuildHTTPRoutesForVirtualService(
 			for _, match := range http.Match {
 				if r := translateRoute(node, http, match, listenPort, virtualService, serviceRegistry,
 					hashByDestination, gatewayNames, opts); r != nil {
+					// Added by ingress
+					out = append(out, buildConditionalMirrorRoutes(r, http, virtualService, serviceRegistry, listenPort)...)
+					// End added by ingress
 					out = append(out, r)
 					// This is a catch all path. Routes are matched in order, so we will never go beyond this match
 					// As an optimization, we can just top sending any more routes here.
@@ -398,16 +411,18 @@ func BuildHTTPRoutesForVirtualServiceWithHTTPFilters(
 		if len(http.Match) == 0 {
 			if r := translateRoute(node, http, nil, listenPort, virtualService, serviceRegistry,
 				hashByDestination, gatewayNames, opts); r != nil {
-				out = append(out, r)
 				r.TypedPerFilterConfig = mseingress.ConstructTypedPerFilterConfigForRoute(globalHTTPFilters, virtualService, http)
+				out = append(out, buildConditionalMirrorRoutes(r, http, virtualService, serviceRegistry, listenPort)...)
+				out = append(out, r)
 			}
 			catchall = true
 		} else {
 			for _, match := range http.Match {
 				if r := translateRoute(node, http, match, listenPort, virtualService, serviceRegistry,
 					hashByDestination, gatewayNames, opts); r != nil {
-					out = append(out, r)
 					r.TypedPerFilterConfig = mseingress.ConstructTypedPerFilterConfigForRoute(globalHTTPFilters, virtualService, http)
+					out = append(out, buildConditionalMirrorRoutes(r, http, virtualService, serviceRegistry, listenPort)...)
+					out = append(out, r)
 					// This is a catch all path. Routes are matched in order, so we will never go beyond this match
 					// As an optimization, we can just top sending any more routes here.
 					if isCatchAllMatch(match) {
@@ -518,7 +533,9 @@ func translateRoute(
 	}
 
 	if opts.IsHTTP3AltSvcHeaderNeeded {
-		http3AltSvcHeader := buildHTTP3AltSvcHeader(listenPort, util.ALPNHttp3OverQUIC)
+		// Modified by ingress
+		http3AltSvcHeader := buildHTTP3AltSvcHeader(listenPort, util.ALPNHttp3OverQUIC, opts.HTTP3AltSvcMaxAge)
+		// End modified by ingress
 		if out.ResponseHeadersToAdd == nil {
 			out.ResponseHeadersToAdd = make([]*core.HeaderValueOption, 0)
 		}
@@ -545,7 +562,9 @@ func applyHTTPRouteDestination(
 		policy = mesh.GetDefaultHttpRetryPolicy()
 	}
 	action := &route.RouteAction{
-		Cors:        TranslateCORSPolicy(in.CorsPolicy),
+		// Modified by ingress
+		Cors: TranslateCORSPolicy(mseingress.ApplyCORSDefaults(mseingress.CORSDefaults(), node, in.CorsPolicy)),
+		// End modified by ingress
 		RetryPolicy: retry.ConvertPolicy(policy),
 		// Added by ingress
 		InternalActiveRedirectPolicy: TranslateInternalActiveRedirectPolicy(in.InternalActiveRedirect, util.RegexEngine),
@@ -586,15 +605,15 @@ func applyHTTPRouteDestination(
 		}
 	}
 
-	if in.Mirror != nil {
-		if mp := MirrorPercent(in); mp != nil {
-			action.RequestMirrorPolicies = []*route.RouteAction_RequestMirrorPolicy{{
-				Cluster:         GetDestinationCluster(in.Mirror, serviceRegistry[host.Name(in.Mirror.Host)], listenerPort),
-				RuntimeFraction: mp,
-				TraceSampled:    &wrappers.BoolValue{Value: false},
-			}}
+	// Modified by ingress
+	for _, target := range mirrorTargets(in, vs) {
+		// The mirror destinations with header conditions are set on the conditional mirror routes.
+		if len(target.headers) == 0 {
+			action.RequestMirrorPolicies = append(action.RequestMirrorPolicies,
+				buildMirrorPolicy(target, serviceRegistry, listenerPort))
 		}
 	}
+	// End modified by ingress
 
 	var totalWeight uint32
 	// TODO: eliminate this logic and use the total_weight option in envoy route
@@ -895,14 +914,19 @@ func ApplyDirectResponse(out *route.Route, directResponse *networking.HTTPDirect
 	out.Action = action
 }
 
-func buildHTTP3AltSvcHeader(port int, h3Alpns []string) *core.HeaderValueOption {
+func buildHTTP3AltSvcHeader(port int, h3Alpns []string, maxAge uint32) *core.HeaderValueOption {
 	// For example, www.cloudflare.com returns the following
 	// alt-svc: h3-27=":443"; ma=86400, h3-28=":443"; ma=86400, h3-29=":443"; ma=86400, h3=":443"; ma=86400
+	// Modified by ingress
+	// Max-age defaults to 1 day.
+	if maxAge == 0 {
+		maxAge = 86400
+	}
 	valParts := make([]string, 0, len(h3Alpns))
 	for _, alpn := range h3Alpns {
-		// Max-age is hardcoded to 1 day for now.
-		valParts = append(valParts, fmt.Sprintf(`%s=":%d"; ma=86400`, alpn, port))
+		valParts = append(valParts, fmt.Sprintf(`%s=":%d"; ma=%d`, alpn, port, maxAge))
 	}
+	// End modified by ingress
 	headerVal := strings.Join(valParts, ", ")
 	return &core.HeaderValueOption{
 		AppendAction: core.HeaderValueOption_APPEND_IF_EXISTS_OR_ADD,
@@ -944,6 +968,106 @@ func MirrorPercent(in *networking.HTTPRoute) *core.RuntimeFractionalPercent {
 	}
 }
 
+// Added by ingress
+
+// mirrorTarget is a mirror destination of a route, with the percentage of the traffic mirrored and the request header
+// conditions of the higress.io/mirror-headers annotation.
+type mirrorTarget struct {
+	destination *networking.Destination
+	percent     *core.RuntimeFractionalPercent
+	headers     map[string]*networking.StringMatch
+}
+
+// mirrorTargets returns the mirror destinations of a route, its mirror followed by its mirrors, skipping the ones
+// mirroring no traffic.
+func mirrorTargets(in *networking.HTTPRoute, vs config.Config) []mirrorTarget {
+	if in.Mirror == nil && len(in.Mirrors) == 0 {
+		return nil
+	}
+	var headers mirror.Headers
+	if value, ok := vs.Annotations[constants.MirrorHeadersAnnotation]; ok {
+		var err error
+		if headers, err = mirror.Parse(value); err != nil {
+			log.Warnf("ignore the mirror headers of virtual service %s/%s: %v", vs.Namespace, vs.Name, err)
+		}
+	}
+	var targets []mirrorTarget
+	if in.Mirror != nil {
+		if mp := MirrorPercent(in); mp != nil {
+			targets = append(targets, mirrorTarget{destination: in.Mirror, percent: mp, headers: headers[in.Mirror.Host]})
+		}
+	}
+	for _, policy := range in.Mirrors {
+		if policy.GetDestination() == nil {
+			continue
+		}
+		if mp := mirrorPolicyPercent(policy); mp != nil {
+			targets = append(targets, mirrorTarget{
+				destination: policy.Destination,
+				percent:     mp,
+				headers:     headers[policy.Destination.Host],
+			})
+		}
+	}
+	return targets
+}
+
+// mirrorPolicyPercent computes the mirror percent of a mirror destination, 100 if unset.
+func mirrorPolicyPercent(in *networking.HTTPMirrorPolicy) *core.RuntimeFractionalPercent {
+	if in.Percentage == nil {
+		return &core.RuntimeFractionalPercent{
+			DefaultValue: translateIntegerToFractionalPercent(100),
+		}
+	}
+	if in.Percentage.GetValue() > 0 {
+		return &core.RuntimeFractionalPercent{
+			DefaultValue: translatePercentToFractionalPercent(in.Percentage),
+		}
+	}
+	// If zero percent is provided explicitly, we should not mirror.
+	return nil
+}
+
+func buildMirrorPolicy(target mirrorTarget, serviceRegistry map[host.Name]*model.Service,
+	listenerPort int,
+) *route.RouteAction_RequestMirrorPolicy {
+	return &route.RouteAction_RequestMirrorPolicy{
+		Cluster:         GetDestinationCluster(target.destination, serviceRegistry[host.Name(target.destination.Host)], listenerPort),
+		RuntimeFraction: target.percent,
+		TraceSampled:    &wrappers.BoolValue{Value: false},
+	}
+}
+
+// buildConditionalMirrorRoutes returns a copy of the route r for each mirror destination of in with header
+// conditions, additionally matching its headers and mirroring to it. They are to be placed before r, so a request
+// matching the conditions of several mirror destinations is only mirrored to the first one.
+func buildConditionalMirrorRoutes(r *route.Route, in *networking.HTTPRoute, vs config.Config,
+	serviceRegistry map[host.Name]*model.Service, listenerPort int,
+) []*route.Route {
+	if r.GetRoute() == nil {
+		return nil
+	}
+	var out []*route.Route
+	for _, target := range mirrorTargets(in, vs) {
+		if len(target.headers) == 0 {
+			continue
+		}
+		mirrored := proto.Clone(r).(*route.Route)
+		names := maps.Keys(target.headers)
+		sort.Strings(names)
+		for _, name := range names {
+			mirrored.Match.Headers = append(mirrored.Match.Headers, translateHeaderMatch(name, target.headers[name]))
+		}
+		action := mirrored.GetRoute()
+		action.RequestMirrorPolicies = append(action.RequestMirrorPolicies,
+			buildMirrorPolicy(target, serviceRegistry, listenerPort))
+		out = append(out, mirrored)
+	}
+	return out
+}
+
+// End added by ingress
+
 // Len is i the sort.Interface for SortHeaderValueOption
 func (b SortHeaderValueOption) Len() int {
 	return len(b)
@@ -100,6 +100,11 @@ func (b *Builder) BuildHTTP(class networking.ListenerClass) []*hcm.HttpFilter {
 		return nil
 	}
 	res := []*hcm.HttpFilter{}
+	// Added by ingress
+	if filter := b.applier.JwtClaimHeadersFilter(); filter != nil {
+		res = append(res, filter)
+	}
+	// End added by ingress
 	if filter := b.applier.JwtFilter(); filter != nil {
 		res = append(res, filter)
 	}
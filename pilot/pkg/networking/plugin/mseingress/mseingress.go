@@ -55,6 +55,18 @@ func (b *Builder) BuildHTTPFilters(cur []*httppb.HttpFilter) []*httppb.HttpFilte
 	if filter := b.addLocalRateLimitWithNeed(cur); filter != nil {
 		result = append(result, filter)
 	}
+	if filter := b.addRateLimitWithNeed(cur); filter != nil {
+		result = append(result, filter)
+	}
+	if filter := b.addBufferWithNeed(cur); filter != nil {
+		result = append(result, filter)
+	}
+	if filter := b.addExtProcWithNeed(cur); filter != nil {
+		result = append(result, filter)
+	}
+	if filter := b.addGrpcJSONTranscoderWithNeed(cur); filter != nil {
+		result = append(result, filter)
+	}
 	return result
 }
 
@@ -111,3 +123,59 @@ func (b *Builder) addLocalRateLimitWithNeed(cur []*httppb.HttpFilter) *httppb.Ht
 
 	return GlobalLocalRateLimitFilter
 }
+
+func (b *Builder) addRateLimitWithNeed(cur []*httppb.HttpFilter) *httppb.HttpFilter {
+	for _, filter := range b.push.GetHTTPFiltersFromEnvoyFilter(b.proxy) {
+		if filter.Name == mseingress.RateLimitFilterName {
+			return nil
+		}
+	}
+	for _, filter := range cur {
+		if filter.Name == mseingress.RateLimitFilterName {
+			return nil
+		}
+	}
+	return mseingress.BuildRateLimitFilter()
+}
+
+func (b *Builder) addBufferWithNeed(cur []*httppb.HttpFilter) *httppb.HttpFilter {
+	for _, filter := range b.push.GetHTTPFiltersFromEnvoyFilter(b.proxy) {
+		if filter.Name == mseingress.BufferFilterName {
+			return nil
+		}
+	}
+	for _, filter := range cur {
+		if filter.Name == mseingress.BufferFilterName {
+			return nil
+		}
+	}
+	return mseingress.BuildBufferFilter()
+}
+
+func (b *Builder) addExtProcWithNeed(cur []*httppb.HttpFilter) *httppb.HttpFilter {
+	for _, filter := range b.push.GetHTTPFiltersFromEnvoyFilter(b.proxy) {
+		if filter.Name == mseingress.ExtProcFilterName {
+			return nil
+		}
+	}
+	for _, filter := range cur {
+		if filter.Name == mseingress.ExtProcFilterName {
+			return nil
+		}
+	}
+	return mseingress.BuildExtProcFilter()
+}
+
+func (b *Builder) addGrpcJSONTranscoderWithNeed(cur []*httppb.HttpFilter) *httppb.HttpFilter {
+	for _, filter := range b.push.GetHTTPFiltersFromEnvoyFilter(b.proxy) {
+		if filter.Name == mseingress.GrpcJSONTranscoderFilterName {
+			return nil
+		}
+	}
+	for _, filter := range cur {
+		if filter.Name == mseingress.GrpcJSONTranscoderFilterName {
+			return nil
+		}
+	}
+	return mseingress.BuildGrpcJSONTranscoderFilter()
+}
@@ -34,6 +34,12 @@ type PolicyApplier interface {
 	// It may return nil, if no JWT validation is needed.
 	JwtFilter() *hcm.HttpFilter
 
+	// Added by ingress
+	// JwtClaimHeadersFilter returns the HTTP filter removing the headers set from the JWTs from the requests, before
+	// the JWT filter. It may return nil, if no header is removed.
+	JwtClaimHeadersFilter() *hcm.HttpFilter
+	// End added by ingress
+
 	// AuthNFilter returns the (authn) HTTP filter to enforce the underlying authentication policy.
 	// It may return nil, if no authentication is needed.
 	AuthNFilter(forSidecar bool) *hcm.HttpFilter
@@ -0,0 +1,106 @@
+package v1beta1
+
+import (
+	"strings"
+
+	mutation_rules "github.com/envoyproxy/go-control-plane/envoy/config/common/mutation_rules/v3"
+	header_mutation "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_mutation/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/ali/config/jwtclaims"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// JwtClaimHeadersFilterName is the name of the header mutation filter removing the headers set from the JWTs from the
+// requests, before the JWT filter.
+const JwtClaimHeadersFilterName = "higress.filters.http.jwt_claim_headers"
+
+// applyJwtClaimHeaders returns the JWT rules of a RequestAuthentication with the claims copied to the request headers
+// by its higress.io/jwt-claim-headers annotation, and adds to sanitized the headers set from its JWTs that the
+// clients may not send.
+func applyJwtClaimHeaders(policy *config.Config, rules []*v1beta1.JWTRule, sanitized sets.String) []*v1beta1.JWTRule {
+	value, ok := policy.Annotations[constants.JWTClaimHeadersAnnotation]
+	if !ok {
+		return rules
+	}
+	spec, err := jwtclaims.Parse(value)
+	if err != nil {
+		authnLog.Warnf("ignoring jwt claim headers of request authentication %s/%s: %v", policy.Namespace, policy.Name, err)
+		return rules
+	}
+	headers := make([]string, 0, len(spec.Claims))
+	for header := range spec.Claims {
+		headers = append(headers, header)
+	}
+	slices.Sort(headers)
+
+	out := make([]*v1beta1.JWTRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		rule = rule.DeepCopy()
+		set := sets.New[string]()
+		for _, claimToHeader := range rule.OutputClaimToHeaders {
+			set.Insert(strings.ToLower(claimToHeader.GetHeader()))
+		}
+		for _, header := range headers {
+			if !set.InsertContains(strings.ToLower(header)) {
+				rule.OutputClaimToHeaders = append(rule.OutputClaimToHeaders, &v1beta1.ClaimToHeader{
+					Header: header,
+					Claim:  spec.Claims[header],
+				})
+			}
+		}
+		if rule.OutputPayloadToHeader != "" {
+			set.Insert(strings.ToLower(rule.OutputPayloadToHeader))
+		}
+		if spec.SanitizeEnabled() {
+			sanitized.Merge(set)
+		}
+		out = append(out, rule)
+	}
+	return out
+}
+
+// sanitizedJwtHeaders returns the headers removed from the requests before verifying the JWTs, but the ones the JWT
+// rules read the JWTs from.
+func sanitizedJwtHeaders(sanitized sets.String, rules []*v1beta1.JWTRule) []string {
+	if sanitized.IsEmpty() {
+		return nil
+	}
+	out := sanitized.Copy()
+	for _, rule := range rules {
+		if len(rule.FromHeaders) == 0 && len(rule.FromParams) == 0 {
+			out.Delete("authorization")
+		}
+		for _, location := range rule.FromHeaders {
+			out.Delete(strings.ToLower(location.GetName()))
+		}
+	}
+	return sets.SortedList(out)
+}
+
+// buildJwtClaimHeadersFilter returns the filter removing the headers from the requests, or nil if there are none.
+func buildJwtClaimHeadersFilter(headers []string) *hcm.HttpFilter {
+	if len(headers) == 0 {
+		return nil
+	}
+	mutations := &header_mutation.Mutations{}
+	for _, header := range headers {
+		mutations.RequestMutations = append(mutations.RequestMutations, &mutation_rules.HeaderMutation{
+			Action: &mutation_rules.HeaderMutation_Remove{Remove: header},
+		})
+	}
+	return &hcm.HttpFilter{
+		Name: JwtClaimHeadersFilterName,
+		ConfigType: &hcm.HttpFilter_TypedConfig{TypedConfig: protoconv.MessageToAny(&header_mutation.HeaderMutation{
+			Mutations: mutations,
+		})},
+	}
+}
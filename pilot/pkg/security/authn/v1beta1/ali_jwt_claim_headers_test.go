@@ -0,0 +1,115 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+
+	header_mutation "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_mutation/v3"
+
+	"istio.io/api/security/v1beta1"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+)
+
+func TestJwtClaimHeadersFilter(t *testing.T) {
+	policy := func(name, annotation string, rules ...*v1beta1.JWTRule) *config.Config {
+		cfg := &config.Config{
+			Meta: config.Meta{Name: name, Namespace: "default"},
+			Spec: &v1beta1.RequestAuthentication{JwtRules: rules},
+		}
+		if annotation != "" {
+			cfg.Annotations = map[string]string{constants.JWTClaimHeadersAnnotation: annotation}
+		}
+		return cfg
+	}
+	issuer := &v1beta1.JWTRule{
+		Issuer:                "https://issuer.example.com",
+		OutputPayloadToHeader: "x-jwt-payload",
+		OutputClaimToHeaders:  []*v1beta1.ClaimToHeader{{Header: "x-user-id", Claim: "sub"}},
+	}
+	cases := []struct {
+		name        string
+		in          []*config.Config
+		wantClaims  []*v1beta1.ClaimToHeader
+		wantRemoved []string
+	}{
+		{
+			name:       "no annotation",
+			in:         []*config.Config{policy("authn", "", issuer)},
+			wantClaims: issuer.OutputClaimToHeaders,
+		},
+		{
+			name: "claims",
+			in:   []*config.Config{policy("authn", `{"claims": {"x-user-id": "uid", "x-tenant": "org.tenant"}}`, issuer)},
+			wantClaims: []*v1beta1.ClaimToHeader{
+				{Header: "x-user-id", Claim: "sub"},
+				{Header: "x-tenant", Claim: "org.tenant"},
+			},
+			wantRemoved: []string{"x-jwt-payload", "x-tenant", "x-user-id"},
+		},
+		{
+			name:        "not sanitized",
+			in:          []*config.Config{policy("authn", `{"claims": {"x-tenant": "tenant"}, "sanitize": false}`, issuer)},
+			wantClaims:  append([]*v1beta1.ClaimToHeader{issuer.OutputClaimToHeaders[0]}, &v1beta1.ClaimToHeader{Header: "x-tenant", Claim: "tenant"}),
+			wantRemoved: nil,
+		},
+		{
+			name: "token header",
+			in: []*config.Config{
+				policy("authn", `{"claims": {"authorization": "sub", "x-token": "sub"}}`, issuer),
+				policy("other", "", &v1beta1.JWTRule{
+					Issuer:      "https://other.example.com",
+					FromHeaders: []*v1beta1.JWTHeader{{Name: "X-Token"}},
+				}),
+			},
+			wantClaims: []*v1beta1.ClaimToHeader{
+				{Header: "x-user-id", Claim: "sub"},
+				{Header: "authorization", Claim: "sub"},
+				{Header: "x-token", Claim: "sub"},
+			},
+			wantRemoved: []string{"x-jwt-payload", "x-user-id"},
+		},
+		{
+			name:       "invalid annotation",
+			in:         []*config.Config{policy("authn", `{"claims": {"x-user-id": ""}}`, issuer)},
+			wantClaims: issuer.OutputClaimToHeaders,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			applier := NewPolicyApplier("root-namespace", c.in, nil, &model.PushContext{}).(v1beta1PolicyApplier)
+			var claims []*v1beta1.ClaimToHeader
+			for _, rule := range applier.processedJwtRules {
+				if rule.Issuer == issuer.Issuer {
+					claims = rule.OutputClaimToHeaders
+				}
+			}
+			if !reflect.DeepEqual(claims, c.wantClaims) {
+				t.Errorf("got claims %v, want %v", claims, c.wantClaims)
+			}
+			if len(issuer.OutputClaimToHeaders) != 1 {
+				t.Fatalf("the jwt rule was modified: %v", issuer)
+			}
+
+			filter := applier.JwtClaimHeadersFilter()
+			if c.wantRemoved == nil {
+				if filter != nil {
+					t.Errorf("got filter %v, want none", filter)
+				}
+				return
+			}
+			mutation := &header_mutation.HeaderMutation{}
+			if err := filter.GetTypedConfig().UnmarshalTo(mutation); err != nil {
+				t.Fatal(err)
+			}
+			var removed []string
+			for _, m := range mutation.GetMutations().GetRequestMutations() {
+				removed = append(removed, m.GetRemove())
+			}
+			if !reflect.DeepEqual(removed, c.wantRemoved) {
+				t.Errorf("got removed headers %v, want %v", removed, c.wantRemoved)
+			}
+		})
+	}
+}
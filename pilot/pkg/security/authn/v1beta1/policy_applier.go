@@ -41,6 +41,7 @@ import (
 	"istio.io/istio/pkg/jwt"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
 )
 
 var authnLog = log.RegisterScope("authn", "authn debugging")
@@ -53,6 +54,11 @@ type v1beta1PolicyApplier struct {
 	consolidatedPeerPolicy MergedPeerAuthentication
 
 	push *model.PushContext
+
+	// Added by ingress
+	// sanitizedHeaders are the headers set from the JWTs removed from the requests before verifying the JWTs.
+	sanitizedHeaders []string
+	// End added by ingress
 }
 
 // NewPolicyApplier returns new applier for v1beta1 authentication policies.
@@ -65,9 +71,14 @@ func NewPolicyApplier(rootNamespace string,
 
 	// TODO(diemtvu) should we need to deduplicate JWT with the same issuer.
 	// https://github.com/istio/istio/issues/19245
+	// Added by ingress
+	sanitized := sets.New[string]()
+	// End added by ingress
 	for idx := range jwtPolicies {
 		spec := jwtPolicies[idx].Spec.(*v1beta1.RequestAuthentication)
-		processedJwtRules = append(processedJwtRules, spec.JwtRules...)
+		// Modified by ingress
+		processedJwtRules = append(processedJwtRules, applyJwtClaimHeaders(jwtPolicies[idx], spec.JwtRules, sanitized)...)
+		// End modified by ingress
 	}
 
 	// Sort the jwt rules by the issuer alphabetically to make the later-on generated filter
@@ -80,6 +91,9 @@ func NewPolicyApplier(rootNamespace string,
 		processedJwtRules:      processedJwtRules,
 		consolidatedPeerPolicy: ComposePeerAuthentication(rootNamespace, peerPolicies),
 		push:                   push,
+		// Added by ingress
+		sanitizedHeaders: sanitizedJwtHeaders(sanitized, processedJwtRules),
+		// End added by ingress
 	}
 }
 
@@ -99,6 +113,19 @@ func (a v1beta1PolicyApplier) JwtFilter() *hcm.HttpFilter {
 	}
 }
 
+// Added by ingress
+
+// JwtClaimHeadersFilter returns the filter removing from the requests the headers set from the JWTs by the
+// RequestAuthentications with the higress.io/jwt-claim-headers annotation, so that only verified JWTs set them.
+func (a v1beta1PolicyApplier) JwtClaimHeadersFilter() *hcm.HttpFilter {
+	if len(a.processedJwtRules) == 0 {
+		return nil
+	}
+	return buildJwtClaimHeadersFilter(a.sanitizedHeaders)
+}
+
+// End added by ingress
+
 func defaultAuthnFilter() *authn_filter.FilterConfig {
 	return &authn_filter.FilterConfig{
 		Policy: &authn_alpha.Policy{},
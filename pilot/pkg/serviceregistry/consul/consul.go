@@ -0,0 +1,368 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package consul serves the services of the catalog of Consul as ServiceEntries.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// hostSuffix is the suffix of the hostnames of the services, as in the DNS interface of Consul.
+	hostSuffix = "service.consul"
+	// protocolMetadata is the metadata of the services telling their protocol, HTTP by default.
+	protocolMetadata = "protocol"
+	// zoneMetadata is the metadata of the nodes telling their zone within their datacenter.
+	zoneMetadata = "zone"
+	// consulService is the service of the servers of Consul, not synced.
+	consulService = "consul"
+
+	defaultRefreshInterval = 30 * time.Second
+	// watchWait is how long the blocking queries watching the catalog wait for changes.
+	watchWait = 5 * time.Minute
+
+	healthPassing  = "passing"
+	healthWarning  = "warning"
+	healthCritical = "critical"
+	// maintenanceCheckPrefix is the prefix of the IDs of the checks of the nodes and services in maintenance.
+	maintenanceCheckPrefix = "_"
+)
+
+// Options are the settings of the Consul registry, set as JSON by PILOT_CONSUL_REGISTRY.
+type Options struct {
+	// Address is the base URL of the HTTP API of Consul, such as http://consul.consul:8500.
+	Address string `json:"address"`
+	// Datacenters are the datacenters of the services, all the datacenters known to Consul if empty.
+	Datacenters []string `json:"datacenters,omitempty"`
+	// Token is the ACL token of the requests to Consul.
+	Token string `json:"token,omitempty"`
+	// RefreshInterval is how often the services are fetched when the catalog does not change, 30s if unset.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// ParseOptions parses and validates the settings of the Consul registry.
+func ParseOptions(value string) (*Options, error) {
+	opts := &Options{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(opts); err != nil {
+		return nil, fmt.Errorf("invalid consul registry: %v", err)
+	}
+	u, err := url.Parse(opts.Address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid consul registry: invalid address %q, must be an http or https URL", opts.Address)
+	}
+	if opts.RefreshInterval != "" {
+		if d, err := time.ParseDuration(opts.RefreshInterval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid consul registry: invalid refreshInterval %q", opts.RefreshInterval)
+		}
+	}
+	return opts, nil
+}
+
+// Interval returns how often the services are fetched when the catalog does not change.
+func (o *Options) Interval() time.Duration {
+	if d, err := time.ParseDuration(o.RefreshInterval); err == nil {
+		return d
+	}
+	return defaultRefreshInterval
+}
+
+type serviceEntry struct {
+	Node    node    `json:"Node"`
+	Service service `json:"Service"`
+	Checks  []check `json:"Checks"`
+}
+
+type node struct {
+	Node       string            `json:"Node"`
+	Address    string            `json:"Address"`
+	Datacenter string            `json:"Datacenter"`
+	Meta       map[string]string `json:"Meta"`
+}
+
+type service struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service"`
+	Address string            `json:"Address"`
+	Port    uint32            `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+	Weights weights           `json:"Weights"`
+}
+
+type weights struct {
+	Passing uint32 `json:"Passing"`
+	Warning uint32 `json:"Warning"`
+}
+
+type check struct {
+	CheckID string `json:"CheckID"`
+	Status  string `json:"Status"`
+}
+
+// Source fetches the services of the catalog of Consul with its HTTP API.
+type Source struct {
+	opts   *Options
+	client *http.Client
+
+	mu sync.Mutex
+	// indexes are the last indexes of the watched endpoints of the API.
+	indexes map[string]uint64
+}
+
+var (
+	_ external.Source  = &Source{}
+	_ external.Watcher = &Source{}
+)
+
+// NewSource creates a Source fetching the services of Consul.
+func NewSource(opts *Options) *Source {
+	return &Source{
+		opts:    opts,
+		client:  &http.Client{Timeout: watchWait + 30*time.Second},
+		indexes: map[string]uint64{},
+	}
+}
+
+// Provider returns the ID of the Consul registry.
+func (s *Source) Provider() provider.ID {
+	return provider.Consul
+}
+
+// Fetch returns a ServiceEntry for each service of the datacenters with instances, its hostname being
+// <service>.service.consul. The instances of all the datacenters are its endpoints, with their datacenter as locality
+// region, so the proxies prefer the instances of their own datacenter. Their health status is mapped from the
+// health checks of their service and node.
+func (s *Source) Fetch(ctx context.Context) ([]config.Config, error) {
+	datacenters, err := s.datacenters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instances := map[string][]serviceEntry{}
+	for _, dc := range datacenters {
+		services := map[string][]string{}
+		if _, err := s.get(ctx, "/v1/catalog/services", url.Values{"dc": {dc}}, &services); err != nil {
+			return nil, fmt.Errorf("failed to list the services of datacenter %s: %v", dc, err)
+		}
+		for name := range services {
+			if name == consulService {
+				continue
+			}
+			var entries []serviceEntry
+			if _, err := s.get(ctx, "/v1/health/service/"+url.PathEscape(name), url.Values{"dc": {dc}}, &entries); err != nil {
+				return nil, fmt.Errorf("failed to list the instances of service %s of datacenter %s: %v", name, dc, err)
+			}
+			for i := range entries {
+				if entries[i].Node.Datacenter == "" {
+					entries[i].Node.Datacenter = dc
+				}
+			}
+			instances[name] = append(instances[name], entries...)
+		}
+	}
+	out := make([]config.Config, 0, len(instances))
+	for name, entries := range instances {
+		if cfg, ok := buildServiceEntry(name, entries); ok {
+			out = append(out, cfg)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// Wait blocks until the services of the datacenters or the status of their health checks change, watching them with
+// the blocking queries of Consul.
+func (s *Source) Wait(ctx context.Context) error {
+	datacenters, err := s.datacenters(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, 2*len(datacenters))
+	for _, dc := range datacenters {
+		for _, path := range []string{"/v1/catalog/services", "/v1/health/state/any"} {
+			go func(path, dc string) {
+				errs <- s.waitIndex(ctx, path, dc)
+			}(path, dc)
+		}
+	}
+	// Return as soon as one of the watches returns.
+	return <-errs
+}
+
+// waitIndex runs a blocking query of the path until its index changes from the one of the previous query. The first
+// query of the path only records its index.
+func (s *Source) waitIndex(ctx context.Context, path, dc string) error {
+	key := dc + path
+	for {
+		s.mu.Lock()
+		last, known := s.indexes[key]
+		s.mu.Unlock()
+		query := url.Values{"dc": {dc}, "wait": {watchWait.String()}}
+		if known {
+			query.Set("index", strconv.FormatUint(last, 10))
+		}
+		var discard json.RawMessage
+		index, err := s.get(ctx, path, query, &discard)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.indexes[key] = index
+		s.mu.Unlock()
+		// Any change of the index counts, as it also goes backwards when Consul is reset.
+		if known && index != last {
+			return nil
+		}
+	}
+}
+
+func (s *Source) datacenters(ctx context.Context) ([]string, error) {
+	if len(s.opts.Datacenters) > 0 {
+		return s.opts.Datacenters, nil
+	}
+	var datacenters []string
+	if _, err := s.get(ctx, "/v1/catalog/datacenters", url.Values{}, &datacenters); err != nil {
+		return nil, fmt.Errorf("failed to list the datacenters: %v", err)
+	}
+	return datacenters, nil
+}
+
+func buildServiceEntry(name string, entries []serviceEntry) (config.Config, bool) {
+	if len(entries) == 0 {
+		return config.Config{}, false
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Node.Datacenter != entries[j].Node.Datacenter {
+			return entries[i].Node.Datacenter < entries[j].Node.Datacenter
+		}
+		return entries[i].Service.ID < entries[j].Service.ID
+	})
+	hostname := external.DNSLabel(name) + "." + hostSuffix
+	proto := protocol.HTTP
+	if p := protocol.Parse(entries[0].Service.Meta[protocolMetadata]); p != protocol.Unsupported {
+		proto = p
+	}
+	portName := strings.ToLower(string(proto))
+	se := &networking.ServiceEntry{
+		Hosts:      []string{hostname},
+		Ports:      []*networking.ServicePort{{Number: entries[0].Service.Port, Protocol: string(proto), Name: portName}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	for _, e := range entries {
+		address := e.Service.Address
+		if address == "" {
+			address = e.Node.Address
+		}
+		health, weight := healthStatus(e)
+		if address == "" || weight == 0 {
+			continue
+		}
+		labels := external.EndpointLabels(e.Service.Meta)
+		labels[constants.EndpointHealthStatusLabel] = health
+		locality := external.DNSLabel(e.Node.Datacenter)
+		if zone := e.Node.Meta[zoneMetadata]; zone != "" {
+			locality += "/" + zone
+		}
+		se.Endpoints = append(se.Endpoints, &networking.WorkloadEntry{
+			Address:  address,
+			Ports:    map[string]uint32{portName: e.Service.Port},
+			Labels:   labels,
+			Locality: locality,
+			Weight:   weight,
+		})
+	}
+	return config.Config{
+		Meta: config.Meta{
+			Name: external.ServiceEntryName(provider.Consul, hostname),
+		},
+		Spec: se,
+	}, true
+}
+
+// healthStatus maps the health checks of an instance to the health status of its endpoint, with its weight. An
+// instance in maintenance is draining, one with a critical check unhealthy. An instance with a warning check is
+// healthy, weighted by its warning weight.
+func healthStatus(e serviceEntry) (string, uint32) {
+	status := healthPassing
+	for _, c := range e.Checks {
+		switch {
+		case c.Status == healthCritical && strings.HasPrefix(c.CheckID, maintenanceCheckPrefix):
+			return "draining", 1
+		case c.Status == healthCritical:
+			status = healthCritical
+		case c.Status == healthWarning && status == healthPassing:
+			status = healthWarning
+		}
+	}
+	switch status {
+	case healthCritical:
+		return "unhealthy", 1
+	case healthWarning:
+		return "healthy", e.Service.Weights.Warning
+	default:
+		if e.Service.Weights.Passing == 0 {
+			// Consul before 1.2.3 did not weigh the instances.
+			return "healthy", 1
+		}
+		return "healthy", e.Service.Weights.Passing
+	}
+}
+
+// get calls the HTTP API of Consul, and returns the index of the response.
+func (s *Source) get(ctx context.Context, path string, query url.Values, out any) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.opts.Address, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if s.opts.Token != "" {
+		req.Header.Set("X-Consul-Token", s.opts.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s responded %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return index, json.Unmarshal(body, out)
+}
@@ -0,0 +1,167 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions(`{"address":"http://consul:8500","datacenters":["dc1"],"token":"t","refreshInterval":"1m"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, &Options{Address: "http://consul:8500", Datacenters: []string{"dc1"}, Token: "t", RefreshInterval: "1m"})
+	assert.Equal(t, opts.Interval(), time.Minute)
+	opts, err = ParseOptions(`{"address":"http://consul:8500"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts.Interval(), defaultRefreshInterval)
+	for _, invalid := range []string{
+		`{}`,
+		`{"address":"consul:8500"}`,
+		`{"address":"http://consul:8500","refreshInterval":"0s"}`,
+		`{"address":"http://consul:8500","namespace":"a"}`,
+	} {
+		if _, err := ParseOptions(invalid); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+func instance(dc, id, address string, meta map[string]string, checks ...check) serviceEntry {
+	return serviceEntry{
+		Node:    node{Node: id, Address: address, Datacenter: dc, Meta: map[string]string{"zone": "z1"}},
+		Service: service{ID: id, Service: "web", Port: 8080, Meta: meta, Weights: weights{Passing: 10, Warning: 1}},
+		Checks:  checks,
+	}
+}
+
+func fakeConsul(t *testing.T, index *atomic.Uint64) *httptest.Server {
+	mux := http.NewServeMux()
+	handle := func(path string, f func(r *http.Request) any) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Consul-Token") != "token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(index.Load(), 10))
+			_ = json.NewEncoder(w).Encode(f(r))
+		})
+	}
+	handle("/v1/catalog/datacenters", func(*http.Request) any {
+		return []string{"dc1", "dc2"}
+	})
+	handle("/v1/catalog/services", func(*http.Request) any {
+		return map[string][]string{"consul": {}, "web": {"v1"}}
+	})
+	handle("/v1/health/state/any", func(*http.Request) any {
+		return []check{}
+	})
+	handle("/v1/health/service/web", func(r *http.Request) any {
+		if r.URL.Query().Get("dc") == "dc2" {
+			return []serviceEntry{instance("dc2", "web-3", "10.0.1.1", nil, check{CheckID: "_node_maintenance", Status: "critical"})}
+		}
+		return []serviceEntry{
+			instance("dc1", "web-2", "10.0.0.2", nil, check{CheckID: "serfHealth", Status: "passing"},
+				check{CheckID: "service:web-2", Status: "warning"}),
+			instance("dc1", "web-1", "10.0.0.1", map[string]string{"protocol": "http2", "version": "v1"},
+				check{CheckID: "serfHealth", Status: "passing"}),
+			instance("dc1", "web-4", "10.0.0.4", nil, check{CheckID: "service:web-4", Status: "critical"}),
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetch(t *testing.T) {
+	server := fakeConsul(t, atomic.NewUint64(1))
+	defer server.Close()
+	source := NewSource(&Options{Address: server.URL, Token: "token"})
+	configs, err := source.Fetch(context.Background())
+	assert.NoError(t, err)
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 ServiceEntry, got %d", len(configs))
+	}
+	assert.Equal(t, configs[0].Name, "consul-web.service.consul")
+	endpoint := func(address, locality, health string, weight uint32, labels map[string]string) *networking.WorkloadEntry {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[constants.EndpointHealthStatusLabel] = health
+		return &networking.WorkloadEntry{
+			Address:  address,
+			Ports:    map[string]uint32{"http2": 8080},
+			Labels:   labels,
+			Locality: locality,
+			Weight:   weight,
+		}
+	}
+	assert.Equal(t, configs[0].Spec.(*networking.ServiceEntry), &networking.ServiceEntry{
+		Hosts:      []string{"web.service.consul"},
+		Ports:      []*networking.ServicePort{{Number: 8080, Protocol: "HTTP2", Name: "http2"}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+		Endpoints: []*networking.WorkloadEntry{
+			endpoint("10.0.0.1", "dc1/z1", "healthy", 10, map[string]string{"protocol": "http2", "version": "v1"}),
+			endpoint("10.0.0.2", "dc1/z1", "healthy", 1, nil),
+			endpoint("10.0.0.4", "dc1/z1", "unhealthy", 1, nil),
+			endpoint("10.0.1.1", "dc2/z1", "draining", 1, nil),
+		},
+	})
+
+	source = NewSource(&Options{Address: server.URL, Token: "wrong"})
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Fatalf("expected error with wrong token")
+	}
+}
+
+func TestWait(t *testing.T) {
+	index := atomic.NewUint64(1)
+	server := fakeConsul(t, index)
+	defer server.Close()
+	source := NewSource(&Options{Address: server.URL, Datacenters: []string{"dc1"}, Token: "token"})
+	done := make(chan error, 1)
+	go func() {
+		done <- source.Wait(context.Background())
+	}()
+	// The fake does not block, so Wait keeps polling until the index changes.
+	select {
+	case <-done:
+		t.Fatalf("Wait returned without change")
+	case <-time.After(100 * time.Millisecond):
+	}
+	index.Store(2)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Wait did not return after a change")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := source.Wait(ctx); err == nil {
+		t.Fatalf("expected error with done context")
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eureka serves the applications registered in Eureka as ServiceEntries.
+package eureka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// hostSuffix is the suffix of the hostnames of the applications without hostname.
+	hostSuffix = "eureka"
+	// protocolMetadata is the metadata of the instances telling the protocol of the application, HTTP by default.
+	protocolMetadata = "protocol"
+
+	defaultRefreshInterval = 30 * time.Second
+	defaultPortName        = "http"
+
+	statusUp           = "UP"
+	statusOutOfService = "OUT_OF_SERVICE"
+)
+
+// Options are the settings of the Eureka registry, set as JSON by PILOT_EUREKA_REGISTRY.
+type Options struct {
+	// Servers are the service URLs of the Eureka servers, such as http://eureka.eureka:8761/eureka, tried in turn.
+	// The user info of the URLs authenticates the requests.
+	Servers []string `json:"servers"`
+	// Hosts are the hostnames of the applications keyed by application name, such as
+	// {"ORDER-SERVICE": "order.shop.svc.cluster.local"}. An application with the hostname of a Kubernetes service is
+	// put in its namespace, so its instances are endpoints of the Kubernetes service along with its pods, to migrate
+	// it to Kubernetes gradually. The other applications are <application>.eureka.
+	Hosts map[string]string `json:"hosts,omitempty"`
+	// PortName is the name of the port of the applications, http by default. It must be the name of the port of the
+	// Kubernetes services merged with the applications.
+	PortName string `json:"portName,omitempty"`
+	// RefreshInterval is how often the applications are fetched, 30s if unset, the interval of the Eureka clients.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// ParseOptions parses and validates the settings of the Eureka registry.
+func ParseOptions(value string) (*Options, error) {
+	opts := &Options{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(opts); err != nil {
+		return nil, fmt.Errorf("invalid eureka registry: %v", err)
+	}
+	if len(opts.Servers) == 0 {
+		return nil, fmt.Errorf("invalid eureka registry: servers is required")
+	}
+	for _, server := range opts.Servers {
+		u, err := url.Parse(server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid eureka registry: invalid server %q, must be an http or https URL", server)
+		}
+	}
+	for app, hostname := range opts.Hosts {
+		if err := validateHostname(hostname); err != nil {
+			return nil, fmt.Errorf("invalid eureka registry: invalid host of application %s: %v", app, err)
+		}
+	}
+	if opts.RefreshInterval != "" {
+		if d, err := time.ParseDuration(opts.RefreshInterval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid eureka registry: invalid refreshInterval %q", opts.RefreshInterval)
+		}
+	}
+	if opts.PortName == "" {
+		opts.PortName = defaultPortName
+	}
+	return opts, nil
+}
+
+func validateHostname(hostname string) error {
+	if hostname == "" || host.Name(hostname).IsWildCarded() {
+		return fmt.Errorf("%q is not a fully qualified hostname", hostname)
+	}
+	return nil
+}
+
+// Interval returns how often the applications are fetched.
+func (o *Options) Interval() time.Duration {
+	if d, err := time.ParseDuration(o.RefreshInterval); err == nil {
+		return d
+	}
+	return defaultRefreshInterval
+}
+
+type applicationsResponse struct {
+	Applications struct {
+		Application list[application] `json:"application"`
+	} `json:"applications"`
+}
+
+type application struct {
+	Name     string         `json:"name"`
+	Instance list[instance] `json:"instance"`
+}
+
+type instance struct {
+	InstanceID string            `json:"instanceId"`
+	IPAddr     string            `json:"ipAddr"`
+	Status     string            `json:"status"`
+	Port       port              `json:"port"`
+	SecurePort port              `json:"securePort"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+type port struct {
+	Number  uint32 `json:"$"`
+	Enabled string `json:"@enabled"`
+}
+
+// list is a list of the JSON of Eureka, which is a single object instead of an array when it has one element.
+type list[T any] []T
+
+func (l *list[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var one T
+		if err := json.Unmarshal(data, &one); err != nil {
+			return err
+		}
+		*l = list[T]{one}
+		return nil
+	}
+	var many []T
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// Source fetches the applications of Eureka with its REST API. It is read only, never registering in Eureka.
+type Source struct {
+	opts   *Options
+	client *http.Client
+}
+
+var _ external.Source = &Source{}
+
+// NewSource creates a Source fetching the applications of Eureka.
+func NewSource(opts *Options) *Source {
+	return &Source{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Provider returns the ID of the Eureka registry.
+func (s *Source) Provider() provider.ID {
+	return provider.Eureka
+}
+
+// Fetch returns a ServiceEntry for each application with instances. The instances UP are healthy endpoints, the ones
+// OUT_OF_SERVICE draining and the others unhealthy.
+func (s *Source) Fetch(ctx context.Context) ([]config.Config, error) {
+	resp := &applicationsResponse{}
+	var err error
+	for _, server := range s.opts.Servers {
+		if err = s.get(ctx, server, resp); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the applications: %v", err)
+	}
+	out := make([]config.Config, 0, len(resp.Applications.Application))
+	for _, app := range resp.Applications.Application {
+		if cfg, ok := s.buildServiceEntry(app); ok {
+			out = append(out, cfg)
+		}
+	}
+	return out, nil
+}
+
+func (s *Source) buildServiceEntry(app application) (config.Config, bool) {
+	instances := app.Instance
+	if len(instances) == 0 {
+		return config.Config{}, false
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceID < instances[j].InstanceID
+	})
+	hostname, namespace := s.hostname(app.Name)
+	proto := protocol.HTTP
+	if p := protocol.Parse(instances[0].Metadata[protocolMetadata]); p != protocol.Unsupported {
+		proto = p
+	}
+	se := &networking.ServiceEntry{
+		Hosts: []string{hostname},
+		Ports: []*networking.ServicePort{{
+			Number:   instancePort(instances[0]),
+			Protocol: string(proto),
+			Name:     s.opts.PortName,
+		}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	for _, in := range instances {
+		p := instancePort(in)
+		if in.IPAddr == "" || p == 0 {
+			continue
+		}
+		labels := external.EndpointLabels(in.Metadata)
+		switch in.Status {
+		case statusUp:
+			labels[constants.EndpointHealthStatusLabel] = "healthy"
+		case statusOutOfService:
+			labels[constants.EndpointHealthStatusLabel] = "draining"
+		default:
+			labels[constants.EndpointHealthStatusLabel] = "unhealthy"
+		}
+		se.Endpoints = append(se.Endpoints, &networking.WorkloadEntry{
+			Address: in.IPAddr,
+			Ports:   map[string]uint32{s.opts.PortName: p},
+			Labels:  labels,
+		})
+	}
+	return config.Config{
+		Meta: config.Meta{
+			Name:      external.ServiceEntryName(provider.Eureka, hostname),
+			Namespace: namespace,
+		},
+		Spec: se,
+	}, true
+}
+
+// hostname returns the hostname of an application, with the namespace of its ServiceEntry if the hostname is the one
+// of a Kubernetes service, <name>.<namespace>.svc.<domain>.
+func (s *Source) hostname(app string) (string, string) {
+	hostname, ok := s.opts.Hosts[app]
+	if !ok {
+		return external.DNSLabel(app) + "." + hostSuffix, ""
+	}
+	if parts := strings.Split(hostname, "."); len(parts) > 3 && parts[2] == "svc" {
+		return hostname, parts[1]
+	}
+	return hostname, ""
+}
+
+// instancePort returns the port of an instance, its secure port if only the secure port is enabled.
+func instancePort(in instance) uint32 {
+	if in.Port.Enabled == "false" && in.SecurePort.Enabled == "true" {
+		return in.SecurePort.Number
+	}
+	return in.Port.Number
+}
+
+func (s *Source) get(ctx context.Context, server string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+"/apps", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
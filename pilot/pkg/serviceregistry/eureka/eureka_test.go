@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions(`{"servers":["http://eureka:8761/eureka"],"hosts":{"ORDER":"order.shop.svc.cluster.local"}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, &Options{
+		Servers:  []string{"http://eureka:8761/eureka"},
+		Hosts:    map[string]string{"ORDER": "order.shop.svc.cluster.local"},
+		PortName: "http",
+	})
+	assert.Equal(t, opts.Interval(), defaultRefreshInterval)
+	opts, err = ParseOptions(`{"servers":["http://eureka:8761/eureka"],"portName":"http-web","refreshInterval":"5s"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts.PortName, "http-web")
+	assert.Equal(t, opts.Interval(), 5*time.Second)
+	for _, invalid := range []string{
+		`{}`,
+		`{"servers":["eureka:8761"]}`,
+		`{"servers":["http://eureka:8761/eureka"],"hosts":{"ORDER":"*.shop"}}`,
+		`{"servers":["http://eureka:8761/eureka"],"refreshInterval":"a"}`,
+		`{"servers":["http://eureka:8761/eureka"],"region":"a"}`,
+	} {
+		if _, err := ParseOptions(invalid); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+// apps are the applications as returned by Eureka, with a single instance as an object rather than an array.
+const apps = `{"applications": {"versions__delta": "1", "application": [
+  {"name": "ORDER", "instance": [
+    {"instanceId": "order-2", "ipAddr": "10.0.0.2", "status": "OUT_OF_SERVICE",
+     "port": {"$": 8080, "@enabled": "true"}, "securePort": {"$": 443, "@enabled": "false"}},
+    {"instanceId": "order-1", "ipAddr": "10.0.0.1", "status": "UP",
+     "port": {"$": 8080, "@enabled": "true"}, "securePort": {"$": 443, "@enabled": "false"},
+     "metadata": {"version": "v1", "management.port": "8081"}},
+    {"instanceId": "order-3", "ipAddr": "10.0.0.3", "status": "DOWN",
+     "port": {"$": 8080, "@enabled": "true"}, "securePort": {"$": 443, "@enabled": "false"}}
+  ]},
+  {"name": "PAYMENT", "instance":
+    {"instanceId": "payment-1", "ipAddr": "10.0.1.1", "status": "UP",
+     "port": {"$": 80, "@enabled": "false"}, "securePort": {"$": 8443, "@enabled": "true"},
+     "metadata": {"protocol": "https"}}
+  }
+]}}`
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eureka/apps" || r.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(apps))
+	}))
+	defer server.Close()
+	// The first server is down, so the second one is used.
+	opts, err := ParseOptions(`{"servers":["http://127.0.0.1:1/eureka","` + server.URL + `/eureka"],` +
+		`"hosts":{"ORDER":"order.shop.svc.cluster.local"}}`)
+	assert.NoError(t, err)
+	configs, err := NewSource(opts).Fetch(context.Background())
+	assert.NoError(t, err)
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 ServiceEntries, got %d", len(configs))
+	}
+
+	// The application with the hostname of a Kubernetes service is in its namespace.
+	assert.Equal(t, configs[0].Name, "eureka-order.shop.svc.cluster.local")
+	assert.Equal(t, configs[0].Namespace, "shop")
+	endpoint := func(address, health string, labels map[string]string) *networking.WorkloadEntry {
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[constants.EndpointHealthStatusLabel] = health
+		return &networking.WorkloadEntry{Address: address, Ports: map[string]uint32{"http": 8080}, Labels: labels}
+	}
+	assert.Equal(t, configs[0].Spec.(*networking.ServiceEntry), &networking.ServiceEntry{
+		Hosts:      []string{"order.shop.svc.cluster.local"},
+		Ports:      []*networking.ServicePort{{Number: 8080, Protocol: "HTTP", Name: "http"}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+		Endpoints: []*networking.WorkloadEntry{
+			endpoint("10.0.0.1", "healthy", map[string]string{"version": "v1", "management.port": "8081"}),
+			endpoint("10.0.0.2", "draining", nil),
+			endpoint("10.0.0.3", "unhealthy", nil),
+		},
+	})
+
+	assert.Equal(t, configs[1].Name, "eureka-payment.eureka")
+	assert.Equal(t, configs[1].Namespace, "")
+	assert.Equal(t, configs[1].Spec.(*networking.ServiceEntry), &networking.ServiceEntry{
+		Hosts:      []string{"payment.eureka"},
+		Ports:      []*networking.ServicePort{{Number: 8443, Protocol: "HTTPS", Name: "http"}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+		Endpoints: []*networking.WorkloadEntry{{
+			Address: "10.0.1.1",
+			Ports:   map[string]uint32{"http": 8443},
+			Labels:  map[string]string{"protocol": "https", constants.EndpointHealthStatusLabel: "healthy"},
+		}},
+	})
+}
@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package external syncs the services of the registries external to the mesh, such as Nacos, as ServiceEntries.
+package external
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
+)
+
+// RegistryLabel is the label of the ServiceEntries of an external registry, set to its provider.
+const RegistryLabel = "higress.io/registry"
+
+var log = istiolog.RegisterScope("external", "external service registries")
+
+// Source is an external service registry.
+type Source interface {
+	// Provider returns the ID of the registry.
+	Provider() provider.ID
+	// Fetch returns the ServiceEntries of all the services of the registry, with their endpoints.
+	Fetch(ctx context.Context) ([]config.Config, error)
+}
+
+// Watcher is a Source able to wait for the changes of its services, synced as soon as they change instead of only
+// periodically.
+type Watcher interface {
+	// Wait blocks until the services of the registry may have changed, or the context is done. It returns an error
+	// if the registry could not be watched.
+	Wait(ctx context.Context) error
+}
+
+// Controller periodically fetches the services of an external registry, and syncs them as ServiceEntries into a
+// config store of its own. Only the changed ServiceEntries are updated, so the ServiceEntry registry only pushes the
+// changed endpoints by EDS when the services are unchanged.
+type Controller struct {
+	source    Source
+	store     model.ConfigStoreController
+	namespace string
+	interval  time.Duration
+	synced    atomic.Bool
+}
+
+// NewController creates a Controller syncing the services of the source as ServiceEntries into the store, every
+// interval. The ServiceEntries fetched without namespace are put in the namespace.
+func NewController(source Source, store model.ConfigStoreController, namespace string, interval time.Duration) *Controller {
+	return &Controller{
+		source:    source,
+		store:     store,
+		namespace: namespace,
+		interval:  interval,
+	}
+}
+
+// HasSynced returns true once the services were fetched once, successfully or not, so an unreachable registry does
+// not block the readiness of pilot.
+func (c *Controller) HasSynced() bool {
+	return c.synced.Load()
+}
+
+// Run syncs the services until the stop channel is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	changed := make(chan struct{}, 1)
+	if watcher, ok := c.source.(Watcher); ok {
+		go c.watch(ctx, watcher, changed)
+	}
+	for {
+		if err := c.Sync(ctx); err != nil {
+			log.Warnf("failed to sync the services of the %s registry: %v", c.source.Provider(), err)
+		}
+		c.synced.Store(true)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// watch notifies the changes of the services of the watcher until the context is done, backing off for an interval
+// when the registry cannot be watched.
+func (c *Controller) watch(ctx context.Context, watcher Watcher, changed chan<- struct{}) {
+	for ctx.Err() == nil {
+		if err := watcher.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Debugf("failed to watch the services of the %s registry: %v", c.source.Provider(), err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.interval):
+			}
+			continue
+		}
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Sync fetches the services of the registry, and creates, updates or deletes their ServiceEntries.
+func (c *Controller) Sync(ctx context.Context) error {
+	configs, err := c.source.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	registry := c.source.Provider().String()
+	existing := map[types.NamespacedName]config.Config{}
+	for _, cfg := range c.store.List(gvk.ServiceEntry, metav1.NamespaceAll) {
+		if cfg.Labels[RegistryLabel] == registry {
+			existing[config.NamespacedName(cfg)] = cfg
+		}
+	}
+	var errs []string
+	for _, cfg := range configs {
+		cfg.GroupVersionKind = gvk.ServiceEntry
+		if cfg.Namespace == "" {
+			cfg.Namespace = c.namespace
+		}
+		if cfg.Labels == nil {
+			cfg.Labels = map[string]string{}
+		}
+		cfg.Labels[RegistryLabel] = registry
+		key := config.NamespacedName(cfg)
+		old, ok := existing[key]
+		delete(existing, key)
+		if !ok {
+			if _, err := c.store.Create(cfg); err != nil {
+				errs = append(errs, fmt.Sprintf("create %s: %v", key, err))
+			}
+			continue
+		}
+		if proto.Equal(old.Spec.(proto.Message), cfg.Spec.(proto.Message)) && maps.Equal(old.Labels, cfg.Labels) {
+			continue
+		}
+		cfg.ResourceVersion = old.ResourceVersion
+		if _, err := c.store.Update(cfg); err != nil {
+			errs = append(errs, fmt.Sprintf("update %s: %v", key, err))
+		}
+	}
+	for key := range existing {
+		if err := c.store.Delete(gvk.ServiceEntry, key.Name, key.Namespace, nil); err != nil {
+			errs = append(errs, fmt.Sprintf("delete %s: %v", key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sync %d ServiceEntries: %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ServiceEntryName returns the name of the ServiceEntry of a host of a registry, a valid DNS label derived from the
+// host.
+func ServiceEntryName(registry provider.ID, hostname string) string {
+	name := strings.ToLower(registry.String() + "-" + hostname)
+	name = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '.' {
+			return r
+		}
+		return '-'
+	}, name)
+	if len(name) > validation.DNS1123SubdomainMaxLength {
+		name = name[:validation.DNS1123SubdomainMaxLength]
+	}
+	return strings.Trim(name, "-.")
+}
+
+// EndpointLabels returns the metadata of an instance of a registry usable as labels of its endpoint.
+func EndpointLabels(metadata map[string]string) map[string]string {
+	labels := map[string]string{}
+	for k, v := range metadata {
+		if len(validation.IsQualifiedName(k)) == 0 && len(validation.IsValidLabelValue(v)) == 0 {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// DNSLabel returns a valid DNS label derived from a name of a registry, such as a group, to build the hostnames of
+// its services.
+func DNSLabel(name string) string {
+	label := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(name))
+	if len(label) > validation.DNS1123LabelMaxLength {
+		label = label[:validation.DNS1123LabelMaxLength]
+	}
+	return strings.Trim(label, "-")
+}
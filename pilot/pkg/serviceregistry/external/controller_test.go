@@ -0,0 +1,159 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package external
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test/util/assert"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+type fakeSource struct {
+	mu      sync.Mutex
+	configs []config.Config
+	err     error
+}
+
+func (f *fakeSource) Provider() provider.ID {
+	return provider.Nacos
+}
+
+func (f *fakeSource) Fetch(context.Context) ([]config.Config, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.configs, f.err
+}
+
+func serviceEntry(host, address string) config.Config {
+	return config.Config{
+		Meta: config.Meta{Name: ServiceEntryName(provider.Nacos, host)},
+		Spec: &networking.ServiceEntry{
+			Hosts:      []string{host},
+			Ports:      []*networking.ServicePort{{Number: 80, Protocol: "HTTP", Name: "http"}},
+			Resolution: networking.ServiceEntry_STATIC,
+			Endpoints:  []*networking.WorkloadEntry{{Address: address}},
+		},
+	}
+}
+
+func TestSync(t *testing.T) {
+	store := memory.NewController(memory.Make(collections.Pilot))
+	source := &fakeSource{}
+	c := NewController(source, store, "istio-system", 0)
+	// A ServiceEntry not of the registry is kept.
+	other := serviceEntry("other.example.com", "10.0.0.9")
+	other.GroupVersionKind = gvk.ServiceEntry
+	other.Namespace = "istio-system"
+	_, err := store.Create(other)
+	assert.NoError(t, err)
+
+	source.configs = []config.Config{serviceEntry("a.nacos", "10.0.0.1"), serviceEntry("b.nacos", "10.0.0.2")}
+	assert.NoError(t, c.Sync(context.Background()))
+	assert.Equal(t, len(store.List(gvk.ServiceEntry, "istio-system")), 3)
+	a := store.Get(gvk.ServiceEntry, "nacos-a.nacos", "istio-system")
+	assert.Equal(t, a.Labels[RegistryLabel], "Nacos")
+	version := a.ResourceVersion
+
+	// Unchanged ServiceEntries are not updated.
+	source.configs = []config.Config{serviceEntry("a.nacos", "10.0.0.1"), serviceEntry("b.nacos", "10.0.0.3")}
+	assert.NoError(t, c.Sync(context.Background()))
+	assert.Equal(t, store.Get(gvk.ServiceEntry, "nacos-a.nacos", "istio-system").ResourceVersion, version)
+	b := store.Get(gvk.ServiceEntry, "nacos-b.nacos", "istio-system")
+	assert.Equal(t, b.Spec.(*networking.ServiceEntry).Endpoints[0].Address, "10.0.0.3")
+
+	// Removed services are deleted.
+	source.configs = []config.Config{serviceEntry("b.nacos", "10.0.0.3")}
+	assert.NoError(t, c.Sync(context.Background()))
+	assert.Equal(t, store.Get(gvk.ServiceEntry, "nacos-a.nacos", "istio-system") == nil, true)
+	assert.Equal(t, store.Get(gvk.ServiceEntry, "nacos-other.example.com", "istio-system") != nil, true)
+	assert.Equal(t, len(store.List(gvk.ServiceEntry, "istio-system")), 2)
+
+	// The ServiceEntries are kept when the registry fails.
+	source.err = fmt.Errorf("unavailable")
+	assert.Equal(t, c.Sync(context.Background()) != nil, true)
+	assert.Equal(t, len(store.List(gvk.ServiceEntry, "istio-system")), 2)
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, ServiceEntryName(provider.Nacos, "Foo_Bar.group.public.nacos"), "nacos-foo-bar.group.public.nacos")
+	assert.Equal(t, len(ServiceEntryName(provider.Nacos, strings.Repeat("a", 300))), 253)
+	assert.Equal(t, DNSLabel("DEFAULT_GROUP"), "default-group")
+	assert.Equal(t, DNSLabel("_a."), "a")
+	assert.Equal(t, EndpointLabels(map[string]string{"version": "v1", "bad key": "a", "k": "bad value"}),
+		map[string]string{"version": "v1"})
+}
+
+type watchingSource struct {
+	fakeSource
+	changes chan struct{}
+}
+
+func (w *watchingSource) Wait(ctx context.Context) error {
+	select {
+	case <-w.changes:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestRunWatch(t *testing.T) {
+	store := memory.NewSyncController(memory.Make(collections.Pilot))
+	source := &watchingSource{changes: make(chan struct{})}
+	source.configs = []config.Config{serviceEntry("a.nacos", "10.0.0.1")}
+	c := NewController(source, store, "istio-system", time.Hour)
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.Run(stop)
+	retry.UntilOrFail(t, c.HasSynced)
+
+	// A change is synced without waiting for the interval.
+	source.mu.Lock()
+	source.configs = []config.Config{serviceEntry("a.nacos", "10.0.0.2")}
+	source.mu.Unlock()
+	source.changes <- struct{}{}
+	retry.UntilOrFail(t, func() bool {
+		se := store.Get(gvk.ServiceEntry, "nacos-a.nacos", "istio-system")
+		return se != nil && se.Spec.(*networking.ServiceEntry).Endpoints[0].Address == "10.0.0.2"
+	})
+}
+
+func TestSyncNamespaces(t *testing.T) {
+	store := memory.NewController(memory.Make(collections.Pilot))
+	source := &fakeSource{}
+	c := NewController(source, store, "istio-system", 0)
+	merged := serviceEntry("order.shop.svc.cluster.local", "10.0.0.1")
+	merged.Namespace = "shop"
+	source.configs = []config.Config{merged, serviceEntry("a.nacos", "10.0.0.2")}
+	assert.NoError(t, c.Sync(context.Background()))
+	assert.Equal(t, store.Get(gvk.ServiceEntry, "nacos-order.shop.svc.cluster.local", "shop") != nil, true)
+	assert.Equal(t, store.Get(gvk.ServiceEntry, "nacos-a.nacos", "istio-system") != nil, true)
+
+	source.configs = nil
+	assert.NoError(t, c.Sync(context.Background()))
+	assert.Equal(t, len(store.List(gvk.ServiceEntry, "")), 0)
+}
@@ -110,6 +110,9 @@ func ConvertService(svc corev1.Service, domainSuffix string, clusterID cluster.I
 			Labels:          svc.Labels,
 			ExportTo:        exportTo,
 			LabelSelectors:  svc.Spec.Selector,
+			// Added by ingress
+			BackendTLS: svc.Annotations[constants.BackendTLSAnnotation],
+			// End added by ingress
 		},
 	}
 
@@ -26,6 +26,7 @@ import (
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/spiffe"
@@ -268,6 +269,38 @@ func TestServiceConversionWithEmptyServiceAccountsAnnotation(t *testing.T) {
 	}
 }
 
+func TestServiceConversionWithBackendTLSAnnotation(t *testing.T) {
+	policy := `{"credentialName": "backend-ca", "hostname": "backend.example.com"}`
+	localSvc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "service1",
+			Namespace:   "default",
+			Annotations: map[string]string{constants.BackendTLSAnnotation: policy},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports: []corev1.ServicePort{{
+				Name:     "https",
+				Protocol: corev1.ProtocolTCP,
+				Port:     443,
+			}},
+		},
+	}
+
+	service := ConvertService(localSvc, domainSuffix, clusterID)
+	if service == nil {
+		t.Fatalf("could not convert service")
+	}
+	if service.Attributes.BackendTLS != policy {
+		t.Fatalf("got backend tls policy %q, want %q", service.Attributes.BackendTLS, policy)
+	}
+	other := ConvertService(*localSvc.DeepCopy(), domainSuffix, clusterID)
+	other.Attributes.BackendTLS = ""
+	if service.Attributes.Equals(&other.Attributes) {
+		t.Fatalf("services with different backend tls policies should not be equal")
+	}
+}
+
 func TestExternalServiceConversion(t *testing.T) {
 	serviceName := "service1"
 	namespace := "default"
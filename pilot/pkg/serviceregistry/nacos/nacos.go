@@ -0,0 +1,332 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nacos serves the services of the naming service of Nacos as ServiceEntries.
+package nacos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// DefaultGroup is the group of the services registered without group.
+	DefaultGroup = "DEFAULT_GROUP"
+	// publicNamespace is the name of the namespace of Nacos with an empty ID in the hostnames.
+	publicNamespace = "public"
+	// hostSuffix is the suffix of the hostnames of the services.
+	hostSuffix = "nacos"
+	// protocolMetadata is the metadata of the instances telling the protocol of the service, HTTP by default.
+	protocolMetadata = "protocol"
+
+	defaultRefreshInterval = 10 * time.Second
+	servicePageSize        = 500
+)
+
+// Options are the settings of the Nacos registry, set as JSON by PILOT_NACOS_REGISTRY.
+type Options struct {
+	// Servers are the base URLs of the Nacos servers, such as http://nacos.nacos:8848, tried in turn.
+	Servers []string `json:"servers"`
+	// NamespaceID is the ID of the namespace of Nacos of the services, the public namespace if empty.
+	NamespaceID string `json:"namespaceId,omitempty"`
+	// Groups are the groups of the services, DEFAULT_GROUP if empty.
+	Groups []string `json:"groups,omitempty"`
+	// Username and Password authenticate to Nacos if its authentication is enabled.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// RefreshInterval is how often the services are fetched, 10s if unset.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// ParseOptions parses and validates the settings of the Nacos registry.
+func ParseOptions(value string) (*Options, error) {
+	opts := &Options{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(opts); err != nil {
+		return nil, fmt.Errorf("invalid nacos registry: %v", err)
+	}
+	if len(opts.Servers) == 0 {
+		return nil, fmt.Errorf("invalid nacos registry: servers is required")
+	}
+	for _, server := range opts.Servers {
+		u, err := url.Parse(server)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid nacos registry: invalid server %q, must be an http or https URL", server)
+		}
+	}
+	if opts.RefreshInterval != "" {
+		if d, err := time.ParseDuration(opts.RefreshInterval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid nacos registry: invalid refreshInterval %q", opts.RefreshInterval)
+		}
+	}
+	if len(opts.Groups) == 0 {
+		opts.Groups = []string{DefaultGroup}
+	}
+	return opts, nil
+}
+
+// Interval returns how often the services are fetched.
+func (o *Options) Interval() time.Duration {
+	if d, err := time.ParseDuration(o.RefreshInterval); err == nil {
+		return d
+	}
+	return defaultRefreshInterval
+}
+
+type serviceList struct {
+	Count int      `json:"count"`
+	Doms  []string `json:"doms"`
+}
+
+type instanceList struct {
+	Hosts []instance `json:"hosts"`
+}
+
+type instance struct {
+	IP       string            `json:"ip"`
+	Port     uint32            `json:"port"`
+	Weight   float64           `json:"weight"`
+	Healthy  bool              `json:"healthy"`
+	Enabled  bool              `json:"enabled"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type loginResult struct {
+	AccessToken string `json:"accessToken"`
+	TokenTTL    int64  `json:"tokenTtl"`
+}
+
+// Source fetches the services of Nacos with its open API.
+type Source struct {
+	opts   *Options
+	client *http.Client
+
+	mu sync.Mutex
+	// server is the index of the server last answering.
+	server      int
+	token       string
+	tokenExpiry time.Time
+}
+
+var _ external.Source = &Source{}
+
+// NewSource creates a Source fetching the services of Nacos.
+func NewSource(opts *Options) *Source {
+	return &Source{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Provider returns the ID of the Nacos registry.
+func (s *Source) Provider() provider.ID {
+	return provider.Nacos
+}
+
+// Fetch returns a ServiceEntry for each service of the groups with instances, its hostname being
+// <service>.<group>.<namespace>.nacos. Only the healthy and enabled instances are endpoints.
+func (s *Source) Fetch(ctx context.Context) ([]config.Config, error) {
+	var out []config.Config
+	for _, group := range s.opts.Groups {
+		services, err := s.listServices(ctx, group)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			instances, err := s.listInstances(ctx, group, service)
+			if err != nil {
+				return nil, err
+			}
+			if cfg, ok := s.buildServiceEntry(group, service, instances); ok {
+				out = append(out, cfg)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Source) listServices(ctx context.Context, group string) ([]string, error) {
+	var services []string
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("pageNo", strconv.Itoa(page))
+		query.Set("pageSize", strconv.Itoa(servicePageSize))
+		query.Set("groupName", group)
+		query.Set("namespaceId", s.opts.NamespaceID)
+		list := &serviceList{}
+		if err := s.get(ctx, "/nacos/v1/ns/service/list", query, list); err != nil {
+			return nil, fmt.Errorf("failed to list the services of group %s: %v", group, err)
+		}
+		services = append(services, list.Doms...)
+		if len(list.Doms) < servicePageSize || len(services) >= list.Count {
+			return services, nil
+		}
+	}
+}
+
+func (s *Source) listInstances(ctx context.Context, group, service string) ([]instance, error) {
+	query := url.Values{}
+	query.Set("serviceName", service)
+	query.Set("groupName", group)
+	query.Set("namespaceId", s.opts.NamespaceID)
+	query.Set("healthyOnly", "false")
+	list := &instanceList{}
+	if err := s.get(ctx, "/nacos/v1/ns/instance/list", query, list); err != nil {
+		return nil, fmt.Errorf("failed to list the instances of service %s of group %s: %v", service, group, err)
+	}
+	return list.Hosts, nil
+}
+
+func (s *Source) buildServiceEntry(group, service string, instances []instance) (config.Config, bool) {
+	if len(instances) == 0 {
+		return config.Config{}, false
+	}
+	namespace := s.opts.NamespaceID
+	if namespace == "" {
+		namespace = publicNamespace
+	}
+	hostname := strings.Join([]string{
+		external.DNSLabel(service), external.DNSLabel(group), external.DNSLabel(namespace), hostSuffix,
+	}, ".")
+
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].IP != instances[j].IP {
+			return instances[i].IP < instances[j].IP
+		}
+		return instances[i].Port < instances[j].Port
+	})
+	proto := protocol.HTTP
+	if p := protocol.Parse(instances[0].Metadata[protocolMetadata]); p != protocol.Unsupported {
+		proto = p
+	}
+	portName := strings.ToLower(string(proto))
+	se := &networking.ServiceEntry{
+		Hosts:      []string{hostname},
+		Ports:      []*networking.ServicePort{{Number: instances[0].Port, Protocol: string(proto), Name: portName}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	for _, in := range instances {
+		if !in.Healthy || !in.Enabled || in.Weight <= 0 {
+			continue
+		}
+		se.Endpoints = append(se.Endpoints, &networking.WorkloadEntry{
+			Address: in.IP,
+			Ports:   map[string]uint32{portName: in.Port},
+			Labels:  external.EndpointLabels(in.Metadata),
+			// Nacos weights are decimals, 1 by default.
+			Weight: uint32(math.Round(in.Weight * 100)),
+		})
+	}
+	return config.Config{
+		Meta: config.Meta{
+			Name: external.ServiceEntryName(provider.Nacos, hostname),
+		},
+		Spec: se,
+	}, true
+}
+
+// get calls the open API of Nacos, trying each server in turn from the one last answering.
+func (s *Source) get(ctx context.Context, path string, query url.Values, out any) error {
+	s.mu.Lock()
+	first := s.server
+	s.mu.Unlock()
+	var lastErr error
+	for i := range s.opts.Servers {
+		server := (first + i) % len(s.opts.Servers)
+		err := s.getFrom(ctx, s.opts.Servers[server], path, query, out)
+		if err == nil {
+			s.mu.Lock()
+			s.server = server
+			s.mu.Unlock()
+			return nil
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+func (s *Source) getFrom(ctx context.Context, server, path string, query url.Values, out any) error {
+	if s.opts.Username != "" {
+		token, err := s.accessToken(ctx, server)
+		if err != nil {
+			return err
+		}
+		query.Set("accessToken", token)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req, out)
+}
+
+// accessToken logs in to Nacos, reusing the access token until it expires.
+func (s *Source) accessToken(ctx context.Context, server string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+	form := url.Values{}
+	form.Set("username", s.opts.Username)
+	form.Set("password", s.opts.Password)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(server, "/")+"/nacos/v1/auth/login",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	result := &loginResult{}
+	if err := s.do(req, result); err != nil {
+		return "", fmt.Errorf("failed to log in: %v", err)
+	}
+	s.token = result.AccessToken
+	// Renew the token before it expires.
+	s.tokenExpiry = time.Now().Add(time.Duration(result.TokenTTL) * time.Second * 9 / 10)
+	return s.token, nil
+}
+
+func (s *Source) do(req *http.Request, out any) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
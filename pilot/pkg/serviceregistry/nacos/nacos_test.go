@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nacos
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+func TestParseOptions(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *Options
+		err   bool
+	}{
+		{
+			name:  "defaults",
+			value: `{"servers":["http://nacos:8848"]}`,
+			want:  &Options{Servers: []string{"http://nacos:8848"}, Groups: []string{DefaultGroup}},
+		},
+		{
+			name:  "full",
+			value: `{"servers":["https://nacos:8848"],"namespaceId":"dev","groups":["a"],"username":"u","password":"p","refreshInterval":"5s"}`,
+			want: &Options{
+				Servers: []string{"https://nacos:8848"}, NamespaceID: "dev", Groups: []string{"a"},
+				Username: "u", Password: "p", RefreshInterval: "5s",
+			},
+		},
+		{name: "no servers", value: `{}`, err: true},
+		{name: "invalid server", value: `{"servers":["nacos:8848"]}`, err: true},
+		{name: "invalid interval", value: `{"servers":["http://nacos:8848"],"refreshInterval":"-1s"}`, err: true},
+		{name: "unknown field", value: `{"servers":["http://nacos:8848"],"cluster":"a"}`, err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOptions(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+	opts, _ := ParseOptions(`{"servers":["http://nacos:8848"]}`)
+	assert.Equal(t, opts.Interval(), defaultRefreshInterval)
+	opts, _ = ParseOptions(`{"servers":["http://nacos:8848"],"refreshInterval":"1m"}`)
+	assert.Equal(t, opts.Interval(), time.Minute)
+}
+
+func fakeNacos(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nacos/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("username") != "u" || r.FormValue("password") != "p" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(loginResult{AccessToken: "token", TokenTTL: 18000})
+	})
+	mux.HandleFunc("/nacos/v1/ns/service/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("accessToken") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		doms := []string{"providers", "empty"}
+		_ = json.NewEncoder(w).Encode(serviceList{Count: len(doms), Doms: doms})
+	})
+	mux.HandleFunc("/nacos/v1/ns/instance/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("healthyOnly") != "false" {
+			t.Errorf("unexpected healthyOnly %q", r.URL.Query().Get("healthyOnly"))
+		}
+		var hosts []instance
+		if r.URL.Query().Get("serviceName") == "providers" {
+			hosts = []instance{
+				{IP: "10.0.0.2", Port: 20880, Weight: 0.5, Healthy: true, Enabled: true},
+				{IP: "10.0.0.1", Port: 20880, Weight: 1, Healthy: true, Enabled: true, Metadata: map[string]string{
+					"protocol": "grpc", "version": "v1", "invalid key": "a",
+				}},
+				{IP: "10.0.0.3", Port: 20880, Weight: 1, Healthy: false, Enabled: true},
+				{IP: "10.0.0.4", Port: 20880, Weight: 1, Healthy: true, Enabled: false},
+				{IP: "10.0.0.5", Port: 20880, Weight: 0, Healthy: true, Enabled: true},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(instanceList{Hosts: hosts})
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetch(t *testing.T) {
+	server := fakeNacos(t)
+	defer server.Close()
+	// The first server is down, so the second one is used.
+	source := NewSource(&Options{
+		Servers:  []string{"http://127.0.0.1:1", server.URL},
+		Groups:   []string{DefaultGroup},
+		Username: "u",
+		Password: "p",
+	})
+	configs, err := source.Fetch(context.Background())
+	assert.NoError(t, err)
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 ServiceEntry, got %d", len(configs))
+	}
+	assert.Equal(t, configs[0].Name, "nacos-providers.default-group.public.nacos")
+	assert.Equal(t, configs[0].Spec.(*networking.ServiceEntry), &networking.ServiceEntry{
+		Hosts:      []string{"providers.default-group.public.nacos"},
+		Ports:      []*networking.ServicePort{{Number: 20880, Protocol: "GRPC", Name: "grpc"}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+		Endpoints: []*networking.WorkloadEntry{
+			{
+				Address: "10.0.0.1",
+				Ports:   map[string]uint32{"grpc": 20880},
+				Labels:  map[string]string{"protocol": "grpc", "version": "v1"},
+				Weight:  100,
+			},
+			{
+				Address: "10.0.0.2",
+				Ports:   map[string]uint32{"grpc": 20880},
+				Labels:  map[string]string{},
+				Weight:  50,
+			},
+		},
+	})
+	assert.Equal(t, source.server, 1)
+
+	source = NewSource(&Options{Servers: []string{server.URL}, Groups: []string{DefaultGroup}, Username: "u", Password: "wrong"})
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Fatalf("expected error with wrong credentials")
+	}
+}
@@ -24,6 +24,16 @@ const (
 	Kubernetes ID = "Kubernetes"
 	// External is a service registry for externally provided ServiceEntries
 	External ID = "External"
+	// Added by ingress
+	// Nacos is a service registry backed by the naming service of Nacos
+	Nacos ID = "Nacos"
+	// Consul is a service registry backed by the catalog of Consul
+	Consul ID = "Consul"
+	// Eureka is a read only service registry backed by the applications of Eureka
+	Eureka ID = "Eureka"
+	// Zookeeper is a service registry backed by the Dubbo providers registered in ZooKeeper
+	Zookeeper ID = "Zookeeper"
+	// End added by ingress
 )
 
 func (id ID) String() string {
@@ -30,6 +30,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/provider"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/workloadinstances"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
@@ -116,6 +117,11 @@ type Controller struct {
 	// Indicates whether this controller is for workload entries.
 	workloadEntryController bool
 
+	// Added by ingress
+	// endpointShards keeps the hashes of the shards of the endpoints of the ServiceEntries whose endpoints are sharded.
+	endpointShards map[types.NamespacedName][]uint64
+	// End added by ingress
+
 	model.NoopAmbientIndexes
 	model.NetworkGatewaysHandler
 }
@@ -178,6 +184,9 @@ func newController(store model.ConfigStore, xdsUpdater model.XDSUpdater, options
 			servicesBySE: map[types.NamespacedName][]*model.Service{},
 		},
 		edsQueue: queue.NewQueue(time.Second),
+		// Added by ingress
+		endpointShards: map[types.NamespacedName][]uint64{},
+		// End added by ingress
 	}
 	for _, o := range options {
 		o(s)
@@ -229,13 +238,20 @@ func (s *Controller) workloadEntryHandler(old, curr config.Config, event model.E
 		namespace: curr.Namespace,
 	}
 
+	// Modified by ingress
+	unhealthy := features.WorkloadEntryHealthChecks && !isHealthy(curr)
 	// If an entry is unhealthy, we will mark this as a delete instead
 	// This ensures we do not track unhealthy endpoints
-	if features.WorkloadEntryHealthChecks && !isHealthy(curr) {
+	// When pilot actively health checks the entries, they are kept as unhealthy endpoints instead.
+	if unhealthy && !alifeatures.WorkloadEntryActiveHealthChecks {
 		event = model.EventDelete
 	}
 
 	wi := s.convertWorkloadEntryToWorkloadInstance(curr, s.Cluster())
+	if wi != nil && unhealthy {
+		wi.Endpoint.HealthStatus = model.UnHealthy
+	}
+	// End modified by ingress
 	if wi != nil && !wi.DNSServiceEntryOnly {
 		// fire off the k8s handlers
 		s.NotifyWorkloadInstanceHandlers(wi, event)
@@ -282,6 +298,13 @@ func (s *Controller) workloadEntryHandler(old, curr config.Config, event model.E
 			continue
 		}
 		instance := s.convertWorkloadEntryToServiceInstances(wle, services, se, &key, s.Cluster())
+		// Added by ingress
+		if unhealthy {
+			for _, si := range instance {
+				si.Endpoint.HealthStatus = model.UnHealthy
+			}
+		}
+		// End added by ingress
 		instancesUpdated = append(instancesUpdated, instance...)
 		if event == model.EventDelete {
 			s.serviceInstances.deleteServiceEntryInstances(namespacedName, key)
@@ -385,20 +408,34 @@ func (s *Controller) serviceEntryHandler(_, curr config.Config, event model.Even
 		unchangedSvcs = cs
 	}
 
-	serviceInstancesByConfig, serviceInstances := s.buildServiceInstances(curr, cs)
-	oldInstances := s.serviceInstances.getServiceEntryInstances(key)
-	for configKey, old := range oldInstances {
-		s.serviceInstances.deleteInstanceKeys(configKey, old)
-	}
+	// Modified by ingress
+	var serviceInstances []*model.ServiceInstance
+	sharded := false
 	if event == model.EventDelete {
-		s.serviceInstances.deleteAllServiceEntryInstances(key)
+		delete(s.endpointShards, key)
 	} else {
-		// Update the indexes with new instances.
-		for ckey, value := range serviceInstancesByConfig {
-			s.serviceInstances.addInstances(ckey, value)
+		// Only the changed shards of the endpoints are updated if the services did not change.
+		incremental := event == model.EventUpdate && len(addedSvcs)+len(updatedSvcs)+len(deletedSvcs) == 0
+		serviceInstances, sharded = s.updateEndpointShards(key, curr, cs, incremental)
+	}
+	if !sharded {
+		var serviceInstancesByConfig map[configKey][]*model.ServiceInstance
+		serviceInstancesByConfig, serviceInstances = s.buildServiceInstances(curr, cs)
+		oldInstances := s.serviceInstances.getServiceEntryInstances(key)
+		for configKey, old := range oldInstances {
+			s.serviceInstances.deleteInstanceKeys(configKey, old)
+		}
+		if event == model.EventDelete {
+			s.serviceInstances.deleteAllServiceEntryInstances(key)
+		} else {
+			// Update the indexes with new instances.
+			for ckey, value := range serviceInstancesByConfig {
+				s.serviceInstances.addInstances(ckey, value)
+			}
+			s.serviceInstances.updateServiceEntryInstances(key, serviceInstancesByConfig)
 		}
-		s.serviceInstances.updateServiceEntryInstances(key, serviceInstancesByConfig)
 	}
+	// End modified by ingress
 
 	shard := model.ShardKeyFromRegistry(s)
 
@@ -23,10 +23,13 @@ import (
 	"time"
 
 	"istio.io/api/label"
+	"istio.io/api/meta/v1alpha1"
 	networking "istio.io/api/networking/v1alpha3"
 	"istio.io/istio/pilot/pkg/config/memory"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/status"
 	"istio.io/istio/pilot/pkg/serviceregistry/util/xdsfake"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/host"
@@ -2050,6 +2053,41 @@ func TestWorkloadEntryOnlyMode(t *testing.T) {
 	}
 }
 
+// Added by ingress
+func TestUnhealthyWorkloadEntryActiveHealthChecks(t *testing.T) {
+	test.SetForTest(t, &alifeatures.WorkloadEntryActiveHealthChecks, true)
+	store, sd := initServiceDiscoveryWithoutEvents(t)
+	stop := test.NewStop(t)
+	go sd.Run(stop)
+
+	wle := createWorkloadEntry("wl", selector.Name,
+		&networking.WorkloadEntry{
+			Address:        "2.2.2.2",
+			Labels:         map[string]string{"app": "wle"},
+			ServiceAccount: "default",
+		})
+	wle.Annotations = map[string]string{status.WorkloadEntryHealthCheckAnnotation: "true"}
+	wle.Status = &v1alpha1.IstioStatus{Conditions: []*v1alpha1.IstioCondition{{
+		Type:   status.ConditionHealthy,
+		Status: status.StatusFalse,
+	}}}
+	createConfigs([]*config.Config{selector, wle}, store, t)
+
+	svc := convertServices(*selector)[0]
+	retry.UntilSuccessOrFail(t, func() error {
+		instances := sd.InstancesByPort(svc, 444)
+		if len(instances) != 1 {
+			return fmt.Errorf("expected the unhealthy WorkloadEntry to be kept, got %d instances", len(instances))
+		}
+		if instances[0].Endpoint.HealthStatus != model.UnHealthy {
+			return fmt.Errorf("expected an unhealthy endpoint, got %v", instances[0].Endpoint.HealthStatus)
+		}
+		return nil
+	}, retry.Timeout(time.Second*5))
+}
+
+// End added by ingress
+
 func BenchmarkServiceEntryHandler(b *testing.B) {
 	_, sd := initServiceDiscoveryWithoutEvents(b)
 	stopCh := make(chan struct{})
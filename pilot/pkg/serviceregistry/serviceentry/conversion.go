@@ -203,8 +203,21 @@ func convertServices(cfg config.Config) []*model.Service {
 		}
 	}
 
-	return buildServices(hostAddresses, cfg.Name, cfg.Namespace, svcPorts, serviceEntry.Location, resolution,
+	// Modified by ingress
+	services := buildServices(hostAddresses, cfg.Name, cfg.Namespace, svcPorts, serviceEntry.Location, resolution,
 		exportTo, labelSelectors, serviceEntry.SubjectAltNames, creationTime, cfg.Labels)
+	if backendTLS, ok := cfg.Annotations[constants.BackendTLSAnnotation]; ok {
+		for _, svc := range services {
+			svc.Attributes.BackendTLS = backendTLS
+		}
+	}
+	if resolvers, ok := cfg.Annotations[constants.DNSResolversAnnotation]; ok {
+		for _, svc := range services {
+			svc.Attributes.DNSResolvers = resolvers
+		}
+	}
+	return services
+	// End modified by ingress
 }
 
 func buildServices(hostAddresses []*HostAddress, name, namespace string, ports model.PortList, location networking.ServiceEntry_Location,
@@ -238,6 +251,25 @@ func buildServices(hostAddresses []*HostAddress, name, namespace string, ports m
 	return out
 }
 
+// Added by ingress
+
+// endpointHealthStatus returns the health status of an endpoint of a ServiceEntry set by its health status label,
+// unset without the label.
+func endpointHealthStatus(labels map[string]string) model.HealthStatus {
+	switch labels[constants.EndpointHealthStatusLabel] {
+	case "healthy":
+		return model.Healthy
+	case "unhealthy":
+		return model.UnHealthy
+	case "draining":
+		return model.Draining
+	default:
+		return 0
+	}
+}
+
+// End added by ingress
+
 func ensureCanonicalServiceLabels(name string, srcLabels map[string]string) map[string]string {
 	if srcLabels == nil {
 		srcLabels = make(map[string]string)
@@ -295,6 +327,9 @@ func (s *Controller) convertEndpoint(service *model.Service, servicePort *networ
 			// After VM auto registry is introduced, workload group annotation should be used for workload name.
 			WorkloadName: configKey.name,
 			Namespace:    configKey.namespace,
+			// Added by ingress
+			HealthStatus: endpointHealthStatus(wle.Labels),
+			// End added by ingress
 		},
 		Service:     service,
 		ServicePort: convertPort(servicePort),
@@ -1267,3 +1267,22 @@ func jsonBytes(t testing.TB, v any) []byte {
 	}
 	return data
 }
+
+func TestEndpointHealthStatus(t *testing.T) {
+	cases := map[string]model.HealthStatus{
+		"":          0,
+		"healthy":   model.Healthy,
+		"unhealthy": model.UnHealthy,
+		"draining":  model.Draining,
+		"unknown":   0,
+	}
+	for value, want := range cases {
+		labels := map[string]string{"app": "a"}
+		if value != "" {
+			labels[constants.EndpointHealthStatusLabel] = value
+		}
+		if got := endpointHealthStatus(labels); got != want {
+			t.Errorf("%q: got %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"hash/fnv"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/types"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+)
+
+// splitEndpoints splits the endpoints of a STATIC ServiceEntry with more than shardSize endpoints into shards by the
+// hash of their address, so that an endpoint stays in the same shard as long as the number of shards is the same.
+// The number of shards is a power of two, changing only when the number of endpoints doubles or halves. It returns
+// nil if the endpoints of the ServiceEntry are not sharded.
+func splitEndpoints(se *networking.ServiceEntry, shardSize int) [][]*networking.WorkloadEntry {
+	if shardSize <= 0 || len(se.Endpoints) <= shardSize ||
+		se.Resolution != networking.ServiceEntry_STATIC || se.WorkloadSelector != nil {
+		return nil
+	}
+	count := 1
+	for count*shardSize < len(se.Endpoints) {
+		count *= 2
+	}
+	shards := make([][]*networking.WorkloadEntry, count)
+	for _, ep := range se.Endpoints {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(ep.Address))
+		i := h.Sum32() % uint32(count)
+		shards[i] = append(shards[i], ep)
+	}
+	return shards
+}
+
+// hashEndpoints returns the hash of the endpoints of a shard.
+func hashEndpoints(endpoints []*networking.WorkloadEntry) uint64 {
+	h := fnv.New64a()
+	marshal := proto.MarshalOptions{Deterministic: true}
+	for _, ep := range endpoints {
+		b, _ := marshal.Marshal(ep)
+		_, _ = h.Write(b)
+		// Separate the endpoints, so that moving bytes from an endpoint to the next changes the hash.
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// endpointShardKey returns the key of the instances of a shard of the endpoints of a ServiceEntry.
+func endpointShardKey(cfg config.Config, shard int) configKey {
+	return configKey{
+		kind:      serviceEntryConfigType,
+		name:      cfg.Name + "#" + strconv.Itoa(shard),
+		namespace: cfg.Namespace,
+	}
+}
+
+// updateEndpointShards updates the instances of a ServiceEntry whose endpoints are sharded. If incremental, only the
+// shards whose endpoints changed since the last update are converted and indexed again, the services of the
+// ServiceEntry having not changed. It returns the instances to update EDS for, the ones of the changed shards, and
+// false if the endpoints of the ServiceEntry are not sharded. It must be called with the mutex held.
+func (s *Controller) updateEndpointShards(key types.NamespacedName, curr config.Config, services []*model.Service,
+	incremental bool,
+) ([]*model.ServiceInstance, bool) {
+	shards := splitEndpoints(curr.Spec.(*networking.ServiceEntry), alifeatures.ServiceEntryEndpointShardSize)
+	hashes := s.endpointShards[key]
+	if shards == nil {
+		delete(s.endpointShards, key)
+		return nil, false
+	}
+	if len(hashes) != len(shards) {
+		incremental = false
+	}
+
+	oldInstances := s.serviceInstances.getServiceEntryInstances(key)
+	if !incremental {
+		for ckey, old := range oldInstances {
+			s.serviceInstances.deleteInstanceKeys(ckey, old)
+		}
+		s.serviceInstances.deleteAllServiceEntryInstances(key)
+	}
+	newHashes := make([]uint64, len(shards))
+	var changed []*model.ServiceInstance
+	for i, endpoints := range shards {
+		newHashes[i] = hashEndpoints(endpoints)
+		if incremental && newHashes[i] == hashes[i] {
+			continue
+		}
+		ckey := endpointShardKey(curr, i)
+		if incremental {
+			// The removed endpoints are updated as well.
+			old := oldInstances[ckey]
+			s.serviceInstances.deleteInstanceKeys(ckey, old)
+			changed = append(changed, old...)
+		}
+		instances := s.convertEndpointsToInstances(services, curr.Spec.(*networking.ServiceEntry), endpoints)
+		s.serviceInstances.addInstances(ckey, instances)
+		s.serviceInstances.updateServiceEntryInstancesPerConfig(key, ckey, instances)
+		changed = append(changed, instances...)
+	}
+	s.endpointShards[key] = newHashes
+	return changed, true
+}
+
+// convertEndpointsToInstances converts some of the endpoints of a ServiceEntry to instances.
+func (s *Controller) convertEndpointsToInstances(services []*model.Service, se *networking.ServiceEntry,
+	endpoints []*networking.WorkloadEntry,
+) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(services)*len(se.Ports)*len(endpoints))
+	for _, service := range services {
+		for _, port := range se.Ports {
+			for _, endpoint := range endpoints {
+				out = append(out, s.convertEndpoint(service, port, endpoint, &configKey{}, s.clusterID))
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"fmt"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/test"
+)
+
+func largeServiceEntry(endpoints int, version string) config.Config {
+	se := &networking.ServiceEntry{
+		Hosts:      []string{"large.example.com"},
+		Ports:      []*networking.ServicePort{{Number: 80, Name: "http", Protocol: "http"}},
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	for i := 0; i < endpoints; i++ {
+		se.Endpoints = append(se.Endpoints, &networking.WorkloadEntry{
+			Address: fmt.Sprintf("10.0.%d.%d", i/250, i%250),
+			Labels:  map[string]string{"version": "v1"},
+		})
+	}
+	if version != "" {
+		se.Endpoints[0].Labels = map[string]string{"version": version}
+	}
+	return config.Config{
+		Meta: config.Meta{GroupVersionKind: gvk.ServiceEntry, Name: "large", Namespace: "ns", CreationTimestamp: GlobalTime},
+		Spec: se,
+	}
+}
+
+func TestSplitEndpoints(t *testing.T) {
+	if shards := splitEndpoints(largeServiceEntry(10, "").Spec.(*networking.ServiceEntry), 10); shards != nil {
+		t.Fatalf("expected no shards up to the shard size, got %d", len(shards))
+	}
+	dns := largeServiceEntry(20, "").Spec.(*networking.ServiceEntry)
+	dns.Resolution = networking.ServiceEntry_DNS
+	if shards := splitEndpoints(dns, 10); shards != nil {
+		t.Fatalf("expected no shards for DNS resolution, got %d", len(shards))
+	}
+
+	se := largeServiceEntry(100, "").Spec.(*networking.ServiceEntry)
+	shards := splitEndpoints(se, 10)
+	if len(shards) != 16 {
+		t.Fatalf("expected 16 shards, got %d", len(shards))
+	}
+	total := 0
+	for _, shard := range shards {
+		total += len(shard)
+	}
+	if total != 100 {
+		t.Fatalf("expected the 100 endpoints in the shards, got %d", total)
+	}
+	// An endpoint stays in its shard while the number of shards is the same.
+	more := largeServiceEntry(110, "").Spec.(*networking.ServiceEntry)
+	moreShards := splitEndpoints(more, 10)
+	for i, shard := range shards {
+		for j, ep := range shard {
+			if moreShards[i][j].Address != ep.Address {
+				t.Fatalf("expected endpoint %s to stay in shard %d", ep.Address, i)
+			}
+		}
+	}
+}
+
+func TestEndpointShardsIncrementalUpdate(t *testing.T) {
+	test.SetForTest(t, &alifeatures.ServiceEntryEndpointShardSize, 10)
+	_, sd := initServiceDiscoveryWithoutEvents(t)
+	go sd.Run(test.NewStop(t))
+
+	instancesByAddress := func() map[string]*model.ServiceInstance {
+		sd.mutex.RLock()
+		defer sd.mutex.RUnlock()
+		out := map[string]*model.ServiceInstance{}
+		for _, instances := range sd.serviceInstances.getServiceEntryInstances(config.NamespacedName(largeServiceEntry(0, ""))) {
+			for _, i := range instances {
+				out[i.Endpoint.Address] = i
+			}
+		}
+		return out
+	}
+
+	initial := largeServiceEntry(100, "")
+	sd.serviceEntryHandler(config.Config{}, initial, model.EventAdd)
+	before := instancesByAddress()
+	if len(before) != 100 {
+		t.Fatalf("expected 100 instances, got %d", len(before))
+	}
+
+	updated := largeServiceEntry(100, "v2")
+	sd.serviceEntryHandler(initial, updated, model.EventUpdate)
+	after := instancesByAddress()
+	if len(after) != 100 {
+		t.Fatalf("expected 100 instances, got %d", len(after))
+	}
+	if after["10.0.0.0"].Endpoint.Labels["version"] != "v2" {
+		t.Fatalf("expected the updated endpoint, got %v", after["10.0.0.0"].Endpoint.Labels)
+	}
+	shards := splitEndpoints(updated.Spec.(*networking.ServiceEntry), 10)
+	changed := map[string]bool{}
+	for _, shard := range shards {
+		for _, ep := range shard {
+			if ep.Address == "10.0.0.0" {
+				for _, ep := range shard {
+					changed[ep.Address] = true
+				}
+			}
+		}
+	}
+	for address, i := range after {
+		if reused := before[address] == i; reused == changed[address] {
+			t.Fatalf("expected instance of %s reused %v, got %v", address, !changed[address], reused)
+		}
+	}
+
+	svc := convertServices(updated)[0]
+	if got := len(sd.InstancesByPort(svc, 80)); got != 100 {
+		t.Fatalf("expected 100 instances by port, got %d", got)
+	}
+
+	sd.serviceEntryHandler(config.Config{}, updated, model.EventDelete)
+	if got := len(instancesByAddress()); got != 0 {
+		t.Fatalf("expected no instances after the delete, got %d", got)
+	}
+	if len(sd.endpointShards) != 0 {
+		t.Fatalf("expected the shards to be forgotten, got %v", sd.endpointShards)
+	}
+}
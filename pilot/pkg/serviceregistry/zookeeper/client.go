@@ -0,0 +1,151 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-zookeeper/zk"
+
+	istiolog "istio.io/istio/pkg/log"
+)
+
+var log = istiolog.RegisterScope("zookeeper", "zookeeper service registry")
+
+// zkLogger logs the messages of the ZooKeeper client at debug level, as it logs every reconnection.
+type zkLogger struct{}
+
+func (zkLogger) Printf(format string, args ...any) {
+	log.Debugf(format, args...)
+}
+
+// client is the session of a Source with ZooKeeper, opened on first use and shared by its fetches and watches. The
+// session moves to the next server by itself when its server is lost, and requests fail when none of the servers
+// can be reached.
+type client struct {
+	servers []string
+
+	mu   sync.Mutex
+	conn *zk.Conn
+
+	// watches are the child watches set on the nodes by path, until they fire. A watch is set once per node, as
+	// ZooKeeper cannot remove them.
+	watches map[string]<-chan zk.Event
+}
+
+func (c *client) connect() (*zk.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		conn, _, err := zk.Connect(c.servers, sessionTimeout, zk.WithLogger(zkLogger{}))
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// children returns the children of the node of the path, or zk.ErrNoNode if it does not exist.
+func (c *client) children(path string) ([]string, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	children, _, err := conn.Children(path)
+	return children, err
+}
+
+// watch sets a child watch on the node of the path unless it has one, or an existence watch if it does not exist.
+func (c *client) watch(path string) error {
+	if _, f := c.watches[path]; f {
+		return nil
+	}
+	conn, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if c.watches == nil {
+		c.watches = map[string]<-chan zk.Event{}
+	}
+	for {
+		_, _, events, err := conn.ChildrenW(path)
+		if err == nil {
+			c.watches[path] = events
+			return nil
+		}
+		if !errors.Is(err, zk.ErrNoNode) {
+			return err
+		}
+		exists, _, events, err := conn.ExistsW(path)
+		if err != nil {
+			return err
+		}
+		// The node may have been created meanwhile, in which case its existence watch is a data watch.
+		if !exists {
+			c.watches[path] = events
+			return nil
+		}
+	}
+}
+
+// wait blocks until one of the watches fires, or the context is done. The watches lost with the session are all
+// set again by the next waits.
+func (c *client) wait(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	type fired struct {
+		path  string
+		event zk.Event
+	}
+	events := make(chan fired, len(c.watches))
+	firing := make(chan struct{}, 1)
+	var wg sync.WaitGroup
+	for path, watch := range c.watches {
+		wg.Add(1)
+		go func(path string, watch <-chan zk.Event) {
+			defer wg.Done()
+			select {
+			case event := <-watch:
+				events <- fired{path, event}
+				select {
+				case firing <- struct{}{}:
+				default:
+				}
+			case <-ctx.Done():
+			}
+		}(path, watch)
+	}
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-firing:
+	}
+	cancel()
+	// The watches firing meanwhile are consumed too, so they are set again.
+	wg.Wait()
+	close(events)
+	for f := range events {
+		delete(c.watches, f.path)
+		if f.event.Type == zk.EventNotWatching {
+			c.watches = nil
+			err = f.event.Err
+			break
+		}
+	}
+	return err
+}
@@ -0,0 +1,295 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zookeeper serves the Dubbo providers registered in ZooKeeper as ServiceEntries.
+package zookeeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/provider"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// hostSuffix is the suffix of the hostnames of the Dubbo interfaces.
+	hostSuffix = "dubbo"
+	// providersNode is the node of the providers of an interface.
+	providersNode = "providers"
+
+	defaultRoot            = "/dubbo"
+	defaultRefreshInterval = 30 * time.Second
+	sessionTimeout         = 10 * time.Second
+	// defaultWeight is the weight of the Dubbo providers without weight.
+	defaultWeight = 100
+)
+
+// The parameters of the URLs of the Dubbo providers mapped to their endpoints.
+const (
+	versionParam = "version"
+	groupParam   = "group"
+	appParam     = "application"
+	weightParam  = "weight"
+	enabledParam = "enabled"
+)
+
+// protocols are the protocols of the Dubbo providers, in their order of preference when an interface is exported with
+// several protocols.
+var protocols = []struct {
+	scheme   string
+	protocol protocol.Instance
+}{
+	{"tri", protocol.GRPC},
+	{"dubbo", protocol.TCP},
+	{"rest", protocol.HTTP},
+}
+
+// Options are the settings of the ZooKeeper registry, set as JSON by PILOT_ZOOKEEPER_REGISTRY.
+type Options struct {
+	// Servers are the addresses of the ZooKeeper servers, such as zookeeper.zookeeper:2181, tried in turn.
+	Servers []string `json:"servers"`
+	// Root is the root node of the Dubbo registry, /dubbo by default.
+	Root string `json:"root,omitempty"`
+	// Interfaces are the Dubbo interfaces synced, all if empty.
+	Interfaces []string `json:"interfaces,omitempty"`
+	// RefreshInterval is how often the providers are fetched, 30s if unset.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+}
+
+// ParseOptions parses and validates the settings of the ZooKeeper registry.
+func ParseOptions(value string) (*Options, error) {
+	opts := &Options{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(opts); err != nil {
+		return nil, fmt.Errorf("invalid zookeeper registry: %v", err)
+	}
+	if len(opts.Servers) == 0 {
+		return nil, fmt.Errorf("invalid zookeeper registry: servers is required")
+	}
+	for _, server := range opts.Servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			return nil, fmt.Errorf("invalid zookeeper registry: invalid server %q: %v", server, err)
+		}
+	}
+	if opts.Root == "" {
+		opts.Root = defaultRoot
+	}
+	if !strings.HasPrefix(opts.Root, "/") {
+		return nil, fmt.Errorf("invalid zookeeper registry: root %q must be absolute", opts.Root)
+	}
+	if opts.RefreshInterval != "" {
+		if d, err := time.ParseDuration(opts.RefreshInterval); err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid zookeeper registry: invalid refreshInterval %q", opts.RefreshInterval)
+		}
+	}
+	return opts, nil
+}
+
+// Interval returns how often the providers are fetched.
+func (o *Options) Interval() time.Duration {
+	if d, err := time.ParseDuration(o.RefreshInterval); err == nil {
+		return d
+	}
+	return defaultRefreshInterval
+}
+
+// Source fetches the Dubbo providers registered in ZooKeeper, and watches their changes.
+type Source struct {
+	opts   *Options
+	client *client
+}
+
+var (
+	_ external.Source  = &Source{}
+	_ external.Watcher = &Source{}
+)
+
+// NewSource creates a Source fetching the Dubbo providers of ZooKeeper.
+func NewSource(opts *Options) *Source {
+	return &Source{opts: opts, client: &client{servers: opts.Servers}}
+}
+
+// Provider returns the ID of the ZooKeeper registry.
+func (s *Source) Provider() provider.ID {
+	return provider.Zookeeper
+}
+
+// Fetch returns a ServiceEntry for each Dubbo interface with providers, its hostname being the interface name
+// lowercased with the .dubbo suffix, such as org.apache.dubbo.demo.demoservice.dubbo, so the interfaces are routed
+// by name. The version, group and application of the providers label their endpoints, to route by subsets.
+func (s *Source) Fetch(ctx context.Context) ([]config.Config, error) {
+	interfaces := s.opts.Interfaces
+	if len(interfaces) == 0 {
+		var err error
+		if interfaces, err = s.client.children(s.opts.Root); err != nil {
+			if errors.Is(err, zk.ErrNoNode) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to list the interfaces: %v", err)
+		}
+	}
+	sort.Strings(interfaces)
+	var out []config.Config
+	for _, iface := range interfaces {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		providers, err := s.client.children(path.Join(s.opts.Root, iface, providersNode))
+		if errors.Is(err, zk.ErrNoNode) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the providers of %s: %v", iface, err)
+		}
+		if cfg, ok := buildServiceEntry(iface, providers); ok {
+			out = append(out, cfg)
+		}
+	}
+	return out, nil
+}
+
+// Wait blocks until the interfaces or their providers change, watching the nodes listing them with the child
+// watches of ZooKeeper. The changes made while a fired watch is set again are synced by the periodic fetches.
+func (s *Source) Wait(ctx context.Context) error {
+	interfaces := s.opts.Interfaces
+	if len(interfaces) == 0 {
+		if err := s.client.watch(s.opts.Root); err != nil {
+			return err
+		}
+		var err error
+		if interfaces, err = s.client.children(s.opts.Root); err != nil && !errors.Is(err, zk.ErrNoNode) {
+			return err
+		}
+	}
+	for _, iface := range interfaces {
+		if err := s.client.watch(path.Join(s.opts.Root, iface, providersNode)); err != nil {
+			return err
+		}
+	}
+	return s.client.wait(ctx)
+}
+
+type dubboProvider struct {
+	scheme string
+	host   string
+	port   uint32
+	params url.Values
+}
+
+// parseProvider parses the URL of a Dubbo provider, the name of its node escaped.
+func parseProvider(node string) (*dubboProvider, bool) {
+	raw, err := url.QueryUnescape(node)
+	if err != nil {
+		return nil, false
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" {
+		return nil, false
+	}
+	port, err := strconv.ParseUint(u.Port(), 10, 32)
+	if err != nil || port == 0 {
+		return nil, false
+	}
+	return &dubboProvider{scheme: u.Scheme, host: u.Hostname(), port: uint32(port), params: u.Query()}, true
+}
+
+// HostName returns the hostname of a Dubbo interface.
+func HostName(iface string) string {
+	var labels []string
+	for _, label := range strings.Split(iface, ".") {
+		if label = external.DNSLabel(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(append(labels, hostSuffix), ".")
+}
+
+func buildServiceEntry(iface string, nodes []string) (config.Config, bool) {
+	byScheme := map[string][]*dubboProvider{}
+	for _, node := range nodes {
+		p, ok := parseProvider(node)
+		if !ok || p.params.Get(enabledParam) == "false" {
+			continue
+		}
+		byScheme[p.scheme] = append(byScheme[p.scheme], p)
+	}
+	var providers []*dubboProvider
+	proto := protocol.Unsupported
+	for _, candidate := range protocols {
+		if len(byScheme[candidate.scheme]) > 0 {
+			providers, proto = byScheme[candidate.scheme], candidate.protocol
+			break
+		}
+	}
+	if len(providers) == 0 {
+		return config.Config{}, false
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].host != providers[j].host {
+			return providers[i].host < providers[j].host
+		}
+		return providers[i].port < providers[j].port
+	})
+	hostname := HostName(iface)
+	portName := providers[0].scheme
+	se := &networking.ServiceEntry{
+		Hosts:      []string{hostname},
+		Ports:      []*networking.ServicePort{{Number: providers[0].port, Protocol: string(proto), Name: portName}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+	}
+	for _, p := range providers {
+		metadata := map[string]string{}
+		for _, param := range []string{versionParam, groupParam, appParam} {
+			if v := p.params.Get(param); v != "" {
+				metadata[param] = v
+			}
+		}
+		weight := uint32(defaultWeight)
+		if w, err := strconv.ParseUint(p.params.Get(weightParam), 10, 32); err == nil {
+			if w == 0 {
+				continue
+			}
+			weight = uint32(w)
+		}
+		se.Endpoints = append(se.Endpoints, &networking.WorkloadEntry{
+			Address: p.host,
+			Ports:   map[string]uint32{portName: p.port},
+			Labels:  external.EndpointLabels(metadata),
+			Weight:  weight,
+		})
+	}
+	return config.Config{
+		Meta: config.Meta{
+			Name: external.ServiceEntryName(provider.Zookeeper, hostname),
+		},
+		Spec: se,
+	}, true
+}
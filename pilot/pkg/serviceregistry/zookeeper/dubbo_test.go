@@ -0,0 +1,362 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/test/util/assert"
+)
+
+// The operations, errors and events of the protocol of ZooKeeper served by fakeZookeeper.
+const (
+	opExists       int32 = 3
+	opPing         int32 = 11
+	opGetChildren2 int32 = 12
+	opSetWatches   int32 = 101
+	opClose        int32 = -11
+
+	errNoNode int32 = -101
+
+	eventNodeCreated         int32 = 1
+	eventNodeChildrenChanged int32 = 4
+	stateConnected           int32 = 3
+)
+
+// fakeZookeeper serves the children of the nodes over the protocol of ZooKeeper, and notifies the watches of the
+// nodes whose children are set.
+type fakeZookeeper struct {
+	mu      sync.Mutex
+	nodes   map[string][]string
+	watches map[string][]*fakeSession
+}
+
+type fakeSession struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *fakeSession) write(w *encoder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(w.buf)))
+	_, err := s.conn.Write(append(buf, w.buf...))
+	return err
+}
+
+func newFakeZookeeper(t *testing.T, nodes map[string][]string) (*fakeZookeeper, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	z := &fakeZookeeper{nodes: nodes, watches: map[string][]*fakeSession{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go z.serve(conn)
+		}
+	}()
+	return z, l.Addr().String()
+}
+
+// setChildren sets the children of the node of the path, creating it if needed, and notifies its watches.
+func (z *fakeZookeeper) setChildren(path string, children []string) {
+	z.mu.Lock()
+	_, existed := z.nodes[path]
+	z.nodes[path] = children
+	sessions := z.watches[path]
+	delete(z.watches, path)
+	z.mu.Unlock()
+	event := eventNodeChildrenChanged
+	if !existed {
+		event = eventNodeCreated
+	}
+	for _, session := range sessions {
+		w := &encoder{}
+		w.int32(-1)
+		w.int64(1)
+		w.int32(0)
+		w.int32(event)
+		w.int32(stateConnected)
+		w.string(path)
+		_ = session.write(w)
+	}
+}
+
+func (z *fakeZookeeper) serve(conn net.Conn) {
+	defer conn.Close()
+	session := &fakeSession{conn: conn}
+	reader := bufio.NewReader(conn)
+	if _, err := readPacket(reader); err != nil {
+		return
+	}
+	w := &encoder{}
+	w.int32(0)
+	w.int32(10000)
+	w.int64(1)
+	w.bytes(make([]byte, 16))
+	if session.write(w) != nil {
+		return
+	}
+	for {
+		req, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		r := &decoder{buf: req}
+		xid, op := r.int32(), r.int32()
+		w := &encoder{}
+		w.int32(xid)
+		w.int64(1)
+		switch op {
+		case opGetChildren2, opExists:
+			path, watch := r.string(), r.bool()
+			z.mu.Lock()
+			children, ok := z.nodes[path]
+			if watch && (ok || op == opExists) {
+				z.watches[path] = append(z.watches[path], session)
+			}
+			z.mu.Unlock()
+			if !ok {
+				w.int32(errNoNode)
+				break
+			}
+			w.int32(0)
+			if op == opGetChildren2 {
+				w.int32(int32(len(children)))
+				for _, child := range children {
+					w.string(child)
+				}
+			}
+			// The stat of the node.
+			w.buf = append(w.buf, make([]byte, 68)...)
+		case opPing, opSetWatches:
+			w.int32(0)
+		case opClose:
+			w.int32(0)
+			_ = session.write(w)
+			return
+		}
+		if session.write(w) != nil {
+			return
+		}
+	}
+}
+
+func readPacket(reader *bufio.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(reader, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err := io.ReadFull(reader, buf)
+	return buf, err
+}
+
+// encoder encodes the records of the jute serialization of ZooKeeper.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.int32(int32(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) string(v string) {
+	e.bytes([]byte(v))
+}
+
+// decoder decodes the records of the jute serialization of ZooKeeper, ignoring truncated records.
+type decoder struct {
+	buf []byte
+}
+
+func (d *decoder) next(n int) []byte {
+	if n < 0 || len(d.buf) < n {
+		n = len(d.buf)
+	}
+	v := d.buf[:n]
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) int32() int32 {
+	if v := d.next(4); len(v) == 4 {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool {
+	v := d.next(1)
+	return len(v) == 1 && v[0] != 0
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.int32())))
+}
+
+func newTestSource(t *testing.T, opts *Options) *Source {
+	source := NewSource(opts)
+	t.Cleanup(func() {
+		if source.client.conn != nil {
+			source.client.conn.Close()
+		}
+	})
+	return source
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions(`{"servers":["zk:2181"]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, &Options{Servers: []string{"zk:2181"}, Root: "/dubbo"})
+	assert.Equal(t, opts.Interval(), defaultRefreshInterval)
+	opts, err = ParseOptions(`{"servers":["zk:2181"],"root":"/registry","interfaces":["a.B"],"refreshInterval":"1m"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, opts.Interval(), time.Minute)
+	for _, invalid := range []string{
+		`{}`,
+		`{"servers":["zk"]}`,
+		`{"servers":["zk:2181"],"root":"dubbo"}`,
+		`{"servers":["zk:2181"],"refreshInterval":"0"}`,
+		`{"servers":["zk:2181"],"namespace":"a"}`,
+	} {
+		if _, err := ParseOptions(invalid); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	provider := func(u string) string {
+		return url.QueryEscape(u)
+	}
+	_, address := newFakeZookeeper(t, map[string][]string{
+		"/dubbo": {"org.apache.dubbo.demo.DemoService", "org.apache.dubbo.demo.Empty", "org.apache.dubbo.demo.Consumed"},
+		"/dubbo/org.apache.dubbo.demo.DemoService/providers": {
+			provider("dubbo://10.0.0.2:20880/org.apache.dubbo.demo.DemoService?application=demo&version=2.0.0&weight=50"),
+			provider("dubbo://10.0.0.1:20880/org.apache.dubbo.demo.DemoService?application=demo&version=1.0.0&group=g1"),
+			provider("dubbo://10.0.0.3:20880/org.apache.dubbo.demo.DemoService?enabled=false"),
+			provider("dubbo://10.0.0.4:20880/org.apache.dubbo.demo.DemoService?weight=0"),
+			provider("rest://10.0.0.1:8080/org.apache.dubbo.demo.DemoService"),
+			"invalid",
+		},
+		"/dubbo/org.apache.dubbo.demo.Empty/providers": {},
+	})
+	// The first server is down, so the second one is used.
+	source := newTestSource(t, &Options{Servers: []string{"127.0.0.1:1", address}, Root: "/dubbo"})
+	configs, err := source.Fetch(context.Background())
+	assert.NoError(t, err)
+	if len(configs) != 1 {
+		t.Fatalf("expected 1 ServiceEntry, got %d", len(configs))
+	}
+	assert.Equal(t, configs[0].Name, "zookeeper-org.apache.dubbo.demo.demoservice.dubbo")
+	assert.Equal(t, configs[0].Spec.(*networking.ServiceEntry), &networking.ServiceEntry{
+		Hosts:      []string{"org.apache.dubbo.demo.demoservice.dubbo"},
+		Ports:      []*networking.ServicePort{{Number: 20880, Protocol: "TCP", Name: "dubbo"}},
+		Location:   networking.ServiceEntry_MESH_INTERNAL,
+		Resolution: networking.ServiceEntry_STATIC,
+		Endpoints: []*networking.WorkloadEntry{
+			{
+				Address: "10.0.0.1",
+				Ports:   map[string]uint32{"dubbo": 20880},
+				Labels:  map[string]string{"application": "demo", "version": "1.0.0", "group": "g1"},
+				Weight:  100,
+			},
+			{
+				Address: "10.0.0.2",
+				Ports:   map[string]uint32{"dubbo": 20880},
+				Labels:  map[string]string{"application": "demo", "version": "2.0.0"},
+				Weight:  50,
+			},
+		},
+	})
+
+	// The interfaces can be listed explicitly.
+	source = newTestSource(t, &Options{Servers: []string{address}, Root: "/dubbo", Interfaces: []string{"org.apache.dubbo.demo.Missing"}})
+	configs, err = source.Fetch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, len(configs), 0)
+}
+
+func TestWait(t *testing.T) {
+	provider := url.QueryEscape("dubbo://10.0.0.1:20880/org.apache.dubbo.demo.DemoService")
+	z, address := newFakeZookeeper(t, map[string][]string{
+		"/dubbo": {"org.apache.dubbo.demo.DemoService"},
+		"/dubbo/org.apache.dubbo.demo.DemoService/providers": {},
+	})
+	source := newTestSource(t, &Options{Servers: []string{address}, Root: "/dubbo"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// wait waits for the children of the node of the path to be set once it is watched.
+	wait := func(path string, children ...string) {
+		t.Helper()
+		done := make(chan error, 1)
+		go func() {
+			done <- source.Wait(ctx)
+		}()
+		for {
+			z.mu.Lock()
+			watched := len(z.watches[path]) > 0
+			z.mu.Unlock()
+			if watched {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		z.setChildren(path, children)
+		assert.NoError(t, <-done)
+	}
+
+	// A change of the providers of an interface is notified.
+	wait("/dubbo/org.apache.dubbo.demo.DemoService/providers", provider)
+	configs, err := source.Fetch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, len(configs), 1)
+
+	// So is a new interface, and the creation of the providers of an interface.
+	wait("/dubbo", "org.apache.dubbo.demo.DemoService", "org.apache.dubbo.demo.Other")
+	wait("/dubbo/org.apache.dubbo.demo.Other/providers", provider)
+	configs, err = source.Fetch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, len(configs), 2)
+}
+
+func TestHostName(t *testing.T) {
+	assert.Equal(t, HostName("org.apache.dubbo.demo.DemoService"), "org.apache.dubbo.demo.demoservice.dubbo")
+	assert.Equal(t, HostName("com.example.Outer$Inner"), "com.example.outer-inner.dubbo")
+}
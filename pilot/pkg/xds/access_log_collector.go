@@ -0,0 +1,284 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+)
+
+// maxAccessLogProxies bounds the number of proxies the access logs are kept of, the proxies which logged least
+// recently being evicted first.
+const maxAccessLogProxies = 1000
+
+// AccessLogEntry is the summary of an access log received from a proxy.
+type AccessLogEntry struct {
+	Time     time.Time `json:"time"`
+	LogName  string    `json:"logName,omitempty"`
+	Protocol string    `json:"protocol"`
+	// Method, Authority, Path and the response code are only set for HTTP.
+	Method              string `json:"method,omitempty"`
+	Authority           string `json:"authority,omitempty"`
+	Path                string `json:"path,omitempty"`
+	ResponseCode        uint32 `json:"responseCode,omitempty"`
+	ResponseCodeDetails string `json:"responseCodeDetails,omitempty"`
+	Duration            string `json:"duration,omitempty"`
+	Route               string `json:"route,omitempty"`
+	UpstreamCluster     string `json:"upstreamCluster,omitempty"`
+	UpstreamHost        string `json:"upstreamHost,omitempty"`
+	DownstreamRemote    string `json:"downstreamRemote,omitempty"`
+	RequestID           string `json:"requestId,omitempty"`
+	BytesReceived       uint64 `json:"bytesReceived,omitempty"`
+	BytesSent           uint64 `json:"bytesSent,omitempty"`
+}
+
+// AccessLogProxy is a proxy the access logs are kept of, as listed by /debug/accesslogz.
+type AccessLogProxy struct {
+	Proxy   string    `json:"proxy"`
+	Entries int       `json:"entries"`
+	Last    time.Time `json:"last"`
+}
+
+// accessLogRing keeps the last access logs of a proxy.
+type accessLogRing struct {
+	entries []AccessLogEntry
+	// next is the index of the oldest entry once the ring is full, which is overwritten next.
+	next int
+	last time.Time
+}
+
+func (r *accessLogRing) add(e AccessLogEntry, size int) {
+	if len(r.entries) < size {
+		r.entries = append(r.entries, e)
+	} else {
+		r.entries[r.next] = e
+		r.next = (r.next + 1) % size
+	}
+	r.last = time.Now()
+}
+
+// list returns the entries from the oldest to the newest.
+func (r *accessLogRing) list() []AccessLogEntry {
+	out := make([]AccessLogEntry, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// accessLogCollector is an Envoy gRPC access log service keeping the last access logs of each proxy streaming
+// to it in memory. It lets the access logs of the proxies, notably the gateways, be read through Istiod during
+// debugging sessions, without collecting them or exec'ing into the pods.
+type accessLogCollector struct {
+	size int
+
+	mu      sync.RWMutex
+	proxies map[string]*accessLogRing
+}
+
+var _ accesslog.AccessLogServiceServer = &accessLogCollector{}
+
+// newAccessLogCollector returns a collector keeping size access logs by proxy, or nil if size is not positive.
+func newAccessLogCollector(size int) *accessLogCollector {
+	if size <= 0 {
+		return nil
+	}
+	return &accessLogCollector{size: size, proxies: map[string]*accessLogRing{}}
+}
+
+// StreamAccessLogs receives the access logs of a proxy. Only the first message of the stream identifies the proxy.
+func (c *accessLogCollector) StreamAccessLogs(stream accesslog.AccessLogService_StreamAccessLogsServer) error {
+	var proxyID, logName string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if istiogrpc.IsExpectedGRPCError(err) {
+				return nil
+			}
+			return err
+		}
+		if id := msg.GetIdentifier(); id != nil {
+			proxyID = accessLogProxyID(id.GetNode().GetId())
+			logName = id.GetLogName()
+		}
+		if proxyID == "" {
+			return status.Error(codes.InvalidArgument, "the first message of the stream must identify the node")
+		}
+		var entries []AccessLogEntry
+		for _, e := range msg.GetHttpLogs().GetLogEntry() {
+			entries = append(entries, httpAccessLogEntry(logName, e))
+		}
+		for _, e := range msg.GetTcpLogs().GetLogEntry() {
+			entries = append(entries, tcpAccessLogEntry(logName, e))
+		}
+		c.record(proxyID, entries)
+	}
+}
+
+func (c *accessLogCollector) record(proxyID string, entries []AccessLogEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := c.proxies[proxyID]
+	if r == nil {
+		if len(c.proxies) >= maxAccessLogProxies {
+			c.evictLocked()
+		}
+		r = &accessLogRing{}
+		c.proxies[proxyID] = r
+	}
+	for _, e := range entries {
+		r.add(e, c.size)
+	}
+}
+
+// evictLocked drops the access logs of the proxy which logged least recently.
+func (c *accessLogCollector) evictLocked() {
+	var oldest string
+	var oldestTime time.Time
+	for proxyID, r := range c.proxies {
+		if oldest == "" || r.last.Before(oldestTime) {
+			oldest, oldestTime = proxyID, r.last
+		}
+	}
+	delete(c.proxies, oldest)
+}
+
+// entries returns the last access logs of the proxy, at most tail of them if positive.
+func (c *accessLogCollector) entries(proxyID string, tail int) []AccessLogEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r := c.proxies[proxyID]
+	if r == nil {
+		return []AccessLogEntry{}
+	}
+	out := r.list()
+	if tail > 0 && len(out) > tail {
+		out = out[len(out)-tail:]
+	}
+	return out
+}
+
+func (c *accessLogCollector) listProxies() []AccessLogProxy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]AccessLogProxy, 0, len(c.proxies))
+	for proxyID, r := range c.proxies {
+		out = append(out, AccessLogProxy{Proxy: proxyID, Entries: len(r.entries), Last: r.last})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Proxy < out[j].Proxy
+	})
+	return out
+}
+
+// accessLogProxyID returns the proxy ID, <pod>.<namespace>, of the node ID of a sidecar or gateway, such as
+// router~10.0.0.1~istio-ingressgateway-59585c5b9c-ndc59.istio-system~istio-system.svc.cluster.local, or the node
+// ID itself if it is not formatted so.
+func accessLogProxyID(nodeID string) string {
+	if parts := strings.Split(nodeID, "~"); len(parts) == 4 {
+		return parts[2]
+	}
+	return nodeID
+}
+
+func accessLogCommon(logName, protocol string, common *accesslogdata.AccessLogCommon) AccessLogEntry {
+	e := AccessLogEntry{
+		Time:             time.Now(),
+		LogName:          logName,
+		Protocol:         protocol,
+		Route:            common.GetRouteName(),
+		UpstreamCluster:  common.GetUpstreamCluster(),
+		UpstreamHost:     accessLogAddress(common.GetUpstreamRemoteAddress()),
+		DownstreamRemote: accessLogAddress(common.GetDownstreamRemoteAddress()),
+	}
+	if common.GetStartTime() != nil {
+		e.Time = common.GetStartTime().AsTime()
+	}
+	if common.GetDuration() != nil {
+		e.Duration = common.GetDuration().AsDuration().String()
+	}
+	return e
+}
+
+func httpAccessLogEntry(logName string, log *accesslogdata.HTTPAccessLogEntry) AccessLogEntry {
+	e := accessLogCommon(logName, "HTTP", log.GetCommonProperties())
+	req, resp := log.GetRequest(), log.GetResponse()
+	if req.GetRequestMethod() != core.RequestMethod_METHOD_UNSPECIFIED {
+		e.Method = req.GetRequestMethod().String()
+	}
+	e.Authority = req.GetAuthority()
+	e.Path = req.GetPath()
+	e.RequestID = req.GetRequestId()
+	e.BytesReceived = req.GetRequestBodyBytes()
+	e.ResponseCode = resp.GetResponseCode().GetValue()
+	e.ResponseCodeDetails = resp.GetResponseCodeDetails()
+	e.BytesSent = resp.GetResponseBodyBytes()
+	return e
+}
+
+func tcpAccessLogEntry(logName string, log *accesslogdata.TCPAccessLogEntry) AccessLogEntry {
+	e := accessLogCommon(logName, "TCP", log.GetCommonProperties())
+	e.BytesReceived = log.GetConnectionProperties().GetReceivedBytes()
+	e.BytesSent = log.GetConnectionProperties().GetSentBytes()
+	return e
+}
+
+func accessLogAddress(addr *core.Address) string {
+	sa := addr.GetSocketAddress()
+	if sa == nil {
+		return ""
+	}
+	return net.JoinHostPort(sa.GetAddress(), strconv.Itoa(int(sa.GetPortValue())))
+}
+
+// accessLogz lists the proxies the access logs are kept of, or with proxyID returns the last access logs of the
+// proxy, from the oldest to the newest, at most tail of them if set.
+// It is mapped to /debug/accesslogz
+func (s *DiscoveryServer) accessLogz(w http.ResponseWriter, req *http.Request) {
+	if s.accessLogs == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("the access log collector is disabled, set PILOT_ACCESS_LOG_COLLECTOR_BUFFER_SIZE to enable it\n"))
+		return
+	}
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		writeJSON(w, s.accessLogs.listProxies(), req)
+		return
+	}
+	tail := 0
+	if t := req.URL.Query().Get("tail"); t != "" {
+		var err error
+		if tail, err = strconv.Atoi(t); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("invalid tail: " + err.Error() + "\n"))
+			return
+		}
+	}
+	writeJSON(w, s.accessLogs.entries(proxyID, tail), req)
+}
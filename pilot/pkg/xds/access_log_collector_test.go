@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"io"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	accesslogdata "github.com/envoyproxy/go-control-plane/envoy/data/accesslog/v3"
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type fakeAccessLogStream struct {
+	grpc.ServerStream
+	msgs []*accesslog.StreamAccessLogsMessage
+}
+
+func (f *fakeAccessLogStream) Recv() (*accesslog.StreamAccessLogsMessage, error) {
+	if len(f.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := f.msgs[0]
+	f.msgs = f.msgs[1:]
+	return msg, nil
+}
+
+func (f *fakeAccessLogStream) SendAndClose(*accesslog.StreamAccessLogsResponse) error {
+	return nil
+}
+
+func TestAccessLogCollector(t *testing.T) {
+	if newAccessLogCollector(0) != nil {
+		t.Fatal("expected the collector to be disabled without buffer size")
+	}
+	c := newAccessLogCollector(2)
+	httpLogs := func(paths ...string) *accesslog.StreamAccessLogsMessage_HttpLogs {
+		logs := &accesslog.StreamAccessLogsMessage_HTTPAccessLogEntries{}
+		for _, path := range paths {
+			logs.LogEntry = append(logs.LogEntry, &accesslogdata.HTTPAccessLogEntry{
+				CommonProperties: &accesslogdata.AccessLogCommon{UpstreamCluster: "outbound|80||a.example.com"},
+				Request:          &accesslogdata.HTTPRequestProperties{RequestMethod: core.RequestMethod_GET, Path: path},
+				Response:         &accesslogdata.HTTPResponseProperties{ResponseCode: wrapperspb.UInt32(200)},
+			})
+		}
+		return &accesslog.StreamAccessLogsMessage_HttpLogs{HttpLogs: logs}
+	}
+	err := c.StreamAccessLogs(&fakeAccessLogStream{msgs: []*accesslog.StreamAccessLogsMessage{
+		{
+			Identifier: &accesslog.StreamAccessLogsMessage_Identifier{
+				Node:    &core.Node{Id: "router~10.0.0.1~gateway-a.istio-system~istio-system.svc.cluster.local"},
+				LogName: "envoy",
+			},
+			LogEntries: httpLogs("/1", "/2"),
+		},
+		{LogEntries: httpLogs("/3")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxies := c.listProxies()
+	if len(proxies) != 1 || proxies[0].Proxy != "gateway-a.istio-system" || proxies[0].Entries != 2 {
+		t.Fatalf("got proxies %+v, want gateway-a with 2 entries", proxies)
+	}
+	entries := c.entries("gateway-a.istio-system", 0)
+	if len(entries) != 2 || entries[0].Path != "/2" || entries[1].Path != "/3" {
+		t.Fatalf("got entries %+v, want the last 2 requests", entries)
+	}
+	if e := entries[1]; e.Method != "GET" || e.ResponseCode != 200 || e.LogName != "envoy" || e.Protocol != "HTTP" ||
+		e.UpstreamCluster != "outbound|80||a.example.com" {
+		t.Errorf("got entry %+v", e)
+	}
+	if tail := c.entries("gateway-a.istio-system", 1); len(tail) != 1 || tail[0].Path != "/3" {
+		t.Errorf("got tail %+v, want the last request", tail)
+	}
+
+	err = c.StreamAccessLogs(&fakeAccessLogStream{msgs: []*accesslog.StreamAccessLogsMessage{{LogEntries: httpLogs("/1")}}})
+	if err == nil {
+		t.Error("expected a stream without identifier to be rejected")
+	}
+}
@@ -15,6 +15,7 @@
 package xds
 
 import (
+	"context"
 	"strconv"
 	"strings"
 	"sync/atomic"
@@ -106,6 +107,26 @@ type Connection struct {
 
 	// errorChan is used to process error during discovery request processing.
 	errorChan chan error
+
+	// Added by Ingress
+	// knownVersions holds, per type, the versions of the resources a reconnecting delta client
+	// already has, as long as they match the state last ACKed to pilot. It is only accessed from
+	// the goroutine handling the stream.
+	knownVersions map[string]map[string]string
+	// lastSecrets is the last SDS response sent over SotW, and goodSecrets holds the last secrets ACKed
+	// by the proxy. They are only accessed from the goroutine handling the stream.
+	lastSecrets *sentSecrets
+	goodSecrets map[string]*discovery.Resource
+	// secretDeliveries tracks the delivery of the secrets, for the debug endpoints.
+	secretDeliveries *secretDeliveries
+	// pushSizes tracks the size of the pushes and the largest resources, for the debug endpoints.
+	pushSizes *pushSizes
+	// sentResources is the last response of each type sent over SotW, and ackedResources holds by type the
+	// last resources ACKed by the proxy. They are only tracked if the NACK quarantine is enabled, and only
+	// accessed from the goroutine handling the stream.
+	sentResources  map[string]*sentResponse
+	ackedResources map[string]map[string]*discovery.Resource
+	// End added by Ingress
 }
 
 // Event represents a config or registry event that results in a push.
@@ -127,6 +148,10 @@ func newConnection(peerAddr string, stream DiscoveryStream) *Connection {
 		peerAddr:    peerAddr,
 		connectedAt: time.Now(),
 		stream:      stream,
+		// Added by Ingress
+		secretDeliveries: &secretDeliveries{},
+		pushSizes:        &pushSizes{},
+		// End added by Ingress
 	}
 }
 
@@ -255,6 +280,11 @@ func (s *DiscoveryServer) Stream(stream DiscoveryStream) error {
 	// ip tables update latencies.
 	// See https://github.com/istio/istio/issues/25495.
 	if !s.IsServerReady() {
+		// Added by Ingress
+		if s.IsStandby() {
+			return s.streamStandby(stream)
+		}
+		// End added by Ingress
 		return status.Error(codes.Unavailable, "server is not ready to serve discovery information")
 	}
 
@@ -365,9 +395,28 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, request)
 		}
+		// Added by Ingress
+		if request.TypeUrl == v3.SecretType {
+			s.onSecretNack(con, request.ResponseNonce, request.ErrorDetail)
+		}
+		if s.onQuarantineNack(con, request) {
+			// Respond without the quarantined resources, so the proxy is not stuck on the rejection.
+			return true, emptyResourceDelta
+		}
+		// End added by Ingress
 		return false, emptyResourceDelta
 	}
 
+	// Added by Ingress
+	if request.TypeUrl == v3.SecretType && request.ResponseNonce != "" {
+		s.onSecretAck(con, request.ResponseNonce)
+	}
+	if request.ResponseNonce != "" {
+		s.onQuarantineAck(con, request.TypeUrl, request.ResponseNonce)
+		s.storeAckedResponse(con, request)
+	}
+	// End added by Ingress
+
 	if shouldUnsubscribe(request) {
 		log.Debugf("ADS:%s: UNSUBSCRIBE %s %s %s", stype, con.conID, request.VersionInfo, request.ResponseNonce)
 		con.proxy.Lock()
@@ -425,9 +474,16 @@ func (s *DiscoveryServer) shouldRespond(con *Connection, request *discovery.Disc
 
 	// If it comes here, that means nonce match.
 	con.proxy.Lock()
-	previousResources := con.proxy.WatchedResources[request.TypeUrl].ResourceNames
+	// Modified by Ingress
+	previousResources := subscribedResources(con.proxy.WatchedResources[request.TypeUrl])
 	con.proxy.WatchedResources[request.TypeUrl].NonceAcked = request.ResponseNonce
-	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = request.ResourceNames
+	updateSubscribedResources(con.proxy.WatchedResources[request.TypeUrl], request.ResourceNames)
+	// End modified by Ingress
+	// Added by Ingress
+	con.proxy.WatchedResources[request.TypeUrl].VersionAcked = request.VersionInfo
+	con.proxy.WatchedResources[request.TypeUrl].AckedAt = time.Now()
+	con.proxy.WatchedResources[request.TypeUrl].PendingResources = nil
+	// End added by Ingress
 	alwaysRespond := previousInfo.AlwaysRespond
 	previousInfo.AlwaysRespond = false
 	con.proxy.Unlock()
@@ -480,6 +536,11 @@ func isWildcardTypeURL(typeURL string) bool {
 	case v3.SecretType, v3.EndpointType, v3.RouteType, v3.ExtensionConfigurationType:
 		// By XDS spec, these are not wildcard
 		return false
+	// Added by ingress
+	case v3.VirtualHostType:
+		// The virtual hosts are requested on demand by name.
+		return false
+	// End added by ingress
 	case v3.ClusterType, v3.ListenerType:
 		// By XDS spec, these are wildcard
 		return true
@@ -522,6 +583,12 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 	if err != nil {
 		return err
 	}
+	// Added by Ingress
+	if err := s.generationLimiter.wait(con.streamContext(), node.Id); err != nil {
+		log.Warnf("ADS: %q %s exceeded generation rate limit: %v", con.peerAddr, node.Id, err)
+		return err
+	}
+	// End added by Ingress
 	// Check if proxy cluster has an alias configured, if yes use that as cluster ID for this proxy.
 	if alias, exists := s.ClusterAliases[proxy.Metadata.ClusterID]; exists {
 		proxy.Metadata.ClusterID = alias
@@ -532,6 +599,7 @@ func (s *DiscoveryServer) initConnection(node *core.Node, con *Connection, ident
 	// First request so initialize connection id and start tracking it.
 	con.conID = connectionID(proxy.ID)
 	con.node = node
+	proxy.DeltaXds = con.deltaStream != nil // Added by ingress
 	con.proxy = proxy
 
 	// Authorize xds clients
@@ -566,6 +634,9 @@ func (s *DiscoveryServer) closeConnection(con *Connection) {
 		return
 	}
 	s.removeCon(con.conID)
+	// Added by Ingress
+	s.clearSecretRejection(con.conID)
+	// End added by Ingress
 	if s.StatusGen != nil {
 		s.StatusGen.OnDisconnect(con)
 	}
@@ -743,17 +814,37 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 			// Only report for full versions, incremental pushes do not have a new version.
 			reportAllEvents(s.StatusReporter, con.conID, pushRequest.Push.LedgerVersion, nil)
 		}
+		// Added by Ingress
+		s.auditPushDone(con, pushRequest, proxySkipped)
+		// End added by Ingress
 		return nil
 	}
 
 	// Send pushes to all generators
 	// Each Generator is responsible for determining if the push event requires a push
 	wrl, ignoreEvents := con.pushDetails()
+	// Added by Ingress
+	wrl = s.pushScheduler.order(wrl)
+	ctx, span := startPushSpan(con, pushRequest)
+	// End added by Ingress
 	for _, w := range wrl {
-		if err := s.pushXds(con, w, pushRequest); err != nil {
+		// Added by Ingress
+		release := s.pushScheduler.acquire(w.TypeUrl)
+		genSpan := startGeneratorSpan(ctx, w.TypeUrl)
+		err := s.pushXds(con, w, pushRequest)
+		endSpan(genSpan, err)
+		release()
+		if err != nil {
+			endSpan(span, err)
+			s.auditPushDone(con, pushRequest, proxyFailed)
 			return err
 		}
+		// End added by Ingress
 	}
+	// Added by Ingress
+	endSpan(span, nil)
+	s.auditPushDone(con, pushRequest, proxyPushed)
+	// End added by Ingress
 	if pushRequest.Full {
 		// Report all events for unwatched resources. Watched resources will be reported in pushXds or on ack.
 		reportAllEvents(s.StatusReporter, con.conID, pushRequest.Push.LedgerVersion, ignoreEvents)
@@ -765,7 +856,7 @@ func (s *DiscoveryServer) pushConnection(con *Connection, pushEv *Event) error {
 
 // PushOrder defines the order that updates will be pushed in. Any types not listed here will be pushed in random
 // order after the types listed here
-var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.ScopedRouteType, v3.RouteType, v3.SecretType}
+var PushOrder = []string{v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.ScopedRouteType, v3.RouteType, v3.VirtualHostType, v3.SecretType}
 
 // KnownOrderedTypeUrls has typeUrls for which we know the order of push.
 var KnownOrderedTypeUrls = map[string]struct{}{
@@ -774,6 +865,7 @@ var KnownOrderedTypeUrls = map[string]struct{}{
 	v3.ListenerType: {},
 	// Added by ingress
 	v3.ScopedRouteType: {},
+	v3.VirtualHostType: {},
 	// End added by ingress
 	v3.RouteType:  {},
 	v3.SecretType: {},
@@ -869,9 +961,15 @@ func (s *DiscoveryServer) startPush(req *model.PushRequest) {
 		}
 	}
 	req.Start = time.Now()
-	for _, p := range s.AllClients() {
+	// Added by Ingress
+	cons := s.AllClients()
+	s.auditPushStart(req, cons)
+	// End added by Ingress
+	// Modified by Ingress
+	for _, p := range cons {
 		s.pushQueue.Enqueue(p, req)
 	}
+	// End modified by Ingress
 }
 
 func (s *DiscoveryServer) addCon(conID string, con *Connection) {
@@ -909,6 +1007,11 @@ func (conn *Connection) send(res *discovery.DiscoveryResponse) error {
 				conn.proxy.WatchedResources[res.TypeUrl] = &model.WatchedResource{TypeUrl: res.TypeUrl}
 			}
 			conn.proxy.WatchedResources[res.TypeUrl].NonceSent = res.Nonce
+			// Added by Ingress
+			conn.proxy.WatchedResources[res.TypeUrl].VersionSent = res.VersionInfo
+			conn.proxy.WatchedResources[res.TypeUrl].SentAt = time.Now()
+			conn.proxy.WatchedResources[res.TypeUrl].PendingResources = nil
+			// End added by Ingress
 			conn.proxy.Unlock()
 		}
 	} else if status.Convert(err).Code() == codes.DeadlineExceeded {
@@ -1010,3 +1113,15 @@ func orderWatchedResources(resources map[string]*model.WatchedResource) []*model
 func (conn *Connection) Stop() {
 	close(conn.stop)
 }
+
+// Added by Ingress
+
+// streamContext returns the context of the stream of the connection, either SotW or delta.
+func (conn *Connection) streamContext() context.Context {
+	if conn.deltaStream != nil {
+		return conn.deltaStream.Context()
+	}
+	return conn.stream.Context()
+}
+
+// End added by Ingress
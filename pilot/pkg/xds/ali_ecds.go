@@ -0,0 +1,159 @@
+package xds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/config/xds"
+	"istio.io/istio/pkg/util/sets"
+)
+
+var _ model.XdsDeltaResourceGenerator = &EcdsGenerator{}
+
+// GenerateDeltas returns the ECDS resources for a given proxy like Generate, along with the extension configs
+// orphaned since the last push, such as the ones of deleted WasmPlugins, as removed so the proxy drops them.
+func (e *EcdsGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
+	w *model.WatchedResource,
+) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	res, logs, orphaned, err := e.generate(proxy, w, req)
+	return res, orphaned, logs, true, err
+}
+
+// reconcileOrphanedExtensionConfigs moves the extension configs watched by the proxy which were not generated out of
+// its watched resource names into its orphaned resources, and restores the orphaned ones generated again. It returns
+// the newly orphaned names.
+func reconcileOrphanedExtensionConfigs(proxy *model.Proxy, w *model.WatchedResource, names []string,
+	resources model.Resources,
+) []string {
+	evaluated := sets.New(names...)
+	missing := evaluated.Copy()
+	for _, r := range resources {
+		missing.Delete(r.Name)
+	}
+
+	proxy.Lock()
+	defer proxy.Unlock()
+	// The watched resource may be a copy, filtered by a delta request.
+	if proxy.WatchedResources[w.TypeUrl] != w {
+		return nil
+	}
+	previous := sets.New(w.OrphanedResources...)
+	subscribed := sets.New(w.ResourceNames...).Union(previous)
+	// Names subscribed since the generation are left to the next push.
+	orphaned := previous.Difference(evaluated).Union(missing.Intersection(subscribed))
+	w.OrphanedResources = sets.SortedList(orphaned)
+	w.ResourceNames = sets.SortedList(subscribed.Difference(orphaned))
+	newlyOrphaned := sets.SortedList(orphaned.Difference(previous))
+	if len(newlyOrphaned) > 0 {
+		log.Infof("ECDS: %d orphaned extension configs of node:%s %v", len(newlyOrphaned), proxy.ID, newlyOrphaned)
+	}
+	return newlyOrphaned
+}
+
+// subscribedResources returns the names of the resources the proxy subscribes to, including the orphaned ones.
+func subscribedResources(w *model.WatchedResource) []string {
+	if len(w.OrphanedResources) == 0 {
+		return w.ResourceNames
+	}
+	return sets.SortedList(sets.New(w.ResourceNames...).InsertAll(w.OrphanedResources...))
+}
+
+// updateSubscribedResources sets the names of the resources the proxy subscribes to, keeping the orphaned ones apart
+// from the watched ones.
+func updateSubscribedResources(w *model.WatchedResource, names []string) {
+	if len(w.OrphanedResources) == 0 {
+		w.ResourceNames = names
+		return
+	}
+	subscribed := sets.New(names...)
+	orphaned := sets.New(w.OrphanedResources...).Intersection(subscribed)
+	w.OrphanedResources = sets.SortedList(orphaned)
+	w.ResourceNames = sets.SortedList(subscribed.Difference(orphaned))
+}
+
+func orphanedLogDetails(orphaned []string) model.XdsLogDetails {
+	if len(orphaned) == 0 {
+		return model.DefaultXdsLogDetails
+	}
+	return model.XdsLogDetails{AdditionalInfo: "orphaned:" + strconv.Itoa(len(orphaned))}
+}
+
+// authorizePullSecretReferences authorizes the WasmPlugins referencing an image pull secret of another namespace, such
+// as a central one, by asking whether the service accounts of their namespace may get the secret. It returns the
+// secret dependencies without the plugins denied, along with the resource names of the plugins denied.
+func authorizePullSecretReferences(controller credscontroller.Controller,
+	secretDeps map[string]sets.String,
+) (map[string]sets.String, sets.String) {
+	authorizer, _ := controller.(credscontroller.NamespaceSecretAuthorizer)
+	authorized := make(map[string]sets.String, len(secretDeps))
+	denied := sets.New[string]()
+	for rn, dependents := range secretDeps {
+		sr, err := parseSecretName(rn, "")
+		if err != nil {
+			authorized[rn] = dependents
+			continue
+		}
+		for _, name := range sets.SortedList(dependents) {
+			// The resource names of the WasmPlugins are namespace.name, and namespaces have no dots.
+			namespace, _, _ := strings.Cut(name, ".")
+			if namespace != sr.Namespace {
+				err = fmt.Errorf("cross namespace image pull secrets are not supported")
+				if authorizer != nil {
+					err = authorizer.AuthorizeNamespaceSecret(namespace, sr.Name, sr.Namespace)
+				}
+				if err != nil {
+					log.Warnf("WasmPlugin %s is not authorized to use image pull secret %s/%s: %v", name, sr.Namespace,
+						sr.Name, err)
+					denied.Insert(name)
+					continue
+				}
+			}
+			sets.InsertOrNew(authorized, rn, name)
+		}
+	}
+	return authorized, denied
+}
+
+// stripPullSecret clears the image pull secret inserted into the extension config of a WasmPlugin, which may have
+// been read for other plugins referencing the same secret.
+func stripPullSecret(ec *core.TypedExtensionConfig) {
+	updateWasmEnvs(ec, func(envs map[string]string) bool {
+		if envs[model.WasmSecretEnv] == "" {
+			return false
+		}
+		envs[model.WasmSecretEnv] = ""
+		return true
+	})
+}
+
+// updateWasmEnvs updates the environment variables of the VM of the extension config of a WasmPlugin, which is
+// re-marshaled if update returns true.
+func updateWasmEnvs(ec *core.TypedExtensionConfig, update func(envs map[string]string) bool) {
+	// The Wasm config may be wrapped by the matcher of the authentication state the plugin runs for.
+	if ec.GetTypedConfig().GetTypeUrl() == xds.ExtensionWithMatcherType {
+		m := &matching.ExtensionWithMatcher{}
+		if err := ec.GetTypedConfig().UnmarshalTo(m); err != nil || m.GetExtensionConfig() == nil {
+			return
+		}
+		updateWasmEnvs(m.ExtensionConfig, update)
+		ec.TypedConfig = protoconv.MessageToAny(m)
+		return
+	}
+	w := &wasm.Wasm{}
+	if err := ec.GetTypedConfig().UnmarshalTo(w); err != nil {
+		return
+	}
+	envs := w.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()
+	if envs == nil || !update(envs) {
+		return
+	}
+	ec.TypedConfig = protoconv.MessageToAny(w)
+}
@@ -0,0 +1,97 @@
+package xds
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	wasmv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type fakeNamespaceSecretAuthorizer struct {
+	credscontroller.Controller
+	allowed sets.String
+}
+
+func (f fakeNamespaceSecretAuthorizer) AuthorizeNamespaceSecret(namespace, secretName, secretNamespace string) error {
+	if !f.allowed.Contains(namespace + ":" + secretNamespace + "/" + secretName) {
+		return fmt.Errorf("%s may not get %s/%s", namespace, secretNamespace, secretName)
+	}
+	return nil
+}
+
+func TestAuthorizePullSecretReferences(t *testing.T) {
+	deps := map[string]sets.String{
+		"kubernetes://tenant-a/local":     sets.New("tenant-a.plugin"),
+		"kubernetes://central/registry":   sets.New("tenant-a.plugin-central", "tenant-b.plugin-central", "central.plugin"),
+		"kubernetes://central/restricted": sets.New("tenant-a.plugin-restricted"),
+	}
+	cases := []struct {
+		name       string
+		controller credscontroller.Controller
+		want       map[string]sets.String
+		wantDenied []string
+	}{
+		{
+			name:       "authorized",
+			controller: fakeNamespaceSecretAuthorizer{allowed: sets.New("tenant-a:central/registry")},
+			want: map[string]sets.String{
+				"kubernetes://tenant-a/local":   sets.New("tenant-a.plugin"),
+				"kubernetes://central/registry": sets.New("tenant-a.plugin-central", "central.plugin"),
+			},
+			wantDenied: []string{"tenant-a.plugin-restricted", "tenant-b.plugin-central"},
+		},
+		{
+			name:       "no authorizer",
+			controller: nil,
+			want: map[string]sets.String{
+				"kubernetes://tenant-a/local":   sets.New("tenant-a.plugin"),
+				"kubernetes://central/registry": sets.New("central.plugin"),
+			},
+			wantDenied: []string{"tenant-a.plugin-central", "tenant-a.plugin-restricted", "tenant-b.plugin-central"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, denied := authorizePullSecretReferences(tt.controller, deps)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got dependencies %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(sets.SortedList(denied), tt.wantDenied) {
+				t.Errorf("got denied %v, want %v", sets.SortedList(denied), tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestStripPullSecret(t *testing.T) {
+	ec := &core.TypedExtensionConfig{
+		Name: "tenant-b.plugin-central",
+		TypedConfig: protoconv.MessageToAny(&wasm.Wasm{
+			Config: &wasmv3.PluginConfig{
+				Vm: &wasmv3.PluginConfig_VmConfig{
+					VmConfig: &wasmv3.VmConfig{
+						EnvironmentVariables: &wasmv3.EnvironmentVariables{
+							KeyValues: map[string]string{model.WasmSecretEnv: "credential"},
+						},
+					},
+				},
+			},
+		}),
+	}
+	stripPullSecret(ec)
+	w := &wasm.Wasm{}
+	if err := ec.TypedConfig.UnmarshalTo(w); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()[model.WasmSecretEnv]; got != "" {
+		t.Errorf("got pull secret %q, want it stripped", got)
+	}
+}
@@ -36,7 +36,10 @@ var skippedCdsConfigs = sets.New[kind.Kind](
 	kind.RequestAuthentication,
 	kind.Secret,
 	kind.Telemetry,
-	kind.WasmPlugin,
+	// Modified by ingress
+	// WasmPlugins generate the clusters of their shared services.
+	// kind.WasmPlugin,
+	// End modified by ingress
 	kind.ProxyConfig,
 )
 
@@ -0,0 +1,266 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// Inconsistencies found by the consistency checker.
+const (
+	// ConsistencyNondeterministic resources are generated differently by two generations without caches.
+	ConsistencyNondeterministic = "nondeterministic"
+	// ConsistencyStale resources are generated differently through the xDS cache.
+	ConsistencyStale = "stale"
+)
+
+var (
+	inconsistencyTag = monitoring.CreateLabel("inconsistency")
+
+	consistencyCheckedProxies = monitoring.NewSum(
+		"pilot_consistency_check_proxies",
+		"Total number of proxies checked by the consistency checker.",
+	)
+
+	consistencyInconsistentProxies = monitoring.NewSum(
+		"pilot_consistency_check_inconsistent_proxies",
+		"Total number of proxies for which the consistency checker generated different resources.",
+	)
+
+	consistencyInconsistentResources = monitoring.NewSum(
+		"pilot_consistency_check_inconsistent_resources",
+		"Total number of resources generated differently by the consistency checker, by type and inconsistency.",
+	)
+
+	consistencyErrors = monitoring.NewSum(
+		"pilot_consistency_check_errors",
+		"Total number of proxies the consistency checker failed to generate the resources of.",
+	)
+)
+
+// ConsistencyResult is the check of the resources generated for a proxy.
+type ConsistencyResult struct {
+	Proxy string    `json:"proxy"`
+	Time  time.Time `json:"time"`
+	// Nondeterministic are the changes between two generations without caches.
+	Nondeterministic []DryRunDiff `json:"nondeterministic,omitempty"`
+	// Stale are the changes from a generation without caches to the generation through the xDS cache.
+	Stale []DryRunDiff `json:"stale,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// ConsistencyStatus is the outcome of the consistency checks, as served by /debug/consistencyz.
+type ConsistencyStatus struct {
+	Rounds       int       `json:"rounds"`
+	LastRound    time.Time `json:"lastRound,omitempty"`
+	Checked      int       `json:"checked"`
+	Inconsistent int       `json:"inconsistent"`
+	Failed       int       `json:"failed"`
+	// Results are the last results of the proxies found inconsistent, or which could not be checked.
+	Results []ConsistencyResult `json:"results,omitempty"`
+}
+
+// maxConsistencyResults bounds the number of results kept for /debug/consistencyz.
+const maxConsistencyResults = 100
+
+// consistencyChecker periodically generates the resources of a random sample of the connected proxies again,
+// twice without caches and once through the xDS cache, which serves the pushes. Resources generated differently
+// from the same push context are nondeterministic, such as those built iterating over maps, or stale, such as
+// cache entries built by racing pushes, and both make the proxies flap between versions on unrelated pushes.
+type consistencyChecker struct {
+	server     *DiscoveryServer
+	interval   time.Duration
+	sampleSize int
+	// generate generates the resources of the proxy of the connection through the given cache.
+	generate func(con *Connection, push *model.PushContext, cache model.XdsCache) (map[string]model.Resources, error)
+
+	mu     sync.RWMutex
+	status ConsistencyStatus
+}
+
+// newConsistencyChecker returns a checker checking sampleSize proxies every interval, or nil if interval is not
+// positive.
+func newConsistencyChecker(s *DiscoveryServer, interval time.Duration, sampleSize int) *consistencyChecker {
+	if interval <= 0 {
+		return nil
+	}
+	return &consistencyChecker{
+		server:     s,
+		interval:   interval,
+		sampleSize: sampleSize,
+		generate: func(con *Connection, push *model.PushContext, cache model.XdsCache) (map[string]model.Resources, error) {
+			return s.generateWithCache(con, s.Env, push, cache)
+		},
+	}
+}
+
+func (c *consistencyChecker) run(stop <-chan struct{}) {
+	log.Infof("checking the consistency of the xDS generated for %d proxies every %v", c.sampleSize, c.interval)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.server.IsServerReady() {
+				c.checkRound()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkRound checks a random sample of the connected proxies.
+func (c *consistencyChecker) checkRound() {
+	cons := c.server.Clients()
+	rand.Shuffle(len(cons), func(i, j int) {
+		cons[i], cons[j] = cons[j], cons[i]
+	})
+	if c.sampleSize > 0 && len(cons) > c.sampleSize {
+		cons = cons[:c.sampleSize]
+	}
+	var results []ConsistencyResult
+	checked, inconsistent, failed := 0, 0, 0
+	for _, con := range cons {
+		result, ok := c.check(con)
+		if !ok {
+			continue
+		}
+		checked++
+		switch {
+		case result.Error != "":
+			failed++
+			consistencyErrors.Increment()
+			log.Debugf("consistency check of %s failed: %v", result.Proxy, result.Error)
+		case len(result.Nondeterministic) > 0 || len(result.Stale) > 0:
+			inconsistent++
+			consistencyInconsistentProxies.Increment()
+			recordInconsistencies(ConsistencyNondeterministic, result.Nondeterministic)
+			recordInconsistencies(ConsistencyStale, result.Stale)
+			log.Warnf("consistency check: resources of %s generated differently: %s, see /debug/consistencyz",
+				result.Proxy, inconsistentTypes(result))
+		default:
+			continue
+		}
+		results = append(results, result)
+	}
+	consistencyCheckedProxies.RecordInt(int64(checked))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status.Rounds++
+	c.status.LastRound = time.Now()
+	c.status.Checked += checked
+	c.status.Inconsistent += inconsistent
+	c.status.Failed += failed
+	c.status.Results = append(c.status.Results, results...)
+	if len(c.status.Results) > maxConsistencyResults {
+		c.status.Results = c.status.Results[len(c.status.Results)-maxConsistencyResults:]
+	}
+}
+
+// check generates the resources of the proxy of the connection three times from the current push context. It
+// returns false if the push context changed meanwhile, as the generations are then not comparable.
+func (c *consistencyChecker) check(con *Connection) (ConsistencyResult, bool) {
+	result := ConsistencyResult{Proxy: con.proxy.ID, Time: time.Now()}
+	push := c.server.globalPushContext()
+	first, err := c.generate(con, push, model.DisabledCache{})
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+	second, err := c.generate(con, push, model.DisabledCache{})
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+	cached, err := c.generate(con, push, c.server.Cache)
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+	if c.server.globalPushContext() != push {
+		return result, false
+	}
+	result.Nondeterministic = diffGenerations(first, second)
+	result.Stale = diffGenerations(first, cached)
+	return result, true
+}
+
+// diffGenerations returns the changes from the resources of a generation to those of another, by type.
+func diffGenerations(a, b map[string]model.Resources) []DryRunDiff {
+	types := sets.New[string]()
+	for typeURL := range a {
+		types.Insert(typeURL)
+	}
+	for typeURL := range b {
+		types.Insert(typeURL)
+	}
+	var diffs []DryRunDiff
+	for _, typeURL := range sets.SortedList(types) {
+		if diff := diffResources(typeURL, a[typeURL], b[typeURL]); diff != nil {
+			diffs = append(diffs, *diff)
+		}
+	}
+	return diffs
+}
+
+func recordInconsistencies(inconsistency string, diffs []DryRunDiff) {
+	for _, d := range diffs {
+		n := len(d.Added) + len(d.Removed) + len(d.Modified)
+		consistencyInconsistentResources.With(typeTag.Value(v3.GetMetricType(d.TypeURL)),
+			inconsistencyTag.Value(inconsistency)).RecordInt(int64(n))
+	}
+}
+
+// inconsistentTypes returns the short types of the resources generated differently, for logging.
+func inconsistentTypes(result ConsistencyResult) []string {
+	types := sets.New[string]()
+	for _, d := range result.Nondeterministic {
+		types.Insert(ConsistencyNondeterministic + ":" + v3.GetShortType(d.TypeURL))
+	}
+	for _, d := range result.Stale {
+		types.Insert(ConsistencyStale + ":" + v3.GetShortType(d.TypeURL))
+	}
+	return sets.SortedList(types)
+}
+
+// consistencyz returns the outcome of the consistency checks.
+// It is mapped to /debug/consistencyz
+func (s *DiscoveryServer) consistencyz(w http.ResponseWriter, req *http.Request) {
+	if s.consistency == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("consistency checks are disabled, set PILOT_CONSISTENCY_CHECK_INTERVAL to enable them\n"))
+		return
+	}
+	s.consistency.mu.RLock()
+	status := s.consistency.status
+	s.consistency.mu.RUnlock()
+	results := append([]ConsistencyResult(nil), status.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.After(results[j].Time)
+	})
+	status.Results = results
+	writeJSON(w, status, req)
+}
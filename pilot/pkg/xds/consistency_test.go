@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestConsistencyCheck(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: shadowConfig("a", "b")})
+	s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	s.ConnectADS().WithType(v3.ListenerType).RequestResponseAck(t, nil)
+
+	if newConsistencyChecker(s.Discovery, 0, 1) != nil {
+		t.Fatal("expected the checker to be disabled without interval")
+	}
+	c := newConsistencyChecker(s.Discovery, time.Minute, 1)
+	c.checkRound()
+	if c.status.Rounds != 1 || c.status.Checked != 1 || c.status.Inconsistent != 0 || c.status.Failed != 0 {
+		t.Fatalf("expected a consistent proxy, got %+v", c.status)
+	}
+
+	// Every generation builds the cluster differently.
+	generations := 0
+	c = newConsistencyChecker(s.Discovery, time.Minute, 0)
+	c.generate = func(*Connection, *model.PushContext, model.XdsCache) (map[string]model.Resources, error) {
+		generations++
+		return map[string]model.Resources{v3.ClusterType: {&discovery.Resource{
+			Name:     "outbound|80||a.example.com",
+			Resource: protoconv.MessageToAny(&cluster.Cluster{Name: "outbound|80||a.example.com", AltStatName: string(rune('a' + generations))}),
+		}}}, nil
+	}
+	c.checkRound()
+	if c.status.Checked != 2 || c.status.Inconsistent != 2 || len(c.status.Results) != 2 {
+		t.Fatalf("expected inconsistent proxies, got %+v", c.status)
+	}
+	result := c.status.Results[0]
+	for _, diffs := range [][]DryRunDiff{result.Nondeterministic, result.Stale} {
+		if len(diffs) != 1 || diffs[0].TypeURL != v3.ClusterType ||
+			!reflect.DeepEqual([]string{diffs[0].Modified[0].Name}, []string{"outbound|80||a.example.com"}) {
+			t.Fatalf("expected the cluster to be modified, got %+v", diffs)
+		}
+	}
+}
@@ -43,6 +43,7 @@ import (
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cache"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/resource"
 	"istio.io/istio/pkg/config/xds"
@@ -176,6 +177,8 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	}
 
 	s.addDebugHandler(mux, internalMux, "/debug/ecdsz", "Status and debug interface for ECDS", s.ecdsz)
+	s.addDebugHandler(mux, internalMux, "/debug/wasm_refresh", "Forced refreshes of the modules of the WasmPlugins", s.wasmRefreshz)
+	s.addDebugHandler(mux, internalMux, "/debug/wasm_statsz", "Health of the WasmPlugins across the proxies reporting their Wasm stats", s.wasmStatsz)
 	s.addDebugHandler(mux, internalMux, "/debug/edsz", "Status and debug interface for EDS", s.Edsz)
 	s.addDebugHandler(mux, internalMux, "/debug/ndsz", "Status and debug interface for NDS", s.ndsz)
 	s.addDebugHandler(mux, internalMux, "/debug/adsz", "Status and debug interface for ADS", s.adsz)
@@ -183,6 +186,7 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 
 	s.addDebugHandler(mux, internalMux, "/debug/syncz", "Synchronization status of all Envoys connected to this Pilot instance", s.Syncz)
 	s.addDebugHandler(mux, internalMux, "/debug/config_distribution", "Version status of all Envoys connected to this Pilot instance", s.distributedVersions)
+	s.addDebugHandler(mux, internalMux, "/debug/proxy_status", "Last sent and ACKed versions per type of the connected proxies", s.proxyStatusz)
 
 	s.addDebugHandler(mux, internalMux, "/debug/registryz", "Debug support for registry", s.registryz)
 	s.addDebugHandler(mux, internalMux, "/debug/endpointz", "Debug support for endpoints", s.endpointz)
@@ -190,6 +194,19 @@ func (s *DiscoveryServer) AddDebugHandlers(mux, internalMux *http.ServeMux, enab
 	s.addDebugHandler(mux, internalMux, "/debug/cachez", "Info about the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?sizes=true", "Info about the size of the internal XDS caches", s.cachez)
 	s.addDebugHandler(mux, internalMux, "/debug/cachez?clear=true", "Clear the XDS caches", s.cachez)
+	s.addDebugHandler(mux, internalMux, "/debug/xds-cache", "Info about the ACKed XDS responses cached per proxy", s.xdsCachez)
+	s.addDebugHandler(mux, internalMux, "/debug/push_sizes", "Size of the last pushes to the connected proxies by type, with their largest resources", s.pushSizez)
+	s.addDebugHandler(mux, internalMux, "/debug/sds_rejects", "Secrets rejected by the connected proxies", s.sdsRejectsz)
+	s.addDebugHandler(mux, internalMux, "/debug/secretsz", "Secrets served to the connected proxies, with the result of their last delivery", s.secretsz)
+	s.addDebugHandler(mux, internalMux, "/debug/proxy_secrets", "Secrets requested by a proxy, with their source, private key provider and certificates", s.proxySecretsz)
+	s.addDebugHandler(mux, internalMux, "/debug/locality_priorities", "Priorities of the localities of the EDS clusters of a proxy", s.localityPriorities)
+	s.addDebugHandler(mux, internalMux, "/debug/outlierz", "Hosts ejected by the outlier detection of the gateways, by service", s.outlierz)
+	s.addDebugHandler(mux, internalMux, "/debug/quarantine", "Resources rejected by proxies, and whether they are quarantined", s.quarantinez)
+	s.addDebugHandler(mux, internalMux, "/debug/dryrun", "Diff of the xDS generated for a proxy with proposed configs", s.dryRunz)
+	s.addDebugHandler(mux, internalMux, "/debug/snapshot", "Tar archive of the xDS resources generated for a proxy", s.snapshotz)
+	s.addDebugHandler(mux, internalMux, "/debug/shadowz", "Divergences of the xDS generated for the proxies of the stable revision", s.shadowz)
+	s.addDebugHandler(mux, internalMux, "/debug/consistencyz", "Resources generated inconsistently for the connected proxies", s.consistencyz)
+	s.addDebugHandler(mux, internalMux, "/debug/accesslogz", "Last access logs streamed to the collector by the proxies", s.accessLogz)
 	s.addDebugHandler(mux, internalMux, "/debug/configz", "Debug support for config", s.configz)
 	s.addDebugHandler(mux, internalMux, "/debug/sidecarz", "Debug sidecar scope for a proxy", s.sidecarz)
 	s.addDebugHandler(mux, internalMux, "/debug/resourcesz", "Debug support for watched resources", s.resourcez)
@@ -320,6 +337,9 @@ func (s *DiscoveryServer) cachez(w http.ResponseWriter, req *http.Request) {
 	}
 	if req.Form.Get("clear") != "" {
 		s.Cache.ClearAll()
+		// Added by Ingress
+		s.clearPluginCaches(nil)
+		// End added by Ingress
 		_, _ = w.Write([]byte("Cache cleared\n"))
 		return
 	}
@@ -352,6 +372,33 @@ func (s *DiscoveryServer) cachez(w http.ResponseWriter, req *http.Request) {
 	writeJSON(w, resources, req)
 }
 
+// XdsCacheDebug is the response of the /debug/xds-cache endpoint.
+type XdsCacheDebug struct {
+	Stats   cache.Stats           `json:"stats"`
+	Entries []cache.XdsCacheEntry `json:"entries"`
+}
+
+// xdsCachez lists the responses held by the XdsResourceCache, optionally filtered by proxyID.
+// It is mapped to /debug/xds-cache
+func (s *DiscoveryServer) xdsCachez(w http.ResponseWriter, req *http.Request) {
+	if s.ResourceCache == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("xds resource cache is not enabled\n"))
+		return
+	}
+	entries := s.ResourceCache.Entries()
+	if proxyID := req.URL.Query().Get("proxyID"); proxyID != "" {
+		filtered := make([]cache.XdsCacheEntry, 0)
+		for _, e := range entries {
+			if e.NodeID == proxyID {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	writeJSON(w, XdsCacheDebug{Stats: s.ResourceCache.Stats(), Entries: entries}, req)
+}
+
 type endpointzResponse struct {
 	Service   string                   `json:"svc"`
 	Endpoints []*model.ServiceInstance `json:"ep"`
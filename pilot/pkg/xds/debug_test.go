@@ -21,12 +21,15 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/xds"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/cache"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 func TestSyncz(t *testing.T) {
@@ -232,3 +235,130 @@ func TestDebugHandlers(t *testing.T) {
 		t.Errorf("Error in generatating debug endpoint list")
 	}
 }
+
+func TestXdsCachez(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	internalMux := s.Discovery.InitDebug(http.NewServeMux(), false, nil)
+	get := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		internalMux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("/debug/xds-cache"); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without resource cache, got %v", rr.Code)
+	}
+
+	rc, err := cache.NewFileXdsResourceCache(t.TempDir(), cache.XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rc.Flush)
+	s.Discovery.ResourceCache = rc
+	for _, node := range []string{"a", "b"} {
+		if err := rc.Add(&discovery.DiscoveryResponse{TypeUrl: v3.ClusterType, Nonce: node}); err != nil {
+			t.Fatal(err)
+		}
+		if err := rc.Store(&discovery.DiscoveryRequest{Node: &core.Node{Id: node}, TypeUrl: v3.ClusterType, ResponseNonce: node}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := get("/debug/xds-cache?proxyID=b")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v", rr.Code)
+	}
+	got := xds.XdsCacheDebug{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Entries) != 1 || got.Entries[0].NodeID != "b" || got.Entries[0].TypeURL != v3.ClusterType {
+		t.Fatalf("unexpected entries %+v", got.Entries)
+	}
+	if got.Stats.Writes != 2 {
+		t.Fatalf("expected 2 writes, got %+v", got.Stats)
+	}
+}
+
+func TestProxyStatusz(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{})
+	internalMux := s.Discovery.InitDebug(http.NewServeMux(), false, nil)
+	ads := s.ConnectADS()
+	get := func(url string, out any) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		internalMux.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected response code %v: %s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The proxy is queried by its node ID.
+	getStatus := func() xds.ProxyConfigStatus {
+		t.Helper()
+		got := xds.ProxyConfigStatus{}
+		get("/debug/proxy_status?proxyID="+ads.ID, &got)
+		return got
+	}
+
+	req := &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType}
+	ads.Request(t, req)
+	resp := ads.ExpectResponse(t)
+	status := getStatus()
+	if status.UpToDate || len(status.Types) != 1 {
+		t.Fatalf("expected the proxy not to be up to date, got %+v", status)
+	}
+	cds := status.Types[0]
+	if cds.TypeURL != v3.ClusterType || cds.NonceSent != resp.Nonce || cds.VersionSent != resp.VersionInfo ||
+		cds.NonceAcked != "" || len(cds.Pending) != len(resp.Resources) {
+		t.Fatalf("unexpected status %+v", cds)
+	}
+
+	req.ResponseNonce = resp.Nonce
+	req.VersionInfo = resp.VersionInfo
+	ads.Request(t, req)
+	retry.UntilSuccessOrFail(t, func() error {
+		status := getStatus()
+		if !status.UpToDate {
+			return fmt.Errorf("expected the proxy to be up to date, got %+v", status)
+		}
+		if cds := status.Types[0]; cds.VersionAcked != resp.VersionInfo || len(cds.Pending) != 0 || cds.AckedAt == nil {
+			return fmt.Errorf("unexpected status %+v", cds)
+		}
+		return nil
+	})
+
+	// All the proxies are keyed by proxy ID, which the proxy is queried by as well.
+	all := map[string]xds.ProxyConfigStatus{}
+	get("/debug/proxy_status", &all)
+	if len(all) != 1 {
+		t.Fatalf("expected the status of the proxy, got %+v", all)
+	}
+	for proxyID, status := range all {
+		got := xds.ProxyConfigStatus{}
+		get("/debug/proxy_status?proxyID="+proxyID, &got)
+		if !got.UpToDate || !status.UpToDate || len(got.Types) != 1 || got.Types[0].VersionAcked != resp.VersionInfo {
+			t.Fatalf("unexpected status %+v of proxy %s", got, proxyID)
+		}
+	}
+
+	httpReq, err := http.NewRequest(http.MethodGet, "/debug/proxy_status?proxyID=unknown", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	internalMux.ServeHTTP(rr, httpReq)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown proxy, got %v", rr.Code)
+	}
+}
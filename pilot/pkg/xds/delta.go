@@ -165,17 +165,37 @@ func (s *DiscoveryServer) pushConnectionDelta(con *Connection, pushEv *Event) er
 			// Only report for full versions, incremental pushes do not have a new version
 			reportAllEvents(s.StatusReporter, con.conID, pushRequest.Push.LedgerVersion, nil)
 		}
+		// Added by Ingress
+		s.auditPushDone(con, pushRequest, proxySkipped)
+		// End added by Ingress
 		return nil
 	}
 
 	// Send pushes to all generators
 	// Each Generator is responsible for determining if the push event requires a push
 	wrl, ignoreEvents := con.pushDetails()
+	// Added by Ingress
+	wrl = s.pushScheduler.order(wrl)
+	ctx, span := startPushSpan(con, pushRequest)
+	// End added by Ingress
 	for _, w := range wrl {
-		if err := s.pushDeltaXds(con, w, pushRequest); err != nil {
+		// Added by Ingress
+		release := s.pushScheduler.acquire(w.TypeUrl)
+		genSpan := startGeneratorSpan(ctx, w.TypeUrl)
+		err := s.pushDeltaXds(con, w, pushRequest)
+		endSpan(genSpan, err)
+		release()
+		if err != nil {
+			endSpan(span, err)
+			s.auditPushDone(con, pushRequest, proxyFailed)
 			return err
 		}
+		// End added by Ingress
 	}
+	// Added by Ingress
+	endSpan(span, nil)
+	s.auditPushDone(con, pushRequest, proxyPushed)
+	// End added by Ingress
 	if pushRequest.Full {
 		// Report all events for unwatched resources. Watched resources will be reported in pushXds or on ack.
 		reportAllEvents(s.StatusReporter, con.conID, pushRequest.Push.LedgerVersion, ignoreEvents)
@@ -252,6 +272,11 @@ func (conn *Connection) sendDelta(res *discovery.DeltaDiscoveryResponse) error {
 				conn.proxy.WatchedResources[res.TypeUrl] = &model.WatchedResource{TypeUrl: res.TypeUrl}
 			}
 			conn.proxy.WatchedResources[res.TypeUrl].NonceSent = res.Nonce
+			// Added by Ingress
+			conn.proxy.WatchedResources[res.TypeUrl].VersionSent = res.SystemVersionInfo
+			conn.proxy.WatchedResources[res.TypeUrl].SentAt = time.Now()
+			conn.proxy.WatchedResources[res.TypeUrl].PendingResources = deltaResourceNames(res)
+			// End added by Ingress
 			if features.EnableUnsafeDeltaTest {
 				conn.proxy.WatchedResources[res.TypeUrl].LastResources = applyDelta(conn.proxy.WatchedResources[res.TypeUrl].LastResources, res)
 			}
@@ -280,10 +305,16 @@ func (s *DiscoveryServer) processDeltaRequest(req *discovery.DeltaDiscoveryReque
 	if s.StatusReporter != nil {
 		s.StatusReporter.RegisterEvent(con.conID, req.TypeUrl, req.ResponseNonce)
 	}
+	// Added by Ingress
+	s.storeDeltaAck(con, req)
+	// End added by Ingress
 	shouldRespond := s.shouldRespondDelta(con, req)
 	if !shouldRespond {
 		return nil
 	}
+	// Added by Ingress
+	s.recordKnownVersions(con, req)
+	// End added by Ingress
 
 	subs := sets.New(req.ResourceNamesSubscribe...).Delete("*")
 	// InitialResourceVersions are essential subscriptions on the first request, since we don't care about the version
@@ -329,6 +360,11 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 		if s.StatusGen != nil {
 			s.StatusGen.OnNack(con.proxy, deltaToSotwRequest(request))
 		}
+		// Added by Ingress
+		if request.TypeUrl == v3.SecretType {
+			s.onSecretNack(con, request.ResponseNonce, request.ErrorDetail)
+		}
+		// End added by Ingress
 		return false
 	}
 
@@ -388,10 +424,23 @@ func (s *DiscoveryServer) shouldRespondDelta(con *Connection, request *discovery
 	// If it comes here, that means nonce match. This an ACK. We should record
 	// the ack details and respond if there is a change in resource names.
 	con.proxy.Lock()
-	previousResources := con.proxy.WatchedResources[request.TypeUrl].ResourceNames
+	// Modified by Ingress
+	previousResources := subscribedResources(con.proxy.WatchedResources[request.TypeUrl])
 	deltaResources, _ := deltaWatchedResources(previousResources, request)
 	con.proxy.WatchedResources[request.TypeUrl].NonceAcked = request.ResponseNonce
-	con.proxy.WatchedResources[request.TypeUrl].ResourceNames = deltaResources
+	updateSubscribedResources(con.proxy.WatchedResources[request.TypeUrl], deltaResources)
+	// End modified by Ingress
+	// Added by Ingress
+	if request.ResponseNonce != "" {
+		// Delta requests carry no version, the ACKed one is the version sent with the nonce.
+		con.proxy.WatchedResources[request.TypeUrl].VersionAcked = previousInfo.VersionSent
+		con.proxy.WatchedResources[request.TypeUrl].AckedAt = time.Now()
+		con.proxy.WatchedResources[request.TypeUrl].PendingResources = nil
+		if request.TypeUrl == v3.SecretType {
+			con.secretDeliveries.acked(request.ResponseNonce, time.Now())
+		}
+	}
+	// End added by Ingress
 	alwaysRespond := previousInfo.AlwaysRespond
 	previousInfo.AlwaysRespond = false
 	con.proxy.Unlock()
@@ -480,6 +529,11 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection,
 		return err
 	}
 	defer func() { recordPushTime(w.TypeUrl, time.Since(t0)) }()
+	// Added by Ingress
+	if s.ResourceCache != nil {
+		res = versionResources(res)
+	}
+	// End added by Ingress
 	resp := &discovery.DeltaDiscoveryResponse{
 		ControlPlane: ControlPlane(),
 		TypeUrl:      w.TypeUrl,
@@ -502,6 +556,14 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection,
 	if len(resp.RemovedResources) > 0 {
 		deltaLog.Debugf("ADS:%v REMOVE for node:%s %v", v3.GetShortType(w.TypeUrl), con.conID, resp.RemovedResources)
 	}
+	// Added by Ingress
+	// After a reconnect, only send the resources the proxy does not already have.
+	var logSkipped string
+	if known := con.takeKnownVersions(w.TypeUrl); known != nil {
+		resp.Resources = skipKnownResources(res, known)
+		logSkipped = " skipped:" + strconv.Itoa(len(res)-len(resp.Resources))
+	}
+	// End added by Ingress
 	// normally wildcard xds `subscribe` is always nil, just in case there are some extended type not handled correctly.
 	if req.Delta.Subscribed == nil && isWildcardResource(w) {
 		// this is probably a bad idea...
@@ -524,6 +586,14 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection,
 	if len(logFiltered) > 0 {
 		info += logFiltered
 	}
+	// Added by Ingress
+	info += logSkipped
+	if s.ResourceCache != nil {
+		if err := s.ResourceCache.AddDelta(con.proxy.ID, resp); err != nil {
+			deltaLog.Debugf("ADS:%s: not caching delta response for %s: %v", v3.GetShortType(w.TypeUrl), con.conID, err)
+		}
+	}
+	// End added by Ingress
 
 	if err := con.sendDelta(resp); err != nil {
 		if recordSendError(w.TypeUrl, err) {
@@ -532,6 +602,13 @@ func (s *DiscoveryServer) pushDeltaXds(con *Connection,
 		}
 		return err
 	}
+	// Added by Ingress
+	if w.TypeUrl == v3.SecretType {
+		con.secretDeliveries.sent(resp.Nonce, resp.Resources, time.Now())
+	}
+	// Delta pushes only hold the changed resources.
+	s.checkPushSize(con, w.TypeUrl, res, configSize, true)
+	// End added by Ingress
 
 	switch {
 	case !req.Full && w.TypeUrl != v3.AddressType:
@@ -578,6 +655,10 @@ func newDeltaConnection(peerAddr string, stream DeltaDiscoveryStream) *Connectio
 		deltaStream:  stream,
 		deltaReqChan: make(chan *discovery.DeltaDiscoveryRequest, 1),
 		errorChan:    make(chan error, 1),
+		// Added by Ingress
+		secretDeliveries: &secretDeliveries{},
+		pushSizes:        &pushSizes{},
+		// End added by Ingress
 	}
 }
 
@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strconv"
+
+	xxhashv2 "github.com/cespare/xxhash/v2"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// When the ResourceCache is enabled, every resource sent over delta xDS is versioned with a hash
+// of its content, and the versions ACKed by each proxy are recorded in the cache. A proxy
+// reconnecting after a pilot rollout reports the versions it holds; the ones matching the
+// recorded state are trusted, and resources whose current version is unchanged are not sent
+// again. This turns the full push following a reconnect into a push of the actual changes.
+
+// resourceVersion identifies the content of a resource.
+func resourceVersion(r *discovery.Resource) string {
+	return strconv.FormatUint(xxhashv2.Sum64(r.GetResource().GetValue()), 16)
+}
+
+// versionResources returns the resources with their version set from their content. Generated
+// resources may be shared through the xds cache, so they are copied rather than modified.
+func versionResources(res model.Resources) model.Resources {
+	out := make(model.Resources, 0, len(res))
+	for _, r := range res {
+		if r.Version != "" {
+			out = append(out, r)
+			continue
+		}
+		out = append(out, &discovery.Resource{
+			Name:         r.Name,
+			Aliases:      r.Aliases,
+			Version:      resourceVersion(r),
+			Resource:     r.Resource,
+			Ttl:          r.Ttl,
+			CacheControl: r.CacheControl,
+		})
+	}
+	return out
+}
+
+// recordKnownVersions remembers the resources a reconnecting proxy reported which match the
+// state it last ACKed, so the next push of the type skips the ones which did not change.
+func (s *DiscoveryServer) recordKnownVersions(con *Connection, req *discovery.DeltaDiscoveryRequest) {
+	if s.ResourceCache == nil || len(req.InitialResourceVersions) == 0 {
+		return
+	}
+	acked, err := s.ResourceCache.LoadDeltaVersions(con.proxy.ID, req.TypeUrl)
+	if err != nil {
+		deltaLog.Warnf("ADS:%s: failed to load cached resource versions for %s: %v", req.TypeUrl, con.conID, err)
+		return
+	}
+	known := map[string]string{}
+	for name, version := range req.InitialResourceVersions {
+		if version != "" && acked[name] == version {
+			known[name] = version
+		}
+	}
+	if len(known) == 0 {
+		return
+	}
+	if con.knownVersions == nil {
+		con.knownVersions = map[string]map[string]string{}
+	}
+	con.knownVersions[req.TypeUrl] = known
+}
+
+// takeKnownVersions returns the resource versions recorded for the type by recordKnownVersions,
+// which are only used by the first push of the type.
+func (con *Connection) takeKnownVersions(typeURL string) map[string]string {
+	known := con.knownVersions[typeURL]
+	delete(con.knownVersions, typeURL)
+	return known
+}
+
+// skipKnownResources drops the resources the proxy already has at their current version.
+func skipKnownResources(res model.Resources, known map[string]string) model.Resources {
+	if len(known) == 0 {
+		return res
+	}
+	out := make(model.Resources, 0, len(res))
+	for _, r := range res {
+		if known[r.Name] != r.Version {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// storeDeltaAck records the resource versions ACKed by the proxy.
+func (s *DiscoveryServer) storeDeltaAck(con *Connection, req *discovery.DeltaDiscoveryRequest) {
+	if s.ResourceCache == nil || req.ResponseNonce == "" || req.ErrorDetail != nil {
+		return
+	}
+	if err := s.ResourceCache.StoreDelta(con.proxy.ID, req); err != nil {
+		deltaLog.Debugf("ADS:%s: not caching resource versions ACKed by %s: %v", req.TypeUrl, con.conID, err)
+	}
+}
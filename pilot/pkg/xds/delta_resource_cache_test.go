@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestSkipKnownResources(t *testing.T) {
+	res := model.Resources{
+		{Name: "a", Resource: &anypb.Any{Value: []byte("a")}},
+		{Name: "b", Resource: &anypb.Any{Value: []byte("b")}},
+		{Name: "c", Resource: &anypb.Any{Value: []byte("c")}, Version: "fixed"},
+	}
+	versioned := versionResources(res)
+	if res[0].Version != "" {
+		t.Fatalf("versionResources modified its input")
+	}
+	if versioned[0].Version != resourceVersion(res[0]) || versioned[2].Version != "fixed" {
+		t.Fatalf("unexpected versions: %v, %v", versioned[0].Version, versioned[2].Version)
+	}
+	if versioned[0].Version == versioned[1].Version {
+		t.Fatalf("different contents got the same version %v", versioned[0].Version)
+	}
+
+	known := map[string]string{
+		"a": versioned[0].Version,
+		"b": "stale",
+	}
+	got := skipKnownResources(versioned, known)
+	names := []string{}
+	for _, r := range got {
+		names = append(names, r.Name)
+	}
+	if len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Fatalf("unexpected resources: %v", names)
+	}
+
+	con := &Connection{knownVersions: map[string]map[string]string{"type": known}}
+	if got := con.takeKnownVersions("type"); len(got) != 2 {
+		t.Fatalf("unexpected known versions: %v", got)
+	}
+	if got := con.takeKnownVersions("type"); got != nil {
+		t.Fatalf("known versions should only be used once, got %v", got)
+	}
+}
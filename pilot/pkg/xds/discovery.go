@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	accesslog "github.com/envoyproxy/go-control-plane/envoy/service/accesslog/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/google/uuid"
 	"go.uber.org/atomic"
@@ -31,6 +32,7 @@ import (
 	"google.golang.org/grpc"
 
 	"istio.io/istio/pilot/pkg/autoregistration"
+	filecredentials "istio.io/istio/pilot/pkg/credentials/file"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/apigen"
@@ -38,7 +40,9 @@ import (
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/envoyfilter"
 	"istio.io/istio/pilot/pkg/networking/grpcgen"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/ali/global"
+	"istio.io/istio/pkg/cache"
 	"istio.io/istio/pkg/cluster"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/schema/kind"
@@ -88,6 +92,29 @@ type DiscoveryServer struct {
 
 	// concurrentPushLimit is a semaphore that limits the amount of concurrent XDS pushes.
 	concurrentPushLimit chan struct{}
+	// Added by Ingress
+	// pushScheduler caps the concurrent pushes of each type, and prioritizes the pushes of some types.
+	pushScheduler *pushScheduler
+	// generationLimiter limits the rate of the initial generations of connections, per node and globally.
+	generationLimiter *generationLimiter
+	// pluginGenerators are the generators built by the registered GeneratorPlugins.
+	pluginGenerators []model.XdsResourceGenerator
+	// nackQuarantine quarantines the resources repeatedly rejected by proxies. It is nil if disabled.
+	nackQuarantine *nackQuarantine
+	// pushAuditor records the pushes for audit. It is nil if disabled.
+	pushAuditor *pushAuditor
+	// shadow compares the xDS generated for the proxies of the stable revision with theirs. It is nil if disabled.
+	shadow *shadowComparator
+	// consistency periodically checks that the xDS generated for the proxies is consistent. It is nil if disabled.
+	consistency *consistencyChecker
+	// accessLogs keeps the last access logs of the proxies streaming them to Pilot. It is nil if disabled.
+	accessLogs *accessLogCollector
+	// standbyEnded is closed once the caches are synced, ending the warm standby.
+	standbyEnded chan struct{}
+	standbyOnce  sync.Once
+	// wasmRefreshes records the forced refreshes of the modules of the WasmPlugins.
+	wasmRefreshes wasmRefreshes
+	// End added by Ingress
 	// RequestRateLimit limits the number of new XDS requests allowed. This helps prevent thundering hurd of incoming requests.
 	RequestRateLimit *rate.Limiter
 
@@ -135,6 +162,26 @@ type DiscoveryServer struct {
 	// Cache for XDS resources
 	Cache model.XdsCache
 
+	// Added by Ingress
+	// ResourceCache holds the last ACKed responses of proxies, if enabled.
+	ResourceCache cache.XdsResourceCache
+	// End added by Ingress
+
+	// Added by Ingress
+	// secretRejections holds the last SDS rejection reported on each connection, until an SDS response is ACKed.
+	secretRejections      map[string]*SecretRejection
+	secretRejectionsMutex sync.RWMutex
+	// End added by Ingress
+
+	// Added by Ingress
+	// outlierAggregator aggregates the outlier detection events reported by the gateways.
+	outlierAggregator *outlierAggregator
+	// wasmStats aggregates the Wasm stats reported by the agents of the proxies.
+	wasmStats *wasmStatsAggregator
+	// pushSizeBudgets are the budgets of the size of the pushes, by type URL.
+	pushSizeBudgets map[string]int64
+	// End added by Ingress
+
 	// JwtKeyResolver holds a reference to the JWT key resolver instance.
 	JwtKeyResolver *model.JwksResolver
 
@@ -154,6 +201,23 @@ type DiscoveryServer struct {
 
 // NewDiscoveryServer creates DiscoveryServer that sources data from Pilot's internal mesh data structures
 func NewDiscoveryServer(env *model.Environment, instanceID string, clusterID cluster.ID, clusterAliases map[string]string) *DiscoveryServer {
+	// Added by Ingress
+	ps, err := newPushScheduler(alifeatures.PushThrottleByType, alifeatures.PushPriorityTypes)
+	if err != nil {
+		log.Errorf("ignoring invalid push throttle settings: %v", err)
+		ps, _ = newPushScheduler("", "")
+	}
+	gl := newGenerationLimiter(alifeatures.GenerationRateLimit, alifeatures.GenerationBurst,
+		alifeatures.ProxyGenerationRateLimit, alifeatures.ProxyGenerationBurst)
+	pa, err := newPushAuditor(alifeatures.PushAuditSink, alifeatures.PushAuditActorAnnotations)
+	if err != nil {
+		log.Errorf("push audit disabled: %v", err)
+	}
+	budgets, err := parsePushSizeBudgets(alifeatures.PushSizeBudgets)
+	if err != nil {
+		log.Errorf("ignoring invalid push size budgets: %v", err)
+	}
+	// End added by Ingress
 	out := &DiscoveryServer{
 		Env:                 env,
 		Generators:          map[string]model.XdsResourceGenerator{},
@@ -163,9 +227,18 @@ func NewDiscoveryServer(env *model.Environment, instanceID string, clusterID clu
 		InboundUpdates:      atomic.NewInt64(0),
 		CommittedUpdates:    atomic.NewInt64(0),
 		pushChannel:         make(chan *model.PushRequest, 10),
-		pushQueue:           NewPushQueue(),
+		pushScheduler:       ps,
+		generationLimiter:   gl,
+		nackQuarantine:      newNackQuarantine(alifeatures.NackQuarantineThreshold, alifeatures.NackQuarantineDuration),
+		pushAuditor:         pa,
+		standbyEnded:        make(chan struct{}),
+		pushQueue:           NewPriorityPushQueue(ps.isPriorityPush),
 		debugHandlers:       map[string]string{},
 		adsClients:          map[string]*Connection{},
+		secretRejections:    map[string]*SecretRejection{},
+		outlierAggregator:   newOutlierAggregator(alifeatures.OutlierEventRetention),
+		wasmStats:           newWasmStatsAggregator(alifeatures.WasmStatsRetention),
+		pushSizeBudgets:     budgets,
 		debounceOptions: debounceOptions{
 			debounceAfter:     features.DebounceAfter,
 			debounceMax:       features.DebounceMax,
@@ -177,6 +250,12 @@ func NewDiscoveryServer(env *model.Environment, instanceID string, clusterID clu
 		discoveryStartTime: processStartTime,
 	}
 
+	// Added by Ingress
+	out.shadow = newShadowComparator(out, alifeatures.ShadowPushSource, alifeatures.ShadowPushInterval, alifeatures.ShadowPushMaxProxies)
+	out.consistency = newConsistencyChecker(out, alifeatures.ConsistencyCheckInterval, alifeatures.ConsistencyCheckSampleSize)
+	out.accessLogs = newAccessLogCollector(alifeatures.AccessLogCollectorBufferSize)
+	// End added by Ingress
+
 	out.ClusterAliases = make(map[cluster.ID]cluster.ID)
 	for alias := range clusterAliases {
 		out.ClusterAliases[cluster.ID(alias)] = cluster.ID(clusterAliases[alias])
@@ -215,6 +294,11 @@ func (s *DiscoveryServer) closeJwksResolver() {
 func (s *DiscoveryServer) Register(rpcs *grpc.Server) {
 	// Register v3 server
 	discovery.RegisterAggregatedDiscoveryServiceServer(rpcs, s)
+	// Added by Ingress
+	if s.accessLogs != nil {
+		accesslog.RegisterAccessLogServiceServer(rpcs, s.accessLogs)
+	}
+	// End added by Ingress
 }
 
 var processStartTime = time.Now()
@@ -223,6 +307,9 @@ var processStartTime = time.Now()
 func (s *DiscoveryServer) CachesSynced() {
 	log.Infof("All caches have been synced up in %v, marking server ready", time.Since(s.discoveryStartTime))
 	s.serverReady.Store(true)
+	// Added by Ingress
+	s.endStandby()
+	// End added by Ingress
 }
 
 func (s *DiscoveryServer) IsServerReady() bool {
@@ -235,6 +322,18 @@ func (s *DiscoveryServer) Start(stopCh <-chan struct{}) {
 	go s.periodicRefreshMetrics(stopCh)
 	go s.sendPushes(stopCh)
 	go s.Cache.Run(stopCh)
+	// Added by Ingress
+	s.runPluginGenerators(stopCh)
+	if s.pushAuditor != nil {
+		go s.pushAuditor.run(stopCh)
+	}
+	if s.shadow != nil {
+		go s.shadow.run(stopCh)
+	}
+	if s.consistency != nil {
+		go s.consistency.run(stopCh)
+	}
+	// End added by Ingress
 }
 
 // Push metrics are updated periodically (10s default)
@@ -264,6 +363,9 @@ func (s *DiscoveryServer) periodicRefreshMetrics(stopCh <-chan struct{}) {
 // dropCacheForRequest clears the cache in response to a push request
 func (s *DiscoveryServer) dropCacheForRequest(req *model.PushRequest) {
 	// If we don't know what updated, cannot safely cache. Clear the whole cache
+	// Added by Ingress
+	s.clearPluginCaches(req.ConfigsUpdated)
+	// End added by Ingress
 	if len(req.ConfigsUpdated) == 0 {
 		s.Cache.ClearAll()
 	} else {
@@ -308,6 +410,9 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 	versionLocal := s.NextVersion()
 	push, err := s.initPushContext(req, oldPushContext, versionLocal)
 	if err != nil {
+		// Added by Ingress
+		s.auditPushFailed(req, err)
+		// End added by Ingress
 		return
 	}
 	initContextTime := time.Since(t0)
@@ -568,13 +673,24 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 	s.Generators[v3.RouteType] = &RdsGenerator{Server: s}
 	// Added by ingress
 	s.Generators[v3.ScopedRouteType] = &SrdsGenerator{Server: s}
+	s.Generators[v3.VirtualHostType] = &VhdsGenerator{Server: s}
 	// End added by ingress
 	s.Generators[v3.EndpointType] = edsGen
 	ecdsGen := &EcdsGenerator{Server: s}
+	// Modified by ingress
+	if env.CredentialsController != nil || len(env.ExternalCredentialsControllers) > 0 {
+		creds := env.CredentialsController
+		if creds == nil {
+			creds = filecredentials.KubernetesUnavailable{}
+		}
+		secretGen := NewSecretGen(creds, s.Cache, s.clusterID, env.Mesh())
+		secretGen.external = env.ExternalCredentialsControllers
+		s.Generators[v3.SecretType] = secretGen
+	}
 	if env.CredentialsController != nil {
-		s.Generators[v3.SecretType] = NewSecretGen(env.CredentialsController, s.Cache, s.clusterID, env.Mesh())
 		ecdsGen.SetCredController(env.CredentialsController)
 	}
+	// End modified by ingress
 	s.Generators[v3.ExtensionConfigurationType] = ecdsGen
 	s.Generators[v3.NameTableType] = &NdsGenerator{Server: s}
 	s.Generators[v3.ProxyConfigType] = &PcdsGenerator{Server: s, TrustBundle: env.TrustBundle}
@@ -596,9 +712,21 @@ func (s *DiscoveryServer) InitGenerators(env *model.Environment, systemNameSpace
 
 	s.Generators["api/"+TypeURLConnect] = s.StatusGen
 
+	// Added by Ingress
+	mcpKinds, err := apigen.ParseMCPKinds(alifeatures.MCPServerKinds)
+	if err != nil {
+		log.Errorf("failed to parse PILOT_MCP_SERVER_KINDS, no config is served to the downstream control planes: %v", err)
+	}
+	// Always registered, not to fall back to the api generator serving all the configs.
+	s.Generators[apigen.MCPGeneratorName] = apigen.NewMCPGenerator(env.ConfigStore, mcpKinds)
+	// End added by Ingress
+
 	s.Generators["event"] = s.StatusGen
 	s.Generators[v3.DebugType] = NewDebugGen(s, systemNameSpace, internalDebugMux)
 	s.Generators[v3.BootstrapType] = &BootstrapGenerator{Server: s}
+	// Added by Ingress
+	s.initGeneratorPlugins(env)
+	// End added by Ingress
 }
 
 // Shutdown shuts down DiscoveryServer components.
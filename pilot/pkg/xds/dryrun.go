@@ -0,0 +1,329 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+)
+
+// maxDryRunBodySize bounds the size of the configs posted to /debug/dryrun.
+const maxDryRunBodySize = 4 * 1024 * 1024
+
+var errDryRunReadOnly = errors.New("dry run config store is read only")
+
+// overlayConfigStore serves the configs of a proposed change over the ones of a store. It never writes
+// to the underlying store.
+type overlayConfigStore struct {
+	model.ConfigStore
+	// configs holds the proposed configs by kind and name. A nil config is a proposed deletion.
+	configs map[config.GroupVersionKind]map[types.NamespacedName]*config.Config
+}
+
+var _ model.ConfigStore = &overlayConfigStore{}
+
+func newOverlayConfigStore(base model.ConfigStore, configs []config.Config, remove bool) *overlayConfigStore {
+	o := &overlayConfigStore{
+		ConfigStore: base,
+		configs:     map[config.GroupVersionKind]map[types.NamespacedName]*config.Config{},
+	}
+	for i := range configs {
+		cfg := &configs[i]
+		if o.configs[cfg.GroupVersionKind] == nil {
+			o.configs[cfg.GroupVersionKind] = map[types.NamespacedName]*config.Config{}
+		}
+		key := types.NamespacedName{Namespace: cfg.Namespace, Name: cfg.Name}
+		if remove {
+			o.configs[cfg.GroupVersionKind][key] = nil
+		} else {
+			o.configs[cfg.GroupVersionKind][key] = cfg
+		}
+	}
+	return o
+}
+
+func (o *overlayConfigStore) Get(typ config.GroupVersionKind, name, namespace string) *config.Config {
+	if cfg, f := o.configs[typ][types.NamespacedName{Namespace: namespace, Name: name}]; f {
+		return cfg
+	}
+	return o.ConfigStore.Get(typ, name, namespace)
+}
+
+func (o *overlayConfigStore) List(typ config.GroupVersionKind, namespace string) []config.Config {
+	base := o.ConfigStore.List(typ, namespace)
+	overlay, f := o.configs[typ]
+	if !f {
+		return base
+	}
+	out := make([]config.Config, 0, len(base)+len(overlay))
+	for _, cfg := range base {
+		if _, f := overlay[types.NamespacedName{Namespace: cfg.Namespace, Name: cfg.Name}]; !f {
+			out = append(out, cfg)
+		}
+	}
+	for key, cfg := range overlay {
+		if cfg != nil && (namespace == metav1.NamespaceAll || key.Namespace == namespace) {
+			out = append(out, *cfg)
+		}
+	}
+	return out
+}
+
+func (o *overlayConfigStore) Create(config.Config) (string, error) {
+	return "", errDryRunReadOnly
+}
+
+func (o *overlayConfigStore) Update(config.Config) (string, error) {
+	return "", errDryRunReadOnly
+}
+
+func (o *overlayConfigStore) UpdateStatus(config.Config) (string, error) {
+	return "", errDryRunReadOnly
+}
+
+func (o *overlayConfigStore) Patch(config.Config, config.PatchFunc) (string, error) {
+	return "", errDryRunReadOnly
+}
+
+func (o *overlayConfigStore) Delete(config.GroupVersionKind, string, string, *string) error {
+	return errDryRunReadOnly
+}
+
+// DryRunResponse is the response of the /debug/dryrun endpoint.
+type DryRunResponse struct {
+	Proxy string `json:"proxy"`
+	// Configs are the proposed configs, as kind/namespace/name.
+	Configs []string `json:"configs"`
+	// Diffs holds the changes of the generated resources, for every type watched by the proxy which changed.
+	Diffs []DryRunDiff `json:"diffs"`
+}
+
+// DryRunDiff describes the changes of the generated resources of a type.
+type DryRunDiff struct {
+	TypeURL  string               `json:"typeUrl"`
+	Added    []string             `json:"added,omitempty"`
+	Removed  []string             `json:"removed,omitempty"`
+	Modified []DryRunResourceDiff `json:"modified,omitempty"`
+}
+
+// DryRunResourceDiff describes the changes of a generated resource.
+type DryRunResourceDiff struct {
+	Name string `json:"name"`
+	Diff string `json:"diff"`
+}
+
+// dryRunz generates the xDS of a proxy with the configs posted as YAML applied, or removed with delete=true,
+// and returns how it differs from the xDS generated with the current configs. Nothing is pushed nor stored.
+// Secrets are never generated, and Gateway API resources are not supported.
+// It is mapped to /debug/dryrun
+func (s *DiscoveryServer) dryRunz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = w.Write([]byte("POST the proposed configs as YAML\n"))
+		return
+	}
+	proxyID, con := s.getDebugConnection(req)
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxDryRunBodySize))
+	if err != nil {
+		handleHTTPError(w, err)
+		return
+	}
+	configs, unknown, err := crd.ParseInputs(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	if len(unknown) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, "unsupported kind %s\n", unknown[0].Kind)
+		return
+	}
+	if len(configs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("no config to dry run\n"))
+		return
+	}
+	names := make([]string, 0, len(configs))
+	for i := range configs {
+		if configs[i].Namespace == "" {
+			configs[i].Namespace = metav1.NamespaceDefault
+		}
+		names = append(names, fmt.Sprintf("%s/%s/%s", configs[i].GroupVersionKind.Kind, configs[i].Namespace, configs[i].Name))
+	}
+
+	resp, err := s.dryRun(con, configs, req.URL.Query().Get("delete") == "true")
+	if err != nil {
+		handleHTTPError(w, err)
+		return
+	}
+	resp.Configs = names
+	writeJSON(w, resp, req)
+}
+
+// dryRun compares the xDS generated for the proxy of the connection with and without the proposed configs.
+func (s *DiscoveryServer) dryRun(con *Connection, configs []config.Config, remove bool) (*DryRunResponse, error) {
+	before, err := s.generateWithoutCache(con, s.Env, s.globalPushContext())
+	if err != nil {
+		return nil, err
+	}
+	env := s.Env.WithConfigStore(newOverlayConfigStore(s.Env.ConfigStore, configs, remove))
+	push := model.NewPushContext()
+	push.JwtKeyResolver = s.JwtKeyResolver
+	if err := push.InitContext(env, nil, nil); err != nil {
+		return nil, fmt.Errorf("failed to init push context: %v", err)
+	}
+	after, err := s.generateWithoutCache(con, env, push)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &DryRunResponse{Proxy: con.proxy.ID, Diffs: []DryRunDiff{}}
+	typeURLs := make([]string, 0, len(after))
+	for typeURL := range after {
+		typeURLs = append(typeURLs, typeURL)
+	}
+	sort.Strings(typeURLs)
+	for _, typeURL := range typeURLs {
+		if diff := diffResources(typeURL, before[typeURL], after[typeURL]); diff != nil {
+			resp.Diffs = append(resp.Diffs, *diff)
+		}
+	}
+	return resp, nil
+}
+
+// generateWithoutCache generates the resources of the types watched by the connection, for a copy of its proxy
+// using the given push context. Generation goes through a discovery server without caches, so it neither
+// reads nor alters the resources generated for pushes. Secrets and debug types are never generated.
+func (s *DiscoveryServer) generateWithoutCache(con *Connection, env *model.Environment, push *model.PushContext) (map[string]model.Resources, error) {
+	return s.generateWithCache(con, env, push, model.DisabledCache{})
+}
+
+// generateWithCache generates the resources of the types watched by the connection like generateWithoutCache, but
+// through the given xDS cache.
+func (s *DiscoveryServer) generateWithCache(con *Connection, env *model.Environment, push *model.PushContext,
+	cache model.XdsCache,
+) (map[string]model.Resources, error) {
+	dry := &DiscoveryServer{
+		Env:             env,
+		Generators:      map[string]model.XdsResourceGenerator{},
+		ConfigGenerator: core.NewConfigGenerator(cache),
+		Cache:           cache,
+		clusterID:       s.clusterID,
+		ClusterAliases:  s.ClusterAliases,
+		JwtKeyResolver:  s.JwtKeyResolver,
+	}
+	dry.InitGenerators(env, "", nil)
+
+	proxy, err := dry.initProxyMetadata(con.node)
+	if err != nil {
+		return nil, err
+	}
+	if alias, exists := dry.ClusterAliases[proxy.Metadata.ClusterID]; exists {
+		proxy.Metadata.ClusterID = alias
+	}
+	proxy.VerifiedIdentity = con.proxy.VerifiedIdentity
+	proxy.LastPushContext = push
+	proxy.LastPushTime = time.Now()
+	dry.computeProxyState(proxy, nil)
+	proxy.DiscoverIPMode()
+	if proxy.Metadata.Generator != "" {
+		proxy.XdsResourceGenerator = dry.Generators[proxy.Metadata.Generator]
+	}
+	con.proxy.RLock()
+	proxy.WatchedResources = make(map[string]*model.WatchedResource, len(con.proxy.WatchedResources))
+	for typeURL, w := range con.proxy.WatchedResources {
+		proxy.WatchedResources[typeURL] = &model.WatchedResource{
+			TypeUrl:       w.TypeUrl,
+			ResourceNames: append([]string(nil), w.ResourceNames...),
+			Wildcard:      w.Wildcard,
+		}
+	}
+	con.proxy.RUnlock()
+
+	dryCon := &Connection{conID: con.conID, proxy: proxy, node: con.node}
+	req := &model.PushRequest{Full: true, Push: push, Start: proxy.LastPushTime, Reason: model.NewReasonStats(model.DebugTrigger)}
+	out := map[string]model.Resources{}
+	for _, w := range orderWatchedResources(proxy.WatchedResources) {
+		if w.TypeUrl == v3.SecretType || strings.HasPrefix(w.TypeUrl, v3.DebugType) {
+			continue
+		}
+		gen := dry.findGenerator(w.TypeUrl, dryCon)
+		if gen == nil {
+			continue
+		}
+		res, _, err := gen.Generate(proxy, w, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate %s: %v", v3.GetShortType(w.TypeUrl), err)
+		}
+		out[w.TypeUrl] = res
+	}
+	return out, nil
+}
+
+// diffResources returns the changes from the resources before to the ones after, or nil if there is none.
+func diffResources(typeURL string, before, after model.Resources) *DryRunDiff {
+	diff := &DryRunDiff{TypeURL: typeURL}
+	previous := make(map[string]model.Resources, len(before))
+	for _, r := range before {
+		previous[r.Name] = append(previous[r.Name], r)
+	}
+	seen := map[string]struct{}{}
+	for _, r := range after {
+		seen[r.Name] = struct{}{}
+		old, f := previous[r.Name]
+		if !f {
+			diff.Added = append(diff.Added, r.Name)
+			continue
+		}
+		if d := cmp.Diff(old[0].Resource, r.Resource, protocmp.Transform()); d != "" {
+			diff.Modified = append(diff.Modified, DryRunResourceDiff{Name: r.Name, Diff: d})
+		}
+	}
+	for _, r := range before {
+		if _, f := seen[r.Name]; !f {
+			diff.Removed = append(diff.Removed, r.Name)
+		}
+	}
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Modified) == 0 {
+		return nil
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Modified, func(i, j int) bool {
+		return diff.Modified[i].Name < diff.Modified[j].Name
+	})
+	return diff
+}
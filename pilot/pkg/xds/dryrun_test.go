@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+func TestOverlayConfigStore(t *testing.T) {
+	vs := func(name, ns string, hosts ...string) config.Config {
+		return config.Config{
+			Meta: config.Meta{GroupVersionKind: gvk.VirtualService, Name: name, Namespace: ns},
+			Spec: &networking.VirtualService{Hosts: hosts},
+		}
+	}
+	base := memory.MakeSkipValidation(collections.Pilot)
+	for _, cfg := range []config.Config{vs("a", "default", "a"), vs("b", "default", "b"), vs("c", "other", "c")} {
+		if _, err := base.Create(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(configs []config.Config) []string {
+		out := []string{}
+		for _, cfg := range configs {
+			out = append(out, cfg.Namespace+"/"+cfg.Name)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	o := newOverlayConfigStore(base, []config.Config{vs("a", "default", "updated"), vs("d", "other", "d")}, false)
+	if got := o.Get(gvk.VirtualService, "a", "default"); got.Spec.(*networking.VirtualService).Hosts[0] != "updated" {
+		t.Fatalf("expected the proposed config, got %v", got.Spec)
+	}
+	if got := names(o.List(gvk.VirtualService, "")); !reflect.DeepEqual(got, []string{"default/a", "default/b", "other/c", "other/d"}) {
+		t.Fatalf("unexpected configs %v", got)
+	}
+	if got := names(o.List(gvk.VirtualService, "default")); !reflect.DeepEqual(got, []string{"default/a", "default/b"}) {
+		t.Fatalf("unexpected configs %v", got)
+	}
+	if _, err := o.Create(vs("e", "default")); err == nil {
+		t.Fatalf("expected the store to be read only")
+	}
+
+	o = newOverlayConfigStore(base, []config.Config{vs("b", "default")}, true)
+	if o.Get(gvk.VirtualService, "b", "default") != nil {
+		t.Fatalf("expected the config to be deleted")
+	}
+	if got := names(o.List(gvk.VirtualService, "")); !reflect.DeepEqual(got, []string{"default/a", "other/c"}) {
+		t.Fatalf("unexpected configs %v", got)
+	}
+	if len(base.List(gvk.VirtualService, "")) != 3 {
+		t.Fatalf("the base store must not be changed")
+	}
+}
+
+func TestDiffResources(t *testing.T) {
+	res := func(name string, timeout int64) *discovery.Resource {
+		return &discovery.Resource{
+			Name:     name,
+			Resource: protoconv.MessageToAny(&cluster.Cluster{Name: name, ConnectTimeout: durationpb.New(time.Duration(timeout) * time.Second)}),
+		}
+	}
+	before := model.Resources{res("a", 1), res("b", 1), res("c", 1)}
+	if diff := diffResources(v3.ClusterType, before, model.Resources{res("c", 1), res("b", 1), res("a", 1)}); diff != nil {
+		t.Fatalf("expected no diff, got %+v", diff)
+	}
+	diff := diffResources(v3.ClusterType, before, model.Resources{res("a", 1), res("c", 2), res("d", 1)})
+	if diff == nil {
+		t.Fatalf("expected a diff")
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"d"}) || !reflect.DeepEqual(diff.Removed, []string{"b"}) {
+		t.Fatalf("unexpected added %v or removed %v", diff.Added, diff.Removed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Name != "c" || diff.Modified[0].Diff == "" {
+		t.Fatalf("unexpected modified %+v", diff.Modified)
+	}
+}
@@ -79,30 +79,41 @@ func onlyReferencedConfigsUpdated(req *model.PushRequest) bool {
 
 // Generate returns ECDS resources for a given proxy.
 func (e *EcdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	// Modified by Ingress
+	res, logs, _, err := e.generate(proxy, w, req)
+	return res, logs, err
+	// End modified by Ingress
+}
+
+// generate returns ECDS resources for a given proxy, along with the newly orphaned extension configs.
+func (e *EcdsGenerator) generate(proxy *model.Proxy, w *model.WatchedResource,
+	req *model.PushRequest,
+) (model.Resources, model.XdsLogDetails, []string, error) {
 	if !ecdsNeedsPush(req) {
-		return nil, model.DefaultXdsLogDetails, nil
+		return nil, model.DefaultXdsLogDetails, nil, nil
 	}
 
-	wasmSecrets := referencedSecrets(proxy, req.Push, w.ResourceNames)
+	// Modified by Ingress
+	// The orphaned extension configs are generated again, to restore them once they are back.
+	names := subscribedResources(w)
+	// End modified by Ingress
+	secretDeps := wasmSecretDependencies(proxy, req.Push, names)
 
 	// When referenced configs are ONLY updated (like secret update), we should push
 	// if the referenced config is relevant for ECDS. A secret update is relevant
-	// only if it is referred via WASM plugin.
-	if onlyReferencedConfigsUpdated(req) {
-		updatedSecrets := model.ConfigsOfKind(req.ConfigsUpdated, kind.Secret)
-		needsPush := false
-		for _, sr := range wasmSecrets {
-			if _, found := updatedSecrets[model.ConfigKey{Kind: kind.Secret, Name: sr.Name, Namespace: sr.Namespace}]; found {
-				needsPush = true
-				break
-			}
-		}
-		if !needsPush {
-			return nil, model.DefaultXdsLogDetails, nil
+	// only if it is referred via WASM plugin, and in that case only the extension
+	// configs of the plugins referencing the updated secrets are regenerated.
+	referencedOnly := onlyReferencedConfigsUpdated(req) // Added by Ingress
+	if referencedOnly {
+		names = affectedExtensionConfigs(secretDeps, model.ConfigsOfKind(req.ConfigsUpdated, kind.Secret))
+		if len(names) == 0 {
+			return nil, model.DefaultXdsLogDetails, nil, nil
 		}
 	}
+	wasmSecrets := referencedSecrets(proxy, secretDeps, names)
 
 	var secrets map[string][]byte
+	var denied sets.String // Added by ingress
 	if len(wasmSecrets) > 0 {
 		// Generate the pull secrets first, which will be used when populating the extension config.
 		if e.secretController != nil {
@@ -110,29 +121,46 @@ func (e *EcdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, r
 			secretController, err := e.secretController.ForCluster(proxy.Metadata.ClusterID)
 			if err != nil {
 				log.Warnf("proxy %s is from an unknown cluster, cannot retrieve certificates for Wasm image pull: %v", proxy.ID, err)
-				return nil, model.DefaultXdsLogDetails, nil
+				return nil, model.DefaultXdsLogDetails, nil, nil
 			}
+			// Added by ingress
+			secretDeps, denied = authorizePullSecretReferences(secretController, secretDeps)
+			wasmSecrets = referencedSecrets(proxy, secretDeps, names)
+			// End added by ingress
 			// Inserts Wasm pull secrets in ECDS response, which will be used at xds proxy for image pull.
 			// Before forwarding to Envoy, xds proxy will remove the secret from ECDS response.
 			secrets = e.GeneratePullSecrets(proxy, wasmSecrets, secretController)
 		}
 	}
 
-	ec := e.Server.ConfigGenerator.BuildExtensionConfiguration(proxy, req.Push, w.ResourceNames, secrets)
+	ec := e.Server.ConfigGenerator.BuildExtensionConfiguration(proxy, req.Push, names, secrets)
 
 	if ec == nil {
-		return nil, model.DefaultXdsLogDetails, nil
+		return nil, model.DefaultXdsLogDetails, nil, nil
 	}
 
 	resources := make(model.Resources, 0, len(ec))
 	for _, c := range ec {
+		// Added by ingress
+		if denied.Contains(c.Name) {
+			stripPullSecret(c)
+		}
+		e.Server.refreshWasmModule(proxy, c)
+		// End added by ingress
 		resources = append(resources, &discovery.Resource{
 			Name:     c.Name,
 			Resource: protoconv.MessageToAny(c),
 		})
 	}
 
-	return resources, model.DefaultXdsLogDetails, nil
+	// Added by Ingress
+	if referencedOnly {
+		// Only the extension configs referencing the updated secrets are pushed.
+		return resources, model.XdsLogDetails{Incremental: true}, nil, nil
+	}
+	orphaned := reconcileOrphanedExtensionConfigs(proxy, w, names, resources)
+	return resources, orphanedLogDetails(orphaned), orphaned, nil
+	// End added by Ingress
 }
 
 func (e *EcdsGenerator) GeneratePullSecrets(proxy *model.Proxy, secretResources []SecretResource,
@@ -159,7 +187,9 @@ func (e *EcdsGenerator) SetCredController(creds credscontroller.MulticlusterCont
 	e.secretController = creds
 }
 
-func referencedSecrets(proxy *model.Proxy, push *model.PushContext, resourceNames []string) []SecretResource {
+// wasmSecretDependencies returns the secret to extension config dependencies of the given proxy, keyed by
+// the pull secret resource name and valued by the resource names of the watched WasmPlugins referencing it.
+func wasmSecretDependencies(proxy *model.Proxy, push *model.PushContext, resourceNames []string) map[string]sets.String {
 	// The requirement for the Wasm pull secret:
 	// * Wasm pull secrets must be of type `kubernetes.io/dockerconfigjson`.
 	// * Secret are referenced by a WasmPlugin which applies to this proxy.
@@ -167,16 +197,39 @@ func referencedSecrets(proxy *model.Proxy, push *model.PushContext, resourceName
 	//       and we will get it again at extension config build. Avoid getting it twice if this becomes a problem.
 	watched := sets.New(resourceNames...)
 	wasmPlugins := push.WasmPlugins(proxy)
-	referencedSecrets := sets.String{}
+	deps := map[string]sets.String{}
 	for _, wps := range wasmPlugins {
 		for _, wp := range wps {
 			if watched.Contains(wp.ResourceName) && wp.ImagePullSecret != "" {
-				referencedSecrets.Insert(wp.ImagePullSecret)
+				sets.InsertOrNew(deps, wp.ImagePullSecret, wp.ResourceName)
 			}
 		}
 	}
+	return deps
+}
+
+// affectedExtensionConfigs returns the extension config names depending on any of the updated secrets.
+func affectedExtensionConfigs(secretDeps map[string]sets.String, updatedSecrets sets.Set[model.ConfigKey]) []string {
+	affected := sets.New[string]()
+	for rn, dependents := range secretDeps {
+		sr, err := parseSecretName(rn, "")
+		if err != nil {
+			continue
+		}
+		if updatedSecrets.Contains(model.ConfigKey{Kind: kind.Secret, Name: sr.Name, Namespace: sr.Namespace}) {
+			affected.Merge(dependents)
+		}
+	}
+	return sets.SortedList(affected)
+}
+
+func referencedSecrets(proxy *model.Proxy, secretDeps map[string]sets.String, resourceNames []string) []SecretResource {
+	names := sets.New(resourceNames...)
 	var filtered []SecretResource
-	for rn := range referencedSecrets {
+	for rn, dependents := range secretDeps {
+		if dependents.Intersection(names).IsEmpty() {
+			continue
+		}
 		sr, err := parseSecretName(rn, proxy.Metadata.ClusterID)
 		if err != nil {
 			log.Warnf("Failed to parse secret resource name %v: %v", rn, err)
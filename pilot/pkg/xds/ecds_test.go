@@ -239,8 +239,7 @@ func TestECDSGenerate(t *testing.T) {
 			wantExtensions:   sets.String{"default.default-plugin-with-sec": {}},
 			wantSecrets:      sets.String{"default-docker-credential": {}},
 		},
-		// All the credentials should be sent to istio-agent even if one of them is only updated,
-		// because `istio-agent` does not keep the credentials.
+		// Only the extension configs referencing the updated secret are regenerated.
 		{
 			name:           "multi_wasmplugin_update_secret",
 			proxyNamespace: "default",
@@ -249,6 +248,20 @@ func TestECDSGenerate(t *testing.T) {
 				ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Secret, Name: "default-pull-secret", Namespace: "default"}),
 			},
 			watchedResources: []string{"default.default-plugin-with-sec", "istio-system.root-plugin"},
+			wantExtensions:   sets.String{"default.default-plugin-with-sec": {}},
+			wantSecrets:      sets.String{"default-docker-credential": {}},
+		},
+		{
+			name:           "multi_wasmplugin_update_all_secrets",
+			proxyNamespace: "default",
+			request: &model.PushRequest{
+				Full: false,
+				ConfigsUpdated: sets.New(
+					model.ConfigKey{Kind: kind.Secret, Name: "default-pull-secret", Namespace: "default"},
+					model.ConfigKey{Kind: kind.Secret, Name: "root-pull-secret", Namespace: "istio-system"},
+				),
+			},
+			watchedResources: []string{"default.default-plugin", "default.default-plugin-with-sec", "istio-system.root-plugin"},
 			wantExtensions:   sets.String{"default.default-plugin-with-sec": {}, "istio-system.root-plugin": {}},
 			wantSecrets:      sets.String{"default-docker-credential": {}, "root-docker-credential": {}},
 		},
@@ -296,3 +309,52 @@ func TestECDSGenerate(t *testing.T) {
 		})
 	}
 }
+
+func TestECDSOrphanedExtensionConfigs(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		Configs: []config.Config{wasmPlugin},
+	})
+	gen := s.Discovery.Generators[v3.ExtensionConfigurationType].(model.XdsDeltaResourceGenerator)
+	proxy := s.SetupProxy(&model.Proxy{
+		VerifiedIdentity: &spiffe.Identity{Namespace: "default"},
+		Type:             model.Router,
+		Metadata: &model.NodeMetadata{
+			ClusterID: "Kubernetes",
+		},
+	})
+	w := &model.WatchedResource{
+		TypeUrl:       v3.ExtensionConfigurationType,
+		ResourceNames: []string{"default.default-plugin", "default.deleted-plugin"},
+	}
+	proxy.WatchedResources = map[string]*model.WatchedResource{v3.ExtensionConfigurationType: w}
+	req := &model.PushRequest{Full: true, Push: s.PushContext(), Start: time.Now()}
+
+	// The extension config of the deleted plugin is signaled as removed once, and moved to the orphaned resources.
+	resources, removed, _, usedDelta, err := gen.GenerateDeltas(proxy, req, w)
+	if err != nil || !usedDelta {
+		t.Fatalf("unexpected delta generation: %v %v", usedDelta, err)
+	}
+	if len(resources) != 1 || resources[0].Name != "default.default-plugin" {
+		t.Errorf("got resources %v, want default.default-plugin", resources)
+	}
+	if !reflect.DeepEqual(removed, model.DeletedResources{"default.deleted-plugin"}) {
+		t.Errorf("got removed %v, want default.deleted-plugin", removed)
+	}
+	if !reflect.DeepEqual(w.ResourceNames, []string{"default.default-plugin"}) ||
+		!reflect.DeepEqual(w.OrphanedResources, []string{"default.deleted-plugin"}) {
+		t.Errorf("got watched %v and orphaned %v", w.ResourceNames, w.OrphanedResources)
+	}
+	if _, removed, _, _, _ = gen.GenerateDeltas(proxy, req, w); len(removed) != 0 {
+		t.Errorf("got removed %v again", removed)
+	}
+
+	// An orphaned extension config generated again is restored.
+	w.ResourceNames, w.OrphanedResources = nil, []string{"default.default-plugin"}
+	resources, _, _ = gen.Generate(proxy, w, req)
+	if len(resources) != 1 || resources[0].Name != "default.default-plugin" {
+		t.Errorf("got resources %v, want default.default-plugin", resources)
+	}
+	if !reflect.DeepEqual(w.ResourceNames, []string{"default.default-plugin"}) || len(w.OrphanedResources) != 0 {
+		t.Errorf("got watched %v and orphaned %v", w.ResourceNames, w.OrphanedResources)
+	}
+}
@@ -285,7 +285,9 @@ func (s *DiscoveryServer) generateEndpoints(b EndpointBuilder) *endpoint.Cluster
 	// Failover should only be enabled when there is an outlier detection, otherwise Envoy
 	// will never detect the hosts are unhealthy and redirect traffic.
 	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
-	lbSetting := loadbalancer.GetLocalityLbSetting(b.push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+	// Modified by ingress
+	lbSetting := loadbalancer.GetLocalityLbSetting(loadbalancer.GetMeshLocalityLbSetting(b.push.Mesh, b.hostname), lb.GetLocalityLbSetting())
+	// End modified by ingress
 	if lbSetting != nil {
 		// Make a shallow copy of the cla as we are mutating the endpoints with priorities/weights relative to the calling proxy
 		l = util.CloneClusterLoadAssignment(l)
@@ -336,7 +338,8 @@ func edsNeedsPush(updates model.XdsUpdates) bool {
 }
 
 func (eds *EdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
-	if !edsNeedsPush(req.ConfigsUpdated) {
+	// Modified by Ingress
+	if !edsNeedsPush(req.ConfigsUpdated) && !gatewayRoutesUpdated(proxy, req) {
 		return nil, model.DefaultXdsLogDetails, nil
 	}
 	resources, logDetails := eds.buildEndpoints(proxy, req, w)
@@ -399,10 +402,12 @@ func buildEmptyClusterLoadAssignment(clusterName string) *endpoint.ClusterLoadAs
 func (eds *EdsGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
 	w *model.WatchedResource,
 ) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
-	if !edsNeedsPush(req.ConfigsUpdated) {
+	// Modified by Ingress
+	routesUpdated := gatewayRoutesUpdated(proxy, req)
+	if !edsNeedsPush(req.ConfigsUpdated) && !routesUpdated {
 		return nil, nil, model.DefaultXdsLogDetails, false, nil
 	}
-	if !shouldUseDeltaEds(req) {
+	if !shouldUseDeltaEds(req) || routesUpdated {
 		resources, logDetails := eds.buildEndpoints(proxy, req, w)
 		return resources, nil, logDetails, false, nil
 	}
@@ -449,9 +454,13 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 	// ConfigsUpdated=ALL, so in this case we would not enable a partial push.
 	// Despite this code existing on the SotW code path, sending these partial pushes is still allowed;
 	// see https://www.envoyproxy.io/docs/envoy/latest/api-docs/xds_protocol#grouping-resources-into-responses
-	if !req.Full || canSendPartialFullPushes(req) {
+	// Modified by Ingress
+	if (!req.Full || canSendPartialFullPushes(req)) && !gatewayRoutesUpdated(proxy, req) {
 		edsUpdatedServices = model.ConfigNamesOfKind(req.ConfigsUpdated, kind.ServiceEntry)
 	}
+	// Added by Ingress
+	filter := newGatewayEndpointFilter(proxy, req.Push)
+	// End added by Ingress
 	var resources model.Resources
 	empty := 0
 	cached := 0
@@ -466,6 +475,16 @@ func (eds *EdsGenerator) buildEndpoints(proxy *model.Proxy,
 			}
 		}
 		builder := NewEndpointBuilder(clusterName, proxy, req.Push)
+		// Added by Ingress
+		if !filter.referenced(&builder) {
+			// Clusters the gateway does not route to only need their endpoints once, empty.
+			if edsUpdatedServices == nil {
+				resources = append(resources, unreferencedEndpoints(clusterName))
+				empty++
+			}
+			continue
+		}
+		// End added by Ingress
 
 		// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
 		if !features.EnableUnsafeAssertions {
@@ -508,6 +527,9 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 	w *model.WatchedResource,
 ) (model.Resources, []string, model.XdsLogDetails) {
 	edsUpdatedServices := model.ConfigNamesOfKind(req.ConfigsUpdated, kind.ServiceEntry)
+	// Added by Ingress
+	filter := newGatewayEndpointFilter(proxy, req.Push)
+	// End added by Ingress
 	var resources model.Resources
 	var removed []string
 	empty := 0
@@ -527,6 +549,11 @@ func (eds *EdsGenerator) buildDeltaEndpoints(proxy *model.Proxy,
 			removed = append(removed, clusterName)
 			continue
 		}
+		// Added by Ingress
+		if !filter.referenced(&builder) {
+			continue
+		}
+		// End added by Ingress
 
 		// We skip cache if assertions are enabled, so that the cache will assert our eviction logic is correct
 		if !features.EnableUnsafeAssertions {
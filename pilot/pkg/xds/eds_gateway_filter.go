@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// gatewayEndpointFilter scopes the endpoints generated for a gateway to the services its routes reference.
+// The clusters of the other services get no endpoints, as the gateway never sends traffic to them.
+type gatewayEndpointFilter struct {
+	hosts sets.String
+}
+
+// newGatewayEndpointFilter returns the filter of the endpoints generated for the proxy, or nil if they are not
+// filtered.
+func newGatewayEndpointFilter(proxy *model.Proxy, push *model.PushContext) *gatewayEndpointFilter {
+	if !alifeatures.FilterGatewayEndpoints || proxy.Type != model.Router || push == nil {
+		return nil
+	}
+	return &gatewayEndpointFilter{hosts: push.GatewayReferencedHosts(proxy)}
+}
+
+// referenced returns true if the endpoints of the cluster are generated.
+func (f *gatewayEndpointFilter) referenced(b *EndpointBuilder) bool {
+	if f == nil || b.service == nil {
+		return true
+	}
+	if alifeatures.EnablePushAllMcpClusters && b.service.Attributes.Namespace == "mcp" {
+		return true
+	}
+	return f.hosts.Contains(string(b.hostname))
+}
+
+// unreferencedEndpoints returns the empty endpoints sent for a cluster the gateway does not route to, so the
+// cluster does not wait for its endpoints to warm.
+func unreferencedEndpoints(clusterName string) *discovery.Resource {
+	return &discovery.Resource{
+		Name:     clusterName,
+		Resource: protoconv.MessageToAny(buildEmptyClusterLoadAssignment(clusterName)),
+	}
+}
+
+// gatewayRoutesUpdated returns true if the push may change the services the gateway routes to or its filters call.
+// All its endpoints are then generated again, while such updates do not trigger EDS pushes otherwise.
+func gatewayRoutesUpdated(proxy *model.Proxy, req *model.PushRequest) bool {
+	if !alifeatures.FilterGatewayEndpoints || proxy.Type != model.Router || !req.Full {
+		return false
+	}
+	for key := range req.ConfigsUpdated {
+		if key.Kind == kind.VirtualService || key.Kind == kind.Gateway || key.Kind == kind.WasmPlugin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const gatewayEndpointsConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: default
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: routed
+  namespace: default
+spec:
+  hosts:
+  - "*"
+  gateways:
+  - gateway
+  http:
+  - route:
+    - destination:
+        host: routed.example.com
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: routed
+  namespace: default
+spec:
+  hosts:
+  - routed.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: unrouted
+  namespace: default
+spec:
+  hosts:
+  - unrouted.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.2
+`
+
+func TestGatewayEndpointFilter(t *testing.T) {
+	test.SetForTest(t, &alifeatures.FilterGatewayEndpoints, true)
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: gatewayEndpointsConfig})
+	proxy := s.SetupProxy(&model.Proxy{
+		Type:   model.Router,
+		Labels: map[string]string{"istio": "ingressgateway"},
+		Metadata: &model.NodeMetadata{
+			Labels: map[string]string{"istio": "ingressgateway"},
+		},
+	})
+	routed := "outbound|80||routed.example.com"
+	unrouted := "outbound|80||unrouted.example.com"
+	w := &model.WatchedResource{TypeUrl: v3.EndpointType, ResourceNames: []string{routed, unrouted}}
+	gen := s.Discovery.Generators[v3.EndpointType]
+
+	endpoints := func(req *model.PushRequest) map[string][]string {
+		req.Push = s.PushContext()
+		res, _, err := gen.Generate(proxy, w, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cla := make([]*endpoint.ClusterLoadAssignment, 0, len(res))
+		for _, r := range res {
+			cla = append(cla, xdstest.UnmarshalAny[endpoint.ClusterLoadAssignment](t, r.Resource))
+		}
+		return xdstest.ExtractLoadAssignments(cla)
+	}
+
+	got := endpoints(&model.PushRequest{Full: true})
+	if len(got[routed]) != 1 {
+		t.Fatalf("expected the endpoints of the routed service, got %v", got)
+	}
+	if eps, f := got[unrouted]; !f || len(eps) != 0 {
+		t.Fatalf("expected no endpoints for the service not routed to, got %v", got)
+	}
+
+	got = endpoints(&model.PushRequest{Full: false, ConfigsUpdated: sets.New(
+		model.ConfigKey{Kind: kind.ServiceEntry, Name: "unrouted.example.com", Namespace: "default"})})
+	if len(got) != 0 {
+		t.Fatalf("expected no push for the service not routed to, got %v", got)
+	}
+
+	got = endpoints(&model.PushRequest{Full: true, ConfigsUpdated: sets.New(
+		model.ConfigKey{Kind: kind.VirtualService, Name: "routed", Namespace: "default"})})
+	if len(got) != 2 {
+		t.Fatalf("expected all the endpoints to be generated when routes change, got %v", got)
+	}
+
+	sidecar := s.SetupProxy(nil)
+	res, _, _ := gen.Generate(sidecar, w, &model.PushRequest{Full: true, Push: s.PushContext()})
+	for _, r := range res {
+		if len(xdstest.UnmarshalAny[endpoint.ClusterLoadAssignment](t, r.Resource).Endpoints) == 0 {
+			t.Fatalf("expected the endpoints of sidecars not to be filtered, got none for %s", r.Name)
+		}
+	}
+}
+
+func TestGatewayReferencedHosts(t *testing.T) {
+	test.SetForTest(t, &alifeatures.RateLimitServiceAddress, "rls.example.com:8081")
+	config := strings.Replace(gatewayEndpointsConfig, "  name: gateway\n", `  name: gateway
+  annotations:
+    higress.io/oidc: '{"authorizationEndpoint": "https://idp.example.com/authorize",
+      "tokenEndpoint": "https://unrouted.example.com/token", "clientID": "gateway", "credentialName": "oidc"}'
+`, 1)
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: config})
+	proxy := s.SetupProxy(&model.Proxy{
+		Type:   model.Router,
+		Labels: map[string]string{"istio": "ingressgateway"},
+		Metadata: &model.NodeMetadata{
+			Labels: map[string]string{"istio": "ingressgateway"},
+		},
+	})
+	push := s.PushContext()
+	hosts := push.GatewayReferencedHosts(proxy)
+	for _, host := range []string{"routed.example.com", "unrouted.example.com", "rls.example.com"} {
+		if !hosts.Contains(host) {
+			t.Errorf("got hosts %v, want %s", hosts, host)
+		}
+	}
+	// The hosts are computed once per push.
+	if again := push.GatewayReferencedHosts(proxy); reflect.ValueOf(again).Pointer() != reflect.ValueOf(hosts).Pointer() {
+		t.Errorf("expected the hosts to be cached for the push")
+	}
+}
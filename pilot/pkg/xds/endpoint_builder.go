@@ -235,7 +235,9 @@ func (e *LocalityEndpoints) AssertInvarianceInTest() {
 func (b *EndpointBuilder) populateFailoverPriorityLabels() {
 	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
 	if enableFailover {
-		lbSetting := loadbalancer.GetLocalityLbSetting(b.push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+		// Modified by ingress
+		lbSetting := loadbalancer.GetLocalityLbSetting(loadbalancer.GetMeshLocalityLbSetting(b.push.Mesh, b.hostname), lb.GetLocalityLbSetting())
+		// End modified by ingress
 		if lbSetting != nil && lbSetting.Distribute == nil &&
 			len(lbSetting.FailoverPriority) > 0 && (lbSetting.Enabled == nil || lbSetting.Enabled.Value) {
 			b.failoverPriorityLabels = util.GetFailoverPriorityLabels(b.proxy.Labels, lbSetting.FailoverPriority)
@@ -21,6 +21,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"strings"
 	"time"
 
@@ -458,6 +459,30 @@ func (f *FakeDiscoveryServer) Endpoints(p *model.Proxy) []*endpoint.ClusterLoadA
 	return loadAssignments
 }
 
+// LoadSnapshot makes the server send the resources of a snapshot, as exported by /debug/snapshot, instead of
+// generating them, to replay the configs of a proxy offline. It must be called before proxies connect.
+func (f *FakeDiscoveryServer) LoadSnapshot(snap *Snapshot) {
+	for typeURL, res := range snap.Resources {
+		f.Discovery.Generators[typeURL] = &snapshotGenerator{resources: res}
+	}
+}
+
+// LoadSnapshotFile reads a snapshot archive from a file, and loads it with LoadSnapshot.
+func (f *FakeDiscoveryServer) LoadSnapshotFile(path string) *Snapshot {
+	f.t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		f.t.Fatalf("failed to open snapshot: %v", err)
+	}
+	defer file.Close()
+	snap, err := ReadSnapshot(file)
+	if err != nil {
+		f.t.Fatalf("failed to read snapshot %s: %v", path, err)
+	}
+	f.LoadSnapshot(snap)
+	return snap
+}
+
 // EnsureSynced checks that all ConfigUpdates sent have been established
 // This does NOT ensure that the change has been sent to all proxies; only that PushContext is updated
 // Typically, if trying to ensure changes are sent, its better to wait for the push event.
@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// generationLimiter limits the rate at which connections get their initial configs generated, both per node ID
+// and globally, so a proxy reconnecting in a loop, or a storm of reconnections, can not use all the CPU of pilot.
+type generationLimiter struct {
+	// global limits the generations of all the proxies. It is nil if disabled.
+	global *rate.Limiter
+
+	// limit and burst configure the limiter of each proxy. A zero limit disables them.
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	proxies   map[string]*proxyLimiter
+	lastPrune time.Time
+}
+
+type proxyLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newGenerationLimiter creates a generationLimiter. A zero or negative rate disables the matching limiter.
+func newGenerationLimiter(globalLimit float64, globalBurst int, proxyLimit float64, proxyBurst int) *generationLimiter {
+	l := &generationLimiter{
+		proxies: map[string]*proxyLimiter{},
+	}
+	if globalBurst < 1 {
+		globalBurst = 1
+	}
+	if proxyBurst < 1 {
+		proxyBurst = 1
+	}
+	if globalLimit > 0 {
+		l.global = rate.NewLimiter(rate.Limit(globalLimit), globalBurst)
+	}
+	if proxyLimit > 0 {
+		l.limit = rate.Limit(proxyLimit)
+		l.burst = proxyBurst
+	}
+	return l
+}
+
+// wait returns once the node is allowed a generation. A node over its own limit is rejected right away, since
+// it is reconnecting in a loop. Otherwise it waits for the global limit for a bit, and is rejected if the wait
+// is too long, so it can reconnect to another instance or retry with backoff.
+func (l *generationLimiter) wait(ctx context.Context, nodeID string) error {
+	if l == nil {
+		return nil
+	}
+	if !l.allowProxy(nodeID, time.Now()) {
+		generationRateLimitedProxyRejected.Increment()
+		return status.Errorf(codes.ResourceExhausted, "generation rate limit exceeded for node %s", nodeID)
+	}
+	if l.global == nil || l.global.Allow() {
+		return nil
+	}
+	generationRateLimitedGlobalDelayed.Increment()
+	wait, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := l.global.Wait(wait); err != nil {
+		generationRateLimitedGlobalRejected.Increment()
+		return status.Errorf(codes.ResourceExhausted, "generation rate limit exceeded: %v", err)
+	}
+	return nil
+}
+
+// allowProxy consumes a token of the limiter of the node, and returns false if there is none left.
+func (l *generationLimiter) allowProxy(nodeID string, now time.Time) bool {
+	if l.limit == 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	p, f := l.proxies[nodeID]
+	if !f {
+		p = &proxyLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.proxies[nodeID] = p
+	}
+	p.lastSeen = now
+	return p.limiter.AllowN(now, 1)
+}
+
+// prune forgets the limiters of the nodes idle for long enough to have a full bucket again, as these behave
+// like new ones. It runs at most once a minute.
+func (l *generationLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	refill := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for nodeID, p := range l.proxies {
+		if now.Sub(p.lastSeen) > refill {
+			delete(l.proxies, nodeID)
+		}
+	}
+}
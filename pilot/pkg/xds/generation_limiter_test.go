@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGenerationLimiterProxy(t *testing.T) {
+	l := newGenerationLimiter(0, 0, 1, 2)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !l.allowProxy("gateway-1", now) {
+			t.Fatalf("expected connection %d within the burst to be allowed", i)
+		}
+	}
+	if l.allowProxy("gateway-1", now) {
+		t.Fatalf("expected connection beyond the burst to be rejected")
+	}
+	if !l.allowProxy("gateway-2", now) {
+		t.Fatalf("expected another node not to be limited")
+	}
+	if !l.allowProxy("gateway-1", now.Add(time.Second)) {
+		t.Fatalf("expected connection to be allowed once the bucket refilled")
+	}
+
+	// Idle nodes are forgotten.
+	l.allowProxy("gateway-1", now.Add(2*time.Minute))
+	if _, f := l.proxies["gateway-2"]; f {
+		t.Fatalf("expected the limiter of the idle node to be pruned")
+	}
+	if _, f := l.proxies["gateway-1"]; !f {
+		t.Fatalf("expected the limiter of the active node to be kept")
+	}
+}
+
+func TestGenerationLimiterWait(t *testing.T) {
+	var disabled *generationLimiter
+	if err := disabled.wait(context.Background(), "gateway"); err != nil {
+		t.Fatalf("expected no limit, got %v", err)
+	}
+	if err := newGenerationLimiter(0, 0, 0, 0).wait(context.Background(), "gateway"); err != nil {
+		t.Fatalf("expected no limit, got %v", err)
+	}
+
+	l := newGenerationLimiter(0, 0, 0.001, 1)
+	if err := l.wait(context.Background(), "gateway"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.wait(context.Background(), "gateway"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected node to be rejected, got %v", err)
+	}
+
+	// The global limiter rejects connections which would wait for more than a second.
+	l = newGenerationLimiter(0.001, 1, 0, 0)
+	if err := l.wait(context.Background(), "gateway-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.wait(context.Background(), "gateway-2"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected connection to be rejected, got %v", err)
+	}
+}
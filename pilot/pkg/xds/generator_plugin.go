@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// GeneratorPlugin adds a generator to every discovery server, so downstream builds can serve their own types,
+// or replace the generator of a type, without patching InitGenerators.
+type GeneratorPlugin struct {
+	// TypeURL is the type served by the generator. If empty, the generator is the default generator of the
+	// proxies selecting it with their GENERATOR node metadata.
+	TypeURL string
+	// Generator, if set, restricts the generator to the proxies whose GENERATOR node metadata has this value.
+	// Otherwise the generator serves the type to all the proxies, replacing the built-in one if any.
+	Generator string
+	// New builds the generator for a discovery server. It is called once the built-in generators are set, and
+	// may return nil to skip the plugin. The generator may implement GeneratorCache and GeneratorRunner.
+	New func(s *DiscoveryServer, env *model.Environment) model.XdsResourceGenerator
+}
+
+// GeneratorCache is implemented by plugin generators keeping their own cache, so it is invalidated along with
+// the XDS cache.
+type GeneratorCache interface {
+	// Clear drops the entries depending on the updated configs.
+	Clear(configs sets.Set[model.ConfigKey])
+	// ClearAll drops all the entries.
+	ClearAll()
+}
+
+// GeneratorRunner is implemented by plugin generators doing background work, which is run along with the
+// discovery server.
+type GeneratorRunner interface {
+	Run(stop <-chan struct{})
+}
+
+var (
+	generatorPlugins      []GeneratorPlugin
+	generatorPluginsMutex sync.RWMutex
+)
+
+// RegisterGeneratorPlugin globally registers a generator plugin, typically from an init function. Plugins are
+// applied to the discovery servers initializing their generators afterwards, in registration order.
+func RegisterGeneratorPlugin(p GeneratorPlugin) {
+	generatorPluginsMutex.Lock()
+	defer generatorPluginsMutex.Unlock()
+	generatorPlugins = append(generatorPlugins, p)
+}
+
+// key returns the key of the generator of the plugin in DiscoveryServer.Generators, as looked up by findGenerator.
+func (p GeneratorPlugin) key() string {
+	switch {
+	case p.Generator == "":
+		return p.TypeURL
+	case p.TypeURL == "":
+		return p.Generator
+	default:
+		return p.Generator + "/" + p.TypeURL
+	}
+}
+
+// initGeneratorPlugins builds the generators of the registered plugins.
+func (s *DiscoveryServer) initGeneratorPlugins(env *model.Environment) {
+	generatorPluginsMutex.RLock()
+	plugins := append([]GeneratorPlugin(nil), generatorPlugins...)
+	generatorPluginsMutex.RUnlock()
+
+	s.pluginGenerators = nil
+	for _, p := range plugins {
+		gen := p.New(s, env)
+		if gen == nil {
+			continue
+		}
+		key := p.key()
+		s.Generators[key] = instrumentGenerator(key, gen)
+		s.pluginGenerators = append(s.pluginGenerators, gen)
+		log.Infof("registered generator plugin %s", key)
+	}
+}
+
+// clearPluginCaches invalidates the caches of the plugin generators. No updated configs clears them all.
+func (s *DiscoveryServer) clearPluginCaches(configs sets.Set[model.ConfigKey]) {
+	for _, gen := range s.pluginGenerators {
+		c, ok := gen.(GeneratorCache)
+		if !ok {
+			continue
+		}
+		if len(configs) == 0 {
+			c.ClearAll()
+		} else {
+			c.Clear(configs)
+		}
+	}
+}
+
+// runPluginGenerators runs the background work of the plugin generators.
+func (s *DiscoveryServer) runPluginGenerators(stopCh <-chan struct{}) {
+	for _, gen := range s.pluginGenerators {
+		if r, ok := gen.(GeneratorRunner); ok {
+			go r.Run(stopCh)
+		}
+	}
+}
+
+// instrumentGenerator wraps a plugin generator to record its generations, keeping whether it supports deltas.
+func instrumentGenerator(key string, gen model.XdsResourceGenerator) model.XdsResourceGenerator {
+	g := &pluginGenerator{gen: gen, tag: generatorTag.Value(key)}
+	if dg, ok := gen.(model.XdsDeltaResourceGenerator); ok {
+		return &pluginDeltaGenerator{pluginGenerator: g, delta: dg}
+	}
+	return g
+}
+
+type pluginGenerator struct {
+	gen model.XdsResourceGenerator
+	tag monitoring.LabelValue
+}
+
+func (g *pluginGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	t0 := time.Now()
+	res, logs, err := g.gen.Generate(proxy, w, req)
+	g.record(t0, err)
+	return res, logs, err
+}
+
+func (g *pluginGenerator) record(t0 time.Time, err error) {
+	pluginGenerationTime.With(g.tag).Record(time.Since(t0).Seconds())
+	if err != nil {
+		pluginGenerationErrors.With(g.tag).Increment()
+	}
+}
+
+type pluginDeltaGenerator struct {
+	*pluginGenerator
+	delta model.XdsDeltaResourceGenerator
+}
+
+func (g *pluginDeltaGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
+	w *model.WatchedResource,
+) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	t0 := time.Now()
+	res, deleted, logs, usedDelta, err := g.delta.GenerateDeltas(proxy, req, w)
+	g.record(t0, err)
+	return res, deleted, logs, usedDelta, err
+}
@@ -0,0 +1,106 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const customType = "type.googleapis.com/higress.custom"
+
+type fakePluginGenerator struct {
+	generated int
+	cleared   []sets.Set[model.ConfigKey]
+}
+
+func (g *fakePluginGenerator) Generate(*model.Proxy, *model.WatchedResource, *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	g.generated++
+	return model.Resources{&discovery.Resource{Name: "custom"}}, model.DefaultXdsLogDetails, nil
+}
+
+func (g *fakePluginGenerator) Clear(configs sets.Set[model.ConfigKey]) {
+	g.cleared = append(g.cleared, configs)
+}
+
+func (g *fakePluginGenerator) ClearAll() {
+	g.cleared = append(g.cleared, nil)
+}
+
+func registerTestGeneratorPlugin(t *testing.T, p GeneratorPlugin) {
+	generatorPluginsMutex.Lock()
+	previous := generatorPlugins
+	generatorPluginsMutex.Unlock()
+	t.Cleanup(func() {
+		generatorPluginsMutex.Lock()
+		generatorPlugins = previous
+		generatorPluginsMutex.Unlock()
+	})
+	RegisterGeneratorPlugin(p)
+}
+
+func TestGeneratorPlugin(t *testing.T) {
+	gen := &fakePluginGenerator{}
+	registerTestGeneratorPlugin(t, GeneratorPlugin{
+		TypeURL:   customType,
+		Generator: "higress",
+		New: func(*DiscoveryServer, *model.Environment) model.XdsResourceGenerator {
+			return gen
+		},
+	})
+	registerTestGeneratorPlugin(t, GeneratorPlugin{
+		TypeURL: v3.ClusterType,
+		New: func(*DiscoveryServer, *model.Environment) model.XdsResourceGenerator {
+			return nil
+		},
+	})
+	s := &DiscoveryServer{
+		Generators: map[string]model.XdsResourceGenerator{v3.ClusterType: &CdsGenerator{}},
+		Cache:      model.DisabledCache{},
+	}
+	s.initGeneratorPlugins(nil)
+
+	if _, ok := s.Generators[v3.ClusterType].(*CdsGenerator); !ok {
+		t.Fatalf("expected a plugin returning no generator to be skipped")
+	}
+	selected := &Connection{proxy: &model.Proxy{Metadata: &model.NodeMetadata{Generator: "higress"}}}
+	g := s.findGenerator(customType, selected)
+	if _, ok := g.(*pluginGenerator); !ok {
+		t.Fatalf("expected the plugin generator for the proxies selecting it, got %T", g)
+	}
+	if _, ok := g.(model.XdsDeltaResourceGenerator); ok {
+		t.Fatalf("plugin generator must not support deltas unless the generator does")
+	}
+	if _, _, err := g.Generate(selected.proxy, nil, &model.PushRequest{}); err != nil || gen.generated != 1 {
+		t.Fatalf("expected generation to be delegated to the plugin, got %d generations and error %v", gen.generated, err)
+	}
+	other := &Connection{proxy: &model.Proxy{Metadata: &model.NodeMetadata{}}}
+	if g := s.findGenerator(customType, other); g == s.Generators["higress/"+customType] {
+		t.Fatalf("expected the plugin generator not to serve other proxies")
+	}
+
+	updated := sets.New(model.ConfigKey{Kind: kind.WasmPlugin, Name: "auth", Namespace: "higress-system"})
+	s.dropCacheForRequest(&model.PushRequest{ConfigsUpdated: updated})
+	s.dropCacheForRequest(&model.PushRequest{})
+	if len(gen.cleared) != 2 || !gen.cleared[0].Equals(updated) || gen.cleared[1] != nil {
+		t.Fatalf("expected the plugin cache to be cleared with the XDS cache, got %v", gen.cleared)
+	}
+}
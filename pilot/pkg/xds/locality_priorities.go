@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+	"istio.io/istio/pilot/pkg/networking/util"
+)
+
+// LocalityPriorities are the priorities and weights of the localities of the endpoints of a cluster, as computed
+// for a proxy.
+type LocalityPriorities struct {
+	Cluster string `json:"cluster"`
+	// Source is where the locality load balancer setting of the cluster comes from, one of destinationrule,
+	// service or mesh. It is empty if the cluster has none.
+	Source string `json:"source,omitempty"`
+	// Failover is true if the cluster fails over between priorities, which needs outlier detection.
+	Failover   bool               `json:"failover"`
+	Localities []LocalityPriority `json:"localities"`
+}
+
+// LocalityPriority is the priority of the endpoints of a locality. Endpoints of a locality may have different
+// priorities when failing over by labels.
+type LocalityPriority struct {
+	Locality  string `json:"locality"`
+	Priority  uint32 `json:"priority"`
+	Weight    uint32 `json:"weight,omitempty"`
+	Endpoints int    `json:"endpoints"`
+}
+
+// localityPriorities returns the priority map of the EDS clusters of the proxy, or of a single cluster if the
+// cluster query parameter is set.
+// It is mapped to /debug/locality_priorities
+func (s *DiscoveryServer) localityPriorities(w http.ResponseWriter, req *http.Request) {
+	proxyID, con := s.getDebugConnection(req)
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+	clusters := con.Clusters()
+	if c := req.URL.Query().Get("cluster"); c != "" {
+		clusters = []string{c}
+	}
+	out := make([]LocalityPriorities, 0, len(clusters))
+	for _, clusterName := range clusters {
+		out = append(out, s.clusterLocalityPriorities(NewEndpointBuilder(clusterName, con.proxy, con.proxy.LastPushContext)))
+	}
+	writeJSON(w, out, req)
+}
+
+func (s *DiscoveryServer) clusterLocalityPriorities(b EndpointBuilder) LocalityPriorities {
+	out := LocalityPriorities{Cluster: b.clusterName, Localities: []LocalityPriority{}}
+	if b.push != nil {
+		failover, lb := getOutlierDetectionAndLoadBalancerSettings(b.DestinationRule(), b.port, b.subsetName)
+		out.Source = loadbalancer.LocalityLbSettingSource(b.push.Mesh, b.hostname, lb.GetLocalityLbSetting())
+		out.Failover = failover && out.Source != ""
+	}
+	cla := s.generateEndpoints(b)
+	for _, ep := range cla.GetEndpoints() {
+		if len(ep.LbEndpoints) == 0 {
+			continue
+		}
+		out.Localities = append(out.Localities, LocalityPriority{
+			Locality:  util.LocalityToString(ep.Locality),
+			Priority:  ep.Priority,
+			Weight:    ep.GetLoadBalancingWeight().GetValue(),
+			Endpoints: len(ep.LbEndpoints),
+		})
+	}
+	sort.SliceStable(out.Localities, func(i, j int) bool {
+		if out.Localities[i].Priority != out.Localities[j].Priority {
+			return out.Localities[i].Priority < out.Localities[j].Priority
+		}
+		return out.Localities[i].Locality < out.Localities[j].Locality
+	})
+	return out
+}
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
+)
+
+const localityConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: svc
+  namespace: default
+spec:
+  hosts:
+  - svc.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: STATIC
+  endpoints:
+  - address: 10.0.0.1
+    locality: us-east/zone-a
+  - address: 10.0.0.2
+    locality: us-east/zone-b
+  - address: 10.0.0.3
+    locality: us-west/zone-a
+  - address: 10.0.0.4
+    locality: eu-west/zone-a
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: svc
+  namespace: default
+spec:
+  host: svc.example.com
+  trafficPolicy:
+    outlierDetection:
+      consecutive5xxErrors: 5
+    loadBalancer:
+      localityLbSetting:
+        failover:
+        - from: us-east
+          to: eu-west
+`
+
+func TestLocalityPriorities(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: localityConfig})
+	proxy := s.SetupProxy(&model.Proxy{Locality: &core.Locality{Region: "us-east", Zone: "zone-a"}})
+
+	got := s.Discovery.clusterLocalityPriorities(NewEndpointBuilder("outbound|80||svc.example.com", proxy, s.PushContext()))
+	if got.Source != loadbalancer.SettingSourceDestinationRule || !got.Failover {
+		t.Fatalf("unexpected setting source %q or failover %v", got.Source, got.Failover)
+	}
+	want := []LocalityPriority{
+		{Locality: "us-east/zone-a", Priority: 0, Endpoints: 1},
+		{Locality: "us-east/zone-b", Priority: 1, Endpoints: 1},
+		{Locality: "eu-west/zone-a", Priority: 2, Endpoints: 1},
+		{Locality: "us-west/zone-a", Priority: 3, Endpoints: 1},
+	}
+	for i := range got.Localities {
+		got.Localities[i].Weight = 0
+	}
+	if !reflect.DeepEqual(got.Localities, want) {
+		t.Fatalf("got priorities %+v, want %+v", got.Localities, want)
+	}
+
+	got = s.Discovery.clusterLocalityPriorities(NewEndpointBuilder("outbound|80||unknown.example.com", proxy, s.PushContext()))
+	// The default mesh config enables locality load balancing, but there is no outlier detection to fail over.
+	if got.Source != loadbalancer.SettingSourceMesh || got.Failover || len(got.Localities) != 0 {
+		t.Fatalf("expected no priority for an unknown service, got %+v", got)
+	}
+}
@@ -55,6 +55,81 @@ var (
 		"Pilot rejected RDS.",
 	)
 
+	// Added by Ingress
+	sdsReject = monitoring.NewGauge(
+		"pilot_xds_sds_reject",
+		"Pilot rejected SDS.",
+	)
+
+	sdsNackFallbacks = monitoring.NewSum(
+		"pilot_xds_sds_nack_fallbacks",
+		"Total number of rejected secrets sent back to proxies at their previous ACKed version.",
+	)
+
+	limiterTag = monitoring.CreateLabel("limiter")
+	resultTag  = monitoring.CreateLabel("result")
+
+	generationRateLimited = monitoring.NewSum(
+		"pilot_xds_generation_rate_limited",
+		"Total number of XDS connections delayed or rejected by the generation rate limits, by limiter.",
+	)
+
+	generationRateLimitedProxyRejected  = generationRateLimited.With(limiterTag.Value("proxy"), resultTag.Value("rejected"))
+	generationRateLimitedGlobalDelayed  = generationRateLimited.With(limiterTag.Value("global"), resultTag.Value("delayed"))
+	generationRateLimitedGlobalRejected = generationRateLimited.With(limiterTag.Value("global"), resultTag.Value("rejected"))
+
+	generatorTag = monitoring.CreateLabel("generator")
+
+	pluginGenerationTime = monitoring.NewDistribution(
+		"pilot_xds_plugin_generation_seconds",
+		"Time in seconds the generators registered as plugins take to generate, by generator.",
+		[]float64{.001, .01, .1, 1, 5},
+	)
+
+	pluginGenerationErrors = monitoring.NewSum(
+		"pilot_xds_plugin_generation_errors",
+		"Total number of failed generations of the generators registered as plugins, by generator.",
+	)
+
+	nackQuarantined = monitoring.NewGauge(
+		"pilot_xds_nack_quarantined_resources",
+		"Number of resource versions quarantined after being rejected by proxies.",
+	)
+
+	nackQuarantineWithheld = monitoring.NewSum(
+		"pilot_xds_nack_quarantine_withheld",
+		"Total number of quarantined resources withheld from pushes, by type.",
+	)
+
+	outlierEjectionEvents = monitoring.NewSum(
+		"pilot_outlier_ejection_events",
+		"Total number of host ejections reported by the outlier detection of the gateways.",
+	)
+
+	pushAuditDropped = monitoring.NewSum(
+		"pilot_push_audit_dropped",
+		"Total number of push audit records dropped as too many were waiting to be written.",
+	)
+
+	pushAuditErrors = monitoring.NewSum(
+		"pilot_push_audit_errors",
+		"Total number of push audit records which could not be written.",
+	)
+
+	standbyResponses = monitoring.NewSum(
+		"pilot_xds_standby_responses",
+		"Total number of types requested by proxies in warm standby, by whether a cached response was found.",
+	)
+
+	standbyResponseHits   = standbyResponses.With(resultTag.Value("hit"))
+	standbyResponseMisses = standbyResponses.With(resultTag.Value("miss"))
+
+	pushSizeBudgetExceeded = monitoring.NewSum(
+		"pilot_xds_push_size_budget_exceeded",
+		"Total number of pushes larger than the budget of their type, by type.",
+	)
+	// End added by Ingress
+
 	totalXDSRejects = monitoring.NewSum(
 		"pilot_total_xds_rejects",
 		"Total number of XDS responses from pilot rejected by proxy.",
@@ -156,6 +231,22 @@ var (
 		"Total number of failures to fetch SDS key and certificate.",
 	)
 
+	// Added by ingress
+	sdsAuthorizationTime = monitoring.NewDistribution(
+		"pilot_sds_authorization_time",
+		"Time in seconds taken to authorize a proxy to read the secrets it requested, once per SDS generation. "+
+			"Authorizations are cached, and the concurrent ones of a service account share a single review.",
+		[]float64{.001, .01, .05, .1, .5, 1, 5},
+	)
+
+	reasonTag = monitoring.CreateLabel("reason")
+
+	pilotSDSRejectedSecrets = monitoring.NewSum(
+		"pilot_sds_rejected_secrets_total",
+		"Total number of secrets not sent to proxies as too large or invalid, labeled by reason.",
+	)
+	// End added by ingress
+
 	inboundConfigUpdates  = inboundUpdates.With(typeTag.Value("config"))
 	inboundEDSUpdates     = inboundUpdates.With(typeTag.Value("eds"))
 	inboundServiceUpdates = inboundUpdates.With(typeTag.Value("svc"))
@@ -245,6 +336,10 @@ func incrementXDSRejects(xdsType string, node, errCode string) {
 		edsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
 	case v3.RouteType:
 		rdsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+	// Added by Ingress
+	case v3.SecretType:
+		sdsReject.With(nodeTag.Value(node), errTag.Value(errCode)).Increment()
+		// End added by Ingress
 	}
 }
 
@@ -0,0 +1,328 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// maxQuarantineProxies bounds the number of proxies recorded as rejecting a resource.
+const maxQuarantineProxies = 10
+
+// QuarantinedResource describes a version of a resource rejected by proxies, and whether it is quarantined.
+type QuarantinedResource struct {
+	TypeURL string `json:"typeUrl"`
+	Name    string `json:"name"`
+	// Version is the hash of the rejected resource.
+	Version string `json:"version"`
+	Nacks   int    `json:"nacks"`
+	// Proxies are some of the proxies which rejected the resource.
+	Proxies     []string  `json:"proxies"`
+	Message     string    `json:"message"`
+	FirstNack   time.Time `json:"firstNack"`
+	LastNack    time.Time `json:"lastNack"`
+	Quarantined bool      `json:"quarantined"`
+}
+
+type quarantineKey struct {
+	typeURL string
+	name    string
+	version string
+}
+
+// nackQuarantine counts the rejections of each version of the resources, and quarantines the versions
+// rejected too many times: these are withheld from the proxies, which keep their previous ACKed version,
+// instead of the whole type being stuck on the rejection.
+type nackQuarantine struct {
+	threshold int
+	duration  time.Duration
+
+	mu        sync.RWMutex
+	resources map[quarantineKey]*QuarantinedResource
+	// quarantined holds, by type, the names of the resources with a quarantined version, so pushes only hash
+	// the resources which may be quarantined.
+	quarantined map[string]sets.String
+	// count is the number of quarantined versions.
+	count int
+}
+
+// newNackQuarantine creates a nackQuarantine quarantining a version once rejected threshold times, for the
+// given duration since its last rejection. It returns nil if the threshold is not positive.
+func newNackQuarantine(threshold int, duration time.Duration) *nackQuarantine {
+	if threshold <= 0 {
+		return nil
+	}
+	return &nackQuarantine{
+		threshold:   threshold,
+		duration:    duration,
+		resources:   map[quarantineKey]*QuarantinedResource{},
+		quarantined: map[string]sets.String{},
+	}
+}
+
+// onNack records the rejection of resources by a proxy, and returns true if a resource got quarantined.
+func (q *nackQuarantine) onNack(proxyID, typeURL string, rejected model.Resources, message string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expire(now)
+	quarantined := false
+	for _, r := range rejected {
+		key := quarantineKey{typeURL: typeURL, name: r.Name, version: resourceVersion(r)}
+		qr, f := q.resources[key]
+		if !f {
+			qr = &QuarantinedResource{TypeURL: typeURL, Name: r.Name, Version: key.version, FirstNack: now}
+			q.resources[key] = qr
+		}
+		qr.Nacks++
+		qr.LastNack = now
+		qr.Message = message
+		if len(qr.Proxies) < maxQuarantineProxies && !slices.Contains(qr.Proxies, proxyID) {
+			qr.Proxies = append(qr.Proxies, proxyID)
+		}
+		if !qr.Quarantined && qr.Nacks >= q.threshold {
+			qr.Quarantined = true
+			sets.InsertOrNew(q.quarantined, typeURL, r.Name)
+			quarantined = true
+			q.count++
+			nackQuarantined.Record(float64(q.count))
+			log.Errorf("%s: quarantined resource %s version %s after %d rejections by proxies %v: %s",
+				v3.GetShortType(typeURL), r.Name, key.version, qr.Nacks, qr.Proxies, message)
+		}
+	}
+	return quarantined
+}
+
+// expire forgets the resources not rejected for the quarantine duration.
+func (q *nackQuarantine) expire(now time.Time) {
+	for key, qr := range q.resources {
+		if now.Sub(qr.LastNack) > q.duration {
+			q.remove(key, qr)
+		}
+	}
+}
+
+func (q *nackQuarantine) remove(key quarantineKey, qr *QuarantinedResource) {
+	delete(q.resources, key)
+	if !qr.Quarantined {
+		return
+	}
+	q.count--
+	nackQuarantined.Record(float64(q.count))
+	log.Infof("%s: resource %s version %s is no longer quarantined", v3.GetShortType(key.typeURL), key.name, key.version)
+	for other, oqr := range q.resources {
+		if other.typeURL == key.typeURL && other.name == key.name && oqr.Quarantined {
+			return
+		}
+	}
+	sets.DeleteCleanupLast(q.quarantined, key.typeURL, key.name)
+}
+
+// hasQuarantined returns true if a version of a resource of the type is quarantined.
+func (q *nackQuarantine) hasQuarantined(typeURL string) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return len(q.quarantined[typeURL]) > 0
+}
+
+// isQuarantined returns true if the version of the resource is quarantined.
+func (q *nackQuarantine) isQuarantined(typeURL string, r *discovery.Resource) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if !q.quarantined[typeURL].Contains(r.Name) {
+		return false
+	}
+	qr, f := q.resources[quarantineKey{typeURL: typeURL, name: r.Name, version: resourceVersion(r)}]
+	return f && qr.Quarantined
+}
+
+// list returns the rejected resources, quarantined or not.
+func (q *nackQuarantine) list() []QuarantinedResource {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	out := make([]QuarantinedResource, 0, len(q.resources))
+	for _, qr := range q.resources {
+		out = append(out, *qr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TypeURL != out[j].TypeURL {
+			return out[i].TypeURL < out[j].TypeURL
+		}
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].FirstNack.Before(out[j].FirstNack)
+	})
+	return out
+}
+
+// clear releases all the quarantined resources.
+func (q *nackQuarantine) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, qr := range q.resources {
+		q.remove(key, qr)
+	}
+}
+
+// sentResponse is the last response of a type sent to a proxy over SotW.
+type sentResponse struct {
+	nonce     string
+	resources model.Resources
+}
+
+// quarantineResources replaces the quarantined resources with the version last ACKed by the proxy, or withholds
+// them if the proxy never ACKed another version.
+func (s *DiscoveryServer) quarantineResources(con *Connection, typeURL string, res model.Resources) model.Resources {
+	if s.nackQuarantine == nil || typeURL == v3.SecretType || !s.nackQuarantine.hasQuarantined(typeURL) {
+		return res
+	}
+	var out model.Resources
+	for i, r := range res {
+		if !s.nackQuarantine.isQuarantined(typeURL, r) {
+			if out != nil {
+				out = append(out, r)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make(model.Resources, 0, len(res)), res[:i]...)
+		}
+		nackQuarantineWithheld.With(typeTag.Value(v3.GetMetricType(typeURL))).Increment()
+		if acked, f := con.ackedResources[typeURL][r.Name]; f && !s.nackQuarantine.isQuarantined(typeURL, acked) {
+			out = append(out, acked)
+		}
+	}
+	if out == nil {
+		return res
+	}
+	return out
+}
+
+// recordSent remembers the resources of the response sent with the nonce, to tell which ones a rejection is
+// about, and which ones are ACKed.
+func (s *DiscoveryServer) recordSent(con *Connection, typeURL, sentNonce string, res model.Resources) {
+	if s.nackQuarantine == nil || typeURL == v3.SecretType {
+		return
+	}
+	if con.sentResources == nil {
+		con.sentResources = map[string]*sentResponse{}
+	}
+	con.sentResources[typeURL] = &sentResponse{nonce: sentNonce, resources: res}
+}
+
+// onQuarantineAck records the resources of the ACKed response as the ones the proxy runs.
+func (s *DiscoveryServer) onQuarantineAck(con *Connection, typeURL, ackedNonce string) {
+	sent := con.sentResources[typeURL]
+	if sent == nil || sent.nonce != ackedNonce {
+		return
+	}
+	delete(con.sentResources, typeURL)
+	if con.ackedResources == nil {
+		con.ackedResources = map[string]map[string]*discovery.Resource{}
+	}
+	acked := con.ackedResources[typeURL]
+	if acked == nil {
+		acked = map[string]*discovery.Resource{}
+		con.ackedResources[typeURL] = acked
+	}
+	for _, r := range sent.resources {
+		acked[r.Name] = r
+	}
+}
+
+// onQuarantineNack records the resources named by a rejection, and returns true if some got quarantined, so
+// the proxy is sent a response without them.
+func (s *DiscoveryServer) onQuarantineNack(con *Connection, request *discovery.DiscoveryRequest) bool {
+	if s.nackQuarantine == nil {
+		return false
+	}
+	sent := con.sentResources[request.TypeUrl]
+	if sent == nil || sent.nonce != request.ResponseNonce {
+		return false
+	}
+	delete(con.sentResources, request.TypeUrl)
+	message := request.ErrorDetail.GetMessage()
+	var rejected model.Resources
+	for _, r := range sent.resources {
+		if mentions(message, r.Name) {
+			rejected = append(rejected, r)
+		}
+	}
+	if len(rejected) == 0 && len(sent.resources) == 1 {
+		rejected = sent.resources
+	}
+	if len(rejected) == 0 {
+		// The rejection can not be attributed to some resources, which are then not quarantined.
+		return false
+	}
+	return s.nackQuarantine.onNack(con.proxy.ID, request.TypeUrl, rejected, message, time.Now())
+}
+
+// mentions returns true if the message contains the name as a whole, not as part of a longer name.
+func mentions(message, name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; ; {
+		j := strings.Index(message[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if (start == 0 || !isNameChar(message[start-1])) && (end == len(message) || !isNameChar(message[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '.' || c == '|' || c == '-' || c == '/'
+}
+
+// quarantinez lists the resources rejected by proxies, and whether they are quarantined. With clear=true, which
+// must be POSTed, the quarantined resources are released.
+// It is mapped to /debug/quarantine
+func (s *DiscoveryServer) quarantinez(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("clear") == "true" {
+		if req.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte("POST to clear the quarantine\n"))
+			return
+		}
+		if s.nackQuarantine != nil {
+			s.nackQuarantine.clear()
+		}
+		_, _ = w.Write([]byte("Quarantine cleared\n"))
+		return
+	}
+	if s.nackQuarantine == nil {
+		writeJSON(w, []QuarantinedResource{}, req)
+		return
+	}
+	writeJSON(w, s.nackQuarantine.list(), req)
+}
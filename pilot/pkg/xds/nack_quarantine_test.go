@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestNackQuarantine(t *testing.T) {
+	s := &DiscoveryServer{nackQuarantine: newNackQuarantine(2, time.Hour)}
+	newCon := func(id string) *Connection {
+		return &Connection{conID: id, proxy: &model.Proxy{ID: id}}
+	}
+	listener := func(name, value string) *discovery.Resource {
+		return &discovery.Resource{Name: name, Resource: &anypb.Any{Value: []byte(value)}}
+	}
+	nack := func(con *Connection, nonce, message string) bool {
+		return s.onQuarantineNack(con, &discovery.DiscoveryRequest{
+			TypeUrl:       v3.ListenerType,
+			ResponseNonce: nonce,
+			ErrorDetail:   &status.Status{Code: 3, Message: message},
+		})
+	}
+	good := model.Resources{listener("0.0.0.0_80", "v1"), listener("0.0.0.0_8080", "v1")}
+	broken := model.Resources{listener("0.0.0.0_80", "v2"), listener("0.0.0.0_8080", "v1")}
+	message := "Error adding/updating listener(s) 0.0.0.0_80: invalid filter"
+
+	gw1, gw2 := newCon("gateway-1"), newCon("gateway-2")
+	s.recordSent(gw1, v3.ListenerType, "n1", good)
+	s.onQuarantineAck(gw1, v3.ListenerType, "n1")
+
+	s.recordSent(gw1, v3.ListenerType, "n2", broken)
+	if nack(gw1, "n2", message) {
+		t.Fatalf("expected no quarantine below the threshold")
+	}
+	if got := s.quarantineResources(gw1, v3.ListenerType, broken); len(got) != 2 || got[0] != broken[0] {
+		t.Fatalf("expected resources not to be changed before quarantine, got %v", got)
+	}
+
+	s.recordSent(gw2, v3.ListenerType, "n3", broken)
+	if !nack(gw2, "n3", message) {
+		t.Fatalf("expected the resource to be quarantined once rejected by proxies twice")
+	}
+	list := s.nackQuarantine.list()
+	if len(list) != 1 || list[0].Name != "0.0.0.0_80" || !list[0].Quarantined || len(list[0].Proxies) != 2 {
+		t.Fatalf("unexpected quarantine %+v", list)
+	}
+
+	// The proxy which ACKed a previous version gets it back, the other one gets nothing.
+	if got := s.quarantineResources(gw1, v3.ListenerType, broken); len(got) != 2 || got[0] != good[0] || got[1] != broken[1] {
+		t.Fatalf("expected the ACKed version of the quarantined resource, got %v", got)
+	}
+	if got := s.quarantineResources(gw2, v3.ListenerType, broken); len(got) != 1 || got[0] != broken[1] {
+		t.Fatalf("expected the quarantined resource to be withheld, got %v", got)
+	}
+	// Another version of the resource is not quarantined.
+	fixed := model.Resources{listener("0.0.0.0_80", "v3")}
+	if got := s.quarantineResources(gw2, v3.ListenerType, fixed); len(got) != 1 || got[0] != fixed[0] {
+		t.Fatalf("expected a new version not to be quarantined, got %v", got)
+	}
+
+	s.nackQuarantine.clear()
+	if got := s.quarantineResources(gw2, v3.ListenerType, broken); len(got) != 2 {
+		t.Fatalf("expected the resource to be released, got %v", got)
+	}
+}
+
+func TestNackQuarantineExpiry(t *testing.T) {
+	q := newNackQuarantine(1, time.Minute)
+	r := &discovery.Resource{Name: "outbound|80||a.example.com", Resource: &anypb.Any{Value: []byte("v1")}}
+	now := time.Now()
+	q.onNack("gateway", v3.ClusterType, model.Resources{r}, "invalid", now)
+	if !q.isQuarantined(v3.ClusterType, r) {
+		t.Fatalf("expected the resource to be quarantined")
+	}
+	q.onNack("gateway", v3.ClusterType, nil, "", now.Add(2*time.Minute))
+	if q.isQuarantined(v3.ClusterType, r) || len(q.list()) != 0 {
+		t.Fatalf("expected the quarantine to expire")
+	}
+}
+
+func TestQuarantinezClear(t *testing.T) {
+	s := &DiscoveryServer{nackQuarantine: newNackQuarantine(1, time.Hour)}
+	r := &discovery.Resource{Name: "0.0.0.0_80", Resource: &anypb.Any{Value: []byte("v1")}}
+	s.nackQuarantine.onNack("gateway", v3.ListenerType, model.Resources{r}, "invalid", time.Now())
+
+	rr := httptest.NewRecorder()
+	s.quarantinez(rr, httptest.NewRequest(http.MethodGet, "/debug/quarantine?clear=true", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d clearing with GET, want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+	if !s.nackQuarantine.isQuarantined(v3.ListenerType, r) {
+		t.Fatalf("expected the quarantine not to be cleared with GET")
+	}
+
+	rr = httptest.NewRecorder()
+	s.quarantinez(rr, httptest.NewRequest(http.MethodPost, "/debug/quarantine?clear=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d clearing with POST, want %d", rr.Code, http.StatusOK)
+	}
+	if s.nackQuarantine.isQuarantined(v3.ListenerType, r) {
+		t.Fatalf("expected the quarantine to be cleared with POST")
+	}
+}
+
+func TestMentions(t *testing.T) {
+	cases := []struct {
+		message string
+		name    string
+		want    bool
+	}{
+		{"Error adding/updating listener(s) 0.0.0.0_80: invalid", "0.0.0.0_80", true},
+		{"Error adding/updating listener(s) 0.0.0.0_8080: invalid", "0.0.0.0_80", false},
+		{"cluster outbound|80||a.example.com, outbound|80||b.example.com rejected", "outbound|80||b.example.com", true},
+		{"cluster outbound|80||aa.example.com rejected", "a.example.com", false},
+		{"route http.80", "http.80", true},
+		{"anything", "", false},
+	}
+	for _, tt := range cases {
+		if got := mentions(tt.message, tt.name); got != tt.want {
+			t.Errorf("mentions(%q, %q) = %v, want %v", tt.message, tt.name, got, tt.want)
+		}
+	}
+}
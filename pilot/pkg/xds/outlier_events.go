@@ -0,0 +1,224 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	clusterdata "github.com/envoyproxy/go-control-plane/envoy/data/cluster/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// maxOutlierEventLineSize bounds the size of a reported outlier detection event.
+const maxOutlierEventLineSize = 64 * 1024
+
+// OutlierEjection describes a host of a cluster ejected by the outlier detection of gateways.
+type OutlierEjection struct {
+	Cluster string `json:"cluster"`
+	// Host is the address of the ejected host.
+	Host string `json:"host"`
+	// Proxies are the proxies currently ejecting the host.
+	Proxies []string `json:"proxies"`
+	// Ejections is the number of ejections of the host reported by all the proxies.
+	Ejections int `json:"ejections"`
+	// Type is the type of the last ejection, such as CONSECUTIVE_5XX.
+	Type         string    `json:"type"`
+	LastEjection time.Time `json:"lastEjection"`
+	LastEvent    time.Time `json:"lastEvent"`
+}
+
+// ServiceOutliers lists the hosts of the clusters of a service ejected by gateways.
+type ServiceOutliers struct {
+	Service string            `json:"service"`
+	Hosts   []OutlierEjection `json:"hosts"`
+}
+
+type outlierKey struct {
+	cluster string
+	host    string
+}
+
+type outlierHost struct {
+	ejection OutlierEjection
+	// proxies holds the time each proxy ejecting the host ejected it.
+	proxies map[string]time.Time
+}
+
+// outlierAggregator aggregates the outlier detection events reported by the gateways, as written to the event log
+// of their clusters by Envoy, to tell which hosts are ejected fleet wide. A host is forgotten once no proxy has
+// reported an event about it for the retention, and a proxy which has not reported the host back is considered to
+// have stopped ejecting it after the retention too, as it may be gone.
+type outlierAggregator struct {
+	retention time.Duration
+
+	mu    sync.RWMutex
+	hosts map[outlierKey]*outlierHost
+}
+
+func newOutlierAggregator(retention time.Duration) *outlierAggregator {
+	return &outlierAggregator{
+		retention: retention,
+		hosts:     map[outlierKey]*outlierHost{},
+	}
+}
+
+// record aggregates an outlier detection event reported by a proxy.
+func (a *outlierAggregator) record(proxyID string, event *clusterdata.OutlierDetectionEvent, now time.Time) {
+	key := outlierKey{cluster: event.ClusterName, host: event.UpstreamUrl}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	h, ok := a.hosts[key]
+	if !ok {
+		h = &outlierHost{
+			ejection: OutlierEjection{Cluster: event.ClusterName, Host: event.UpstreamUrl},
+			proxies:  map[string]time.Time{},
+		}
+		a.hosts[key] = h
+	}
+	h.ejection.LastEvent = now
+	switch event.Action {
+	case clusterdata.Action_EJECT:
+		if !event.Enforced {
+			// The host was not ejected, as the enforcing percentage or the max ejection percentage were reached.
+			return
+		}
+		ejectionTime := now
+		if event.Timestamp != nil {
+			ejectionTime = event.Timestamp.AsTime()
+		}
+		h.proxies[proxyID] = now
+		h.ejection.Ejections++
+		h.ejection.Type = event.Type.String()
+		h.ejection.LastEjection = ejectionTime
+		outlierEjectionEvents.Increment()
+	case clusterdata.Action_UNEJECT:
+		delete(h.proxies, proxyID)
+	}
+}
+
+// expire forgets the hosts and the ejections older than the retention.
+func (a *outlierAggregator) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, h := range a.hosts {
+		for proxyID, ejected := range h.proxies {
+			if now.Sub(ejected) > a.retention {
+				delete(h.proxies, proxyID)
+			}
+		}
+		if now.Sub(h.ejection.LastEvent) > a.retention {
+			delete(a.hosts, key)
+		}
+	}
+}
+
+// list returns the hosts currently ejected by at least one proxy, by service, optionally filtered by the hostname of
+// the service.
+func (a *outlierAggregator) list(service string, now time.Time) []ServiceOutliers {
+	a.expire(now)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	byService := map[string][]OutlierEjection{}
+	for _, h := range a.hosts {
+		if len(h.proxies) == 0 {
+			continue
+		}
+		name := outlierServiceName(h.ejection.Cluster)
+		if service != "" && name != service {
+			continue
+		}
+		ejection := h.ejection
+		ejection.Proxies = maps.Keys(h.proxies)
+		sort.Strings(ejection.Proxies)
+		byService[name] = append(byService[name], ejection)
+	}
+	out := make([]ServiceOutliers, 0, len(byService))
+	for name, hosts := range byService {
+		sort.Slice(hosts, func(i, j int) bool {
+			if hosts[i].Cluster != hosts[j].Cluster {
+				return hosts[i].Cluster < hosts[j].Cluster
+			}
+			return hosts[i].Host < hosts[j].Host
+		})
+		out = append(out, ServiceOutliers{Service: name, Hosts: hosts})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Service < out[j].Service
+	})
+	return out
+}
+
+// outlierServiceName returns the hostname of the service of a cluster, or the cluster name if it is not the cluster
+// of a service.
+func outlierServiceName(clusterName string) string {
+	if _, _, hostname, _ := model.ParseSubsetKey(clusterName); hostname != "" {
+		return string(hostname)
+	}
+	return clusterName
+}
+
+// outlierz lists the hosts ejected by the outlier detection of the gateways by service, optionally filtered by the
+// hostname of the service. The gateways POST their outlier detection events, one JSON event of the Envoy event log
+// per line, with their proxyID.
+// It is mapped to /debug/outlierz
+func (s *DiscoveryServer) outlierz(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		s.reportOutlierEvents(w, req)
+		return
+	}
+	writeJSON(w, s.outlierAggregator.list(req.URL.Query().Get("service"), time.Now()), req)
+}
+
+func (s *DiscoveryServer) reportOutlierEvents(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("proxyID is required\n"))
+		return
+	}
+	scanner := bufio.NewScanner(req.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxOutlierEventLineSize)
+	now := time.Now()
+	recorded := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		event := &clusterdata.OutlierDetectionEvent{}
+		if err := protomarshal.UnmarshalAllowUnknown([]byte(line), event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(fmt.Sprintf("invalid outlier detection event %d: %v\n", recorded+1, err)))
+			return
+		}
+		s.outlierAggregator.record(proxyID, event, now)
+		recorded++
+	}
+	if err := scanner.Err(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("failed to read the outlier detection events: %v\n", err)))
+		return
+	}
+	_, _ = w.Write([]byte(fmt.Sprintf("Recorded %d outlier detection events\n", recorded)))
+}
@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	clusterdata "github.com/envoyproxy/go-control-plane/envoy/data/cluster/v3"
+)
+
+func TestOutlierAggregator(t *testing.T) {
+	a := newOutlierAggregator(time.Minute)
+	now := time.Now()
+	eject := func(cluster, host string) *clusterdata.OutlierDetectionEvent {
+		return &clusterdata.OutlierDetectionEvent{
+			Type:        clusterdata.OutlierEjectionType_CONSECUTIVE_5XX,
+			ClusterName: cluster,
+			UpstreamUrl: host,
+			Action:      clusterdata.Action_EJECT,
+			Enforced:    true,
+		}
+	}
+	reviews := "outbound|9080||reviews.default.svc.cluster.local"
+	ratings := "outbound|9080|v1|ratings.default.svc.cluster.local"
+
+	a.record("gateway-1", eject(reviews, "10.0.0.1:9080"), now)
+	a.record("gateway-2", eject(reviews, "10.0.0.1:9080"), now)
+	a.record("gateway-1", eject(ratings, "10.0.0.2:9080"), now)
+	notEnforced := eject(ratings, "10.0.0.3:9080")
+	notEnforced.Enforced = false
+	a.record("gateway-1", notEnforced, now)
+
+	got := a.list("", now)
+	if len(got) != 2 || got[0].Service != "ratings.default.svc.cluster.local" ||
+		got[1].Service != "reviews.default.svc.cluster.local" {
+		t.Fatalf("unexpected outliers %+v", got)
+	}
+	if len(got[0].Hosts) != 1 || got[0].Hosts[0].Host != "10.0.0.2:9080" {
+		t.Errorf("expected only the enforced ejection, got %+v", got[0].Hosts)
+	}
+	host := got[1].Hosts[0]
+	if !reflect.DeepEqual(host.Proxies, []string{"gateway-1", "gateway-2"}) || host.Ejections != 2 ||
+		host.Type != "CONSECUTIVE_5XX" {
+		t.Errorf("unexpected ejection %+v", host)
+	}
+
+	a.record("gateway-1", &clusterdata.OutlierDetectionEvent{
+		ClusterName: reviews,
+		UpstreamUrl: "10.0.0.1:9080",
+		Action:      clusterdata.Action_UNEJECT,
+	}, now)
+	got = a.list("reviews.default.svc.cluster.local", now)
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Hosts[0].Proxies, []string{"gateway-2"}) {
+		t.Errorf("expected the host to be only ejected by gateway-2, got %+v", got)
+	}
+
+	if got := a.list("", now.Add(2*time.Minute)); len(got) != 0 {
+		t.Errorf("expected the ejections to expire, got %+v", got)
+	}
+	if len(a.hosts) != 0 {
+		t.Errorf("expected the hosts to be forgotten, got %v", a.hosts)
+	}
+}
+
+func TestOutlierz(t *testing.T) {
+	s := &DiscoveryServer{outlierAggregator: newOutlierAggregator(time.Minute)}
+	events := `{"type":"CONSECUTIVE_GATEWAY_FAILURE","cluster_name":"outbound|80||httpbin.default.svc.cluster.local",` +
+		`"upstream_url":"10.0.0.1:80","action":"EJECT","num_ejections":1,"enforced":true,"eject_consecutive_event":{}}
+
+{"cluster_name":"outbound|80||httpbin.default.svc.cluster.local","upstream_url":"10.0.0.2:80","action":"UNEJECT"}
+`
+	req := httptest.NewRequest(http.MethodPost, "/debug/outlierz?proxyID=gateway-1", strings.NewReader(events))
+	w := httptest.NewRecorder()
+	s.outlierz(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Recorded 2") {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.outlierz(w, httptest.NewRequest(http.MethodGet, "/debug/outlierz", nil))
+	var got []ServiceOutliers
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Service != "httpbin.default.svc.cluster.local" || len(got[0].Hosts) != 1 ||
+		got[0].Hosts[0].Type != "CONSECUTIVE_GATEWAY_FAILURE" {
+		t.Errorf("unexpected outliers %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	s.outlierz(w, httptest.NewRequest(http.MethodPost, "/debug/outlierz", strings.NewReader(events)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a report without proxyID to be rejected, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.outlierz(w, httptest.NewRequest(http.MethodPost, "/debug/outlierz?proxyID=gateway-1", strings.NewReader("{")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid event to be rejected, got %d", w.Code)
+	}
+}
@@ -17,6 +17,7 @@ package xds
 import (
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/apigen"
 	"istio.io/istio/pkg/config/host"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/util/sets"
@@ -91,6 +92,13 @@ func proxyDependentOnConfig(proxy *model.Proxy, config model.ConfigKey, push *mo
 
 // DefaultProxyNeedsPush check if a proxy needs push for this push event.
 func DefaultProxyNeedsPush(proxy *model.Proxy, req *model.PushRequest) bool {
+	// Added by Ingress
+	if proxy.Metadata.Generator == apigen.MCPGeneratorName {
+		// The downstream control planes consume all the configs of the kinds served, the generator skipping the
+		// kinds not updated.
+		return req.Full
+	}
+	// End added by Ingress
 	if ConfigAffectsProxy(req, proxy) {
 		return true
 	}
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// ProxyConfigStatus tells whether a connected proxy is up to date: for each type, the last sent and the last ACKed
+// responses, and the resources pending an ACK.
+type ProxyConfigStatus struct {
+	ProxyID      string    `json:"proxyId"`
+	ConnectionID string    `json:"connectionId"`
+	ProxyType    string    `json:"proxyType,omitempty"`
+	ClusterID    string    `json:"clusterId,omitempty"`
+	IstioVersion string    `json:"istioVersion,omitempty"`
+	ConnectedAt  time.Time `json:"connectedAt"`
+	Delta        bool      `json:"delta,omitempty"`
+	// PushPending is true if a push is queued for the proxy.
+	PushPending bool `json:"pushPending"`
+	// UpToDate is true if every sent response is ACKed and no push is queued.
+	UpToDate bool             `json:"upToDate"`
+	Types    []TypeSyncStatus `json:"types"`
+}
+
+// TypeSyncStatus is the synchronization status of a type for a proxy.
+type TypeSyncStatus struct {
+	TypeURL      string     `json:"typeUrl"`
+	NonceSent    string     `json:"nonceSent,omitempty"`
+	NonceAcked   string     `json:"nonceAcked,omitempty"`
+	VersionSent  string     `json:"versionSent,omitempty"`
+	VersionAcked string     `json:"versionAcked,omitempty"`
+	SentAt       *time.Time `json:"sentAt,omitempty"`
+	AckedAt      *time.Time `json:"ackedAt,omitempty"`
+	// Pending are the resources of the last sent response, if it is not ACKed yet.
+	Pending []string `json:"pending,omitempty"`
+	// Orphaned are the resources subscribed to which are no longer generated.
+	Orphaned []string `json:"orphaned,omitempty"`
+	UpToDate bool     `json:"upToDate"`
+}
+
+// setPendingResources records the resources of the response sent with the nonce as pending an ACK.
+func (conn *Connection) setPendingResources(typeURL, sentNonce string, res model.Resources) {
+	names := make([]string, 0, len(res))
+	for _, r := range res {
+		names = append(names, r.Name)
+	}
+	conn.proxy.Lock()
+	defer conn.proxy.Unlock()
+	if w := conn.proxy.WatchedResources[typeURL]; w != nil && w.NonceSent == sentNonce && w.NonceAcked != sentNonce {
+		w.PendingResources = names
+	}
+}
+
+// deltaResourceNames returns the names of the resources added or removed by a delta response.
+func deltaResourceNames(res *discovery.DeltaDiscoveryResponse) []string {
+	names := make([]string, 0, len(res.Resources)+len(res.RemovedResources))
+	for _, r := range res.Resources {
+		names = append(names, r.Name)
+	}
+	return append(names, res.RemovedResources...)
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// proxyConfigStatus returns the status of a connection.
+func (s *DiscoveryServer) proxyConfigStatus(con *Connection) ProxyConfigStatus {
+	proxy := cloneProxy(con.proxy)
+	status := ProxyConfigStatus{
+		ProxyID:      proxy.ID,
+		ConnectionID: con.conID,
+		ProxyType:    string(proxy.Type),
+		ClusterID:    proxy.GetClusterID().String(),
+		IstioVersion: proxy.GetIstioVersion(),
+		ConnectedAt:  con.connectedAt,
+		Delta:        con.deltaStream != nil,
+		PushPending:  s.pushQueue.isPending(con),
+		Types:        make([]TypeSyncStatus, 0, len(proxy.WatchedResources)),
+	}
+	status.UpToDate = !status.PushPending
+	for typeURL, w := range proxy.WatchedResources {
+		ts := TypeSyncStatus{
+			TypeURL:      typeURL,
+			NonceSent:    w.NonceSent,
+			NonceAcked:   w.NonceAcked,
+			VersionSent:  w.VersionSent,
+			VersionAcked: w.VersionAcked,
+			SentAt:       timeOrNil(w.SentAt),
+			AckedAt:      timeOrNil(w.AckedAt),
+			Orphaned:     w.OrphanedResources,
+			UpToDate:     w.NonceSent == w.NonceAcked,
+		}
+		if !ts.UpToDate {
+			ts.Pending = w.PendingResources
+			status.UpToDate = false
+		}
+		status.Types = append(status.Types, ts)
+	}
+	sort.Slice(status.Types, func(i, j int) bool {
+		return status.Types[i].TypeURL < status.Types[j].TypeURL
+	})
+	return status
+}
+
+// proxyStatusz returns the config status of the proxy, or of all the connected proxies keyed by proxy ID. The proxy
+// is queried by its proxy ID, connection ID or node ID.
+// It is mapped to /debug/proxy_status
+func (s *DiscoveryServer) proxyStatusz(w http.ResponseWriter, req *http.Request) {
+	if proxyID := req.URL.Query().Get("proxyID"); proxyID != "" {
+		for _, con := range s.SortedClients() {
+			if con.proxy.ID == proxyID || con.conID == proxyID || con.node.GetId() == proxyID {
+				writeJSON(w, s.proxyConfigStatus(con), req)
+				return
+			}
+		}
+		s.errorHandler(w, proxyID, nil)
+		return
+	}
+	out := map[string]ProxyConfigStatus{}
+	for _, con := range s.SortedClients() {
+		out[con.proxy.ID] = s.proxyConfigStatus(con)
+	}
+	writeJSON(w, out, req)
+}
@@ -0,0 +1,392 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// maxAuditedConfigs bounds the number of updated configs recorded for a push.
+	maxAuditedConfigs = 100
+	// maxAuditedProxies bounds the number of proxies recorded as pushed, or failed, for a push.
+	maxAuditedProxies = 100
+	// pushAuditTimeout is how long a push waits for the outcome of all its proxies before being recorded anyway.
+	// Proxies disconnecting before being pushed are reported as unfinished.
+	pushAuditTimeout = time.Minute
+	// pushAuditBuffer bounds the number of records waiting to be written, the next ones are dropped.
+	pushAuditBuffer = 1000
+)
+
+// Outcomes of the audited pushes.
+const (
+	PushAuditSucceeded = "succeeded"
+	PushAuditPartial   = "partial"
+	PushAuditFailed    = "failed"
+)
+
+// PushAuditRecord records a push: the config changes which triggered it, who made them, the proxies it reached
+// and its outcome.
+type PushAuditRecord struct {
+	ID      uint64            `json:"id"`
+	Time    time.Time         `json:"time"`
+	Version string            `json:"version,omitempty"`
+	Full    bool              `json:"full"`
+	Reasons model.ReasonStats `json:"reasons,omitempty"`
+	// Configs are the updated configs which triggered the push. It is empty if all configs are pushed.
+	Configs          []AuditedConfig `json:"configs,omitempty"`
+	ConfigsTruncated bool            `json:"configsTruncated,omitempty"`
+	// Proxies is the number of connected proxies the push was queued for.
+	Proxies int `json:"proxies"`
+	// Pushed, Skipped and Failed are the number of proxies which got configs, did not need any, or could not be
+	// pushed. Unfinished are the proxies with no outcome when the record was written, mostly disconnected ones.
+	Pushed     int `json:"pushed"`
+	Skipped    int `json:"skipped"`
+	Failed     int `json:"failed"`
+	Unfinished int `json:"unfinished,omitempty"`
+	// PushedProxies and FailedProxies are some of the pushed, and failed, proxies.
+	PushedProxies []string  `json:"pushedProxies,omitempty"`
+	FailedProxies []string  `json:"failedProxies,omitempty"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+	Completed     time.Time `json:"completed"`
+}
+
+// AuditedConfig is an updated config, with the actor who last changed it if known.
+type AuditedConfig struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Actor     string `json:"actor,omitempty"`
+}
+
+// pushAuditSink writes the audit records.
+type pushAuditSink interface {
+	write(record *PushAuditRecord) error
+	close()
+}
+
+// fileAuditSink appends the records to a file, one JSON object per line.
+type fileAuditSink struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func (f *fileAuditSink) write(record *PushAuditRecord) error {
+	return f.enc.Encode(record)
+}
+
+func (f *fileAuditSink) close() {
+	_ = f.f.Close()
+}
+
+// webhookAuditSink posts each record as JSON to a URL.
+type webhookAuditSink struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhookAuditSink) write(record *PushAuditRecord) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.url, resp.Status)
+	}
+	return nil
+}
+
+func (w *webhookAuditSink) close() {}
+
+// newPushAuditSink creates a sink posting to the target if it is an HTTP URL, or appending to the file at the target
+// otherwise.
+func newPushAuditSink(target string) (pushAuditSink, error) {
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		return &webhookAuditSink{url: target, client: &http.Client{Timeout: 5 * time.Second}}, nil
+	}
+	f, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditSink{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// pushAuditor records the pushes, and writes them once all the proxies they were queued for have been pushed.
+// The audited pushes are tracked through the queue by the AuditIDs of the push requests, which are merged along
+// with the requests, so a push to a proxy reports the outcome of every push it covers.
+type pushAuditor struct {
+	sink             pushAuditSink
+	actorAnnotations []string
+	records          chan *PushAuditRecord
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]*pendingPushAudit
+}
+
+type pendingPushAudit struct {
+	record *PushAuditRecord
+	// remaining are the IDs of the connections with no outcome yet.
+	remaining sets.String
+}
+
+// newPushAuditor creates a pushAuditor writing to the target, a file or a webhook URL, and reading the actors of
+// the configs from the comma separated annotations. It returns nil if the target is empty.
+func newPushAuditor(target string, actorAnnotations string) (*pushAuditor, error) {
+	if target == "" {
+		return nil, nil
+	}
+	sink, err := newPushAuditSink(target)
+	if err != nil {
+		return nil, err
+	}
+	var annotations []string
+	for _, a := range strings.Split(actorAnnotations, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			annotations = append(annotations, a)
+		}
+	}
+	return &pushAuditor{
+		sink:             sink,
+		actorAnnotations: annotations,
+		records:          make(chan *PushAuditRecord, pushAuditBuffer),
+		pending:          map[uint64]*pendingPushAudit{},
+	}, nil
+}
+
+// start records a push queued for the connections, and returns its ID.
+func (a *pushAuditor) start(record *PushAuditRecord, conIDs []string) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextID++
+	record.ID = a.nextID
+	record.Proxies = len(conIDs)
+	if len(conIDs) == 0 {
+		a.complete(record, 0)
+		return record.ID
+	}
+	a.pending[record.ID] = &pendingPushAudit{record: record, remaining: sets.New(conIDs...)}
+	return record.ID
+}
+
+// failed records a push which could not be started.
+func (a *pushAuditor) failed(record *PushAuditRecord, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nextID++
+	record.ID = a.nextID
+	record.Error = err.Error()
+	a.complete(record, 0)
+}
+
+// Outcomes of a push to a proxy.
+const (
+	proxyPushed = iota
+	proxySkipped
+	proxyFailed
+)
+
+// done records the outcome of the pushes covered by the request for the connection.
+func (a *pushAuditor) done(conID, proxyID string, ids []uint64, outcome int) {
+	if len(ids) == 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range ids {
+		p, f := a.pending[id]
+		if !f || !p.remaining.Contains(conID) {
+			continue
+		}
+		p.remaining.Delete(conID)
+		r := p.record
+		switch outcome {
+		case proxyPushed:
+			r.Pushed++
+			if len(r.PushedProxies) < maxAuditedProxies {
+				r.PushedProxies = append(r.PushedProxies, proxyID)
+			}
+		case proxySkipped:
+			r.Skipped++
+		case proxyFailed:
+			r.Failed++
+			if len(r.FailedProxies) < maxAuditedProxies {
+				r.FailedProxies = append(r.FailedProxies, proxyID)
+			}
+		}
+		if len(p.remaining) == 0 {
+			delete(a.pending, id)
+			a.complete(r, 0)
+		}
+	}
+}
+
+// expire records the pushes still waiting for proxies after the timeout.
+func (a *pushAuditor) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, p := range a.pending {
+		if now.Sub(p.record.Time) > pushAuditTimeout {
+			delete(a.pending, id)
+			a.complete(p.record, len(p.remaining))
+		}
+	}
+}
+
+// complete sets the outcome of the record and queues it for writing.
+func (a *pushAuditor) complete(r *PushAuditRecord, unfinished int) {
+	r.Unfinished = unfinished
+	r.Completed = time.Now()
+	switch {
+	case r.Error != "" || r.Proxies > 0 && r.Failed == r.Proxies:
+		r.Outcome = PushAuditFailed
+	case r.Failed > 0 || r.Unfinished > 0:
+		r.Outcome = PushAuditPartial
+	default:
+		r.Outcome = PushAuditSucceeded
+	}
+	select {
+	case a.records <- r:
+	default:
+		pushAuditDropped.Increment()
+	}
+}
+
+// run writes the records, and expires the pushes waiting for too long, until stopped.
+func (a *pushAuditor) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	defer a.sink.close()
+	for {
+		select {
+		case r := <-a.records:
+			if err := a.sink.write(r); err != nil {
+				pushAuditErrors.Increment()
+				log.Warnf("failed to write the audit record of push %d: %v", r.ID, err)
+			}
+		case now := <-ticker.C:
+			a.expire(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// auditedConfigs returns the updated configs of the request, with their actors.
+func (s *DiscoveryServer) auditedConfigs(req *model.PushRequest) ([]AuditedConfig, bool) {
+	keys := make([]model.ConfigKey, 0, len(req.ConfigsUpdated))
+	for key := range req.ConfigsUpdated {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	truncated := len(keys) > maxAuditedConfigs
+	if truncated {
+		keys = keys[:maxAuditedConfigs]
+	}
+	out := make([]AuditedConfig, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, AuditedConfig{
+			Kind:      key.Kind.String(),
+			Namespace: key.Namespace,
+			Name:      key.Name,
+			Actor:     s.configActor(key),
+		})
+	}
+	return out, truncated
+}
+
+// configActor returns the actor who last changed the config, from the first of its actor annotations found.
+func (s *DiscoveryServer) configActor(key model.ConfigKey) string {
+	if s.Env == nil || s.Env.ConfigStore == nil || len(s.pushAuditor.actorAnnotations) == 0 {
+		return ""
+	}
+	for _, schema := range s.Env.ConfigStore.Schemas().All() {
+		if schema.Kind() != key.Kind.String() {
+			continue
+		}
+		cfg := s.Env.ConfigStore.Get(schema.GroupVersionKind(), key.Name, key.Namespace)
+		if cfg == nil {
+			continue
+		}
+		for _, annotation := range s.pushAuditor.actorAnnotations {
+			if actor := cfg.Annotations[annotation]; actor != "" {
+				return actor
+			}
+		}
+	}
+	return ""
+}
+
+// newPushAuditRecord creates the record of a push request.
+func (s *DiscoveryServer) newPushAuditRecord(req *model.PushRequest) *PushAuditRecord {
+	r := &PushAuditRecord{
+		Time:    time.Now(),
+		Full:    req.Full,
+		Reasons: req.Reason,
+	}
+	if req.Push != nil {
+		r.Version = req.Push.PushVersion
+	}
+	r.Configs, r.ConfigsTruncated = s.auditedConfigs(req)
+	return r
+}
+
+// auditPushStart records a push queued for the connections, setting its ID on the request.
+func (s *DiscoveryServer) auditPushStart(req *model.PushRequest, cons []*Connection) {
+	if s.pushAuditor == nil {
+		return
+	}
+	conIDs := make([]string, 0, len(cons))
+	for _, con := range cons {
+		conIDs = append(conIDs, con.conID)
+	}
+	id := s.pushAuditor.start(s.newPushAuditRecord(req), conIDs)
+	req.AuditIDs = append(req.AuditIDs, id)
+}
+
+// auditPushFailed records a push which could not be started.
+func (s *DiscoveryServer) auditPushFailed(req *model.PushRequest, err error) {
+	if s.pushAuditor == nil {
+		return
+	}
+	s.pushAuditor.failed(s.newPushAuditRecord(req), err)
+}
+
+// auditPushDone records the outcome of a push to a connection.
+func (s *DiscoveryServer) auditPushDone(con *Connection, req *model.PushRequest, outcome int) {
+	if s.pushAuditor == nil {
+		return
+	}
+	s.pushAuditor.done(con.conID, con.proxy.ID, req.AuditIDs, outcome)
+}
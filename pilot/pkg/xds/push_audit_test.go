@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestPushAuditor(t *testing.T) {
+	a, err := newPushAuditor(filepath.Join(t.TempDir(), "audit.log"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &DiscoveryServer{pushAuditor: a}
+	newCon := func(id string) *Connection {
+		return &Connection{conID: id, proxy: &model.Proxy{ID: id}}
+	}
+	gw1, gw2, gw3 := newCon("gw-1"), newCon("gw-2"), newCon("gw-3")
+	cons := []*Connection{gw1, gw2, gw3}
+
+	first := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.VirtualService, Name: "vs", Namespace: "default"}),
+		Reason:         model.NewReasonStats(model.ConfigUpdate),
+	}
+	s.auditPushStart(first, cons)
+	second := &model.PushRequest{Full: true, Reason: model.NewReasonStats(model.ConfigUpdate)}
+	s.auditPushStart(second, cons)
+
+	// gw-1 gets both pushes merged, gw-2 only the first one, and gw-3 fails.
+	s.auditPushDone(gw1, first.CopyMerge(second), proxyPushed)
+	s.auditPushDone(gw2, first, proxySkipped)
+	s.auditPushDone(gw3, first.CopyMerge(second), proxyFailed)
+	// A push is only reported once for a proxy.
+	s.auditPushDone(gw2, first, proxyPushed)
+
+	r := <-a.records
+	if r.ID != first.AuditIDs[0] || r.Outcome != PushAuditPartial || r.Pushed != 1 || r.Skipped != 1 || r.Failed != 1 {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if !reflect.DeepEqual(r.Configs, []AuditedConfig{{Kind: "VirtualService", Namespace: "default", Name: "vs"}}) {
+		t.Fatalf("unexpected configs %+v", r.Configs)
+	}
+	if !reflect.DeepEqual(r.FailedProxies, []string{"gw-3"}) {
+		t.Fatalf("unexpected failed proxies %v", r.FailedProxies)
+	}
+	select {
+	case r := <-a.records:
+		t.Fatalf("unexpected record %+v, the second push is not done", r)
+	default:
+	}
+
+	// gw-2 disconnected before getting the second push.
+	a.expire(time.Now().Add(2 * pushAuditTimeout))
+	r = <-a.records
+	if r.ID != second.AuditIDs[0] || r.Outcome != PushAuditPartial || r.Pushed != 1 || r.Failed != 1 || r.Unfinished != 1 {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	s.auditPushFailed(&model.PushRequest{Full: true}, errors.New("invalid"))
+	if r = <-a.records; r.Outcome != PushAuditFailed || r.Error != "invalid" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := newPushAuditSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint64{1, 2} {
+		if err := sink.write(&PushAuditRecord{ID: id, Outcome: PushAuditSucceeded}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var ids []uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r PushAuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.ID)
+	}
+	if !reflect.DeepEqual(ids, []uint64{1, 2}) {
+		t.Fatalf("unexpected records %v", ids)
+	}
+}
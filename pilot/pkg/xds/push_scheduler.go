@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// priorityTypeKinds lists, for the types which can be prioritized, the kinds of configs whose updates
+// only need a push of the type. Pushes only triggered by these configs are dequeued first.
+var priorityTypeKinds = map[string][]kind.Kind{
+	v3.SecretType:                 {kind.Secret, kind.ReferenceGrant},
+	v3.ExtensionConfigurationType: {kind.WasmPlugin, kind.Secret},
+}
+
+// pushScheduler caps the number of concurrent pushes of each xDS type, and prioritizes the pushes
+// of some types over the others, so a type with large responses such as EDS does not starve the others.
+type pushScheduler struct {
+	// limits holds a semaphore for every type whose concurrent pushes are capped.
+	limits map[string]chan struct{}
+	// priority is the set of types pushed first.
+	priority sets.String
+	// priorityKinds is the set of config kinds whose updates only need a push of a priority type.
+	priorityKinds sets.Set[kind.Kind]
+}
+
+// newPushScheduler creates a pushScheduler from the per-type limits, as a comma separated list of
+// TYPE=LIMIT, and the comma separated list of priority types. Types are either type URLs or short
+// names such as EDS or SDS.
+func newPushScheduler(limits, priority string) (*pushScheduler, error) {
+	ps := &pushScheduler{
+		limits:        map[string]chan struct{}{},
+		priority:      sets.New[string](),
+		priorityKinds: sets.New[kind.Kind](),
+	}
+	for _, l := range splitList(limits) {
+		tp, n, ok := strings.Cut(l, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid push limit %q, expected TYPE=LIMIT", l)
+		}
+		typeURL, err := parsePushType(tp)
+		if err != nil {
+			return nil, err
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid push limit %q for %s, expected a positive integer", n, tp)
+		}
+		ps.limits[typeURL] = make(chan struct{}, limit)
+	}
+	for _, tp := range splitList(priority) {
+		typeURL, err := parsePushType(tp)
+		if err != nil {
+			return nil, err
+		}
+		kinds, f := priorityTypeKinds[typeURL]
+		if !f {
+			return nil, fmt.Errorf("push type %s can not be prioritized", tp)
+		}
+		ps.priority.Insert(typeURL)
+		ps.priorityKinds.InsertAll(kinds...)
+	}
+	return ps, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// parsePushType returns the type URL of a type given either by its URL or by its short name.
+func parsePushType(tp string) (string, error) {
+	tp = strings.TrimSpace(tp)
+	if strings.HasPrefix(tp, resource.APITypePrefix) {
+		return tp, nil
+	}
+	for _, typeURL := range pushSchedulerTypes {
+		if strings.EqualFold(v3.GetShortType(typeURL), tp) {
+			return typeURL, nil
+		}
+	}
+	return "", fmt.Errorf("unknown push type %q", tp)
+}
+
+var pushSchedulerTypes = []string{
+	v3.ClusterType,
+	v3.EndpointType,
+	v3.ListenerType,
+	v3.ScopedRouteType,
+	v3.RouteType,
+	v3.SecretType,
+	v3.ExtensionConfigurationType,
+	v3.NameTableType,
+	v3.ProxyConfigType,
+}
+
+// acquire blocks until a push of the type is allowed, and returns the function to call once it is done.
+func (ps *pushScheduler) acquire(typeURL string) func() {
+	sem, f := ps.limits[typeURL]
+	if !f {
+		return func() {}
+	}
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// order moves the watched resources of the priority types first, keeping the order of the others.
+func (ps *pushScheduler) order(wrl []*model.WatchedResource) []*model.WatchedResource {
+	if len(ps.priority) == 0 {
+		return wrl
+	}
+	out := make([]*model.WatchedResource, 0, len(wrl))
+	for _, w := range wrl {
+		if ps.priority.Contains(w.TypeUrl) {
+			out = append(out, w)
+		}
+	}
+	for _, w := range wrl {
+		if !ps.priority.Contains(w.TypeUrl) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// isPriorityPush returns true if the push is only triggered by updates of configs pushed by priority types,
+// such as a secret rotation when SDS is prioritized.
+func (ps *pushScheduler) isPriorityPush(req *model.PushRequest) bool {
+	if len(ps.priorityKinds) == 0 || !req.Full || len(req.ConfigsUpdated) == 0 {
+		return false
+	}
+	for key := range req.ConfigsUpdated {
+		if !ps.priorityKinds.Contains(key.Kind) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestNewPushScheduler(t *testing.T) {
+	cases := []struct {
+		name     string
+		limits   string
+		priority string
+		want     map[string]int
+		wantErr  bool
+	}{
+		{name: "empty"},
+		{name: "short names", limits: "EDS=10, cds=2", priority: "SDS", want: map[string]int{v3.EndpointType: 10, v3.ClusterType: 2}},
+		{name: "type url", limits: v3.EndpointType + "=3", want: map[string]int{v3.EndpointType: 3}},
+		{name: "missing limit", limits: "EDS", wantErr: true},
+		{name: "invalid limit", limits: "EDS=0", wantErr: true},
+		{name: "unknown type", limits: "XDS=1", wantErr: true},
+		{name: "not prioritizable", priority: "EDS", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ps, err := newPushScheduler(tt.limits, tt.priority)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ps.limits) != len(tt.want) {
+				t.Fatalf("got limits %v, want %v", ps.limits, tt.want)
+			}
+			for tp, limit := range tt.want {
+				if cap(ps.limits[tp]) != limit {
+					t.Fatalf("got limit %d for %s, want %d", cap(ps.limits[tp]), tp, limit)
+				}
+			}
+		})
+	}
+}
+
+func TestPushSchedulerAcquire(t *testing.T) {
+	ps, err := newPushScheduler("EDS=1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Types without a limit are never blocked.
+	ps.acquire(v3.ClusterType)
+	ps.acquire(v3.ClusterType)
+
+	release := ps.acquire(v3.EndpointType)
+	acquired := make(chan struct{})
+	go func() {
+		ps.acquire(v3.EndpointType)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("push allowed beyond the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatalf("push not allowed once released")
+	}
+}
+
+func TestPushSchedulerOrder(t *testing.T) {
+	ps, err := newPushScheduler("", "SDS,ECDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrl := orderWatchedResources(map[string]*model.WatchedResource{
+		v3.ClusterType:                {TypeUrl: v3.ClusterType},
+		v3.EndpointType:               {TypeUrl: v3.EndpointType},
+		v3.ListenerType:               {TypeUrl: v3.ListenerType},
+		v3.SecretType:                 {TypeUrl: v3.SecretType},
+		v3.ExtensionConfigurationType: {TypeUrl: v3.ExtensionConfigurationType},
+	})
+	got := []string{}
+	for _, w := range ps.order(wrl) {
+		got = append(got, v3.GetShortType(w.TypeUrl))
+	}
+	want := []string{"SDS", "ECDS", "CDS", "EDS", "LDS"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got order %v, want %v", got, want)
+	}
+}
+
+func TestPriorityPushQueue(t *testing.T) {
+	ps, err := newPushScheduler("", "SDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies := make([]*Connection, 0, 3)
+	for p := 0; p < 3; p++ {
+		proxies = append(proxies, &Connection{conID: fmt.Sprintf("proxy-%d", p)})
+	}
+	eds := &model.PushRequest{Full: true, ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.ServiceEntry, Name: "svc"})}
+	secret := func() *model.PushRequest {
+		return &model.PushRequest{Full: true, ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Secret, Name: "cert"})}
+	}
+
+	t.Run("priority push first", func(t *testing.T) {
+		p := NewPriorityPushQueue(ps.isPriorityPush)
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], eds)
+		p.Enqueue(proxies[1], eds)
+		p.Enqueue(proxies[2], secret())
+		ExpectDequeue(t, p, proxies[2])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("merged into pending push", func(t *testing.T) {
+		p := NewPriorityPushQueue(ps.isPriorityPush)
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], eds)
+		p.Enqueue(proxies[1], eds)
+		p.Enqueue(proxies[1], secret())
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("enqueued while processing", func(t *testing.T) {
+		p := NewPriorityPushQueue(ps.isPriorityPush)
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], eds)
+		ExpectDequeue(t, p, proxies[0])
+		p.Enqueue(proxies[1], eds)
+		p.Enqueue(proxies[0], secret())
+		p.MarkDone(proxies[0])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectDequeue(t, p, proxies[1])
+		ExpectTimeout(t, p)
+	})
+
+	t.Run("entry left in queue", func(t *testing.T) {
+		p := NewPriorityPushQueue(ps.isPriorityPush)
+		defer p.ShutDown()
+		p.Enqueue(proxies[0], secret())
+		p.Enqueue(proxies[1], eds)
+		ExpectDequeue(t, p, proxies[0])
+		p.MarkDone(proxies[0])
+		p.Enqueue(proxies[0], eds)
+		ExpectDequeue(t, p, proxies[1])
+		ExpectDequeue(t, p, proxies[0])
+		ExpectTimeout(t, p)
+		if p.Pending() != 0 {
+			t.Fatalf("expected no pending push, got %d", p.Pending())
+		}
+	})
+}
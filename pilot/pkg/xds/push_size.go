@@ -0,0 +1,226 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// maxLargestResources bounds the number of the largest resources tracked per type for each proxy.
+const maxLargestResources = 5
+
+// parsePushSizeBudgets parses the budgets of the size of the pushes, as comma separated TYPE=SIZE where SIZE is a
+// quantity of bytes such as 10Mi.
+func parsePushSizeBudgets(s string) (map[string]int64, error) {
+	budgets := map[string]int64{}
+	for _, b := range splitList(s) {
+		tp, size, ok := strings.Cut(b, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid push size budget %q, expected TYPE=SIZE", b)
+		}
+		typeURL, err := parsePushType(tp)
+		if err != nil {
+			return nil, err
+		}
+		q, err := resource.ParseQuantity(strings.TrimSpace(size))
+		if err != nil || q.Value() <= 0 {
+			return nil, fmt.Errorf("invalid push size budget %q for %s, expected a positive size such as 10Mi", size, tp)
+		}
+		budgets[typeURL] = q.Value()
+	}
+	return budgets, nil
+}
+
+// SizedResource is a generated resource with its size.
+type SizedResource struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// PushSize describes the size of the last push of a type to a proxy.
+type PushSize struct {
+	Type string `json:"type"`
+	// Size is the size of the resources of the last push, which only holds the changed resources if incremental.
+	Size int `json:"size"`
+	// Budget is the budget of the size of the pushes of the type, if any.
+	Budget int64 `json:"budget,omitempty"`
+	// Exceeded is whether the last push exceeded the budget.
+	Exceeded bool      `json:"exceeded,omitempty"`
+	Time     time.Time `json:"time"`
+	// Largest are the largest resources of the type generated for the proxy, largest first.
+	Largest []SizedResource `json:"largest"`
+}
+
+// ProxyPushSizes lists the sizes of the pushes to a proxy by type.
+type ProxyPushSizes struct {
+	ProxyID string     `json:"proxyID"`
+	Types   []PushSize `json:"types"`
+}
+
+// pushSizes tracks by type the size of the last push to the proxy of a connection and its largest resources. It is
+// guarded by a mutex as it is read by the debug endpoints. A nil pushSizes tracks nothing.
+type pushSizes struct {
+	mu    sync.RWMutex
+	types map[string]*PushSize
+}
+
+// record records the size of a push. A full push replaces the largest resources of the type, while the resources
+// of an incremental one are merged into them.
+func (p *pushSizes) record(typeURL string, res model.Resources, size int, budget int64, incremental bool, now time.Time) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.types == nil {
+		p.types = map[string]*PushSize{}
+	}
+	ps := p.types[typeURL]
+	if ps == nil {
+		ps = &PushSize{Type: v3.GetShortType(typeURL)}
+		p.types[typeURL] = ps
+	}
+	ps.Size = size
+	ps.Budget = budget
+	ps.Exceeded = budget > 0 && int64(size) > budget
+	ps.Time = now
+	largest := map[string]int{}
+	if incremental {
+		for _, r := range ps.Largest {
+			largest[r.Name] = r.Size
+		}
+	}
+	for _, r := range res {
+		if r.Resource != nil {
+			largest[r.Name] = len(r.Resource.Value)
+		}
+	}
+	ps.Largest = largestResources(largest)
+}
+
+func (p *pushSizes) list() []PushSize {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]PushSize, 0, len(p.types))
+	for _, ps := range p.types {
+		out = append(out, *ps)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Type < out[j].Type
+	})
+	return out
+}
+
+func (p *pushSizes) largest(typeURL string) []SizedResource {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if ps := p.types[typeURL]; ps != nil {
+		return ps.Largest
+	}
+	return nil
+}
+
+// largestResources returns the largest of the resources, by decreasing size.
+func largestResources(sizes map[string]int) []SizedResource {
+	out := make([]SizedResource, 0, len(sizes))
+	for name, size := range sizes {
+		out = append(out, SizedResource{Name: name, Size: size})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Size != out[j].Size {
+			return out[i].Size > out[j].Size
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > maxLargestResources {
+		out = out[:maxLargestResources]
+	}
+	return out
+}
+
+// checkPushSize records the size of a push to the proxy, and warns if it exceeds the budget of its type.
+func (s *DiscoveryServer) checkPushSize(con *Connection, typeURL string, res model.Resources, size int, incremental bool) {
+	budget := s.pushSizeBudgets[typeURL]
+	con.pushSizes.record(typeURL, res, size, budget, incremental, time.Now())
+	if budget == 0 || int64(size) <= budget {
+		return
+	}
+	pushSizeBudgetExceeded.With(typeTag.Value(v3.GetMetricType(typeURL))).Increment()
+	largest := make([]string, 0, maxLargestResources)
+	for _, r := range con.pushSizes.largest(typeURL) {
+		largest = append(largest, fmt.Sprintf("%s (%s)", r.Name, util.ByteCount(r.Size)))
+	}
+	log.Warnf("%s: push of %s for node:%s exceeds the budget of %s, largest resources: %s", v3.GetShortType(typeURL),
+		util.ByteCount(size), con.proxy.ID, util.ByteCount(int(budget)), strings.Join(largest, ", "))
+}
+
+// pushSizez lists the sizes of the last pushes to the connected proxies by type, with their largest resources,
+// optionally of a single proxyID or only those exceeding their budget with exceeded=true. The proxies are listed by
+// decreasing size of their largest push.
+// It is mapped to /debug/push_sizes
+func (s *DiscoveryServer) pushSizez(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	exceeded := req.URL.Query().Get("exceeded") == "true"
+	out := make([]ProxyPushSizes, 0)
+	maxSize := map[string]int{}
+	for _, con := range s.AllClients() {
+		if proxyID != "" && con.proxy.ID != proxyID {
+			continue
+		}
+		sizes := con.pushSizes.list()
+		if exceeded {
+			filtered := sizes[:0]
+			for _, ps := range sizes {
+				if ps.Exceeded {
+					filtered = append(filtered, ps)
+				}
+			}
+			sizes = filtered
+		}
+		if len(sizes) == 0 {
+			continue
+		}
+		for _, ps := range sizes {
+			if ps.Size > maxSize[con.proxy.ID] {
+				maxSize[con.proxy.ID] = ps.Size
+			}
+		}
+		out = append(out, ProxyPushSizes{ProxyID: con.proxy.ID, Types: sizes})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if maxSize[out[i].ProxyID] != maxSize[out[j].ProxyID] {
+			return maxSize[out[i].ProxyID] > maxSize[out[j].ProxyID]
+		}
+		return out[i].ProxyID < out[j].ProxyID
+	})
+	writeJSON(w, out, req)
+}
@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestParsePushSizeBudgets(t *testing.T) {
+	cases := []struct {
+		name    string
+		budgets string
+		want    map[string]int64
+		wantErr bool
+	}{
+		{name: "empty", want: map[string]int64{}},
+		{name: "quantities", budgets: "RDS=10Mi, cds=1000", want: map[string]int64{v3.RouteType: 10 * 1024 * 1024, v3.ClusterType: 1000}},
+		{name: "missing size", budgets: "RDS", wantErr: true},
+		{name: "invalid size", budgets: "RDS=big", wantErr: true},
+		{name: "zero size", budgets: "RDS=0", wantErr: true},
+		{name: "unknown type", budgets: "XDS=1Mi", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePushSizeBudgets(tt.budgets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got budgets %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushSizes(t *testing.T) {
+	s := &DiscoveryServer{adsClients: map[string]*Connection{}, pushSizeBudgets: map[string]int64{v3.RouteType: 100}}
+	newCon := func(conID, proxyID string) *Connection {
+		con := &Connection{conID: conID, pushSizes: &pushSizes{}, proxy: &model.Proxy{ID: proxyID}}
+		s.adsClients[conID] = con
+		return con
+	}
+	resources := func(sizes map[string]int) model.Resources {
+		res := model.Resources{}
+		for name, size := range sizes {
+			res = append(res, &discovery.Resource{Name: name, Resource: &anypb.Any{Value: make([]byte, size)}})
+		}
+		return res
+	}
+	a := newCon("gateway-a-1", "gateway-a.istio-system")
+	b := newCon("gateway-b-1", "gateway-b.istio-system")
+
+	routes := resources(map[string]int{"80": 150, "443": 20, "8080": 10, "8443": 5, "9080": 3, "9443": 1})
+	s.checkPushSize(a, v3.RouteType, routes, ResourceSize(routes), false)
+	clusters := resources(map[string]int{"outbound|80||a": 50})
+	s.checkPushSize(b, v3.ClusterType, clusters, ResourceSize(clusters), false)
+
+	sizes := a.pushSizes.list()
+	if len(sizes) != 1 || sizes[0].Type != "RDS" || sizes[0].Size != 189 || sizes[0].Budget != 100 || !sizes[0].Exceeded {
+		t.Fatalf("unexpected push sizes %+v", sizes)
+	}
+	want := []SizedResource{{"80", 150}, {"443", 20}, {"8080", 10}, {"8443", 5}, {"9080", 3}}
+	if !reflect.DeepEqual(sizes[0].Largest, want) {
+		t.Errorf("got largest resources %v, want %v", sizes[0].Largest, want)
+	}
+
+	// An incremental push is merged into the largest resources.
+	update := resources(map[string]int{"443": 200})
+	s.checkPushSize(a, v3.RouteType, update, ResourceSize(update), true)
+	largest := a.pushSizes.largest(v3.RouteType)
+	if largest[0] != (SizedResource{"443", 200}) || largest[1] != (SizedResource{"80", 150}) {
+		t.Errorf("expected the incremental push to be merged, got %v", largest)
+	}
+
+	w := httptest.NewRecorder()
+	s.pushSizez(w, httptest.NewRequest(http.MethodGet, "/debug/push_sizes", nil))
+	var got []ProxyPushSizes
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ProxyID != "gateway-a.istio-system" || got[1].ProxyID != "gateway-b.istio-system" {
+		t.Errorf("expected the proxies by decreasing push size, got %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	s.pushSizez(w, httptest.NewRequest(http.MethodGet, "/debug/push_sizes?exceeded=true", nil))
+	if !strings.Contains(w.Body.String(), "gateway-a") || strings.Contains(w.Body.String(), "gateway-b") {
+		t.Errorf("expected only the proxies exceeding their budgets, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/tracing"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// Pushes to a connection are traced as a span holding a child span per pushed type, so the generator
+// dominating the latency of a push shows up in the trace. Spans are only recorded once pkg/tracing is
+// initialized, see PILOT_ENABLE_PUSH_TRACING.
+
+// maxTracedConfigs bounds the number of updated configs recorded on a push span.
+const maxTracedConfigs = 10
+
+// startPushSpan starts the span of a push to the connection.
+func startPushSpan(con *Connection, req *model.PushRequest) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(context.Background(), "xds.push")
+	if !span.IsRecording() {
+		return ctx, span
+	}
+	span.SetAttributes(
+		attribute.String("proxy.id", con.proxy.ID),
+		attribute.String("proxy.type", string(con.proxy.Type)),
+		attribute.String("push.reason", req.PushReason()),
+		attribute.Bool("push.full", req.Full),
+		attribute.Int("push.configs_updated", len(req.ConfigsUpdated)),
+	)
+	if len(req.ConfigsUpdated) > 0 {
+		kinds := sets.New[string]()
+		configs := make([]string, 0, maxTracedConfigs)
+		for key := range req.ConfigsUpdated {
+			kinds.Insert(key.Kind.String())
+			if len(configs) < maxTracedConfigs {
+				configs = append(configs, key.String())
+			}
+		}
+		span.SetAttributes(
+			attribute.StringSlice("push.trigger_kinds", sets.SortedList(kinds)),
+			attribute.StringSlice("push.trigger_configs", configs),
+		)
+	}
+	return ctx, span
+}
+
+// startGeneratorSpan starts the span of the push of a type within the push span.
+func startGeneratorSpan(ctx context.Context, typeURL string) trace.Span {
+	_, span := tracing.Start(ctx, "xds.generate."+v3.GetShortType(typeURL))
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("xds.type", typeURL))
+	}
+	return span
+}
+
+// endSpan ends the span, recording the error if any.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
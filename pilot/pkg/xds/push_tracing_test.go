@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestPushTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	con := &Connection{proxy: &model.Proxy{ID: "gateway.istio-system", Type: model.Router}}
+	req := &model.PushRequest{
+		Full:           true,
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Secret, Name: "cert", Namespace: "istio-system"}),
+		Reason:         model.NewReasonStats(model.SecretTrigger),
+	}
+	ctx, span := startPushSpan(con, req)
+	endSpan(startGeneratorSpan(ctx, v3.SecretType), nil)
+	endSpan(startGeneratorSpan(ctx, v3.ListenerType), errors.New("send failure"))
+	endSpan(span, nil)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	sds, lds, push := spans[0], spans[1], spans[2]
+	if push.Name != "xds.push" || sds.Name != "xds.generate.SDS" || lds.Name != "xds.generate.LDS" {
+		t.Fatalf("unexpected span names %q %q %q", push.Name, sds.Name, lds.Name)
+	}
+	for _, s := range []tracetest.SpanStub{sds, lds} {
+		if s.Parent.SpanID() != push.SpanContext.SpanID() {
+			t.Fatalf("span %s is not a child of the push span", s.Name)
+		}
+	}
+	if lds.Status.Code != codes.Error {
+		t.Fatalf("expected the failed push to be recorded, got %v", lds.Status)
+	}
+	attrs := map[string]string{}
+	for _, a := range push.Attributes {
+		attrs[string(a.Key)] = a.Value.Emit()
+	}
+	if attrs["proxy.id"] != "gateway.istio-system" || attrs["push.trigger_kinds"] != "[Secret]" {
+		t.Fatalf("unexpected push span attributes %v", attrs)
+	}
+}
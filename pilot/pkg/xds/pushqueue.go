@@ -18,6 +18,7 @@ import (
 	"sync"
 
 	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/util/sets"
 )
 
 type PushQueue struct {
@@ -35,6 +36,18 @@ type PushQueue struct {
 	// If model.PushRequest is not nil, it will be Enqueued again once MarkDone has been called.
 	processing map[*Connection]*model.PushRequest
 
+	// Added by Ingress
+	// isPriority tells the pushes which are dequeued before the others, if set.
+	isPriority func(*model.PushRequest) bool
+	// priorityQueue maintains ordering of the priority pushes. The connections it holds are also in queue.
+	// The entries of a connection which is no longer in prioritized, or in pending, are skipped on Dequeue.
+	priorityQueue []*Connection
+	// prioritized stores the connections with a priority push pending, or enqueued while being processed.
+	prioritized sets.Set[*Connection]
+	// skipped counts the entries left in queue by the connections dequeued from priorityQueue.
+	skipped map[*Connection]int
+	// End added by Ingress
+
 	shuttingDown bool
 }
 
@@ -42,10 +55,34 @@ func NewPushQueue() *PushQueue {
 	return &PushQueue{
 		pending:    make(map[*Connection]*model.PushRequest),
 		processing: make(map[*Connection]*model.PushRequest),
-		cond:       sync.NewCond(&sync.Mutex{}),
+		// Added by Ingress
+		prioritized: sets.New[*Connection](),
+		skipped:     make(map[*Connection]int),
+		// End added by Ingress
+		cond: sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// Added by Ingress
+
+// NewPriorityPushQueue creates a PushQueue dequeuing the pushes for which isPriority returns true
+// before the others.
+func NewPriorityPushQueue(isPriority func(*model.PushRequest) bool) *PushQueue {
+	p := NewPushQueue()
+	p.isPriority = isPriority
+	return p
+}
+
+// prioritize adds a pending connection to the priority queue, unless it is already there.
+func (p *PushQueue) prioritize(con *Connection) {
+	if p.prioritized.InsertContains(con) {
+		return
 	}
+	p.priorityQueue = append(p.priorityQueue, con)
 }
 
+// End added by Ingress
+
 // Enqueue will mark a proxy as pending a push. If it is already pending, pushInfo will be merged.
 // ServiceEntry updates will be added together, and full will be set if either were full
 func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
@@ -56,19 +93,40 @@ func (p *PushQueue) Enqueue(con *Connection, pushRequest *model.PushRequest) {
 		return
 	}
 
+	// Added by Ingress
+	priority := p.isPriority != nil && p.isPriority(pushRequest)
+	// End added by Ingress
+
 	// If its already in progress, merge the info and return
 	if request, f := p.processing[con]; f {
 		p.processing[con] = request.CopyMerge(pushRequest)
+		// Added by Ingress
+		if priority {
+			// The connection is prioritized once enqueued again by MarkDone.
+			p.prioritized.Insert(con)
+		}
+		// End added by Ingress
 		return
 	}
 
 	if request, f := p.pending[con]; f {
 		p.pending[con] = request.CopyMerge(pushRequest)
+		// Added by Ingress
+		if priority {
+			p.prioritize(con)
+			p.cond.Signal()
+		}
+		// End added by Ingress
 		return
 	}
 
 	p.pending[con] = pushRequest
 	p.queue = append(p.queue, con)
+	// Added by Ingress
+	if priority {
+		p.prioritize(con)
+	}
+	// End added by Ingress
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
@@ -79,20 +137,51 @@ func (p *PushQueue) Dequeue() (con *Connection, request *model.PushRequest, shut
 	defer p.cond.L.Unlock()
 
 	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.queue) == 0 && !p.shuttingDown {
+	for len(p.pending) == 0 && !p.shuttingDown {
 		p.cond.Wait()
 	}
 
-	if len(p.queue) == 0 {
+	if len(p.pending) == 0 {
 		// We must be shutting down.
 		return nil, nil, true
 	}
 
-	con = p.queue[0]
-	// The underlying array will still exist, despite the slice changing, so the object may not GC without this
-	// See https://github.com/grpc/grpc-go/issues/4758
-	p.queue[0] = nil
-	p.queue = p.queue[1:]
+	// Added by Ingress
+	for con == nil && len(p.priorityQueue) > 0 {
+		con = p.priorityQueue[0]
+		p.priorityQueue[0] = nil
+		p.priorityQueue = p.priorityQueue[1:]
+		if _, f := p.pending[con]; !f || !p.prioritized.Contains(con) {
+			// Stale entry, the connection was dequeued from queue first.
+			con = nil
+			continue
+		}
+		// Its entry in queue is the oldest one of the connection, it is skipped when reached.
+		p.skipped[con]++
+	}
+	// End added by Ingress
+
+	for con == nil {
+		con = p.queue[0]
+		// The underlying array will still exist, despite the slice changing, so the object may not GC without this
+		// See https://github.com/grpc/grpc-go/issues/4758
+		p.queue[0] = nil
+		p.queue = p.queue[1:]
+		// Added by Ingress
+		if n := p.skipped[con]; n > 0 {
+			// Stale entry, the connection was dequeued from priorityQueue first.
+			if n == 1 {
+				delete(p.skipped, con)
+			} else {
+				p.skipped[con] = n - 1
+			}
+			con = nil
+		}
+		// End added by Ingress
+	}
+	// Added by Ingress
+	p.prioritized.Delete(con)
+	// End added by Ingress
 
 	request = p.pending[con]
 	delete(p.pending, con)
@@ -114,6 +203,11 @@ func (p *PushQueue) MarkDone(con *Connection) {
 	if request != nil {
 		p.pending[con] = request
 		p.queue = append(p.queue, con)
+		// Added by Ingress
+		if p.prioritized.Contains(con) {
+			p.priorityQueue = append(p.priorityQueue, con)
+		}
+		// End added by Ingress
 		p.cond.Signal()
 	}
 }
@@ -122,9 +216,21 @@ func (p *PushQueue) MarkDone(con *Connection) {
 func (p *PushQueue) Pending() int {
 	p.cond.L.Lock()
 	defer p.cond.L.Unlock()
-	return len(p.queue)
+	return len(p.pending)
 }
 
+// Added by Ingress
+
+// isPending returns true if a push is queued for the connection, or queued again while it is processed.
+func (p *PushQueue) isPending(con *Connection) bool {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+	_, pending := p.pending[con]
+	return pending || p.processing[con] != nil
+}
+
+// End added by Ingress
+
 // ShutDown will cause queue to ignore all new items added to it. As soon as the
 // worker goroutines have drained the existing items in the queue, they will be
 // instructed to exit.
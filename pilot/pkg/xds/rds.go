@@ -56,6 +56,12 @@ func rdsNeedsPush(req *model.PushRequest) bool {
 			return true
 		}
 	}
+	// Added by Ingress
+	// Routes selecting WasmPlugins by annotation disable the other plugins, so they change with the WasmPlugins.
+	if req.Push != nil && req.Push.HasWasmPluginsAnnotation() && model.HasConfigsOfKind(req.ConfigsUpdated, kind.WasmPlugin) {
+		return true
+	}
+	// End added by Ingress
 	return false
 }
 
@@ -32,6 +32,7 @@ import (
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	mesh "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
 	credscontroller "istio.io/istio/pilot/pkg/credentials"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
@@ -40,6 +41,7 @@ import (
 	"istio.io/istio/pilot/pkg/util/protoconv"
 	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/cluster"
+	"istio.io/istio/pkg/config/gateway"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -48,6 +50,10 @@ import (
 type SecretResource struct {
 	credentials.SecretResource
 	pkpConfHash string
+	// Added by ingress
+	// federatedSANs are the SANs of the other trust domains accepted by a CA secret served to an east-west gateway.
+	federatedSANs []string
+	// End added by ingress
 }
 
 var _ model.XdsCacheEntry = SecretResource{}
@@ -57,6 +63,11 @@ func (sr SecretResource) Type() string {
 }
 
 func (sr SecretResource) Key() any {
+	// Modified by ingress
+	if len(sr.federatedSANs) > 0 {
+		return sr.SecretResource.Key() + "/" + sr.pkpConfHash + "/" + federatedSANsHash(sr.federatedSANs)
+	}
+	// End modified by ingress
 	return sr.SecretResource.Key() + "/" + sr.pkpConfHash
 }
 
@@ -104,7 +115,7 @@ func (s *SecretGen) parseResources(names []string, proxy *model.Proxy) []SecretR
 			log.Warnf("error parsing resource name: %v", err)
 			continue
 		}
-		res = append(res, SecretResource{sr, pkpConfHashStr})
+		res = append(res, SecretResource{SecretResource: sr, pkpConfHash: pkpConfHashStr})
 	}
 	return res
 }
@@ -114,9 +125,11 @@ func (s *SecretGen) Generate(proxy *model.Proxy, w *model.WatchedResource, req *
 		log.Warnf("proxy %s is not authorized to receive credscontroller. Ensure you are connecting over TLS port and are authenticated.", proxy.ID)
 		return nil, model.DefaultXdsLogDetails, nil
 	}
-	if req == nil || !sdsNeedsPush(req.ConfigsUpdated) {
+	// Modified by Ingress
+	if req == nil || !(sdsNeedsPush(req.ConfigsUpdated) || sdsGatewayNeedsPush(proxy, req.Push, w, req.ConfigsUpdated)) {
 		return nil, model.DefaultXdsLogDetails, nil
 	}
+	// End modified by Ingress
 	var updatedSecrets sets.Set[model.ConfigKey]
 	if !req.Full {
 		updatedSecrets = model.ConfigsOfKind(req.ConfigsUpdated, kind.Secret)
@@ -139,7 +152,10 @@ func (s *SecretGen) Generate(proxy *model.Proxy, w *model.WatchedResource, req *
 	// Filter down to resources we can access. We do not return an error if they attempt to access a Secret
 	// they cannot; instead we just exclude it. This ensures that a single bad reference does not break the whole
 	// SDS flow. The pilotSDSCertificateErrors metric and logs handle visibility into invalid references.
-	resources := filterAuthorizedResources(s.parseResources(w.ResourceNames, proxy), proxy, proxyClusterSecrets)
+	// Modified by Ingress
+	resources := filterAuthorizedResources(s.parseResources(sdsResourceNames(proxy, req.Push, w), proxy), proxy, proxyClusterSecrets,
+		s.secrets, s.authorizer, waypointFrontedServiceAccount(proxy, req.Push))
+	// End modified by Ingress
 
 	results := model.Resources{}
 	cached, regenerated := 0, 0
@@ -151,6 +167,9 @@ func (s *SecretGen) Generate(proxy *model.Proxy, w *model.WatchedResource, req *
 			}
 		}
 
+		// Added by ingress
+		sr.federatedSANs = federatedSANs(proxy, req.Push, sr)
+		// End added by ingress
 		cachedItem := s.cache.Get(sr)
 		if cachedItem != nil && !features.EnableUnsafeAssertions {
 			// If it is in the Cache, add it and continue
@@ -172,6 +191,91 @@ func (s *SecretGen) Generate(proxy *model.Proxy, w *model.WatchedResource, req *
 	}, nil
 }
 
+// Added by Ingress
+
+// sdsResourceNames returns the secrets to generate for the watched resource. By default, as upstream, only the
+// secrets the proxy explicitly subscribed to are generated, along with the fallback certificates served by its
+// gateways to the clients whose SNI matches no server, which its listeners may reference. A wildcard delta
+// subscription of a proxy opting in with the WILDCARD_SDS metadata is expanded to all the secrets referenced by
+// its gateways.
+func sdsResourceNames(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource) []string {
+	if proxy.MergedGateway == nil {
+		return w.ResourceNames
+	}
+	fallbacks := gatewayFallbackResourceNames(proxy, push)
+	if !wildcardSDS(proxy, w) {
+		if len(fallbacks) == 0 {
+			return w.ResourceNames
+		}
+		return sets.SortedList(sets.New(w.ResourceNames...).InsertAll(fallbacks...))
+	}
+	names := sets.New(w.ResourceNames...).InsertAll(fallbacks...)
+	for _, ms := range proxy.MergedGateway.MergedServers {
+		for _, server := range ms.Servers {
+			cn := server.GetTls().GetCredentialName()
+			if cn == "" {
+				continue
+			}
+			rn := credentials.ToResourceName(cn)
+			names.Insert(rn)
+			if server.GetTls().GetMode() == networking.ServerTLSSettings_MUTUAL {
+				names.Insert(rn + credentials.SdsCaSuffix)
+			}
+		}
+	}
+	gatewayNames := sets.New[string]()
+	for _, gatewayName := range proxy.MergedGateway.GatewayNameForServer {
+		gatewayNames.Insert(gatewayName)
+	}
+	for gatewayName := range gatewayNames {
+		if spec := push.OIDC(gatewayName); spec != nil {
+			namespace, _, _ := strings.Cut(gatewayName, "/")
+			names.InsertAll(credentials.ToGenericSecretResourceName(namespace, spec.CredentialName, spec.ClientSecretKey),
+				credentials.ToGenericSecretResourceName(namespace, spec.CredentialName, spec.HMACSecretKey))
+		}
+	}
+	return sets.SortedList(names)
+}
+
+// gatewayFallbackResourceNames returns the fallback certificates of the gateways of the proxy. As for the fallback
+// filter chains of the listeners, a port serves the fallback certificate of the gateway of its first HTTPS server
+// using simple TLS.
+func gatewayFallbackResourceNames(proxy *model.Proxy, push *model.PushContext) []string {
+	if push == nil || proxy.MergedGateway == nil {
+		return nil
+	}
+	var names []string
+	for _, ms := range proxy.MergedGateway.MergedServers {
+		for _, server := range ms.Servers {
+			if !gateway.IsHTTPSServerWithTLSTermination(server) || server.Tls.Mode != networking.ServerTLSSettings_SIMPLE {
+				continue
+			}
+			if fallback := push.FallbackCredentialName(proxy.MergedGateway.GatewayNameForServer[server]); fallback != "" {
+				names = append(names, credentials.ToResourceName(fallback))
+			}
+			break
+		}
+	}
+	return names
+}
+
+// sdsGatewayNeedsPush returns true if the updates may change the secrets depending on the gateways of the proxy,
+// those of an expanded wildcard subscription or its fallback certificates.
+func sdsGatewayNeedsPush(proxy *model.Proxy, push *model.PushContext, w *model.WatchedResource, updates model.XdsUpdates) bool {
+	if len(model.ConfigsOfKind(updates, kind.Gateway)) == 0 {
+		return false
+	}
+	return wildcardSDS(proxy, w) || len(gatewayFallbackResourceNames(proxy, push)) > 0
+}
+
+// wildcardSDS returns true if the wildcard subscription is expanded to the secrets of the gateways of the proxy,
+// which must opt in.
+func wildcardSDS(proxy *model.Proxy, w *model.WatchedResource) bool {
+	return w.Wildcard && bool(proxy.Metadata.WildcardSDS)
+}
+
+// End added by Ingress
+
 func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClusterSecrets credscontroller.Controller, proxy *model.Proxy) *discovery.Resource {
 	// Fetch the appropriate cluster's secret, based on the credential type
 	var secretController credscontroller.Controller
@@ -179,6 +283,14 @@ func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClust
 	switch sr.ResourceType {
 	case credentials.KubernetesGatewaySecretType:
 		secretController = configClusterSecrets
+	// Added by ingress
+	case credentials.FileSecretType, credentials.AWSSecretsManagerSecretType, credentials.AlibabaKMSSecretType:
+		if secretController = s.external[sr.ResourceType]; secretController == nil {
+			log.Warnf("failed to fetch key and certificate for %s: %s credentials are not enabled", sr.ResourceName, sr.ResourceType)
+			pilotSDSCertificateErrors.Increment()
+			return nil
+		}
+	// End added by ingress
 	case credentials.KubernetesIngressSecretType:
 		// Added by ingress
 		if secretController, err = s.secrets.ForCluster(sr.Cluster); err != nil {
@@ -189,8 +301,23 @@ func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClust
 		// End added by ingress
 	default:
 		secretController = proxyClusterSecrets
+		// Added by ingress
+		// The resource name selected a cluster other than the one of the proxy.
+		if sr.ResourceType == credentials.KubernetesSecretType && sr.Cluster != proxy.Metadata.ClusterID {
+			if secretController, err = s.secrets.ForCluster(sr.Cluster); err != nil {
+				log.Warnf("failed to fetch key and certificate for %s from unknown cluster %s: %v", sr.ResourceName, sr.Cluster, err)
+				pilotSDSCertificateErrors.Increment()
+				return nil
+			}
+		}
+		// End added by ingress
 	}
 
+	// Added by ingress
+	if sr.DataKey != "" {
+		return generateGenericSecret(sr, secretController)
+	}
+	// End added by ingress
 	isCAOnlySecret := strings.HasSuffix(sr.Name, securitymodel.SdsCaSuffix)
 	if isCAOnlySecret {
 		caCertInfo, err := secretController.GetCaCert(sr.Name, sr.Namespace)
@@ -204,7 +331,14 @@ func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClust
 				recordInvalidCertificate(sr.ResourceName, err)
 			}
 		}
-		res := toEnvoyCaSecret(sr.ResourceName, caCertInfo)
+		// Added by ingress
+		if !guardSecret(sr.ResourceName, caCertInfo, true) {
+			return nil
+		}
+		// End added by ingress
+		// Modified by ingress
+		res := toEnvoyCaSecret(sr.ResourceName, caCertInfo, sr.federatedSANs)
+		// End modified by ingress
 		return res
 	}
 	certInfo, err := secretController.GetCertInfo(sr.Name, sr.Namespace)
@@ -218,6 +352,11 @@ func (s *SecretGen) generate(sr SecretResource, configClusterSecrets, proxyClust
 			recordInvalidCertificate(sr.ResourceName, err)
 		}
 	}
+	// Added by ingress
+	if !guardSecret(sr.ResourceName, certInfo, false) {
+		return nil
+	}
+	// End added by ingress
 	res := toEnvoyTLSSecret(sr.ResourceName, certInfo, proxy, s.meshConfig)
 	return res
 }
@@ -247,7 +386,11 @@ func recordInvalidCertificate(name string, err error) {
 }
 
 // filterAuthorizedResources takes a list of SecretResource and filters out resources that proxy cannot access
-func filterAuthorizedResources(resources []SecretResource, proxy *model.Proxy, secrets credscontroller.Controller) []SecretResource {
+// Modified by ingress
+func filterAuthorizedResources(resources []SecretResource, proxy *model.Proxy, secrets credscontroller.Controller,
+	clusters credscontroller.MulticlusterController, authorizer credscontroller.SecretAuthorizer, fronted string,
+) []SecretResource {
+	// End modified by ingress
 	// Added by ingress
 	// We can not check whether the mse gateway access the target secret resource.
 	// So, we just pass it.
@@ -264,14 +407,71 @@ func filterAuthorizedResources(resources []SecretResource, proxy *model.Proxy, s
 			return *authzResult
 		}
 		res := false
+		// Added by ingress
+		start := time.Now()
+		// End added by ingress
 		if err := secrets.Authorize(proxy.VerifiedIdentity.ServiceAccount, proxy.VerifiedIdentity.Namespace); err == nil {
 			res = true
 		} else {
 			authzError = err
 		}
+		// Added by ingress
+		sdsAuthorizationTime.Record(time.Since(start).Seconds())
+		// End added by ingress
 		authzResult = &res
 		return res
 	}
+	// Added by ingress
+	// isAuthorizedInCluster authorizes the proxy in a cluster selected by a resource name, once per cluster.
+	clusterAuthzResults := map[cluster.ID]bool{}
+	isAuthorizedInCluster := func(c cluster.ID) bool {
+		if c == proxy.Metadata.ClusterID || clusters == nil {
+			return isAuthorized()
+		}
+		if res, f := clusterAuthzResults[c]; f {
+			return res
+		}
+		res := false
+		start := time.Now()
+		if controller, err := clusters.ForCluster(c); err != nil {
+			authzError = err
+		} else if err := controller.Authorize(proxy.VerifiedIdentity.ServiceAccount, proxy.VerifiedIdentity.Namespace); err != nil {
+			authzError = err
+		} else {
+			res = true
+		}
+		sdsAuthorizationTime.Record(time.Since(start).Seconds())
+		clusterAuthzResults[c] = res
+		return res
+	}
+	// isSecretAuthorized asks the external authorizer, if any, instead of isAuthorizedInCluster.
+	isSecretAuthorized := func(r SecretResource, c cluster.ID) bool {
+		if authorizer == nil {
+			return isAuthorizedInCluster(c)
+		}
+		err := authorizer.AuthorizeSecret(proxy.VerifiedIdentity.ServiceAccount, proxy.VerifiedIdentity.Namespace,
+			r.Name, r.Namespace)
+		if err != nil {
+			authzError = err
+		}
+		return err == nil
+	}
+	// isFrontedAuthorized authorizes a waypoint proxy with the identity of the service account it fronts, once per
+	// cluster.
+	frontedAuthzResults := map[cluster.ID]bool{}
+	isFrontedAuthorized := func(r SecretResource) bool {
+		if fronted == "" {
+			return false
+		}
+		if res, f := frontedAuthzResults[r.Cluster]; f {
+			return res
+		}
+		res := authorizeFronted(r, proxy, fronted, secrets, clusters, authorizer) == nil
+		frontedAuthzResults[r.Cluster] = res
+		return res
+	}
+	var frontedResources []string
+	// End added by ingress
 
 	// There are 4 cases of secret reference
 	// Verified cross namespace (by ReferencePolicy). No Authz needed.
@@ -296,14 +496,27 @@ func filterAuthorizedResources(resources []SecretResource, proxy *model.Proxy, s
 		case credentials.KubernetesSecretType:
 			// For Kubernetes, we require the secret to be in the same namespace as the proxy and for it to be
 			// authorized for access.
-			if sameNamespace && isAuthorized() {
+			// Modified by ingress
+			if sameNamespace && isSecretAuthorized(r, r.Cluster) {
+				allowedResources = append(allowedResources, r)
+			} else if sameNamespace && isFrontedAuthorized(r) {
 				allowedResources = append(allowedResources, r)
+				frontedResources = append(frontedResources, r.Name)
 			} else {
 				deniedResources = append(deniedResources, r.Name)
 			}
+			// End modified by ingress
 			// Added by ingress
+		case credentials.FileSecretType, credentials.AWSSecretsManagerSecretType, credentials.AlibabaKMSSecretType:
+			// Secrets stored outside of Kubernetes have no owner, so only the references of the gateways of the
+			// namespace of the proxy are allowed.
+			if verified {
+				allowedResources = append(allowedResources, r)
+			} else {
+				deniedResources = append(deniedResources, r.Name)
+			}
 		case credentials.KubernetesIngressSecretType:
-			if isAuthorized() {
+			if isSecretAuthorized(r, proxy.Metadata.ClusterID) {
 				allowedResources = append(allowedResources, r)
 			} else {
 				deniedResources = append(deniedResources, r.Name)
@@ -326,6 +539,9 @@ func filterAuthorizedResources(resources []SecretResource, proxy *model.Proxy, s
 		log.Warnf("proxy %s attempted to access unauthorized certificates %s: %v", proxy.ID, atMostNJoin(deniedResources, 3), errMessage)
 		pilotSDSCertificateErrors.Increment()
 	}
+	// Added by ingress
+	auditFrontedGrants(proxy, fronted, frontedResources)
+	// End added by ingress
 
 	return allowedResources
 }
@@ -344,7 +560,9 @@ func atMostNJoin(data []string, limit int) string {
 	return strings.Join(data[:limit-1], ", ") + fmt.Sprintf(", and %d others", len(data)-limit+1)
 }
 
-func toEnvoyCaSecret(name string, certInfo *credscontroller.CertInfo) *discovery.Resource {
+// Modified by ingress
+func toEnvoyCaSecret(name string, certInfo *credscontroller.CertInfo, federatedSANs []string) *discovery.Resource {
+	// End modified by ingress
 	validationContext := &envoytls.CertificateValidationContext{
 		TrustedCa: &core.DataSource{
 			Specifier: &core.DataSource_InlineBytes{
@@ -359,6 +577,9 @@ func toEnvoyCaSecret(name string, certInfo *credscontroller.CertInfo) *discovery
 			},
 		}
 	}
+	// Added by ingress
+	withFederatedSANs(validationContext, federatedSANs)
+	// End added by ingress
 	res := protoconv.MessageToAny(&envoytls.Secret{
 		Name: name,
 		Type: &envoytls.Secret_ValidationContext{
@@ -498,6 +719,12 @@ type SecretGen struct {
 	cache         model.XdsCache
 	configCluster cluster.ID
 	meshConfig    *mesh.MeshConfig
+	// Added by ingress
+	// authorizer, if set, authorizes the proxies for each secret instead of the credentials controller.
+	authorizer credscontroller.SecretAuthorizer
+	// external serves the secrets stored outside of Kubernetes, keyed by their type.
+	external map[string]credscontroller.Controller
+	// End added by ingress
 }
 
 var _ model.XdsResourceGenerator = &SecretGen{}
@@ -507,10 +734,18 @@ func NewSecretGen(sc credscontroller.MulticlusterController, cache model.XdsCach
 ) *SecretGen {
 	// TODO: Currently we only have a single credentials controller (Kubernetes). In the future, we will need a mapping
 	// of resource type to secret controller (ie kubernetes:// -> KubernetesController, vault:// -> VaultController)
-	return &SecretGen{
+	gen := &SecretGen{
 		secrets:       sc,
 		cache:         cache,
 		configCluster: configCluster,
 		meshConfig:    meshConfig,
 	}
+	// Added by ingress
+	if alifeatures.SDSExternalAuthorizer != "" {
+		gen.authorizer = credscontroller.NewHTTPSecretAuthorizer(alifeatures.SDSExternalAuthorizer,
+			alifeatures.SDSExternalAuthorizerTimeout, alifeatures.SecretAuthorizationCacheTTL,
+			alifeatures.SecretAuthorizationNegativeCacheTTL)
+	}
+	// End added by ingress
+	return gen
 }
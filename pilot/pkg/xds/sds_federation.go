@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strconv"
+	"strings"
+
+	xxhashv2 "github.com/cespare/xxhash/v2"
+	envoytls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// eastWestGateway returns true if the proxy is a gateway of a network with gateways declared for the cross-network
+// traffic, by MeshNetworks or the registries.
+func eastWestGateway(proxy *model.Proxy, push *model.PushContext) bool {
+	if proxy.Type != model.Router || proxy.Metadata.Network == "" || push == nil || push.NetworkManager() == nil {
+		return false
+	}
+	return len(push.NetworkManager().GatewaysForNetwork(proxy.Metadata.Network)) > 0
+}
+
+// federatedSANs returns the SANs a CA secret served to an east-west gateway also accepts, so that the clients of
+// the clusters federated with other trust domains are accepted: the SPIFFE SANs of the mutual TLS servers
+// of the gateway using the credential, rewritten for the trust domain and the trust domain aliases of the mesh.
+// The SANs themselves are left out, as they are already matched by the listeners.
+func federatedSANs(proxy *model.Proxy, push *model.PushContext, sr SecretResource) []string {
+	if !alifeatures.EnableSDSTrustDomainMapping || proxy.MergedGateway == nil ||
+		!strings.HasSuffix(sr.Name, credentials.SdsCaSuffix) || !eastWestGateway(proxy, push) {
+		return nil
+	}
+	sans := sets.New[string]()
+	for _, ms := range proxy.MergedGateway.MergedServers {
+		for _, server := range ms.Servers {
+			tls := server.GetTls()
+			if tls.GetMode() != networking.ServerTLSSettings_MUTUAL || tls.GetCredentialName() == "" ||
+				credentials.ToResourceName(tls.GetCredentialName())+credentials.SdsCaSuffix != sr.ResourceName {
+				continue
+			}
+			sans.InsertAll(tls.GetSubjectAltNames()...)
+		}
+	}
+	if len(sans) == 0 {
+		return nil
+	}
+	trustDomains := append([]string{push.Mesh.GetTrustDomain()}, push.Mesh.GetTrustDomainAliases()...)
+	return sets.SortedList(spiffe.ExpandWithTrustDomains(sans, trustDomains).DeleteAll(sets.SortedList(sans)...))
+}
+
+// federatedSANsHash returns the hash of the federated SANs of a secret, keying its cache entries.
+func federatedSANsHash(sans []string) string {
+	if len(sans) == 0 {
+		return ""
+	}
+	return strconv.FormatUint(xxhashv2.Sum64String(strings.Join(sans, ",")), 10)
+}
+
+// withFederatedSANs makes a validation context also accept the certificates with any of the SANs.
+func withFederatedSANs(validationContext *envoytls.CertificateValidationContext, sans []string) {
+	for _, san := range sans {
+		validationContext.MatchTypedSubjectAltNames = append(validationContext.MatchTypedSubjectAltNames, &envoytls.SubjectAltNameMatcher{
+			SanType: envoytls.SubjectAltNameMatcher_URI,
+			Matcher: &matcher.StringMatcher{MatchPattern: &matcher.StringMatcher_Exact{Exact: san}},
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	envoytls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/network"
+	"istio.io/istio/pkg/test"
+)
+
+const eastWestGatewayConfig = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: eastwest
+  namespace: istio-system
+spec:
+  selector:
+    istio: eastwestgateway
+  servers:
+  - port:
+      number: 15443
+      name: tls
+      protocol: HTTPS
+    hosts:
+    - "*.global"
+    tls:
+      mode: MUTUAL
+      credentialName: eastwest
+      subjectAltNames:
+      - spiffe://cluster.local/ns/default/sa/client
+      - client.example.com
+`
+
+func TestFederatedSANs(t *testing.T) {
+	test.SetForTest(t, &alifeatures.EnableSDSTrustDomainMapping, true)
+	m := mesh.DefaultMeshConfig()
+	m.TrustDomain = "cluster.local"
+	m.TrustDomainAliases = []string{"cluster-b.local"}
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		ConfigString: eastWestGatewayConfig,
+		MeshConfig:   m,
+		NetworksWatcher: mesh.NewFixedNetworksWatcher(&meshconfig.MeshNetworks{
+			Networks: map[string]*meshconfig.Network{
+				"network-a": {Gateways: []*meshconfig.Network_IstioNetworkGateway{{
+					Gw:   &meshconfig.Network_IstioNetworkGateway_Address{Address: "10.0.0.1"},
+					Port: 15443,
+				}}},
+			},
+		}),
+	})
+	gateway := func(nw network.ID) *model.Proxy {
+		return s.SetupProxy(&model.Proxy{
+			Type:     model.Router,
+			Labels:   map[string]string{"istio": "eastwestgateway"},
+			Metadata: &model.NodeMetadata{Network: nw, Labels: map[string]string{"istio": "eastwestgateway"}},
+		})
+	}
+	sr := func(name string) SecretResource {
+		res, err := credentials.ParseResourceName(credentials.ToResourceName(name), "istio-system", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SecretResource{SecretResource: res}
+	}
+
+	got := federatedSANs(gateway("network-a"), s.PushContext(), sr("eastwest"+credentials.SdsCaSuffix))
+	if want := []string{"spiffe://cluster-b.local/ns/default/sa/client"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got federated SANs %v, want %v", got, want)
+	}
+	if got := federatedSANs(gateway("network-a"), s.PushContext(), sr("eastwest")); got != nil {
+		t.Fatalf("expected no federated SAN for a certificate, got %v", got)
+	}
+	if got := federatedSANs(gateway("network-b"), s.PushContext(), sr("eastwest"+credentials.SdsCaSuffix)); got != nil {
+		t.Fatalf("expected no federated SAN for a gateway of a network without cross-network gateways, got %v", got)
+	}
+
+	key := SecretResource{SecretResource: sr("eastwest" + credentials.SdsCaSuffix).SecretResource}
+	federated := key
+	federated.federatedSANs = got
+	if key.Key() == federated.Key() {
+		t.Fatalf("expected the federated SANs to key the cache entries")
+	}
+
+	res := toEnvoyCaSecret("kubernetes://eastwest-cacert", &credscontroller.CertInfo{Cert: []byte("ca")},
+		[]string{"spiffe://cluster-b.local/ns/default/sa/client"})
+	secret := xdstest.UnmarshalAny[envoytls.Secret](t, res.Resource)
+	sans := secret.GetValidationContext().GetMatchTypedSubjectAltNames()
+	if len(sans) != 1 || sans[0].SanType != envoytls.SubjectAltNameMatcher_URI ||
+		sans[0].GetMatcher().GetExact() != "spiffe://cluster-b.local/ns/default/sa/client" {
+		t.Fatalf("unexpected SAN matchers %v", sans)
+	}
+}
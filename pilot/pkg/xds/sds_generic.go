@@ -0,0 +1,61 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	envoytls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+// generateGenericSecret returns the data of the key of the secret of a resource as a generic secret, such as the
+// client secret of the OAuth2 filter, or nil if the controller of the secret does not serve generic secrets.
+func generateGenericSecret(sr SecretResource, secretController credscontroller.Controller) *discovery.Resource {
+	generic, ok := secretController.(credscontroller.GenericSecretController)
+	if !ok {
+		log.Warnf("failed to fetch generic secret for %s: %s credentials are not supported", sr.ResourceName, sr.ResourceType)
+		pilotSDSCertificateErrors.Increment()
+		return nil
+	}
+	data, err := generic.GetGenericSecret(sr.Name, sr.Namespace, sr.DataKey)
+	if err != nil {
+		log.Warnf("failed to fetch generic secret for %s: %v", sr.ResourceName, err)
+		pilotSDSCertificateErrors.Increment()
+		return nil
+	}
+	return toEnvoyGenericSecret(sr.ResourceName, data)
+}
+
+func toEnvoyGenericSecret(name string, data []byte) *discovery.Resource {
+	res := protoconv.MessageToAny(&envoytls.Secret{
+		Name: name,
+		Type: &envoytls.Secret_GenericSecret{
+			GenericSecret: &envoytls.GenericSecret{
+				Secret: &core.DataSource{
+					Specifier: &core.DataSource_InlineBytes{
+						InlineBytes: data,
+					},
+				},
+			},
+		},
+	})
+	return &discovery.Resource{
+		Name:     name,
+		Resource: res,
+	}
+}
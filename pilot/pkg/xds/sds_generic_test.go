@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"testing"
+
+	envoytls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/model/credentials"
+)
+
+type fakeGenericSecrets struct {
+	credscontroller.Controller
+	data map[string][]byte
+}
+
+func (f fakeGenericSecrets) GetGenericSecret(name, namespace, key string) ([]byte, error) {
+	if data, ok := f.data[namespace+"/"+name+"#"+key]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("secret %s/%s has no key %s", namespace, name, key)
+}
+
+func TestGenerateGenericSecret(t *testing.T) {
+	secrets := fakeGenericSecrets{data: map[string][]byte{"istio-system/oidc#client-secret": []byte("s3cr3t")}}
+	resource := func(name string) SecretResource {
+		sr, err := credentials.ParseResourceName(name, "istio-system", "cluster", "cluster")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SecretResource{SecretResource: sr}
+	}
+
+	res := generateGenericSecret(resource("kubernetes://oidc#client-secret"), secrets)
+	if res == nil {
+		t.Fatal("missing generic secret")
+	}
+	secret := &envoytls.Secret{}
+	if err := res.Resource.UnmarshalTo(secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(secret.GetGenericSecret().GetSecret().GetInlineBytes()); got != "s3cr3t" {
+		t.Errorf("got secret %q, want s3cr3t", got)
+	}
+	if secret.Name != "kubernetes://oidc#client-secret" {
+		t.Errorf("got name %s, want the resource name", secret.Name)
+	}
+
+	if res := generateGenericSecret(resource("kubernetes://oidc#hmac-secret"), secrets); res != nil {
+		t.Errorf("got secret %v for a missing key", res)
+	}
+	if res := generateGenericSecret(resource("kubernetes://oidc#client-secret"), nil); res != nil {
+		t.Errorf("got secret %v from a controller without generic secrets", res)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	alifeatures "istio.io/istio/pkg/ali/features"
+)
+
+// Reasons of the secrets rejected by guardSecret, as labeled in pilotSDSRejectedSecrets.
+const (
+	secretTooLarge    = "too_large"
+	secretNotPEM      = "not_pem"
+	secretKeyMismatch = "key_mismatch"
+)
+
+// guardSecret returns false if the secret must not be sent to proxies, rather than letting them reject it or serve
+// garbage: if it is larger than PILOT_SDS_MAX_SECRET_SIZE, or, with PILOT_SDS_VALIDATE_SECRETS, if its payloads are
+// not PEM encoded or its key does not match its certificate. CA only secrets have no key.
+func guardSecret(resourceName string, certInfo *credscontroller.CertInfo, caOnly bool) bool {
+	reason, err := checkSecret(certInfo, caOnly)
+	if err == nil {
+		return true
+	}
+	pilotSDSRejectedSecrets.With(reasonTag.Value(reason)).Increment()
+	log.Warnf("not sending secret %s to proxies: %v", resourceName, err)
+	return false
+}
+
+func checkSecret(certInfo *credscontroller.CertInfo, caOnly bool) (string, error) {
+	if maxSize := alifeatures.SDSMaxSecretSize; maxSize > 0 {
+		if size := len(certInfo.Cert) + len(certInfo.Key) + len(certInfo.Staple) + len(certInfo.CRL); size > maxSize {
+			return secretTooLarge, fmt.Errorf("the secret is %d bytes, more than the %d allowed", size, maxSize)
+		}
+	}
+	if !alifeatures.SDSValidateSecrets {
+		return "", nil
+	}
+	if !isPEM(certInfo.Cert) {
+		return secretNotPEM, errors.New("the certificate is not PEM encoded")
+	}
+	if len(certInfo.CRL) > 0 && !isPEM(certInfo.CRL) {
+		return secretNotPEM, errors.New("the CRL is not PEM encoded")
+	}
+	if caOnly {
+		return "", nil
+	}
+	if !isPEM(certInfo.Key) {
+		return secretNotPEM, errors.New("the key is not PEM encoded")
+	}
+	if _, err := tls.X509KeyPair(certInfo.Cert, certInfo.Key); err != nil {
+		return secretKeyMismatch, fmt.Errorf("the key does not match the certificate: %v", err)
+	}
+	return "", nil
+}
+
+func isPEM(data []byte) bool {
+	block, _ := pem.Decode(data)
+	return block != nil
+}
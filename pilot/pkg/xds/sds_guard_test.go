@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/testcerts"
+)
+
+func TestCheckSecret(t *testing.T) {
+	test.SetForTest(t, &alifeatures.SDSValidateSecrets, true)
+	test.SetForTest(t, &alifeatures.SDSMaxSecretSize, 4096)
+	cases := []struct {
+		name     string
+		certInfo *credscontroller.CertInfo
+		caOnly   bool
+		want     string
+	}{
+		{
+			name:     "valid",
+			certInfo: &credscontroller.CertInfo{Cert: testcerts.ServerCert, Key: testcerts.ServerKey},
+		},
+		{
+			name:     "valid ca",
+			certInfo: &credscontroller.CertInfo{Cert: testcerts.CACert},
+			caOnly:   true,
+		},
+		{
+			name:     "too large",
+			certInfo: &credscontroller.CertInfo{Cert: testcerts.ServerCert, Key: testcerts.ServerKey, CRL: make([]byte, 4096)},
+			want:     secretTooLarge,
+		},
+		{
+			name:     "binary certificate",
+			certInfo: &credscontroller.CertInfo{Cert: []byte{0x30, 0x82, 0x01, 0x0a}, Key: testcerts.ServerKey},
+			want:     secretNotPEM,
+		},
+		{
+			name:     "binary crl",
+			certInfo: &credscontroller.CertInfo{Cert: testcerts.CACert, CRL: []byte{0x30, 0x82}},
+			caOnly:   true,
+			want:     secretNotPEM,
+		},
+		{
+			name:     "mismatched key",
+			certInfo: &credscontroller.CertInfo{Cert: testcerts.ServerCert, Key: testcerts.RotatedKey},
+			want:     secretKeyMismatch,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkSecret(tt.certInfo, tt.caOnly)
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q: %v", tt.want, got, err)
+			}
+			if (err == nil) != (tt.want == "") {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
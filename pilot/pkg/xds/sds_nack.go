@@ -0,0 +1,211 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+)
+
+// SecretRejection describes the last SDS response rejected by a proxy.
+type SecretRejection struct {
+	ProxyID      string `json:"proxy"`
+	ConnectionID string `json:"connectionId"`
+	// Resources are the secrets the rejection is attributed to. These are the secrets named in the error
+	// message if any, otherwise all the secrets of the rejected response.
+	Resources []string  `json:"resources"`
+	Code      string    `json:"code"`
+	Message   string    `json:"message"`
+	Nonce     string    `json:"nonce"`
+	Time      time.Time `json:"time"`
+	// Fallback lists the secrets sent back to the proxy at their previous ACKed version.
+	Fallback []string `json:"fallback,omitempty"`
+}
+
+// sentSecrets is the last SDS response sent to a proxy over SotW.
+type sentSecrets struct {
+	nonce     string
+	resources model.Resources
+	// fallback is set if the response holds the previous ACKed version of rejected secrets.
+	fallback bool
+}
+
+// recordSecretsSent remembers the secrets of the SDS response sent with the nonce, to tell which secrets
+// a rejection is about, and which ones are good once ACKed.
+func (con *Connection) recordSecretsSent(sentNonce string, res model.Resources, fallback bool) {
+	con.lastSecrets = &sentSecrets{nonce: sentNonce, resources: res, fallback: fallback}
+	con.secretDeliveries.sent(sentNonce, res, time.Now())
+}
+
+// onSecretAck records the secrets of the ACKed SDS response as good, and clears the rejection reported
+// by the proxy unless the ACK is the one of a fallback response.
+func (s *DiscoveryServer) onSecretAck(con *Connection, ackedNonce string) {
+	con.secretDeliveries.acked(ackedNonce, time.Now())
+	sent := con.lastSecrets
+	if sent == nil || sent.nonce != ackedNonce {
+		return
+	}
+	con.lastSecrets = nil
+	if con.goodSecrets == nil {
+		con.goodSecrets = map[string]*discovery.Resource{}
+	}
+	for _, r := range sent.resources {
+		con.goodSecrets[r.Name] = r
+	}
+	if !sent.fallback {
+		s.secretRejectionsMutex.Lock()
+		delete(s.secretRejections, con.conID)
+		s.secretRejectionsMutex.Unlock()
+	}
+}
+
+// onSecretNack records the secrets rejected by the proxy and, if enabled, sends back their previous ACKed
+// version so a gateway is not left without certificates.
+func (s *DiscoveryServer) onSecretNack(con *Connection, rejectedNonce string, detail *status.Status) {
+	var names []string
+	if sent := con.lastSecrets; sent != nil && sent.nonce == rejectedNonce {
+		for _, r := range sent.resources {
+			names = append(names, r.Name)
+		}
+	} else if w := con.Watched(v3.SecretType); w != nil {
+		names = w.ResourceNames
+	}
+	rejection := &SecretRejection{
+		ProxyID:      con.proxy.ID,
+		ConnectionID: con.conID,
+		Resources:    rejectedSecrets(names, detail.GetMessage()),
+		Code:         codes.Code(detail.GetCode()).String(),
+		Message:      detail.GetMessage(),
+		Nonce:        rejectedNonce,
+		Time:         time.Now(),
+	}
+	con.secretDeliveries.rejected(rejectedNonce, rejection.Resources, rejection.Message)
+	rejection.Fallback = s.sendSecretFallback(con, rejectedNonce, rejection.Resources)
+	s.secretRejectionsMutex.Lock()
+	s.secretRejections[con.conID] = rejection
+	s.secretRejectionsMutex.Unlock()
+}
+
+// rejectedSecrets returns the secrets named in the error message, or all of them if none is.
+func rejectedSecrets(names []string, message string) []string {
+	var named []string
+	for _, name := range names {
+		if strings.Contains(message, name) {
+			named = append(named, name)
+		}
+	}
+	if len(named) == 0 {
+		named = append(named, names...)
+	}
+	sort.Strings(named)
+	return named
+}
+
+// sendSecretFallback sends the previous ACKed version of the rejected secrets, and returns their names.
+// Nothing is sent for a rejected fallback, or for secrets which were never ACKed.
+func (s *DiscoveryServer) sendSecretFallback(con *Connection, rejectedNonce string, rejected []string) []string {
+	sent := con.lastSecrets
+	con.lastSecrets = nil
+	if !alifeatures.EnableSDSNackFallback || sent == nil || sent.nonce != rejectedNonce || sent.fallback {
+		return nil
+	}
+	res := make(model.Resources, 0, len(rejected))
+	var names []string
+	for _, name := range rejected {
+		good, f := con.goodSecrets[name]
+		if !f || rejectedVersion(sent.resources, good) {
+			continue
+		}
+		res = append(res, good)
+		names = append(names, name)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	push := con.proxy.LastPushContext
+	resp := &discovery.DiscoveryResponse{
+		ControlPlane: ControlPlane(),
+		TypeUrl:      v3.SecretType,
+		VersionInfo:  push.PushVersion,
+		Nonce:        nonce(push.LedgerVersion),
+		Resources:    model.ResourcesToAny(res),
+	}
+	if err := con.send(resp); err != nil {
+		log.Warnf("SDS: failed to send previous version of rejected secrets %v to %s: %v", names, con.conID, err)
+		return nil
+	}
+	con.recordSecretsSent(resp.Nonce, res, true)
+	sdsNackFallbacks.Increment()
+	log.Infof("SDS: sent previous version of rejected secrets %v to %s", names, con.conID)
+	return names
+}
+
+// rejectedVersion returns true if the good version of a secret is the one which was rejected.
+func rejectedVersion(rejected model.Resources, good *discovery.Resource) bool {
+	for _, r := range rejected {
+		if r.Name == good.Name {
+			return proto.Equal(r.Resource, good.Resource)
+		}
+	}
+	return false
+}
+
+// clearSecretRejection forgets the rejection reported on a closed connection.
+func (s *DiscoveryServer) clearSecretRejection(conID string) {
+	s.secretRejectionsMutex.Lock()
+	delete(s.secretRejections, conID)
+	s.secretRejectionsMutex.Unlock()
+}
+
+// SecretRejections returns the SDS rejections currently reported by the connected proxies.
+func (s *DiscoveryServer) SecretRejections() []SecretRejection {
+	s.secretRejectionsMutex.RLock()
+	defer s.secretRejectionsMutex.RUnlock()
+	out := make([]SecretRejection, 0, len(s.secretRejections))
+	for _, r := range s.secretRejections {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ConnectionID < out[j].ConnectionID
+	})
+	return out
+}
+
+// sdsRejectsz lists the SDS responses rejected by proxies, optionally filtered by proxyID.
+// It is mapped to /debug/sds_rejects
+func (s *DiscoveryServer) sdsRejectsz(w http.ResponseWriter, req *http.Request) {
+	rejections := s.SecretRejections()
+	if proxyID := req.URL.Query().Get("proxyID"); proxyID != "" {
+		filtered := make([]SecretRejection, 0)
+		for _, r := range rejections {
+			if r.ProxyID == proxyID {
+				filtered = append(filtered, r)
+			}
+		}
+		rejections = filtered
+	}
+	writeJSON(w, rejections, req)
+}
@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestSecretRejections(t *testing.T) {
+	s := &DiscoveryServer{secretRejections: map[string]*SecretRejection{}}
+	con := &Connection{
+		conID: "gateway-1",
+		proxy: &model.Proxy{
+			ID: "gateway.istio-system",
+			WatchedResources: map[string]*model.WatchedResource{
+				v3.SecretType: {TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://a", "kubernetes://b"}},
+			},
+		},
+	}
+	secret := func(name, value string) *discovery.Resource {
+		return &discovery.Resource{Name: name, Resource: &anypb.Any{Value: []byte(value)}}
+	}
+
+	con.recordSecretsSent("n1", model.Resources{secret("kubernetes://a", "a1"), secret("kubernetes://b", "b1")}, false)
+	s.onSecretAck(con, "n1")
+	if len(con.goodSecrets) != 2 {
+		t.Fatalf("expected ACKed secrets to be recorded, got %v", con.goodSecrets)
+	}
+
+	con.recordSecretsSent("n2", model.Resources{secret("kubernetes://a", "a2")}, false)
+	s.onSecretNack(con, "n2", &status.Status{Code: 3, Message: "Failed to load certificate for kubernetes://a"})
+	got := s.SecretRejections()
+	if len(got) != 1 || got[0].ProxyID != con.proxy.ID || got[0].Code != "InvalidArgument" {
+		t.Fatalf("unexpected rejections %+v", got)
+	}
+	if !reflect.DeepEqual(got[0].Resources, []string{"kubernetes://a"}) {
+		t.Fatalf("expected the rejection to be attributed to the named secret, got %v", got[0].Resources)
+	}
+	if len(got[0].Fallback) != 0 {
+		t.Fatalf("fallback is disabled by default, got %v", got[0].Fallback)
+	}
+	if string(con.goodSecrets["kubernetes://a"].Resource.Value) != "a1" {
+		t.Fatalf("rejected secret must not be recorded as good")
+	}
+
+	// A rejection not naming any secret is attributed to all the watched ones.
+	s.onSecretNack(con, "unknown", &status.Status{Code: 3, Message: "invalid"})
+	if got := s.SecretRejections(); !reflect.DeepEqual(got[0].Resources, []string{"kubernetes://a", "kubernetes://b"}) {
+		t.Fatalf("unexpected rejected secrets %v", got[0].Resources)
+	}
+
+	con.recordSecretsSent("n3", model.Resources{secret("kubernetes://a", "a3")}, false)
+	s.onSecretAck(con, "n3")
+	if got := s.SecretRejections(); len(got) != 0 {
+		t.Fatalf("expected the rejection to be cleared by an ACK, got %+v", got)
+	}
+}
+
+func TestRejectedVersion(t *testing.T) {
+	good := &discovery.Resource{Name: "a", Resource: &anypb.Any{Value: []byte("1")}}
+	if !rejectedVersion(model.Resources{{Name: "a", Resource: &anypb.Any{Value: []byte("1")}}}, good) {
+		t.Fatalf("expected the same version to be rejected")
+	}
+	if rejectedVersion(model.Resources{{Name: "a", Resource: &anypb.Any{Value: []byte("2")}}}, good) {
+		t.Fatalf("expected another version not to be rejected")
+	}
+}
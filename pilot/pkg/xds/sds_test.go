@@ -15,6 +15,7 @@
 package xds
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -27,6 +28,7 @@ import (
 	qat "github.com/envoyproxy/go-control-plane/contrib/envoy/extensions/private_key_providers/qat/v3alpha"
 	"github.com/golang/protobuf/ptypes/duration"
 	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,14 +37,19 @@ import (
 	k8stesting "k8s.io/client-go/testing"
 
 	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
 	credentials "istio.io/istio/pilot/pkg/credentials/kube"
 	"istio.io/istio/pilot/pkg/model"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pilot/test/xdstest"
+	alifeatures "istio.io/istio/pkg/ali/features"
 	"istio.io/istio/pkg/config/schema/kind"
 	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/multicluster"
 	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
 	"istio.io/istio/pkg/util/sets"
 )
 
@@ -350,6 +357,78 @@ func TestGenerate(t *testing.T) {
 	}
 }
 
+func TestGenerateClusterSecret(t *testing.T) {
+	remoteCert := genericCert.DeepCopy()
+	remoteCert.Name = "remote"
+	otherCert := genericCert.DeepCopy()
+	otherCert.Name = "other"
+	otherCert.Namespace = "other"
+	cases := []struct {
+		name      string
+		resource  string
+		authorize bool
+		expect    []string
+	}{
+		{
+			name:      "remote cluster",
+			resource:  "kubernetes://remote/istio-system/remote",
+			authorize: true,
+			expect:    []string{"kubernetes://remote/istio-system/remote"},
+		},
+		{
+			name:     "unauthorized in remote cluster",
+			resource: "kubernetes://remote/istio-system/remote",
+		},
+		{
+			name:      "other namespace",
+			resource:  "kubernetes://remote/other/other",
+			authorize: true,
+		},
+		{
+			name:      "unknown cluster",
+			resource:  "kubernetes://unknown/istio-system/remote",
+			authorize: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			localClient := kube.NewFakeClient()
+			disableAuthorizationForSecret(localClient.Kube().(*fake.Clientset))
+			remoteClient := kube.NewFakeClient(remoteCert, otherCert)
+			if tt.authorize {
+				disableAuthorizationForSecret(remoteClient.Kube().(*fake.Clientset))
+			} else {
+				remoteClient.Kube().(*fake.Clientset).Fake.PrependReactor("create", "subjectaccessreviews",
+					func(action k8stesting.Action) (bool, runtime.Object, error) {
+						return true, nil, errors.New("not authorized")
+					})
+			}
+			sc := credentials.NewMulticluster("Kubernetes")
+			sc.ClusterAdded(&multicluster.Cluster{ID: "Kubernetes", Client: localClient}, nil)
+			sc.ClusterAdded(&multicluster.Cluster{ID: "remote", Client: remoteClient}, nil)
+			stop := test.NewStop(t)
+			localClient.RunAndWait(stop)
+			remoteClient.RunAndWait(stop)
+
+			gen := NewSecretGen(sc, model.DisabledCache{}, "Kubernetes", nil)
+			proxy := &model.Proxy{
+				Metadata:         &model.NodeMetadata{ClusterID: "Kubernetes"},
+				VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"},
+				Type:             model.Router,
+			}
+			secrets, _, _ := gen.Generate(proxy, &model.WatchedResource{ResourceNames: []string{tt.resource}},
+				&model.PushRequest{Full: true, Start: time.Now()})
+			var got []string
+			for name := range xdstest.ExtractTLSSecrets(t, model.ResourcesToAny(secrets)) {
+				got = append(got, name)
+			}
+			if diff := cmp.Diff(got, tt.expect); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
 // TestCaching ensures we don't have cross-proxy cache generation issues. This is split from TestGenerate
 // since it is order dependent.
 // Regression test for https://github.com/istio/istio/issues/33368
@@ -1290,3 +1369,215 @@ func TestCachingWithKeyProvider(t *testing.T) {
 		}
 	}
 }
+
+func TestSDSResourceNames(t *testing.T) {
+	httpsPort := &networking.Port{Number: 443, Name: "https", Protocol: "HTTPS"}
+	gateway := &model.MergedGateway{
+		MergedServers: map[model.ServerPort]*model.MergedServers{
+			{Number: 443, Protocol: "HTTPS"}: {Servers: []*networking.Server{
+				{Port: httpsPort, Tls: &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "a"}},
+				{Port: httpsPort, Tls: &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_MUTUAL, CredentialName: "b"}},
+				{Port: httpsPort},
+			}},
+		},
+	}
+	cases := []struct {
+		name     string
+		wildcard bool
+		fallback string
+		w        *model.WatchedResource
+		want     []string
+	}{
+		{
+			name: "explicit subscription",
+			w:    &model.WatchedResource{TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://c"}},
+			want: []string{"kubernetes://c"},
+		},
+		{
+			name:     "explicit subscription opted in",
+			wildcard: true,
+			w:        &model.WatchedResource{TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://c"}},
+			want:     []string{"kubernetes://c"},
+		},
+		{
+			// Without opting in, a wildcard delta client keeps the upstream on-demand behavior.
+			name: "wildcard subscription not opted in",
+			w:    &model.WatchedResource{TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://c"}, Wildcard: true},
+			want: []string{"kubernetes://c"},
+		},
+		{
+			name:     "wildcard subscription opted in",
+			wildcard: true,
+			w:        &model.WatchedResource{TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://c"}, Wildcard: true},
+			want:     []string{"kubernetes://a", "kubernetes://b", "kubernetes://b-cacert", "kubernetes://c"},
+		},
+		{
+			name:     "explicit subscription with fallback certificate",
+			fallback: "fallback",
+			w:        &model.WatchedResource{TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://a"}},
+			want:     []string{"kubernetes://a", "kubernetes://fallback"},
+		},
+		{
+			name:     "wildcard subscription with fallback certificate",
+			wildcard: true,
+			fallback: "fallback",
+			w:        &model.WatchedResource{TypeUrl: v3.SecretType, Wildcard: true},
+			want:     []string{"kubernetes://a", "kubernetes://b", "kubernetes://b-cacert", "kubernetes://fallback"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			test.SetForTest(t, &alifeatures.GatewayFallbackCredentialName, tt.fallback)
+			proxy := &model.Proxy{
+				Metadata:      &model.NodeMetadata{WildcardSDS: model.StringBool(tt.wildcard)},
+				MergedGateway: gateway,
+			}
+			if diff := cmp.Diff(sdsResourceNames(proxy, &model.PushContext{}, tt.w), tt.want); diff != "" {
+				t.Fatal(diff)
+			}
+			gatewayUpdate := sets.New(model.ConfigKey{Kind: kind.Gateway, Name: "gw", Namespace: "istio-system"})
+			want := (tt.w.Wildcard && tt.wildcard) || tt.fallback != ""
+			if got := sdsGatewayNeedsPush(proxy, &model.PushContext{}, tt.w, gatewayUpdate); got != want {
+				t.Fatalf("sdsGatewayNeedsPush: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFallbackCertificateForNonWildcardGateway(t *testing.T) {
+	fallbackCert := makeSecret("fallback", map[string]string{
+		credentials.GenericScrtCert: readFile(filepath.Join(certDir, "dns/cert-chain.pem")),
+		credentials.GenericScrtKey:  readFile(filepath.Join(certDir, "dns/key.pem")),
+	})
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		KubernetesObjects: []runtime.Object{genericCert, fallbackCert},
+		KubeClientModifier: func(c kube.Client) {
+			cc := c.Kube().(*fake.Clientset)
+			disableAuthorizationForSecret(cc)
+		},
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: istio-system
+  annotations:
+    higress.io/fallback-credential-name: fallback
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    hosts:
+    - example.com
+    tls:
+      mode: SIMPLE
+      credentialName: generic
+`,
+	})
+	proxy := s.SetupProxy(&model.Proxy{
+		Metadata:         &model.NodeMetadata{ClusterID: "Kubernetes"},
+		VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"},
+		Type:             model.Router,
+		ConfigNamespace:  "istio-system",
+		Labels:           map[string]string{"istio": "ingressgateway"},
+	})
+	gen := s.Discovery.Generators[v3.SecretType]
+	// A SotW subscription is never wildcard, and the gateway does not opt in to the wildcard expansion.
+	secrets, _, _ := gen.Generate(proxy, &model.WatchedResource{ResourceNames: []string{"kubernetes://generic"}},
+		&model.PushRequest{Full: true, Start: time.Now(), Push: s.PushContext()})
+	raw := xdstest.ExtractTLSSecrets(t, model.ResourcesToAny(secrets))
+	if len(raw) != 2 || raw["kubernetes://generic"] == nil || raw["kubernetes://fallback"] == nil {
+		t.Fatalf("expected the subscribed and the fallback certificates, got %v", raw)
+	}
+}
+
+func TestSecretRotationKeepsListeners(t *testing.T) {
+	s := NewFakeDiscoveryServer(t, FakeOptions{
+		KubernetesObjects: []runtime.Object{genericCert},
+		KubeClientModifier: func(c kube.Client) {
+			cc := c.Kube().(*fake.Clientset)
+			disableAuthorizationForSecret(cc)
+		},
+		ConfigString: `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gateway
+  namespace: istio-system
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 443
+      name: https
+      protocol: HTTPS
+    hosts:
+    - example.com
+    tls:
+      mode: SIMPLE
+      credentialName: generic
+`,
+	})
+	proxy := s.SetupProxy(&model.Proxy{
+		Metadata:         &model.NodeMetadata{ClusterID: "Kubernetes"},
+		VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"},
+		Type:             model.Router,
+		ConfigNamespace:  "istio-system",
+		Labels:           map[string]string{"istio": "ingressgateway"},
+	})
+	listeners := func() []byte {
+		t.Helper()
+		var out []byte
+		ls, _ := s.ConfigGen.BuildListeners(proxy, &model.PushRequest{Full: true, Start: time.Now(), Push: s.PushContext()})
+		for _, l := range ls {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, b...)
+		}
+		return out
+	}
+	before := listeners()
+	if len(before) == 0 {
+		t.Fatal("expected listeners for the gateway")
+	}
+
+	rotated := makeSecret("generic", map[string]string{
+		credentials.GenericScrtCert: readFile(filepath.Join(certDir, "dns/cert-chain.pem")),
+		credentials.GenericScrtKey:  readFile(filepath.Join(certDir, "dns/key.pem")),
+	})
+	if _, err := s.KubeClient().Kube().CoreV1().Secrets("istio-system").Update(context.Background(), rotated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// The rotation is pushed over SDS only, and the listeners it would otherwise push are unchanged.
+	secretPush := &model.PushRequest{
+		Full:           false,
+		ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.Secret, Name: "generic", Namespace: "istio-system"}),
+	}
+	gen := s.Discovery.Generators[v3.SecretType]
+	retry.UntilSuccessOrFail(t, func() error {
+		// The fake server registers no secret handler, so clear the SDS cache as the push of the rotation does.
+		s.Discovery.Cache.Clear(secretPush.ConfigsUpdated)
+		secrets, _, _ := gen.Generate(proxy, &model.WatchedResource{ResourceNames: []string{"kubernetes://generic"}},
+			&model.PushRequest{Full: true, Start: time.Now(), Push: s.PushContext()})
+		raw := xdstest.ExtractTLSSecrets(t, model.ResourcesToAny(secrets))
+		got := string(raw["kubernetes://generic"].GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+		if got != string(rotated.Data[credentials.GenericScrtCert]) {
+			return errors.New("rotated certificate not served yet")
+		}
+		return nil
+	})
+
+	if ldsNeedsPush(proxy, secretPush) {
+		t.Fatal("expected no LDS push for a secret rotation")
+	}
+	if after := listeners(); string(after) != string(before) {
+		t.Fatal("expected the listeners to be unchanged by a secret rotation")
+	}
+}
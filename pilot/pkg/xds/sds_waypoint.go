@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strings"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	istiolog "istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/monitoring"
+)
+
+// sdsAuditLog records the secrets granted to proxies with an identity other than theirs.
+var sdsAuditLog = istiolog.RegisterScope("sdsaudit", "audit of the secrets granted to proxies with another identity")
+
+var pilotSDSFrontedGrants = monitoring.NewSum(
+	"pilot_sds_fronted_grants_total",
+	"Total number of secrets granted to waypoint proxies with the identity of the service account they front.",
+)
+
+// waypointFrontedServiceAccount returns the service account fronted by a waypoint proxy, with the identity of which
+// it may be granted secrets, or an empty string if it is not a waypoint of a service account. The scope of
+// a waypoint is declared by the proxy, so it is only trusted if the ambient index knows the proxy as a waypoint of
+// this scope.
+func waypointFrontedServiceAccount(proxy *model.Proxy, push *model.PushContext) string {
+	if !features.EnableAmbientControllers || !proxy.IsWaypointProxy() || push == nil || proxy.VerifiedIdentity == nil {
+		return ""
+	}
+	scope := proxy.WaypointScope()
+	if scope.ServiceAccount == "" || scope.ServiceAccount == proxy.VerifiedIdentity.ServiceAccount ||
+		scope.Namespace != proxy.VerifiedIdentity.Namespace {
+		return ""
+	}
+	for _, addr := range push.WaypointsFor(scope) {
+		for _, ip := range proxy.IPAddresses {
+			if addr.String() == ip {
+				return scope.ServiceAccount
+			}
+		}
+	}
+	return ""
+}
+
+// authorizeFronted authorizes a waypoint proxy to access a secret with the identity of the service account it
+// fronts, in the cluster of the secret.
+func authorizeFronted(r SecretResource, proxy *model.Proxy, fronted string, secrets credscontroller.Controller,
+	clusters credscontroller.MulticlusterController, authorizer credscontroller.SecretAuthorizer,
+) error {
+	namespace := proxy.VerifiedIdentity.Namespace
+	if authorizer != nil {
+		return authorizer.AuthorizeSecret(fronted, namespace, r.Name, r.Namespace)
+	}
+	if r.Cluster != proxy.Metadata.ClusterID && clusters != nil {
+		controller, err := clusters.ForCluster(r.Cluster)
+		if err != nil {
+			return err
+		}
+		return controller.Authorize(fronted, namespace)
+	}
+	return secrets.Authorize(fronted, namespace)
+}
+
+// auditFrontedGrants records the secrets granted to a waypoint proxy with the identity of the service account it
+// fronts.
+func auditFrontedGrants(proxy *model.Proxy, fronted string, resources []string) {
+	if len(resources) == 0 {
+		return
+	}
+	pilotSDSFrontedGrants.RecordInt(int64(len(resources)))
+	sdsAuditLog.Infof("waypoint %s with identity %s/%s granted the secrets %s with the identity %s/%s it fronts",
+		proxy.ID, proxy.VerifiedIdentity.Namespace, proxy.VerifiedIdentity.ServiceAccount, strings.Join(resources, ", "),
+		proxy.VerifiedIdentity.Namespace, fronted)
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"testing"
+
+	credscontroller "istio.io/istio/pilot/pkg/credentials"
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test"
+)
+
+// saAuthorizer authorizes the service accounts of a namespace to access its secrets.
+type saAuthorizer struct {
+	credscontroller.Controller
+	namespace       string
+	serviceAccounts []string
+}
+
+func (a saAuthorizer) Authorize(serviceAccount, namespace string) error {
+	for _, sa := range a.serviceAccounts {
+		if namespace == a.namespace && sa == serviceAccount {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s/%s is not authorized", namespace, serviceAccount)
+}
+
+func TestFilterAuthorizedResourcesFronted(t *testing.T) {
+	proxy := &model.Proxy{
+		ID:               "waypoint.default",
+		Type:             model.Waypoint,
+		Metadata:         &model.NodeMetadata{},
+		VerifiedIdentity: &spiffe.Identity{Namespace: "default", ServiceAccount: "productpage-waypoint"},
+	}
+	resource := func(name string) SecretResource {
+		r, err := credentials.ParseResourceName(name, "default", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return SecretResource{SecretResource: r}
+	}
+	resources := []SecretResource{resource("kubernetes://tls"), resource("kubernetes://other/tls")}
+	secrets := saAuthorizer{namespace: "default", serviceAccounts: []string{"productpage"}}
+
+	if got := filterAuthorizedResources(resources, proxy, secrets, nil, nil, ""); len(got) != 0 {
+		t.Fatalf("expected the waypoint identity not to be authorized, got %v", got)
+	}
+	got := filterAuthorizedResources(resources, proxy, secrets, nil, nil, "productpage")
+	if len(got) != 1 || got[0].ResourceName != "kubernetes://tls" {
+		t.Fatalf("expected only the secret of the namespace to be granted with the fronted identity, got %v", got)
+	}
+	if got := filterAuthorizedResources(resources, proxy, secrets, nil, nil, "reviews"); len(got) != 0 {
+		t.Fatalf("expected an unauthorized fronted identity not to be granted, got %v", got)
+	}
+}
+
+func TestWaypointFrontedServiceAccount(t *testing.T) {
+	waypoint := &model.Proxy{
+		Type:             model.Waypoint,
+		ConfigNamespace:  "default",
+		IPAddresses:      []string{"10.0.0.1"},
+		Metadata:         &model.NodeMetadata{Annotations: map[string]string{constants.WaypointServiceAccount: "productpage"}},
+		VerifiedIdentity: &spiffe.Identity{Namespace: "default", ServiceAccount: "productpage-waypoint"},
+	}
+	if got := waypointFrontedServiceAccount(waypoint, nil); got != "" {
+		t.Fatalf("expected no fronted service account with ambient disabled, got %q", got)
+	}
+	test.SetForTest(t, &features.EnableAmbientControllers, true)
+	push := NewFakeDiscoveryServer(t, FakeOptions{}).PushContext()
+	// The ambient index does not know the proxy as a waypoint of the scope it declares.
+	if got := waypointFrontedServiceAccount(waypoint, push); got != "" {
+		t.Fatalf("expected the declared scope not to be trusted, got %q", got)
+	}
+	sidecar := &model.Proxy{Type: model.SidecarProxy, Metadata: waypoint.Metadata, VerifiedIdentity: waypoint.VerifiedIdentity}
+	if got := waypointFrontedServiceAccount(sidecar, push); got != "" {
+		t.Fatalf("expected no fronted service account for a sidecar, got %q", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// The results of the delivery of a secret to a proxy.
+const (
+	// SecretNotSent is the result of the secrets subscribed to which were never sent, such as missing secrets.
+	SecretNotSent = "NOT_SENT"
+	SecretPending = "PENDING"
+	SecretAcked   = "ACK"
+	SecretNacked  = "NACK"
+)
+
+// SecretDistribution lists the connected proxies subscribed to a secret, and the delivery of the secret to each.
+type SecretDistribution struct {
+	ResourceName string             `json:"resourceName"`
+	Subscribers  []SecretSubscriber `json:"subscribers"`
+}
+
+// SecretSubscriber is the delivery of a secret to a proxy.
+type SecretSubscriber struct {
+	ProxyID      string `json:"proxy"`
+	ConnectionID string `json:"connectionId"`
+	// SentVersion and AckedVersion are hashes of the content of the secret last sent to and ACKed by the proxy.
+	SentVersion  string     `json:"sentVersion,omitempty"`
+	SentAt       *time.Time `json:"sentAt,omitempty"`
+	AckedVersion string     `json:"ackedVersion,omitempty"`
+	AckedAt      *time.Time `json:"ackedAt,omitempty"`
+	// Result is the result of the last delivery: one of NOT_SENT, PENDING, ACK or NACK.
+	Result string `json:"result"`
+	// Error is the error of the proxy rejecting the secret, if the last delivery is rejected.
+	Error string `json:"error,omitempty"`
+}
+
+// secretDelivery is the delivery of a secret to the proxy of a connection.
+type secretDelivery struct {
+	nonce        string
+	sentVersion  string
+	sentAt       time.Time
+	ackedVersion string
+	ackedAt      time.Time
+	result       string
+	err          string
+}
+
+// secretDeliveries tracks by name the secrets sent to the proxy of a connection. Unlike the other state of the
+// stream, it is guarded by a mutex as it is read by the debug endpoints. A nil secretDeliveries tracks nothing.
+type secretDeliveries struct {
+	mu      sync.RWMutex
+	secrets map[string]*secretDelivery
+}
+
+// sent records the secrets of the response sent with the nonce as pending an ACK.
+func (d *secretDeliveries) sent(sentNonce string, res model.Resources, now time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.secrets == nil {
+		d.secrets = map[string]*secretDelivery{}
+	}
+	for _, r := range res {
+		sd := d.secrets[r.Name]
+		if sd == nil {
+			sd = &secretDelivery{}
+			d.secrets[r.Name] = sd
+		}
+		sd.nonce = sentNonce
+		sd.sentVersion = resourceVersion(r)
+		sd.sentAt = now
+		sd.result = SecretPending
+		sd.err = ""
+	}
+}
+
+// acked records the secrets of the response ACKed with the nonce as delivered.
+func (d *secretDeliveries) acked(ackedNonce string, now time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, sd := range d.secrets {
+		if sd.nonce == ackedNonce && sd.result == SecretPending {
+			sd.ackedVersion = sd.sentVersion
+			sd.ackedAt = now
+			sd.result = SecretAcked
+		}
+	}
+}
+
+// rejected records the rejection of the named secrets of the response sent with the nonce.
+func (d *secretDeliveries) rejected(rejectedNonce string, names []string, message string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, name := range names {
+		if sd := d.secrets[name]; sd != nil && sd.nonce == rejectedNonce {
+			sd.result = SecretNacked
+			sd.err = message
+		}
+	}
+}
+
+// subscriber returns the delivery of the named secret to the proxy.
+func (d *secretDeliveries) subscriber(name string) SecretSubscriber {
+	if d == nil {
+		return SecretSubscriber{Result: SecretNotSent}
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	sd := d.secrets[name]
+	if sd == nil {
+		return SecretSubscriber{Result: SecretNotSent}
+	}
+	return SecretSubscriber{
+		SentVersion:  sd.sentVersion,
+		SentAt:       timeOrNil(sd.sentAt),
+		AckedVersion: sd.ackedVersion,
+		AckedAt:      timeOrNil(sd.ackedAt),
+		Result:       sd.result,
+		Error:        sd.err,
+	}
+}
+
+// SecretDistributions returns, for each secret served by this Pilot, the delivery of the secret to the connected
+// proxies subscribed to it. The secrets of the agents, such as the workload certificates, are left out.
+func (s *DiscoveryServer) SecretDistributions() []SecretDistribution {
+	bySecret := map[string][]SecretSubscriber{}
+	for _, con := range s.SortedClients() {
+		w := con.Watched(v3.SecretType)
+		if w == nil {
+			continue
+		}
+		con.proxy.RLock()
+		names := append([]string(nil), w.ResourceNames...)
+		con.proxy.RUnlock()
+		for _, name := range names {
+			// Only the credentials, such as kubernetes:// ones, are served by Pilot.
+			if !strings.Contains(name, "://") {
+				continue
+			}
+			sub := con.secretDeliveries.subscriber(name)
+			sub.ProxyID = con.proxy.ID
+			sub.ConnectionID = con.conID
+			bySecret[name] = append(bySecret[name], sub)
+		}
+	}
+	out := make([]SecretDistribution, 0, len(bySecret))
+	for name, subs := range bySecret {
+		out = append(out, SecretDistribution{ResourceName: name, Subscribers: subs})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ResourceName < out[j].ResourceName
+	})
+	return out
+}
+
+// secretsz lists the secrets served by this Pilot, with the connected proxies subscribed to them and the result of
+// their last delivery. It is filtered by resource name with resource, and by proxy with proxyID.
+// It is mapped to /debug/secretsz
+func (s *DiscoveryServer) secretsz(w http.ResponseWriter, req *http.Request) {
+	resource := req.URL.Query().Get("resource")
+	proxyID := req.URL.Query().Get("proxyID")
+	out := make([]SecretDistribution, 0)
+	for _, d := range s.SecretDistributions() {
+		if resource != "" && d.ResourceName != resource {
+			continue
+		}
+		if proxyID != "" {
+			subs := d.Subscribers[:0]
+			for _, sub := range d.Subscribers {
+				if sub.ProxyID == proxyID {
+					subs = append(subs, sub)
+				}
+			}
+			if len(subs) == 0 {
+				continue
+			}
+			d.Subscribers = subs
+		}
+		out = append(out, d)
+	}
+	writeJSON(w, out, req)
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestSecretDistributions(t *testing.T) {
+	s := &DiscoveryServer{adsClients: map[string]*Connection{}, secretRejections: map[string]*SecretRejection{}}
+	newCon := func(conID, proxyID string, secrets ...string) *Connection {
+		con := &Connection{
+			conID:            conID,
+			initialized:      make(chan struct{}),
+			secretDeliveries: &secretDeliveries{},
+			proxy: &model.Proxy{
+				ID:       proxyID,
+				Metadata: &model.NodeMetadata{},
+				WatchedResources: map[string]*model.WatchedResource{
+					v3.SecretType: {TypeUrl: v3.SecretType, ResourceNames: secrets},
+				},
+			},
+		}
+		close(con.initialized)
+		s.adsClients[conID] = con
+		return con
+	}
+	secret := func(name, value string) *discovery.Resource {
+		return &discovery.Resource{Name: name, Resource: &anypb.Any{Value: []byte(value)}}
+	}
+	a := newCon("gateway-a-1", "gateway-a.istio-system", "kubernetes://istio-system/cert", "default")
+	b := newCon("gateway-b-1", "gateway-b.istio-system", "kubernetes://istio-system/cert", "kubernetes://istio-system/missing")
+
+	a.recordSecretsSent("n1", model.Resources{secret("kubernetes://istio-system/cert", "v1")}, false)
+	s.onSecretAck(a, "n1")
+	b.recordSecretsSent("n1", model.Resources{secret("kubernetes://istio-system/cert", "v1")}, false)
+	s.onSecretAck(b, "n1")
+	// The new certificate is rejected by gateway b, and not ACKed yet by gateway a.
+	a.recordSecretsSent("n2", model.Resources{secret("kubernetes://istio-system/cert", "v2")}, false)
+	b.recordSecretsSent("n2", model.Resources{secret("kubernetes://istio-system/cert", "v2")}, false)
+	s.onSecretNack(b, "n2", &status.Status{Code: 3, Message: "Failed to load certificate for kubernetes://istio-system/cert"})
+
+	got := s.SecretDistributions()
+	if len(got) != 2 || got[0].ResourceName != "kubernetes://istio-system/cert" || got[1].ResourceName != "kubernetes://istio-system/missing" {
+		t.Fatalf("got distributions %+v, want the cert and the missing secret", got)
+	}
+	cert := got[0].Subscribers
+	if len(cert) != 2 || cert[0].ProxyID != "gateway-a.istio-system" || cert[1].ProxyID != "gateway-b.istio-system" {
+		t.Fatalf("got subscribers %+v of the cert", cert)
+	}
+	v1, v2 := resourceVersion(secret("", "v1")), resourceVersion(secret("", "v2"))
+	if cert[0].Result != SecretPending || cert[0].SentVersion != v2 || cert[0].AckedVersion != v1 {
+		t.Errorf("got delivery %+v to gateway a, want v2 pending and v1 ACKed", cert[0])
+	}
+	if cert[1].Result != SecretNacked || cert[1].SentVersion != v2 || cert[1].AckedVersion != v1 || cert[1].Error == "" {
+		t.Errorf("got delivery %+v to gateway b, want v2 rejected and v1 ACKed", cert[1])
+	}
+	if missing := got[1].Subscribers; len(missing) != 1 || missing[0].Result != SecretNotSent {
+		t.Errorf("got subscribers %+v of the missing secret, want it not sent", missing)
+	}
+
+	s.onSecretAck(a, "n2")
+	if sub := a.secretDeliveries.subscriber("kubernetes://istio-system/cert"); sub.Result != SecretAcked || sub.AckedVersion != v2 {
+		t.Errorf("got delivery %+v to gateway a, want v2 ACKed", sub)
+	}
+}
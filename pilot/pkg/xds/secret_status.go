@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/model/credentials"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/slices"
+)
+
+// ProxySecret is the status of a secret requested by a proxy, as served by this Pilot.
+type ProxySecret struct {
+	ResourceName string `json:"resourceName"`
+	// Type is the type of the credential, such as kubernetes or kubernetes-gateway.
+	Type string `json:"type,omitempty"`
+	// CredentialName and Namespace identify the Secret the credential is read from.
+	CredentialName string `json:"credentialName,omitempty"`
+	Namespace      string `json:"namespace,omitempty"`
+	Cluster        string `json:"cluster,omitempty"`
+	// CA is set for the CA certificates validating the peers.
+	CA bool `json:"ca"`
+	// PrivateKeyProvider is the name of the private key provider the key is offloaded to, such as cryptomb or qat.
+	PrivateKeyProvider string              `json:"privateKeyProvider,omitempty"`
+	Certificates       []SecretCertificate `json:"certificates,omitempty"`
+	// Error is set when the secret is not served to the proxy.
+	Error string `json:"error,omitempty"`
+	// Rejection is the error of the proxy rejecting the secret, if any.
+	Rejection string `json:"rejection,omitempty"`
+}
+
+// SecretCertificate describes a certificate of a served chain, leaf first.
+type SecretCertificate struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serialNumber"`
+	DNSNames     []string  `json:"dnsNames,omitempty"`
+	URIs         []string  `json:"uris,omitempty"`
+	NotBefore    time.Time `json:"notBefore"`
+	NotAfter     time.Time `json:"notAfter"`
+}
+
+// ProxySecrets returns the status of the secrets requested by the proxy of the connection. Secrets are generated
+// the same way they are for pushes, but only the metadata of the certificates is returned.
+func (s *DiscoveryServer) ProxySecrets(con *Connection) ([]ProxySecret, error) {
+	gen, ok := s.Generators[v3.SecretType].(*SecretGen)
+	if !ok {
+		return nil, fmt.Errorf("secrets are not served by this Pilot")
+	}
+	proxy := con.proxy
+	if proxy.VerifiedIdentity == nil {
+		return nil, fmt.Errorf("proxy %s is not authorized to receive secrets", proxy.ID)
+	}
+	proxy.RLock()
+	watched := proxy.WatchedResources[v3.SecretType]
+	if watched != nil {
+		watched = &model.WatchedResource{
+			TypeUrl:       watched.TypeUrl,
+			ResourceNames: append([]string(nil), watched.ResourceNames...),
+			Wildcard:      watched.Wildcard,
+		}
+	}
+	proxy.RUnlock()
+	if watched == nil {
+		return []ProxySecret{}, nil
+	}
+
+	push := s.globalPushContext()
+	req := &model.PushRequest{Full: true, Push: push, Start: time.Now(), Reason: model.NewReasonStats(model.DebugTrigger)}
+	resources, _, err := gen.Generate(proxy, watched, req)
+	if err != nil {
+		return nil, err
+	}
+	served := make(map[string]*tls.Secret, len(resources))
+	for _, r := range resources {
+		secret := &tls.Secret{}
+		if err := r.Resource.UnmarshalTo(secret); err != nil {
+			return nil, err
+		}
+		served[r.Name] = secret
+	}
+	rejections := map[string]string{}
+	for _, r := range s.SecretRejections() {
+		if r.ProxyID != proxy.ID {
+			continue
+		}
+		for _, name := range r.Resources {
+			rejections[name] = r.Message
+		}
+	}
+
+	names := sdsResourceNames(proxy, push, watched)
+	out := make([]ProxySecret, 0, len(names))
+	for _, name := range names {
+		status := ProxySecret{ResourceName: name, CA: strings.HasSuffix(name, credentials.SdsCaSuffix), Rejection: rejections[name]}
+		if sr, err := credentials.ParseResourceName(name, proxy.VerifiedIdentity.Namespace, proxy.Metadata.ClusterID,
+			gen.configCluster); err == nil {
+			status.Type = sr.ResourceType
+			status.CredentialName = strings.TrimSuffix(sr.Name, credentials.SdsCaSuffix)
+			status.Namespace = sr.Namespace
+			status.Cluster = sr.Cluster.String()
+		}
+		secret, ok := served[name]
+		if !ok {
+			status.Error = "not served: the secret is missing or invalid, or the proxy is not authorized to read it"
+			out = append(out, status)
+			continue
+		}
+		chain := secret.GetTlsCertificate().GetCertificateChain().GetInlineBytes()
+		if status.CA {
+			chain = secret.GetValidationContext().GetTrustedCa().GetInlineBytes()
+		}
+		status.PrivateKeyProvider = secret.GetTlsCertificate().GetPrivateKeyProvider().GetProviderName()
+		status.Certificates = parseCertificates(chain)
+		out = append(out, status)
+	}
+	return out, nil
+}
+
+// parseCertificates returns the metadata of the PEM encoded certificates, skipping those which do not parse.
+func parseCertificates(data []byte) []SecretCertificate {
+	var out []SecretCertificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return out
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		out = append(out, SecretCertificate{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: fmt.Sprintf("%x", cert.SerialNumber),
+			DNSNames:     cert.DNSNames,
+			URIs:         slices.Map(cert.URIs, func(u *url.URL) string { return u.String() }),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+		})
+	}
+}
+
+// proxySecretsz lists the status of the secrets requested by a proxy.
+// It is mapped to /debug/proxy_secrets
+func (s *DiscoveryServer) proxySecretsz(w http.ResponseWriter, req *http.Request) {
+	proxyID, con := s.getDebugConnection(req)
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+	secrets, err := s.ProxySecrets(con)
+	if err != nil {
+		handleHTTPError(w, err)
+		return
+	}
+	writeJSON(w, secrets, req)
+}
@@ -0,0 +1,81 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pilot/pkg/credentials/kube"
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/test/env"
+)
+
+func TestProxySecrets(t *testing.T) {
+	readCert := func(name string) []byte {
+		b, err := os.ReadFile(filepath.Join(env.IstioSrc, "tests/testdata/certs/default", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "generic", Namespace: "istio-system"},
+		Data: map[string][]byte{
+			kube.GenericScrtCert: readCert("cert-chain.pem"),
+			kube.GenericScrtKey:  readCert("key.pem"),
+		},
+	}
+	s := NewFakeDiscoveryServer(t, FakeOptions{KubernetesObjects: []runtime.Object{secret}})
+	disableAuthorizationForSecret(s.KubeClient().Kube().(*fake.Clientset))
+
+	proxy := s.SetupProxy(&model.Proxy{
+		Type:             model.Router,
+		VerifiedIdentity: &spiffe.Identity{Namespace: "istio-system"},
+		Metadata:         &model.NodeMetadata{ClusterID: "Kubernetes"},
+	})
+	proxy.WatchedResources = map[string]*model.WatchedResource{
+		v3.SecretType: {TypeUrl: v3.SecretType, ResourceNames: []string{"kubernetes://generic", "kubernetes://missing"}},
+	}
+	secrets, err := s.Discovery.ProxySecrets(&Connection{proxy: proxy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 {
+		t.Fatalf("expected the status of 2 secrets, got %+v", secrets)
+	}
+	generic, missing := secrets[0], secrets[1]
+	if generic.ResourceName != "kubernetes://generic" || generic.CredentialName != "generic" ||
+		generic.Namespace != "istio-system" || generic.Error != "" || generic.CA {
+		t.Fatalf("unexpected status of the served secret %+v", generic)
+	}
+	if len(generic.Certificates) == 0 || generic.Certificates[0].NotAfter.IsZero() || generic.Certificates[0].SerialNumber == "" {
+		t.Fatalf("expected the certificate chain of the served secret, got %+v", generic.Certificates)
+	}
+	if generic.PrivateKeyProvider != "" {
+		t.Fatalf("expected no private key provider, got %q", generic.PrivateKeyProvider)
+	}
+	if missing.Error == "" || len(missing.Certificates) != 0 {
+		t.Fatalf("expected the missing secret not to be served, got %+v", missing)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	securitymodel "istio.io/istio/pilot/pkg/security/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/monitoring"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// shadowRequestTimeout bounds the requests made to the stable revision.
+const shadowRequestTimeout = 30 * time.Second
+
+// Changes of the resources generated by this revision, compared to the ones of the stable revision.
+const (
+	ShadowAdded    = "added"
+	ShadowRemoved  = "removed"
+	ShadowModified = "modified"
+)
+
+var (
+	changeTag = monitoring.CreateLabel("change")
+
+	shadowProxies = monitoring.NewGauge(
+		"pilot_shadow_push_proxies",
+		"Number of proxies of the stable revision compared in the last shadow comparison round.",
+	)
+
+	shadowDivergentProxies = monitoring.NewGauge(
+		"pilot_shadow_push_divergent_proxies",
+		"Number of proxies for which the last shadow comparison round generated different resources than the "+
+			"stable revision.",
+	)
+
+	shadowDivergentResources = monitoring.NewSum(
+		"pilot_shadow_push_divergent_resources",
+		"Total number of resources generated differently than the stable revision, by type and change.",
+	)
+
+	shadowErrors = monitoring.NewSum(
+		"pilot_shadow_push_errors",
+		"Total number of proxies of the stable revision which could not be compared.",
+	)
+)
+
+// ShadowResult is the comparison of the resources generated for a proxy by the stable revision, with the ones
+// this revision generates for it. Added resources are only generated by this revision, removed ones only by
+// the stable revision.
+type ShadowResult struct {
+	Proxy string       `json:"proxy"`
+	Time  time.Time    `json:"time"`
+	Diffs []DryRunDiff `json:"diffs,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// ShadowStatus is the outcome of the last shadow comparison round, as served by /debug/shadowz.
+type ShadowStatus struct {
+	Source    string    `json:"source"`
+	Time      time.Time `json:"time,omitempty"`
+	Connected int       `json:"connected"`
+	Compared  int       `json:"compared"`
+	Divergent int       `json:"divergent"`
+	Failed    int       `json:"failed"`
+	// Results are the results of the proxies which diverged, or could not be compared.
+	Results []ShadowResult `json:"results,omitempty"`
+}
+
+// shadowComparator generates the xDS of the proxies connected to the stable revision of pilot, without sending
+// it, and compares it with the xDS the stable revision generates for them. The stable revision exports its
+// proxies by /debug/syncz and their resources by /debug/snapshot, so a canary revision is validated against
+// the real proxies of the mesh before any of them connects to it.
+type shadowComparator struct {
+	server     *DiscoveryServer
+	source     string
+	interval   time.Duration
+	maxProxies int
+	client     *http.Client
+
+	mu sync.RWMutex
+	// offset rotates the proxies compared by the rounds, when they are more than maxProxies.
+	offset int
+	status ShadowStatus
+}
+
+// newShadowComparator returns a comparator with the stable revision serving its debug endpoints at source,
+// or nil if source is empty.
+func newShadowComparator(s *DiscoveryServer, source string, interval time.Duration, maxProxies int) *shadowComparator {
+	if source == "" {
+		return nil
+	}
+	return &shadowComparator{
+		server:     s,
+		source:     strings.TrimSuffix(source, "/"),
+		interval:   interval,
+		maxProxies: maxProxies,
+		client:     &http.Client{Timeout: shadowRequestTimeout},
+		status:     ShadowStatus{Source: source},
+	}
+}
+
+func (c *shadowComparator) run(stop <-chan struct{}) {
+	log.Infof("comparing the xDS generated for the proxies of %s", c.source)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Nothing is compared before the caches of this revision are synced.
+			if c.server.IsServerReady() {
+				c.compareRound()
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// compareRound compares the resources of up to maxProxies proxies of the stable revision.
+func (c *shadowComparator) compareRound() {
+	proxies, err := c.listProxies()
+	if err != nil {
+		log.Warnf("shadow comparison: failed to list the proxies of %s: %v", c.source, err)
+		shadowErrors.Increment()
+		return
+	}
+	status := ShadowStatus{Source: c.source, Time: time.Now(), Connected: len(proxies)}
+	for _, proxyID := range c.selectProxies(proxies) {
+		status.Compared++
+		result := c.compare(proxyID)
+		switch {
+		case result.Error != "":
+			status.Failed++
+			shadowErrors.Increment()
+			log.Debugf("shadow comparison of %s failed: %v", proxyID, result.Error)
+		case len(result.Diffs) > 0:
+			status.Divergent++
+			recordShadowDivergence(result.Diffs)
+		default:
+			continue
+		}
+		status.Results = append(status.Results, result)
+	}
+	shadowProxies.Record(float64(status.Compared))
+	shadowDivergentProxies.Record(float64(status.Divergent))
+	if status.Divergent > 0 {
+		log.Warnf("shadow comparison: %d of %d proxies of %s diverged, see /debug/shadowz",
+			status.Divergent, status.Compared, c.source)
+	}
+	c.mu.Lock()
+	c.status = status
+	c.mu.Unlock()
+}
+
+// selectProxies returns the proxies compared by a round. When there are more than maxProxies, consecutive rounds
+// compare consecutive ranges of them.
+func (c *shadowComparator) selectProxies(proxies []string) []string {
+	if c.maxProxies <= 0 || len(proxies) <= c.maxProxies {
+		return proxies
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	start := c.offset % len(proxies)
+	c.offset = start + c.maxProxies
+	out := make([]string, 0, c.maxProxies)
+	for i := 0; i < c.maxProxies; i++ {
+		out = append(out, proxies[(start+i)%len(proxies)])
+	}
+	return out
+}
+
+func recordShadowDivergence(diffs []DryRunDiff) {
+	for _, d := range diffs {
+		t := typeTag.Value(v3.GetMetricType(d.TypeURL))
+		if len(d.Added) > 0 {
+			shadowDivergentResources.With(t, changeTag.Value(ShadowAdded)).RecordInt(int64(len(d.Added)))
+		}
+		if len(d.Removed) > 0 {
+			shadowDivergentResources.With(t, changeTag.Value(ShadowRemoved)).RecordInt(int64(len(d.Removed)))
+		}
+		if len(d.Modified) > 0 {
+			shadowDivergentResources.With(t, changeTag.Value(ShadowModified)).RecordInt(int64(len(d.Modified)))
+		}
+	}
+}
+
+// listProxies returns the sorted IDs of the proxies connected to the stable revision.
+func (c *shadowComparator) listProxies() ([]string, error) {
+	body, err := c.get("/debug/syncz")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var syncz []SyncStatus
+	if err := json.NewDecoder(body).Decode(&syncz); err != nil {
+		return nil, fmt.Errorf("invalid proxy list: %v", err)
+	}
+	ids := sets.New[string]()
+	for _, s := range syncz {
+		if s.ProxyID != "" {
+			ids.Insert(s.ProxyID)
+		}
+	}
+	return sets.SortedList(ids), nil
+}
+
+// compare generates the resources of a proxy of the stable revision, and compares them with the snapshot of
+// the stable revision.
+func (c *shadowComparator) compare(proxyID string) ShadowResult {
+	result := ShadowResult{Proxy: proxyID, Time: time.Now()}
+	snap, err := c.snapshot(proxyID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	diffs, err := c.server.compareSnapshot(snap)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Diffs = diffs
+	return result
+}
+
+func (c *shadowComparator) snapshot(proxyID string) (*Snapshot, error) {
+	body, err := c.get("/debug/snapshot?proxyID=" + url.QueryEscape(proxyID))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ReadSnapshot(body)
+}
+
+// get requests a debug endpoint of the stable revision. Requests are authenticated with the service account
+// token of pilot, if any, as debug endpoints only serve unauthenticated requests from localhost.
+func (c *shadowComparator) get(path string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.source+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token, err := os.ReadFile(securitymodel.K8sSAJwtFileName); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("%s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Body, nil
+}
+
+// compareSnapshot generates the resources of the proxy of a snapshot, for the types it holds, and returns how
+// they differ from the ones of the snapshot.
+func (s *DiscoveryServer) compareSnapshot(snap *Snapshot) ([]DryRunDiff, error) {
+	if snap.Node == nil {
+		return nil, fmt.Errorf("the snapshot of %s does not describe the proxy", snap.Proxy)
+	}
+	proxy := &model.Proxy{VerifiedIdentity: snap.Identity, WatchedResources: map[string]*model.WatchedResource{}}
+	for typeURL, w := range snap.Watches {
+		if _, f := snap.Resources[typeURL]; f {
+			proxy.WatchedResources[typeURL] = w
+		}
+	}
+	con := &Connection{conID: "shadow-" + snap.Proxy, node: snap.Node, proxy: proxy}
+	generated, err := s.generateWithoutCache(con, s.Env, s.globalPushContext())
+	if err != nil {
+		return nil, err
+	}
+
+	typeURLs := sets.New[string]()
+	for typeURL := range snap.Resources {
+		typeURLs.Insert(typeURL)
+	}
+	for typeURL := range generated {
+		typeURLs.Insert(typeURL)
+	}
+	diffs := []DryRunDiff{}
+	for _, typeURL := range sets.SortedList(typeURLs) {
+		if diff := diffResources(typeURL, snap.Resources[typeURL], generated[typeURL]); diff != nil {
+			diffs = append(diffs, *diff)
+		}
+	}
+	return diffs, nil
+}
+
+// shadowz returns the outcome of the last shadow comparison round.
+// It is mapped to /debug/shadowz
+func (s *DiscoveryServer) shadowz(w http.ResponseWriter, req *http.Request) {
+	if s.shadow == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("shadow comparison is disabled, set PILOT_SHADOW_PUSH_SOURCE to enable it\n"))
+		return
+	}
+	s.shadow.mu.RLock()
+	status := s.shadow.status
+	s.shadow.mu.RUnlock()
+	results := append([]ShadowResult(nil), status.Results...)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Proxy < results[j].Proxy
+	})
+	status.Results = results
+	writeJSON(w, status, req)
+}
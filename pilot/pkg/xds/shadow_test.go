@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+const shadowServiceEntry = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: %s
+  namespace: default
+spec:
+  hosts:
+  - %s.example.com
+  ports:
+  - number: 80
+    name: http
+    protocol: HTTP
+  resolution: DNS
+`
+
+func shadowConfig(names ...string) string {
+	out := ""
+	for _, name := range names {
+		out += "---" + fmt.Sprintf(shadowServiceEntry, name, name)
+	}
+	return out
+}
+
+func TestShadowComparison(t *testing.T) {
+	stable := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: shadowConfig("a", "b")})
+	stable.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/syncz", stable.Discovery.Syncz)
+	mux.HandleFunc("/debug/snapshot", stable.Discovery.snapshotz)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	same := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: shadowConfig("a", "b")})
+	c := newShadowComparator(same.Discovery, srv.URL, time.Minute, 0)
+	c.compareRound()
+	if c.status.Connected != 1 || c.status.Compared != 1 || c.status.Divergent != 0 || c.status.Failed != 0 {
+		t.Fatalf("expected no divergence, got %+v", c.status)
+	}
+
+	canary := NewFakeDiscoveryServer(t, FakeOptions{ConfigString: shadowConfig("a", "c")})
+	c = newShadowComparator(canary.Discovery, srv.URL, time.Minute, 0)
+	c.compareRound()
+	if c.status.Compared != 1 || c.status.Divergent != 1 || len(c.status.Results) != 1 {
+		t.Fatalf("expected a divergence, got %+v", c.status)
+	}
+	diffs := c.status.Results[0].Diffs
+	if len(diffs) != 1 || diffs[0].TypeURL != v3.ClusterType {
+		t.Fatalf("expected the clusters to diverge, got %+v", diffs)
+	}
+	if !reflect.DeepEqual(diffs[0].Added, []string{"outbound|80||c.example.com"}) ||
+		!reflect.DeepEqual(diffs[0].Removed, []string{"outbound|80||b.example.com"}) {
+		t.Fatalf("unexpected added %v or removed %v clusters", diffs[0].Added, diffs[0].Removed)
+	}
+
+	c = newShadowComparator(canary.Discovery, srv.URL+"/missing", time.Minute, 0)
+	c.compareRound()
+	if c.status.Compared != 0 {
+		t.Fatalf("expected no proxy to be compared, got %+v", c.status)
+	}
+}
+
+func TestShadowSelectProxies(t *testing.T) {
+	c := newShadowComparator(nil, "http://stable", time.Minute, 2)
+	proxies := []string{"a", "b", "c"}
+	for _, want := range [][]string{{"a", "b"}, {"c", "a"}, {"b", "c"}} {
+		if got := c.selectProxies(proxies); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got proxies %v, want %v", got, want)
+		}
+	}
+	if newShadowComparator(nil, "", time.Minute, 2) != nil {
+		t.Fatalf("expected the shadow comparison to be disabled without a source")
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/spiffe"
+	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const snapshotMetadataFile = "metadata.json"
+
+// Snapshot is the full set of resources generated for a proxy, for every type it watches. Snapshots are
+// exported by /debug/snapshot to replay the configs of a proxy offline, see FakeDiscoveryServer.LoadSnapshot.
+type Snapshot struct {
+	Proxy string
+	Time  time.Time
+	// Resources holds the generated resources by type URL.
+	Resources map[string]model.Resources
+	// Node, Identity and Watches describe the proxy, so that its resources can be generated again elsewhere, as
+	// the shadow comparison does. They are optional.
+	Node     *core.Node
+	Identity *spiffe.Identity
+	Watches  map[string]*model.WatchedResource
+}
+
+type snapshotMetadata struct {
+	Proxy    string                   `json:"proxy"`
+	Time     time.Time                `json:"time"`
+	Types    []string                 `json:"types"`
+	Node     json.RawMessage          `json:"node,omitempty"`
+	Identity *spiffe.Identity         `json:"identity,omitempty"`
+	Watches  map[string]snapshotWatch `json:"watches,omitempty"`
+}
+
+// snapshotWatch is a resource type watched by the proxy of a snapshot.
+type snapshotWatch struct {
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	Wildcard      bool     `json:"wildcard,omitempty"`
+}
+
+// WriteSnapshot writes the snapshot as a tar archive. The archive holds a metadata.json file, and a file per
+// type with the resources of the type as a JSON DeltaDiscoveryResponse, so they keep their names.
+func WriteSnapshot(w io.Writer, snap *Snapshot) error {
+	types := make([]string, 0, len(snap.Resources))
+	for typeURL := range snap.Resources {
+		types = append(types, typeURL)
+	}
+	sort.Strings(types)
+
+	m := snapshotMetadata{Proxy: snap.Proxy, Time: snap.Time, Types: types, Identity: snap.Identity}
+	if snap.Node != nil {
+		node, err := protomarshal.Marshal(snap.Node)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the node: %v", err)
+		}
+		m.Node = node
+	}
+	if len(snap.Watches) > 0 {
+		m.Watches = make(map[string]snapshotWatch, len(snap.Watches))
+		for typeURL, w := range snap.Watches {
+			m.Watches[typeURL] = snapshotWatch{ResourceNames: w.ResourceNames, Wildcard: w.Wildcard}
+		}
+	}
+
+	tw := tar.NewWriter(w)
+	meta, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeSnapshotFile(tw, snapshotMetadataFile, meta, snap.Time); err != nil {
+		return err
+	}
+	for _, typeURL := range types {
+		b, err := protomarshal.MarshalIndentWithGlobalTypesResolver(&discovery.DeltaDiscoveryResponse{
+			TypeUrl:   typeURL,
+			Resources: snap.Resources[typeURL],
+		}, "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %v", typeURL, err)
+		}
+		if err := writeSnapshotFile(tw, snapshotFileName(typeURL), b, snap.Time); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeSnapshotFile(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// snapshotFileName returns the name of the file holding the resources of a type in a snapshot archive.
+func snapshotFileName(typeURL string) string {
+	return strings.NewReplacer("/", "_", ".", "_").Replace(typeURL) + ".json"
+}
+
+// ReadSnapshot reads a snapshot written by WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = b
+	}
+
+	meta, f := files[snapshotMetadataFile]
+	if !f {
+		return nil, errors.New("invalid snapshot: missing " + snapshotMetadataFile)
+	}
+	var m snapshotMetadata
+	if err := json.Unmarshal(meta, &m); err != nil {
+		return nil, fmt.Errorf("invalid snapshot metadata: %v", err)
+	}
+	snap := &Snapshot{Proxy: m.Proxy, Time: m.Time, Identity: m.Identity, Resources: make(map[string]model.Resources, len(m.Types))}
+	if len(m.Node) > 0 {
+		snap.Node = &core.Node{}
+		if err := protomarshal.Unmarshal(m.Node, snap.Node); err != nil {
+			return nil, fmt.Errorf("invalid snapshot node: %v", err)
+		}
+	}
+	if len(m.Watches) > 0 {
+		snap.Watches = make(map[string]*model.WatchedResource, len(m.Watches))
+		for typeURL, w := range m.Watches {
+			snap.Watches[typeURL] = &model.WatchedResource{TypeUrl: typeURL, ResourceNames: w.ResourceNames, Wildcard: w.Wildcard}
+		}
+	}
+	for _, typeURL := range m.Types {
+		b, f := files[snapshotFileName(typeURL)]
+		if !f {
+			return nil, fmt.Errorf("invalid snapshot: missing resources of %s", typeURL)
+		}
+		resp := &discovery.DeltaDiscoveryResponse{}
+		if err := protomarshal.UnmarshalWithGlobalTypesResolver(b, resp); err != nil {
+			return nil, fmt.Errorf("invalid snapshot resources of %s: %v", typeURL, err)
+		}
+		snap.Resources[typeURL] = resp.Resources
+	}
+	return snap, nil
+}
+
+// DiffSnapshots returns the changes from the resources of snapshot a to those of snapshot b, for every type
+// either holds. The resources of b which a does not hold are added, and those of a which b does not hold are removed.
+func DiffSnapshots(a, b *Snapshot) []DryRunDiff {
+	types := sets.New[string]()
+	for typeURL := range a.Resources {
+		types.Insert(typeURL)
+	}
+	for typeURL := range b.Resources {
+		types.Insert(typeURL)
+	}
+	diffs := []DryRunDiff{}
+	for _, typeURL := range sets.SortedList(types) {
+		if diff := diffResources(typeURL, a.Resources[typeURL], b.Resources[typeURL]); diff != nil {
+			diffs = append(diffs, *diff)
+		}
+	}
+	return diffs
+}
+
+// snapshotz writes a snapshot of the resources generated for a proxy, as a tar archive. Resources are generated
+// from the current push context without caches, and secrets are left out.
+// It is mapped to /debug/snapshot
+func (s *DiscoveryServer) snapshotz(w http.ResponseWriter, req *http.Request) {
+	proxyID, con := s.getDebugConnection(req)
+	if con == nil {
+		s.errorHandler(w, proxyID, con)
+		return
+	}
+	res, err := s.generateWithoutCache(con, s.Env, s.globalPushContext())
+	if err != nil {
+		handleHTTPError(w, err)
+		return
+	}
+	snap := &Snapshot{
+		Proxy:     con.proxy.ID,
+		Time:      time.Now(),
+		Resources: res,
+		Node:      con.node,
+		Identity:  con.proxy.VerifiedIdentity,
+		Watches:   map[string]*model.WatchedResource{},
+	}
+	con.proxy.RLock()
+	for typeURL, w := range con.proxy.WatchedResources {
+		snap.Watches[typeURL] = &model.WatchedResource{
+			TypeUrl:       typeURL,
+			ResourceNames: append([]string(nil), w.ResourceNames...),
+			Wildcard:      w.Wildcard,
+		}
+	}
+	con.proxy.RUnlock()
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", con.proxy.ID+".tar"))
+	if err := WriteSnapshot(w, snap); err != nil {
+		log.Errorf("failed to write snapshot of %s: %v", con.proxy.ID, err)
+	}
+}
+
+// snapshotGenerator serves the resources of a snapshot instead of generating them.
+type snapshotGenerator struct {
+	resources model.Resources
+}
+
+var _ model.XdsResourceGenerator = &snapshotGenerator{}
+
+func (g *snapshotGenerator) Generate(_ *model.Proxy, w *model.WatchedResource, _ *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	if w == nil || w.Wildcard || len(w.ResourceNames) == 0 {
+		return g.resources, model.DefaultXdsLogDetails, nil
+	}
+	names := sets.New(w.ResourceNames...)
+	res := make(model.Resources, 0, len(names))
+	for _, r := range g.resources {
+		if names.Contains(r.Name) {
+			res = append(res, r)
+		}
+	}
+	return res, model.DefaultXdsLogDetails, nil
+}
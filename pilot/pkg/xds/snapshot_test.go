@@ -0,0 +1,141 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pilot/test/xdstest"
+	"istio.io/istio/pkg/spiffe"
+)
+
+func snapshotCluster(name string) *discovery.Resource {
+	return &discovery.Resource{Name: name, Resource: protoconv.MessageToAny(&cluster.Cluster{Name: name})}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	snap := &Snapshot{
+		Proxy: "gateway.istio-system",
+		Time:  time.Now().UTC().Truncate(time.Second),
+		Resources: map[string]model.Resources{
+			v3.ClusterType: {snapshotCluster("a"), snapshotCluster("b")},
+			v3.RouteType:   {},
+		},
+		Node:     &core.Node{Id: "router~10.0.0.1~gateway.istio-system~istio-system.svc.cluster.local"},
+		Identity: &spiffe.Identity{TrustDomain: "cluster.local", Namespace: "istio-system", ServiceAccount: "gateway"},
+		Watches:  map[string]*model.WatchedResource{v3.RouteType: {TypeUrl: v3.RouteType, ResourceNames: []string{"http.80"}}},
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteSnapshot(buf, snap); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshot(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Proxy != snap.Proxy || !got.Time.Equal(snap.Time) || len(got.Resources) != len(snap.Resources) {
+		t.Fatalf("got snapshot %+v, want %+v", got, snap)
+	}
+	for typeURL, res := range snap.Resources {
+		if len(got.Resources[typeURL]) != len(res) {
+			t.Fatalf("got %d resources of %s, want %d", len(got.Resources[typeURL]), typeURL, len(res))
+		}
+		for i := range res {
+			if !proto.Equal(got.Resources[typeURL][i], res[i]) {
+				t.Fatalf("got resource %v, want %v", got.Resources[typeURL][i], res[i])
+			}
+		}
+	}
+
+	if got.Node.GetId() != snap.Node.Id || *got.Identity != *snap.Identity ||
+		!reflect.DeepEqual(got.Watches[v3.RouteType].ResourceNames, []string{"http.80"}) {
+		t.Fatalf("got proxy %v %v %v, want %v %v %v", got.Node, got.Identity, got.Watches, snap.Node, snap.Identity, snap.Watches)
+	}
+
+	if _, err := ReadSnapshot(&bytes.Buffer{}); err == nil {
+		t.Fatalf("expected an error reading an empty archive")
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.tar")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &Snapshot{
+		Proxy:     "gateway.istio-system",
+		Resources: map[string]model.Resources{v3.ClusterType: {snapshotCluster("outbound|80||replayed.com")}},
+	}
+	if err := WriteSnapshot(file, snap); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	s := NewFakeDiscoveryServer(t, FakeOptions{})
+	s.LoadSnapshotFile(path)
+	res := s.ConnectADS().WithType(v3.ClusterType).RequestResponseAck(t, nil)
+	var got []string
+	for _, r := range res.Resources {
+		got = append(got, xdstest.UnmarshalAny[cluster.Cluster](t, r).Name)
+	}
+	if !reflect.DeepEqual(got, []string{"outbound|80||replayed.com"}) {
+		t.Fatalf("expected the clusters of the snapshot, got %v", got)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	a := &Snapshot{Resources: map[string]model.Resources{
+		v3.ClusterType:  {snapshotCluster("shared"), snapshotCluster("only-a")},
+		v3.ListenerType: {},
+	}}
+	modified := &cluster.Cluster{Name: "shared", AltStatName: "b"}
+	b := &Snapshot{Resources: map[string]model.Resources{
+		v3.ClusterType: {{Name: "shared", Resource: protoconv.MessageToAny(modified)}, snapshotCluster("only-b")},
+		v3.RouteType:   {{Name: "http.80", Resource: protoconv.MessageToAny(&route.RouteConfiguration{Name: "http.80"})}},
+	}}
+	diffs := DiffSnapshots(a, b)
+	if len(diffs) != 2 || diffs[0].TypeURL != v3.ClusterType || diffs[1].TypeURL != v3.RouteType {
+		t.Fatalf("got diffs %+v, want the clusters and routes", diffs)
+	}
+	clusters := diffs[0]
+	if !reflect.DeepEqual(clusters.Added, []string{"only-b"}) || !reflect.DeepEqual(clusters.Removed, []string{"only-a"}) {
+		t.Errorf("got added %v and removed %v", clusters.Added, clusters.Removed)
+	}
+	if len(clusters.Modified) != 1 || clusters.Modified[0].Name != "shared" || !strings.Contains(clusters.Modified[0].Diff, "alt_stat_name") {
+		t.Errorf("got modified %+v, want the alt stat name of shared", clusters.Modified)
+	}
+	if !reflect.DeepEqual(diffs[1].Added, []string{"http.80"}) {
+		t.Errorf("got added routes %v", diffs[1].Added)
+	}
+	if diffs := DiffSnapshots(a, a); len(diffs) != 0 {
+		t.Errorf("got diffs %+v of a snapshot with itself", diffs)
+	}
+}
@@ -32,6 +32,7 @@ const (
 
 	// Added by ingress
 	ScopedRouteType = resource.APITypePrefix + "envoy.config.route.v3.ScopedRouteConfiguration"
+	VirtualHostType = resource.VirtualHostType
 	// End added by ingress
 
 	NameTableType   = resource.APITypePrefix + "istio.networking.nds.v1.NameTable"
@@ -75,6 +76,8 @@ func GetShortType(typeURL string) string {
 	// Added by ingress
 	case ScopedRouteType:
 		return "SRDS"
+	case VirtualHostType:
+		return "VHDS"
 	// End added by ingress
 	default:
 		return typeURL
@@ -109,6 +112,8 @@ func GetMetricType(typeURL string) string {
 	// Added by ingress
 	case ScopedRouteType:
 		return "srds"
+	case VirtualHostType:
+		return "vhds"
 	// End added by ingress
 	default:
 		return typeURL
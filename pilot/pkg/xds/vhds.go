@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"sort"
+	"strings"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/maps"
+)
+
+// VhdsGenerator generates the virtual hosts requested on demand by the gateways whose route configurations are sent
+// without their virtual hosts. Envoy requests them by the alias <route configuration>/<host header>, and each
+// resolved alias gets a virtual host of its own, named by the alias and only matching its host, so that the aliases
+// resolving to the same virtual host, such as a wildcard one, do not duplicate its domains.
+type VhdsGenerator struct {
+	Server *DiscoveryServer
+}
+
+var (
+	_ model.XdsResourceGenerator      = &VhdsGenerator{}
+	_ model.XdsDeltaResourceGenerator = &VhdsGenerator{}
+)
+
+func (g VhdsGenerator) Generate(proxy *model.Proxy, w *model.WatchedResource, req *model.PushRequest) (model.Resources, model.XdsLogDetails, error) {
+	res, _, logDetails, _, err := g.GenerateDeltas(proxy, req, w)
+	return res, logDetails, err
+}
+
+// GenerateDeltas resolves the aliases watched by the proxy. The aliases the proxy just requested which do not
+// resolve are answered with an empty resource, which tells Envoy they were not found, while those no longer
+// resolving on a push are removed.
+func (g VhdsGenerator) GenerateDeltas(proxy *model.Proxy, req *model.PushRequest,
+	w *model.WatchedResource,
+) (model.Resources, model.DeletedResources, model.XdsLogDetails, bool, error) {
+	if !proxy.VHDSEnabled() || !rdsNeedsPush(req) || len(w.ResourceNames) == 0 {
+		return nil, nil, model.DefaultXdsLogDetails, false, nil
+	}
+	aliasesByRoute := map[string][]string{}
+	var unresolved []string
+	for _, alias := range w.ResourceNames {
+		routeName, host, ok := strings.Cut(alias, "/")
+		if !ok || host == "" || strings.Contains(host, "*") {
+			unresolved = append(unresolved, alias)
+			continue
+		}
+		aliasesByRoute[routeName] = append(aliasesByRoute[routeName], alias)
+	}
+	res := model.Resources{}
+	for _, rc := range g.Server.ConfigGenerator.BuildVirtualHosts(proxy, req, maps.Keys(aliasesByRoute)) {
+		aliases := aliasesByRoute[rc.Name]
+		delete(aliasesByRoute, rc.Name)
+		for _, alias := range aliases {
+			_, host, _ := strings.Cut(alias, "/")
+			vh := matchVirtualHost(rc.VirtualHosts, host)
+			if vh == nil {
+				unresolved = append(unresolved, alias)
+				continue
+			}
+			vh = proto.Clone(vh).(*route.VirtualHost)
+			vh.Name = alias
+			vh.Domains = []string{host}
+			res = append(res, &discovery.Resource{Name: alias, Aliases: []string{alias}, Resource: protoconv.MessageToAny(vh)})
+		}
+	}
+	for _, aliases := range aliasesByRoute {
+		unresolved = append(unresolved, aliases...)
+	}
+	var deleted model.DeletedResources
+	for _, alias := range unresolved {
+		if req.Delta.Subscribed.Contains(alias) {
+			res = append(res, &discovery.Resource{Name: alias, Aliases: []string{alias}})
+		} else {
+			deleted = append(deleted, alias)
+		}
+	}
+	sort.Strings(deleted)
+	return res, deleted, model.DefaultXdsLogDetails, true, nil
+}
+
+// matchVirtualHost returns the virtual host Envoy would select for the host: the one with the host as domain,
+// else with the longest suffix wildcard domain matching it, else with the longest prefix wildcard one, else with the
+// * domain.
+func matchVirtualHost(vhosts []*route.VirtualHost, host string) *route.VirtualHost {
+	host = strings.ToLower(host)
+	var best *route.VirtualHost
+	bestRank, bestLen := 0, 0
+	for _, vh := range vhosts {
+		for _, domain := range vh.Domains {
+			rank, n := domainMatch(strings.ToLower(domain), host)
+			if rank > bestRank || (rank > 0 && rank == bestRank && n > bestLen) {
+				best, bestRank, bestLen = vh, rank, n
+			}
+		}
+	}
+	return best
+}
+
+// domainMatch ranks how a domain matches the host, from 4 for an exact match to 1 for the * domain, or 0 if it does
+// not match, with the length of the domain matched to tell the wildcard domains apart.
+func domainMatch(domain, host string) (int, int) {
+	switch {
+	case domain == host:
+		return 4, len(domain)
+	case domain == "*":
+		return 1, 0
+	case strings.HasPrefix(domain, "*"):
+		if suffix := domain[1:]; len(host) > len(suffix) && strings.HasSuffix(host, suffix) {
+			return 3, len(suffix)
+		}
+	case strings.HasSuffix(domain, "*"):
+		if prefix := domain[:len(domain)-1]; len(host) > len(prefix) && strings.HasPrefix(host, prefix) {
+			return 2, len(prefix)
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,129 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/util/sets"
+)
+
+type fakeRouteGenerator struct {
+	core.ConfigGenerator
+	routes []*route.RouteConfiguration
+}
+
+func (g fakeRouteGenerator) BuildVirtualHosts(_ *model.Proxy, _ *model.PushRequest, names []string) []*route.RouteConfiguration {
+	requested := sets.New(names...)
+	var out []*route.RouteConfiguration
+	for _, rc := range g.routes {
+		if requested.Contains(rc.Name) {
+			out = append(out, rc)
+		}
+	}
+	return out
+}
+
+func TestMatchVirtualHost(t *testing.T) {
+	vhosts := []*route.VirtualHost{
+		{Name: "exact", Domains: []string{"www.example.com", "www.example.com:*"}},
+		{Name: "suffix", Domains: []string{"*.example.com"}},
+		{Name: "longer suffix", Domains: []string{"*.api.example.com"}},
+		{Name: "prefix", Domains: []string{"www.*"}},
+		{Name: "any", Domains: []string{"*"}},
+	}
+	cases := map[string]string{
+		"www.example.com":      "exact",
+		"WWW.Example.com":      "exact",
+		"www.example.com:8080": "exact",
+		"a.example.com":        "suffix",
+		"a.api.example.com":    "longer suffix",
+		"www.example.org":      "prefix",
+		"example.org":          "any",
+	}
+	for host, want := range cases {
+		if got := matchVirtualHost(vhosts, host); got == nil || got.Name != want {
+			t.Errorf("got virtual host %v for %s, want %s", got, host, want)
+		}
+	}
+	if got := matchVirtualHost(vhosts[:1], "example.org"); got != nil {
+		t.Errorf("expected no virtual host, got %v", got)
+	}
+}
+
+func TestVhdsGenerator(t *testing.T) {
+	test.SetForTest(t, &alifeatures.EnableVHDS, true)
+	test.SetForTest(t, &alifeatures.EnableScopedRDS, false)
+	rc := &route.RouteConfiguration{
+		Name: "http.80",
+		VirtualHosts: []*route.VirtualHost{
+			{Name: "www.example.com:80", Domains: []string{"www.example.com", "www.example.com:*"}},
+			{Name: "*.example.org:80", Domains: []string{"*.example.org", "*.example.org:*"}},
+		},
+	}
+	s := &DiscoveryServer{ConfigGenerator: fakeRouteGenerator{routes: []*route.RouteConfiguration{rc}}}
+	gateway := &model.Proxy{Type: model.Router, DeltaXds: true}
+
+	aliases := []string{"http.80/www.example.com", "http.80/a.example.org", "http.80/b.example.org", "http.80/missing.com", "http.81/www.example.com"}
+	g := VhdsGenerator{Server: s}
+	req := &model.PushRequest{Full: true, Delta: model.ResourceDelta{Subscribed: sets.New("http.80/missing.com")}}
+	res, deleted, _, usedDelta, err := g.GenerateDeltas(gateway, req, &model.WatchedResource{TypeUrl: v3.VirtualHostType, ResourceNames: aliases})
+	if err != nil || !usedDelta {
+		t.Fatalf("unexpected result %v %v", usedDelta, err)
+	}
+	byName := map[string]*discovery.Resource{}
+	for _, r := range res {
+		byName[r.Name] = r
+	}
+	for _, alias := range []string{"http.80/www.example.com", "http.80/a.example.org", "http.80/b.example.org"} {
+		r := byName[alias]
+		if r == nil {
+			t.Fatalf("expected %s to be resolved, got %v", alias, res)
+		}
+		vh := &route.VirtualHost{}
+		if err := r.GetResource().UnmarshalTo(vh); err != nil {
+			t.Fatal(err)
+		}
+		_, host, _ := strings.Cut(alias, "/")
+		if vh.Name != alias || !reflect.DeepEqual(vh.Domains, []string{host}) || !reflect.DeepEqual(r.Aliases, []string{alias}) {
+			t.Errorf("unexpected virtual host %v for %s", vh, alias)
+		}
+	}
+	// The requested alias not found is answered without a resource, the others are removed.
+	if r := byName["http.80/missing.com"]; r == nil || r.Resource != nil {
+		t.Errorf("expected an empty resource for the alias not found, got %v", r)
+	}
+	if !reflect.DeepEqual(deleted, model.DeletedResources{"http.81/www.example.com"}) {
+		t.Errorf("got deleted %v", deleted)
+	}
+
+	// Sidecars and the gateways connected with the state of the world protocol get their virtual hosts with the
+	// route configurations.
+	for _, proxy := range []*model.Proxy{{Type: model.SidecarProxy, DeltaXds: true}, {Type: model.Router}} {
+		if res, _, _, _, _ := g.GenerateDeltas(proxy, req, &model.WatchedResource{ResourceNames: aliases}); res != nil {
+			t.Errorf("expected no virtual hosts for %v, got %v", proxy.Type, res)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	istiogrpc "istio.io/istio/pilot/pkg/grpc"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// States of the discovery server, as reported by ServingState.
+const (
+	// ServingLive is the state of a server generating configs.
+	ServingLive = "live"
+	// ServingStandby is the state of a server in warm standby, serving the cached configs read-only while its
+	// caches sync. It is degraded: proxies get no config changes, and proxies with nothing cached get nothing.
+	ServingStandby = "standby"
+	// ServingNotReady is the state of a server rejecting connections until its caches are synced.
+	ServingNotReady = "not ready"
+)
+
+// standbyReconnectJitter spreads the reconnections of the proxies once the warm standby ends.
+const standbyReconnectJitter = 5 * time.Second
+
+// IsStandby returns true if the server serves the cached configs while its caches sync.
+func (s *DiscoveryServer) IsStandby() bool {
+	return alifeatures.EnableWarmStandby && s.ResourceCache != nil && !s.IsServerReady()
+}
+
+// ServingState returns the state of the server: live, standby or not ready.
+func (s *DiscoveryServer) ServingState() string {
+	switch {
+	case s.IsServerReady():
+		return ServingLive
+	case s.IsStandby():
+		return ServingStandby
+	default:
+		return ServingNotReady
+	}
+}
+
+// endStandby ends the warm standby, closing the streams served from the cache so their proxies reconnect and get
+// live configs.
+func (s *DiscoveryServer) endStandby() {
+	if s.standbyEnded == nil {
+		return
+	}
+	s.standbyOnce.Do(func() {
+		close(s.standbyEnded)
+	})
+}
+
+// streamStandby serves a SotW stream in warm standby: each type requested by the proxy gets the response it
+// last ACKed, as held by the ResourceCache, and nothing is generated. Types with nothing cached get no response.
+// Once the caches are synced, the stream is closed so the proxy reconnects and gets live configs. Proxies keep
+// their configs across the reconnection, so rolling pilot leaves no gap.
+func (s *DiscoveryServer) streamStandby(stream DiscoveryStream) error {
+	ctx := stream.Context()
+	peerAddr := "0.0.0.0"
+	if peerInfo, ok := peer.FromContext(ctx); ok {
+		peerAddr = peerInfo.Addr.String()
+	}
+	if err := s.WaitForRequestLimit(ctx); err != nil {
+		log.Warnf("ADS: %q exceeded rate limit: %v", peerAddr, err)
+		return status.Errorf(codes.ResourceExhausted, "request rate limit exceeded: %v", err)
+	}
+	identities, err := s.authenticate(ctx)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	reqs := make(chan *discovery.DiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var node *core.Node
+	served := sets.New[string]()
+	for {
+		select {
+		case <-s.standbyEnded:
+			// Spread the reconnections, as they all get their configs generated.
+			time.Sleep(time.Duration(rand.Int63n(int64(standbyReconnectJitter))))
+			log.Infof("ADS: %q warm standby ended, closing stream served from cache", peerAddr)
+			return status.Error(codes.Unavailable, "warm standby ended; reconnect for live configs")
+		case err := <-errs:
+			if istiogrpc.IsExpectedGRPCError(err) {
+				return nil
+			}
+			return err
+		case req := <-reqs:
+			if node == nil {
+				if req.Node == nil || req.Node.Id == "" {
+					return status.New(codes.InvalidArgument, "missing node information").Err()
+				}
+				if err := s.authorizeStandby(req.Node, identities, peerAddr); err != nil {
+					return err
+				}
+				node = req.Node
+				log.Infof("ADS: %q %s connected in warm standby, serving cached configs", peerAddr, node.Id)
+			}
+			// ACKs and NACKs of the cached responses need no response, and secrets are never cached.
+			if req.ErrorDetail != nil || served.Contains(req.TypeUrl) ||
+				req.TypeUrl == v3.SecretType || strings.HasPrefix(req.TypeUrl, v3.DebugType) {
+				continue
+			}
+			resp, err := s.ResourceCache.Load(&discovery.DiscoveryRequest{Node: node, TypeUrl: req.TypeUrl})
+			if err != nil || resp == nil {
+				standbyResponseMisses.Increment()
+				log.Debugf("ADS:%s: nothing cached for %s in warm standby: %v", v3.GetShortType(req.TypeUrl), node.Id, err)
+				continue
+			}
+			served.Insert(req.TypeUrl)
+			resp.Nonce = nonce("standby")
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+			standbyResponseHits.Increment()
+			log.Infof("%s: PUSH CACHED for node:%s resources:%d", v3.GetShortType(req.TypeUrl), node.Id, len(resp.Resources))
+		}
+	}
+}
+
+// authorizeStandby checks the identities of a stream served in warm standby are allowed the configs of the node.
+func (s *DiscoveryServer) authorizeStandby(node *core.Node, identities []string, peerAddr string) error {
+	proxy, err := s.initProxyMetadata(node)
+	if err != nil {
+		return err
+	}
+	return s.authorize(&Connection{proxy: proxy, peerAddr: peerAddr}, identities)
+}
+
+// cacheSentResponse adds a response sent over SotW to the ResourceCache, where it is stored once ACKed. Only the
+// responses holding all the resources watched by the proxy are cached, as they are served alone in warm standby:
+// partial responses, such as incremental EDS pushes, keep the last full response cached.
+func (s *DiscoveryServer) cacheSentResponse(con *Connection, resp *discovery.DiscoveryResponse, partial bool) {
+	if s.ResourceCache == nil || resp.TypeUrl == v3.SecretType || strings.HasPrefix(resp.TypeUrl, v3.DebugType) {
+		return
+	}
+	if partial {
+		log.Debugf("ADS:%s: not caching partial response for %s", v3.GetShortType(resp.TypeUrl), con.conID)
+		return
+	}
+	if err := s.ResourceCache.Add(resp); err != nil {
+		log.Debugf("ADS:%s: not caching response for %s: %v", v3.GetShortType(resp.TypeUrl), con.conID, err)
+	}
+}
+
+// storeAckedResponse stores the response ACKed by the request in the ResourceCache.
+func (s *DiscoveryServer) storeAckedResponse(con *Connection, request *discovery.DiscoveryRequest) {
+	if s.ResourceCache == nil || request.TypeUrl == v3.SecretType || strings.HasPrefix(request.TypeUrl, v3.DebugType) {
+		return
+	}
+	// Only the first request of a stream carries the node.
+	err := s.ResourceCache.Store(&discovery.DiscoveryRequest{
+		Node:          con.node,
+		TypeUrl:       request.TypeUrl,
+		VersionInfo:   request.VersionInfo,
+		ResponseNonce: request.ResponseNonce,
+	})
+	if err != nil {
+		log.Debugf("ADS:%s: not storing ACKed response for %s: %v", v3.GetShortType(request.TypeUrl), con.conID, err)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	alifeatures "istio.io/istio/pkg/ali/features"
+	"istio.io/istio/pkg/cache"
+	"istio.io/istio/pkg/test"
+)
+
+// standbyStream is a DiscoveryStream fed with requests, recording the responses.
+type standbyStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	requests  chan *discovery.DiscoveryRequest
+	responses chan *discovery.DiscoveryResponse
+}
+
+func (s *standbyStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *standbyStream) Send(resp *discovery.DiscoveryResponse) error {
+	s.responses <- resp
+	return nil
+}
+
+func (s *standbyStream) Recv() (*discovery.DiscoveryRequest, error) {
+	select {
+	case req := <-s.requests:
+		return req, nil
+	case <-s.ctx.Done():
+		return nil, io.EOF
+	}
+}
+
+func TestWarmStandby(t *testing.T) {
+	test.SetForTest(t, &alifeatures.EnableWarmStandby, true)
+	rc, err := cache.NewFileXdsResourceCache(t.TempDir(), cache.XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(rc.Flush)
+	s := &DiscoveryServer{
+		ResourceCache:    rc,
+		RequestRateLimit: rate.NewLimiter(0, 1),
+		standbyEnded:     make(chan struct{}),
+	}
+	if got := s.ServingState(); got != ServingStandby {
+		t.Fatalf("expected standby, got %v", got)
+	}
+
+	// A response ACKed by the proxy over a previous connection is cached.
+	node := &core.Node{Id: "router~10.0.0.1~gateway-1.higress-system~higress-system.svc.cluster.local"}
+	con := &Connection{conID: "gateway-1", node: node, proxy: &model.Proxy{ID: node.Id}}
+	resp := &discovery.DiscoveryResponse{
+		TypeUrl:   v3.ClusterType,
+		Nonce:     "n1",
+		Resources: []*anypb.Any{protoconv.MessageToAny(&cluster.Cluster{Name: "outbound|80||a.example.com"})},
+	}
+	s.cacheSentResponse(con, resp, false)
+	s.storeAckedResponse(con, &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n1"})
+	// A partial response leaves the last full response cached.
+	partial := &discovery.DiscoveryResponse{
+		TypeUrl:   v3.ClusterType,
+		Nonce:     "n2",
+		Resources: []*anypb.Any{protoconv.MessageToAny(&cluster.Cluster{Name: "outbound|80||b.example.com"})},
+	}
+	s.cacheSentResponse(con, partial, true)
+	s.storeAckedResponse(con, &discovery.DiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: "n2"})
+
+	ctx, cancel := context.WithCancel(peer.NewContext(context.Background(), &peer.Peer{Addr: &net.IPAddr{IP: net.ParseIP("10.0.0.1")}}))
+	defer cancel()
+	stream := &standbyStream{
+		ctx:       ctx,
+		requests:  make(chan *discovery.DiscoveryRequest, 3),
+		responses: make(chan *discovery.DiscoveryResponse, 3),
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Stream(stream)
+	}()
+
+	stream.requests <- &discovery.DiscoveryRequest{Node: node, TypeUrl: v3.ClusterType}
+	// Nothing is cached for listeners.
+	stream.requests <- &discovery.DiscoveryRequest{TypeUrl: v3.ListenerType}
+	select {
+	case got := <-stream.responses:
+		if got.TypeUrl != v3.ClusterType || len(got.Resources) != 1 || got.Nonce == "n1" {
+			t.Fatalf("unexpected response %v", got)
+		}
+		if !proto.Equal(got.Resources[0], resp.Resources[0]) {
+			t.Fatalf("expected the last full response, got %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a cached response")
+	}
+
+	s.CachesSynced()
+	if got := s.ServingState(); got != ServingLive {
+		t.Fatalf("expected live, got %v", got)
+	}
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected the stream to be closed as unavailable, got %v", err)
+		}
+	case <-time.After(2 * standbyReconnectJitter):
+		t.Fatalf("expected the stream to be closed once the caches are synced")
+	}
+	select {
+	case got := <-stream.responses:
+		t.Fatalf("unexpected response %v", got)
+	default:
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/istio/pilot/pkg/model"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config/schema/kind"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// maxWasmRefreshes bounds the number of refreshes recorded per WasmPlugin.
+const maxWasmRefreshes = 16
+
+// WasmRefresh is a forced refresh of the module of a WasmPlugin on the proxies matching a label selector.
+type WasmRefresh struct {
+	// Plugin is the resource name of the WasmPlugin, as namespace.name.
+	Plugin   string `json:"plugin"`
+	Selector string `json:"selector"`
+	// Token is appended to the resource version of the plugin for the selected proxies, so their agents pull the
+	// module again when its pull policy is Always, or its image is tagged latest.
+	Token string    `json:"token"`
+	Time  time.Time `json:"time"`
+}
+
+type wasmRefreshEntry struct {
+	refresh  WasmRefresh
+	selector klabels.Selector
+}
+
+// wasmRefreshes records the refreshes of the WasmPlugins, latest last for each plugin.
+type wasmRefreshes struct {
+	mu        sync.RWMutex
+	refreshes map[string][]wasmRefreshEntry
+}
+
+// add records a refresh, replacing the previous one of the plugin with the same selector.
+func (r *wasmRefreshes) add(refresh WasmRefresh, selector klabels.Selector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refreshes == nil {
+		r.refreshes = map[string][]wasmRefreshEntry{}
+	}
+	entries := r.refreshes[refresh.Plugin]
+	kept := make([]wasmRefreshEntry, 0, len(entries)+1)
+	for _, e := range entries {
+		if e.refresh.Selector != refresh.Selector {
+			kept = append(kept, e)
+		}
+	}
+	kept = append(kept, wasmRefreshEntry{refresh: refresh, selector: selector})
+	if len(kept) > maxWasmRefreshes {
+		kept = kept[len(kept)-maxWasmRefreshes:]
+	}
+	r.refreshes[refresh.Plugin] = kept
+}
+
+// token returns the token of the latest refresh of the plugin selecting the proxy, if any.
+func (r *wasmRefreshes) token(plugin string, proxy *model.Proxy) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := r.refreshes[plugin]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].selector.Matches(klabels.Set(proxy.Labels)) {
+			return entries[i].refresh.Token
+		}
+	}
+	return ""
+}
+
+func (r *wasmRefreshes) list() []WasmRefresh {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []WasmRefresh{}
+	for _, entries := range r.refreshes {
+		for _, e := range entries {
+			out = append(out, e.refresh)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Plugin != out[j].Plugin {
+			return out[i].Plugin < out[j].Plugin
+		}
+		return out[i].Time.Before(out[j].Time)
+	})
+	return out
+}
+
+// refreshWasmModule appends the token of the latest refresh selecting the proxy to the resource version of the
+// extension config of a WasmPlugin, which makes the agent of the proxy pull the module again.
+func (s *DiscoveryServer) refreshWasmModule(proxy *model.Proxy, ec *core.TypedExtensionConfig) {
+	token := s.wasmRefreshes.token(ec.Name, proxy)
+	if token == "" {
+		return
+	}
+	updateWasmEnvs(ec, func(envs map[string]string) bool {
+		if _, ok := envs[model.WasmResourceVersionEnv]; !ok {
+			return false
+		}
+		envs[model.WasmResourceVersionEnv] += "-refresh-" + token
+		return true
+	})
+}
+
+// RefreshWasmModule forces the proxies matching the selector to pull the module of the WasmPlugin again, by
+// changing the resource version of its extension config for them, and pushes it to those connected to this Pilot.
+// It returns the IDs of the proxies pushed to.
+func (s *DiscoveryServer) RefreshWasmModule(namespace, name, selector, token string) ([]string, error) {
+	sel, err := klabels.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %v", selector, err)
+	}
+	if token == "" {
+		token = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	plugin := namespace + "." + name
+	s.wasmRefreshes.add(WasmRefresh{Plugin: plugin, Selector: sel.String(), Token: token, Time: time.Now()}, sel)
+
+	push := s.globalPushContext()
+	var pushed []string
+	for _, con := range s.AllClients() {
+		proxy := con.proxy
+		if !sel.Matches(klabels.Set(proxy.Labels)) {
+			continue
+		}
+		proxy.RLock()
+		watched := proxy.WatchedResources[v3.ExtensionConfigurationType]
+		subscribed := watched != nil && sets.New(subscribedResources(watched)...).Contains(plugin)
+		proxy.RUnlock()
+		if !subscribed {
+			continue
+		}
+		s.pushQueue.Enqueue(con, &model.PushRequest{
+			Full:           true,
+			Push:           push,
+			Start:          time.Now(),
+			ConfigsUpdated: sets.New(model.ConfigKey{Kind: kind.WasmPlugin, Name: name, Namespace: namespace}),
+			Reason:         model.NewReasonStats(model.DebugTrigger),
+		})
+		pushed = append(pushed, proxy.ID)
+	}
+	sort.Strings(pushed)
+	log.Infof("WasmPlugin %s/%s refreshed with token %s on %d proxies selected by %q", namespace, name, token,
+		len(pushed), sel.String())
+	return pushed, nil
+}
+
+// wasmRefreshz lists the forced refreshes of the modules of the WasmPlugins. POSTed with plugin=namespace/name, and
+// optionally selector and token, it refreshes the module of the plugin on the selected proxies.
+// It is mapped to /debug/wasm_refresh
+func (s *DiscoveryServer) wasmRefreshz(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeJSON(w, s.wasmRefreshes.list(), req)
+		return
+	}
+	namespace, name, ok := strings.Cut(req.URL.Query().Get("plugin"), "/")
+	if !ok || namespace == "" || name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("plugin must be set as namespace/name\n"))
+		return
+	}
+	pushed, err := s.RefreshWasmModule(namespace, name, req.URL.Query().Get("selector"), req.URL.Query().Get("token"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	if pushed == nil {
+		pushed = []string{}
+	}
+	writeJSON(w, pushed, req)
+}
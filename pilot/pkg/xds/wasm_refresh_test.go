@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds_test
+
+import (
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/spiffe"
+)
+
+func TestRefreshWasmModule(t *testing.T) {
+	s := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		Configs: []config.Config{wasmPlugin},
+	})
+	gen := s.Discovery.Generators[v3.ExtensionConfigurationType]
+	resourceVersion := func(labels map[string]string) string {
+		t.Helper()
+		proxy := s.SetupProxy(&model.Proxy{
+			VerifiedIdentity: &spiffe.Identity{Namespace: "default"},
+			Type:             model.Router,
+			Labels:           labels,
+			Metadata: &model.NodeMetadata{
+				ClusterID: "Kubernetes",
+				Labels:    labels,
+			},
+		})
+		req := &model.PushRequest{Full: true, Push: s.PushContext(), Start: time.Now()}
+		resources, _, err := gen.Generate(proxy, &model.WatchedResource{ResourceNames: []string{"default.default-plugin"}}, req)
+		if err != nil || len(resources) != 1 {
+			t.Fatalf("unexpected generation: %v %v", resources, err)
+		}
+		ec := &core.TypedExtensionConfig{}
+		if err := resources[0].Resource.UnmarshalTo(ec); err != nil {
+			t.Fatal(err)
+		}
+		w := &wasm.Wasm{}
+		if err := ec.TypedConfig.UnmarshalTo(w); err != nil {
+			t.Fatal(err)
+		}
+		return w.GetConfig().GetVmConfig().GetEnvironmentVariables().GetKeyValues()[model.WasmResourceVersionEnv]
+	}
+
+	canary := map[string]string{"app": "gateway", "version": "canary"}
+	stable := map[string]string{"app": "gateway", "version": "stable"}
+	original := resourceVersion(canary)
+
+	if _, err := s.Discovery.RefreshWasmModule("default", "default-plugin", "version in (", ""); err == nil {
+		t.Fatal("expected an invalid selector to be rejected")
+	}
+	if _, err := s.Discovery.RefreshWasmModule("default", "default-plugin", "version=canary", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resourceVersion(canary), original+"-refresh-1"; got != want {
+		t.Errorf("got resource version %q for the selected proxy, want %q", got, want)
+	}
+	if got := resourceVersion(stable); got != original {
+		t.Errorf("got resource version %q for the unselected proxy, want %q", got, original)
+	}
+
+	// The latest refresh selecting a proxy applies.
+	if _, err := s.Discovery.RefreshWasmModule("default", "default-plugin", "app=gateway", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := resourceVersion(canary), original+"-refresh-2"; got != want {
+		t.Errorf("got resource version %q for the selected proxy, want %q", got, want)
+	}
+	if got, want := resourceVersion(stable), original+"-refresh-2"; got != want {
+		t.Errorf("got resource version %q for the selected proxy, want %q", got, want)
+	}
+}
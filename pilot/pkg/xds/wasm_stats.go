@@ -0,0 +1,275 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/sets"
+)
+
+const (
+	// maxWasmStatsReportSize bounds the size of the stats reported at once by a proxy.
+	maxWasmStatsReportSize = 1024 * 1024
+	// ecdsStatPrefix prefixes the stats of the extension configs, as
+	// extension_config_discovery.<stat prefix>.<extension config name>.<stat>.
+	ecdsStatPrefix = "extension_config_discovery."
+	// wasmRuntimeStatPrefix prefixes the stats of the Wasm runtimes, shared by the plugins of a proxy.
+	wasmRuntimeStatPrefix = "wasm.envoy.wasm.runtime."
+)
+
+// WasmPluginHealth summarizes the Wasm stats of a WasmPlugin across the proxies running it.
+type WasmPluginHealth struct {
+	// Plugin is the resource name of the WasmPlugin, as namespace.name.
+	Plugin string `json:"plugin"`
+	// Proxies is the number of proxies running the plugin, either connected to this Pilot or reporting its stats.
+	Proxies int `json:"proxies"`
+	// Reporting is the number of them whose stats were reported within the retention.
+	Reporting   int     `json:"reporting"`
+	ActiveVMs   float64 `json:"activeVMs"`
+	MemoryBytes float64 `json:"memoryBytes"`
+	// CPUTime is the thread time spent in the plugin, if exposed by its stats.
+	CPUTime  float64 `json:"cpuTime,omitempty"`
+	Failures float64 `json:"failures"`
+	// FailingProxies are the proxies whose failures of the plugin increased since their previous report.
+	FailingProxies []string `json:"failingProxies,omitempty"`
+	// ProxyStats are the stats of the plugin on each reporting proxy, only listed for a single plugin.
+	ProxyStats []WasmProxyStats `json:"proxyStats,omitempty"`
+}
+
+// WasmProxyStats are the Wasm stats of a plugin on a proxy.
+type WasmProxyStats struct {
+	Proxy       string    `json:"proxy"`
+	Reported    time.Time `json:"reported"`
+	ActiveVMs   float64   `json:"activeVMs"`
+	MemoryBytes float64   `json:"memoryBytes"`
+	CPUTime     float64   `json:"cpuTime,omitempty"`
+	Failures    float64   `json:"failures"`
+	// RuntimeActiveVMs is the number of VMs of the Wasm runtimes of the proxy, shared by all its plugins.
+	RuntimeActiveVMs float64 `json:"runtimeActiveVMs"`
+	// Stats are the stats of the plugin.
+	Stats map[string]float64 `json:"stats"`
+}
+
+type wasmStatsReport struct {
+	stats    map[string]float64
+	previous map[string]float64
+	reported time.Time
+}
+
+// wasmStatsAggregator keeps the last Wasm stats reported by the agents of the proxies, to summarize the health of
+// the WasmPlugins fleet wide. The stats of a proxy are forgotten once it has not reported them for the retention.
+type wasmStatsAggregator struct {
+	retention time.Duration
+
+	mu      sync.RWMutex
+	reports map[string]*wasmStatsReport
+}
+
+func newWasmStatsAggregator(retention time.Duration) *wasmStatsAggregator {
+	return &wasmStatsAggregator{
+		retention: retention,
+		reports:   map[string]*wasmStatsReport{},
+	}
+}
+
+// record replaces the stats of a proxy, keeping the previous ones to tell the failures since.
+func (a *wasmStatsAggregator) record(proxyID string, stats map[string]float64, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := &wasmStatsReport{stats: stats, reported: now}
+	if prev := a.reports[proxyID]; prev != nil {
+		r.previous = prev.stats
+	}
+	a.reports[proxyID] = r
+}
+
+// expire forgets the stats older than the retention.
+func (a *wasmStatsAggregator) expire(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for proxyID, r := range a.reports {
+		if now.Sub(r.reported) > a.retention {
+			delete(a.reports, proxyID)
+		}
+	}
+}
+
+// summary returns the health of the plugins, optionally of a single one with the stats of each proxy. subscribed
+// holds the plugins the connected proxies subscribe to, by proxy; the plugins of the reporting proxies are also
+// found from the stats of their extension configs, as they may be connected to another Pilot.
+func (a *wasmStatsAggregator) summary(subscribed map[string]sets.String, plugin string, now time.Time) []WasmPluginHealth {
+	a.expire(now)
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	proxiesByPlugin := map[string]sets.String{}
+	addPlugins := func(proxyID string, plugins sets.String) {
+		for p := range plugins {
+			if plugin != "" && p != plugin {
+				continue
+			}
+			if proxiesByPlugin[p] == nil {
+				proxiesByPlugin[p] = sets.New[string]()
+			}
+			proxiesByPlugin[p].Insert(proxyID)
+		}
+	}
+	for proxyID, plugins := range subscribed {
+		addPlugins(proxyID, plugins)
+	}
+	for proxyID, r := range a.reports {
+		addPlugins(proxyID, ecdsStatPlugins(r.stats))
+	}
+
+	out := make([]WasmPluginHealth, 0, len(proxiesByPlugin))
+	for p, proxies := range proxiesByPlugin {
+		health := WasmPluginHealth{Plugin: p, Proxies: proxies.Len()}
+		for _, proxyID := range sets.SortedList(proxies) {
+			r := a.reports[proxyID]
+			if r == nil {
+				continue
+			}
+			health.Reporting++
+			stats := pluginWasmStats(p, r.stats)
+			stats.Proxy = proxyID
+			stats.Reported = r.reported
+			health.ActiveVMs += stats.ActiveVMs
+			health.MemoryBytes += stats.MemoryBytes
+			health.CPUTime += stats.CPUTime
+			health.Failures += stats.Failures
+			if r.previous != nil && stats.Failures > pluginWasmStats(p, r.previous).Failures {
+				health.FailingProxies = append(health.FailingProxies, proxyID)
+			}
+			if plugin != "" {
+				health.ProxyStats = append(health.ProxyStats, stats)
+			}
+		}
+		out = append(out, health)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Plugin < out[j].Plugin
+	})
+	return out
+}
+
+// ecdsStatPlugins returns the names of the extension configs found in the stats.
+func ecdsStatPlugins(stats map[string]float64) sets.String {
+	plugins := sets.New[string]()
+	for name := range stats {
+		rest, ok := strings.CutPrefix(name, ecdsStatPrefix)
+		if !ok {
+			continue
+		}
+		// Cut the stat prefix, such as http_filter, and the stat, such as config_reload.
+		_, rest, _ = strings.Cut(rest, ".")
+		if i := strings.LastIndexByte(rest, '.'); i > 0 {
+			plugins.Insert(rest[:i])
+		}
+	}
+	return plugins
+}
+
+// pluginWasmStats classifies the stats of the plugin, those naming it as a dot separated part of their name.
+func pluginWasmStats(plugin string, stats map[string]float64) WasmProxyStats {
+	out := WasmProxyStats{Stats: map[string]float64{}}
+	for name, value := range stats {
+		if strings.HasPrefix(name, wasmRuntimeStatPrefix) && strings.HasSuffix(name, ".active") {
+			out.RuntimeActiveVMs += value
+			continue
+		}
+		if !strings.Contains("."+name+".", "."+plugin+".") {
+			continue
+		}
+		out.Stats[name] = value
+		switch lower := strings.ToLower(name); {
+		case containsAnySubstring(lower, "fail", "error", "crash", "panic", "abort"):
+			out.Failures += value
+		case strings.HasSuffix(lower, ".active") || strings.Contains(lower, "active_vm"):
+			out.ActiveVMs += value
+		case containsAnySubstring(lower, "memory", "heap"):
+			out.MemoryBytes += value
+		case containsAnySubstring(lower, "cpu", "thread_time"):
+			out.CPUTime += value
+		}
+	}
+	return out
+}
+
+func containsAnySubstring(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// wasmPluginSubscriptions returns the extension configs subscribed to by the connected proxies, by proxy.
+func (s *DiscoveryServer) wasmPluginSubscriptions() map[string]sets.String {
+	out := map[string]sets.String{}
+	for _, con := range s.AllClients() {
+		proxy := con.proxy
+		proxy.RLock()
+		watched := proxy.WatchedResources[v3.ExtensionConfigurationType]
+		if watched != nil {
+			out[proxy.ID] = sets.New(subscribedResources(watched)...)
+		}
+		proxy.RUnlock()
+	}
+	return out
+}
+
+// wasmStatsz summarizes the health of the WasmPlugins across the proxies, from the Wasm stats reported by their
+// agents, optionally of a single plugin=namespace.name with the stats of each proxy. The agents POST the stats of
+// their proxy as a JSON object of the values by stat name, with their proxyID.
+// It is mapped to /debug/wasm_statsz
+func (s *DiscoveryServer) wasmStatsz(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		s.reportWasmStats(w, req)
+		return
+	}
+	writeJSON(w, s.wasmStats.summary(s.wasmPluginSubscriptions(), req.URL.Query().Get("plugin"), time.Now()), req)
+}
+
+func (s *DiscoveryServer) reportWasmStats(w http.ResponseWriter, req *http.Request) {
+	proxyID := req.URL.Query().Get("proxyID")
+	if proxyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("proxyID is required\n"))
+		return
+	}
+	b, err := io.ReadAll(io.LimitReader(req.Body, maxWasmStatsReportSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("failed to read the Wasm stats: %v\n", err)))
+		return
+	}
+	stats := map[string]float64{}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("invalid Wasm stats: %v\n", err)))
+		return
+	}
+	s.wasmStats.record(proxyID, stats, time.Now())
+	_, _ = w.Write([]byte(fmt.Sprintf("Recorded %d Wasm stats\n", len(stats))))
+}
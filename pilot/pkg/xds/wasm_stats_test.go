@@ -0,0 +1,111 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/util/sets"
+)
+
+func TestWasmStatsAggregator(t *testing.T) {
+	a := newWasmStatsAggregator(time.Minute)
+	now := time.Now()
+	stats := func(failures float64) map[string]float64 {
+		return map[string]float64{
+			"extension_config_discovery.http_filter.default.auth.config_reload": 1,
+			"extension_config_discovery.http_filter.default.auth.config_fail":   failures,
+			"wasm.envoy.wasm.runtime.v8.active":                                 2,
+			"wasm.envoy.wasm.runtime.v8.created":                                2,
+			"wasmcustom.default.auth.memory_bytes":                              1024,
+			"wasmcustom.default.auth.cpu_thread_time_us":                        30,
+			"wasmcustom.default.authz.memory_bytes":                             4096,
+		}
+	}
+	a.record("gateway-1", stats(0), now)
+	a.record("gateway-2", stats(1), now)
+	a.record("gateway-2", stats(3), now)
+	subscribed := map[string]sets.String{
+		"gateway-1": sets.New("default.auth"),
+		"gateway-3": sets.New("default.auth", "default.cache"),
+	}
+
+	got := a.summary(subscribed, "", now)
+	if len(got) != 2 || got[0].Plugin != "default.auth" || got[1].Plugin != "default.cache" {
+		t.Fatalf("unexpected plugins %+v", got)
+	}
+	auth := got[0]
+	if auth.Proxies != 3 || auth.Reporting != 2 || auth.MemoryBytes != 2048 || auth.CPUTime != 60 || auth.Failures != 3 {
+		t.Errorf("unexpected health %+v", auth)
+	}
+	if !reflect.DeepEqual(auth.FailingProxies, []string{"gateway-2"}) {
+		t.Errorf("expected gateway-2 to be failing, got %v", auth.FailingProxies)
+	}
+	if auth.ProxyStats != nil {
+		t.Errorf("expected no stats by proxy in the summary of all the plugins, got %+v", auth.ProxyStats)
+	}
+	if cache := got[1]; cache.Proxies != 1 || cache.Reporting != 0 {
+		t.Errorf("unexpected health %+v", cache)
+	}
+
+	got = a.summary(subscribed, "default.auth", now)
+	if len(got) != 1 || len(got[0].ProxyStats) != 2 {
+		t.Fatalf("expected the stats of the plugin by proxy, got %+v", got)
+	}
+	if ps := got[0].ProxyStats[0]; ps.Proxy != "gateway-1" || ps.RuntimeActiveVMs != 2 || len(ps.Stats) != 4 {
+		t.Errorf("unexpected stats %+v", ps)
+	}
+
+	if got := a.summary(nil, "", now.Add(2*time.Minute)); len(got) != 0 {
+		t.Errorf("expected the stats to expire, got %+v", got)
+	}
+}
+
+func TestWasmStatsz(t *testing.T) {
+	s := &DiscoveryServer{wasmStats: newWasmStatsAggregator(time.Minute), adsClients: map[string]*Connection{}}
+	stats := `{"extension_config_discovery.http_filter.default.auth.config_fail": 2}`
+	w := httptest.NewRecorder()
+	s.wasmStatsz(w, httptest.NewRequest(http.MethodPost, "/debug/wasm_statsz?proxyID=gateway-1", strings.NewReader(stats)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Recorded 1") {
+		t.Fatalf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.wasmStatsz(w, httptest.NewRequest(http.MethodGet, "/debug/wasm_statsz", nil))
+	var got []WasmPluginHealth
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Plugin != "default.auth" || got[0].Failures != 2 {
+		t.Errorf("unexpected health %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	s.wasmStatsz(w, httptest.NewRequest(http.MethodPost, "/debug/wasm_statsz", strings.NewReader(stats)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a report without proxyID to be rejected, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.wasmStatsz(w, httptest.NewRequest(http.MethodPost, "/debug/wasm_statsz?proxyID=gateway-1", strings.NewReader("[")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected invalid stats to be rejected, got %d", w.Code)
+	}
+}
@@ -144,6 +144,10 @@ func (s *DiscoveryServer) pushXds(con *Connection, w *model.WatchedResource, req
 	}
 	defer func() { recordPushTime(w.TypeUrl, time.Since(t0)) }()
 
+	// Added by Ingress
+	res = s.quarantineResources(con, w.TypeUrl, res)
+	// End added by Ingress
+
 	resp := &discovery.DiscoveryResponse{
 		ControlPlane: ControlPlane(),
 		TypeUrl:      w.TypeUrl,
@@ -168,6 +172,15 @@ func (s *DiscoveryServer) pushXds(con *Connection, w *model.WatchedResource, req
 		}
 		return err
 	}
+	// Added by Ingress
+	if w.TypeUrl == v3.SecretType {
+		con.recordSecretsSent(resp.Nonce, res, false)
+	}
+	s.recordSent(con, w.TypeUrl, resp.Nonce, res)
+	s.cacheSentResponse(con, resp, !req.Full || logdata.Incremental || len(logFiltered) > 0)
+	con.setPendingResources(w.TypeUrl, resp.Nonce, res)
+	s.checkPushSize(con, w.TypeUrl, res, configSize, logdata.Incremental || len(logFiltered) > 0)
+	// End added by Ingress
 
 	switch {
 	case !req.Full:
@@ -193,7 +206,10 @@ func ResourceSize(r model.Resources) int {
 	// proto.Size, at the expense of slightly under counting.
 	size := 0
 	for _, r := range r {
-		size += len(r.Resource.Value)
+		// Modified by ingress
+		// The virtual hosts not found by VHDS are sent without a resource.
+		size += len(r.GetResource().GetValue())
+		// End modified by ingress
 	}
 	return size
 }
@@ -220,6 +220,11 @@ type ADSC struct {
 
 	sync     map[string]time.Time
 	Locality *core.Locality
+
+	// Added by ingress
+	// connected is true while responses are received on the stream.
+	connected bool
+	// End added by ingress
 }
 
 type ResponseHandler interface {
@@ -473,6 +478,26 @@ func (a *ADSC) HasSynced() bool {
 	return true
 }
 
+// Added by ingress
+
+// RunWithRetry runs the client as Run, but retries with the backoff policy until the stream is created rather than
+// failing if the server is not available.
+func (a *ADSC) RunWithRetry() {
+	if err := a.Run(); err != nil {
+		adscLog.Warnf("failed to connect to %s, retrying: %v", a.url, err)
+		time.AfterFunc(a.cfg.BackoffPolicy.NextBackOff(), a.reconnect)
+	}
+}
+
+// Connected returns true while the client receives responses from the server.
+func (a *ADSC) Connected() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.connected && !a.closed
+}
+
+// End added by ingress
+
 // reconnect will create a new stream
 func (a *ADSC) reconnect() {
 	a.mutex.RLock()
@@ -497,6 +522,11 @@ func (a *ADSC) handleRecv() {
 	for {
 		var err error
 		msg, err := a.stream.Recv()
+		// Added by ingress
+		a.mutex.Lock()
+		a.connected = err == nil
+		a.mutex.Unlock()
+		// End added by ingress
 		if err != nil {
 			a.RecvWg.Done()
 			adscLog.Infof("Connection closed for node %v with err: %v", a.nodeID, err)
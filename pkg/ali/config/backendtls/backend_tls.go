@@ -0,0 +1,56 @@
+package backendtls
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Spec is the TLS policy of the gateways connecting to the ports of a service, modeled on the BackendTLSPolicy of the
+// Gateway API. The gateways originate TLS to the Hostname and verify the certificate of the backend against the CA
+// certificate of the CredentialName secret, served by SDS as its -cacert resource.
+type Spec struct {
+	// CredentialName is the name of the secret holding the CA certificate, in the namespace of the gateways.
+	CredentialName string `json:"credentialName"`
+	// Hostname is the SNI sent to the backend and the subject alt name verified in its certificate.
+	Hostname string `json:"hostname"`
+	// Ports are the names of the ports of the service the policy applies to, all of them if empty.
+	Ports []string `json:"ports,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/backend-tls annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid backend tls policy: %v", err)
+	}
+	if spec.CredentialName == "" {
+		return nil, fmt.Errorf("invalid backend tls policy: credentialName is required")
+	}
+	if errs := validation.IsDNS1123Subdomain(spec.Hostname); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid backend tls policy: invalid hostname %q: %s", spec.Hostname, strings.Join(errs, ", "))
+	}
+	for _, port := range spec.Ports {
+		if port == "" {
+			return nil, fmt.Errorf("invalid backend tls policy: empty port name")
+		}
+	}
+	return spec, nil
+}
+
+// AppliesTo returns true if the policy applies to the port of the service.
+func (s *Spec) AppliesTo(portName string) bool {
+	if len(s.Ports) == 0 {
+		return true
+	}
+	for _, port := range s.Ports {
+		if port == portName {
+			return true
+		}
+	}
+	return false
+}
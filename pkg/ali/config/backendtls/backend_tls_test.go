@@ -0,0 +1,77 @@
+package backendtls
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    *Spec
+		wantErr bool
+	}{
+		{
+			name:  "all ports",
+			value: `{"credentialName": "backend-ca", "hostname": "backend.example.com"}`,
+			want:  &Spec{CredentialName: "backend-ca", Hostname: "backend.example.com"},
+		},
+		{
+			name:  "some ports",
+			value: `{"credentialName": "backend-ca", "hostname": "backend.example.com", "ports": ["https"]}`,
+			want:  &Spec{CredentialName: "backend-ca", Hostname: "backend.example.com", Ports: []string{"https"}},
+		},
+		{
+			name:    "not json",
+			value:   "backend-ca",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"credentialName": "backend-ca", "hostname": "backend.example.com", "sni": "backend.example.com"}`,
+			wantErr: true,
+		},
+		{
+			name:    "no credential",
+			value:   `{"hostname": "backend.example.com"}`,
+			wantErr: true,
+		},
+		{
+			name:    "no hostname",
+			value:   `{"credentialName": "backend-ca"}`,
+			wantErr: true,
+		},
+		{
+			name:    "wildcard hostname",
+			value:   `{"credentialName": "backend-ca", "hostname": "*.example.com"}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty port",
+			value:   `{"credentialName": "backend-ca", "hostname": "backend.example.com", "ports": [""]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	if !(&Spec{}).AppliesTo("http") {
+		t.Error("a policy without ports should apply to all of them")
+	}
+	spec := &Spec{Ports: []string{"https"}}
+	if !spec.AppliesTo("https") || spec.AppliesTo("http") {
+		t.Errorf("got wrong ports for %v", spec.Ports)
+	}
+}
@@ -0,0 +1,181 @@
+package bodytransformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+// placeholderRegex matches the placeholders of the templates of the values added to the bodies, such as
+// ${header.x-user-id}.
+var placeholderRegex = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// Transformation transforms the JSON bodies of the requests or responses. The fields are dot separated paths, such
+// as user.id, and are removed, then renamed, then added.
+type Transformation struct {
+	// Add sets the fields to the values of the templates, overwriting them. A template is a JSON value whose strings
+	// may hold the placeholders ${header.<name>}, ${query.<name>}, ${path}, ${method} or ${host} of the request.
+	Add map[string]json.RawMessage `json:"add,omitempty"`
+	// Remove removes the fields.
+	Remove []string `json:"remove,omitempty"`
+	// Rename renames the fields, keyed by their current path.
+	Rename map[string]string `json:"rename,omitempty"`
+}
+
+// Rule transforms the bodies of the requests and responses of a route.
+type Rule struct {
+	Request  *Transformation `json:"request,omitempty"`
+	Response *Transformation `json:"response,omitempty"`
+}
+
+func (r *Rule) empty() bool {
+	return r.Request == nil && r.Response == nil
+}
+
+// Spec is the body transformation of the routes of a virtual service.
+type Spec struct {
+	// Rule is the body transformation of all the routes of the virtual service.
+	Rule
+	// Routes are the body transformations of some routes, keyed by the name of the HTTP route, or of the HTTP route
+	// and its match as <route>.<match>. They override Rule.
+	Routes map[string]*Rule `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/body-transformation annotation, as JSON such as
+// {"request": {"add": {"user.id": "${header.x-user-id}"}, "remove": ["debug"]}, "routes": {"legacy": {...}}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid body transformation: %v", err)
+	}
+	if spec.Rule.empty() && len(spec.Routes) == 0 {
+		return nil, fmt.Errorf("invalid body transformation: a request or response transformation or routes are required")
+	}
+	if err := validateRule(&spec.Rule); err != nil {
+		return nil, fmt.Errorf("invalid body transformation: %v", err)
+	}
+	for name, rule := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid body transformation: route name may not be empty")
+		}
+		if rule == nil || rule.empty() {
+			return nil, fmt.Errorf("invalid body transformation of route %s: a request or response transformation is required", name)
+		}
+		if err := validateRule(rule); err != nil {
+			return nil, fmt.Errorf("invalid body transformation of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+func validateRule(r *Rule) error {
+	if err := validateTransformation(r.Request); err != nil {
+		return fmt.Errorf("invalid request transformation: %v", err)
+	}
+	if err := validateTransformation(r.Response); err != nil {
+		return fmt.Errorf("invalid response transformation: %v", err)
+	}
+	return nil
+}
+
+func validateTransformation(t *Transformation) error {
+	if t == nil {
+		return nil
+	}
+	if len(t.Add) == 0 && len(t.Remove) == 0 && len(t.Rename) == 0 {
+		return fmt.Errorf("one of add, remove or rename is required")
+	}
+	for _, field := range t.Remove {
+		if err := validateField(field); err != nil {
+			return err
+		}
+	}
+	for from, to := range t.Rename {
+		if err := validateField(from); err != nil {
+			return err
+		}
+		if err := validateField(to); err != nil {
+			return err
+		}
+	}
+	for field, template := range t.Add {
+		if err := validateField(field); err != nil {
+			return err
+		}
+		var value any
+		if err := json.Unmarshal(template, &value); err != nil {
+			return fmt.Errorf("invalid template of field %s: %v", field, err)
+		}
+		if err := validateTemplate(value); err != nil {
+			return fmt.Errorf("invalid template of field %s: %v", field, err)
+		}
+	}
+	return nil
+}
+
+func validateField(field string) error {
+	if field == "" {
+		return fmt.Errorf("field may not be empty")
+	}
+	for _, part := range strings.Split(field, ".") {
+		if part == "" {
+			return fmt.Errorf("invalid field %q, expected a dot separated path such as user.id", field)
+		}
+	}
+	return nil
+}
+
+// validateTemplate checks the placeholders of the strings of the template.
+func validateTemplate(value any) error {
+	switch v := value.(type) {
+	case string:
+		for _, m := range placeholderRegex.FindAllStringSubmatch(v, -1) {
+			if err := validatePlaceholder(m[1]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := validateTemplate(item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for _, item := range v {
+			if err := validateTemplate(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validatePlaceholder(placeholder string) error {
+	switch placeholder {
+	case "path", "method", "host":
+		return nil
+	}
+	source, name, ok := strings.Cut(placeholder, ".")
+	if !ok || name == "" || (source != "header" && source != "query") {
+		return fmt.Errorf("unknown placeholder ${%s}, expected ${header.<name>}, ${query.<name>}, ${path}, "+
+			"${method} or ${host}", placeholder)
+	}
+	return nil
+}
+
+// RouteRule returns the body transformation of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, else of the virtual service. It returns nil if none is set.
+func (s *Spec) RouteRule(name string) *Rule {
+	if rule, ok := routes.Lookup(s.Routes, name); ok {
+		return rule
+	}
+	if !s.Rule.empty() {
+		return &s.Rule
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package bodytransformation
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "request and response",
+			value: `{"request": {"add": {"user.id": "${header.x-user-id}"}, "remove": ["debug"]}, "response": {"rename": {"data": "result"}}}`,
+		},
+		{name: "routes", value: `{"routes": {"legacy": {"request": {"add": {"meta": {"path": "${path}", "tags": ["${query.tag}"]}}}}}}`},
+		{name: "not json", value: "add", wantErr: true},
+		{name: "empty", value: `{}`, wantErr: true},
+		{name: "unknown field", value: `{"request": {"set": {"a": 1}}}`, wantErr: true},
+		{name: "empty transformation", value: `{"request": {}}`, wantErr: true},
+		{name: "invalid field", value: `{"request": {"remove": ["user..id"]}}`, wantErr: true},
+		{name: "invalid rename", value: `{"response": {"rename": {"data": ""}}}`, wantErr: true},
+		{name: "unknown placeholder", value: `{"request": {"add": {"id": "${cookie.id}"}}}`, wantErr: true},
+		{name: "nested unknown placeholder", value: `{"request": {"add": {"meta": {"tags": ["${header}"]}}}}`, wantErr: true},
+		{name: "empty route rule", value: `{"routes": {"legacy": {}}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {"request": {"remove": ["a"]}}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteRule(t *testing.T) {
+	spec, err := Parse(`{"request": {"remove": ["debug"]}, "routes": {"api": {"response": {"remove": ["internal"]}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RouteRule("api.v1"); got == nil || got.Response == nil || got.Request != nil {
+		t.Errorf("got rule %+v of route api.v1, want the one of api", got)
+	}
+	if got := spec.RouteRule("catalog"); got == nil || got.Request == nil {
+		t.Errorf("got rule %+v of route catalog, want the default one", got)
+	}
+
+	spec, _ = Parse(`{"routes": {"api": {"request": {"remove": ["debug"]}}}}`)
+	if got := spec.RouteRule("catalog"); got != nil {
+		t.Errorf("got rule %+v for a route without one and no default", got)
+	}
+}
@@ -0,0 +1,146 @@
+package canary
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+const (
+	// Always routes the requests with the value to the canary destination.
+	Always = "always"
+	// Never routes the requests with the value to the stable destinations.
+	Never = "never"
+	// DefaultStickyCookie is the name of the cookie pinning the destination of a session by default.
+	DefaultStickyCookie = "higress-canary"
+)
+
+// cookieNameRegex matches the token characters of RFC 6265 cookie names.
+var cookieNameRegex = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
+// Policy is the canary release of a weighted route, whose last destination is the canary and the others the stable
+// ones. The requests forced to a destination by a header, query parameter or cookie are routed first, then those
+// pinned by the sticky cookie, the others being split by the weights of the destinations.
+type Policy struct {
+	// Header forces the destination of the requests with the header set to always or never.
+	Header string `json:"header,omitempty"`
+	// QueryParameter forces the destination of the requests with the query parameter set to always or never.
+	QueryParameter string `json:"queryParameter,omitempty"`
+	// Cookie forces the destination of the requests with the cookie set to always or never.
+	Cookie string `json:"cookie,omitempty"`
+	// HashHeader splits the requests by the weights of the destinations consistently on the value of the header, a
+	// number such as a user id, rather than randomly. The requests without it are split randomly.
+	HashHeader string `json:"hashHeader,omitempty"`
+	// Sticky pins the destination picked for a session with a generated cookie.
+	Sticky *Sticky `json:"sticky,omitempty"`
+}
+
+// Sticky is the cookie pinning the destination of a session, set to canary or stable on the responses.
+type Sticky struct {
+	// Cookie is the name of the cookie, higress-canary by default.
+	Cookie string `json:"cookie,omitempty"`
+	// TTL is the lifetime of the cookie, renewed on each response, such as 24h. The cookie lasts for the session of
+	// the browser without it.
+	TTL string `json:"ttl,omitempty"`
+
+	ttl time.Duration
+}
+
+// CookieName returns the name of the sticky cookie.
+func (s *Sticky) CookieName() string {
+	if s.Cookie == "" {
+		return DefaultStickyCookie
+	}
+	return s.Cookie
+}
+
+// MaxAge returns the lifetime of the sticky cookie, or 0 if it lasts for the session of the browser.
+func (s *Sticky) MaxAge() time.Duration {
+	return s.ttl
+}
+
+func (p *Policy) empty() bool {
+	return p.Header == "" && p.QueryParameter == "" && p.Cookie == "" && p.HashHeader == "" && p.Sticky == nil
+}
+
+// Spec is the canary release of the weighted routes of a virtual service.
+type Spec struct {
+	// Policy is the canary release of all the weighted routes of the virtual service.
+	Policy
+	// Routes are the canary releases of some routes, keyed by the name of the HTTP route, or of the HTTP route and
+	// its match as <route>.<match>. They override Policy.
+	Routes map[string]*Policy `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/canary annotation, as JSON such as
+// {"header": "x-canary", "sticky": {"ttl": "24h"}, "routes": {"checkout": {"hashHeader": "x-user-id"}}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid canary: %v", err)
+	}
+	if spec.Policy.empty() && len(spec.Routes) == 0 {
+		return nil, fmt.Errorf("invalid canary: a policy or routes are required")
+	}
+	if err := validatePolicy(&spec.Policy); err != nil {
+		return nil, fmt.Errorf("invalid canary: %v", err)
+	}
+	for name, policy := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid canary: route name may not be empty")
+		}
+		if policy == nil || policy.empty() {
+			return nil, fmt.Errorf("invalid canary of route %s: the policy may not be empty", name)
+		}
+		if err := validatePolicy(policy); err != nil {
+			return nil, fmt.Errorf("invalid canary of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+func validatePolicy(p *Policy) error {
+	p.Header = strings.ToLower(p.Header)
+	p.HashHeader = strings.ToLower(p.HashHeader)
+	if strings.ContainsAny(p.Header+p.HashHeader, " :") {
+		return fmt.Errorf("invalid header name")
+	}
+	if p.Cookie != "" && !cookieNameRegex.MatchString(p.Cookie) {
+		return fmt.Errorf("invalid cookie name %q", p.Cookie)
+	}
+	if p.Sticky == nil {
+		return nil
+	}
+	if p.Sticky.Cookie != "" && !cookieNameRegex.MatchString(p.Sticky.Cookie) {
+		return fmt.Errorf("invalid sticky cookie name %q", p.Sticky.Cookie)
+	}
+	if p.Sticky.Cookie != "" && p.Sticky.Cookie == p.Cookie {
+		return fmt.Errorf("the sticky cookie may not be the cookie forcing the destination")
+	}
+	if p.Sticky.TTL != "" {
+		ttl, err := time.ParseDuration(p.Sticky.TTL)
+		if err != nil || ttl < time.Second {
+			return fmt.Errorf("invalid sticky cookie ttl %q, expected a duration of at least 1s such as 24h", p.Sticky.TTL)
+		}
+		p.Sticky.ttl = ttl
+	}
+	return nil
+}
+
+// RoutePolicy returns the canary release of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, else of the virtual service. It returns nil if none is set.
+func (s *Spec) RoutePolicy(name string) *Policy {
+	if policy, ok := routes.Lookup(s.Routes, name); ok {
+		return policy
+	}
+	if !s.Policy.empty() {
+		return &s.Policy
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package canary
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "policy", value: `{"header": "X-Canary", "sticky": {"ttl": "24h"}}`},
+		{name: "routes", value: `{"routes": {"checkout": {"hashHeader": "x-user-id"}}}`},
+		{name: "not json", value: "x-canary", wantErr: true},
+		{name: "empty", value: `{}`, wantErr: true},
+		{name: "unknown field", value: `{"headers": "x-canary"}`, wantErr: true},
+		{name: "invalid header", value: `{"header": "x canary"}`, wantErr: true},
+		{name: "invalid cookie", value: `{"cookie": "canary;"}`, wantErr: true},
+		{name: "invalid ttl", value: `{"sticky": {"ttl": "1d"}}`, wantErr: true},
+		{name: "ttl too short", value: `{"sticky": {"ttl": "10ms"}}`, wantErr: true},
+		{name: "same cookies", value: `{"cookie": "canary", "sticky": {"cookie": "canary"}}`, wantErr: true},
+		{name: "empty route policy", value: `{"routes": {"checkout": {}}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {"header": "x-canary"}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	spec, err := Parse(`{"header": "X-Canary", "sticky": {"ttl": "1h"}, ` +
+		`"routes": {"api": {"cookie": "canary"}, "api.v2": {"hashHeader": "x-user-id"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RoutePolicy("api.v1"); got == nil || got.Cookie != "canary" {
+		t.Errorf("got policy %+v of route api.v1, want the one of api", got)
+	}
+	if got := spec.RoutePolicy("api.v2"); got == nil || got.HashHeader != "x-user-id" {
+		t.Errorf("got policy %+v of route api.v2, want its own", got)
+	}
+	got := spec.RoutePolicy("catalog")
+	if got == nil || got.Header != "x-canary" {
+		t.Fatalf("got policy %+v of route catalog, want the default one", got)
+	}
+	if got.Sticky.CookieName() != DefaultStickyCookie || got.Sticky.MaxAge() != time.Hour {
+		t.Errorf("got sticky cookie %s with max age %v, want %s with 1h", got.Sticky.CookieName(), got.Sticky.MaxAge(),
+			DefaultStickyCookie)
+	}
+
+	spec, _ = Parse(`{"routes": {"api": {"header": "x-canary"}}}`)
+	if got := spec.RoutePolicy("catalog"); got != nil {
+		t.Errorf("got policy %+v for a route without one and no default", got)
+	}
+}
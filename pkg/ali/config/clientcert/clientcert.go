@@ -0,0 +1,124 @@
+package clientcert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+
+	"istio.io/istio/pkg/slices"
+)
+
+// How the gateway handles the x-forwarded-client-cert header, as the forward_client_cert_details of Envoy.
+const (
+	Sanitize          = "sanitize"
+	ForwardOnly       = "forward_only"
+	AppendForward     = "append_forward"
+	SanitizeSet       = "sanitize_set"
+	AlwaysForwardOnly = "always_forward_only"
+)
+
+// Details of the client certificate set in the x-forwarded-client-cert header, as the
+// set_current_client_cert_details of Envoy. Its hash is always set.
+const (
+	DetailSubject = "subject"
+	DetailCert    = "cert"
+	DetailChain   = "chain"
+	DetailDNS     = "dns"
+	DetailURI     = "uri"
+)
+
+// Attributes of the client certificate copied to the request headers.
+const (
+	AttributeHash    = "hash"
+	AttributeSubject = "subject"
+	AttributeIssuer  = "issuer"
+	AttributeSerial  = "serial"
+	AttributeURISAN  = "uri_san"
+	AttributeDNSSAN  = "dns_san"
+	AttributeCert    = "cert"
+)
+
+// xfccHeader is the header forwarding the client certificates, set by the forward mode only.
+const xfccHeader = "x-forwarded-client-cert"
+
+var (
+	forwardModes = []string{Sanitize, ForwardOnly, AppendForward, SanitizeSet, AlwaysForwardOnly}
+	details      = []string{DetailSubject, DetailCert, DetailChain, DetailDNS, DetailURI}
+	attributes   = []string{
+		AttributeHash, AttributeSubject, AttributeIssuer, AttributeSerial, AttributeURISAN, AttributeDNSSAN, AttributeCert,
+	}
+)
+
+// Spec is how the gateway passes the client certificates of the mTLS connections of its servers to the upstreams.
+type Spec struct {
+	// Forward is how the x-forwarded-client-cert header is handled, the one of the gateway topology of the proxy if
+	// empty.
+	Forward string `json:"forward,omitempty"`
+	// Details are the details of the client certificate set in the x-forwarded-client-cert header by the
+	// append_forward and sanitize_set modes, all but the chain if empty.
+	Details []string `json:"details,omitempty"`
+	// Headers are the attributes of the client certificate copied to the request headers, keyed by header name, such
+	// as {"x-client-cert-hash": "hash"}. The headers are removed from the requests of the clients first, and are not
+	// set on the connections without a client certificate.
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/client-cert annotation, as JSON such as
+// {"forward": "sanitize_set", "details": ["uri", "dns"], "headers": {"x-client-uri": "uri_san"}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid client cert: %v", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid client cert: %v", err)
+	}
+	return spec, nil
+}
+
+func (s *Spec) validate() error {
+	if s.Forward != "" && !slices.Contains(forwardModes, s.Forward) {
+		return fmt.Errorf("invalid forward mode %q, must be one of %s", s.Forward, strings.Join(forwardModes, ", "))
+	}
+	if len(s.Details) > 0 && s.Forward != AppendForward && s.Forward != SanitizeSet {
+		return fmt.Errorf("details only apply to the %s and %s forward modes", AppendForward, SanitizeSet)
+	}
+	for _, detail := range s.Details {
+		if !slices.Contains(details, detail) {
+			return fmt.Errorf("invalid detail %q, must be one of %s", detail, strings.Join(details, ", "))
+		}
+	}
+	headers := make(map[string]string, len(s.Headers))
+	for header, attribute := range s.Headers {
+		if !httpguts.ValidHeaderFieldName(header) {
+			return fmt.Errorf("invalid header %q", header)
+		}
+		// The headers are normalized to lower case, as HTTP/2 requires.
+		header = strings.ToLower(header)
+		if header == "host" || header == xfccHeader {
+			return fmt.Errorf("header %q may not be set", header)
+		}
+		if _, ok := headers[header]; ok {
+			return fmt.Errorf("duplicate header %q", header)
+		}
+		if !slices.Contains(attributes, attribute) {
+			return fmt.Errorf("invalid attribute %q of header %q, must be one of %s", attribute, header,
+				strings.Join(attributes, ", "))
+		}
+		headers[header] = attribute
+	}
+	s.Headers = headers
+	return nil
+}
+
+// HasDetail returns true if the detail of the client certificate is set in the x-forwarded-client-cert header.
+func (s *Spec) HasDetail(detail string) bool {
+	if len(s.Details) == 0 {
+		return detail != DetailChain
+	}
+	return slices.Contains(s.Details, detail)
+}
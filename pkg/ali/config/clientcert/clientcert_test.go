@@ -0,0 +1,55 @@
+package clientcert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name        string
+		value       string
+		wantHeaders map[string]string
+		wantErr     bool
+	}{
+		{name: "forward", value: `{"forward": "sanitize"}`, wantHeaders: map[string]string{}},
+		{name: "details", value: `{"forward": "sanitize_set", "details": ["uri", "dns"]}`, wantHeaders: map[string]string{}},
+		{
+			name:        "headers",
+			value:       `{"headers": {"X-Client-Cert-Hash": "hash", "x-client-uri": "uri_san"}}`,
+			wantHeaders: map[string]string{"x-client-cert-hash": "hash", "x-client-uri": "uri_san"},
+		},
+		{name: "not json", value: "sanitize", wantErr: true},
+		{name: "unknown field", value: `{"mode": "sanitize"}`, wantErr: true},
+		{name: "invalid forward", value: `{"forward": "SANITIZE"}`, wantErr: true},
+		{name: "details not set", value: `{"forward": "forward_only", "details": ["uri"]}`, wantErr: true},
+		{name: "details of default forward", value: `{"details": ["uri"]}`, wantErr: true},
+		{name: "invalid detail", value: `{"forward": "sanitize_set", "details": ["hash"]}`, wantErr: true},
+		{name: "invalid header", value: `{"headers": {"x client": "hash"}}`, wantErr: true},
+		{name: "xfcc header", value: `{"headers": {"X-Forwarded-Client-Cert": "cert"}}`, wantErr: true},
+		{name: "duplicate header", value: `{"headers": {"x-client": "hash", "X-Client": "subject"}}`, wantErr: true},
+		{name: "invalid attribute", value: `{"headers": {"x-client": "san"}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(spec.Headers, tt.wantHeaders) {
+				t.Errorf("got headers %v, want %v", spec.Headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestHasDetail(t *testing.T) {
+	spec := &Spec{Forward: SanitizeSet}
+	if !spec.HasDetail(DetailURI) || spec.HasDetail(DetailChain) {
+		t.Errorf("got default details %v, want all but the chain", spec.Details)
+	}
+	spec.Details = []string{DetailChain}
+	if spec.HasDetail(DetailURI) || !spec.HasDetail(DetailChain) {
+		t.Errorf("got details %v, want the chain only", spec.Details)
+	}
+}
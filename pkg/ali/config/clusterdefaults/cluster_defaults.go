@@ -0,0 +1,78 @@
+package clusterdefaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// Spec is the default resilience settings of the outbound clusters, applied under the traffic policy of their
+// DestinationRules.
+type Spec struct {
+	// ConnectionPool is merged field by field under the connection pool of the DestinationRules.
+	ConnectionPool *networking.ConnectionPoolSettings
+	// RetryBudget limits the concurrent retries of the clusters, unless their DestinationRules have their own.
+	RetryBudget *RetryBudget
+}
+
+// RetryBudget limits the concurrent retries of a cluster to a percentage of its active requests, instead of the
+// fixed max retries of its circuit breaker.
+type RetryBudget struct {
+	// BudgetPercent is the percentage of the active requests that may be retried, 20 if unset.
+	BudgetPercent float64 `json:"budgetPercent,omitempty"`
+	// MinRetryConcurrency is the number of concurrent retries always allowed, 3 if unset.
+	MinRetryConcurrency uint32 `json:"minRetryConcurrency,omitempty"`
+}
+
+type rawSpec struct {
+	ConnectionPool json.RawMessage `json:"connectionPool,omitempty"`
+	RetryBudget    *RetryBudget    `json:"retryBudget,omitempty"`
+}
+
+// Parse parses and validates the cluster defaults, as JSON such as
+// {"connectionPool": {"tcp": {"maxConnections": 1024}}, "retryBudget": {"budgetPercent": 20}}.
+func Parse(value string) (*Spec, error) {
+	raw := &rawSpec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(raw); err != nil {
+		return nil, fmt.Errorf("invalid cluster defaults: %v", err)
+	}
+	spec := &Spec{RetryBudget: raw.RetryBudget}
+	if len(raw.ConnectionPool) > 0 {
+		spec.ConnectionPool = &networking.ConnectionPoolSettings{}
+		if err := protomarshal.Unmarshal(raw.ConnectionPool, spec.ConnectionPool); err != nil {
+			return nil, fmt.Errorf("invalid cluster defaults: invalid connectionPool: %v", err)
+		}
+	}
+	if spec.RetryBudget != nil {
+		if err := spec.RetryBudget.validate(); err != nil {
+			return nil, fmt.Errorf("invalid cluster defaults: %v", err)
+		}
+	}
+	return spec, nil
+}
+
+// ParseRetryBudget parses and validates the value of a higress.io/retry-budget annotation.
+func ParseRetryBudget(value string) (*RetryBudget, error) {
+	budget := &RetryBudget{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(budget); err != nil {
+		return nil, fmt.Errorf("invalid retry budget: %v", err)
+	}
+	if err := budget.validate(); err != nil {
+		return nil, fmt.Errorf("invalid retry budget: %v", err)
+	}
+	return budget, nil
+}
+
+func (b *RetryBudget) validate() error {
+	if b.BudgetPercent < 0 || b.BudgetPercent > 100 {
+		return fmt.Errorf("budgetPercent %v must be between 0 and 100", b.BudgetPercent)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package clusterdefaults
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name               string
+		value              string
+		wantMaxConnections int32
+		wantBudget         *RetryBudget
+		wantErr            bool
+	}{
+		{
+			name:               "connection pool and retry budget",
+			value:              `{"connectionPool": {"tcp": {"maxConnections": 1024}}, "retryBudget": {"budgetPercent": 25}}`,
+			wantMaxConnections: 1024,
+			wantBudget:         &RetryBudget{BudgetPercent: 25},
+		},
+		{
+			name:       "retry budget only",
+			value:      `{"retryBudget": {"minRetryConcurrency": 5}}`,
+			wantBudget: &RetryBudget{MinRetryConcurrency: 5},
+		},
+		{
+			name:    "not json",
+			value:   "1024",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"connectionPool": {}, "outlierDetection": {}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown connection pool field",
+			value:   `{"connectionPool": {"tcp": {"maxConnection": 1024}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "budget above 100 percent",
+			value:   `{"retryBudget": {"budgetPercent": 120}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := got.ConnectionPool.GetTcp().GetMaxConnections(); got != tt.wantMaxConnections {
+				t.Errorf("got max connections %d, want %d", got, tt.wantMaxConnections)
+			}
+			if !reflect.DeepEqual(got.RetryBudget, tt.wantBudget) {
+				t.Errorf("got retry budget %+v, want %+v", got.RetryBudget, tt.wantBudget)
+			}
+			if tt.wantMaxConnections == 0 && got.ConnectionPool != nil {
+				t.Errorf("got connection pool %v, want none", got.ConnectionPool)
+			}
+		})
+	}
+}
+
+func TestParseRetryBudget(t *testing.T) {
+	got, err := ParseRetryBudget(`{"budgetPercent": 10.5, "minRetryConcurrency": 1}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&RetryBudget{BudgetPercent: 10.5, MinRetryConcurrency: 1}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	for _, value := range []string{`{"budgetPercent": -1}`, `{"percent": 10}`, "10"} {
+		if _, err := ParseRetryBudget(value); err == nil {
+			t.Errorf("expected %s to be rejected", value)
+		}
+	}
+}
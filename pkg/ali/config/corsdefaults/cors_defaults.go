@@ -0,0 +1,94 @@
+package corsdefaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+const (
+	// MergeFields merges the default policy under the CORS policy of the routes, field by field, so that the fields
+	// set by the routes take precedence.
+	MergeFields = "fields"
+	// MergeReplace replaces the default policy with the CORS policy of the routes having one.
+	MergeReplace = "replace"
+)
+
+// Spec is the default CORS policy of the gateway routes.
+type Spec struct {
+	// Policy is the CORS policy of the routes without one, and merged under the one of the others.
+	Policy *networking.CorsPolicy
+	// Merge is how the policy of the routes overrides the default one, fields or replace.
+	Merge string
+}
+
+type rawSpec struct {
+	Policy json.RawMessage `json:"policy,omitempty"`
+	Merge  string          `json:"merge,omitempty"`
+}
+
+// Parse parses and validates the CORS defaults, as JSON such as
+// {"policy": {"allowOrigins": [{"exact": "https://example.com"}], "allowMethods": ["GET"]}, "merge": "fields"}.
+func Parse(value string) (*Spec, error) {
+	raw := &rawSpec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(raw); err != nil {
+		return nil, fmt.Errorf("invalid cors defaults: %v", err)
+	}
+	if len(raw.Policy) == 0 {
+		return nil, fmt.Errorf("invalid cors defaults: the policy is required")
+	}
+	spec := &Spec{Policy: &networking.CorsPolicy{}, Merge: strings.ToLower(raw.Merge)}
+	if err := protomarshal.Unmarshal(raw.Policy, spec.Policy); err != nil {
+		return nil, fmt.Errorf("invalid cors defaults: invalid policy: %v", err)
+	}
+	if len(spec.Policy.AllowOrigins) == 0 && len(spec.Policy.AllowOrigin) == 0 {
+		return nil, fmt.Errorf("invalid cors defaults: the policy allows no origin")
+	}
+	switch spec.Merge {
+	case "":
+		spec.Merge = MergeFields
+	case MergeFields, MergeReplace:
+	default:
+		return nil, fmt.Errorf("invalid cors defaults: invalid merge %q, must be fields or replace", raw.Merge)
+	}
+	return spec, nil
+}
+
+// Apply returns the CORS policy of a route with the defaults: the default policy if the route has none, else the
+// policy of the route, with the fields it does not set taken from the default one when merged field by field.
+func (s *Spec) Apply(policy *networking.CorsPolicy) *networking.CorsPolicy {
+	if policy == nil {
+		return s.Policy
+	}
+	if s.Merge == MergeReplace {
+		return policy
+	}
+	out := s.Policy.DeepCopy()
+	// The origins are replaced together, as the deprecated allowOrigin only applies without allowOrigins.
+	// nolint: staticcheck
+	if len(policy.AllowOrigins) > 0 || len(policy.AllowOrigin) > 0 {
+		out.AllowOrigins = policy.AllowOrigins
+		out.AllowOrigin = policy.AllowOrigin
+	}
+	if len(policy.AllowMethods) > 0 {
+		out.AllowMethods = policy.AllowMethods
+	}
+	if len(policy.AllowHeaders) > 0 {
+		out.AllowHeaders = policy.AllowHeaders
+	}
+	if len(policy.ExposeHeaders) > 0 {
+		out.ExposeHeaders = policy.ExposeHeaders
+	}
+	if policy.MaxAge != nil {
+		out.MaxAge = policy.MaxAge
+	}
+	if policy.AllowCredentials != nil {
+		out.AllowCredentials = policy.AllowCredentials
+	}
+	return out
+}
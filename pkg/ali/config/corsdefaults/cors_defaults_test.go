@@ -0,0 +1,80 @@
+package corsdefaults
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "fields", value: `{"policy": {"allowOrigins": [{"exact": "https://example.com"}], "allowMethods": ["GET"]}}`},
+		{name: "replace", value: `{"policy": {"allowOrigins": [{"prefix": "https://"}]}, "merge": "Replace"}`},
+		{name: "not json", value: "*", wantErr: true},
+		{name: "unknown field", value: `{"cors": {}}`, wantErr: true},
+		{name: "no policy", value: `{"merge": "fields"}`, wantErr: true},
+		{name: "unknown policy field", value: `{"policy": {"allowOrigins": [{"exact": "*"}], "allowMethod": ["GET"]}}`, wantErr: true},
+		{name: "no origin", value: `{"policy": {"allowMethods": ["GET"]}}`, wantErr: true},
+		{name: "invalid merge", value: `{"policy": {"allowOrigins": [{"exact": "*"}]}, "merge": "append"}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	defaults := &networking.CorsPolicy{
+		AllowOrigins: []*networking.StringMatch{{MatchType: &networking.StringMatch_Exact{Exact: "https://example.com"}}},
+		AllowMethods: []string{"GET", "POST"},
+		MaxAge:       durationpb.New(time.Hour),
+	}
+	route := &networking.CorsPolicy{
+		AllowMethods:     []string{"PUT"},
+		AllowCredentials: wrapperspb.Bool(true),
+	}
+	cases := []struct {
+		name   string
+		merge  string
+		policy *networking.CorsPolicy
+		want   *networking.CorsPolicy
+	}{
+		{name: "no route policy", merge: MergeFields, want: defaults},
+		{
+			name:   "fields",
+			merge:  MergeFields,
+			policy: route,
+			want: &networking.CorsPolicy{
+				AllowOrigins:     defaults.AllowOrigins,
+				AllowMethods:     []string{"PUT"},
+				MaxAge:           durationpb.New(time.Hour),
+				AllowCredentials: wrapperspb.Bool(true),
+			},
+		},
+		{name: "replace", merge: MergeReplace, policy: route, want: route},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &Spec{Policy: defaults, Merge: tt.merge}
+			if got := spec.Apply(tt.policy); !proto.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(defaults.AllowMethods) != 2 {
+		t.Errorf("the defaults were modified: %v", defaults)
+	}
+}
@@ -0,0 +1,89 @@
+package dnsresolver
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// DoT is the resolver resolving through the DNS-over-TLS forwarder of the agents, which forwards the queries to the
+// DNS-over-TLS resolvers set by their DNS_OVER_TLS_RESOLVERS.
+const DoT = "dot"
+
+// DoTForwarderPort is the port of the DNS-over-TLS forwarder of the agents, listening on localhost.
+const DoTForwarderPort = 15054
+
+const defaultPort = 53
+
+// Address is the address of a resolver.
+type Address struct {
+	IP   string
+	Port uint32
+}
+
+// Spec are the DNS resolvers of the DNS clusters, instead of the resolvers of the system of the proxies.
+type Spec struct {
+	// Resolvers are the addresses of the resolvers, tried in turn.
+	Resolvers []Address
+	// TCP queries the resolvers over TCP rather than UDP.
+	TCP bool
+}
+
+// Parse parses and validates a comma separated list of resolvers, such as the value of a higress.io/dns-resolvers
+// annotation. A resolver is an IP address with an optional port, 53 by default, prefixed by udp:// or tcp:// for the
+// transport, UDP by default, or "dot" to resolve with DNS-over-TLS through the forwarder of the agent. The resolvers
+// share their transport, so any TCP or DNS-over-TLS resolver makes all of them queried over TCP.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	for _, resolver := range strings.Split(value, ",") {
+		resolver = strings.TrimSpace(resolver)
+		if resolver == "" {
+			continue
+		}
+		if resolver == DoT {
+			spec.Resolvers = append(spec.Resolvers, Address{IP: "127.0.0.1", Port: DoTForwarderPort})
+			spec.TCP = true
+			continue
+		}
+		address := resolver
+		switch {
+		case strings.HasPrefix(resolver, "tcp://"):
+			address = strings.TrimPrefix(resolver, "tcp://")
+			spec.TCP = true
+		case strings.HasPrefix(resolver, "udp://"):
+			address = strings.TrimPrefix(resolver, "udp://")
+		case strings.Contains(resolver, "://"):
+			return nil, fmt.Errorf("invalid dns resolver %q: unknown scheme, must be udp or tcp", resolver)
+		}
+		a, err := parseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dns resolver %q: %v", resolver, err)
+		}
+		spec.Resolvers = append(spec.Resolvers, a)
+	}
+	if len(spec.Resolvers) == 0 {
+		return nil, fmt.Errorf("invalid dns resolvers: no resolver")
+	}
+	return spec, nil
+}
+
+func parseAddress(address string) (Address, error) {
+	if ip, err := netip.ParseAddr(address); err == nil {
+		return Address{IP: ip.String(), Port: defaultPort}, nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return Address{}, err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return Address{}, fmt.Errorf("%q is not an IP address", host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || p == 0 {
+		return Address{}, fmt.Errorf("invalid port %q", port)
+	}
+	return Address{IP: ip.String(), Port: uint32(p)}, nil
+}
@@ -0,0 +1,57 @@
+package dnsresolver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		want  *Spec
+		err   bool
+	}{
+		{
+			name:  "udp",
+			value: "10.0.0.10, udp://10.0.0.11:5353",
+			want:  &Spec{Resolvers: []Address{{IP: "10.0.0.10", Port: 53}, {IP: "10.0.0.11", Port: 5353}}},
+		},
+		{
+			name:  "tcp",
+			value: "tcp://[fd00::10]:53",
+			want:  &Spec{Resolvers: []Address{{IP: "fd00::10", Port: 53}}, TCP: true},
+		},
+		{
+			name:  "ipv6 without port",
+			value: "fd00::10",
+			want:  &Spec{Resolvers: []Address{{IP: "fd00::10", Port: 53}}},
+		},
+		{
+			name:  "dot",
+			value: "dot,10.0.0.10",
+			want:  &Spec{Resolvers: []Address{{IP: "127.0.0.1", Port: DoTForwarderPort}, {IP: "10.0.0.10", Port: 53}}, TCP: true},
+		},
+		{name: "empty", value: " , ", err: true},
+		{name: "hostname", value: "dns.internal:53", err: true},
+		{name: "scheme", value: "https://10.0.0.10", err: true},
+		{name: "port", value: "10.0.0.10:0", err: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package extproc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+var (
+	headerModes = map[string]bool{"default": true, "send": true, "skip": true}
+	bodyModes   = map[string]bool{"none": true, "streamed": true, "buffered": true, "buffered_partial": true}
+)
+
+// ProcessingMode selects the parts of the requests and responses sent to the external processor. The header and
+// trailer modes are default, send or skip, and the body modes none, streamed, buffered or buffered_partial. The
+// unset parts are processed with the default mode, sending the headers but not the bodies and trailers.
+type ProcessingMode struct {
+	RequestHeaders   string `json:"requestHeaders,omitempty"`
+	ResponseHeaders  string `json:"responseHeaders,omitempty"`
+	RequestBody      string `json:"requestBody,omitempty"`
+	ResponseBody     string `json:"responseBody,omitempty"`
+	RequestTrailers  string `json:"requestTrailers,omitempty"`
+	ResponseTrailers string `json:"responseTrailers,omitempty"`
+}
+
+// ParseProcessingMode parses and validates a processing mode, as JSON such as
+// {"requestHeaders": "send", "responseHeaders": "skip", "requestBody": "buffered"}.
+func ParseProcessingMode(value string) (*ProcessingMode, error) {
+	mode := &ProcessingMode{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(mode); err != nil {
+		return nil, fmt.Errorf("invalid processing mode: %v", err)
+	}
+	if err := mode.validate(); err != nil {
+		return nil, fmt.Errorf("invalid processing mode: %v", err)
+	}
+	return mode, nil
+}
+
+func (m *ProcessingMode) validate() error {
+	for name, value := range map[string]*string{
+		"requestHeaders":   &m.RequestHeaders,
+		"responseHeaders":  &m.ResponseHeaders,
+		"requestTrailers":  &m.RequestTrailers,
+		"responseTrailers": &m.ResponseTrailers,
+	} {
+		*value = strings.ToLower(*value)
+		if *value != "" && !headerModes[*value] {
+			return fmt.Errorf("invalid %s mode %q, must be default, send or skip", name, *value)
+		}
+	}
+	for name, value := range map[string]*string{
+		"requestBody":  &m.RequestBody,
+		"responseBody": &m.ResponseBody,
+	} {
+		*value = strings.ToLower(*value)
+		if *value != "" && !bodyModes[*value] {
+			return fmt.Errorf("invalid %s mode %q, must be none, streamed, buffered or buffered_partial", name, *value)
+		}
+	}
+	return nil
+}
+
+// Merge returns the processing mode with the parts set by the override replaced.
+func (m ProcessingMode) Merge(override *ProcessingMode) ProcessingMode {
+	if override == nil {
+		return m
+	}
+	for _, part := range []struct{ to, from *string }{
+		{&m.RequestHeaders, &override.RequestHeaders},
+		{&m.ResponseHeaders, &override.ResponseHeaders},
+		{&m.RequestBody, &override.RequestBody},
+		{&m.ResponseBody, &override.ResponseBody},
+		{&m.RequestTrailers, &override.RequestTrailers},
+		{&m.ResponseTrailers, &override.ResponseTrailers},
+	} {
+		if *part.from != "" {
+			*part.to = *part.from
+		}
+	}
+	return m
+}
+
+// Policy enables the external processing of a route.
+type Policy struct {
+	// Disabled disables the external processing of the route, such as a route of a virtual service whose other
+	// routes are processed.
+	Disabled bool `json:"disabled,omitempty"`
+	// ProcessingMode overrides the parts of the processing mode of the mesh it sets on the route.
+	ProcessingMode *ProcessingMode `json:"processingMode,omitempty"`
+}
+
+// Spec is the external processing of the routes of a virtual service. The routes are processed if the virtual
+// service or their route enables it.
+type Spec struct {
+	// Policy is the external processing of all the routes of the virtual service.
+	Policy
+	// Routes are the external processing of some routes, keyed by the name of the HTTP route, or of the HTTP route and
+	// its match as <route>.<match>. They override Policy.
+	Routes map[string]*Policy `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/ext-proc annotation, as JSON such as
+// {"processingMode": {"requestBody": "buffered"}, "routes": {"healthz": {"disabled": true}}}. An empty object
+// processes all the routes with the processing mode of the mesh.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid ext proc: %v", err)
+	}
+	if spec.ProcessingMode != nil {
+		if err := spec.ProcessingMode.validate(); err != nil {
+			return nil, fmt.Errorf("invalid ext proc: invalid processing mode: %v", err)
+		}
+	}
+	for name, policy := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid ext proc: route name may not be empty")
+		}
+		if policy == nil {
+			return nil, fmt.Errorf("invalid ext proc of route %s: the policy may not be null", name)
+		}
+		if policy.Disabled && policy.ProcessingMode != nil {
+			return nil, fmt.Errorf("invalid ext proc of route %s: a disabled route may not have a processing mode", name)
+		}
+		if policy.ProcessingMode != nil {
+			if err := policy.ProcessingMode.validate(); err != nil {
+				return nil, fmt.Errorf("invalid ext proc of route %s: invalid processing mode: %v", name, err)
+			}
+		}
+	}
+	return spec, nil
+}
+
+// RoutePolicy returns the external processing of the named Envoy route: the one of the route itself, else of its
+// HTTP route, the longest name prefixing it, else of the virtual service.
+func (s *Spec) RoutePolicy(name string) *Policy {
+	if policy, ok := routes.Lookup(s.Routes, name); ok {
+		return policy
+	}
+	return &s.Policy
+}
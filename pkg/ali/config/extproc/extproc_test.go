@@ -0,0 +1,83 @@
+package extproc
+
+import (
+	"testing"
+)
+
+func TestParseProcessingMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "modes", value: `{"requestHeaders": "SEND", "responseHeaders": "skip", "requestBody": "buffered_partial"}`},
+		{name: "empty", value: `{}`},
+		{name: "not json", value: "send", wantErr: true},
+		{name: "unknown field", value: `{"requestHeader": "send"}`, wantErr: true},
+		{name: "invalid header mode", value: `{"requestHeaders": "buffered"}`, wantErr: true},
+		{name: "invalid body mode", value: `{"responseBody": "send"}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseProcessingMode(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "all routes", value: `{}`},
+		{name: "routes", value: `{"processingMode": {"requestBody": "buffered"}, "routes": {"healthz": {"disabled": true}}}`},
+		{name: "not json", value: "true", wantErr: true},
+		{name: "unknown field", value: `{"mode": {}}`, wantErr: true},
+		{name: "invalid mode", value: `{"processingMode": {"requestBody": "all"}}`, wantErr: true},
+		{name: "invalid route mode", value: `{"routes": {"api": {"processingMode": {"requestHeaders": "none"}}}}`, wantErr: true},
+		{name: "disabled route with mode", value: `{"routes": {"api": {"disabled": true, "processingMode": {}}}}`, wantErr: true},
+		{name: "null route policy", value: `{"routes": {"api": null}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	spec, err := Parse(`{"processingMode": {"requestBody": "buffered"}, ` +
+		`"routes": {"healthz": {"disabled": true}, "upload": {"processingMode": {"requestBody": "streamed"}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RoutePolicy("healthz"); !got.Disabled {
+		t.Errorf("got policy %+v of route healthz, want disabled", got)
+	}
+	if got := spec.RoutePolicy("upload.large"); got.ProcessingMode.RequestBody != "streamed" {
+		t.Errorf("got policy %+v of route upload.large, want the one of upload", got)
+	}
+	if got := spec.RoutePolicy("catalog"); got.Disabled || got.ProcessingMode.RequestBody != "buffered" {
+		t.Errorf("got policy %+v of route catalog, want the default one", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	mode := ProcessingMode{RequestHeaders: "send", RequestBody: "buffered"}
+	got := mode.Merge(&ProcessingMode{RequestBody: "streamed", ResponseHeaders: "skip"})
+	want := ProcessingMode{RequestHeaders: "send", RequestBody: "streamed", ResponseHeaders: "skip"}
+	if got != want {
+		t.Errorf("got merged mode %+v, want %+v", got, want)
+	}
+	if got := mode.Merge(nil); got != mode {
+		t.Errorf("got merged mode %+v without override, want %+v", got, mode)
+	}
+}
@@ -0,0 +1,139 @@
+package gatewaypatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// MaxRequestHeadersKb is the largest limit of request headers accepted by Envoy.
+const MaxRequestHeadersKb = 8192
+
+// Spec is a typed patch of the listeners and routes generated for the servers of a gateway, a structured
+// alternative to an EnvoyFilter for the common gateway tweaks. Unset fields keep the generated configuration.
+type Spec struct {
+	Timeouts        *Timeouts `json:"timeouts,omitempty"`
+	Buffers         *Buffers  `json:"buffers,omitempty"`
+	Headers         *Headers  `json:"headers,omitempty"`
+	AccessLogFormat string    `json:"accessLogFormat,omitempty"`
+}
+
+// Timeouts of the HTTP connection manager of the gateway servers, as Go durations such as "30s".
+type Timeouts struct {
+	Idle           string `json:"idle,omitempty"`
+	Request        string `json:"request,omitempty"`
+	RequestHeaders string `json:"requestHeaders,omitempty"`
+	StreamIdle     string `json:"streamIdle,omitempty"`
+}
+
+// Buffers limits the buffers of the gateway listeners.
+type Buffers struct {
+	PerConnectionLimitBytes uint32 `json:"perConnectionLimitBytes,omitempty"`
+	MaxRequestHeadersKb     uint32 `json:"maxRequestHeadersKb,omitempty"`
+}
+
+// Headers manipulates the request and response headers of the routes of the gateway.
+type Headers struct {
+	Request  *HeaderOperations `json:"request,omitempty"`
+	Response *HeaderOperations `json:"response,omitempty"`
+}
+
+// HeaderOperations overwrites the Set headers, appends the Add headers and removes the Remove headers.
+type HeaderOperations struct {
+	Set    map[string]string `json:"set,omitempty"`
+	Add    map[string]string `json:"add,omitempty"`
+	Remove []string          `json:"remove,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/gateway-patch annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	// Reject the misspelled fields, which would otherwise be silently ignored.
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid gateway patch: %v", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid gateway patch: %v", err)
+	}
+	return spec, nil
+}
+
+// Duration returns the parsed duration, or zero if it is not set. The duration is validated by Parse.
+func Duration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+func (s *Spec) validate() error {
+	if t := s.Timeouts; t != nil {
+		for name, value := range map[string]string{
+			"idle":           t.Idle,
+			"request":        t.Request,
+			"requestHeaders": t.RequestHeaders,
+			"streamIdle":     t.StreamIdle,
+		} {
+			if value == "" {
+				continue
+			}
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s timeout %q: %v", name, value, err)
+			}
+			if d < 0 {
+				return fmt.Errorf("invalid %s timeout %q: must not be negative", name, value)
+			}
+		}
+	}
+	if b := s.Buffers; b != nil && b.MaxRequestHeadersKb > MaxRequestHeadersKb {
+		return fmt.Errorf("maxRequestHeadersKb %d exceeds %d", b.MaxRequestHeadersKb, MaxRequestHeadersKb)
+	}
+	if h := s.Headers; h != nil {
+		if err := h.Request.validate(); err != nil {
+			return fmt.Errorf("invalid request headers: %v", err)
+		}
+		if err := h.Response.validate(); err != nil {
+			return fmt.Errorf("invalid response headers: %v", err)
+		}
+	}
+	if strings.Count(s.AccessLogFormat, "%")%2 != 0 {
+		return fmt.Errorf("invalid access log format %q: unbalanced %%", s.AccessLogFormat)
+	}
+	return nil
+}
+
+func (o *HeaderOperations) validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, headers := range []map[string]string{o.Set, o.Add} {
+		for name, value := range headers {
+			if err := validateHeaderName(name); err != nil {
+				return err
+			}
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return fmt.Errorf("invalid value %q of header %q", value, name)
+			}
+		}
+	}
+	for _, name := range o.Remove {
+		if err := validateHeaderName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateHeaderName(name string) error {
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("invalid header %q", name)
+	}
+	if strings.EqualFold(name, "host") {
+		return fmt.Errorf("header %q can not be modified", name)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package gatewaypatch
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    *Spec
+		wantErr bool
+	}{
+		{
+			name: "all fields",
+			value: `{"timeouts": {"idle": "1h", "request": "30s", "requestHeaders": "10s", "streamIdle": "5m"},
+"buffers": {"perConnectionLimitBytes": 65536, "maxRequestHeadersKb": 96},
+"headers": {"request": {"set": {"x-gateway": "higress"}, "remove": ["x-internal"]}, "response": {"add": {"x-served-by": "higress"}}},
+"accessLogFormat": "%START_TIME% %RESPONSE_CODE%\n"}`,
+			want: &Spec{
+				Timeouts: &Timeouts{Idle: "1h", Request: "30s", RequestHeaders: "10s", StreamIdle: "5m"},
+				Buffers:  &Buffers{PerConnectionLimitBytes: 65536, MaxRequestHeadersKb: 96},
+				Headers: &Headers{
+					Request:  &HeaderOperations{Set: map[string]string{"x-gateway": "higress"}, Remove: []string{"x-internal"}},
+					Response: &HeaderOperations{Add: map[string]string{"x-served-by": "higress"}},
+				},
+				AccessLogFormat: "%START_TIME% %RESPONSE_CODE%\n",
+			},
+		},
+		{
+			name:  "empty",
+			value: `{}`,
+			want:  &Spec{},
+		},
+		{
+			name:    "not json",
+			value:   "timeouts",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"timeout": {"idle": "1h"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown duration",
+			value:   `{"timeouts": {"idle": "1 hour"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			value:   `{"timeouts": {"request": "-1s"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "too large headers",
+			value:   `{"buffers": {"maxRequestHeadersKb": 8193}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid header",
+			value:   `{"headers": {"request": {"set": {"x gateway": "higress"}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid header value",
+			value:   `{"headers": {"response": {"add": {"x-gateway": "a\nb"}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "pseudo header",
+			value:   `{"headers": {"request": {"remove": [":path"]}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "host header",
+			value:   `{"headers": {"request": {"set": {"Host": "example.com"}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced access log format",
+			value:   `{"accessLogFormat": "%START_TIME"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	if got := Duration("1m30s"); got != 90*time.Second {
+		t.Errorf("got %v, want 1m30s", got)
+	}
+	if got := Duration(""); got != 0 {
+		t.Errorf("got %v, want 0", got)
+	}
+}
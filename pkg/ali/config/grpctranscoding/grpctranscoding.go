@@ -0,0 +1,157 @@
+package grpctranscoding
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+const (
+	// ConfigMapScheme prefixes the descriptor sets stored in a key of a ConfigMap of the namespace of the virtual
+	// service, as configmap://<name>/<key>.
+	ConfigMapScheme = "configmap://"
+	// OCIScheme prefixes the descriptor sets stored as the single layer of an OCI artifact, as
+	// oci://<registry>/<repository>:<tag>.
+	OCIScheme = "oci://"
+)
+
+// Transcoding transcodes the JSON requests of a route to the methods of gRPC services, and their responses back.
+type Transcoding struct {
+	// Disabled disables the transcoding of the route, such as a route of a virtual service whose other routes are
+	// transcoded.
+	Disabled bool `json:"disabled,omitempty"`
+	// Descriptor is the source of the serialized FileDescriptorSet of the services, built by protoc with
+	// --include_imports: configmap://<name>/<key>, oci://<reference> or an http(s):// URL.
+	Descriptor string `json:"descriptor,omitempty"`
+	// Services are the fully qualified names of the gRPC services transcoded, such as helloworld.Greeter.
+	Services []string `json:"services,omitempty"`
+	// AutoMapping maps the methods without google.api.http option to POST /<package>.<service>/<method>.
+	AutoMapping bool `json:"autoMapping,omitempty"`
+	// ConvertGrpcStatus converts the gRPC status of the responses to a JSON body.
+	ConvertGrpcStatus bool `json:"convertGrpcStatus,omitempty"`
+	// IgnoreUnknownQueryParameters ignores the query parameters not mapped to a field of the request message,
+	// instead of rejecting the request.
+	IgnoreUnknownQueryParameters bool `json:"ignoreUnknownQueryParameters,omitempty"`
+	// IgnoredQueryParameters are the query parameters not mapped to the request message, such as api_key.
+	IgnoredQueryParameters []string `json:"ignoredQueryParameters,omitempty"`
+}
+
+// Merge returns the transcoding with the descriptor and services unset by the override inherited from t.
+func (t Transcoding) Merge(override *Transcoding) Transcoding {
+	if override == nil {
+		return t
+	}
+	out := *override
+	if out.Descriptor == "" {
+		out.Descriptor = t.Descriptor
+	}
+	if len(out.Services) == 0 {
+		out.Services = t.Services
+	}
+	return out
+}
+
+// Enabled returns true if the transcoding applies to the routes, having a descriptor set.
+func (t *Transcoding) Enabled() bool {
+	return !t.Disabled && t.Descriptor != ""
+}
+
+func (t *Transcoding) validate() error {
+	if t.Descriptor != "" {
+		if err := validateSource(t.Descriptor); err != nil {
+			return err
+		}
+	}
+	for _, service := range t.Services {
+		if service == "" || strings.ContainsAny(service, "/ ") {
+			return fmt.Errorf("invalid service %q, must be a fully qualified name such as helloworld.Greeter", service)
+		}
+	}
+	if t.Enabled() && len(t.Services) == 0 {
+		return fmt.Errorf("the services transcoded are required with a descriptor")
+	}
+	return nil
+}
+
+// Spec is the gRPC-JSON transcoding of the routes of a virtual service.
+type Spec struct {
+	// Transcoding is the transcoding of all the routes of the virtual service, if it has a descriptor.
+	Transcoding
+	// Routes are the transcoding of some routes, keyed by the name of the HTTP route, or of the HTTP route and its
+	// match as <route>.<match>. They override Transcoding, inheriting its descriptor and services if unset.
+	Routes map[string]*Transcoding `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/grpc-json-transcoding annotation, as JSON such as
+// {"descriptor": "configmap://greeter-descriptor/greeter.pb", "services": ["helloworld.Greeter"],
+// "routes": {"healthz": {"disabled": true}}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid grpc json transcoding: %v", err)
+	}
+	if err := spec.Transcoding.validate(); err != nil {
+		return nil, fmt.Errorf("invalid grpc json transcoding: %v", err)
+	}
+	for name, transcoding := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid grpc json transcoding: route name may not be empty")
+		}
+		if transcoding == nil {
+			return nil, fmt.Errorf("invalid grpc json transcoding of route %s: the transcoding may not be null", name)
+		}
+		merged := spec.Transcoding.Merge(transcoding)
+		if err := merged.validate(); err != nil {
+			return nil, fmt.Errorf("invalid grpc json transcoding of route %s: %v", name, err)
+		}
+		if !merged.Disabled && merged.Descriptor == "" {
+			return nil, fmt.Errorf("invalid grpc json transcoding of route %s: a descriptor is required", name)
+		}
+	}
+	return spec, nil
+}
+
+// RouteTranscoding returns the transcoding of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, merged with the one of the virtual service, else the one of the virtual
+// service.
+func (s *Spec) RouteTranscoding(name string) Transcoding {
+	if transcoding, ok := routes.Lookup(s.Routes, name); ok {
+		return s.Transcoding.Merge(transcoding)
+	}
+	return s.Transcoding
+}
+
+func validateSource(source string) error {
+	switch {
+	case strings.HasPrefix(source, ConfigMapScheme):
+		name, key, ok := strings.Cut(strings.TrimPrefix(source, ConfigMapScheme), "/")
+		if !ok || name == "" || key == "" || strings.Contains(key, "/") {
+			return fmt.Errorf("invalid descriptor %q, expected configmap://<name>/<key>", source)
+		}
+	case strings.HasPrefix(source, OCIScheme):
+		if strings.TrimPrefix(source, OCIScheme) == "" {
+			return fmt.Errorf("invalid descriptor %q, expected oci://<registry>/<repository>:<tag>", source)
+		}
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		if u, err := url.Parse(source); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid descriptor URL %q", source)
+		}
+	default:
+		return fmt.Errorf("invalid descriptor %q, must be a configmap://, oci:// or http(s):// source", source)
+	}
+	return nil
+}
+
+// ResolveSource returns the source of a descriptor set of a virtual service of the namespace, the ConfigMaps being
+// qualified by the namespace as configmap://<namespace>/<name>/<key>.
+func ResolveSource(source, namespace string) string {
+	if rest, ok := strings.CutPrefix(source, ConfigMapScheme); ok {
+		return ConfigMapScheme + namespace + "/" + rest
+	}
+	return source
+}
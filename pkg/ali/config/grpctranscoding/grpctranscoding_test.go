@@ -0,0 +1,81 @@
+package grpctranscoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "configmap", value: `{"descriptor": "configmap://greeter/greeter.pb", "services": ["helloworld.Greeter"]}`},
+		{name: "oci", value: `{"descriptor": "oci://registry.io/protos/greeter:v1", "services": ["helloworld.Greeter"], "autoMapping": true}`},
+		{name: "url", value: `{"descriptor": "https://example.com/greeter.pb", "services": ["helloworld.Greeter"]}`},
+		{name: "routes only", value: `{"routes": {"greeter": {"descriptor": "configmap://greeter/greeter.pb", "services": ["helloworld.Greeter"]}}}`},
+		{name: "inherited by routes", value: `{"descriptor": "configmap://greeter/greeter.pb", "services": ["helloworld.Greeter"], ` +
+			`"routes": {"healthz": {"disabled": true}, "echo": {"services": ["echo.Echo"]}}}`},
+		{name: "empty", value: `{}`},
+		{name: "not json", value: "true", wantErr: true},
+		{name: "unknown field", value: `{"descriptorBin": "abc"}`, wantErr: true},
+		{name: "no services", value: `{"descriptor": "configmap://greeter/greeter.pb"}`, wantErr: true},
+		{name: "invalid service", value: `{"descriptor": "configmap://greeter/greeter.pb", "services": ["/helloworld.Greeter"]}`, wantErr: true},
+		{name: "unknown scheme", value: `{"descriptor": "file:///greeter.pb", "services": ["helloworld.Greeter"]}`, wantErr: true},
+		{name: "configmap without key", value: `{"descriptor": "configmap://greeter", "services": ["helloworld.Greeter"]}`, wantErr: true},
+		{name: "configmap with namespace", value: `{"descriptor": "configmap://default/greeter/greeter.pb", "services": ["helloworld.Greeter"]}`, wantErr: true},
+		{name: "url without host", value: `{"descriptor": "https:///greeter.pb", "services": ["helloworld.Greeter"]}`, wantErr: true},
+		{name: "route without descriptor", value: `{"routes": {"greeter": {"services": ["helloworld.Greeter"]}}}`, wantErr: true},
+		{name: "null route", value: `{"routes": {"greeter": null}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {"disabled": true}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteTranscoding(t *testing.T) {
+	spec, err := Parse(`{"descriptor": "configmap://greeter/greeter.pb", "services": ["helloworld.Greeter"], ` +
+		`"routes": {"healthz": {"disabled": true}, "echo": {"services": ["echo.Echo"], "autoMapping": true}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		route string
+		want  Transcoding
+	}{
+		{route: "greeter", want: Transcoding{Descriptor: "configmap://greeter/greeter.pb", Services: []string{"helloworld.Greeter"}}},
+		{route: "healthz.0", want: Transcoding{Disabled: true, Descriptor: "configmap://greeter/greeter.pb", Services: []string{"helloworld.Greeter"}}},
+		{route: "echo", want: Transcoding{Descriptor: "configmap://greeter/greeter.pb", Services: []string{"echo.Echo"}, AutoMapping: true}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.route, func(t *testing.T) {
+			got := spec.RouteTranscoding(tt.route)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if got.Enabled() == tt.want.Disabled {
+				t.Fatalf("got enabled %v", got.Enabled())
+			}
+		})
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	cases := map[string]string{
+		"configmap://greeter/greeter.pb":        "configmap://default/greeter/greeter.pb",
+		"oci://registry.io/protos/greeter:v1":   "oci://registry.io/protos/greeter:v1",
+		"https://example.com/protos/greeter.pb": "https://example.com/protos/greeter.pb",
+	}
+	for source, want := range cases {
+		if got := ResolveSource(source, "default"); got != want {
+			t.Errorf("ResolveSource(%s) = %s, want %s", source, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package ipaccess
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+// Addresses of the clients matched by the policies.
+const (
+	// SourceRemote is the address of the client, from the PROXY protocol or the x-forwarded-for header as trusted by
+	// the gateway.
+	SourceRemote = "remote"
+	// SourceDirect is the address of the peer of the connection, such as a load balancer in front of the gateway.
+	SourceDirect = "direct"
+)
+
+// ParseCIDR parses an IP address or a CIDR, such as 10.0.0.1 or 10.0.0.0/8.
+func ParseCIDR(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", value)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP address %q", value)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Policy allows or denies the clients by IP address. A client is denied if its address is in a deny CIDR, or if
+// there are allow CIDRs and its address is in none of them.
+type Policy struct {
+	// Disabled lets all the clients of a route through, ignoring the policy of the virtual service.
+	Disabled bool `json:"disabled,omitempty"`
+	// Allow are the CIDRs of the only clients allowed, or empty to allow all the clients not denied.
+	Allow []string `json:"allow,omitempty"`
+	// Deny are the CIDRs of the clients denied.
+	Deny []string `json:"deny,omitempty"`
+	// Shadow only records the clients that would be denied, in the shadow stats and dynamic metadata of the RBAC
+	// filters, to try a policy out before enforcing it.
+	Shadow bool `json:"shadow,omitempty"`
+	// Source is the address of the clients matched, remote or direct, remote by default.
+	Source string `json:"source,omitempty"`
+
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// AllowCIDRs returns the parsed allow CIDRs.
+func (p *Policy) AllowCIDRs() []netip.Prefix {
+	return p.allow
+}
+
+// DenyCIDRs returns the parsed deny CIDRs.
+func (p *Policy) DenyCIDRs() []netip.Prefix {
+	return p.deny
+}
+
+// Enabled returns true if the policy denies some clients.
+func (p *Policy) Enabled() bool {
+	return !p.Disabled && (len(p.allow) > 0 || len(p.deny) > 0)
+}
+
+func (p *Policy) validate() error {
+	if p.Disabled {
+		if len(p.Allow) > 0 || len(p.Deny) > 0 || p.Shadow || p.Source != "" {
+			return fmt.Errorf("a disabled policy may not set other fields")
+		}
+		return nil
+	}
+	switch p.Source {
+	case "":
+		p.Source = SourceRemote
+	case SourceRemote, SourceDirect:
+	default:
+		return fmt.Errorf("invalid source %q, must be remote or direct", p.Source)
+	}
+	p.allow, p.deny = nil, nil
+	for _, value := range p.Allow {
+		prefix, err := ParseCIDR(value)
+		if err != nil {
+			return err
+		}
+		p.allow = append(p.allow, prefix)
+	}
+	for _, value := range p.Deny {
+		prefix, err := ParseCIDR(value)
+		if err != nil {
+			return err
+		}
+		p.deny = append(p.deny, prefix)
+	}
+	return nil
+}
+
+// Spec is the IP access control of the routes of a virtual service, or of the listeners of the servers of a gateway.
+type Spec struct {
+	// Policy is the IP access control of all the routes of the virtual service, or of the listeners of the gateway.
+	Policy
+	// Routes are the IP access control of some routes, keyed by the name of the HTTP route, or of the HTTP route and
+	// its match as <route>.<match>. They override Policy. Gateways may not set them.
+	Routes map[string]*Policy `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/ip-access annotation of a virtual service, as JSON such as
+// {"deny": ["192.168.0.0/16"], "routes": {"admin": {"allow": ["10.0.0.0/8"]}}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid ip access: %v", err)
+	}
+	if spec.Disabled {
+		return nil, fmt.Errorf("invalid ip access: only the policies of the routes may be disabled")
+	}
+	if err := spec.Policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid ip access: %v", err)
+	}
+	for name, policy := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid ip access: route name may not be empty")
+		}
+		if policy == nil {
+			return nil, fmt.Errorf("invalid ip access of route %s: the policy may not be null", name)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid ip access of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+// ParseGateway parses and validates the value of a higress.io/ip-access annotation of a gateway, which applies to the
+// connections of its listeners and so has no routes.
+func ParseGateway(value string) (*Spec, error) {
+	spec, err := Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.Routes) > 0 {
+		return nil, fmt.Errorf("invalid ip access: a gateway may not set the policies of routes")
+	}
+	if !spec.Enabled() {
+		return nil, fmt.Errorf("invalid ip access: the policy must allow or deny some CIDRs")
+	}
+	return spec, nil
+}
+
+// RoutePolicy returns the IP access control of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, else of the virtual service.
+func (s *Spec) RoutePolicy(name string) *Policy {
+	if policy, ok := routes.Lookup(s.Routes, name); ok {
+		return policy
+	}
+	return &s.Policy
+}
@@ -0,0 +1,96 @@
+package ipaccess
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestParseCIDR(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "10.0.0.1", want: "10.0.0.1/32"},
+		{value: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{value: "2001:db8::/32", want: "2001:db8::/32"},
+		{value: "::1", want: "::1/128"},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "example.com", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseCIDR(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "deny", value: `{"deny": ["192.168.0.0/16"]}`},
+		{name: "routes", value: `{"allow": ["10.0.0.0/8"], "shadow": true, "routes": {"public": {"disabled": true}, "admin": {"allow": ["10.0.0.1"], "source": "direct"}}}`},
+		{name: "not json", value: "10.0.0.0/8", wantErr: true},
+		{name: "unknown field", value: `{"whitelist": ["10.0.0.0/8"]}`, wantErr: true},
+		{name: "invalid cidr", value: `{"deny": ["10.0.0.0/40"]}`, wantErr: true},
+		{name: "invalid source", value: `{"deny": ["10.0.0.0/8"], "source": "xff"}`, wantErr: true},
+		{name: "disabled", value: `{"disabled": true}`, wantErr: true},
+		{name: "disabled with cidrs", value: `{"routes": {"admin": {"disabled": true, "allow": ["10.0.0.0/8"]}}}`, wantErr: true},
+		{name: "invalid route cidr", value: `{"routes": {"admin": {"allow": ["admin"]}}}`, wantErr: true},
+		{name: "null route policy", value: `{"routes": {"admin": null}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseGateway(t *testing.T) {
+	spec, err := ParseGateway(`{"allow": ["10.0.0.0/8"], "deny": ["10.0.0.1"], "source": "direct"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}; !reflect.DeepEqual(spec.AllowCIDRs(), want) {
+		t.Errorf("got allow %v, want %v", spec.AllowCIDRs(), want)
+	}
+	if want := []netip.Prefix{netip.MustParsePrefix("10.0.0.1/32")}; !reflect.DeepEqual(spec.DenyCIDRs(), want) {
+		t.Errorf("got deny %v, want %v", spec.DenyCIDRs(), want)
+	}
+	if _, err := ParseGateway(`{"deny": ["10.0.0.1"], "routes": {"admin": {}}}`); err == nil {
+		t.Error("got no error for the routes of a gateway")
+	}
+	if _, err := ParseGateway(`{"shadow": true}`); err == nil {
+		t.Error("got no error for a gateway policy without CIDRs")
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	spec, err := Parse(`{"deny": ["192.168.0.0/16"], "routes": {"admin": {"allow": ["10.0.0.0/8"]}, "public": {"disabled": true}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RoutePolicy("api"); len(got.DenyCIDRs()) != 1 || got.Source != SourceRemote {
+		t.Errorf("got policy %+v for route api, want the policy of the virtual service", got)
+	}
+	if got := spec.RoutePolicy("admin.1"); len(got.AllowCIDRs()) != 1 {
+		t.Errorf("got policy %+v for route admin.1, want the policy of route admin", got)
+	}
+	if got := spec.RoutePolicy("public"); got.Enabled() {
+		t.Error("got route public enabled, want disabled")
+	}
+}
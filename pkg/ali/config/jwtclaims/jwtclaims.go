@@ -0,0 +1,48 @@
+package jwtclaims
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Spec is the propagation of the claims of the verified JWTs of a RequestAuthentication to request headers.
+type Spec struct {
+	// Claims are the claims copied to the requests, keyed by header name, such as {"x-user-id": "sub"}. Nested
+	// claims are separated by dots, such as "org.tenant". Only the claims of string, number and boolean values are
+	// copied.
+	Claims map[string]string `json:"claims,omitempty"`
+	// Sanitize removes from the requests the headers of Claims, and the output claim and payload headers of the JWT
+	// rules, before verifying the JWTs, so that clients cannot spoof them without a JWT. True by default.
+	Sanitize *bool `json:"sanitize,omitempty"`
+}
+
+// SanitizeEnabled returns true if the headers set from the JWTs are removed from the requests before verification.
+func (s *Spec) SanitizeEnabled() bool {
+	return s.Sanitize == nil || *s.Sanitize
+}
+
+// Parse parses and validates the value of a higress.io/jwt-claim-headers annotation, as JSON such as
+// {"claims": {"x-user-id": "sub", "x-tenant": "org.tenant"}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid jwt claim headers: %v", err)
+	}
+	for header, claim := range spec.Claims {
+		if !httpguts.ValidHeaderFieldName(header) {
+			return nil, fmt.Errorf("invalid jwt claim headers: invalid header name %q", header)
+		}
+		if strings.EqualFold(header, "host") {
+			return nil, fmt.Errorf("invalid jwt claim headers: the host header may not be set")
+		}
+		if claim == "" || strings.HasPrefix(claim, ".") || strings.HasSuffix(claim, ".") || strings.Contains(claim, "..") {
+			return nil, fmt.Errorf("invalid jwt claim headers: invalid claim %q of header %s", claim, header)
+		}
+	}
+	return spec, nil
+}
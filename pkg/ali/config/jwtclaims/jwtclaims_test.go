@@ -0,0 +1,36 @@
+package jwtclaims
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name         string
+		value        string
+		wantSanitize bool
+		wantErr      bool
+	}{
+		{name: "claims", value: `{"claims": {"x-user-id": "sub", "x-tenant": "org.tenant"}}`, wantSanitize: true},
+		{name: "sanitize only", value: `{"sanitize": true}`, wantSanitize: true},
+		{name: "not sanitized", value: `{"claims": {"x-user-id": "sub"}, "sanitize": false}`},
+		{name: "not json", value: "x-user-id=sub", wantErr: true},
+		{name: "unknown field", value: `{"headers": {"x-user-id": "sub"}}`, wantErr: true},
+		{name: "invalid header", value: `{"claims": {"x user": "sub"}}`, wantErr: true},
+		{name: "pseudo header", value: `{"claims": {":path": "sub"}}`, wantErr: true},
+		{name: "host", value: `{"claims": {"Host": "aud"}}`, wantErr: true},
+		{name: "empty claim", value: `{"claims": {"x-user-id": ""}}`, wantErr: true},
+		{name: "invalid nested claim", value: `{"claims": {"x-tenant": "org..tenant"}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && spec.SanitizeEnabled() != tt.wantSanitize {
+				t.Errorf("got sanitize %v, want %v", spec.SanitizeEnabled(), tt.wantSanitize)
+			}
+		})
+	}
+}
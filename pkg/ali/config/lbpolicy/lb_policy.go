@@ -0,0 +1,150 @@
+package lbpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// LeastRequest picks the host with the fewest active requests among ChoiceCount random hosts, weighing the hosts
+	// by their active requests with ActiveRequestBias when their weights differ.
+	LeastRequest = "least_request"
+	// ClientSideWeightedRoundRobin weighs the hosts by the utilization and the request rate they report in ORCA load
+	// reports, a peak-EWMA like policy sending less traffic to the busy or slow hosts.
+	ClientSideWeightedRoundRobin = "client_side_weighted_round_robin"
+)
+
+// Spec is the load balancing policy of the clusters of a DestinationRule, tuning it beyond the simple load balancer
+// of its traffic policy.
+type Spec struct {
+	// Policy is the load balancing policy, least_request or client_side_weighted_round_robin.
+	Policy string `json:"policy"`
+
+	// ChoiceCount is the number of random hosts compared by least_request, 2 if unset.
+	ChoiceCount uint32 `json:"choiceCount,omitempty"`
+	// ActiveRequestBias is the exponent of the active requests dividing the weights of the hosts by least_request,
+	// 1.0 if unset.
+	ActiveRequestBias *float64 `json:"activeRequestBias,omitempty"`
+	// SlowStart progressively increases the traffic sent by least_request to the new hosts.
+	SlowStart *SlowStart `json:"slowStart,omitempty"`
+
+	// EnableOOBLoadReport lets client_side_weighted_round_robin receive the load reports out of band, instead of in
+	// the response headers.
+	EnableOOBLoadReport bool `json:"enableOobLoadReport,omitempty"`
+	// OOBReportingPeriod is the period of the out of band load reports, 10s if unset.
+	OOBReportingPeriod string `json:"oobReportingPeriod,omitempty"`
+	// BlackoutPeriod is the time a host is weighted by the mean weight after reporting its load, 10s if unset.
+	BlackoutPeriod string `json:"blackoutPeriod,omitempty"`
+	// WeightExpirationPeriod is the time after which the weight of a host not reporting its load expires, 3m if
+	// unset.
+	WeightExpirationPeriod string `json:"weightExpirationPeriod,omitempty"`
+	// WeightUpdatePeriod is the period of the update of the weights, 1s if unset.
+	WeightUpdatePeriod string `json:"weightUpdatePeriod,omitempty"`
+	// ErrorUtilizationPenalty is the multiplier of the error rate added to the utilization of the hosts, 1.0 if
+	// unset.
+	ErrorUtilizationPenalty *float64 `json:"errorUtilizationPenalty,omitempty"`
+}
+
+// SlowStart is the slow start of the new hosts of a cluster.
+type SlowStart struct {
+	// Window is the duration of the slow start, such as "30s".
+	Window string `json:"window"`
+	// Aggression is the rate of the increase of the traffic, linear with 1.0, the default.
+	Aggression *float64 `json:"aggression,omitempty"`
+	// MinWeightPercent is the minimum percentage of its weight a new host is weighted by, 10 if unset.
+	MinWeightPercent *float64 `json:"minWeightPercent,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/load-balancer-policy annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid load balancer policy: %v", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid load balancer policy: %v", err)
+	}
+	return spec, nil
+}
+
+func (s *Spec) validate() error {
+	switch s.Policy {
+	case LeastRequest:
+		if s.EnableOOBLoadReport || s.OOBReportingPeriod != "" || s.BlackoutPeriod != "" ||
+			s.WeightExpirationPeriod != "" || s.WeightUpdatePeriod != "" || s.ErrorUtilizationPenalty != nil {
+			return fmt.Errorf("the settings of %s are not allowed for %s", ClientSideWeightedRoundRobin, LeastRequest)
+		}
+		if s.ChoiceCount == 1 {
+			return fmt.Errorf("choiceCount must be at least 2")
+		}
+		if s.ActiveRequestBias != nil && *s.ActiveRequestBias < 0 {
+			return fmt.Errorf("activeRequestBias %v may not be negative", *s.ActiveRequestBias)
+		}
+		if s.SlowStart != nil {
+			return s.SlowStart.validate()
+		}
+	case ClientSideWeightedRoundRobin:
+		if s.ChoiceCount != 0 || s.ActiveRequestBias != nil || s.SlowStart != nil {
+			return fmt.Errorf("the settings of %s are not allowed for %s", LeastRequest, ClientSideWeightedRoundRobin)
+		}
+		periods := map[string]string{
+			"oobReportingPeriod":     s.OOBReportingPeriod,
+			"blackoutPeriod":         s.BlackoutPeriod,
+			"weightExpirationPeriod": s.WeightExpirationPeriod,
+			"weightUpdatePeriod":     s.WeightUpdatePeriod,
+		}
+		for name, period := range periods {
+			if err := validateDuration(name, period); err != nil {
+				return err
+			}
+		}
+		if s.ErrorUtilizationPenalty != nil && *s.ErrorUtilizationPenalty < 0 {
+			return fmt.Errorf("errorUtilizationPenalty %v may not be negative", *s.ErrorUtilizationPenalty)
+		}
+	case "":
+		return fmt.Errorf("policy is required")
+	default:
+		return fmt.Errorf("unknown policy %q, must be %s or %s", s.Policy, LeastRequest, ClientSideWeightedRoundRobin)
+	}
+	return nil
+}
+
+func (s *SlowStart) validate() error {
+	if s.Window == "" {
+		return fmt.Errorf("slowStart window is required")
+	}
+	if err := validateDuration("slowStart window", s.Window); err != nil {
+		return err
+	}
+	if s.Aggression != nil && *s.Aggression <= 0 {
+		return fmt.Errorf("slowStart aggression %v must be positive", *s.Aggression)
+	}
+	if s.MinWeightPercent != nil && (*s.MinWeightPercent < 0 || *s.MinWeightPercent > 100) {
+		return fmt.Errorf("slowStart minWeightPercent %v must be between 0 and 100", *s.MinWeightPercent)
+	}
+	return nil
+}
+
+func validateDuration(name, value string) error {
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", name, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("%s %s must be positive", name, value)
+	}
+	return nil
+}
+
+// Duration returns the duration of a validated setting, 0 if unset.
+func Duration(value string) time.Duration {
+	d, _ := time.ParseDuration(value)
+	return d
+}
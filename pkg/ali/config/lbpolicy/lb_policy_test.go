@@ -0,0 +1,49 @@
+package lbpolicy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "least request", value: `{"policy": "least_request", "choiceCount": 3, "activeRequestBias": 0.5}`},
+		{name: "slow start", value: `{"policy": "least_request", "slowStart": {"window": "30s", "aggression": 1.5, "minWeightPercent": 20}}`},
+		{name: "client side weighted round robin", value: `{"policy": "client_side_weighted_round_robin", "blackoutPeriod": "5s", "errorUtilizationPenalty": 2}`},
+		{name: "invalid json", value: `{"policy": `, wantErr: true},
+		{name: "unknown field", value: `{"policy": "least_request", "ewmaDecay": "10s"}`, wantErr: true},
+		{name: "no policy", value: `{"choiceCount": 3}`, wantErr: true},
+		{name: "unknown policy", value: `{"policy": "random"}`, wantErr: true},
+		{name: "choice count 1", value: `{"policy": "least_request", "choiceCount": 1}`, wantErr: true},
+		{name: "negative bias", value: `{"policy": "least_request", "activeRequestBias": -1}`, wantErr: true},
+		{name: "no slow start window", value: `{"policy": "least_request", "slowStart": {"aggression": 1}}`, wantErr: true},
+		{name: "invalid slow start window", value: `{"policy": "least_request", "slowStart": {"window": "30"}}`, wantErr: true},
+		{name: "zero aggression", value: `{"policy": "least_request", "slowStart": {"window": "30s", "aggression": 0}}`, wantErr: true},
+		{name: "min weight over 100", value: `{"policy": "least_request", "slowStart": {"window": "30s", "minWeightPercent": 101}}`, wantErr: true},
+		{name: "weighted round robin settings for least request", value: `{"policy": "least_request", "blackoutPeriod": "5s"}`, wantErr: true},
+		{name: "least request settings for weighted round robin", value: `{"policy": "client_side_weighted_round_robin", "choiceCount": 3}`, wantErr: true},
+		{name: "negative period", value: `{"policy": "client_side_weighted_round_robin", "weightUpdatePeriod": "-1s"}`, wantErr: true},
+		{name: "negative penalty", value: `{"policy": "client_side_weighted_round_robin", "errorUtilizationPenalty": -1}`, wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Parse(c.value)
+			if (err != nil) != c.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	if got := Duration("1m30s"); got != 90*time.Second {
+		t.Errorf("Duration() = %v, want 1m30s", got)
+	}
+	if got := Duration(""); got != 0 {
+		t.Errorf("Duration() = %v, want 0", got)
+	}
+}
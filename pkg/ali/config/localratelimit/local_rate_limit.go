@@ -0,0 +1,102 @@
+package localratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// minFillInterval is the shortest fill interval Envoy accepts for a token bucket.
+const minFillInterval = 50 * time.Millisecond
+
+// Spec is the local rate limit set by the higress.io/local-rate-limit annotation of a virtual service on its routes.
+type Spec struct {
+	TokenBucket
+	// StatusCode is the status of the rate limited responses, 429 if unset.
+	StatusCode uint32 `json:"statusCode,omitempty"`
+	// ResponseHeaders are added to the rate limited responses, x-local-rate-limit: true if unset.
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	// Descriptors override the token bucket for the requests with a header value.
+	Descriptors []*Descriptor `json:"descriptors,omitempty"`
+}
+
+// TokenBucket holds MaxTokens tokens, refilled with TokensPerFill tokens every FillInterval. TokensPerFill defaults
+// to MaxTokens, allowing MaxTokens requests per FillInterval.
+type TokenBucket struct {
+	MaxTokens     uint32 `json:"maxTokens"`
+	TokensPerFill uint32 `json:"tokensPerFill,omitempty"`
+	FillInterval  string `json:"fillInterval"`
+
+	interval time.Duration
+}
+
+// Interval returns the parsed fill interval of the token bucket.
+func (b *TokenBucket) Interval() time.Duration {
+	return b.interval
+}
+
+// Descriptor overrides the token bucket of a local rate limit for the requests whose Header is Value.
+type Descriptor struct {
+	Header string `json:"header"`
+	Value  string `json:"value"`
+	TokenBucket
+}
+
+// Parse parses and validates the value of a higress.io/local-rate-limit annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	if err := json.Unmarshal([]byte(value), spec); err != nil {
+		return nil, fmt.Errorf("invalid local rate limit: %v", err)
+	}
+	if err := spec.TokenBucket.validate(); err != nil {
+		return nil, fmt.Errorf("invalid local rate limit: %v", err)
+	}
+	if spec.StatusCode != 0 && (spec.StatusCode < 400 || spec.StatusCode > 599) {
+		return nil, fmt.Errorf("invalid local rate limit: status code %d is not in [400, 599]", spec.StatusCode)
+	}
+	for name, v := range spec.ResponseHeaders {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(v) {
+			return nil, fmt.Errorf("invalid local rate limit: invalid response header %q: %q", name, v)
+		}
+	}
+	seen := map[[2]string]bool{}
+	for i, d := range spec.Descriptors {
+		if d == nil || !httpguts.ValidHeaderFieldName(d.Header) || d.Value == "" {
+			return nil, fmt.Errorf("invalid local rate limit descriptor %d: a valid header and a value are required", i)
+		}
+		key := [2]string{d.Header, d.Value}
+		if seen[key] {
+			return nil, fmt.Errorf("invalid local rate limit descriptor %d: duplicate %s: %s", i, d.Header, d.Value)
+		}
+		seen[key] = true
+		if err := d.TokenBucket.validate(); err != nil {
+			return nil, fmt.Errorf("invalid local rate limit descriptor %d: %v", i, err)
+		}
+		// Envoy refills the token buckets of the descriptors along with the one of the filter.
+		if d.interval%spec.interval != 0 {
+			return nil, fmt.Errorf("invalid local rate limit descriptor %d: fill interval %v is not a multiple of %v",
+				i, d.interval, spec.interval)
+		}
+	}
+	return spec, nil
+}
+
+func (b *TokenBucket) validate() error {
+	if b.MaxTokens == 0 {
+		return fmt.Errorf("maxTokens must be positive")
+	}
+	if b.TokensPerFill == 0 {
+		b.TokensPerFill = b.MaxTokens
+	}
+	interval, err := time.ParseDuration(b.FillInterval)
+	if err != nil {
+		return fmt.Errorf("invalid fillInterval: %v", err)
+	}
+	if interval < minFillInterval {
+		return fmt.Errorf("fillInterval %v is shorter than %v", interval, minFillInterval)
+	}
+	b.interval = interval
+	return nil
+}
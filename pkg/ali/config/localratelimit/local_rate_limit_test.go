@@ -0,0 +1,90 @@
+package localratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{
+			name:  "token bucket",
+			value: `{"maxTokens": 100, "fillInterval": "1s"}`,
+		},
+		{
+			name: "full",
+			value: `{"maxTokens": 100, "tokensPerFill": 10, "fillInterval": "1s", "statusCode": 503,
+				"responseHeaders": {"x-limited": "yes"},
+				"descriptors": [{"header": "x-user", "value": "vip", "maxTokens": 1000, "fillInterval": "2s"}]}`,
+		},
+		{
+			name:    "not json",
+			value:   "100/s",
+			wantErr: true,
+		},
+		{
+			name:    "no tokens",
+			value:   `{"fillInterval": "1s"}`,
+			wantErr: true,
+		},
+		{
+			name:    "short fill interval",
+			value:   `{"maxTokens": 100, "fillInterval": "10ms"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid status code",
+			value:   `{"maxTokens": 100, "fillInterval": "1s", "statusCode": 200}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid response header",
+			value:   `{"maxTokens": 100, "fillInterval": "1s", "responseHeaders": {"x limited": "yes"}}`,
+			wantErr: true,
+		},
+		{
+			name: "descriptor without value",
+			value: `{"maxTokens": 100, "fillInterval": "1s",
+				"descriptors": [{"header": "x-user", "maxTokens": 1000, "fillInterval": "1s"}]}`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate descriptors",
+			value: `{"maxTokens": 100, "fillInterval": "1s", "descriptors": [
+				{"header": "x-user", "value": "vip", "maxTokens": 1000, "fillInterval": "1s"},
+				{"header": "x-user", "value": "vip", "maxTokens": 10, "fillInterval": "1s"}]}`,
+			wantErr: true,
+		},
+		{
+			name: "descriptor fill interval not a multiple",
+			value: `{"maxTokens": 100, "fillInterval": "1s",
+				"descriptors": [{"header": "x-user", "value": "vip", "maxTokens": 1000, "fillInterval": "1500ms"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Parse(c.value); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	spec, err := Parse(`{"maxTokens": 100, "fillInterval": "1s",
+		"descriptors": [{"header": "x-user", "value": "vip", "maxTokens": 1000, "fillInterval": "2s"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.TokensPerFill != 100 || spec.Interval() != time.Second {
+		t.Errorf("got token bucket %d per %v, want 100 per 1s", spec.TokensPerFill, spec.Interval())
+	}
+	if d := spec.Descriptors[0]; d.TokensPerFill != 1000 || d.Interval() != 2*time.Second {
+		t.Errorf("got descriptor token bucket %d per %v, want 1000 per 2s", d.TokensPerFill, d.Interval())
+	}
+}
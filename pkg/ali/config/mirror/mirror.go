@@ -0,0 +1,69 @@
+package mirror
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "istio.io/api/networking/v1alpha3"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// Headers are the request header conditions of the mirror destinations of a virtual service, keyed by the host of
+// the mirror destinations. The requests are mirrored to a destination only if their headers match all its conditions.
+type Headers map[string]map[string]*networking.StringMatch
+
+// Parse parses and validates the value of a higress.io/mirror-headers annotation, as JSON such as
+// {"reviews-canary.default.svc.cluster.local": {"x-canary": {"exact": "true"}}}.
+func Parse(value string) (Headers, error) {
+	raw := map[string]map[string]json.RawMessage{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid mirror headers: %v", err)
+	}
+	headers := make(Headers, len(raw))
+	for mirrorHost, conditions := range raw {
+		if mirrorHost == "" {
+			return nil, fmt.Errorf("invalid mirror headers: mirror host may not be empty")
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("invalid mirror headers: no header conditions for mirror host %s", mirrorHost)
+		}
+		matches := make(map[string]*networking.StringMatch, len(conditions))
+		for name, condition := range conditions {
+			if name == "" {
+				return nil, fmt.Errorf("invalid mirror headers: header name of mirror host %s may not be empty", mirrorHost)
+			}
+			match := &networking.StringMatch{}
+			if err := protomarshal.Unmarshal(condition, match); err != nil {
+				return nil, fmt.Errorf("invalid mirror headers: invalid match of header %s of mirror host %s: %v",
+					name, mirrorHost, err)
+			}
+			if err := validateMatch(match); err != nil {
+				return nil, fmt.Errorf("invalid mirror headers: invalid match of header %s of mirror host %s: %v",
+					name, mirrorHost, err)
+			}
+			matches[strings.ToLower(name)] = match
+		}
+		headers[mirrorHost] = matches
+	}
+	return headers, nil
+}
+
+func validateMatch(match *networking.StringMatch) error {
+	switch m := match.MatchType.(type) {
+	case nil:
+		return fmt.Errorf("one of exact, prefix or regex is required")
+	case *networking.StringMatch_Prefix:
+		if m.Prefix == "" {
+			return fmt.Errorf("prefix may not be empty")
+		}
+	case *networking.StringMatch_Regex:
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", m.Regex, err)
+		}
+	}
+	return nil
+}
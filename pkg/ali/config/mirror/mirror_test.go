@@ -0,0 +1,102 @@
+package mirror
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    Headers
+		wantErr bool
+	}{
+		{
+			name:  "exact and prefix",
+			value: `{"canary.default.svc.cluster.local": {"X-Canary": {"exact": "true"}, "user-agent": {"prefix": "curl"}}}`,
+			want: Headers{
+				"canary.default.svc.cluster.local": {
+					"x-canary":   {MatchType: &networking.StringMatch_Exact{Exact: "true"}},
+					"user-agent": {MatchType: &networking.StringMatch_Prefix{Prefix: "curl"}},
+				},
+			},
+		},
+		{
+			name:  "regex",
+			value: `{"shadow.default.svc.cluster.local": {"x-user": {"regex": "test-.*"}}}`,
+			want: Headers{
+				"shadow.default.svc.cluster.local": {
+					"x-user": {MatchType: &networking.StringMatch_Regex{Regex: "test-.*"}},
+				},
+			},
+		},
+		{
+			name:    "invalid json",
+			value:   `{"canary.default.svc.cluster.local": `,
+			wantErr: true,
+		},
+		{
+			name:    "no conditions",
+			value:   `{"canary.default.svc.cluster.local": {}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty host",
+			value:   `{"": {"x-canary": {"exact": "true"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty header name",
+			value:   `{"canary.default.svc.cluster.local": {"": {"exact": "true"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "no match type",
+			value:   `{"canary.default.svc.cluster.local": {"x-canary": {}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "empty prefix",
+			value:   `{"canary.default.svc.cluster.local": {"x-canary": {"prefix": ""}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid regex",
+			value:   `{"canary.default.svc.cluster.local": {"x-canary": {"regex": "("}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"canary.default.svc.cluster.local": {"x-canary": {"suffix": "true"}}}`,
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Parse(c.value)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if c.wantErr {
+				return
+			}
+			if len(got) != len(c.want) {
+				t.Fatalf("Parse() = %v, want %v", got, c.want)
+			}
+			for mirrorHost, want := range c.want {
+				if len(got[mirrorHost]) != len(want) {
+					t.Fatalf("Parse()[%s] = %v, want %v", mirrorHost, got[mirrorHost], want)
+				}
+				for name, match := range want {
+					if !proto.Equal(got[mirrorHost][name], match) {
+						t.Errorf("Parse()[%s][%s] = %v, want %v", mirrorHost, name, got[mirrorHost][name], match)
+					}
+				}
+			}
+		})
+	}
+}
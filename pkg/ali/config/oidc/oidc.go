@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Defaults of the OIDC login of a gateway.
+const (
+	DefaultRedirectPath    = "/oauth2/callback"
+	DefaultClientSecretKey = "client-secret"
+	DefaultHMACSecretKey   = "hmac-secret"
+	DefaultTokenTimeout    = 5 * time.Second
+)
+
+// Ways the gateway authenticates to the token endpoint.
+const (
+	// AuthTypeBody sends the client credentials in the body of the token requests.
+	AuthTypeBody = "body"
+	// AuthTypeBasic sends the client credentials in the basic authorization header of the token requests.
+	AuthTypeBasic = "basic"
+)
+
+// CookieNames are the names of the cookies holding the tokens of the logged in users, the defaults of Envoy if empty.
+type CookieNames struct {
+	BearerToken  string `json:"bearerToken,omitempty"`
+	HMAC         string `json:"hmac,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+	IDToken      string `json:"idToken,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+func (c *CookieNames) validate() error {
+	for _, name := range []string{c.BearerToken, c.HMAC, c.Expires, c.IDToken, c.RefreshToken} {
+		if name != "" && !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("invalid cookie name %q", name)
+		}
+	}
+	return nil
+}
+
+// Spec is the OIDC login of the browsers to some hosts of the servers of a gateway, with the authorization code flow
+// of an identity provider.
+type Spec struct {
+	// Hosts are the hosts requiring a login, such as app.example.com or *.example.com, all the hosts of the servers
+	// of the gateway by default.
+	Hosts []string `json:"hosts,omitempty"`
+	// AuthorizationEndpoint is the URL the browsers are redirected to for the login.
+	AuthorizationEndpoint string `json:"authorizationEndpoint"`
+	// TokenEndpoint is the URL the gateway exchanges the authorization codes for tokens at. Its host must be a
+	// service of the mesh, or of a ServiceEntry.
+	TokenEndpoint string `json:"tokenEndpoint"`
+	// TokenTimeout is the timeout of the token requests, 5s by default.
+	TokenTimeout string `json:"tokenTimeout,omitempty"`
+	// ClientID is the ID of the gateway at the identity provider.
+	ClientID string `json:"clientID"`
+	// CredentialName is the Kubernetes secret of the namespace of the gateway holding the client secret and the HMAC
+	// secret signing the cookies, served to the gateway by SDS.
+	CredentialName string `json:"credentialName"`
+	// ClientSecretKey is the key of the client secret in the secret, client-secret by default.
+	ClientSecretKey string `json:"clientSecretKey,omitempty"`
+	// HMACSecretKey is the key of the HMAC secret in the secret, hmac-secret by default.
+	HMACSecretKey string `json:"hmacSecretKey,omitempty"`
+	// AuthType is how the gateway authenticates to the token endpoint, body or basic, body by default.
+	AuthType string `json:"authType,omitempty"`
+	// Scopes are the scopes requested, openid by default.
+	Scopes []string `json:"scopes,omitempty"`
+	// Resources are the resource parameters of the authorization requests.
+	Resources []string `json:"resources,omitempty"`
+	// RedirectPath is the path of the callback of the identity provider, /oauth2/callback by default.
+	RedirectPath string `json:"redirectPath,omitempty"`
+	// LogoutPath is the path clearing the cookies of the users, if any.
+	LogoutPath string `json:"logoutPath,omitempty"`
+	// ForwardBearerToken sends the access token of the users to the upstreams as a bearer token.
+	ForwardBearerToken bool `json:"forwardBearerToken,omitempty"`
+	// PassThroughPaths are the path prefixes not requiring a login, such as health checks.
+	PassThroughPaths []string `json:"passThroughPaths,omitempty"`
+	// CookieNames are the names of the cookies of the tokens.
+	CookieNames *CookieNames `json:"cookieNames,omitempty"`
+
+	tokenTimeout time.Duration
+}
+
+// Timeout returns the timeout of the token requests.
+func (s *Spec) Timeout() time.Duration {
+	return s.tokenTimeout
+}
+
+// Parse parses and validates the value of a higress.io/oidc annotation, as JSON such as
+// {"authorizationEndpoint": "https://idp.example.com/authorize", "tokenEndpoint": "https://idp.example.com/token",
+// "clientID": "gateway", "credentialName": "oidc-client"}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid oidc: %v", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid oidc: %v", err)
+	}
+	return spec, nil
+}
+
+func (s *Spec) validate() error {
+	for _, host := range s.Hosts {
+		if host == "" || strings.ContainsAny(host, "/: ") || strings.LastIndex(host, "*") > 0 {
+			return fmt.Errorf("invalid host %q", host)
+		}
+	}
+	for name, endpoint := range map[string]string{
+		"authorization endpoint": s.AuthorizationEndpoint,
+		"token endpoint":         s.TokenEndpoint,
+	} {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid %s %q, expected an http or https URL", name, endpoint)
+		}
+	}
+	s.tokenTimeout = DefaultTokenTimeout
+	if s.TokenTimeout != "" {
+		timeout, err := time.ParseDuration(s.TokenTimeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid token timeout %q", s.TokenTimeout)
+		}
+		s.tokenTimeout = timeout
+	}
+	if s.ClientID == "" {
+		return fmt.Errorf("the client ID is required")
+	}
+	if s.CredentialName == "" || strings.ContainsAny(s.CredentialName, "/#") {
+		return fmt.Errorf("invalid credential name %q, expected the name of a secret of the namespace of the gateway",
+			s.CredentialName)
+	}
+	if s.ClientSecretKey == "" {
+		s.ClientSecretKey = DefaultClientSecretKey
+	}
+	if s.HMACSecretKey == "" {
+		s.HMACSecretKey = DefaultHMACSecretKey
+	}
+	switch s.AuthType {
+	case "":
+		s.AuthType = AuthTypeBody
+	case AuthTypeBody, AuthTypeBasic:
+	default:
+		return fmt.Errorf("invalid auth type %q, must be body or basic", s.AuthType)
+	}
+	if len(s.Scopes) == 0 {
+		s.Scopes = []string{"openid"}
+	}
+	if s.RedirectPath == "" {
+		s.RedirectPath = DefaultRedirectPath
+	}
+	for _, path := range append([]string{s.RedirectPath, s.LogoutPath}, s.PassThroughPaths...) {
+		if path != "" && !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid path %q, must start with /", path)
+		}
+	}
+	if s.LogoutPath == s.RedirectPath {
+		return fmt.Errorf("the logout path may not be the redirect path")
+	}
+	if s.CookieNames != nil {
+		if err := s.CookieNames.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
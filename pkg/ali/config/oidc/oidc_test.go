@@ -0,0 +1,73 @@
+package oidc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const endpoints = `"authorizationEndpoint": "https://idp.example.com/authorize", "tokenEndpoint": "https://idp.example.com/token"`
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "minimal", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc"}`},
+		{
+			name: "full",
+			value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "hosts": ["app.example.com", "*.example.com"],
+				"tokenTimeout": "3s", "authType": "basic", "scopes": ["openid", "email"], "logoutPath": "/logout",
+				"forwardBearerToken": true, "passThroughPaths": ["/healthz"], "cookieNames": {"bearerToken": "token"}}`,
+		},
+		{name: "not json", value: "oidc", wantErr: true},
+		{name: "unknown field", value: `{` + endpoints + `, "clientID": "gateway", "clientSecret": "secret", "credentialName": "oidc"}`, wantErr: true},
+		{name: "no endpoints", value: `{"clientID": "gateway", "credentialName": "oidc"}`, wantErr: true},
+		{name: "relative endpoint", value: `{"authorizationEndpoint": "/authorize", "tokenEndpoint": "https://idp/token", "clientID": "gateway", "credentialName": "oidc"}`, wantErr: true},
+		{name: "no client id", value: `{` + endpoints + `, "credentialName": "oidc"}`, wantErr: true},
+		{name: "no credential", value: `{` + endpoints + `, "clientID": "gateway"}`, wantErr: true},
+		{name: "credential of namespace", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "default/oidc"}`, wantErr: true},
+		{name: "invalid host", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "hosts": ["app.*.com"]}`, wantErr: true},
+		{name: "invalid timeout", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "tokenTimeout": "0s"}`, wantErr: true},
+		{name: "invalid auth type", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "authType": "jwt"}`, wantErr: true},
+		{name: "relative path", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "logoutPath": "logout"}`, wantErr: true},
+		{name: "logout on redirect", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "logoutPath": "/oauth2/callback"}`, wantErr: true},
+		{name: "invalid cookie", value: `{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "cookieNames": {"hmac": "a b"}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	spec, err := Parse(`{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Timeout() != DefaultTokenTimeout {
+		t.Errorf("got timeout %v, want %v", spec.Timeout(), DefaultTokenTimeout)
+	}
+	if spec.RedirectPath != DefaultRedirectPath || spec.AuthType != AuthTypeBody {
+		t.Errorf("got redirect path %s and auth type %s, want the defaults", spec.RedirectPath, spec.AuthType)
+	}
+	if spec.ClientSecretKey != DefaultClientSecretKey || spec.HMACSecretKey != DefaultHMACSecretKey {
+		t.Errorf("got secret keys %s and %s, want the defaults", spec.ClientSecretKey, spec.HMACSecretKey)
+	}
+	if !reflect.DeepEqual(spec.Scopes, []string{"openid"}) {
+		t.Errorf("got scopes %v, want openid", spec.Scopes)
+	}
+
+	spec, err = Parse(`{` + endpoints + `, "clientID": "gateway", "credentialName": "oidc", "tokenTimeout": "3s"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Timeout() != 3*time.Second {
+		t.Errorf("got timeout %v, want 3s", spec.Timeout())
+	}
+}
@@ -0,0 +1,69 @@
+package proxyprotocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Modes of the PROXY protocol on the listeners of a gateway.
+const (
+	// ModeRequired rejects the connections without a PROXY protocol header.
+	ModeRequired = "required"
+	// ModeOptional accepts the connections with or without a PROXY protocol header.
+	ModeOptional = "optional"
+	// ModeDisabled opts the listeners out of the mesh wide PROXY protocol.
+	ModeDisabled = "disabled"
+)
+
+// Versions of the PROXY protocol header sent to the upstream hosts.
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Spec is the PROXY protocol of the listeners of a gateway, preserving the address of the clients behind a load
+// balancer. The downstream address of the connections becomes the source address of the PROXY protocol header.
+type Spec struct {
+	// Mode is either required, optional or disabled, required by default.
+	Mode string `json:"mode,omitempty"`
+	// Metadata maps dynamic metadata keys to the types of the PROXY protocol v2 TLVs setting them, such as the VPC
+	// endpoint ID of a cloud load balancer, for the filters and the Wasm plugins to read.
+	Metadata map[string]uint32 `json:"metadata,omitempty"`
+	// PassThroughTLVs keeps all the TLVs of the connections for the PROXY protocol header sent to the upstream hosts.
+	PassThroughTLVs bool `json:"passThroughTLVs,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/proxy-protocol annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid proxy protocol: %v", err)
+	}
+	switch spec.Mode {
+	case "":
+		spec.Mode = ModeRequired
+	case ModeRequired, ModeOptional, ModeDisabled:
+	default:
+		return nil, fmt.Errorf("invalid proxy protocol: unknown mode %q", spec.Mode)
+	}
+	for key, tlvType := range spec.Metadata {
+		if key == "" {
+			return nil, fmt.Errorf("invalid proxy protocol: empty metadata key")
+		}
+		if tlvType > 255 {
+			return nil, fmt.Errorf("invalid proxy protocol: invalid TLV type %d of metadata %s", tlvType, key)
+		}
+	}
+	return spec, nil
+}
+
+// ValidateVersion validates the value of a higress.io/upstream-proxy-protocol annotation.
+func ValidateVersion(version string) error {
+	if version != V1 && version != V2 {
+		return fmt.Errorf("invalid upstream proxy protocol version %q: must be %s or %s", version, V1, V2)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package proxyprotocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    *Spec
+		wantErr bool
+	}{
+		{
+			name:  "required by default",
+			value: `{}`,
+			want:  &Spec{Mode: ModeRequired},
+		},
+		{
+			name:  "optional with metadata",
+			value: `{"mode": "optional", "metadata": {"vpce_id": 234}, "passThroughTLVs": true}`,
+			want:  &Spec{Mode: ModeOptional, Metadata: map[string]uint32{"vpce_id": 234}, PassThroughTLVs: true},
+		},
+		{
+			name:  "disabled",
+			value: `{"mode": "disabled"}`,
+			want:  &Spec{Mode: ModeDisabled},
+		},
+		{
+			name:    "not json",
+			value:   "required",
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			value:   `{"mode": "strict"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"version": "v2"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid TLV type",
+			value:   `{"metadata": {"vpce_id": 256}}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	for _, version := range []string{V1, V2} {
+		if err := ValidateVersion(version); err != nil {
+			t.Errorf("expected %s to be valid: %v", version, err)
+		}
+	}
+	if err := ValidateVersion("2"); err == nil {
+		t.Errorf("expected 2 to be invalid")
+	}
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Descriptor is a descriptor sent to the global rate limit service for the requests to the routes of a virtual
+// service, generated from its entries in order. No descriptor is sent if an entry can not be generated, such as
+// the one of a missing header.
+type Descriptor []*Entry
+
+// Entry is a descriptor entry, either the value of a request Header, the RemoteAddress of the client, or the constant
+// Value of a generic key.
+type Entry struct {
+	// Key of the entry, required for a header, generic_key by default for a value, and always remote_address for
+	// the remote address.
+	Key           string `json:"key,omitempty"`
+	Header        string `json:"header,omitempty"`
+	RemoteAddress bool   `json:"remoteAddress,omitempty"`
+	Value         string `json:"value,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/rate-limit annotation, a JSON list of descriptors.
+func Parse(value string) ([]Descriptor, error) {
+	var descriptors []Descriptor
+	if err := json.Unmarshal([]byte(value), &descriptors); err != nil {
+		return nil, fmt.Errorf("invalid rate limit descriptors: %v", err)
+	}
+	for i, d := range descriptors {
+		if len(d) == 0 {
+			return nil, fmt.Errorf("invalid rate limit descriptor %d: no entries", i)
+		}
+		for j, e := range d {
+			if err := e.validate(); err != nil {
+				return nil, fmt.Errorf("invalid rate limit descriptor %d entry %d: %v", i, j, err)
+			}
+		}
+	}
+	return descriptors, nil
+}
+
+func (e *Entry) validate() error {
+	if e == nil {
+		return fmt.Errorf("empty entry")
+	}
+	kinds := 0
+	if e.Header != "" {
+		kinds++
+		if !httpguts.ValidHeaderFieldName(e.Header) {
+			return fmt.Errorf("invalid header %q", e.Header)
+		}
+		if e.Key == "" {
+			return fmt.Errorf("a key is required for header %q", e.Header)
+		}
+	}
+	if e.RemoteAddress {
+		kinds++
+		if e.Key != "" {
+			return fmt.Errorf("the key of the remote address is always remote_address")
+		}
+	}
+	if e.Value != "" {
+		kinds++
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of header, remoteAddress and value is required")
+	}
+	return nil
+}
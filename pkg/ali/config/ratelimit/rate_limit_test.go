@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    []Descriptor
+		wantErr bool
+	}{
+		{
+			name:  "entries",
+			value: `[[{"value": "api"}, {"key": "user", "header": "x-user"}], [{"remoteAddress": true}]]`,
+			want: []Descriptor{
+				{{Value: "api"}, {Key: "user", Header: "x-user"}},
+				{{RemoteAddress: true}},
+			},
+		},
+		{
+			name:    "not json",
+			value:   "x-user",
+			wantErr: true,
+		},
+		{
+			name:    "empty descriptor",
+			value:   `[[]]`,
+			wantErr: true,
+		},
+		{
+			name:    "header without key",
+			value:   `[[{"header": "x-user"}]]`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid header",
+			value:   `[[{"key": "user", "header": "x user"}]]`,
+			wantErr: true,
+		},
+		{
+			name:    "remote address key",
+			value:   `[[{"key": "ip", "remoteAddress": true}]]`,
+			wantErr: true,
+		},
+		{
+			name:    "several kinds",
+			value:   `[[{"key": "user", "header": "x-user", "value": "api"}]]`,
+			wantErr: true,
+		},
+		{
+			name:    "no kind",
+			value:   `[[{"key": "user"}]]`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
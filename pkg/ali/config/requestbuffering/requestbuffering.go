@@ -0,0 +1,95 @@
+package requestbuffering
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+// ParseSize parses a size of request bodies, as a quantity of bytes such as 10Mi, up to 4Gi.
+func ParseSize(value string) (uint32, error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Value() <= 0 || q.Value() > math.MaxUint32 {
+		return 0, fmt.Errorf("invalid size %q, expected a quantity of bytes from 1 to 4Gi such as 10Mi", value)
+	}
+	return uint32(q.Value()), nil
+}
+
+// Policy buffers the requests of a route, rejecting with 413 those larger than the max request size.
+type Policy struct {
+	// Disabled streams the requests of the route to the upstream, without buffering nor size limit.
+	Disabled bool `json:"disabled,omitempty"`
+	// MaxRequestSize is the max size of the requests buffered, such as 10Mi, PILOT_REQUEST_BUFFER_MAX_SIZE by
+	// default.
+	MaxRequestSize string `json:"maxRequestSize,omitempty"`
+
+	maxRequestBytes uint32
+}
+
+// MaxRequestBytes returns the max request size in bytes, or 0 if the policy does not set it.
+func (p *Policy) MaxRequestBytes() uint32 {
+	return p.maxRequestBytes
+}
+
+func (p *Policy) validate() error {
+	if p.Disabled && p.MaxRequestSize != "" {
+		return fmt.Errorf("a disabled policy may not set the max request size")
+	}
+	if p.MaxRequestSize != "" {
+		size, err := ParseSize(p.MaxRequestSize)
+		if err != nil {
+			return err
+		}
+		p.maxRequestBytes = size
+	}
+	return nil
+}
+
+// Spec is the request buffering of the routes of a virtual service, overriding the one of the gateways.
+type Spec struct {
+	// Policy is the request buffering of all the routes of the virtual service.
+	Policy
+	// Routes are the request buffering of some routes, keyed by the name of the HTTP route, or of the HTTP route and
+	// its match as <route>.<match>. They override Policy.
+	Routes map[string]*Policy `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/request-buffering annotation, as JSON such as
+// {"maxRequestSize": "10Mi", "routes": {"upload": {"disabled": true}}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid request buffering: %v", err)
+	}
+	if err := spec.Policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid request buffering: %v", err)
+	}
+	for name, policy := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid request buffering: route name may not be empty")
+		}
+		if policy == nil {
+			return nil, fmt.Errorf("invalid request buffering of route %s: the policy may not be null", name)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid request buffering of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+// RoutePolicy returns the request buffering of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, else of the virtual service.
+func (s *Spec) RoutePolicy(name string) *Policy {
+	if policy, ok := routes.Lookup(s.Routes, name); ok {
+		return policy
+	}
+	return &s.Policy
+}
@@ -0,0 +1,73 @@
+package requestbuffering
+
+import (
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    uint32
+		wantErr bool
+	}{
+		{value: "10Mi", want: 10 << 20},
+		{value: "1024", want: 1024},
+		{value: "4Gi", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "-1Ki", wantErr: true},
+		{value: "ten", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "default size", value: `{}`},
+		{name: "routes", value: `{"maxRequestSize": "1Mi", "routes": {"upload": {"maxRequestSize": "100Mi"}, "events": {"disabled": true}}}`},
+		{name: "not json", value: "1Mi", wantErr: true},
+		{name: "unknown field", value: `{"maxRequestBytes": 1024}`, wantErr: true},
+		{name: "invalid size", value: `{"maxRequestSize": "1MB"}`, wantErr: true},
+		{name: "invalid route size", value: `{"routes": {"upload": {"maxRequestSize": "0"}}}`, wantErr: true},
+		{name: "disabled with size", value: `{"routes": {"upload": {"disabled": true, "maxRequestSize": "1Mi"}}}`, wantErr: true},
+		{name: "null route policy", value: `{"routes": {"upload": null}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	spec, err := Parse(`{"maxRequestSize": "1Mi", "routes": {"upload": {"maxRequestSize": "100Mi"}, "events": {"disabled": true}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RoutePolicy("api").MaxRequestBytes(); got != 1<<20 {
+		t.Errorf("got max request bytes %d for route api, want 1Mi", got)
+	}
+	if got := spec.RoutePolicy("upload.1").MaxRequestBytes(); got != 100<<20 {
+		t.Errorf("got max request bytes %d for route upload.1, want 100Mi", got)
+	}
+	if got := spec.RoutePolicy("events"); !got.Disabled {
+		t.Error("got route events enabled, want disabled")
+	}
+}
@@ -0,0 +1,25 @@
+// Package routes looks up the per-route overrides of the annotations of virtual services. The routes are keyed by
+// the name of the HTTP route, or of the HTTP route and its match as <route>.<match>, while the Envoy routes are named
+// after both.
+package routes
+
+import "strings"
+
+// Lookup returns the value of the named Envoy route: the one of the route itself, else the one of the longest name
+// prefixing it, as its HTTP route. It returns false if none matches.
+func Lookup[V any](routes map[string]V, name string) (V, bool) {
+	if value, ok := routes[name]; ok {
+		return value, true
+	}
+	longest := ""
+	for route := range routes {
+		if len(route) > len(longest) && strings.HasPrefix(name, route+".") {
+			longest = route
+		}
+	}
+	if longest == "" {
+		var zero V
+		return zero, false
+	}
+	return routes[longest], true
+}
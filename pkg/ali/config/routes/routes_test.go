@@ -0,0 +1,36 @@
+package routes
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	routes := map[string]int{
+		"checkout":       1,
+		"checkout.0":     2,
+		"checkout.0.api": 3,
+		"cart":           4,
+	}
+	cases := []struct {
+		name  string
+		value int
+		found bool
+	}{
+		{name: "checkout", value: 1, found: true},
+		{name: "checkout.0", value: 2, found: true},
+		{name: "checkout.1", value: 1, found: true},
+		{name: "checkout.0.api.1", value: 3, found: true},
+		{name: "cart.2", value: 4, found: true},
+		{name: "checkouts", found: false},
+		{name: "healthz", found: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			value, found := Lookup(routes, c.name)
+			if value != c.value || found != c.found {
+				t.Errorf("got %v, %v, want %v, %v", value, found, c.value, c.found)
+			}
+		})
+	}
+	if _, found := Lookup[int](nil, "checkout"); found {
+		t.Errorf("found a route in no routes")
+	}
+}
@@ -0,0 +1,62 @@
+package sniforwardproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// AnyDomain allows the connections with any SNI to be forwarded.
+const AnyDomain = "*"
+
+// Spec is the dynamic forward proxy of a gateway, transparently proxying the TLS connections to its passthrough
+// servers whose SNI matches no server, to the host named by their SNI.
+type Spec struct {
+	// AllowedDomains are the SNI hosts forwarded, such as "api.example.com" or "*.example.com", or "*" for any.
+	AllowedDomains []string `json:"allowedDomains"`
+	// Port is the port of the upstream hosts, the port of the gateway server if unset.
+	Port uint32 `json:"port,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/sni-forward-proxy annotation.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid sni forward proxy: %v", err)
+	}
+	if len(spec.AllowedDomains) == 0 {
+		return nil, fmt.Errorf("invalid sni forward proxy: allowedDomains is required")
+	}
+	for _, domain := range spec.AllowedDomains {
+		if domain == AnyDomain {
+			continue
+		}
+		var errs []string
+		if strings.HasPrefix(domain, "*.") {
+			errs = validation.IsWildcardDNS1123Subdomain(domain)
+		} else {
+			errs = validation.IsDNS1123Subdomain(domain)
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("invalid sni forward proxy: invalid domain %q: %s", domain, strings.Join(errs, ", "))
+		}
+	}
+	if spec.Port > 65535 {
+		return nil, fmt.Errorf("invalid sni forward proxy: invalid port %d", spec.Port)
+	}
+	return spec, nil
+}
+
+// AllowsAnyDomain returns true if the connections with any SNI are forwarded.
+func (s *Spec) AllowsAnyDomain() bool {
+	for _, domain := range s.AllowedDomains {
+		if domain == AnyDomain {
+			return true
+		}
+	}
+	return false
+}
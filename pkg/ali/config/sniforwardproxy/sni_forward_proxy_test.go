@@ -0,0 +1,71 @@
+package sniforwardproxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    *Spec
+		wantErr bool
+	}{
+		{
+			name:  "domains",
+			value: `{"allowedDomains": ["api.example.com", "*.example.org"]}`,
+			want:  &Spec{AllowedDomains: []string{"api.example.com", "*.example.org"}},
+		},
+		{
+			name:  "any domain and port",
+			value: `{"allowedDomains": ["*"], "port": 8443}`,
+			want:  &Spec{AllowedDomains: []string{"*"}, Port: 8443},
+		},
+		{
+			name:    "not json",
+			value:   "*.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			value:   `{"allowedDomains": ["*"], "deniedDomains": ["example.com"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "no domains",
+			value:   `{"port": 443}`,
+			wantErr: true,
+		},
+		{
+			name:    "infix wildcard",
+			value:   `{"allowedDomains": ["api.*.com"]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			value:   `{"allowedDomains": ["*"], "port": 70000}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowsAnyDomain(t *testing.T) {
+	if (&Spec{AllowedDomains: []string{"*.example.com"}}).AllowsAnyDomain() {
+		t.Errorf("expected a wildcard domain not to allow any domain")
+	}
+	if !(&Spec{AllowedDomains: []string{"example.com", "*"}}).AllowsAnyDomain() {
+		t.Errorf("expected * to allow any domain")
+	}
+}
@@ -0,0 +1,106 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+const (
+	// WebSocket routes upgrade their connections to WebSocket.
+	WebSocket = "websocket"
+	// SSE routes stream server-sent events in their responses.
+	SSE = "sse"
+)
+
+// Policy sets the timeouts of the streaming routes, which are not bounded by the timeout of their route but
+// reset after some idle time.
+type Policy struct {
+	// Disabled leaves the timeouts of the route as is, such as a route detected as streaming.
+	Disabled bool `json:"disabled,omitempty"`
+	// Type is websocket or sse, detected from the matches of the route if unset.
+	Type string `json:"type,omitempty"`
+	// IdleTimeout resets the streams idle for the duration, such as 1h, PILOT_STREAMING_IDLE_TIMEOUT by default. A
+	// zero duration never resets them.
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// MaxStreamDuration bounds the duration of the streams, such as 24h, PILOT_STREAMING_MAX_STREAM_DURATION by
+	// default. A zero duration does not bound them.
+	MaxStreamDuration string `json:"maxStreamDuration,omitempty"`
+}
+
+func (p *Policy) validate() error {
+	if p.Disabled && (p.Type != "" || p.IdleTimeout != "" || p.MaxStreamDuration != "") {
+		return fmt.Errorf("a disabled policy may not set the type or timeouts")
+	}
+	p.Type = strings.ToLower(p.Type)
+	if p.Type != "" && p.Type != WebSocket && p.Type != SSE {
+		return fmt.Errorf("invalid type %q, must be websocket or sse", p.Type)
+	}
+	for name, value := range map[string]string{"idleTimeout": p.IdleTimeout, "maxStreamDuration": p.MaxStreamDuration} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s %q, expected a duration such as 1h", name, value)
+		}
+	}
+	return nil
+}
+
+// Duration returns the parsed duration, or the default if it is not set. The duration is validated by Parse.
+func Duration(value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	d, _ := time.ParseDuration(value)
+	return d
+}
+
+// Spec is the streaming policy of the routes of a virtual service. All its routes are streaming but the disabled
+// ones.
+type Spec struct {
+	// Policy is the streaming policy of all the routes of the virtual service.
+	Policy
+	// Routes are the streaming policies of some routes, keyed by the name of the HTTP route, or of the HTTP route and
+	// its match as <route>.<match>. They override Policy.
+	Routes map[string]*Policy `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/streaming annotation, as JSON such as
+// {"type": "sse", "idleTimeout": "10m", "routes": {"healthz": {"disabled": true}}}. An empty object sets the
+// default timeouts of the streaming routes on all the routes.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid streaming: %v", err)
+	}
+	if err := spec.Policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid streaming: %v", err)
+	}
+	for name, policy := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid streaming: route name may not be empty")
+		}
+		if policy == nil {
+			return nil, fmt.Errorf("invalid streaming of route %s: the policy may not be null", name)
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("invalid streaming of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+// RoutePolicy returns the streaming policy of the named Envoy route: the one of the route itself, else of its HTTP
+// route, the longest name prefixing it, else of the virtual service.
+func (s *Spec) RoutePolicy(name string) *Policy {
+	if policy, ok := routes.Lookup(s.Routes, name); ok {
+		return policy
+	}
+	return &s.Policy
+}
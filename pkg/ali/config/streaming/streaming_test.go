@@ -0,0 +1,58 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "all routes", value: `{}`},
+		{name: "sse", value: `{"type": "SSE", "idleTimeout": "10m", "maxStreamDuration": "24h"}`},
+		{name: "routes", value: `{"type": "websocket", "routes": {"healthz": {"disabled": true}, "events": {"type": "sse"}}}`},
+		{name: "no idle timeout", value: `{"idleTimeout": "0s"}`},
+		{name: "not json", value: "websocket", wantErr: true},
+		{name: "unknown field", value: `{"timeout": "1h"}`, wantErr: true},
+		{name: "invalid type", value: `{"type": "grpc"}`, wantErr: true},
+		{name: "invalid idle timeout", value: `{"idleTimeout": "1 hour"}`, wantErr: true},
+		{name: "negative max stream duration", value: `{"maxStreamDuration": "-1h"}`, wantErr: true},
+		{name: "disabled with type", value: `{"routes": {"api": {"disabled": true, "type": "sse"}}}`, wantErr: true},
+		{name: "null route policy", value: `{"routes": {"api": null}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": {}}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	spec, err := Parse(`{"type": "websocket", "routes": {"healthz": {"disabled": true}, "events": {"type": "sse", "idleTimeout": "5m"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spec.RoutePolicy("chat"); got.Type != WebSocket {
+		t.Errorf("got type %q for route chat, want websocket", got.Type)
+	}
+	if got := spec.RoutePolicy("healthz.0"); !got.Disabled {
+		t.Error("got route healthz.0 enabled, want disabled")
+	}
+	got := spec.RoutePolicy("events")
+	if got.Type != SSE {
+		t.Errorf("got type %q for route events, want sse", got.Type)
+	}
+	if d := Duration(got.IdleTimeout, time.Hour); d != 5*time.Minute {
+		t.Errorf("got idle timeout %v for route events, want 5m", d)
+	}
+	if d := Duration(got.MaxStreamDuration, time.Hour); d != time.Hour {
+		t.Errorf("got max stream duration %v for route events, want the default 1h", d)
+	}
+}
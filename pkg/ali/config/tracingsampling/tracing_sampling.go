@@ -0,0 +1,66 @@
+package tracingsampling
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/ali/config/routes"
+)
+
+// Spec is the trace sampling of the routes of a virtual service, overriding the random sampling percentage of the
+// tracing of the gateways.
+type Spec struct {
+	// Percentage is the random sampling percentage of the routes of the virtual service, on all its hosts.
+	Percentage *float64 `json:"percentage,omitempty"`
+	// Routes are the random sampling percentages of some routes, keyed by the name of the HTTP route, or of the
+	// HTTP route and its match as <route>.<match>. They override Percentage.
+	Routes map[string]float64 `json:"routes,omitempty"`
+}
+
+// Parse parses and validates the value of a higress.io/tracing-sampling annotation, as JSON such as
+// {"percentage": 10, "routes": {"healthz": 0, "checkout": 100}}.
+func Parse(value string) (*Spec, error) {
+	spec := &Spec{}
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid tracing sampling: %v", err)
+	}
+	if spec.Percentage == nil && len(spec.Routes) == 0 {
+		return nil, fmt.Errorf("invalid tracing sampling: a percentage or routes are required")
+	}
+	if spec.Percentage != nil {
+		if err := validatePercentage(*spec.Percentage); err != nil {
+			return nil, fmt.Errorf("invalid tracing sampling: %v", err)
+		}
+	}
+	for name, percentage := range spec.Routes {
+		if name == "" {
+			return nil, fmt.Errorf("invalid tracing sampling: route name may not be empty")
+		}
+		if err := validatePercentage(percentage); err != nil {
+			return nil, fmt.Errorf("invalid tracing sampling of route %s: %v", name, err)
+		}
+	}
+	return spec, nil
+}
+
+func validatePercentage(percentage float64) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("percentage %v must be between 0 and 100", percentage)
+	}
+	return nil
+}
+
+// RoutePercentage returns the random sampling percentage of the named Envoy route: the one of the route itself, else
+// of its HTTP route, the longest name prefixing it, else of the virtual service. It returns false if none is set.
+func (s *Spec) RoutePercentage(name string) (float64, bool) {
+	if percentage, ok := routes.Lookup(s.Routes, name); ok {
+		return percentage, true
+	}
+	if s.Percentage != nil {
+		return *s.Percentage, true
+	}
+	return 0, false
+}
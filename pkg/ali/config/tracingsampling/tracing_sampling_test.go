@@ -0,0 +1,57 @@
+package tracingsampling
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "percentage", value: `{"percentage": 10}`},
+		{name: "routes", value: `{"routes": {"healthz": 0, "checkout": 100}}`},
+		{name: "not json", value: "10", wantErr: true},
+		{name: "empty", value: `{}`, wantErr: true},
+		{name: "unknown field", value: `{"percent": 10}`, wantErr: true},
+		{name: "percentage out of range", value: `{"percentage": 101}`, wantErr: true},
+		{name: "negative route percentage", value: `{"routes": {"healthz": -1}}`, wantErr: true},
+		{name: "empty route name", value: `{"routes": {"": 1}}`, wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRoutePercentage(t *testing.T) {
+	spec, err := Parse(`{"percentage": 10, "routes": {"healthz": 0, "api": 50, "api.errors": 100}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		route string
+		want  float64
+	}{
+		{route: "healthz", want: 0},
+		{route: "api.v1", want: 50},
+		{route: "api.errors", want: 100},
+		{route: "apis", want: 10},
+		{route: "", want: 10},
+	}
+	for _, tt := range cases {
+		if got, ok := spec.RoutePercentage(tt.route); !ok || got != tt.want {
+			t.Errorf("got percentage %v, %v of route %q, want %v", got, ok, tt.route, tt.want)
+		}
+	}
+
+	spec, _ = Parse(`{"routes": {"healthz": 0}}`)
+	if _, ok := spec.RoutePercentage("api"); ok {
+		t.Error("expected no percentage for a route without one and no default")
+	}
+}
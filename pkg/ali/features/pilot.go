@@ -50,6 +50,17 @@ var (
 	OnDemandRDS = env.RegisterBoolVar("ON_DEMAND_RDS", false,
 		"If enabled, the on demand filter will be added to the HCM filters").Get()
 
+	EnableVHDS = env.RegisterBoolVar("PILOT_ENABLE_VHDS", false,
+		"If enabled with ENBALE_SCOPED_RDS disabled, the route configurations of the gateways are sent without "+
+			"their virtual hosts, which the gateways request on demand through VHDS for the hosts actually served. "+
+			"It requires the gateways to use the delta xDS protocol").Get()
+
+	IngressRoutePrecedence = env.RegisterStringVar("PILOT_INGRESS_ROUTE_PRECEDENCE", "istio",
+		"The precedence of the routes generated from the Ingresses for a host: \"istio\" sorts them by decreasing "+
+			"length of their path, the exact path first for the same length, while \"nginx\" matches them as "+
+			"ingress-nginx, the exact paths first, then the prefix paths by decreasing length, then the regex paths "+
+			"of the Ingresses annotated with nginx.ingress.kubernetes.io/use-regex in order").Get()
+
 	DefaultUpstreamConcurrencyThreshold = env.RegisterIntVar("DEFAULT_UPSTREAM_CONCURRENCY_THRESHOLD", math.MaxUint32,
 		"The default threshold of max_requests/max_pending_requests/max_connections of circuit breaker").Get()
 
@@ -61,4 +72,392 @@ var (
 
 	EnablePushAllMcpClusters = env.RegisterBoolVar("ENABLE_PUSH_ALL_MCP_CLUSTERS", true,
 		"If enable, all mcp clusters will push to data plane").Get()
+
+	XdsResourceCacheDir = env.RegisterStringVar("PILOT_XDS_RESOURCE_CACHE_DIR", "",
+		"If set, the last ACKed xDS responses of proxies are persisted under this directory, "+
+			"so they survive pilot restarts").Get()
+
+	XdsResourceCacheRedisAddrs = env.RegisterStringVar("PILOT_XDS_RESOURCE_CACHE_REDIS_ADDRS", "",
+		"Comma separated addresses of the Redis server or cluster nodes persisting the last ACKed xDS responses "+
+			"of proxies, shared by all pilot replicas. Takes precedence over PILOT_XDS_RESOURCE_CACHE_DIR").Get()
+
+	XdsResourceCacheRedisCluster = env.RegisterBoolVar("PILOT_XDS_RESOURCE_CACHE_REDIS_CLUSTER", false,
+		"Whether the xDS resource cache Redis is a cluster. Implied by several PILOT_XDS_RESOURCE_CACHE_REDIS_ADDRS").Get()
+
+	XdsResourceCacheRedisPassword = env.RegisterStringVar("PILOT_XDS_RESOURCE_CACHE_REDIS_PASSWORD", "",
+		"The password used to authenticate to the xDS resource cache Redis").Get()
+
+	XDSCacheEvictionPolicy = env.RegisterStringVar("PILOT_XDS_CACHE_EVICTION_POLICY", "lru",
+		"How entries are displaced from the XDS cache when it is full: lru, or tinylfu to keep the frequently used "+
+			"entries through pushes generating many entries used once").Get()
+
+	XdsResourceCacheTTL = env.RegisterDurationVar("PILOT_XDS_RESOURCE_CACHE_TTL", 24*time.Hour,
+		"How long the last ACKed xDS response of a proxy is kept. Zero keeps responses until evicted by size").Get()
+
+	XdsResourceCacheMaxBytes = env.RegisterIntVar("PILOT_XDS_RESOURCE_CACHE_MAX_BYTES", 256*1024*1024,
+		"The maximum total size of the cached xDS responses, the least recently used are evicted beyond it. "+
+			"Zero means unbounded").Get()
+
+	XdsResourceCacheWarmupConcurrency = env.RegisterIntVar("PILOT_XDS_RESOURCE_CACHE_WARMUP_CONCURRENCY", 8,
+		"The number of cached xDS responses loaded concurrently when pilot starts").Get()
+
+	PushThrottleByType = env.RegisterStringVar("PILOT_PUSH_THROTTLE_BY_TYPE", "",
+		"Comma separated limits of the concurrent pushes of xDS types, as TYPE=LIMIT where TYPE is a short "+
+			"type name such as EDS or a type URL. For example EDS=10 keeps endpoint pushes from using all "+
+			"the PILOT_PUSH_THROTTLE slots").Get()
+
+	PushSizeBudgets = env.RegisterStringVar("PILOT_PUSH_SIZE_BUDGETS", "",
+		"Comma separated budgets of the size of the pushes of xDS types, as TYPE=SIZE where TYPE is a short type "+
+			"name such as RDS or a type URL, and SIZE a quantity of bytes such as 10Mi. Pushes exceeding their "+
+			"budget are still sent, but logged with the largest resources of the push and counted in "+
+			"pilot_xds_push_size_budget_exceeded, as listed by /debug/push_sizes").Get()
+
+	PushPriorityTypes = env.RegisterStringVar("PILOT_PUSH_PRIORITY_TYPES", "",
+		"Comma separated xDS types pushed ahead of the others, either SDS or ECDS. Pushes only triggered by "+
+			"the configs of these types, such as secret rotations, are sent before pending pushes").Get()
+
+	EnableSDSNackFallback = env.RegisterBoolVar("PILOT_ENABLE_SDS_NACK_FALLBACK", false,
+		"If enabled, when a proxy rejects secrets pushed over SotW SDS, the previous version of the secrets it "+
+			"ACKed is sent back to it, so gateways are not left without certificates").Get()
+
+	EnablePushTracing = env.RegisterBoolVar("PILOT_ENABLE_PUSH_TRACING", false,
+		"If enabled, every push to a proxy is traced with a span per pushed xDS type, exported with OpenTelemetry. "+
+			"The exporter is configured by the standard OTEL_EXPORTER_OTLP_* environment variables").Get()
+
+	ProxyGenerationRateLimit = env.RegisterFloatVar("PILOT_PROXY_GENERATION_RATE_LIMIT", 0,
+		"Limits the number of XDS connections per second of a single node ID getting their configs generated. "+
+			"Connections beyond it are rejected, so a proxy reconnecting in a loop can not use all the CPU of pilot. "+
+			"Zero disables the limit").Get()
+
+	ProxyGenerationBurst = env.RegisterIntVar("PILOT_PROXY_GENERATION_BURST", 3,
+		"The number of XDS connections of a single node ID allowed at once beyond PILOT_PROXY_GENERATION_RATE_LIMIT").Get()
+
+	GenerationRateLimit = env.RegisterFloatVar("PILOT_GENERATION_RATE_LIMIT", 0,
+		"Limits the number of XDS connections per second of all nodes getting their configs generated. Connections "+
+			"beyond it wait for up to a second, and are rejected if still beyond it. Zero disables the limit").Get()
+
+	GenerationBurst = env.RegisterIntVar("PILOT_GENERATION_BURST", 10,
+		"The number of XDS connections of all nodes allowed at once beyond PILOT_GENERATION_RATE_LIMIT").Get()
+
+	FilterGatewayEndpoints = env.RegisterBoolVar("PILOT_FILTER_GATEWAY_ENDPOINTS", false,
+		"If enabled, gateways only get the endpoints of the services routed to by the virtual services bound to "+
+			"them, and of the mesh config extension providers. The clusters of the other services get no endpoints. "+
+			"Services only reached otherwise, such as by Wasm plugins, must then be routed to").Get()
+
+	NackQuarantineThreshold = env.RegisterIntVar("PILOT_NACK_QUARANTINE_THRESHOLD", 0,
+		"If positive, a version of a resource rejected this many times by proxies over SotW is quarantined: it is "+
+			"replaced in pushes by the version last ACKed by each proxy, or withheld, so the rest of its type is "+
+			"still updated. Only rejections naming the resource, or of responses with a single resource, count. "+
+			"Zero disables the quarantine").Get()
+
+	NackQuarantineDuration = env.RegisterDurationVar("PILOT_NACK_QUARANTINE_DURATION", time.Hour,
+		"How long after its last rejection a resource stays quarantined").Get()
+
+	PushAuditSink = env.RegisterStringVar("PILOT_PUSH_AUDIT_SINK", "",
+		"If set, every push is recorded for audit, with the updated configs which triggered it, the actors who "+
+			"changed them, the proxies pushed and its outcome. Records are posted as JSON to the URL if it is an "+
+			"http or https URL, or appended as JSON lines to the file at the path otherwise").Get()
+
+	PushAuditActorAnnotations = env.RegisterStringVar("PILOT_PUSH_AUDIT_ACTOR_ANNOTATIONS",
+		"higress.io/last-modified-by,kubernetes.io/change-cause",
+		"Comma separated annotations of the configs holding the actor who last changed them, as recorded in "+
+			"the push audit. The first one set is used").Get()
+
+	EnableWarmStandby = env.RegisterBoolVar("PILOT_ENABLE_WARM_STANDBY", false,
+		"If enabled along with the xDS resource cache, pilot accepts SotW connections while its caches sync, "+
+			"and serves each proxy the configs it last ACKed, read-only, without generating any. Pilot is then "+
+			"reported ready, as degraded. Once synced, these connections are closed so the proxies reconnect and get "+
+			"live configs").Get()
+
+	GatewayFallbackCredentialName = env.RegisterStringVar("PILOT_GATEWAY_FALLBACK_CREDENTIAL_NAME", "",
+		"If set, the credential of the certificate served on the HTTPS ports of the gateways to the clients whose "+
+			"SNI matches none of their servers, which are otherwise reset. The higress.io/fallback-credential-name "+
+			"annotation of a Gateway overrides it").Get()
+
+	SecretAuthorizationAllowlist = env.RegisterStringVar("PILOT_SECRET_AUTHORIZATION_ALLOWLIST", "",
+		"If set, proxies are authorized to read the secrets of their namespace against this comma separated list "+
+			"of namespace/service-account entries, where either part may be *, instead of by SubjectAccessReviews "+
+			"sent to the API server").Get()
+
+	SecretAuthorizationCacheTTL = env.RegisterDurationVar("PILOT_SECRET_AUTHORIZATION_CACHE_TTL", 5*time.Minute,
+		"How long a SubjectAccessReview allowing a proxy to read secrets is cached").Get()
+
+	SecretAuthorizationNegativeCacheTTL = env.RegisterDurationVar("PILOT_SECRET_AUTHORIZATION_NEGATIVE_CACHE_TTL",
+		time.Minute, "How long a SubjectAccessReview denying a proxy to read secrets, or failing, is cached. "+
+			"Zero disables the caching of denials").Get()
+
+	SDSExternalAuthorizer = env.RegisterStringVar("PILOT_SDS_EXTERNAL_AUTHORIZER", "",
+		"If set, the URL of a policy service deciding whether proxies may receive the secrets they request, "+
+			"instead of SubjectAccessReviews. A JSON request with serviceAccount, namespace, secretName and "+
+			"secretNamespace is posted for each secret, and the response must be 200 OK with allowed set to true. "+
+			"Decisions are cached for PILOT_SECRET_AUTHORIZATION_CACHE_TTL, and denials for "+
+			"PILOT_SECRET_AUTHORIZATION_NEGATIVE_CACHE_TTL").Get()
+
+	SDSExternalAuthorizerTimeout = env.RegisterDurationVar("PILOT_SDS_EXTERNAL_AUTHORIZER_TIMEOUT", 5*time.Second,
+		"Timeout of the requests to the external authorizer of secrets").Get()
+
+	SDSMaxSecretSize = env.RegisterIntVar("PILOT_SDS_MAX_SECRET_SIZE", 0,
+		"If positive, the secrets whose certificates, key, OCSP staple and CRL add up to more than this many bytes "+
+			"are not sent to proxies").Get()
+
+	SDSValidateSecrets = env.RegisterBoolVar("PILOT_SDS_VALIDATE_SECRETS", false,
+		"If enabled, the secrets whose certificates, key or CRL are not PEM encoded, or whose key does not match "+
+			"their certificate, are not sent to proxies").Get()
+
+	SDSCredentialDir = env.RegisterStringVar("PILOT_SDS_CREDENTIAL_DIR", "",
+		"If set, the directory of the file://name credentials, such as one mounted by a secret manager CSI driver: "+
+			"each is read from the files of the directory name under it, and pushed again when they change. This "+
+			"works without Kubernetes").Get()
+
+	SDSAWSSecretsManager = env.RegisterBoolVar("PILOT_SDS_AWS_SECRETS_MANAGER", false,
+		"If enabled, the aws-sm://arn credentials are read from AWS Secrets Manager, in the region of their ARN. "+
+			"Pilot authenticates with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or with the role of AWS_ROLE_ARN "+
+			"and the token of AWS_WEB_IDENTITY_TOKEN_FILE").Get()
+
+	SDSAlibabaKMSRegion = env.RegisterStringVar("PILOT_SDS_ALIBABA_KMS_REGION", "",
+		"If set, the alikms://name credentials are read from the secrets manager of Alibaba Cloud KMS in this region. "+
+			"Pilot authenticates with ALIBABA_CLOUD_ACCESS_KEY_ID and ALIBABA_CLOUD_ACCESS_KEY_SECRET, or with the "+
+			"role of ALIBABA_CLOUD_ROLE_ARN and the token of ALIBABA_CLOUD_OIDC_TOKEN_FILE").Get()
+
+	SDSCloudCredentialRefreshInterval = env.RegisterDurationVar("PILOT_SDS_CLOUD_CREDENTIAL_REFRESH_INTERVAL", 5*time.Minute,
+		"Interval at which the credentials read from cloud secret managers are polled, pushing them again once "+
+			"rotated").Get()
+
+	WasmPullSecretNamespaces = env.RegisterStringVar("PILOT_WASM_PULL_SECRET_NAMESPACES", "",
+		"Comma separated list of central namespaces whose secrets may be referenced by the WasmPlugins of other "+
+			"namespaces as image pull secrets, as namespace/name. Such a secret is only used if the service accounts "+
+			"of the plugin namespace are allowed to get it by a SubjectAccessReview. The references to other "+
+			"namespaces are resolved in the plugin namespace").Get()
+
+	WasmKeepVMOnConfigChange = env.RegisterBoolVar("PILOT_WASM_KEEP_VM_ON_CONFIG_CHANGE", true,
+		"If enabled, the VM config of a WasmPlugin is versioned by its spec except its pluginConfig, so that Envoy "+
+			"keeps its VM and in-memory state when only the pluginConfig changes, and only configures the plugin "+
+			"again. Otherwise, the modules pulled with the Always policy are pulled again on every update").Get()
+
+	WasmIsolateMetrics = env.RegisterBoolVar("PILOT_WASM_ISOLATE_METRICS", false,
+		"If enabled, the WasmPlugins are told by the HIGRESS_WASM_METRIC_PREFIX VM environment variable to define "+
+			"their metrics under the prefix wasm.<namespace>.<name>., so that the metrics of different plugins "+
+			"never collide").Get()
+
+	WasmMaxMetrics = env.RegisterIntVar("PILOT_WASM_MAX_METRICS", 0,
+		"If positive, the WasmPlugins are told by the HIGRESS_WASM_MAX_METRICS VM environment variable to define at "+
+			"most this many metrics, guarding the stats cardinality of shared gateways against misbehaving plugins").Get()
+
+	WasmAllowedMetrics = env.RegisterStringVar("PILOT_WASM_ALLOWED_METRICS", "",
+		"If set, a regular expression the names of the metrics the WasmPlugins may define must match, passed by "+
+			"the HIGRESS_WASM_ALLOWED_METRICS VM environment variable. The metric prefix is not part of the names").Get()
+
+	RateLimitServiceAddress = env.RegisterStringVar("PILOT_RATE_LIMIT_SERVICE_ADDRESS", "",
+		"If set, the host:port of a gRPC global rate limit service. The gateways get a cluster of it and a rate "+
+			"limit filter calling it with the descriptors generated by the higress.io/rate-limit annotation of the "+
+			"virtual services on their routes").Get()
+
+	RateLimitServiceDomain = env.RegisterStringVar("PILOT_RATE_LIMIT_SERVICE_DOMAIN", "higress",
+		"Domain of the requests to the global rate limit service").Get()
+
+	RateLimitServiceTimeout = env.RegisterDurationVar("PILOT_RATE_LIMIT_SERVICE_TIMEOUT", 20*time.Millisecond,
+		"Timeout of the requests to the global rate limit service").Get()
+
+	RateLimitFailureModeDeny = env.RegisterBoolVar("PILOT_RATE_LIMIT_FAILURE_MODE_DENY", false,
+		"If enabled, the requests are rejected when the global rate limit service fails or times out, instead of "+
+			"being allowed").Get()
+
+	RateLimitServiceMTLS = env.RegisterBoolVar("PILOT_RATE_LIMIT_SERVICE_MTLS", true,
+		"If enabled, the gateways connect to the global rate limit service by mTLS, with their workload certificate "+
+			"and the mesh root certificate managed by SDS").Get()
+
+	RateLimitServiceSubjectAltNames = env.RegisterStringVar("PILOT_RATE_LIMIT_SERVICE_SUBJECT_ALT_NAMES", "",
+		"Comma separated list of the subject alternative names the certificate of the global rate limit service "+
+			"must have one of with PILOT_RATE_LIMIT_SERVICE_MTLS, such as its SPIFFE identity. Any certificate "+
+			"issued by the mesh root is accepted if unset").Get()
+
+	ExtProcServiceAddress = env.RegisterStringVar("PILOT_EXT_PROC_SERVICE_ADDRESS", "",
+		"If set, the host:port of a gRPC external processor. The gateways get a cluster of it and an ext_proc "+
+			"filter calling it, enabled on the routes of the virtual services with the higress.io/ext-proc "+
+			"annotation").Get()
+
+	ExtProcMessageTimeout = env.RegisterDurationVar("PILOT_EXT_PROC_MESSAGE_TIMEOUT", 200*time.Millisecond,
+		"Timeout of the responses of the external processor to each message").Get()
+
+	ExtProcFailureModeAllow = env.RegisterBoolVar("PILOT_EXT_PROC_FAILURE_MODE_ALLOW", false,
+		"If enabled, the requests are processed further when the external processor fails or times out, instead "+
+			"of being rejected").Get()
+
+	ExtProcProcessingMode = env.RegisterStringVar("PILOT_EXT_PROC_PROCESSING_MODE", "",
+		"The processing mode of the routes enabling the external processor, as a JSON object with the \"requestHeaders\", "+
+			"\"responseHeaders\", \"requestTrailers\" and \"responseTrailers\" modes, default, send or skip, "+
+			"and the \"requestBody\" and \"responseBody\" modes, none, streamed, buffered or buffered_partial. "+
+			"The headers are sent and the bodies and trailers are not by default. The higress.io/ext-proc "+
+			"annotations override it").Get()
+
+	ExtProcServiceMTLS = env.RegisterBoolVar("PILOT_EXT_PROC_SERVICE_MTLS", true,
+		"If enabled, the gateways connect to the external processor by mTLS, with their workload certificate "+
+			"and the mesh root certificate managed by SDS").Get()
+
+	ExtProcServiceSubjectAltNames = env.RegisterStringVar("PILOT_EXT_PROC_SERVICE_SUBJECT_ALT_NAMES", "",
+		"Comma separated list of the subject alternative names the certificate of the external processor must "+
+			"have one of with PILOT_EXT_PROC_SERVICE_MTLS, such as its SPIFFE identity. Any certificate issued by "+
+			"the mesh root is accepted if unset").Get()
+
+	EnableGrpcJSONTranscoding = env.RegisterBoolVar("PILOT_ENABLE_GRPC_JSON_TRANSCODING", false,
+		"If enabled, the gateways get a grpc_json_transcoder filter, enabled on the routes of the virtual services "+
+			"with the higress.io/grpc-json-transcoding annotation with the proto descriptor sets it references").Get()
+
+	GrpcDescriptorSetRefreshInterval = env.RegisterDurationVar("PILOT_GRPC_DESCRIPTOR_SET_REFRESH_INTERVAL", time.Minute,
+		"How often the proto descriptor sets of the gRPC-JSON transcoding are fetched again, the routes being "+
+			"updated when they change").Get()
+
+	EnableStreamingDetection = env.RegisterBoolVar("PILOT_ENABLE_STREAMING_DETECTION", false,
+		"If enabled, the gateway routes matching an Upgrade header to websocket or an Accept header of "+
+			"text/event-stream get the timeouts of the streaming routes, as with the higress.io/streaming "+
+			"annotation").Get()
+
+	StreamingIdleTimeout = env.RegisterDurationVar("PILOT_STREAMING_IDLE_TIMEOUT", time.Hour,
+		"The idle timeout of the streams of the streaming routes, whose route timeout is disabled. A zero "+
+			"duration never resets the idle streams").Get()
+
+	StreamingMaxStreamDuration = env.RegisterDurationVar("PILOT_STREAMING_MAX_STREAM_DURATION", 0,
+		"If set, the max duration of the streams of the streaming routes").Get()
+
+	RequestBufferMaxSize = env.RegisterStringVar("PILOT_REQUEST_BUFFER_MAX_SIZE", "",
+		"If set, the max size of the requests, such as 10Mi, buffered by the gateways before they are sent "+
+			"upstream, the larger ones being rejected with 413. The higress.io/request-buffering annotations "+
+			"override it on the routes of the virtual services, and the streaming routes are never buffered").Get()
+
+	CORSDefaults = env.RegisterStringVar("PILOT_CORS_DEFAULTS", "",
+		"If set, the default CORS policy of the gateway routes, as a JSON object with a VirtualService CORS "+
+			"\"policy\" and how the CORS policies of the routes \"merge\" with it: \"fields\", the default, "+
+			"taking the fields they do not set from it, or \"replace\", replacing it").Get()
+
+	ClusterDefaults = env.RegisterStringVar("PILOT_CLUSTER_DEFAULTS", "",
+		"If set, the default resilience settings of the outbound clusters, as a JSON object with a DestinationRule "+
+			"\"connectionPool\" merged field by field under the connection pool of the DestinationRules, and a "+
+			"\"retryBudget\" with the \"budgetPercent\" and \"minRetryConcurrency\" of the concurrent retries, "+
+			"overridden by the higress.io/retry-budget annotation of the DestinationRules. The per-try timeouts of the "+
+			"routes default to the perTryTimeout of the defaultHttpRetryPolicy of the mesh config").Get()
+
+	OutlierEventRetention = env.RegisterDurationVar("PILOT_OUTLIER_EVENT_RETENTION", 10*time.Minute,
+		"How long the hosts ejected by the outlier detection of the gateways, as reported by their agents with "+
+			"OUTLIER_EVENT_REPORT_URL, are remembered by /debug/outlierz after their last reported event. A proxy "+
+			"not reporting a host back within it no longer ejects it").Get()
+
+	WasmStatsRetention = env.RegisterDurationVar("PILOT_WASM_STATS_RETENTION", 5*time.Minute,
+		"How long the Wasm stats of a proxy, as reported by its agent with WASM_STATS_REPORT_URL, are kept by "+
+			"/debug/wasm_statsz after its last report").Get()
+
+	NacosRegistry = env.RegisterStringVar("PILOT_NACOS_REGISTRY", "",
+		"The Nacos registry synced as ServiceEntries when Nacos is one of the registries of pilot, as a JSON object "+
+			"with the \"servers\" base URLs, the \"namespaceId\", the \"groups\" (DEFAULT_GROUP by default), the "+
+			"\"username\" and \"password\" if the authentication of Nacos is enabled, and the \"refreshInterval\" "+
+			"(10s by default)").Get()
+
+	ConsulRegistry = env.RegisterStringVar("PILOT_CONSUL_REGISTRY", "",
+		"The Consul registry synced as ServiceEntries when Consul is one of the registries of pilot, as a JSON object "+
+			"with the \"address\" of its HTTP API, the \"datacenters\" (all by default), the ACL \"token\" and the "+
+			"\"refreshInterval\" (30s by default) completing the watches of the catalog").Get()
+
+	EurekaRegistry = env.RegisterStringVar("PILOT_EUREKA_REGISTRY", "",
+		"The Eureka registry synced as ServiceEntries when Eureka is one of the registries of pilot, as a JSON object "+
+			"with the \"servers\" service URLs, the \"hosts\" of the applications keyed by application name, the "+
+			"Kubernetes services of which get the instances of the applications as endpoints, the \"portName\" "+
+			"of the applications (http by default) and the \"refreshInterval\" (30s by default)").Get()
+
+	ZookeeperRegistry = env.RegisterStringVar("PILOT_ZOOKEEPER_REGISTRY", "",
+		"The Dubbo providers registered in ZooKeeper synced as ServiceEntries when Zookeeper is one of the "+
+			"registries of pilot, as a JSON object with the \"servers\" addresses, the \"root\" of the Dubbo "+
+			"registry (/dubbo by default), the \"interfaces\" synced (all by default) and the \"refreshInterval\" "+
+			"(30s by default)").Get()
+
+	DNSResolvers = env.RegisterStringVar("PILOT_DNS_RESOLVERS", "",
+		"If set, the DNS resolvers of the STRICT_DNS and LOGICAL_DNS clusters instead of the resolvers of the "+
+			"system of the proxies, as a comma separated list of IP addresses with optional ports, prefixed by "+
+			"udp:// or tcp://, or \"dot\" to resolve with DNS-over-TLS through the forwarder of the agents, which "+
+			"forwards to their DNS_OVER_TLS_RESOLVERS. Overridden by the higress.io/dns-resolvers annotation of the "+
+			"ServiceEntries").Get()
+
+	WorkloadEntryActiveHealthChecks = env.RegisterBoolVar("PILOT_ENABLE_WORKLOAD_ENTRY_ACTIVE_HEALTHCHECKS", false,
+		"If enabled with PILOT_ENABLE_WORKLOAD_ENTRY_HEALTHCHECKS, pilot runs the HTTP and TCP readiness probes of "+
+			"the WorkloadGroups against the WorkloadEntries it auto-registered instead of relying on the health "+
+			"reported by their agents, and keeps the unhealthy WorkloadEntries as UNHEALTHY endpoints in EDS rather "+
+			"than removing them").Get()
+
+	ServiceEntryEndpointShardSize = env.RegisterIntVar("PILOT_SERVICE_ENTRY_ENDPOINT_SHARD_SIZE", 1000,
+		"The number of endpoints from which the endpoints of the STATIC ServiceEntries are split into shards, "+
+			"so that updating some endpoints only converts and indexes again the shards of these endpoints, and "+
+			"does not push EDS if none changed. Zero disables the sharding").Get()
+
+	MCPServerKinds = env.RegisterStringVar("PILOT_MCP_SERVER_KINDS", "",
+		"The comma separated kinds of the configs, such as VirtualService,WasmPlugin,ServiceEntry, served over xDS "+
+			"to the downstream control planes connecting with the mcp generator, each filtered by the namespaces "+
+			"in its MCP_NAMESPACES node metadata and the label selector in its MCP_LABEL_SELECTOR node metadata. "+
+			"No config is served by default").Get()
+
+	XDSConfigSourceFailover = env.RegisterBoolVar("PILOT_XDS_CONFIG_SOURCE_FAILOVER", false,
+		"If enabled, the xds:// config sources of the mesh config are alternatives rather than merged: the configs "+
+			"of the first connected and synced of them by priority are used, set by the priority query parameter "+
+			"of their address (their order by default), failing over to the next ones during the outages of the "+
+			"preferred ones and failing back when they recover").Get()
+
+	XDSConfigSourceHealthCheckInterval = env.RegisterDurationVar("PILOT_XDS_CONFIG_SOURCE_HEALTH_CHECK_INTERVAL",
+		5*time.Second, "The interval of the health checks of the xds:// config sources failing over").Get()
+
+	ConfigSourceTransforms = env.RegisterStringVar("PILOT_CONFIG_SOURCE_TRANSFORMS", "",
+		"The transformations of the configs of the fs:// and xds:// config sources, as a JSON array of rules applied "+
+			"in order, each matching the configs by \"sources\" addresses or prefixes, \"kinds\", \"namespaces\" "+
+			"and label \"selector\", and either dropping them with \"drop\", or setting their \"labels\" (removed "+
+			"if empty), moving them to a \"namespace\" and setting the \"defaults\" JSON fields of their specs "+
+			"when unset").Get()
+
+	ShadowPushSource = env.RegisterStringVar("PILOT_SHADOW_PUSH_SOURCE", "",
+		"The URL of the debug HTTP server of the stable revision of pilot, such as http://istiod.istio-system:8080. "+
+			"If set, this revision runs in shadow mode: it periodically generates the xDS of the proxies connected "+
+			"to the stable revision without sending it, compares it with the xDS of the stable revision, and "+
+			"exports the divergences as metrics and by /debug/shadowz").Get()
+
+	ShadowPushInterval = env.RegisterDurationVar("PILOT_SHADOW_PUSH_INTERVAL", time.Minute,
+		"The interval of the shadow comparison rounds").Get()
+
+	ShadowPushMaxProxies = env.RegisterIntVar("PILOT_SHADOW_PUSH_MAX_PROXIES", 50,
+		"The maximum number of proxies compared by a shadow comparison round, the next rounds comparing the "+
+			"next proxies. Zero compares all proxies").Get()
+
+	ConsistencyCheckInterval = env.RegisterDurationVar("PILOT_CONSISTENCY_CHECK_INTERVAL", 0,
+		"If positive, the interval at which pilot generates the xDS of a random sample of the connected proxies "+
+			"again, twice without caches and once through the xDS cache serving the pushes, and exports the "+
+			"resources generated differently as metrics and by /debug/consistencyz. Such differences are "+
+			"nondeterministic or stale generations, which cause needless pushes").Get()
+
+	ConsistencyCheckSampleSize = env.RegisterIntVar("PILOT_CONSISTENCY_CHECK_SAMPLE_SIZE", 5,
+		"The number of connected proxies checked by each consistency check round").Get()
+
+	AccessLogCollectorBufferSize = env.RegisterIntVar("PILOT_ACCESS_LOG_COLLECTOR_BUFFER_SIZE", 0,
+		"If positive, pilot serves the Envoy gRPC access log service on its xDS ports, keeping the last access "+
+			"logs of each proxy streaming to it, up to this number, for /debug/accesslogz and istioctl x "+
+			"access-log. It is meant for short debugging sessions, pointing the proxies at pilot with an "+
+			"envoyHttpAls or envoyTcpAls extension provider").Get()
+
+	AccessLogFormats = env.RegisterStringVar("PILOT_ACCESS_LOG_FORMATS", "",
+		"The named JSON access log formats of the mesh, as a JSON object of formats by name, each with the "+
+			"\"fields\" of the access logs, as a list of fields with a \"name\" and either the \"value\" of a "+
+			"command operator format string or a \"cel\" expression, \"includeDefaults\" to add the fields "+
+			"of the default JSON format and \"omitEmptyValues\". The Telemetry resources reference them with "+
+			"the higress.io/access-log-format annotation, formatting the file access logs of their providers").Get()
+
+	EnableSDSTrustDomainMapping = env.RegisterBoolVar("PILOT_ENABLE_SDS_TRUST_DOMAIN_MAPPING", false,
+		"If enabled, the CA secrets served to the east-west gateways, the gateways of the networks with gateways "+
+			"in MeshNetworks, also accept the SPIFFE SANs of their mutual TLS servers rewritten for the trust domain "+
+			"and the trustDomainAliases of the mesh config, so that the clients of the clusters federated with "+
+			"other trust domains are accepted").Get()
+
+	ServiceLocalityLbSettings = env.RegisterStringVar("PILOT_SERVICE_LOCALITY_LB_SETTINGS", "",
+		"The locality load balancer settings of the mesh for some services, as a JSON object of settings in the "+
+			"format of the localityLbSetting of the mesh config, such as {\"*.ns.svc.cluster.local\": "+
+			"{\"failoverPriority\": [\"topology.istio.io/network\", \"topology.kubernetes.io/region\"]}}, by "+
+			"service host or wildcard host, the most specific applying. They override the localityLbSetting of "+
+			"the mesh config, and are overridden by the destination rules").Get()
+
+	BodyTransformationPlugin = env.RegisterStringVar("PILOT_BODY_TRANSFORMATION_PLUGIN", "",
+		"The WasmPlugin transforming the JSON bodies of the requests and responses, as namespace.name, such as "+
+			"higress-system.transformer. The higress.io/body-transformation annotations of the virtual services "+
+			"configure it on their routes, enabling it there even if the virtual service does not select it").Get()
 )
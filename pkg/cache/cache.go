@@ -17,6 +17,7 @@
 package cache
 
 import (
+	"fmt"
 	"time"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
@@ -40,6 +41,29 @@ type XdsResourceCache interface {
 	// Store xds resource into store base on the ack discovery request.
 	// Caller should make sure this discovery request is ack request.
 	Store(req *discovery.DiscoveryRequest) error
+
+	// AddDelta records a delta response sent to the node. Once ACKed, the versions of the resources
+	// it carries are merged into the resource versions cached for the node.
+	AddDelta(node string, resp *discovery.DeltaDiscoveryResponse) error
+
+	// StoreDelta merges the delta response ACKed by the request into the resource versions cached
+	// for the node. Delta requests only carry the node on the first request of a stream, so it is
+	// passed explicitly.
+	StoreDelta(node string, req *discovery.DeltaDiscoveryRequest) error
+
+	// LoadDeltaVersions returns the versions of the resources of the given type last ACKed by the
+	// node over delta xDS, keyed by resource name, or nil if nothing is cached.
+	LoadDeltaVersions(node, typeURL string) (map[string]string, error)
+
+	// Flush waits for the ACKed responses to be written to the store. Responses are written
+	// asynchronously, so Store and StoreDelta do not wait for the store.
+	Flush()
+
+	// Stats returns information about the efficiency of the cache.
+	Stats() Stats
+
+	// Entries returns information about the cached responses, sorted by node and type.
+	Entries() []XdsCacheEntry
 }
 
 // Stats returns usage statistics about an individual cache, useful to assess the
@@ -86,9 +110,7 @@ type Stats struct {
 type Cache interface {
 	// Ideas for the future:
 	//   - Return the number of entries in the cache in stats.
-	//   - Provide an eviction callback to know when entries are evicted.
 	//   - Have Set and Remove return the previous value for the key, if any.
-	//   - Have Get return the expiration time for entries.
 
 	// Set inserts an entry in the cache. This will replace any entry with
 	// the same key that is already in the cache. The entry may be automatically
@@ -121,6 +143,71 @@ type ExpiringCache interface {
 	// requested expiration time.
 	SetWithExpiration(key any, value any, expiration time.Duration)
 
+	// GetWithExpiration retrieves the value associated with the supplied key if the key
+	// is present in the cache, along with the time left before the entry expires. Since
+	// expired entries are evicted periodically, the remaining time can be negative.
+	GetWithExpiration(key any) (value any, remaining time.Duration, ok bool)
+
 	// EvictExpired() synchronously evicts all expired entries from the cache
 	EvictExpired()
 }
+
+// EvictionReason tells why an entry left a cache.
+type EvictionReason int
+
+const (
+	// Expired means the entry reached its expiration time.
+	Expired EvictionReason = iota
+	// Evicted means the entry was displaced to make room for another one.
+	Evicted
+	// Removed means the entry was explicitly removed from the cache.
+	Removed
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case Expired:
+		return "expired"
+	case Evicted:
+		return "evicted"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// EvictionListener is invoked whenever an entry leaves a cache, so the resources associated
+// with the entry can be released. Listeners are never invoked while the cache is locked, so
+// they may call back into the cache. Replacing the value of a key does not invoke the listener.
+type EvictionListener func(key, value any, reason EvictionReason)
+
+// EvictionPolicy selects how a bounded cache chooses the entries to displace when it is full.
+type EvictionPolicy int
+
+const (
+	// LRUPolicy displaces the least recently used entry, see NewLRU.
+	LRUPolicy EvictionPolicy = iota
+	// TinyLFUPolicy only admits new entries used more often than the entry they would
+	// displace, which keeps hot entries in the cache through scans, see NewTinyLFU.
+	TinyLFUPolicy
+)
+
+// ParseEvictionPolicy parses the name of an eviction policy, lru or tinylfu.
+func ParseEvictionPolicy(name string) (EvictionPolicy, error) {
+	switch name {
+	case "lru":
+		return LRUPolicy, nil
+	case "tinylfu":
+		return TinyLFUPolicy, nil
+	}
+	return LRUPolicy, fmt.Errorf("unknown eviction policy %q, must be lru or tinylfu", name)
+}
+
+// NewBounded creates a cache holding up to maxEntries entries, displaced according to policy.
+// See NewLRU for the meaning of the other parameters.
+func NewBounded(policy EvictionPolicy, defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	if policy == TinyLFUPolicy {
+		return NewTinyLFU(defaultExpiration, evictionInterval, maxEntries)
+	}
+	return NewLRU(defaultExpiration, evictionInterval, maxEntries)
+}
@@ -237,6 +237,67 @@ func testCacheEvictExpired(c ExpiringCache, t *testing.T) {
 	}
 }
 
+type evictionRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *evictionRecorder) listener(key, value any, reason EvictionReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, key.(string)+"="+value.(string)+":"+reason.String())
+}
+
+func (r *evictionRecorder) check(t *testing.T, want ...string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) != len(want) {
+		t.Fatalf("got eviction events %v, want %v", r.events, want)
+	}
+	for i := range want {
+		if r.events[i] != want[i] {
+			t.Fatalf("got eviction events %v, want %v", r.events, want)
+		}
+	}
+	r.events = nil
+}
+
+func testCacheEvictionListener(c ExpiringCache, r *evictionRecorder, t *testing.T) {
+	c.SetWithExpiration("A", "a", 1*time.Millisecond)
+	c.Set("B", "b")
+	c.Set("C", "c")
+	// replacing a value is not an eviction
+	c.Set("C", "c")
+	r.check(t)
+
+	time.Sleep(10 * time.Millisecond)
+	c.EvictExpired()
+	r.check(t, "A=a:expired")
+
+	c.Remove("B")
+	c.Remove("B")
+	r.check(t, "B=b:removed")
+
+	c.RemoveAll()
+	r.check(t, "C=c:removed")
+}
+
+func testCacheGetWithExpiration(c ExpiringCache, t *testing.T) {
+	if _, _, ok := c.GetWithExpiration("A"); ok {
+		t.Error("Got an entry, expecting it to be missing")
+	}
+
+	c.SetWithExpiration("A", "a", time.Hour)
+	value, remaining, ok := c.GetWithExpiration("A")
+	if !ok || value != "a" {
+		t.Errorf("Got %v, %v, expecting a, true", value, ok)
+	}
+	if remaining <= 0 || remaining > time.Hour {
+		t.Errorf("Got remaining time %v, expecting it to be within (0, 1h]", remaining)
+	}
+}
+
 func testCacheEvicter(c ExpiringCache) {
 	c.SetWithExpiration("A", "A", 1*time.Millisecond)
 
@@ -371,3 +432,33 @@ func benchmarkCacheSetRemove(c Cache, b *testing.B) {
 		c.Remove(name)
 	}
 }
+
+// benchmarkCacheGetSetGoroutines spreads a mostly-read workload over many keys and goroutines,
+// to measure lock contention.
+func benchmarkCacheGetSetGoroutines(c Cache, goroutines int, b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "foo" + strconv.Itoa(i)
+		c.Set(keys[i], "bar")
+	}
+
+	wg := new(sync.WaitGroup)
+	each := b.N / goroutines
+	wg.Add(goroutines)
+
+	b.ResetTimer()
+	for i := 0; i < goroutines; i++ {
+		go func(offset int) {
+			for j := 0; j < each; j++ {
+				key := keys[(offset+j)%len(keys)]
+				if j%8 == 0 {
+					c.Set(key, "bar")
+				} else {
+					c.Get(key)
+				}
+			}
+			wg.Done()
+		}(i * 97)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,41 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"hash/maphash"
+	"strconv"
+)
+
+// hashKey hashes a cache key. Strings and integers are hashed directly, other keys are
+// hashed through their fmt representation, which is considerably slower, so callers
+// with struct keys should prefer converting them to strings.
+func hashKey(seed maphash.Seed, key any) uint64 {
+	switch k := key.(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return maphash.String(seed, strconv.FormatInt(int64(k), 10))
+	case int32:
+		return maphash.String(seed, strconv.FormatInt(int64(k), 10))
+	case int64:
+		return maphash.String(seed, strconv.FormatInt(k, 10))
+	case uint64:
+		return maphash.String(seed, strconv.FormatUint(k, 10))
+	default:
+		return maphash.String(seed, fmt.Sprintf("%#v", k))
+	}
+}
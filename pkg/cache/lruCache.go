@@ -86,6 +86,7 @@ type lruCache struct {
 	stopEvicter       chan bool
 	baseTimeNanos     int64
 	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	listener          EvictionListener
 }
 
 // lruEntry is used to hold a value in the ordered lru list represented by the entry slice
@@ -117,10 +118,19 @@ const sentinelIndex = 0
 // evictionInterval specifies the frequency at which eviction activities take
 // place. This should likely be >= 1 second.
 func NewLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	return NewLRUWithListener(defaultExpiration, evictionInterval, maxEntries, func(key, value any, reason EvictionReason) {})
+}
+
+// NewLRUWithListener creates a new cache with an LRU and time-based eviction model that will invoke
+// the supplied listener whenever an entry expires, is displaced or is removed. See also: NewLRU.
+func NewLRUWithListener(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	listener EvictionListener,
+) ExpiringCache {
 	c := &lruCache{
 		entries:           make([]lruEntry, maxEntries+1),
 		lookup:            make(map[any]int32, maxEntries),
 		defaultExpiration: defaultExpiration,
+		listener:          listener,
 	}
 
 	// create the linked list of entries
@@ -184,8 +194,12 @@ func (c *lruCache) evictExpired(t time.Time) {
 
 		c.Lock()
 		if ent.expiration <= n {
+			key, value := ent.key, ent.value
 			c.remove(i)
 			c.stats.Evictions++
+			c.Unlock()
+			c.listener(key, value, Expired)
+			continue
 		}
 		c.Unlock()
 	}
@@ -229,11 +243,13 @@ func (c *lruCache) SetWithExpiration(key any, value any, expiration time.Duratio
 
 	c.Lock()
 
+	var evictedKey, evictedValue any
 	index, ok := c.lookup[key]
 	if !ok {
 		// reclaim the tail entry
 		index = c.sentinel.prev
-		delete(c.lookup, c.entries[index].key)
+		evictedKey, evictedValue = c.entries[index].key, c.entries[index].value
+		delete(c.lookup, evictedKey)
 		c.lookup[key] = index
 	}
 
@@ -247,6 +263,10 @@ func (c *lruCache) SetWithExpiration(key any, value any, expiration time.Duratio
 	c.stats.Writes++
 
 	c.Unlock()
+
+	if evictedKey != nil {
+		c.listener(evictedKey, evictedValue, Evicted)
+	}
 }
 
 func (c *lruCache) Get(key any) (any, bool) {
@@ -268,15 +288,46 @@ func (c *lruCache) Get(key any) (any, bool) {
 	return value, ok
 }
 
+func (c *lruCache) GetWithExpiration(key any) (any, time.Duration, bool) {
+	c.Lock()
+
+	var value any
+	var expiration int64
+	index, ok := c.lookup[key]
+	if ok {
+		c.unlinkEntry(index)
+		c.linkEntryAtHead(index)
+		value = c.entries[index].value
+		expiration = c.entries[index].expiration
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+
+	c.Unlock()
+
+	if !ok {
+		return nil, 0, false
+	}
+	return value, time.Duration(expiration - time.Now().UnixNano()), true
+}
+
 func (c *lruCache) Remove(key any) {
 	c.Lock()
 
-	if index, ok := c.lookup[key]; ok {
+	index, ok := c.lookup[key]
+	var value any
+	if ok {
+		value = c.entries[index].value
 		c.remove(index)
 		c.stats.Removals++
 	}
 
 	c.Unlock()
+
+	if ok {
+		c.listener(key, value, Removed)
+	}
 }
 
 func (c *lruCache) RemoveAll() {
@@ -285,8 +336,12 @@ func (c *lruCache) RemoveAll() {
 
 		c.Lock()
 		if ent.key != nil {
+			key, value := ent.key, ent.value
 			c.remove(int32(i))
 			c.stats.Removals++
+			c.Unlock()
+			c.listener(key, value, Removed)
+			continue
 		}
 		c.Unlock()
 	}
@@ -309,6 +364,13 @@ func (c *lruCache) Stats() Stats {
 	return c.stats
 }
 
+// Len returns the number of entries in the cache.
+func (c *lruCache) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.lookup)
+}
+
 /* debugging aid
 func (c *lruCache) dumpList(banner string) {
 	fmt.Printf("%s\n", banner)
@@ -44,6 +44,23 @@ func TestLRUEvictExpired(t *testing.T) {
 	testCacheEvictExpired(lru, t)
 }
 
+func TestLRUEvictionListener(t *testing.T) {
+	r := &evictionRecorder{}
+	lru := NewLRUWithListener(5*time.Second, 0, 500, r.listener)
+	testCacheEvictionListener(lru, r, t)
+
+	// displacing the least recently used entry reports it as evicted
+	lru = NewLRUWithListener(5*time.Second, 0, 1, r.listener)
+	lru.Set("A", "a")
+	lru.Set("B", "b")
+	r.check(t, "A=a:evicted")
+}
+
+func TestLRUGetWithExpiration(t *testing.T) {
+	lru := NewLRU(5*time.Second, 0, 500)
+	testCacheGetWithExpiration(lru, t)
+}
+
 func TestLRUFinalizer(t *testing.T) {
 	lru := NewLRU(5*time.Second, 1*time.Millisecond, 500).(*lruWrapper)
 	testCacheFinalizer(&lru.evicterTerminated)
@@ -111,3 +128,13 @@ func BenchmarkLRUSetRemove(b *testing.B) {
 	c := NewLRU(5*time.Minute, 1*time.Minute, 500)
 	benchmarkCacheSetRemove(c, b)
 }
+
+func BenchmarkLRUGetSet64Goroutines(b *testing.B) {
+	c := NewLRU(5*time.Minute, 1*time.Minute, 2048)
+	benchmarkCacheGetSetGoroutines(c, 64, b)
+}
+
+func BenchmarkLRUGetSet256Goroutines(b *testing.B) {
+	c := NewLRU(5*time.Minute, 1*time.Minute, 2048)
+	benchmarkCacheGetSetGoroutines(c, 256, b)
+}
@@ -0,0 +1,91 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"istio.io/istio/pkg/monitoring"
+)
+
+var (
+	cacheNameTag = monitoring.CreateLabel("cache")
+
+	cacheHits = monitoring.NewDerivedSum(
+		"cache_hits",
+		"Total number of lookups that found an entry in the cache.",
+	)
+
+	cacheMisses = monitoring.NewDerivedSum(
+		"cache_misses",
+		"Total number of lookups that did not find an entry in the cache.",
+	)
+
+	cacheEvictions = monitoring.NewDerivedSum(
+		"cache_evictions",
+		"Total number of entries evicted from the cache.",
+	)
+
+	cacheEntries = monitoring.NewDerivedGauge(
+		"cache_entries",
+		"Number of entries currently in the cache.",
+	)
+
+	cacheBytes = monitoring.NewDerivedGauge(
+		"cache_size_bytes",
+		"Size in bytes of the entries currently in the cache.",
+	)
+)
+
+// StatsProvider is implemented by every cache in this package.
+type StatsProvider interface {
+	Stats() Stats
+}
+
+// entryCounter is implemented by caches able to tell how many entries they hold.
+type entryCounter interface {
+	Len() int
+}
+
+// byteCounter is implemented by caches able to tell the size of the entries they hold.
+type byteCounter interface {
+	Bytes() int
+}
+
+// RegisterMetrics exports the statistics of c as metrics labeled with the given cache name.
+// The number of entries and their size are only exported for caches able to report them.
+//
+// The metrics keep c alive, so this is meant for caches living as long as the process.
+// Registering another cache under the same name replaces the previous one.
+func RegisterMetrics(name string, c StatsProvider) {
+	label := cacheNameTag.Value(name)
+	cacheHits.ValueFrom(func() float64 {
+		return float64(c.Stats().Hits)
+	}, label)
+	cacheMisses.ValueFrom(func() float64 {
+		return float64(c.Stats().Misses)
+	}, label)
+	cacheEvictions.ValueFrom(func() float64 {
+		return float64(c.Stats().Evictions)
+	}, label)
+	if ec, ok := c.(entryCounter); ok {
+		cacheEntries.ValueFrom(func() float64 {
+			return float64(ec.Len())
+		}, label)
+	}
+	if bc, ok := c.(byteCounter); ok {
+		cacheBytes.ValueFrom(func() float64 {
+			return float64(bc.Bytes())
+		}, label)
+	}
+}
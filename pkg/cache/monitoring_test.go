@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/monitoring/monitortest"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	mt := monitortest.New(t)
+
+	c := NewLRU(5*time.Minute, 0, 2)
+	RegisterMetrics("test-lru", c)
+	c.Set("a", "a")
+	c.Get("a")
+	c.Get("b")
+
+	tags := map[string]string{"cache": "test-lru"}
+	mt.Assert(cacheHits.Name(), tags, monitortest.Exactly(1))
+	mt.Assert(cacheMisses.Name(), tags, monitortest.Exactly(1))
+	mt.Assert(cacheEvictions.Name(), tags, monitortest.Exactly(0))
+	mt.Assert(cacheEntries.Name(), tags, monitortest.Exactly(1))
+
+	// The totals are exported as counters, so their rates can be computed.
+	families, err := monitortest.TestRegistry(t).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters := map[string]bool{cacheHits.Name(): true, cacheMisses.Name(): true, cacheEvictions.Name(): true}
+	for _, f := range families {
+		if counters[f.GetName()] {
+			if f.GetType() != dto.MetricType_COUNTER {
+				t.Errorf("got %v type for %s, want a counter", f.GetType(), f.GetName())
+			}
+			delete(counters, f.GetName())
+		}
+	}
+	if len(counters) != 0 {
+		t.Errorf("metrics %v not exported", counters)
+	}
+
+	xc, err := NewFileXdsResourceCache(t.TempDir(), XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(xc.Flush)
+	RegisterMetrics("test-xds", xc)
+	if err := xc.Add(testResponse("n1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := xc.Store(testAck("node", "n1")); err != nil {
+		t.Fatal(err)
+	}
+	mt.Assert(cacheEntries.Name(), map[string]string{"cache": "test-xds"}, monitortest.Exactly(1))
+	mt.Assert(cacheBytes.Name(), map[string]string{"cache": "test-xds"}, monitortest.AtLeast(1))
+}
@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// The sharded LRU spreads entries over a fixed number of independent LRU caches, each
+// guarded by its own lock, so goroutines working on different keys rarely contend.
+// The price is that the LRU order is only maintained per shard: when a shard is full,
+// its least recently used entry is displaced even if other shards hold older entries.
+//
+// Keys are assigned to shards by hashing, see hashKey.
+
+// See use of SetFinalizer in NewLRU for an explanation of this weird composition
+type shardedLRUWrapper struct {
+	*shardedLRU
+}
+
+type shardedLRU struct {
+	shards            []*lruCache
+	seed              maphash.Seed
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+}
+
+// NewShardedLRU creates a new cache with an LRU and time-based eviction model, split
+// into the given number of shards. See NewLRU for the meaning of the other parameters.
+//
+// maxEntries is divided between shards, the first ones holding one more entry if it is not
+// a multiple of shards. Each shard holds at least one entry.
+func NewShardedLRU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32, shards int) ExpiringCache {
+	return NewShardedLRUWithListener(defaultExpiration, evictionInterval, maxEntries, shards,
+		func(key, value any, reason EvictionReason) {})
+}
+
+// NewShardedLRUWithListener creates a new sharded LRU cache that will invoke the supplied
+// listener whenever an entry expires, is displaced or is removed. See also: NewShardedLRU.
+func NewShardedLRUWithListener(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32, shards int,
+	listener EvictionListener,
+) ExpiringCache {
+	if shards < 1 {
+		shards = 1
+	}
+	perShard, remainder := maxEntries/int32(shards), maxEntries%int32(shards)
+
+	c := &shardedLRU{
+		shards: make([]*lruCache, shards),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range c.shards {
+		n := perShard
+		if int32(i) < remainder {
+			n++
+		}
+		if n < 1 {
+			n = 1
+		}
+		c.shards[i] = NewLRUWithListener(defaultExpiration, 0, n, listener).(*lruCache)
+	}
+
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		result := &shardedLRUWrapper{c}
+		runtime.SetFinalizer(result, func(w *shardedLRUWrapper) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *shardedLRU) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case now := <-ticker.C:
+			c.evictExpired(now)
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *shardedLRU) shard(key any) *lruCache {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+
+	return c.shards[hashKey(c.seed, key)%uint64(len(c.shards))]
+}
+
+func (c *shardedLRU) evictExpired(t time.Time) {
+	for _, s := range c.shards {
+		s.evictExpired(t)
+	}
+}
+
+func (c *shardedLRU) EvictExpired() {
+	c.evictExpired(time.Now())
+}
+
+func (c *shardedLRU) Set(key any, value any) {
+	c.shard(key).Set(key, value)
+}
+
+func (c *shardedLRU) SetWithExpiration(key any, value any, expiration time.Duration) {
+	c.shard(key).SetWithExpiration(key, value, expiration)
+}
+
+func (c *shardedLRU) Get(key any) (any, bool) {
+	return c.shard(key).Get(key)
+}
+
+func (c *shardedLRU) GetWithExpiration(key any) (any, time.Duration, bool) {
+	return c.shard(key).GetWithExpiration(key)
+}
+
+func (c *shardedLRU) Remove(key any) {
+	c.shard(key).Remove(key)
+}
+
+func (c *shardedLRU) RemoveAll() {
+	for _, s := range c.shards {
+		s.RemoveAll()
+	}
+}
+
+func (c *shardedLRU) Stats() Stats {
+	var stats Stats
+	for _, s := range c.shards {
+		ss := s.Stats()
+		stats.Writes += ss.Writes
+		stats.Hits += ss.Hits
+		stats.Misses += ss.Misses
+		stats.Evictions += ss.Evictions
+		stats.Removals += ss.Removals
+	}
+	return stats
+}
+
+// Len returns the number of entries in the cache.
+func (c *shardedLRU) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Len()
+	}
+	return n
+}
@@ -0,0 +1,143 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardedLRUBasic(t *testing.T) {
+	lru := NewShardedLRU(5*time.Minute, 1*time.Millisecond, 500, 16)
+	testCacheBasic(lru, t)
+}
+
+func TestShardedLRUConcurrent(t *testing.T) {
+	lru := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	testCacheConcurrent(lru, t)
+}
+
+func TestShardedLRUExpiration(t *testing.T) {
+	lru := NewShardedLRU(5*time.Second, 0, 500, 16).(*shardedLRU)
+	testCacheExpiration(lru, lru.evictExpired, t)
+}
+
+func TestShardedLRUEvicter(t *testing.T) {
+	lru := NewShardedLRU(5*time.Second, 1*time.Millisecond, 500, 16)
+	testCacheEvicter(lru)
+}
+
+func TestShardedLRUEvictExpired(t *testing.T) {
+	lru := NewShardedLRU(5*time.Second, 0, 500, 16)
+	testCacheEvictExpired(lru, t)
+}
+
+func TestShardedLRUFinalizer(t *testing.T) {
+	lru := NewShardedLRU(5*time.Second, 1*time.Millisecond, 500, 16).(*shardedLRUWrapper)
+	testCacheFinalizer(&lru.evicterTerminated)
+}
+
+func TestShardedLRUEvictionListener(t *testing.T) {
+	r := &evictionRecorder{}
+	lru := NewShardedLRUWithListener(5*time.Second, 0, 500, 16, r.listener)
+	testCacheEvictionListener(lru, r, t)
+}
+
+func TestShardedLRUGetWithExpiration(t *testing.T) {
+	lru := NewShardedLRU(5*time.Second, 0, 500, 16)
+	testCacheGetWithExpiration(lru, t)
+}
+
+func TestShardedLRUCapacity(t *testing.T) {
+	lru := NewShardedLRU(5*time.Minute, 0, 64, 4)
+	for i := 0; i < 1000; i++ {
+		lru.Set(strconv.Itoa(i), i)
+	}
+	count := 0
+	for i := 0; i < 1000; i++ {
+		if _, ok := lru.Get(strconv.Itoa(i)); ok {
+			count++
+		}
+	}
+	if count != 64 {
+		t.Errorf("Got %d entries, expecting 64", count)
+	}
+
+	// the remainder of the capacity goes to the first shards
+	for _, tc := range []struct {
+		maxEntries int32
+		want       []int
+	}{
+		{maxEntries: 10, want: []int{3, 3, 2, 2}},
+		{maxEntries: 2, want: []int{1, 1, 1, 1}},
+	} {
+		shards := NewShardedLRU(5*time.Minute, 0, tc.maxEntries, 4).(*shardedLRU).shards
+		for i, s := range shards {
+			if got := len(s.entries) - 1; got != tc.want[i] {
+				t.Errorf("Got capacity %d for shard %d of %d entries, expecting %d", got, i, tc.maxEntries, tc.want[i])
+			}
+		}
+	}
+
+	// every key type can be sharded
+	for _, key := range []any{1, int32(2), int64(3), uint64(4), struct{ a string }{"5"}} {
+		lru.Set(key, "v")
+		if _, ok := lru.Get(key); !ok {
+			t.Errorf("Got no entry for %v, expecting it to be there", key)
+		}
+	}
+}
+
+func BenchmarkShardedLRUGet(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkShardedLRUGetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUSet(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkShardedLRUSetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUGetSetConcurrent(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkShardedLRUSetRemove(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 500, 16)
+	benchmarkCacheSetRemove(c, b)
+}
+
+// Compare with BenchmarkLRUGetSet64Goroutines and BenchmarkLRUGetSet256Goroutines.
+func BenchmarkShardedLRUGetSet64Goroutines(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 2048, 32)
+	benchmarkCacheGetSetGoroutines(c, 64, b)
+}
+
+func BenchmarkShardedLRUGetSet256Goroutines(b *testing.B) {
+	c := NewShardedLRU(5*time.Minute, 1*time.Minute, 2048, 32)
+	benchmarkCacheGetSetGoroutines(c, 256, b)
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+)
+
+// Loader produces the value for a key missing from a cache.
+type Loader func() (any, error)
+
+// LoadingCache is a cache able to fill in missing entries on demand.
+type LoadingCache interface {
+	Cache
+
+	// GetOrLoad returns the value associated with the supplied key, calling load to
+	// produce it if the key is not present in the cache. Concurrent calls for the same
+	// key share a single invocation of load. Successfully loaded values are added to the
+	// cache, errors are returned to every waiting caller and are not cached.
+	GetOrLoad(key any, load Loader) (any, error)
+}
+
+type singleflightCache struct {
+	Cache
+
+	mu       sync.Mutex
+	inflight map[any]*loadCall
+}
+
+// loadCall is an in-flight or completed GetOrLoad call.
+type loadCall struct {
+	done  chan struct{}
+	value any
+	err   error
+}
+
+var _ LoadingCache = &singleflightCache{}
+
+// WithSingleflight decorates c so that concurrent loads of the same key are deduplicated.
+func WithSingleflight(c Cache) LoadingCache {
+	return &singleflightCache{
+		Cache:    c,
+		inflight: map[any]*loadCall{},
+	}
+}
+
+func (c *singleflightCache) GetOrLoad(key any, load Loader) (any, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &loadCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = load()
+	if call.err == nil {
+		c.Set(key, call.value)
+	}
+	return call.value, call.err
+}
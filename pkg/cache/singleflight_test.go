@@ -0,0 +1,87 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflightBasic(t *testing.T) {
+	c := WithSingleflight(NewTTL(5*time.Second, 0))
+	testCacheBasic(c, t)
+}
+
+func TestSingleflightGetOrLoad(t *testing.T) {
+	c := WithSingleflight(NewTTL(5*time.Second, 0))
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func() (any, error) {
+		loads.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make([]any, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, err := c.GetOrLoad("key", load)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = v
+		}(i)
+	}
+	// Give every caller a chance to join the in-flight load before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("expected a single load, got %d", n)
+	}
+	for _, v := range results {
+		if v != "value" {
+			t.Fatalf("got %v, want value", v)
+		}
+	}
+	if v, ok := c.Get("key"); !ok || v != "value" {
+		t.Fatalf("expected loaded value to be cached, got %v, %v", v, ok)
+	}
+}
+
+func TestSingleflightLoadError(t *testing.T) {
+	c := WithSingleflight(NewTTL(5*time.Second, 0))
+
+	errLoad := errors.New("load failed")
+	if _, err := c.GetOrLoad("key", func() (any, error) { return nil, errLoad }); !errors.Is(err, errLoad) {
+		t.Fatalf("got %v, want %v", err, errLoad)
+	}
+	if _, ok := c.Get("key"); ok {
+		t.Fatal("expected failed load not to be cached")
+	}
+	v, err := c.GetOrLoad("key", func() (any, error) { return "value", nil })
+	if err != nil || v != "value" {
+		t.Fatalf("expected the next call to load again, got %v, %v", v, err)
+	}
+}
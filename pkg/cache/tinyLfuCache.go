@@ -0,0 +1,434 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// This is an implementation of W-TinyLFU (https://arxiv.org/abs/1512.00727).
+//
+// A plain LRU admits every new entry, so a one-off scan over many keys flushes the
+// frequently used entries out of the cache. W-TinyLFU instead keeps an approximate
+// access frequency for every key, including keys not in the cache, in a count-min
+// sketch, and only lets a new entry into the main cache if it is accessed more often
+// than the entry it would displace.
+//
+// Entries first land in a small LRU window, which absorbs bursts of new keys. When the
+// window is full, its least recently used entry becomes a candidate for the main cache.
+// The main cache is a segmented LRU: entries start in the probation segment and are
+// promoted to the protected segment when accessed again. The victim compared against a
+// candidate is always the least recently used entry of the probation segment, so hot
+// entries sitting in the protected segment are never displaced by a scan.
+//
+// Frequencies are halved periodically, so keys that used to be hot eventually age out.
+
+const (
+	// tinyLFUWindowPercent is the share of the capacity used by the admission window.
+	tinyLFUWindowPercent = 1
+	// tinyLFUProtectedPercent is the share of the main cache used by the protected segment.
+	tinyLFUProtectedPercent = 80
+	// tinyLFUSampleFactor controls how many accesses, relative to the capacity, are
+	// recorded before frequencies are halved.
+	tinyLFUSampleFactor = 10
+)
+
+type tinyLFUSegment int8
+
+const (
+	windowSegment tinyLFUSegment = iota
+	probationSegment
+	protectedSegment
+)
+
+// See use of SetFinalizer in NewLRU for an explanation of this weird composition
+type tinyLFUWrapper struct {
+	*tinyLFUCache
+}
+
+type tinyLFUCache struct {
+	sync.Mutex
+	lookup            map[any]*list.Element
+	segments          [3]*list.List
+	windowCap         int
+	mainCap           int
+	protectedCap      int
+	sketch            *countMinSketch
+	seed              maphash.Seed
+	stats             Stats
+	defaultExpiration time.Duration
+	baseTimeNanos     int64
+	stopEvicter       chan bool
+	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
+	listener          EvictionListener
+}
+
+type tinyLFUEntry struct {
+	key        any
+	value      any
+	hash       uint64
+	expiration int64 // nanoseconds
+	segment    tinyLFUSegment
+}
+
+// NewTinyLFU creates a new cache with a W-TinyLFU admission policy and time-based eviction.
+//
+// Unlike NewLRU, a new entry may be rejected when the cache is full, if it has been accessed
+// less often than the entry it would displace. This protects a small set of hot entries
+// from being flushed by scans over many entries that are only used once.
+//
+// See NewLRU for the meaning of the parameters.
+func NewTinyLFU(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32) ExpiringCache {
+	return NewTinyLFUWithListener(defaultExpiration, evictionInterval, maxEntries, func(key, value any, reason EvictionReason) {})
+}
+
+// NewTinyLFUWithListener creates a new W-TinyLFU cache that will invoke the supplied listener
+// whenever an entry expires, is displaced, is rejected or is removed. See also: NewTinyLFU.
+func NewTinyLFUWithListener(defaultExpiration time.Duration, evictionInterval time.Duration, maxEntries int32,
+	listener EvictionListener,
+) ExpiringCache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	windowCap := int(maxEntries) * tinyLFUWindowPercent / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	mainCap := int(maxEntries) - windowCap
+
+	c := &tinyLFUCache{
+		lookup:            make(map[any]*list.Element, maxEntries),
+		segments:          [3]*list.List{list.New(), list.New(), list.New()},
+		windowCap:         windowCap,
+		mainCap:           mainCap,
+		protectedCap:      mainCap * tinyLFUProtectedPercent / 100,
+		sketch:            newCountMinSketch(int(maxEntries)),
+		seed:              maphash.MakeSeed(),
+		defaultExpiration: defaultExpiration,
+		listener:          listener,
+	}
+
+	c.baseTimeNanos = time.Now().UnixNano()
+	if evictionInterval > 0 {
+		c.stopEvicter = make(chan bool, 1)
+		c.evicterTerminated.Add(1)
+		go c.evicter(evictionInterval)
+
+		result := &tinyLFUWrapper{c}
+		runtime.SetFinalizer(result, func(w *tinyLFUWrapper) {
+			w.stopEvicter <- true
+			w.evicterTerminated.Wait()
+		})
+		return result
+	}
+
+	return c
+}
+
+func (c *tinyLFUCache) evicter(evictionInterval time.Duration) {
+	// Wake up once in a while and evict stale items
+	ticker := time.NewTicker(evictionInterval)
+	for {
+		select {
+		case now := <-ticker.C:
+			c.evictExpired(now)
+		case <-c.stopEvicter:
+			ticker.Stop()
+			c.evicterTerminated.Done() // record this for the sake of unit tests
+			return
+		}
+	}
+}
+
+func (c *tinyLFUCache) evictExpired(t time.Time) {
+	// We snapshot a base time here such that the time doesn't need to be
+	// sampled in the Set call as calling time.Now() is relatively expensive.
+	n := t.UnixNano()
+	atomic.StoreInt64(&c.baseTimeNanos, n)
+
+	var expired []*tinyLFUEntry
+	c.Lock()
+	for _, l := range c.segments {
+		for el := l.Front(); el != nil; {
+			next := el.Next()
+			if ent := el.Value.(*tinyLFUEntry); ent.expiration <= n {
+				c.remove(el)
+				c.stats.Evictions++
+				expired = append(expired, ent)
+			}
+			el = next
+		}
+	}
+	c.Unlock()
+
+	for _, ent := range expired {
+		c.listener(ent.key, ent.value, Expired)
+	}
+}
+
+func (c *tinyLFUCache) EvictExpired() {
+	c.evictExpired(time.Now())
+}
+
+func (c *tinyLFUCache) Set(key any, value any) {
+	c.SetWithExpiration(key, value, c.defaultExpiration)
+}
+
+func (c *tinyLFUCache) SetWithExpiration(key any, value any, expiration time.Duration) {
+	exp := atomic.LoadInt64(&c.baseTimeNanos) + expiration.Nanoseconds()
+	h := hashKey(c.seed, key)
+
+	c.Lock()
+
+	c.sketch.increment(h)
+	c.stats.Writes++
+	if el, ok := c.lookup[key]; ok {
+		ent := el.Value.(*tinyLFUEntry)
+		ent.value = value
+		ent.expiration = exp
+		c.touch(el)
+		c.Unlock()
+		return
+	}
+
+	c.lookup[key] = c.segments[windowSegment].PushFront(&tinyLFUEntry{
+		key:        key,
+		value:      value,
+		hash:       h,
+		expiration: exp,
+		segment:    windowSegment,
+	})
+	evicted := c.admit()
+
+	c.Unlock()
+
+	for _, ent := range evicted {
+		c.listener(ent.key, ent.value, Evicted)
+	}
+}
+
+// admit moves the entries overflowing the window to the main cache, if they are
+// accessed more often than the entries they displace. It returns the evicted entries.
+func (c *tinyLFUCache) admit() []*tinyLFUEntry {
+	var evicted []*tinyLFUEntry
+	window := c.segments[windowSegment]
+	for window.Len() > c.windowCap {
+		candidate := c.remove(window.Back())
+
+		if c.segments[probationSegment].Len()+c.segments[protectedSegment].Len() < c.mainCap {
+			c.push(candidate, probationSegment)
+			continue
+		}
+
+		victim := c.segments[probationSegment].Back()
+		if victim == nil {
+			victim = c.segments[protectedSegment].Back()
+		}
+		if victim != nil && c.sketch.estimate(candidate.hash) > c.sketch.estimate(victim.Value.(*tinyLFUEntry).hash) {
+			evicted = append(evicted, c.remove(victim))
+			c.push(candidate, probationSegment)
+		} else {
+			evicted = append(evicted, candidate)
+		}
+		c.stats.Evictions++
+	}
+	return evicted
+}
+
+// touch records an access to the entry, promoting it from probation to protected.
+func (c *tinyLFUCache) touch(el *list.Element) {
+	ent := el.Value.(*tinyLFUEntry)
+	switch ent.segment {
+	case windowSegment, protectedSegment:
+		c.segments[ent.segment].MoveToFront(el)
+	case probationSegment:
+		c.remove(el)
+		c.push(ent, protectedSegment)
+		protected := c.segments[protectedSegment]
+		if protected.Len() > c.protectedCap {
+			c.push(c.remove(protected.Back()), probationSegment)
+		}
+	}
+}
+
+func (c *tinyLFUCache) push(ent *tinyLFUEntry, segment tinyLFUSegment) {
+	ent.segment = segment
+	c.lookup[ent.key] = c.segments[segment].PushFront(ent)
+}
+
+func (c *tinyLFUCache) remove(el *list.Element) *tinyLFUEntry {
+	ent := el.Value.(*tinyLFUEntry)
+	c.segments[ent.segment].Remove(el)
+	delete(c.lookup, ent.key)
+	return ent
+}
+
+func (c *tinyLFUCache) Get(key any) (any, bool) {
+	value, _, ok := c.get(key)
+	return value, ok
+}
+
+func (c *tinyLFUCache) GetWithExpiration(key any) (any, time.Duration, bool) {
+	value, expiration, ok := c.get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	return value, time.Duration(expiration - time.Now().UnixNano()), true
+}
+
+func (c *tinyLFUCache) get(key any) (any, int64, bool) {
+	h := hashKey(c.seed, key)
+
+	c.Lock()
+	defer c.Unlock()
+
+	// misses are recorded too, so that keys which are repeatedly requested get admitted
+	c.sketch.increment(h)
+	el, ok := c.lookup[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, 0, false
+	}
+	c.stats.Hits++
+	c.touch(el)
+	ent := el.Value.(*tinyLFUEntry)
+	return ent.value, ent.expiration, true
+}
+
+func (c *tinyLFUCache) Remove(key any) {
+	c.Lock()
+
+	el, ok := c.lookup[key]
+	var ent *tinyLFUEntry
+	if ok {
+		ent = c.remove(el)
+		c.stats.Removals++
+	}
+
+	c.Unlock()
+
+	if ok {
+		c.listener(ent.key, ent.value, Removed)
+	}
+}
+
+func (c *tinyLFUCache) RemoveAll() {
+	var removed []*tinyLFUEntry
+	c.Lock()
+	for _, l := range c.segments {
+		for el := l.Front(); el != nil; el = el.Next() {
+			removed = append(removed, el.Value.(*tinyLFUEntry))
+		}
+		l.Init()
+	}
+	c.lookup = make(map[any]*list.Element, len(c.lookup))
+	c.stats.Removals += uint64(len(removed))
+	c.Unlock()
+
+	for _, ent := range removed {
+		c.listener(ent.key, ent.value, Removed)
+	}
+}
+
+func (c *tinyLFUCache) Stats() Stats {
+	c.Lock()
+	defer c.Unlock()
+	return c.stats
+}
+
+// Len returns the number of entries in the cache.
+func (c *tinyLFUCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.lookup)
+}
+
+// Keys returns the keys of the entries in the cache, for testing and debugging.
+func (c *tinyLFUCache) Keys() []any {
+	c.Lock()
+	defer c.Unlock()
+	keys := make([]any, 0, len(c.lookup))
+	for key := range c.lookup {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// countMinSketch estimates the access frequency of keys with 4-bit counters.
+type countMinSketch struct {
+	rows       [4][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	// Four counters per entry keep the collisions caused by scans over many keys low,
+	// for 16 bytes of counters per entry.
+	width := 16
+	for width < 4*capacity {
+		width *= 2
+	}
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		sampleSize: tinyLFUSampleFactor * capacity,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index derives the counter used in each row from the two halves of the hash.
+func (s *countMinSketch) index(h uint64, row int) uint64 {
+	return ((h & 0xffffffff) + uint64(row)*(h>>32)) & s.mask
+}
+
+func (s *countMinSketch) increment(h uint64) {
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(h uint64) uint8 {
+	min := uint8(15)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(h, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// reset halves all counters, so that the frequencies of the past fade out.
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,146 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTinyLFUBasic(t *testing.T) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Millisecond, 500)
+	testCacheBasic(c, t)
+}
+
+func TestTinyLFUConcurrent(t *testing.T) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	testCacheConcurrent(c, t)
+}
+
+func TestTinyLFUExpiration(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500).(*tinyLFUCache)
+	testCacheExpiration(c, c.evictExpired, t)
+}
+
+func TestTinyLFUEvicter(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 1*time.Millisecond, 500)
+	testCacheEvicter(c)
+}
+
+func TestTinyLFUEvictExpired(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500)
+	testCacheEvictExpired(c, t)
+}
+
+func TestTinyLFUFinalizer(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 1*time.Millisecond, 500).(*tinyLFUWrapper)
+	testCacheFinalizer(&c.evicterTerminated)
+}
+
+func TestTinyLFUEvictionListener(t *testing.T) {
+	r := &evictionRecorder{}
+	c := NewTinyLFUWithListener(5*time.Second, 0, 500, r.listener)
+	testCacheEvictionListener(c, r, t)
+}
+
+func TestTinyLFUGetWithExpiration(t *testing.T) {
+	c := NewTinyLFU(5*time.Second, 0, 500)
+	testCacheGetWithExpiration(c, t)
+}
+
+func TestTinyLFUScanResistance(t *testing.T) {
+	const hot = 10
+	for _, tc := range []struct {
+		policy  EvictionPolicy
+		minKept int
+		maxKept int
+	}{
+		// a scan flushes every hot entry out of an LRU...
+		{LRUPolicy, 0, 0},
+		// ...but not out of W-TinyLFU. The last hot entry set may still sit in the admission
+		// window, from where it only gets into the main cache if it wins on frequency.
+		{TinyLFUPolicy, hot - 1, hot},
+	} {
+		c := NewBounded(tc.policy, 5*time.Minute, 0, 100)
+		for round := 0; round < 5; round++ {
+			for i := 0; i < hot; i++ {
+				key := "hot" + strconv.Itoa(i)
+				if _, ok := c.Get(key); !ok {
+					c.Set(key, i)
+				}
+			}
+		}
+		for i := 0; i < 1000; i++ {
+			c.Set("scan"+strconv.Itoa(i), i)
+		}
+
+		kept := 0
+		for i := 0; i < hot; i++ {
+			if _, ok := c.Get("hot" + strconv.Itoa(i)); ok {
+				kept++
+			}
+		}
+		if kept < tc.minKept || kept > tc.maxKept {
+			t.Errorf("policy %d: got %d hot entries after a scan, expecting %d to %d", tc.policy, kept, tc.minKept, tc.maxKept)
+		}
+	}
+}
+
+func TestTinyLFUCapacity(t *testing.T) {
+	r := &evictionRecorder{}
+	c := NewTinyLFUWithListener(5*time.Minute, 0, 64, r.listener).(*tinyLFUCache)
+	for i := 0; i < 1000; i++ {
+		c.Set(strconv.Itoa(i), strconv.Itoa(i))
+	}
+	if len(c.lookup) != 64 {
+		t.Errorf("Got %d entries, expecting 64", len(c.lookup))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) != 1000-64 {
+		t.Errorf("Got %d eviction events, expecting %d", len(r.events), 1000-64)
+	}
+}
+
+func BenchmarkTinyLFUGet(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGet(c, b)
+}
+
+func BenchmarkTinyLFUGetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUSet(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSet(c, b)
+}
+
+func BenchmarkTinyLFUSetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUGetSetConcurrent(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheGetSetConcurrent(c, b)
+}
+
+func BenchmarkTinyLFUSetRemove(b *testing.B) {
+	c := NewTinyLFU(5*time.Minute, 1*time.Minute, 500)
+	benchmarkCacheSetRemove(c, b)
+}
@@ -48,7 +48,7 @@ type ttlCache struct {
 	defaultExpiration time.Duration
 	stopEvicter       chan bool
 	evicterTerminated sync.WaitGroup // used by unit tests to verify the finalizer ran
-	callback          EvictionCallback
+	listener          EvictionListener
 }
 
 // A single cache entry. This is the values we use in our storage map
@@ -84,15 +84,25 @@ type EvictionCallback func(key, value any)
 // use up all available memory by continuing to add entries to the cache with a
 // long enough expiration time. Don't do that.
 func NewTTL(defaultExpiration time.Duration, evictionInterval time.Duration) ExpiringCache {
-	return NewTTLWithCallback(defaultExpiration, evictionInterval, func(key, value any) {})
+	return NewTTLWithListener(defaultExpiration, evictionInterval, func(key, value any, reason EvictionReason) {})
 }
 
 // NewTTLWithCallback creates a new cache with a time-based eviction model that will invoke the supplied
 // callback on all evictions. See also: NewTTL.
 func NewTTLWithCallback(defaultExpiration time.Duration, evictionInterval time.Duration, callback EvictionCallback) ExpiringCache {
+	return NewTTLWithListener(defaultExpiration, evictionInterval, func(key, value any, reason EvictionReason) {
+		if reason == Expired {
+			callback(key, value)
+		}
+	})
+}
+
+// NewTTLWithListener creates a new cache with a time-based eviction model that will invoke the supplied
+// listener whenever an entry expires or is removed. See also: NewTTL.
+func NewTTLWithListener(defaultExpiration time.Duration, evictionInterval time.Duration, listener EvictionListener) ExpiringCache {
 	c := &ttlCache{
 		defaultExpiration: defaultExpiration,
-		callback:          callback,
+		listener:          listener,
 	}
 
 	c.baseTimeNanos = time.Now().UnixNano()
@@ -139,22 +149,14 @@ func (c *ttlCache) evictExpired(t time.Time) {
 	n := t.UnixNano()
 	atomic.StoreInt64(&c.baseTimeNanos, n)
 
-	// This loop is inherently racy. As we iterate through the
-	// key/value pairs, the value assigned to a particular key may
-	// change at any point. So when we find an expired entry and
-	// delete it, it's possible that a concurrent update assigned a
-	// fresh value to the key at hand, and so we'll proceed to delete
-	// the fresh key/value combo.
-	//
-	// This is a cache, not a map. So we're OK with this extremely rare
-	// situation. So long as the cache never lies, it's OK if it spuriously
-	// forgets.
+	// As we iterate through the key/value pairs, the value assigned to a
+	// particular key may change at any point. Expired entries are only
+	// deleted if they are still current, so a fresh value assigned
+	// concurrently survives and the listener sees each entry at most once.
 	c.entries.Range(func(key any, value any) bool {
 		e := value.(*entry)
-		if e.expiration <= n {
-			c.entries.Delete(key)
-			c.callback(key, value.(*entry).value)
-			// Note: can miscount if the key was removed before it was evicted
+		if e.expiration <= n && c.entries.CompareAndDelete(key, value) {
+			c.listener(key, e.value, Expired)
 			atomic.AddUint64(&c.stats.Evictions, 1)
 		}
 		return true
@@ -195,8 +197,22 @@ func (c *ttlCache) Get(key any) (any, bool) {
 	return e.(*entry).value, true
 }
 
+func (c *ttlCache) GetWithExpiration(key any) (any, time.Duration, bool) {
+	e, ok := c.entries.Load(key)
+	if !ok {
+		atomic.AddUint64(&c.stats.Misses, 1)
+		return nil, 0, false
+	}
+
+	atomic.AddUint64(&c.stats.Hits, 1)
+	ent := e.(*entry)
+	return ent.value, time.Duration(ent.expiration - time.Now().UnixNano()), true
+}
+
 func (c *ttlCache) Remove(key any) {
-	c.entries.Delete(key)
+	if e, ok := c.entries.LoadAndDelete(key); ok {
+		c.listener(key, e.(*entry).value, Removed)
+	}
 
 	// Note: we count this as a removal even in the case where the key wasn't actually in the map
 	atomic.AddUint64(&c.stats.Removals, 1)
@@ -204,7 +220,9 @@ func (c *ttlCache) Remove(key any) {
 
 func (c *ttlCache) RemoveAll() {
 	c.entries.Range(func(key any, value any) bool {
-		c.entries.Delete(key)
+		if e, ok := c.entries.LoadAndDelete(key); ok {
+			c.listener(key, e.(*entry).value, Removed)
+		}
 
 		// Note: can miscount if the key was evicted before it was removed
 		atomic.AddUint64(&c.stats.Removals, 1)
@@ -222,3 +240,13 @@ func (c *ttlCache) Stats() Stats {
 		Removals:  atomic.LoadUint64(&c.stats.Removals),
 	}
 }
+
+// Len returns the number of entries in the cache, including expired entries not evicted yet.
+func (c *ttlCache) Len() int {
+	n := 0
+	c.entries.Range(func(key any, value any) bool {
+		n++
+		return true
+	})
+	return n
+}
@@ -62,6 +62,17 @@ func TestTTLEvictionCallback(t *testing.T) {
 	}
 }
 
+func TestTTLEvictionListener(t *testing.T) {
+	r := &evictionRecorder{}
+	ttl := NewTTLWithListener(5*time.Second, 0, r.listener)
+	testCacheEvictionListener(ttl, r, t)
+}
+
+func TestTTLGetWithExpiration(t *testing.T) {
+	ttl := NewTTL(5*time.Second, 0)
+	testCacheGetWithExpiration(ttl, t)
+}
+
 func TestTTLFinalizer(t *testing.T) {
 	ttl := NewTTL(5*time.Second, 1*time.Millisecond).(*ttlWrapper)
 	testCacheFinalizer(&ttl.evicterTerminated)
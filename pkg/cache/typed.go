@@ -0,0 +1,117 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"time"
+)
+
+// Typed is a type-safe view over a Cache. All entries of the underlying cache must be
+// set through the same Typed view.
+type Typed[K comparable, V any] struct {
+	c Cache
+}
+
+// NewTyped returns a type-safe view over c.
+func NewTyped[K comparable, V any](c Cache) Typed[K, V] {
+	return Typed[K, V]{c: c}
+}
+
+// Set inserts an entry in the cache. See Cache.Set.
+func (t Typed[K, V]) Set(key K, value V) {
+	t.c.Set(key, value)
+}
+
+// Get retrieves the value associated with the supplied key. See Cache.Get.
+func (t Typed[K, V]) Get(key K) (V, bool) {
+	v, ok := t.c.Get(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return v.(V), true
+}
+
+// Remove deletes the given key from the cache. See Cache.Remove.
+func (t Typed[K, V]) Remove(key K) {
+	t.c.Remove(key)
+}
+
+// RemoveAll deletes all entries from the cache.
+func (t Typed[K, V]) RemoveAll() {
+	t.c.RemoveAll()
+}
+
+// Stats returns information about the efficiency of the cache.
+func (t Typed[K, V]) Stats() Stats {
+	return t.c.Stats()
+}
+
+// TypedExpiring is a type-safe view over an ExpiringCache.
+type TypedExpiring[K comparable, V any] struct {
+	Typed[K, V]
+	c ExpiringCache
+}
+
+// NewTypedExpiring returns a type-safe view over c.
+func NewTypedExpiring[K comparable, V any](c ExpiringCache) TypedExpiring[K, V] {
+	return TypedExpiring[K, V]{Typed: NewTyped[K, V](c), c: c}
+}
+
+// SetWithExpiration inserts an entry in the cache with a requested expiration time.
+// See ExpiringCache.SetWithExpiration.
+func (t TypedExpiring[K, V]) SetWithExpiration(key K, value V, expiration time.Duration) {
+	t.c.SetWithExpiration(key, value, expiration)
+}
+
+// GetWithExpiration retrieves the value associated with the supplied key along with the
+// time left before it expires. See ExpiringCache.GetWithExpiration.
+func (t TypedExpiring[K, V]) GetWithExpiration(key K) (V, time.Duration, bool) {
+	v, remaining, ok := t.c.GetWithExpiration(key)
+	if !ok {
+		var zero V
+		return zero, 0, false
+	}
+	return v.(V), remaining, true
+}
+
+// EvictExpired synchronously evicts all expired entries from the cache.
+func (t TypedExpiring[K, V]) EvictExpired() {
+	t.c.EvictExpired()
+}
+
+// TypedLoading is a type-safe view over a LoadingCache.
+type TypedLoading[K comparable, V any] struct {
+	Typed[K, V]
+	c LoadingCache
+}
+
+// NewTypedLoading returns a type-safe view over c.
+func NewTypedLoading[K comparable, V any](c LoadingCache) TypedLoading[K, V] {
+	return TypedLoading[K, V]{Typed: NewTyped[K, V](c), c: c}
+}
+
+// GetOrLoad returns the value associated with the supplied key, loading it if missing.
+// See LoadingCache.GetOrLoad.
+func (t TypedLoading[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	v, err := t.c.GetOrLoad(key, func() (any, error) {
+		return load()
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
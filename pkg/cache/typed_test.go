@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type typedKey struct {
+	name    string
+	version int
+}
+
+func TestTyped(t *testing.T) {
+	c := NewTypedExpiring[typedKey, []string](NewTTL(time.Hour, 0))
+
+	if v, ok := c.Get(typedKey{"a", 1}); ok || v != nil {
+		t.Fatalf("expected miss, got %v, %v", v, ok)
+	}
+	c.Set(typedKey{"a", 1}, []string{"x"})
+	c.SetWithExpiration(typedKey{"a", 2}, []string{"y"}, time.Nanosecond)
+	if v, ok := c.Get(typedKey{"a", 1}); !ok || len(v) != 1 || v[0] != "x" {
+		t.Fatalf("got %v, %v", v, ok)
+	}
+
+	time.Sleep(time.Millisecond)
+	c.EvictExpired()
+	if _, ok := c.Get(typedKey{"a", 2}); ok {
+		t.Fatal("expected expired entry to be evicted")
+	}
+
+	c.Remove(typedKey{"a", 1})
+	if _, ok := c.Get(typedKey{"a", 1}); ok {
+		t.Fatal("expected removed entry to be gone")
+	}
+	if stats := c.Stats(); stats.Writes != 2 || stats.Evictions != 1 || stats.Removals != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTypedLoading(t *testing.T) {
+	c := NewTypedLoading[string, int](WithSingleflight(NewTTL(time.Hour, 0)))
+
+	v, err := c.GetOrLoad("a", func() (int, error) { return 1, nil })
+	if err != nil || v != 1 {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	v, err = c.GetOrLoad("a", func() (int, error) { return 2, nil })
+	if err != nil || v != 1 {
+		t.Fatalf("expected cached value, got %v, %v", v, err)
+	}
+	errLoad := errors.New("load failed")
+	if v, err := c.GetOrLoad("b", func() (int, error) { return 3, errLoad }); !errors.Is(err, errLoad) || v != 0 {
+		t.Fatalf("expected zero value and error, got %v, %v", v, err)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// defaultMaxPendingWrites bounds the number of snapshots waiting to be written to the store.
+const defaultMaxPendingWrites = 10000
+
+var errWriteQueueFull = errors.New("too many xds cache snapshots waiting to be written")
+
+type snapshotWrite struct {
+	// data is the snapshot to save, or nil if the snapshot is deleted.
+	data []byte
+	seq  uint64
+}
+
+// asyncSnapshotStore queues the writes to a snapshot store and applies them from a single goroutine, so ACKs
+// never wait for the store. Writes of a key are coalesced: only the last one queued is applied, and reads of a
+// key with a queued write are served from the queue.
+//
+// At most maxPending keys have a queued write. Once the queue is full, writes of other keys fail; the snapshots
+// stay cached in memory and are persisted by the next ACK of their proxy.
+type asyncSnapshotStore struct {
+	store      xdsSnapshotStore
+	maxPending int
+
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending map[XdsCacheKey]snapshotWrite
+	// queue orders the keys with a queued write from the oldest to the newest.
+	queue   []XdsCacheKey
+	seq     uint64
+	running bool
+}
+
+var _ xdsSnapshotStore = &asyncSnapshotStore{}
+
+func newAsyncSnapshotStore(store xdsSnapshotStore, maxPending int) *asyncSnapshotStore {
+	s := &asyncSnapshotStore{
+		store:      store,
+		maxPending: maxPending,
+		pending:    map[XdsCacheKey]snapshotWrite{},
+	}
+	s.idle = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *asyncSnapshotStore) Save(key XdsCacheKey, data []byte) error {
+	return s.enqueue(key, data)
+}
+
+func (s *asyncSnapshotStore) Delete(key XdsCacheKey) error {
+	return s.enqueue(key, nil)
+}
+
+func (s *asyncSnapshotStore) enqueue(key XdsCacheKey, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, f := s.pending[key]; !f {
+		if len(s.pending) >= s.maxPending {
+			return errWriteQueueFull
+		}
+		s.queue = append(s.queue, key)
+	}
+	s.seq++
+	s.pending[key] = snapshotWrite{data: data, seq: s.seq}
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+	return nil
+}
+
+// run applies the queued writes until the queue is empty.
+func (s *asyncSnapshotStore) run() {
+	s.mu.Lock()
+	for len(s.queue) > 0 {
+		key := s.queue[0]
+		w := s.pending[key]
+		s.mu.Unlock()
+		var err error
+		if w.data == nil {
+			err = s.store.Delete(key)
+		} else {
+			err = s.store.Save(key, w.data)
+		}
+		if err != nil {
+			XdsCache.Warnf("failed to write xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, err)
+		}
+		s.mu.Lock()
+		s.queue = s.queue[1:]
+		if s.pending[key].seq == w.seq {
+			delete(s.pending, key)
+		} else {
+			// The key was written again meanwhile, write it again after the other keys.
+			s.queue = append(s.queue, key)
+		}
+	}
+	s.running = false
+	s.idle.Broadcast()
+	s.mu.Unlock()
+}
+
+// Flush waits for the queued writes to be applied.
+func (s *asyncSnapshotStore) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.running {
+		s.idle.Wait()
+	}
+}
+
+func (s *asyncSnapshotStore) Read(key XdsCacheKey) ([]byte, error) {
+	s.mu.Lock()
+	w, f := s.pending[key]
+	s.mu.Unlock()
+	if f {
+		return w.data, nil
+	}
+	return s.store.Read(key)
+}
+
+func (s *asyncSnapshotStore) List() ([]XdsCacheKey, error) {
+	keys, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]XdsCacheKey, 0, len(keys)+len(s.pending))
+	for _, key := range keys {
+		if _, f := s.pending[key]; !f {
+			res = append(res, key)
+		}
+	}
+	for key, w := range s.pending {
+		if w.data != nil {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,119 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// blockingSnapshotStore is an in-memory snapshot store whose writes wait for unblock to be closed.
+type blockingSnapshotStore struct {
+	unblock chan struct{}
+
+	mu     sync.Mutex
+	data   map[XdsCacheKey][]byte
+	reads  int
+	writes int
+}
+
+func (s *blockingSnapshotStore) Save(key XdsCacheKey, data []byte) error {
+	<-s.unblock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = data
+	s.writes++
+	return nil
+}
+
+func (s *blockingSnapshotStore) Read(key XdsCacheKey) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	return s.data[key], nil
+}
+
+func (s *blockingSnapshotStore) Delete(key XdsCacheKey) error {
+	<-s.unblock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, key)
+	s.writes++
+	return nil
+}
+
+func (s *blockingSnapshotStore) List() ([]XdsCacheKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []XdsCacheKey
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func TestAsyncSnapshotStore(t *testing.T) {
+	backend := &blockingSnapshotStore{unblock: make(chan struct{}), data: map[XdsCacheKey][]byte{}}
+	s := newAsyncSnapshotStore(backend, 2)
+	a := XdsCacheKey{NodeID: "a", TypeURL: testClusterType}
+	b := XdsCacheKey{NodeID: "b", TypeURL: testClusterType}
+	c := XdsCacheKey{NodeID: "c", TypeURL: testClusterType}
+
+	// Writes do not wait for the store, and the queued snapshots are read back.
+	for _, data := range []string{"a1", "a2", "a3"} {
+		if err := s.Save(a, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Save(b, []byte("b1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(b); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.Read(a); err != nil || string(data) != "a3" {
+		t.Fatalf("got %q, %v, want the last queued snapshot", data, err)
+	}
+	if data, err := s.Read(b); err != nil || data != nil {
+		t.Fatalf("got %q, %v, want the queued deletion", data, err)
+	}
+	if keys, err := s.List(); err != nil || len(keys) != 1 || keys[0] != a {
+		t.Fatalf("got keys %v, %v, want the queued snapshot", keys, err)
+	}
+	if err := s.Save(c, []byte("c1")); !errors.Is(err, errWriteQueueFull) {
+		t.Fatalf("got %v, want the queue to be full", err)
+	}
+
+	close(backend.unblock)
+	s.Flush()
+	if data, _ := backend.Read(a); string(data) != "a3" {
+		t.Fatalf("got stored snapshot %q, want a3", data)
+	}
+	if data, _ := backend.Read(b); data != nil {
+		t.Fatalf("got stored snapshot %q, want none", data)
+	}
+	// The writes of a key queued while the first one was applied are coalesced.
+	if backend.writes > 3 {
+		t.Fatalf("got %d writes to the store, want the queued writes coalesced", backend.writes)
+	}
+	if err := s.Save(c, []byte("c1")); err != nil {
+		t.Fatal(err)
+	}
+	s.Flush()
+	if data, _ := backend.Read(c); string(data) != "c1" {
+		t.Fatalf("got stored snapshot %q, want c1", data)
+	}
+}
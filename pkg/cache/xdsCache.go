@@ -0,0 +1,492 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+)
+
+// pendingResponseTimeout bounds how long a response added to the cache waits for its ACK
+// before it is dropped.
+const pendingResponseTimeout = time.Minute
+
+// XdsCacheKey identifies an ACKed xds response of a proxy.
+type XdsCacheKey struct {
+	NodeID  string
+	TypeURL string
+}
+
+// xdsSnapshotStore is the durable storage backing an XdsResourceCache. Values are
+// DiscoveryResponses encoded by encodeSnapshot.
+type xdsSnapshotStore interface {
+	// Save atomically replaces the snapshot stored for the key.
+	Save(key XdsCacheKey, data []byte) error
+	// Read returns the snapshot stored for the key, or nil if there is none.
+	Read(key XdsCacheKey) ([]byte, error)
+	// Delete removes the snapshot stored for the key, if any.
+	Delete(key XdsCacheKey) error
+	// List returns the keys of all stored snapshots.
+	List() ([]XdsCacheKey, error)
+}
+
+type pendingResponse struct {
+	resp  *discovery.DiscoveryResponse
+	added time.Time
+}
+
+// XdsCacheEntry describes a response held by an XdsResourceCache.
+type XdsCacheEntry struct {
+	NodeID    string    `json:"nodeId"`
+	TypeURL   string    `json:"typeUrl"`
+	Resources int       `json:"resources"`
+	Bytes     int       `json:"bytes"`
+	LastAck   time.Time `json:"lastAck"`
+}
+
+// XdsCacheOptions configures the eviction policy of an XdsResourceCache.
+type XdsCacheOptions struct {
+	// DefaultTTL is how long a response is kept after it was last ACKed. Zero keeps responses
+	// until they are replaced or evicted to respect MaxBytes.
+	DefaultTTL time.Duration
+	// TTLs overrides DefaultTTL per type URL.
+	TTLs map[string]time.Duration
+	// MaxBytes bounds the total size of the cached responses. Once exceeded, the least recently
+	// used responses are evicted, from memory and from the store. Zero means unbounded.
+	MaxBytes int
+	// EvictionInterval is the minimum interval between two sweeps for expired responses.
+	// Defaults to one minute.
+	EvictionInterval time.Duration
+	// WarmupConcurrency bounds the number of snapshots loaded concurrently by Initialize.
+	// Defaults to one.
+	WarmupConcurrency int
+	// MaxPendingWrites bounds the number of snapshots waiting to be written to the store.
+	// Defaults to 10000.
+	MaxPendingWrites int
+}
+
+func (o XdsCacheOptions) ttl(typeURL string) time.Duration {
+	if ttl, f := o.TTLs[strings.TrimPrefix(typeURL, deltaTypePrefix)]; f {
+		return ttl
+	}
+	return o.DefaultTTL
+}
+
+type xdsCacheEntry struct {
+	key XdsCacheKey
+	// data is the encoded snapshot, as written to the store.
+	data      []byte
+	resources int
+	acked     time.Time
+	// elem is the position of the entry in the LRU list.
+	elem *list.Element
+}
+
+// xdsResourceCache keeps the last ACKed response per (node, typeUrl) in memory and
+// writes it behind to a snapshot store, so it survives pilot restarts.
+//
+// Entries are kept as the encoded snapshots written to the store and are verified every time
+// they are loaded, so a corrupted snapshot or one written by another pilot build is never served.
+type xdsResourceCache struct {
+	mu      sync.Mutex
+	opts    XdsCacheOptions
+	store   *asyncSnapshotStore
+	pending map[string]pendingResponse // keyed by nonce
+	// pendingDeltas holds the delta responses waiting for their ACK, keyed by nonce.
+	pendingDeltas map[string]pendingDelta
+	entries       map[XdsCacheKey]*xdsCacheEntry
+	// lru orders the entries from the most to the least recently used.
+	lru       *list.List
+	bytes     int
+	lastSweep time.Time
+	stats     Stats
+}
+
+var _ XdsResourceCache = &xdsResourceCache{}
+
+func newXdsResourceCache(store xdsSnapshotStore, opts XdsCacheOptions) *xdsResourceCache {
+	if opts.EvictionInterval == 0 {
+		opts.EvictionInterval = time.Minute
+	}
+	if opts.MaxPendingWrites == 0 {
+		opts.MaxPendingWrites = defaultMaxPendingWrites
+	}
+	return &xdsResourceCache{
+		opts:          opts,
+		store:         newAsyncSnapshotStore(store, opts.MaxPendingWrites),
+		pending:       map[string]pendingResponse{},
+		pendingDeltas: map[string]pendingDelta{},
+		entries:       map[XdsCacheKey]*xdsCacheEntry{},
+		lru:           list.New(),
+		lastSweep:     time.Now(),
+	}
+}
+
+// Initialize warms up the cache from the store. Entries of gateways are loaded first, followed by
+// sidecars and any other proxies, so the ingress traffic paths are recovered first after a restart.
+// Within a class, up to WarmupConcurrency entries are loaded concurrently.
+func (c *xdsResourceCache) Initialize() {
+	keys, err := c.store.List()
+	if err != nil {
+		XdsCache.Warnf("failed to list xds cache snapshots: %v", err)
+		return
+	}
+	classes := make([][]XdsCacheKey, len(warmupOrder)+1)
+	for _, key := range keys {
+		classes[warmupPriority(key.NodeID)] = append(classes[warmupPriority(key.NodeID)], key)
+	}
+	now := time.Now()
+	for i, class := range classes {
+		start := time.Now()
+		loaded := c.warmup(class, now)
+		XdsCache.Infof("loaded %d/%d xds cache snapshots of %s proxies in %v",
+			loaded, len(class), warmupClassName(i), time.Since(start))
+	}
+}
+
+// Node IDs are formatted as "<type>~<ip>~<id>~<domain>", see model.ParseServiceNodeWithMetadata.
+var warmupOrder = []string{"router~", "sidecar~"}
+
+func warmupPriority(nodeID string) int {
+	for i, prefix := range warmupOrder {
+		if strings.HasPrefix(nodeID, prefix) {
+			return i
+		}
+	}
+	return len(warmupOrder)
+}
+
+func warmupClassName(priority int) string {
+	if priority < len(warmupOrder) {
+		return strings.TrimSuffix(warmupOrder[priority], "~")
+	}
+	return "other"
+}
+
+// warmup loads the given keys from the store and returns the number of entries loaded.
+// Entries are inserted as the least recently used ones, so the memory budget is spent on
+// the classes warmed up first.
+func (c *xdsResourceCache) warmup(keys []XdsCacheKey, now time.Time) int {
+	concurrency := c.opts.WarmupConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var loaded atomic.Int32
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, key := range keys {
+		key := key
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			data, err := c.store.Read(key)
+			if err != nil || data == nil {
+				XdsCache.Warnf("failed to load xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, err)
+				return
+			}
+			e, err := c.decodeEntry(key, data)
+			if err != nil {
+				c.invalidate(key, err)
+				return
+			}
+			if c.expired(e, now) {
+				c.evict(key)
+				return
+			}
+			c.put(e, false)
+			loaded.Add(1)
+		}()
+	}
+	wg.Wait()
+	return int(loaded.Load())
+}
+
+// Load returns the last ACKed response for the node and type of the request, or nil if nothing is cached.
+// The request must carry the node. Entries not yet loaded in memory are read through from the store, so
+// responses ACKed through other pilot replicas sharing the store are served as well.
+func (c *xdsResourceCache) Load(req *discovery.DiscoveryRequest) (*discovery.DiscoveryResponse, error) {
+	key, err := xdsCacheKeyForRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	e, f := c.entries[key]
+	if f {
+		c.lru.MoveToFront(e.elem)
+	}
+	c.mu.Unlock()
+	var data []byte
+	if f {
+		data = e.data
+	} else {
+		if data, err = c.store.Read(key); err != nil {
+			return nil, err
+		}
+		if data == nil {
+			atomic.AddUint64(&c.stats.Misses, 1)
+			return nil, nil
+		}
+	}
+	resp, acked, err := decodeResponse(key, data)
+	if err != nil {
+		atomic.AddUint64(&c.stats.Misses, 1)
+		c.invalidate(key, err)
+		return nil, err
+	}
+	if !f {
+		e = &xdsCacheEntry{key: key, data: data, resources: len(resp.Resources), acked: acked}
+	}
+	if c.expired(e, time.Now()) {
+		atomic.AddUint64(&c.stats.Misses, 1)
+		c.evict(key)
+		return nil, nil
+	}
+	if !f {
+		c.put(e, true)
+	}
+	atomic.AddUint64(&c.stats.Hits, 1)
+	return resp, nil
+}
+
+func (c *xdsResourceCache) decodeEntry(key XdsCacheKey, data []byte) (*xdsCacheEntry, error) {
+	resp, acked, err := decodeResponse(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return &xdsCacheEntry{key: key, data: data, resources: len(resp.Resources), acked: acked}, nil
+}
+
+func (c *xdsResourceCache) expired(e *xdsCacheEntry, now time.Time) bool {
+	ttl := c.opts.ttl(e.key.TypeURL)
+	return ttl > 0 && now.Sub(e.acked) > ttl
+}
+
+// put inserts or replaces an entry as the most or least recently used one, evicting the least
+// recently used entries if the memory budget is exceeded.
+func (c *xdsResourceCache) put(e *xdsCacheEntry, mostRecent bool) {
+	var evicted []XdsCacheKey
+	c.mu.Lock()
+	if old, f := c.entries[e.key]; f {
+		c.removeLocked(old)
+	}
+	if mostRecent {
+		e.elem = c.lru.PushFront(e)
+	} else {
+		e.elem = c.lru.PushBack(e)
+	}
+	c.entries[e.key] = e
+	c.bytes += len(e.data)
+	for c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes && c.lru.Len() > 1 {
+		victim := c.lru.Back().Value.(*xdsCacheEntry)
+		c.removeLocked(victim)
+		evicted = append(evicted, victim.key)
+	}
+	c.mu.Unlock()
+	c.deleteEvicted(evicted)
+}
+
+func (c *xdsResourceCache) removeLocked(e *xdsCacheEntry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, e.key)
+	c.bytes -= len(e.data)
+}
+
+// evict drops an entry from memory and from the store.
+func (c *xdsResourceCache) evict(key XdsCacheKey) {
+	c.mu.Lock()
+	if e, f := c.entries[key]; f {
+		c.removeLocked(e)
+	}
+	c.mu.Unlock()
+	c.deleteEvicted([]XdsCacheKey{key})
+}
+
+func (c *xdsResourceCache) deleteEvicted(keys []XdsCacheKey) {
+	for _, key := range keys {
+		atomic.AddUint64(&c.stats.Evictions, 1)
+		if err := c.store.Delete(key); err != nil {
+			XdsCache.Warnf("failed to delete evicted xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, err)
+		}
+	}
+}
+
+// maybeEvictExpired evicts the expired entries, if the last sweep is older than the eviction interval.
+func (c *xdsResourceCache) maybeEvictExpired(now time.Time) {
+	var expired []XdsCacheKey
+	c.mu.Lock()
+	if now.Sub(c.lastSweep) >= c.opts.EvictionInterval {
+		c.lastSweep = now
+		for _, e := range c.entries {
+			if c.expired(e, now) {
+				c.removeLocked(e)
+				expired = append(expired, e.key)
+			}
+		}
+	}
+	c.mu.Unlock()
+	c.deleteEvicted(expired)
+}
+
+// invalidate drops an entry which failed verification from memory and from the store.
+func (c *xdsResourceCache) invalidate(key XdsCacheKey, reason error) {
+	XdsCache.Warnf("invalidating xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, reason)
+	c.mu.Lock()
+	if e, f := c.entries[key]; f {
+		c.removeLocked(e)
+	}
+	c.mu.Unlock()
+	atomic.AddUint64(&c.stats.Removals, 1)
+	if err := c.store.Delete(key); err != nil {
+		XdsCache.Warnf("failed to delete xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, err)
+	}
+}
+
+func decodeResponse(key XdsCacheKey, data []byte) (*discovery.DiscoveryResponse, time.Time, error) {
+	payload, acked, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resp := &discovery.DiscoveryResponse{}
+	if err := proto.Unmarshal(payload, resp); err != nil {
+		return nil, time.Time{}, err
+	}
+	if resp.TypeUrl != key.TypeURL {
+		return nil, time.Time{}, fmt.Errorf("snapshot type %q does not match %q", resp.TypeUrl, key.TypeURL)
+	}
+	return resp, acked, nil
+}
+
+func (c *xdsResourceCache) Add(resp *discovery.DiscoveryResponse) error {
+	if resp.Nonce == "" {
+		return fmt.Errorf("xds response of type %s has no nonce", resp.TypeUrl)
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for nonce, p := range c.pending {
+		if now.Sub(p.added) > pendingResponseTimeout {
+			delete(c.pending, nonce)
+		}
+	}
+	c.pending[resp.Nonce] = pendingResponse{resp: resp, added: now}
+	return nil
+}
+
+func (c *xdsResourceCache) Store(req *discovery.DiscoveryRequest) error {
+	if req.ErrorDetail != nil {
+		return fmt.Errorf("refusing to store rejected response with nonce %s", req.ResponseNonce)
+	}
+	key, err := xdsCacheKeyForRequest(req)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	p, f := c.pending[req.ResponseNonce]
+	delete(c.pending, req.ResponseNonce)
+	c.mu.Unlock()
+	if !f {
+		return fmt.Errorf("no pending response for nonce %s", req.ResponseNonce)
+	}
+	if p.resp.TypeUrl != key.TypeURL {
+		return fmt.Errorf("pending response type %s does not match ack type %s", p.resp.TypeUrl, key.TypeURL)
+	}
+	return c.save(key, p.resp)
+}
+
+// save caches an ACKed response in memory and queues it to be persisted.
+func (c *xdsResourceCache) save(key XdsCacheKey, resp *discovery.DiscoveryResponse) error {
+	payload, err := proto.MarshalOptions{Deterministic: true}.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	acked := time.Now()
+	data := encodeSnapshot(payload, acked)
+	c.put(&xdsCacheEntry{key: key, data: data, resources: len(resp.Resources), acked: acked}, true)
+	atomic.AddUint64(&c.stats.Writes, 1)
+	c.maybeEvictExpired(acked)
+	if err := c.store.Save(key, data); err != nil {
+		return fmt.Errorf("failed to persist xds cache snapshot for %s/%s: %v", key.NodeID, key.TypeURL, err)
+	}
+	return nil
+}
+
+func (c *xdsResourceCache) Flush() {
+	c.store.Flush()
+}
+
+func (c *xdsResourceCache) Stats() Stats {
+	return Stats{
+		Evictions: atomic.LoadUint64(&c.stats.Evictions),
+		Hits:      atomic.LoadUint64(&c.stats.Hits),
+		Misses:    atomic.LoadUint64(&c.stats.Misses),
+		Writes:    atomic.LoadUint64(&c.stats.Writes),
+		Removals:  atomic.LoadUint64(&c.stats.Removals),
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *xdsResourceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Bytes returns the total size of the cached responses.
+func (c *xdsResourceCache) Bytes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+func (c *xdsResourceCache) Entries() []XdsCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]XdsCacheEntry, 0, len(c.entries))
+	for k, e := range c.entries {
+		res = append(res, XdsCacheEntry{
+			NodeID:    k.NodeID,
+			TypeURL:   k.TypeURL,
+			Resources: e.resources,
+			Bytes:     len(e.data),
+			LastAck:   e.acked,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].NodeID != res[j].NodeID {
+			return res[i].NodeID < res[j].NodeID
+		}
+		return res[i].TypeURL < res[j].TypeURL
+	})
+	return res
+}
+
+func xdsCacheKeyForRequest(req *discovery.DiscoveryRequest) (XdsCacheKey, error) {
+	if req.GetNode().GetId() == "" {
+		return XdsCacheKey{}, fmt.Errorf("xds request of type %s has no node", req.GetTypeUrl())
+	}
+	return XdsCacheKey{NodeID: req.Node.Id, TypeURL: req.TypeUrl}, nil
+}
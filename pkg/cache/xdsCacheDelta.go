@@ -0,0 +1,157 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// deltaTypePrefix prefixes the type URL of the entries holding the resource versions ACKed
+// over delta xDS, so they never collide with the SotW response of the same type.
+//
+// These entries are stored like SotW responses, whose resources are discovery.Resources
+// carrying only the name and version of the resources ACKed by the node.
+const deltaTypePrefix = "delta:"
+
+type pendingDelta struct {
+	node     string
+	typeURL  string
+	versions map[string]string
+	removed  []string
+	added    time.Time
+}
+
+func (c *xdsResourceCache) AddDelta(node string, resp *discovery.DeltaDiscoveryResponse) error {
+	if resp.Nonce == "" {
+		return fmt.Errorf("delta xds response of type %s has no nonce", resp.TypeUrl)
+	}
+	if node == "" {
+		return fmt.Errorf("delta xds response of type %s has no node", resp.TypeUrl)
+	}
+	p := pendingDelta{
+		node:     node,
+		typeURL:  resp.TypeUrl,
+		versions: make(map[string]string, len(resp.Resources)),
+		removed:  resp.RemovedResources,
+		added:    time.Now(),
+	}
+	for _, r := range resp.Resources {
+		if r.Version == "" {
+			// The version held by the node can not be told, so it must not be trusted later on.
+			p.removed = append(p.removed, r.Name)
+			continue
+		}
+		p.versions[r.Name] = r.Version
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for nonce, pd := range c.pendingDeltas {
+		if p.added.Sub(pd.added) > pendingResponseTimeout {
+			delete(c.pendingDeltas, nonce)
+		}
+	}
+	c.pendingDeltas[resp.Nonce] = p
+	return nil
+}
+
+func (c *xdsResourceCache) StoreDelta(node string, req *discovery.DeltaDiscoveryRequest) error {
+	c.mu.Lock()
+	p, f := c.pendingDeltas[req.ResponseNonce]
+	delete(c.pendingDeltas, req.ResponseNonce)
+	c.mu.Unlock()
+	if req.ErrorDetail != nil {
+		return fmt.Errorf("refusing to store rejected delta response with nonce %s", req.ResponseNonce)
+	}
+	if !f {
+		return fmt.Errorf("no pending delta response for nonce %s", req.ResponseNonce)
+	}
+	if p.node != node || p.typeURL != req.TypeUrl {
+		return fmt.Errorf("pending delta response %s/%s does not match ack %s/%s", p.node, p.typeURL, node, req.TypeUrl)
+	}
+
+	key := XdsCacheKey{NodeID: node, TypeURL: deltaTypePrefix + p.typeURL}
+	versions := c.cachedDeltaVersions(key)
+	if versions == nil {
+		versions = map[string]string{}
+	}
+	for _, name := range p.removed {
+		delete(versions, name)
+	}
+	for name, version := range p.versions {
+		versions[name] = version
+	}
+
+	resp := &discovery.DiscoveryResponse{TypeUrl: key.TypeURL, Resources: make([]*anypb.Any, 0, len(versions))}
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r, err := anypb.New(&discovery.Resource{Name: name, Version: versions[name]})
+		if err != nil {
+			return err
+		}
+		resp.Resources = append(resp.Resources, r)
+	}
+	return c.save(key, resp)
+}
+
+func (c *xdsResourceCache) LoadDeltaVersions(node, typeURL string) (map[string]string, error) {
+	resp, err := c.Load(&discovery.DiscoveryRequest{Node: &core.Node{Id: node}, TypeUrl: deltaTypePrefix + typeURL})
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	return deltaVersions(resp)
+}
+
+// cachedDeltaVersions returns the versions held in memory for the key, or nil if there are none. Unlike
+// LoadDeltaVersions it never reads through from the store, so merging an ACK does not wait for the store.
+// The versions of entries evicted from memory were deleted from the store as well, so none are missed.
+func (c *xdsResourceCache) cachedDeltaVersions(key XdsCacheKey) map[string]string {
+	c.mu.Lock()
+	e, f := c.entries[key]
+	c.mu.Unlock()
+	if !f || c.expired(e, time.Now()) {
+		return nil
+	}
+	resp, _, err := decodeResponse(key, e.data)
+	if err != nil {
+		return nil
+	}
+	versions, err := deltaVersions(resp)
+	if err != nil {
+		return nil
+	}
+	return versions
+}
+
+func deltaVersions(resp *discovery.DiscoveryResponse) (map[string]string, error) {
+	versions := make(map[string]string, len(resp.Resources))
+	for _, a := range resp.Resources {
+		r := &discovery.Resource{}
+		if err := a.UnmarshalTo(r); err != nil {
+			return nil, err
+		}
+		versions[r.Name] = r.Version
+	}
+	return versions, nil
+}
@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"istio.io/istio/pkg/version"
+)
+
+// snapshotMagic prefixes every stored snapshot and identifies the envelope format.
+const snapshotMagic = "istio-xds-snapshot/1"
+
+var (
+	errSnapshotCorrupted    = errors.New("xds cache snapshot is corrupted")
+	errSnapshotVersionSkew  = errors.New("xds cache snapshot was written by a different pilot build")
+	errSnapshotUnrecognized = errors.New("unrecognized xds cache snapshot format")
+)
+
+// snapshotBuild identifies the pilot build writing snapshots. Responses generated by another
+// build may carry config the proxies connected to this build do not expect, so they are never served.
+var snapshotBuild = version.Info.String()
+
+// encodeSnapshot wraps a serialized DiscoveryResponse in an envelope recording the pilot
+// build, the time the response was ACKed and the SHA-256 of the payload:
+//
+//	istio-xds-snapshot/1\n<build>\n<ack unix nanos>\n<hex sha256>\n<payload>
+func encodeSnapshot(payload []byte, acked time.Time) []byte {
+	sum := sha256.Sum256(payload)
+	var buf bytes.Buffer
+	buf.Grow(len(snapshotMagic) + len(snapshotBuild) + 20 + hex.EncodedLen(len(sum)) + len(payload) + 4)
+	buf.WriteString(snapshotMagic + "\n")
+	buf.WriteString(snapshotBuild + "\n")
+	buf.WriteString(strconv.FormatInt(acked.UnixNano(), 10) + "\n")
+	buf.WriteString(hex.EncodeToString(sum[:]) + "\n")
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// decodeSnapshot verifies the envelope written by encodeSnapshot and returns the payload
+// and the time it was ACKed.
+func decodeSnapshot(data []byte) ([]byte, time.Time, error) {
+	header := make([][]byte, 0, 4)
+	rest := data
+	for i := 0; i < 4; i++ {
+		line, r, ok := bytes.Cut(rest, []byte("\n"))
+		if !ok {
+			return nil, time.Time{}, errSnapshotUnrecognized
+		}
+		header = append(header, line)
+		rest = r
+	}
+	if string(header[0]) != snapshotMagic {
+		return nil, time.Time{}, errSnapshotUnrecognized
+	}
+	if string(header[1]) != snapshotBuild {
+		return nil, time.Time{}, fmt.Errorf("%w: %s", errSnapshotVersionSkew, header[1])
+	}
+	acked, err := strconv.ParseInt(string(header[2]), 10, 64)
+	if err != nil {
+		return nil, time.Time{}, errSnapshotUnrecognized
+	}
+	sum := sha256.Sum256(rest)
+	if hex.EncodeToString(sum[:]) != string(header[3]) {
+		return nil, time.Time{}, errSnapshotCorrupted
+	}
+	return rest, time.Unix(0, acked), nil
+}
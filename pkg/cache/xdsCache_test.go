@@ -0,0 +1,452 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const testClusterType = "type.googleapis.com/envoy.config.cluster.v3.Cluster"
+
+func testResponse(nonce string) *discovery.DiscoveryResponse {
+	return &discovery.DiscoveryResponse{
+		VersionInfo: "v1",
+		TypeUrl:     testClusterType,
+		Nonce:       nonce,
+		Resources:   []*anypb.Any{{TypeUrl: testClusterType, Value: []byte("cluster")}},
+	}
+}
+
+func testAck(node, nonce string) *discovery.DiscoveryRequest {
+	return &discovery.DiscoveryRequest{
+		Node:          &core.Node{Id: node},
+		TypeUrl:       testClusterType,
+		ResponseNonce: nonce,
+	}
+}
+
+func TestFileXdsResourceCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Initialize()
+
+	if resp, err := c.Load(testAck("router~1.1.1.1~gw.ns~ns.svc.cluster.local", "")); err != nil || resp != nil {
+		t.Fatalf("expected empty cache, got %v, %v", resp, err)
+	}
+
+	resp := testResponse("n1")
+	if err := c.Add(resp); err != nil {
+		t.Fatal(err)
+	}
+	// A NACK must not be persisted.
+	nack := testAck("router~1.1.1.1~gw.ns~ns.svc.cluster.local", "n1")
+	nack.ErrorDetail = &status.Status{Message: "rejected"}
+	if err := c.Store(nack); err == nil {
+		t.Fatal("expected error storing a NACK")
+	}
+	if err := c.Add(resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(testAck("router~1.1.1.1~gw.ns~ns.svc.cluster.local", "n1")); err != nil {
+		t.Fatal(err)
+	}
+	// The pending response is consumed by the ACK.
+	if err := c.Store(testAck("router~1.1.1.1~gw.ns~ns.svc.cluster.local", "n1")); err == nil {
+		t.Fatal("expected error storing an unknown nonce")
+	}
+	c.Flush()
+
+	// A new cache over the same directory serves the last ACKed response.
+	restarted, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Initialize()
+	got, err := restarted.Load(testAck("router~1.1.1.1~gw.ns~ns.svc.cluster.local", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, resp) {
+		t.Fatalf("got %v, want %v", got, resp)
+	}
+	if stats := restarted.Stats(); stats.Hits != 1 || stats.Misses != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if entries := restarted.Entries(); len(entries) != 1 || entries[0].Resources != 1 || entries[0].LastAck.IsZero() {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	if _, err := restarted.Load(&discovery.DiscoveryRequest{TypeUrl: testClusterType}); err == nil {
+		t.Fatal("expected error loading without node")
+	}
+}
+
+func TestXdsResourceCacheVerification(t *testing.T) {
+	node := "router~1.1.1.1~gw.ns~ns.svc.cluster.local"
+	setup := func(t *testing.T) (string, XdsResourceCache) {
+		dir := t.TempDir()
+		c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add(testResponse("n1")); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Store(testAck(node, "n1")); err != nil {
+			t.Fatal(err)
+		}
+		c.Flush()
+		return dir, c
+	}
+	snapshotFile := func(t *testing.T, dir string) string {
+		files, err := filepath.Glob(filepath.Join(dir, "*"+snapshotFileSuffix))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("expected a single snapshot, got %v, %v", files, err)
+		}
+		return files[0]
+	}
+
+	t.Run("corrupted", func(t *testing.T) {
+		dir, _ := setup(t)
+		f := snapshotFile(t, dir)
+		data, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[len(data)-1] ^= 0xff
+		if err := os.WriteFile(f, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		restarted, _ := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+		if _, err := restarted.Load(testAck(node, "")); !errors.Is(err, errSnapshotCorrupted) {
+			t.Fatalf("expected corrupted snapshot error, got %v", err)
+		}
+		restarted.Flush()
+		if _, err := os.Stat(f); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected corrupted snapshot to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("version skew", func(t *testing.T) {
+		dir, _ := setup(t)
+		old := snapshotBuild
+		snapshotBuild = "1.0.0-newer-Clean"
+		t.Cleanup(func() { snapshotBuild = old })
+		restarted, _ := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+		restarted.Initialize()
+		if resp, err := restarted.Load(testAck(node, "")); err != nil || resp != nil {
+			t.Fatalf("expected snapshot of another build to be dropped, got %v, %v", resp, err)
+		}
+		restarted.Flush()
+		if files, _ := filepath.Glob(filepath.Join(dir, "*"+snapshotFileSuffix)); len(files) != 0 {
+			t.Fatalf("expected snapshot of another build to be deleted, got %v", files)
+		}
+	})
+}
+
+func TestXdsResourceCacheEviction(t *testing.T) {
+	store := func(t *testing.T, c XdsResourceCache, node string) {
+		t.Helper()
+		if err := c.Add(testResponse(node)); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Store(testAck(node, node)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("ttl", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{
+			DefaultTTL: time.Hour,
+			TTLs:       map[string]time.Duration{testClusterType: time.Nanosecond},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store(t, c, "a")
+		time.Sleep(time.Millisecond)
+		if resp, err := c.Load(testAck("a", "")); err != nil || resp != nil {
+			t.Fatalf("expected expired response to be evicted, got %v, %v", resp, err)
+		}
+		c.Flush()
+		if files, _ := filepath.Glob(filepath.Join(dir, "*"+snapshotFileSuffix)); len(files) != 0 {
+			t.Fatalf("expected expired snapshot to be deleted, got %v", files)
+		}
+		if stats := c.Stats(); stats.Evictions != 1 {
+			t.Fatalf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		dir := t.TempDir()
+		// A budget smaller than a single response keeps only the most recently used one.
+		c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{MaxBytes: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		store(t, c, "a")
+		store(t, c, "b")
+		entries := c.Entries()
+		if len(entries) != 1 || entries[0].NodeID != "b" {
+			t.Fatalf("expected only the most recent entry to be kept, got %+v", entries)
+		}
+		if resp, _ := c.Load(testAck("a", "")); resp != nil {
+			t.Fatalf("expected evicted response not to be served from the store")
+		}
+		c.Flush()
+	})
+}
+
+func TestXdsResourceCacheWarmupPriority(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := []string{
+		"sidecar~10.0.0.1~a.ns~ns.svc.cluster.local",
+		"router~10.0.0.2~gw.ns~ns.svc.cluster.local",
+		"sidecar~10.0.0.3~b.ns~ns.svc.cluster.local",
+	}
+	for _, node := range nodes {
+		if err := c.Add(testResponse(node)); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Store(testAck(node, node)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Flush()
+
+	// With a budget for a single response, the gateway wins over the sidecars.
+	restarted, err := NewFileXdsResourceCache(dir, XdsCacheOptions{MaxBytes: 1, WarmupConcurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Initialize()
+	entries := restarted.Entries()
+	if len(entries) != 1 || entries[0].NodeID != nodes[1] {
+		t.Fatalf("expected only the gateway entry to be warmed up, got %+v", entries)
+	}
+}
+
+func TestXdsResourceCacheDelta(t *testing.T) {
+	node := "sidecar~10.0.0.1~a.ns~ns.svc.cluster.local"
+	dir := t.TempDir()
+	c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := func(nonce string, resources map[string]string, removed ...string) {
+		t.Helper()
+		resp := &discovery.DeltaDiscoveryResponse{TypeUrl: testClusterType, Nonce: nonce, RemovedResources: removed}
+		for name, version := range resources {
+			resp.Resources = append(resp.Resources, &discovery.Resource{Name: name, Version: version})
+		}
+		if err := c.AddDelta(node, resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ack := func(nonce string) error {
+		return c.StoreDelta(node, &discovery.DeltaDiscoveryRequest{TypeUrl: testClusterType, ResponseNonce: nonce})
+	}
+
+	if v, err := c.LoadDeltaVersions(node, testClusterType); err != nil || v != nil {
+		t.Fatalf("expected no versions, got %v, %v", v, err)
+	}
+	push("n1", map[string]string{"a": "1", "b": "1", "c": "1"})
+	if err := ack("n1"); err != nil {
+		t.Fatal(err)
+	}
+	// Resources without a version are forgotten, as the version held by the proxy is unknown.
+	push("n2", map[string]string{"a": "2", "c": ""}, "b")
+	nack := &discovery.DeltaDiscoveryRequest{TypeUrl: testClusterType, ResponseNonce: "n2", ErrorDetail: &status.Status{Message: "rejected"}}
+	if err := c.StoreDelta(node, nack); err == nil {
+		t.Fatal("expected error storing a NACK")
+	}
+	if err := ack("n2"); err == nil {
+		t.Fatal("expected the NACKed response to be dropped")
+	}
+	push("n3", map[string]string{"a": "2", "c": ""}, "b")
+	if err := ack("n3"); err != nil {
+		t.Fatal(err)
+	}
+	c.Flush()
+
+	// The merged versions survive a restart and do not collide with SotW responses.
+	restarted, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.Initialize()
+	got, err := restarted.LoadDeltaVersions(node, testClusterType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["a"] != "2" {
+		t.Fatalf("unexpected versions %v", got)
+	}
+	if resp, err := restarted.Load(testAck(node, "")); err != nil || resp != nil {
+		t.Fatalf("expected no SotW response, got %v, %v", resp, err)
+	}
+}
+
+func TestXdsResourceCacheDeltaAckFromMemory(t *testing.T) {
+	node := "sidecar~10.0.0.1~a.ns~ns.svc.cluster.local"
+	backend := &blockingSnapshotStore{unblock: make(chan struct{}), data: map[XdsCacheKey][]byte{}}
+	c := newXdsResourceCache(backend, XdsCacheOptions{})
+	for i, versions := range []map[string]string{{"a": "1"}, {"b": "1"}, {"a": "2"}} {
+		nonce := fmt.Sprint(i)
+		resp := &discovery.DeltaDiscoveryResponse{TypeUrl: testClusterType, Nonce: nonce}
+		for name, version := range versions {
+			resp.Resources = append(resp.Resources, &discovery.Resource{Name: name, Version: version})
+		}
+		if err := c.AddDelta(node, resp); err != nil {
+			t.Fatal(err)
+		}
+		// The ACKs are merged while the store is blocked.
+		if err := c.StoreDelta(node, &discovery.DeltaDiscoveryRequest{TypeUrl: testClusterType, ResponseNonce: nonce}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := c.LoadDeltaVersions(node, testClusterType)
+	if err != nil || len(got) != 2 || got["a"] != "2" || got["b"] != "1" {
+		t.Fatalf("unexpected versions %v, %v", got, err)
+	}
+	close(backend.unblock)
+	c.Flush()
+	if backend.reads != 0 {
+		t.Fatalf("got %d reads of the store, want the ACKs merged in memory", backend.reads)
+	}
+	versions, err := deltaVersions(mustDecode(t, XdsCacheKey{NodeID: node, TypeURL: deltaTypePrefix + testClusterType}, backend))
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("got stored versions %v, %v, want the merged versions", versions, err)
+	}
+}
+
+func mustDecode(t *testing.T, key XdsCacheKey, s xdsSnapshotStore) *discovery.DiscoveryResponse {
+	t.Helper()
+	data, err := s.Read(key)
+	if err != nil || data == nil {
+		t.Fatalf("got snapshot %v, %v", data, err)
+	}
+	resp, _, err := decodeResponse(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestFileXdsResourceCacheCrashConsistency(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := testResponse("a")
+	if err := c.Add(resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(testAck("a", "a")); err != nil {
+		t.Fatal(err)
+	}
+	c.Flush()
+	store := &fileSnapshotStore{dir: dir}
+	snapshot, err := os.ReadFile(store.path(XdsCacheKey{NodeID: "a", TypeURL: testClusterType}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash while replacing the snapshot of a leaves a temporary file, and a filesystem not ordering the rename
+	// after the data leaves a torn snapshot of b.
+	tmp := store.path(XdsCacheKey{NodeID: "a", TypeURL: testClusterType}) + snapshotTempInfix + "1234"
+	if err := os.WriteFile(tmp, snapshot[:len(snapshot)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(store.path(XdsCacheKey{NodeID: "b", TypeURL: testClusterType}), snapshot[:len(snapshot)/2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewFileXdsResourceCache(dir, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(tmp); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the temporary file to be removed, got %v", err)
+	}
+	restarted.Initialize()
+	got, err := restarted.Load(testAck("a", ""))
+	if err != nil || !proto.Equal(got, resp) {
+		t.Fatalf("expected the previous snapshot to be served, got %v, %v", got, err)
+	}
+	if got, _ := restarted.Load(testAck("b", "")); got != nil {
+		t.Fatalf("expected the torn snapshot not to be served, got %v", got)
+	}
+}
+
+func TestFileSnapshotStoreManyKeys(t *testing.T) {
+	s := &fileSnapshotStore{dir: t.TempDir()}
+	const nodes = 1000
+	types := []string{testClusterType, deltaTypePrefix + testClusterType}
+	want := map[XdsCacheKey]bool{}
+	for i := 0; i < nodes; i++ {
+		for _, typeURL := range types {
+			key := XdsCacheKey{NodeID: fmt.Sprintf("sidecar~10.0.%d.%d~app-%d.ns~ns.svc.cluster.local", i/256, i%256, i), TypeURL: typeURL}
+			if err := s.Save(key, []byte(key.NodeID+key.TypeURL)); err != nil {
+				t.Fatal(err)
+			}
+			want[key] = true
+		}
+	}
+	keys, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(want))
+	}
+	for _, key := range keys {
+		if !want[key] {
+			t.Fatalf("unexpected key %+v", key)
+		}
+		data, err := s.Read(key)
+		if err != nil || string(data) != key.NodeID+key.TypeURL {
+			t.Fatalf("got snapshot %q, %v for %+v", data, err, key)
+		}
+	}
+	for _, key := range keys[:len(keys)/2] {
+		if err := s.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys, _ := s.List(); len(keys) != len(want)/2 {
+		t.Fatalf("got %d keys after deleting half of them, want %d", len(keys), len(want)/2)
+	}
+}
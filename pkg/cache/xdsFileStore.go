@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	snapshotFileSuffix = ".pb"
+	// snapshotTempInfix marks the temporary files snapshots are written to before being renamed.
+	snapshotTempInfix = ".tmp."
+)
+
+// fileSnapshotStore stores each snapshot in its own file under dir.
+//
+// The cache only ever replaces or deletes the whole snapshot of a (node, typeUrl), so it needs neither
+// transactions across keys nor the compaction of an embedded key-value store, and adds no dependency to pilot.
+// A snapshot is written to a temporary file, synced, then renamed over the previous one, so a crash leaves either
+// the previous or the new snapshot. Temporary files left by a crash are removed when the store is opened, and a
+// snapshot torn by a filesystem not ordering the rename after the data fails verification and is dropped.
+type fileSnapshotStore struct {
+	dir string
+}
+
+var _ xdsSnapshotStore = &fileSnapshotStore{}
+
+// NewFileXdsResourceCache creates an XdsResourceCache persisting ACKed responses to files under dir.
+// The directory is created if it does not exist.
+func NewFileXdsResourceCache(dir string, opts XdsCacheOptions) (XdsResourceCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create xds cache directory %s: %v", dir, err)
+	}
+	s := &fileSnapshotStore{dir: dir}
+	if err := s.removeTempFiles(); err != nil {
+		return nil, fmt.Errorf("failed to clean up xds cache directory %s: %v", dir, err)
+	}
+	return newXdsResourceCache(s, opts), nil
+}
+
+// removeTempFiles removes the temporary files of the snapshots being written when pilot stopped.
+func (s *fileSnapshotStore) removeTempFiles() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.Contains(f.Name(), snapshotFileSuffix+snapshotTempInfix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// The node ID and type URL are encoded with the URL-safe base64 alphabet, which
+// excludes '.', so they can be joined into a single valid file name.
+func (s *fileSnapshotStore) path(key XdsCacheKey) string {
+	return filepath.Join(s.dir, base64.RawURLEncoding.EncodeToString([]byte(key.NodeID))+"."+
+		base64.RawURLEncoding.EncodeToString([]byte(key.TypeURL))+snapshotFileSuffix)
+}
+
+func (s *fileSnapshotStore) Save(key XdsCacheKey, data []byte) (err error) {
+	path := s.path(key)
+	tmp, err := os.CreateTemp(s.dir, filepath.Base(path)+snapshotTempInfix)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	// Sync the data before the rename, so a crash can not leave an empty snapshot in place of the previous one.
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *fileSnapshotStore) Read(key XdsCacheKey) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *fileSnapshotStore) Delete(key XdsCacheKey) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileSnapshotStore) List() ([]XdsCacheKey, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []XdsCacheKey
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), snapshotFileSuffix)
+		if f.IsDir() || !ok {
+			continue
+		}
+		node, typeURL, ok := strings.Cut(name, ".")
+		if !ok {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(node)
+		if err != nil {
+			continue
+		}
+		t, err := base64.RawURLEncoding.DecodeString(typeURL)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, XdsCacheKey{NodeID: string(n), TypeURL: string(t)})
+	}
+	return keys, nil
+}
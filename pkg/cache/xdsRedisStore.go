@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisKeyPrefix   = "istio-xds"
+	defaultRedisDialTimeout = 5 * time.Second
+)
+
+// RedisOptions configures a Redis backed XdsResourceCache.
+type RedisOptions struct {
+	// Addrs are the addresses of the Redis server, or the seed nodes of a Redis cluster.
+	Addrs []string
+	// Cluster uses the cluster mode with a single seed node. It is implied by several addresses.
+	Cluster bool
+	// Password is used to AUTH to every node, if set.
+	Password string
+	// DB selects the database. Only valid for standalone Redis.
+	DB int
+	// KeyPrefix namespaces the keys written by pilot. Replicas sharing a cache must use the same prefix.
+	KeyPrefix string
+	// DialTimeout bounds connecting and each command round trip.
+	DialTimeout time.Duration
+}
+
+// NewRedisXdsResourceCache creates an XdsResourceCache persisting ACKed responses to Redis, so
+// that multiple pilot replicas can share the last-known-good config of proxies.
+//
+// Snapshots are stored under "{<prefix>}:<node>:<typeUrl>" and indexed by the set "{<prefix>}:index".
+// The keys share a hash tag, so in cluster mode they live in a single slot and a snapshot is written
+// together with its index member in a single MULTI/EXEC transaction.
+func NewRedisXdsResourceCache(opts RedisOptions, cacheOpts XdsCacheOptions) (XdsResourceCache, error) {
+	if len(opts.Addrs) == 0 {
+		return nil, errors.New("no redis address configured")
+	}
+	if opts.KeyPrefix == "" {
+		opts.KeyPrefix = defaultRedisKeyPrefix
+	}
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = defaultRedisDialTimeout
+	}
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:         opts.Addrs,
+		IsClusterMode: opts.Cluster,
+		Password:      opts.Password,
+		DB:            opts.DB,
+		DialTimeout:   opts.DialTimeout,
+		ReadTimeout:   opts.DialTimeout,
+		WriteTimeout:  opts.DialTimeout,
+	})
+	return newXdsResourceCache(&redisSnapshotStore{
+		client:  client,
+		prefix:  opts.KeyPrefix,
+		timeout: opts.DialTimeout,
+	}, cacheOpts), nil
+}
+
+type redisSnapshotStore struct {
+	client  redis.UniversalClient
+	prefix  string
+	timeout time.Duration
+}
+
+var _ xdsSnapshotStore = &redisSnapshotStore{}
+
+func (s *redisSnapshotStore) key(key XdsCacheKey) string {
+	return "{" + s.prefix + "}:" + key.NodeID + ":" + key.TypeURL
+}
+
+func (s *redisSnapshotStore) indexKey() string {
+	return "{" + s.prefix + "}:index"
+}
+
+// indexMember encodes a key as an index member. Node IDs never contain a newline.
+func indexMember(key XdsCacheKey) string {
+	return key.NodeID + "\n" + key.TypeURL
+}
+
+func (s *redisSnapshotStore) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+func (s *redisSnapshotStore) Save(key XdsCacheKey, data []byte) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key(key), data, 0)
+		pipe.SAdd(ctx, s.indexKey(), indexMember(key))
+		return nil
+	})
+	return err
+}
+
+func (s *redisSnapshotStore) Read(key XdsCacheKey) ([]byte, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	data, err := s.client.Get(ctx, s.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *redisSnapshotStore) Delete(key XdsCacheKey) error {
+	ctx, cancel := s.context()
+	defer cancel()
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(key))
+		pipe.SRem(ctx, s.indexKey(), indexMember(key))
+		return nil
+	})
+	return err
+}
+
+func (s *redisSnapshotStore) List() ([]XdsCacheKey, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+	members, err := s.client.SMembers(ctx, s.indexKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]XdsCacheKey, 0, len(members))
+	for _, m := range members {
+		node, typeURL, ok := strings.Cut(m, "\n")
+		if !ok {
+			continue
+		}
+		keys = append(keys, XdsCacheKey{NodeID: node, TypeURL: typeURL})
+	}
+	return keys, nil
+}
@@ -0,0 +1,84 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRedisXdsResourceCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	node := "sidecar~10.0.0.1~a.ns~ns.svc.cluster.local"
+
+	c, err := NewRedisXdsResourceCache(RedisOptions{Addrs: []string{server.Addr()}}, XdsCacheOptions{MaxBytes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Initialize()
+	resp := testResponse("n1")
+	if err := c.Add(resp); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(testAck(node, "n1")); err != nil {
+		t.Fatal(err)
+	}
+	c.Flush()
+	key := "{" + defaultRedisKeyPrefix + "}:" + node + ":" + testClusterType
+	index := "{" + defaultRedisKeyPrefix + "}:index"
+	if !server.Exists(key) {
+		t.Fatalf("expected snapshot to be written to %s, got keys %v", key, server.Keys())
+	}
+	if members, _ := server.Members(index); len(members) != 1 {
+		t.Fatalf("expected snapshot to be indexed, got %v", members)
+	}
+
+	// Another replica sharing the same Redis serves the cached response.
+	replica, err := NewRedisXdsResourceCache(RedisOptions{Addrs: []string{server.Addr()}}, XdsCacheOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.Initialize()
+	got, err := replica.Load(testAck(node, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(got, resp) {
+		t.Fatalf("got %v, want %v", got, resp)
+	}
+
+	// Evicting the snapshot removes it together with its index member.
+	if err := c.Add(testResponse("n2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Store(testAck("other", "n2")); err != nil {
+		t.Fatal(err)
+	}
+	c.Flush()
+	if server.Exists(key) {
+		t.Fatalf("expected evicted snapshot to be deleted")
+	}
+	if members, _ := server.Members(index); len(members) != 1 || members[0] != indexMember(XdsCacheKey{NodeID: "other", TypeURL: testClusterType}) {
+		t.Fatalf("expected evicted snapshot to be removed from the index, got %v", members)
+	}
+}
+
+func TestRedisXdsResourceCacheNoAddress(t *testing.T) {
+	if _, err := NewRedisXdsResourceCache(RedisOptions{}, XdsCacheOptions{}); err == nil {
+		t.Fatal("expected error without address")
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"istio.io/istio/pkg/config/analysis/analyzers/sidecar"
 	"istio.io/istio/pkg/config/analysis/analyzers/telemetry"
 	"istio.io/istio/pkg/config/analysis/analyzers/virtualservice"
+	"istio.io/istio/pkg/config/analysis/analyzers/wasmplugin"
 	"istio.io/istio/pkg/config/analysis/analyzers/webhook"
 )
 
@@ -47,6 +48,7 @@ func All() []analysis.Analyzer {
 		&gateway.CertificateAnalyzer{},
 		&gateway.SecretAnalyzer{},
 		&gateway.ConflictingGatewayAnalyzer{},
+		&gateway.PatchAnalyzer{}, // Added by ingress
 		&injection.Analyzer{},
 		&injection.ImageAnalyzer{},
 		&injection.ImageAutoAnalyzer{},
@@ -68,6 +70,8 @@ func All() []analysis.Analyzer {
 		&telemetry.SelectorAnalyzer{},
 		&telemetry.DefaultSelectorAnalyzer{},
 		&telemetry.LightstepAnalyzer{},
+		&wasmplugin.ConfigAnalyzer{}, // Added by ingress
+		&wasmplugin.ModuleAnalyzer{}, // Added by ingress
 	}
 
 	analyzers = append(analyzers, schema.AllValidationAnalyzers()...)
@@ -15,6 +15,7 @@
 package analyzers
 
 import (
+	"encoding/binary"
 	"fmt"
 	"os"
 	"regexp"
@@ -41,12 +42,14 @@ import (
 	"istio.io/istio/pkg/config/analysis/analyzers/sidecar"
 	"istio.io/istio/pkg/config/analysis/analyzers/telemetry"
 	"istio.io/istio/pkg/config/analysis/analyzers/virtualservice"
+	"istio.io/istio/pkg/config/analysis/analyzers/wasmplugin"
 	"istio.io/istio/pkg/config/analysis/analyzers/webhook"
 	"istio.io/istio/pkg/config/analysis/diag"
 	"istio.io/istio/pkg/config/analysis/local"
 	"istio.io/istio/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/wasm"
 )
 
 type message struct {
@@ -196,6 +199,15 @@ var testGrid = []testCase{
 		analyzer:   &gateway.ConflictingGatewayAnalyzer{},
 		expected:   []message{},
 	},
+	{
+		name:       "gatewayPatch",
+		inputFiles: []string{"testdata/gateway-patch.yaml"},
+		analyzer:   &gateway.PatchAnalyzer{},
+		expected: []message{
+			{msg.InvalidAnnotation, "Gateway default/bad-duration"},
+			{msg.InvalidAnnotation, "Gateway default/host-header"},
+		},
+	},
 	{
 		name:       "istioInjection",
 		inputFiles: []string{"testdata/injection.yaml"},
@@ -828,6 +840,48 @@ var testGrid = []testCase{
 			{msg.Deprecated, "Telemetry istio-system/mesh-default"},
 		},
 	},
+	{
+		name:       "wasmPluginConfig",
+		inputFiles: []string{"testdata/wasmplugin.yaml"},
+		analyzer:   &wasmplugin.ConfigAnalyzer{},
+		expected: []message{
+			{msg.WasmPluginPullSecretNotFound, "WasmPlugin default/missing-secret"},
+			{msg.WasmPluginFailOpenInSecurityPhase, "WasmPlugin default/authn-fail-open"},
+			{msg.ConflictingWasmPluginPriorities, "WasmPlugin default/authz-a"},
+			{msg.ConflictingWasmPluginPriorities, "WasmPlugin default/authz-b"},
+		},
+	},
+	{
+		name:       "wasmPluginModule",
+		inputFiles: []string{"testdata/wasmplugin.yaml"},
+		analyzer:   &wasmplugin.ModuleAnalyzer{Fetch: fetchTestModule},
+		expected: []message{
+			{msg.WasmPluginModuleUnreachable, "WasmPlugin default/unreachable"},
+			{msg.WasmPluginModuleUnreachable, "WasmPlugin default/checksum-mismatch"},
+			{msg.InvalidWasmPluginConfig, "WasmPlugin default/invalid-config"},
+		},
+	},
+}
+
+// fetchTestModule serves the modules of testdata/wasmplugin.yaml. The block modules embed the JSON Schema of
+// their config, and the private ones require a pull secret.
+func fetchTestModule(url string, pullSecret []byte) ([]byte, error) {
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	switch {
+	case strings.Contains(url, "unreachable"):
+		return nil, fmt.Errorf("GET %s: 404 Not Found", url)
+	case strings.Contains(url, "private"):
+		if pullSecret == nil {
+			return nil, fmt.Errorf("%s: unauthorized", url)
+		}
+		return module, nil
+	}
+	name, schema := wasm.PluginConfigSchemaSection, `{"type": "object", "required": ["block_urls"]}`
+	section := append(binary.AppendUvarint(nil, uint64(len(name))), name...)
+	section = append(section, schema...)
+	module = append(module, 0x00)
+	module = binary.AppendUvarint(module, uint64(len(section)))
+	return append(module, section...), nil
 }
 
 // regex patterns for analyzer names that should be explicitly ignored for testing
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// PatchAnalyzer checks the higress.io/gateway-patch annotation of the gateways, which is ignored by the gateways
+// if it is invalid.
+type PatchAnalyzer struct{}
+
+var _ analysis.Analyzer = &PatchAnalyzer{}
+
+// Metadata implements analysis.Analyzer
+func (*PatchAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "gateway.PatchAnalyzer",
+		Description: "Checks the patches of the gateways for correctness",
+		Inputs: []config.GroupVersionKind{
+			gvk.Gateway,
+		},
+	}
+}
+
+// Analyze implements analysis.Analyzer
+func (*PatchAnalyzer) Analyze(ctx analysis.Context) {
+	ctx.ForEach(gvk.Gateway, func(r *resource.Instance) bool {
+		value, ok := r.Metadata.Annotations[constants.GatewayPatchAnnotation]
+		if !ok {
+			return true
+		}
+		if _, err := gatewaypatch.Parse(value); err != nil {
+			ctx.Report(gvk.Gateway, msg.NewInvalidAnnotation(r, constants.GatewayPatchAnnotation, err.Error()))
+		}
+		return true
+	})
+}
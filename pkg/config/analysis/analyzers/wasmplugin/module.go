@@ -0,0 +1,111 @@
+package wasmplugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+	"istio.io/istio/pkg/util/protomarshal"
+	"istio.io/istio/pkg/wasm"
+)
+
+// fetchTimeout bounds the fetch of each module.
+const fetchTimeout = 10 * time.Second
+
+// ModuleAnalyzer fetches the modules of the WasmPlugins the way the agents do, and checks the plugin configs
+// against the JSON Schema embedded in the modules.
+type ModuleAnalyzer struct {
+	// Fetch fetches the module of the URL with the docker config of the pull secret, if any. The modules are
+	// fetched from their registries or servers when unset.
+	Fetch func(url string, pullSecret []byte) ([]byte, error)
+}
+
+var _ analysis.Analyzer = &ModuleAnalyzer{}
+
+// Metadata implements analysis.Analyzer
+func (*ModuleAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "wasmplugin.ModuleAnalyzer",
+		Description: "Checks that the modules of the WasmPlugins can be fetched and accept their plugin config",
+		Inputs: []config.GroupVersionKind{
+			gvk.WasmPlugin,
+			gvk.Secret,
+		},
+	}
+}
+
+type fetchResult struct {
+	module []byte
+	err    error
+}
+
+// Analyze implements analysis.Analyzer
+func (a *ModuleAnalyzer) Analyze(ctx analysis.Context) {
+	fetch := a.Fetch
+	if fetch == nil {
+		fetch = fetchModule
+	}
+	// Plugins often share a module, which is fetched once.
+	fetched := map[string]fetchResult{}
+
+	ctx.ForEach(gvk.WasmPlugin, func(r *resource.Instance) bool {
+		plugin := r.Message.(*extensions.WasmPlugin)
+		url := moduleURL(plugin)
+		// Local modules are read from the file system of the proxies.
+		if strings.HasPrefix(url, "file://") {
+			return true
+		}
+		var secret []byte
+		if name := plugin.GetImagePullSecret(); name != "" {
+			// Missing pull secrets are reported by the ConfigAnalyzer.
+			if secret = pullSecret(ctx, r.Metadata.FullName.Namespace, name); secret == nil {
+				return true
+			}
+		}
+		key := url + "/" + string(secret)
+		res, ok := fetched[key]
+		if !ok {
+			res.module, res.err = fetch(url, secret)
+			fetched[key] = res
+		}
+		if res.err != nil {
+			ctx.Report(gvk.WasmPlugin, msg.NewWasmPluginModuleUnreachable(r, plugin.GetUrl(), res.err.Error()))
+			return true
+		}
+		if want := plugin.GetSha256(); want != "" {
+			sha := sha256.Sum256(res.module)
+			if got := hex.EncodeToString(sha[:]); got != want {
+				ctx.Report(gvk.WasmPlugin, msg.NewWasmPluginModuleUnreachable(r, plugin.GetUrl(), checksumError(got, want).Error()))
+				return true
+			}
+		}
+		schema, err := wasm.ExtractPluginConfigSchema(res.module)
+		if err != nil || schema == nil {
+			return true
+		}
+		cfg := ""
+		if plugin.GetPluginConfig() != nil {
+			if cfg, err = protomarshal.ToJSON(plugin.GetPluginConfig()); err != nil {
+				return true
+			}
+		}
+		if err := wasm.ValidatePluginConfig(schema, cfg); err != nil {
+			ctx.Report(gvk.WasmPlugin, msg.NewInvalidWasmPluginConfig(r, err.Error()))
+		}
+		return true
+	})
+}
+
+func fetchModule(url string, pullSecret []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	return wasm.Fetch(ctx, url, wasm.FetchOptions{PullSecret: pullSecret, Timeout: fetchTimeout})
+}
@@ -0,0 +1,117 @@
+package wasmplugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+
+	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/analysis"
+	"istio.io/istio/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/gvk"
+)
+
+// ConfigAnalyzer checks the WasmPlugins for the pull secrets they reference, the fail strategy of the plugins of the
+// security phases, and the plugins which may run in any order.
+type ConfigAnalyzer struct{}
+
+var _ analysis.Analyzer = &ConfigAnalyzer{}
+
+// Metadata implements analysis.Analyzer
+func (*ConfigAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "wasmplugin.ConfigAnalyzer",
+		Description: "Checks the pull secrets, fail strategies and priorities of the WasmPlugins",
+		Inputs: []config.GroupVersionKind{
+			gvk.WasmPlugin,
+			gvk.Secret,
+		},
+	}
+}
+
+// Analyze implements analysis.Analyzer
+func (*ConfigAnalyzer) Analyze(ctx analysis.Context) {
+	// The plugins by the workloads they select and the phase and priority they run at.
+	type orderKey struct {
+		namespace resource.Namespace
+		selector  string
+		phase     extensions.PluginPhase
+		priority  int32
+	}
+	ordered := map[orderKey][]*resource.Instance{}
+
+	ctx.ForEach(gvk.WasmPlugin, func(r *resource.Instance) bool {
+		plugin := r.Message.(*extensions.WasmPlugin)
+		ns := r.Metadata.FullName.Namespace
+
+		if secret := plugin.GetImagePullSecret(); secret != "" && pullSecret(ctx, ns, secret) == nil {
+			ctx.Report(gvk.WasmPlugin, msg.NewWasmPluginPullSecretNotFound(r, secret, ns.String()))
+		}
+		if plugin.GetFailStrategy() == extensions.FailStrategy_FAIL_OPEN && isSecurityPhase(plugin.GetPhase()) {
+			ctx.Report(gvk.WasmPlugin, msg.NewWasmPluginFailOpenInSecurityPhase(r, plugin.GetPhase().String()))
+		}
+		// Plugins without a priority are ordered by creation time and name.
+		if plugin.GetPriority() != nil {
+			key := orderKey{
+				namespace: ns,
+				selector:  klabels.SelectorFromSet(plugin.GetSelector().GetMatchLabels()).String(),
+				phase:     plugin.GetPhase(),
+				priority:  plugin.GetPriority().GetValue(),
+			}
+			ordered[key] = append(ordered[key], r)
+		}
+		return true
+	})
+
+	for key, plugins := range ordered {
+		if len(plugins) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(plugins))
+		for _, r := range plugins {
+			names = append(names, r.Metadata.FullName.String())
+		}
+		sort.Strings(names)
+		for _, r := range plugins {
+			ctx.Report(gvk.WasmPlugin, msg.NewConflictingWasmPluginPriorities(r, names, key.phase.String(), int(key.priority)))
+		}
+	}
+}
+
+// isSecurityPhase returns true for the phases of the plugins authenticating and authorizing the requests.
+func isSecurityPhase(phase extensions.PluginPhase) bool {
+	return phase == extensions.PluginPhase_AUTHN || phase == extensions.PluginPhase_AUTHZ
+}
+
+// pullSecret returns the docker config of the image pull secret of a WasmPlugin, or nil if the secret is not found.
+func pullSecret(ctx analysis.Context, ns resource.Namespace, name string) []byte {
+	r := ctx.Find(gvk.Secret, resource.NewFullName(ns, resource.LocalName(name)))
+	if r == nil {
+		return nil
+	}
+	secret, ok := r.Message.(*corev1.Secret)
+	if !ok {
+		return nil
+	}
+	if b, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		return b
+	}
+	return []byte{}
+}
+
+// moduleURL returns the URL the module of the plugin is fetched from, which defaults to an OCI image.
+func moduleURL(plugin *extensions.WasmPlugin) string {
+	if !strings.Contains(plugin.GetUrl(), "://") {
+		return "oci://" + plugin.GetUrl()
+	}
+	return plugin.GetUrl()
+}
+
+func checksumError(got, want string) error {
+	return fmt.Errorf("module has checksum %v, which does not match %v", got, want)
+}
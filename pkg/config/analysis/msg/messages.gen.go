@@ -236,6 +236,26 @@ var (
 	// InvalidGatewayCredential defines a diag.MessageType for message "InvalidGatewayCredential".
 	// Description: The credential provided for the Gateway resource is invalid
 	InvalidGatewayCredential = diag.NewMessageType(diag.Error, "IST0161", "The credential referenced by the Gateway %s in namespace %s is invalid, which can cause the traffic not to work as expected.")
+
+	// WasmPluginModuleUnreachable defines a diag.MessageType for message "WasmPluginModuleUnreachable".
+	// Description: The Wasm module of a WasmPlugin cannot be fetched
+	WasmPluginModuleUnreachable = diag.NewMessageType(diag.Error, "IST0162", "The module %v of the WasmPlugin cannot be fetched, so the plugin will not be loaded: %v")
+
+	// WasmPluginPullSecretNotFound defines a diag.MessageType for message "WasmPluginPullSecretNotFound".
+	// Description: The image pull secret of a WasmPlugin does not exist
+	WasmPluginPullSecretNotFound = diag.NewMessageType(diag.Error, "IST0163", "The image pull secret %q of the WasmPlugin is not found in namespace %q, so its module cannot be pulled from a private registry.")
+
+	// WasmPluginFailOpenInSecurityPhase defines a diag.MessageType for message "WasmPluginFailOpenInSecurityPhase".
+	// Description: A WasmPlugin running in a security phase fails open
+	WasmPluginFailOpenInSecurityPhase = diag.NewMessageType(diag.Warning, "IST0164", "The WasmPlugin runs in the %v phase with the FAIL_OPEN fail strategy, so requests bypass it when it cannot be loaded.")
+
+	// ConflictingWasmPluginPriorities defines a diag.MessageType for message "ConflictingWasmPluginPriorities".
+	// Description: WasmPlugins selecting the same workloads run in the same phase with the same priority
+	ConflictingWasmPluginPriorities = diag.NewMessageType(diag.Warning, "IST0165", "The WasmPlugins %v select the same workloads in the %v phase with the same priority %v, so the order they run in is undefined.")
+
+	// InvalidWasmPluginConfig defines a diag.MessageType for message "InvalidWasmPluginConfig".
+	// Description: The pluginConfig of a WasmPlugin does not match the schema embedded in its module
+	InvalidWasmPluginConfig = diag.NewMessageType(diag.Error, "IST0166", "The pluginConfig of the WasmPlugin is rejected by its module: %v")
 )
 
 // All returns a list of all known message types.
@@ -298,6 +318,11 @@ func All() []*diag.MessageType {
 		ConflictingTelemetryWorkloadSelectors,
 		MultipleTelemetriesWithoutWorkloadSelectors,
 		InvalidGatewayCredential,
+		WasmPluginModuleUnreachable,
+		WasmPluginPullSecretNotFound,
+		WasmPluginFailOpenInSecurityPhase,
+		ConflictingWasmPluginPriorities,
+		InvalidWasmPluginConfig,
 	}
 }
 
@@ -859,3 +884,52 @@ func NewInvalidGatewayCredential(r *resource.Instance, gatewayName string, gatew
 		gatewayNamespace,
 	)
 }
+
+// NewWasmPluginModuleUnreachable returns a new diag.Message based on WasmPluginModuleUnreachable.
+func NewWasmPluginModuleUnreachable(r *resource.Instance, url string, error string) diag.Message {
+	return diag.NewMessage(
+		WasmPluginModuleUnreachable,
+		r,
+		url,
+		error,
+	)
+}
+
+// NewWasmPluginPullSecretNotFound returns a new diag.Message based on WasmPluginPullSecretNotFound.
+func NewWasmPluginPullSecretNotFound(r *resource.Instance, secret string, namespace string) diag.Message {
+	return diag.NewMessage(
+		WasmPluginPullSecretNotFound,
+		r,
+		secret,
+		namespace,
+	)
+}
+
+// NewWasmPluginFailOpenInSecurityPhase returns a new diag.Message based on WasmPluginFailOpenInSecurityPhase.
+func NewWasmPluginFailOpenInSecurityPhase(r *resource.Instance, phase string) diag.Message {
+	return diag.NewMessage(
+		WasmPluginFailOpenInSecurityPhase,
+		r,
+		phase,
+	)
+}
+
+// NewConflictingWasmPluginPriorities returns a new diag.Message based on ConflictingWasmPluginPriorities.
+func NewConflictingWasmPluginPriorities(r *resource.Instance, plugins []string, phase string, priority int) diag.Message {
+	return diag.NewMessage(
+		ConflictingWasmPluginPriorities,
+		r,
+		plugins,
+		phase,
+		priority,
+	)
+}
+
+// NewInvalidWasmPluginConfig returns a new diag.Message based on InvalidWasmPluginConfig.
+func NewInvalidWasmPluginConfig(r *resource.Instance, error string) diag.Message {
+	return diag.NewMessage(
+		InvalidWasmPluginConfig,
+		r,
+		error,
+	)
+}
@@ -189,6 +189,140 @@ const (
 	DefaultScopedRouteName   = "scoped-route"
 	MSEOriginName            = "internal.mse.kubernetes.io/mse-origin-name"
 	GlobalWildcardHost       = "*"
+	// WasmPluginsAnnotation on an Ingress, a VirtualService or a Gateway selects the WasmPlugins applying to its
+	// routes, as a comma separated list of names optionally qualified by namespace. Other plugins are disabled.
+	WasmPluginsAnnotation = "higress.io/wasm-plugins"
+	// FallbackCredentialNameAnnotation on a Gateway names the credential of the certificate served on its HTTPS
+	// ports to the clients whose SNI matches none of its servers, which are otherwise reset.
+	FallbackCredentialNameAnnotation = "higress.io/fallback-credential-name"
+	// WasmInitialFetchTimeoutAnnotation on a WasmPlugin sets how long the listeners wait for its config before they
+	// are warmed without it, as a duration. Until the config arrives, requests pass through a FAIL_OPEN plugin and are
+	// rejected by a FAIL_CLOSE one. The listeners wait forever by default.
+	WasmInitialFetchTimeoutAnnotation = "higress.io/wasm-initial-fetch-timeout"
+	// WasmMatchAuthenticationAnnotation on a WasmPlugin runs it only for the requests in an authentication state:
+	// peer-authenticated or peer-unauthenticated by mTLS, which applies to any phase, or authenticated or
+	// unauthenticated by mTLS or a JWT bearer token, which only applies to the AUTHN phase, before the JWTs are
+	// verified and stripped.
+	WasmMatchAuthenticationAnnotation = "higress.io/wasm-match-authentication"
+	// WasmMatchRulesAnnotation on a WasmPlugin overrides its pluginConfig on some domains or routes of the gateways,
+	// as a JSON list of rules, each with either a domain or a routePrefix list and a config merged into the
+	// pluginConfig. The first rule matching a domain or a route applies, and the route rules take precedence.
+	WasmMatchRulesAnnotation = "higress.io/wasm-match-rules"
+	// WasmSharedServicesAnnotation on a WasmPlugin declares the shared services its plugin calls, such as a Redis or a
+	// rate limit service, as a JSON object of names to {"address": "host:port", "tls": bool, "sni": string}. A cluster
+	// is generated for each of them on the proxies of the plugin, and the pluginConfig string values of the form
+	// shared-service://<name> are replaced by the name of its cluster.
+	WasmSharedServicesAnnotation = "higress.io/wasm-shared-services"
+	// LocalRateLimitAnnotation on a VirtualService sets a local rate limit on its routes on the gateways, as a JSON
+	// token bucket {"maxTokens", "tokensPerFill", "fillInterval"} with an optional "statusCode", "responseHeaders" of
+	// the rate limited responses, and "descriptors" overriding the token bucket for the requests with a "header"
+	// "value". The local rate limits of the route HTTP filters take precedence.
+	LocalRateLimitAnnotation = "higress.io/local-rate-limit"
+	// RateLimitAnnotation on a VirtualService sets the descriptors sent to the global rate limit service for the
+	// requests to its routes on the gateways, as a JSON list of descriptors, each a list of entries with either a
+	// "key" and a request "header", "remoteAddress": true, or a generic key "value".
+	RateLimitAnnotation = "higress.io/rate-limit"
+	// GatewayPatchAnnotation on a Gateway patches the listeners and routes generated for its servers, as a JSON object
+	// with the "timeouts" and "buffers" of the connections, the request and response "headers" operations of the
+	// routes, and the "accessLogFormat". It is validated, unlike the EnvoyFilter patches it replaces.
+	GatewayPatchAnnotation = "higress.io/gateway-patch"
+	// BackendTLSAnnotation on a Service or a ServiceEntry sets the TLS policy of the gateways connecting to it, as a
+	// JSON object with the "credentialName" of the secret holding the CA certificate, the "hostname" of the backend
+	// and the names of the "ports" it applies to, all of them by default. A DestinationRule TLS setting takes
+	// precedence.
+	BackendTLSAnnotation = "higress.io/backend-tls"
+	// RetryBudgetAnnotation on a DestinationRule limits the concurrent retries of the clusters of its host to a
+	// percentage of their active requests, as a JSON object with the "budgetPercent" and the
+	// "minRetryConcurrency". It overrides the retry budget of PILOT_CLUSTER_DEFAULTS.
+	RetryBudgetAnnotation = "higress.io/retry-budget"
+	// HTTP3Annotation on a Gateway set to "true" adds QUIC listeners for its HTTPS servers terminating TLS, serving
+	// HTTP/3 with the same SDS certificates, even if HTTP/3 is disabled mesh wide. Set to "false", it opts the
+	// gateway out of the mesh wide HTTP/3.
+	HTTP3Annotation = "higress.io/http3"
+	// HTTP3AltSvcMaxAgeAnnotation on a Gateway sets the max age in seconds of the alt-svc header advertising HTTP/3 on
+	// the responses of its routes, one day by default. A short max age lets the clients whose UDP traffic is blocked
+	// downgrade to HTTP/1.1 or HTTP/2 over TCP sooner.
+	HTTP3AltSvcMaxAgeAnnotation = "higress.io/http3-alt-svc-max-age"
+	// SNIForwardProxyAnnotation on a Gateway forwards the TLS connections to its PASSTHROUGH servers whose SNI matches
+	// no TLS route to the host named by their SNI, resolved by a dynamic forward proxy. It is a JSON object with the
+	// "allowedDomains" forwarded, such as "*.example.com" or "*" for any, and the upstream "port", the port of the
+	// server by default.
+	SNIForwardProxyAnnotation = "higress.io/sni-forward-proxy"
+	// ProxyProtocolAnnotation on a Gateway sets the PROXY protocol of the listeners of its servers, overriding the mesh
+	// wide one. It is a JSON object with the "mode", required, optional or disabled, the dynamic "metadata" keys set
+	// from the PROXY protocol v2 TLVs of the given types, and "passThroughTLVs" to send the TLVs upstream.
+	ProxyProtocolAnnotation = "higress.io/proxy-protocol"
+	// UpstreamProxyProtocolAnnotation on a DestinationRule sends a PROXY protocol header of the version, v1 or v2,
+	// to the hosts of its clusters, preserving the address of the downstream clients.
+	UpstreamProxyProtocolAnnotation = "higress.io/upstream-proxy-protocol"
+	// MirrorHeadersAnnotation on a VirtualService mirrors the requests to the mirror destinations of its routes only
+	// if their headers match. It is a JSON object keyed by the host of the mirror destinations, of the header
+	// conditions keyed by header name, such as {"canary.default.svc.cluster.local": {"x-canary": {"exact": "true"}}}.
+	MirrorHeadersAnnotation = "higress.io/mirror-headers"
+	// LoadBalancerPolicyAnnotation on a DestinationRule replaces the load balancer of its clusters with a tuned policy,
+	// a JSON object with the "policy", least_request with its "choiceCount", "activeRequestBias" and "slowStart", or
+	// client_side_weighted_round_robin weighing the hosts by their ORCA load reports. It is ignored for the clusters
+	// hashing consistently.
+	LoadBalancerPolicyAnnotation = "higress.io/load-balancer-policy"
+	// EndpointHealthStatusLabel on an endpoint of a ServiceEntry sets its health status sent by EDS, healthy,
+	// unhealthy or draining, such as mapped from the health checks of the registry of the endpoint. Unhealthy
+	// endpoints are only sent with PILOT_SEND_UNHEALTHY_ENDPOINTS.
+	EndpointHealthStatusLabel = "higress.io/health-status"
+	// DNSResolversAnnotation on a ServiceEntry resolved by DNS sets the DNS resolvers of its clusters, overriding the
+	// mesh wide PILOT_DNS_RESOLVERS. It is a comma separated list of IP addresses with optional ports, prefixed by
+	// udp:// or tcp://, or "dot" to resolve with DNS-over-TLS through the forwarder of the agent.
+	DNSResolversAnnotation = "higress.io/dns-resolvers"
+	// AccessLogFormatAnnotation on a Telemetry formats the file access logs of the providers of its accessLogging with
+	// the named format of PILOT_ACCESS_LOG_FORMATS. The annotation of the most specific Telemetry applies.
+	AccessLogFormatAnnotation = "higress.io/access-log-format"
+	// TracingSamplingAnnotation on a VirtualService sets the random sampling percentage of the traces of its routes on
+	// the gateways, overriding the one of their tracing. It is a JSON object with the "percentage" of all its routes
+	// and the percentages of some "routes", keyed by the name of the HTTP route, or of the route and match.
+	TracingSamplingAnnotation = "higress.io/tracing-sampling"
+	// CanaryAnnotation on a VirtualService releases the last destination of its weighted routes as a canary. It is a
+	// JSON object with the "header", "queryParameter" and "cookie" forcing the canary or stable destinations when set
+	// to always or never, the "hashHeader" splitting the requests consistently on its numeric value, the "sticky"
+	// cookie pinning the destination of a session with its "ttl", and the policies of some "routes".
+	CanaryAnnotation = "higress.io/canary"
+	// BodyTransformationAnnotation on a VirtualService transforms the JSON bodies of the requests and responses of its
+	// routes with the WasmPlugin of PILOT_BODY_TRANSFORMATION_PLUGIN. It is a JSON object with the "request" and
+	// "response" transformations, adding, removing and renaming fields, and the transformations of some "routes".
+	BodyTransformationAnnotation = "higress.io/body-transformation"
+	// ExtProcAnnotation on a VirtualService enables the external processor of PILOT_EXT_PROC_SERVICE_ADDRESS on its
+	// routes. It is a JSON object with the "processingMode" of its routes, overriding the one of the filter, and the
+	// policies of some "routes", "disabled" or with their own processing mode.
+	ExtProcAnnotation = "higress.io/ext-proc"
+	// GrpcJSONTranscodingAnnotation on a VirtualService transcodes the JSON requests of its routes to the methods of
+	// gRPC services with PILOT_ENABLE_GRPC_JSON_TRANSCODING. It is a JSON object with the "descriptor" set of the
+	// services, from a configmap://, oci:// or http(s):// source, the "services" transcoded, and the transcoding of
+	// some "routes".
+	GrpcJSONTranscodingAnnotation = "higress.io/grpc-json-transcoding"
+	// StreamingAnnotation on a VirtualService makes its routes WebSocket or server-sent events ones, whose streams
+	// are bounded by an idle timeout and a max stream duration instead of the route timeout. It is a JSON object with
+	// the "type", websocket or sse, the "idleTimeout" and "maxStreamDuration", and the policies of some "routes".
+	StreamingAnnotation = "higress.io/streaming"
+	// RequestBufferingAnnotation on a VirtualService overrides the buffering of the requests of its routes by the
+	// buffer filter of PILOT_REQUEST_BUFFER_MAX_SIZE. It is a JSON object with the "maxRequestSize" of the requests,
+	// larger ones being rejected with 413, or "disabled", and the policies of some "routes".
+	RequestBufferingAnnotation = "higress.io/request-buffering"
+	// IPAccessAnnotation on a VirtualService or a Gateway allows or denies the clients of its routes, or of the
+	// listeners of its servers, by IP address. It is a JSON object with the "allow" and "deny" CIDRs, "shadow" to
+	// only record the denials, the "source" address, remote or direct, and on a VirtualService the policies of some
+	// "routes".
+	IPAccessAnnotation = "higress.io/ip-access"
+	// JWTClaimHeadersAnnotation on a RequestAuthentication copies the claims of its verified JWTs to request
+	// headers. It is a JSON object with the "claims" copied, keyed by header name, such as {"x-user-id": "sub"}, and
+	// "sanitize", true by default, to remove the headers set from its JWTs from the requests before verification.
+	JWTClaimHeadersAnnotation = "higress.io/jwt-claim-headers"
+	// OIDCAnnotation on a Gateway requires the browsers to log in with an OIDC identity provider to some hosts of its
+	// servers. It is a JSON object with the "authorizationEndpoint" and "tokenEndpoint" of the provider, the
+	// "clientID" of the gateway and the "credentialName" of the secret holding its client secret and HMAC secret.
+	OIDCAnnotation = "higress.io/oidc"
+	// ClientCertAnnotation on a Gateway sets how the client certificates of the mTLS connections of its servers are
+	// passed to the upstreams. It is a JSON object with the "forward" mode of the x-forwarded-client-cert header, its
+	// "details", and the attributes of the certificates copied to the request "headers", such as {"x-client-uri":
+	// "uri_san"}.
+	ClientCertAnnotation = "higress.io/client-cert"
 	// End added by ingress
 
 )
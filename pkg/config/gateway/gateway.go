@@ -61,10 +61,22 @@ func IsEligibleForHTTP3Upgrade(server *v1alpha3.Server) bool {
 	if !features.EnableQUICListeners {
 		return false
 	}
+	// Modified by ingress
+	return IsHTTP3CapableServer(server)
+	// End modified by ingress
+}
+
+// Added by ingress
+
+// IsHTTP3CapableServer returns true if an HTTP/3 server listening on QUIC can mirror the given server, regardless of
+// PILOT_ENABLE_QUIC_LISTENERS. It must be a TLS non-passthrough as TLS is mandatory for QUIC
+func IsHTTP3CapableServer(server *v1alpha3.Server) bool {
 	p := protocol.Parse(server.Port.Protocol)
 	return p == protocol.HTTPS && server.Tls != nil && !IsPassThroughServer(server)
 }
 
+// End added by ingress
+
 // IsPassThroughServer returns true if this server does TLS passthrough (auto or manual)
 func IsPassThroughServer(server *v1alpha3.Server) bool {
 	if server.Tls == nil {
@@ -48,6 +48,27 @@ import (
 	telemetry "istio.io/api/telemetry/v1alpha1"
 	type_beta "istio.io/api/type/v1beta1"
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pkg/ali/config/backendtls"
+	"istio.io/istio/pkg/ali/config/bodytransformation"
+	"istio.io/istio/pkg/ali/config/canary"
+	"istio.io/istio/pkg/ali/config/clientcert"
+	"istio.io/istio/pkg/ali/config/clusterdefaults"
+	"istio.io/istio/pkg/ali/config/dnsresolver"
+	"istio.io/istio/pkg/ali/config/extproc"
+	"istio.io/istio/pkg/ali/config/gatewaypatch"
+	"istio.io/istio/pkg/ali/config/grpctranscoding"
+	"istio.io/istio/pkg/ali/config/ipaccess"
+	"istio.io/istio/pkg/ali/config/jwtclaims"
+	"istio.io/istio/pkg/ali/config/lbpolicy"
+	"istio.io/istio/pkg/ali/config/localratelimit"
+	"istio.io/istio/pkg/ali/config/mirror"
+	"istio.io/istio/pkg/ali/config/oidc"
+	"istio.io/istio/pkg/ali/config/proxyprotocol"
+	"istio.io/istio/pkg/ali/config/ratelimit"
+	"istio.io/istio/pkg/ali/config/requestbuffering"
+	"istio.io/istio/pkg/ali/config/sniforwardproxy"
+	"istio.io/istio/pkg/ali/config/streaming"
+	"istio.io/istio/pkg/ali/config/tracingsampling"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/config/gateway"
@@ -505,6 +526,42 @@ var ValidateGateway = registerValidateFunc("ValidateGateway",
 			}
 		}
 
+		// Added by ingress
+		if patch, ok := cfg.Annotations[constants.GatewayPatchAnnotation]; ok {
+			_, err := gatewaypatch.Parse(patch)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.ProxyProtocolAnnotation]; ok {
+			_, err := proxyprotocol.Parse(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.SNIForwardProxyAnnotation]; ok {
+			_, err := sniforwardproxy.Parse(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.IPAccessAnnotation]; ok {
+			_, err := ipaccess.ParseGateway(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.OIDCAnnotation]; ok {
+			_, err := oidc.Parse(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.ClientCertAnnotation]; ok {
+			_, err := clientcert.Parse(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.HTTP3Annotation]; ok && value != "true" && value != "false" {
+			v = appendValidation(v, fmt.Errorf("invalid %s annotation %q: must be true or false", constants.HTTP3Annotation, value))
+		}
+		if value, ok := cfg.Annotations[constants.HTTP3AltSvcMaxAgeAnnotation]; ok {
+			if maxAge, err := strconv.ParseUint(value, 10, 32); err != nil || maxAge == 0 {
+				v = appendValidation(v, fmt.Errorf("invalid %s annotation %q: must be a positive number of seconds",
+					constants.HTTP3AltSvcMaxAgeAnnotation, value))
+			}
+		}
+		// End added by ingress
+
 		return v.Unwrap()
 	})
 
@@ -704,6 +761,20 @@ var ValidateDestinationRule = registerValidateFunc("ValidateDestinationRule",
 
 		v = appendValidation(v, validateWorkloadSelector(rule.GetWorkloadSelector()))
 
+		// Added by ingress
+		if value, ok := cfg.Annotations[constants.RetryBudgetAnnotation]; ok {
+			_, err := clusterdefaults.ParseRetryBudget(value)
+			v = appendValidation(v, err)
+		}
+		if value, ok := cfg.Annotations[constants.UpstreamProxyProtocolAnnotation]; ok {
+			v = appendValidation(v, proxyprotocol.ValidateVersion(value))
+		}
+		if value, ok := cfg.Annotations[constants.LoadBalancerPolicyAnnotation]; ok {
+			_, err := lbpolicy.Parse(value)
+			v = appendValidation(v, err)
+		}
+		// End added by ingress
+
 		return v.Unwrap()
 	})
 
@@ -2168,6 +2239,12 @@ var ValidateRequestAuthentication = registerValidateFunc("ValidateRequestAuthent
 		for _, rule := range in.JwtRules {
 			errs = appendValidation(errs, validateJwtRule(rule))
 		}
+		// Added by ingress
+		if value, ok := cfg.Annotations[constants.JWTClaimHeadersAnnotation]; ok {
+			_, err := jwtclaims.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		// End added by ingress
 		return errs.Unwrap()
 	})
 
@@ -2365,6 +2442,52 @@ var ValidateVirtualService = registerValidateFunc("ValidateVirtualService",
 		}
 
 		errs = appendValidation(errs, validateExportTo(cfg.Namespace, virtualService.ExportTo, false, false))
+		// Added by ingress
+		if value, ok := cfg.Annotations[constants.LocalRateLimitAnnotation]; ok {
+			_, err := localratelimit.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.RateLimitAnnotation]; ok {
+			_, err := ratelimit.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.MirrorHeadersAnnotation]; ok {
+			_, err := mirror.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.TracingSamplingAnnotation]; ok {
+			_, err := tracingsampling.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.CanaryAnnotation]; ok {
+			_, err := canary.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.BodyTransformationAnnotation]; ok {
+			_, err := bodytransformation.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.ExtProcAnnotation]; ok {
+			_, err := extproc.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.GrpcJSONTranscodingAnnotation]; ok {
+			_, err := grpctranscoding.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.StreamingAnnotation]; ok {
+			_, err := streaming.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.RequestBufferingAnnotation]; ok {
+			_, err := requestbuffering.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.IPAccessAnnotation]; ok {
+			_, err := ipaccess.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		// End added by ingress
 
 		warnUnused := func(ruleno, reason string) {
 			errs = appendValidation(errs, WrapWarning(&AnalysisAwareError{
@@ -3540,6 +3663,19 @@ var ValidateServiceEntry = registerValidateFunc("ValidateServiceEntry",
 		}
 
 		errs = appendValidation(errs, validateExportTo(cfg.Namespace, serviceEntry.ExportTo, true, false))
+		// Added by ingress
+		if value, ok := cfg.Annotations[constants.BackendTLSAnnotation]; ok {
+			_, err := backendtls.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		if value, ok := cfg.Annotations[constants.DNSResolversAnnotation]; ok {
+			if serviceEntry.Resolution != networking.ServiceEntry_DNS && serviceEntry.Resolution != networking.ServiceEntry_DNS_ROUND_ROBIN {
+				errs = appendValidation(errs, fmt.Errorf("%s requires DNS or DNS_ROUND_ROBIN resolution", constants.DNSResolversAnnotation))
+			}
+			_, err := dnsresolver.Parse(value)
+			errs = appendValidation(errs, err)
+		}
+		// End added by ingress
 		return errs.Unwrap()
 	})
 
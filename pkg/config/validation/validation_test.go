@@ -1091,6 +1091,101 @@ func TestValidateGateway(t *testing.T) {
 	}
 }
 
+func TestValidateGatewayPatchAnnotation(t *testing.T) {
+	gateway := &networking.Gateway{
+		Servers: []*networking.Server{{
+			Hosts: []string{"foo.bar.com"},
+			Port:  &networking.Port{Name: "name1", Number: 7, Protocol: "http"},
+		}},
+	}
+	tests := []struct {
+		name  string
+		patch string
+		out   string
+	}{
+		{"valid", `{"timeouts": {"idle": "1h"}, "headers": {"response": {"set": {"x-served-by": "higress"}}}}`, ""},
+		{"invalid duration", `{"timeouts": {"idle": "1 hour"}}`, "invalid idle timeout"},
+		{"host header", `{"headers": {"request": {"remove": ["host"]}}}`, "can not be modified"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateGateway(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.GatewayPatchAnnotation: tt.patch},
+				},
+				Spec: gateway,
+			})
+			checkValidationMessage(t, warn, err, "", tt.out)
+		})
+	}
+}
+
+func TestValidateGatewaySNIForwardProxyAnnotation(t *testing.T) {
+	gateway := &networking.Gateway{
+		Servers: []*networking.Server{{
+			Hosts: []string{"*"},
+			Port:  &networking.Port{Name: "tls", Number: 443, Protocol: "TLS"},
+			Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_PASSTHROUGH},
+		}},
+	}
+	tests := []struct {
+		name  string
+		proxy string
+		out   string
+	}{
+		{"valid", `{"allowedDomains": ["*.example.com"]}`, ""},
+		{"no domains", `{}`, "allowedDomains is required"},
+		{"invalid domain", `{"allowedDomains": ["api.*.com"]}`, "invalid domain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateGateway(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.SNIForwardProxyAnnotation: tt.proxy},
+				},
+				Spec: gateway,
+			})
+			checkValidationMessage(t, warn, err, "", tt.out)
+		})
+	}
+}
+
+func TestValidateGatewayHTTP3Annotations(t *testing.T) {
+	gateway := &networking.Gateway{
+		Servers: []*networking.Server{{
+			Hosts: []string{"foo.bar.com"},
+			Port:  &networking.Port{Name: "name1", Number: 7, Protocol: "http"},
+		}},
+	}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		out         string
+	}{
+		{"valid", map[string]string{constants.HTTP3Annotation: "true", constants.HTTP3AltSvcMaxAgeAnnotation: "300"}, ""},
+		{"invalid http3", map[string]string{constants.HTTP3Annotation: "yes"}, "must be true or false"},
+		{"zero max age", map[string]string{constants.HTTP3AltSvcMaxAgeAnnotation: "0"}, "positive number of seconds"},
+		{"duration max age", map[string]string{constants.HTTP3AltSvcMaxAgeAnnotation: "5m"}, "positive number of seconds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warn, err := ValidateGateway(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: tt.annotations,
+				},
+				Spec: gateway,
+			})
+			checkValidationMessage(t, warn, err, "", tt.out)
+		})
+	}
+}
+
 func TestValidateServer(t *testing.T) {
 	tests := []struct {
 		name string
@@ -5460,6 +5555,236 @@ func TestValidateServiceEntries(t *testing.T) {
 	}
 }
 
+func TestValidateServiceEntryBackendTLSAnnotation(t *testing.T) {
+	serviceEntry := &networking.ServiceEntry{
+		Hosts:      []string{"backend.example.com"},
+		Ports:      []*networking.ServicePort{{Number: 443, Protocol: "https", Name: "https"}},
+		Resolution: networking.ServiceEntry_DNS,
+	}
+	cases := []struct {
+		name   string
+		policy string
+		valid  bool
+	}{
+		{"valid", `{"credentialName": "backend-ca", "hostname": "backend.example.com"}`, true},
+		{"no credential", `{"hostname": "backend.example.com"}`, false},
+		{"not json", "backend-ca", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ValidateServiceEntry(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.BackendTLSAnnotation: c.policy},
+				},
+				Spec: serviceEntry,
+			})
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateServiceEntryDNSResolversAnnotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		resolvers  string
+		resolution networking.ServiceEntry_Resolution
+		valid      bool
+	}{
+		{"valid", "10.0.0.10,tcp://10.0.0.11:5353", networking.ServiceEntry_DNS, true},
+		{"dot", "dot", networking.ServiceEntry_DNS_ROUND_ROBIN, true},
+		{"hostname", "dns.internal", networking.ServiceEntry_DNS, false},
+		{"static resolution", "10.0.0.10", networking.ServiceEntry_STATIC, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			se := &networking.ServiceEntry{
+				Hosts:      []string{"backend.example.com"},
+				Ports:      []*networking.ServicePort{{Number: 443, Protocol: "https", Name: "https"}},
+				Resolution: c.resolution,
+			}
+			if c.resolution == networking.ServiceEntry_STATIC {
+				se.Endpoints = []*networking.WorkloadEntry{{Address: "10.0.1.1"}}
+			}
+			_, err := ValidateServiceEntry(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.DNSResolversAnnotation: c.resolvers},
+				},
+				Spec: se,
+			})
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleRetryBudgetAnnotation(t *testing.T) {
+	cases := []struct {
+		name   string
+		budget string
+		valid  bool
+	}{
+		{"valid", `{"budgetPercent": 20, "minRetryConcurrency": 3}`, true},
+		{"above 100 percent", `{"budgetPercent": 200}`, false},
+		{"not json", "20", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.RetryBudgetAnnotation: c.budget},
+				},
+				Spec: &networking.DestinationRule{Host: "reviews"},
+			})
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateDestinationRuleLoadBalancerPolicyAnnotation(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"least request", `{"policy": "least_request", "choiceCount": 3}`, true},
+		{"client side weighted round robin", `{"policy": "client_side_weighted_round_robin"}`, true},
+		{"unknown policy", `{"policy": "peak_ewma"}`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ValidateDestinationRule(config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: map[string]string{constants.LoadBalancerPolicyAnnotation: c.value},
+				},
+				Spec: &networking.DestinationRule{Host: "reviews"},
+			})
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateProxyProtocolAnnotations(t *testing.T) {
+	gateway := &networking.Gateway{
+		Servers: []*networking.Server{{
+			Hosts: []string{"foo.bar.com"},
+			Port:  &networking.Port{Name: "name1", Number: 7, Protocol: "http"},
+		}},
+	}
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		spec        proto.Message
+		valid       bool
+	}{
+		{"gateway", map[string]string{constants.ProxyProtocolAnnotation: `{"mode": "optional"}`}, gateway, true},
+		{"gateway unknown mode", map[string]string{constants.ProxyProtocolAnnotation: `{"mode": "v2"}`}, gateway, false},
+		{"destination rule", map[string]string{constants.UpstreamProxyProtocolAnnotation: "v2"}, &networking.DestinationRule{Host: "reviews"}, true},
+		{"destination rule unknown version", map[string]string{constants.UpstreamProxyProtocolAnnotation: "v3"}, &networking.DestinationRule{Host: "reviews"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: c.annotations,
+				},
+				Spec: c.spec,
+			}
+			var err error
+			if _, ok := c.spec.(*networking.Gateway); ok {
+				_, err = ValidateGateway(cfg)
+			} else {
+				_, err = ValidateDestinationRule(cfg)
+			}
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualServiceMirrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		mirrors     []*networking.HTTPMirrorPolicy
+		valid       bool
+	}{
+		{
+			name: "mirrors",
+			mirrors: []*networking.HTTPMirrorPolicy{
+				{Destination: &networking.Destination{Host: "canary"}, Percentage: &networking.Percent{Value: 10}},
+				{Destination: &networking.Destination{Host: "shadow"}},
+			},
+			valid: true,
+		},
+		{
+			name:    "no destination",
+			mirrors: []*networking.HTTPMirrorPolicy{{Percentage: &networking.Percent{Value: 10}}},
+			valid:   false,
+		},
+		{
+			name: "percentage over 100",
+			mirrors: []*networking.HTTPMirrorPolicy{
+				{Destination: &networking.Destination{Host: "canary"}, Percentage: &networking.Percent{Value: 101}},
+			},
+			valid: false,
+		},
+		{
+			name:        "mirror headers",
+			annotations: map[string]string{constants.MirrorHeadersAnnotation: `{"canary": {"x-canary": {"exact": "true"}}}`},
+			mirrors:     []*networking.HTTPMirrorPolicy{{Destination: &networking.Destination{Host: "canary"}}},
+			valid:       true,
+		},
+		{
+			name:        "invalid mirror headers",
+			annotations: map[string]string{constants.MirrorHeadersAnnotation: `{"canary": {"x-canary": {}}}`},
+			mirrors:     []*networking.HTTPMirrorPolicy{{Destination: &networking.Destination{Host: "canary"}}},
+			valid:       false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := config.Config{
+				Meta: config.Meta{
+					Name:        someName,
+					Namespace:   someNamespace,
+					Annotations: c.annotations,
+				},
+				Spec: &networking.VirtualService{
+					Hosts: []string{"foo.bar"},
+					Http: []*networking.HTTPRoute{{
+						Route: []*networking.HTTPRouteDestination{{
+							Destination: &networking.Destination{Host: "foo.baz"},
+						}},
+						Mirrors: c.mirrors,
+					}},
+				},
+			}
+			_, err := ValidateVirtualService(cfg)
+			if (err == nil) != c.valid {
+				t.Errorf("got valid=%v but wanted valid=%v: %v", err == nil, c.valid, err)
+			}
+		})
+	}
+}
+
 func TestValidateAuthorizationPolicy(t *testing.T) {
 	cases := []struct {
 		name        string
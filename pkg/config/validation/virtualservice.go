@@ -96,6 +96,21 @@ func validateHTTPRoute(http *networking.HTTPRoute, delegate, gatewaySemantics bo
 	}
 
 	errs = appendValidation(errs, validateDestination(http.Mirror))
+	// Added by ingress
+	for _, mirror := range http.Mirrors {
+		if mirror == nil {
+			errs = appendValidation(errs, errors.New("mirror may not be null"))
+			continue
+		}
+		if mirror.Destination == nil {
+			errs = appendValidation(errs, errors.New("mirror destination is required"))
+		}
+		errs = appendValidation(errs, validateDestination(mirror.Destination))
+		if value := mirror.Percentage.GetValue(); value < 0 || value > 100 {
+			errs = appendValidation(errs, fmt.Errorf("mirror percentage must be between 0 and 100 (it has %f)", value))
+		}
+	}
+	// End added by ingress
 	errs = appendValidation(errs, validateHTTPRedirect(http.Redirect))
 	errs = appendValidation(errs, validateHTTPDirectResponse(http.DirectResponse))
 	errs = appendValidation(errs, validateHTTPRetry(http.Retries))
@@ -24,6 +24,8 @@ const (
 	RBACHTTPFilterType = resource.APITypePrefix + "envoy.extensions.filters.http.rbac.v3.RBAC"
 	TypedStructType    = resource.APITypePrefix + "udpa.type.v1.TypedStruct"
 
+	ExtensionWithMatcherType = resource.APITypePrefix + "envoy.extensions.common.matching.v3.ExtensionWithMatcher" // Added by ingress
+
 	StatsFilterName       = "istio.stats"
 	StackdriverFilterName = "istio.stackdriver"
 )
@@ -106,6 +106,11 @@ type Agent struct {
 	// local DNS Server that processes DNS requests locally and forwards to upstream DNS if needed.
 	localDNSServer *dnsClient.LocalDNSServer
 
+	// Added by ingress
+	// dotForwarder forwards the DNS queries of the DNS clusters resolving by "dot" to DNS-over-TLS resolvers.
+	dotForwarder *dotForwarder
+	// End added by ingress
+
 	// Signals true completion (e.g. with delayed graceful termination of Envoy)
 	wg sync.WaitGroup
 }
@@ -192,6 +197,21 @@ type AgentOptions struct {
 	DualStack bool
 
 	UseExternalWorkloadSDS bool
+
+	// Added by ingress
+	// ECDSFallbackPath if set persists the last ECDS resources ACKed by Envoy to the file, to serve them to Envoy
+	// while istiod is unreachable.
+	ECDSFallbackPath string
+	// OutlierEventReportURL if set is the URL of the /debug/outlierz endpoint of istiod, the outlier detection
+	// events written by Envoy to its outlier log are reported to.
+	OutlierEventReportURL string
+	// WasmStatsReportURL if set is the URL of the /debug/wasm_statsz endpoint of istiod, the Wasm stats of Envoy
+	// are periodically reported to.
+	WasmStatsReportURL string
+	// DNSOverTLSResolvers if set are the DNS-over-TLS resolvers the plain DNS queries of the DNS clusters resolving
+	// through the forwarder of the agent are forwarded to.
+	DNSOverTLSResolvers string
+	// End added by ingress
 }
 
 // NewAgent hosts the functionality for local SDS and XDS. This consists of the local SDS server and
@@ -386,6 +406,19 @@ func (a *Agent) Run(ctx context.Context) (func(), error) {
 		})
 	}
 
+	// Added by ingress
+	if a.cfg.DNSOverTLSResolvers != "" {
+		forwarder, err := newDoTForwarder(a.cfg.DNSOverTLSResolvers)
+		if err != nil {
+			return nil, err
+		}
+		if err := forwarder.start(dotForwarderAddress); err != nil {
+			return nil, err
+		}
+		a.dotForwarder = forwarder
+	}
+	// End added by ingress
+
 	if !a.EnvoyDisabled() {
 		err = a.initializeEnvoyAgent(ctx)
 		if err != nil {
@@ -398,6 +431,25 @@ func (a *Agent) Run(ctx context.Context) (func(), error) {
 			// This is a blocking call for graceful termination.
 			a.envoyAgent.Run(ctx)
 		}()
+
+		// Added by ingress
+		if a.cfg.OutlierEventReportURL != "" && a.envoyOpts.OutlierLogPath != "" {
+			reporter, err := newOutlierReporter(a.envoyOpts.OutlierLogPath, a.cfg.OutlierEventReportURL,
+				a.cfg.ServiceNode, a.secOpts.CredFetcher)
+			if err != nil {
+				return nil, err
+			}
+			go reporter.run(ctx)
+		}
+		if a.cfg.WasmStatsReportURL != "" {
+			reporter, err := newWasmStatsReporter(a.proxyConfig.ProxyAdminPort, a.cfg.WasmStatsReportURL,
+				a.cfg.ServiceNode, a.secOpts.CredFetcher)
+			if err != nil {
+				return nil, err
+			}
+			go reporter.run(ctx)
+		}
+		// End added by ingress
 	} else if a.WaitForSigterm() {
 		// wait for SIGTERM and perform graceful shutdown
 		a.wg.Add(1)
@@ -584,6 +636,11 @@ func (a *Agent) Close() {
 	if a.localDNSServer != nil {
 		a.localDNSServer.Close()
 	}
+	// Added by ingress
+	if a.dotForwarder != nil {
+		a.dotForwarder.close()
+	}
+	// End added by ingress
 	if a.sdsServer != nil {
 		a.sdsServer.Stop()
 	}
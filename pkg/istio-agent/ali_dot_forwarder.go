@@ -0,0 +1,140 @@
+package istioagent
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"istio.io/istio/pkg/ali/config/dnsresolver"
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// dotDefaultPort is the port of DNS-over-TLS.
+	dotDefaultPort = "853"
+	// dotTimeout bounds the exchange with a DNS-over-TLS resolver.
+	dotTimeout = 5 * time.Second
+)
+
+// dotUpstream is a DNS-over-TLS resolver.
+type dotUpstream struct {
+	address string
+	client  *dns.Client
+}
+
+// dotForwarder forwards the plain DNS queries of Envoy on localhost to DNS-over-TLS resolvers, so the DNS clusters of
+// Envoy, which only queries its resolvers in plain text, resolve over TLS when pilot sets their resolver to "dot".
+type dotForwarder struct {
+	upstreams []dotUpstream
+	servers   []*dns.Server
+}
+
+// parseDoTResolvers parses a comma separated list of DNS-over-TLS resolvers, host:port#server-name with the port 853
+// and the server name, verified against the certificate of the resolver, being the host by default.
+func parseDoTResolvers(value string) ([]dotUpstream, error) {
+	var upstreams []dotUpstream
+	for _, resolver := range strings.Split(value, ",") {
+		resolver = strings.TrimSpace(resolver)
+		if resolver == "" {
+			continue
+		}
+		address, serverName, _ := strings.Cut(resolver, "#")
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			host, port = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), dotDefaultPort
+		}
+		if host == "" {
+			return nil, fmt.Errorf("invalid dns-over-tls resolver %q", resolver)
+		}
+		if serverName == "" {
+			serverName = host
+		}
+		upstreams = append(upstreams, dotUpstream{
+			address: net.JoinHostPort(host, port),
+			client: &dns.Client{
+				Net:       "tcp-tls",
+				Timeout:   dotTimeout,
+				TLSConfig: &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12},
+			},
+		})
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("no dns-over-tls resolver")
+	}
+	return upstreams, nil
+}
+
+func newDoTForwarder(resolvers string) (*dotForwarder, error) {
+	upstreams, err := parseDoTResolvers(resolvers)
+	if err != nil {
+		return nil, err
+	}
+	return &dotForwarder{upstreams: upstreams}, nil
+}
+
+// start listens for the queries on the address, over UDP and TCP.
+func (f *dotForwarder) start(address string) error {
+	udp, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for dns-over-tls forwarding on udp %s: %v", address, err)
+	}
+	tcp, err := net.Listen("tcp", address)
+	if err != nil {
+		udp.Close()
+		return fmt.Errorf("failed to listen for dns-over-tls forwarding on tcp %s: %v", address, err)
+	}
+	f.servers = []*dns.Server{
+		{PacketConn: udp, Handler: f},
+		{Listener: tcp, Handler: f},
+	}
+	for _, s := range f.servers {
+		go func(s *dns.Server) {
+			if err := s.ActivateAndServe(); err != nil {
+				log.Warnf("dns-over-tls forwarder stopped: %v", err)
+			}
+		}(s)
+	}
+	log.Infof("forwarding dns queries on %s to dns-over-tls resolvers", address)
+	return nil
+}
+
+// ServeDNS forwards a query to the resolvers in turn, answering SERVFAIL if none answers.
+func (f *dotForwarder) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	for _, upstream := range f.upstreams {
+		resp, _, err := upstream.client.Exchange(req, upstream.address)
+		if err != nil || resp == nil {
+			log.Debugf("dns-over-tls resolver %s failed: %v", upstream.address, err)
+			continue
+		}
+		if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+			resp.Truncate(udpSize(req))
+		}
+		_ = w.WriteMsg(resp)
+		return
+	}
+	resp := new(dns.Msg)
+	resp.SetRcode(req, dns.RcodeServerFailure)
+	_ = w.WriteMsg(resp)
+}
+
+// udpSize returns the size of the UDP responses accepted by the client of a query.
+func udpSize(req *dns.Msg) int {
+	if opt := req.IsEdns0(); opt != nil {
+		return int(opt.UDPSize())
+	}
+	return dns.MinMsgSize
+}
+
+func (f *dotForwarder) close() {
+	for _, s := range f.servers {
+		_ = s.Shutdown()
+	}
+}
+
+// dotForwarderAddress is the address of the forwarder queried by the DNS clusters resolving by "dot".
+var dotForwarderAddress = net.JoinHostPort("127.0.0.1", strconv.Itoa(dnsresolver.DoTForwarderPort))
@@ -0,0 +1,136 @@
+package istioagent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseDoTResolvers(t *testing.T) {
+	upstreams, err := parseDoTResolvers("10.0.0.10, dns.internal:8853#resolver.internal,[fd00::1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ address, serverName string }{
+		{"10.0.0.10:853", "10.0.0.10"},
+		{"dns.internal:8853", "resolver.internal"},
+		{"[fd00::1]:853", "fd00::1"},
+	}
+	if len(upstreams) != len(want) {
+		t.Fatalf("got %d resolvers, want %d", len(upstreams), len(want))
+	}
+	for i, w := range want {
+		if upstreams[i].address != w.address || upstreams[i].client.TLSConfig.ServerName != w.serverName {
+			t.Errorf("resolver %d: got %s#%s, want %s#%s", i, upstreams[i].address,
+				upstreams[i].client.TLSConfig.ServerName, w.address, w.serverName)
+		}
+	}
+	if _, err := parseDoTResolvers(" , "); err == nil {
+		t.Errorf("expected error without resolver")
+	}
+}
+
+// dotServer starts a DNS-over-TLS server answering the A queries of example.internal, and returns its address with
+// the pool of its certificate.
+func dotServer(t *testing.T) (string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"resolver.internal"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{Listener: l, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		if req.Question[0].Name == "example.internal." {
+			rr, _ := dns.NewRR("example.internal. 30 IN A 10.0.0.1")
+			resp.Answer = append(resp.Answer, rr)
+		} else {
+			resp.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(resp)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return l.Addr().String(), pool
+}
+
+func TestDoTForwarder(t *testing.T) {
+	address, pool := dotServer(t)
+	// The first resolver is down, so the second one answers.
+	f, err := newDoTForwarder("127.0.0.1:1#resolver.internal," + address + "#resolver.internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range f.upstreams {
+		u.client.TLSConfig.RootCAs = pool
+	}
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarderAddress := l.LocalAddr().String()
+	l.Close()
+	if err := f.start(forwarderAddress); err != nil {
+		t.Fatal(err)
+	}
+	defer f.close()
+
+	for _, network := range []string{"udp", "tcp"} {
+		client := &dns.Client{Net: network, Timeout: 10 * time.Second}
+		req := new(dns.Msg)
+		req.SetQuestion("example.internal.", dns.TypeA)
+		var resp *dns.Msg
+		for i := 0; i < 50; i++ {
+			if resp, _, err = client.Exchange(req, forwarderAddress); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", network, err)
+		}
+		if len(resp.Answer) != 1 || resp.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+			t.Errorf("%s: unexpected answer %v", network, resp.Answer)
+		}
+	}
+
+	// Without any resolver answering, the query fails.
+	f.upstreams = f.upstreams[:1]
+	req := new(dns.Msg)
+	req.SetQuestion("example.internal.", dns.TypeA)
+	resp, _, err := (&dns.Client{Net: "tcp", Timeout: 10 * time.Second}).Exchange(req, forwarderAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("got rcode %d, want SERVFAIL", resp.Rcode)
+	}
+}
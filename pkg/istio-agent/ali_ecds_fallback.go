@@ -0,0 +1,191 @@
+package istioagent
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// ecdsFallbackRetryInterval is how long Envoy is served the persisted ECDS resources before its stream is closed, so
+// that it reconnects and istiod is tried again.
+const ecdsFallbackRetryInterval = 10 * time.Second
+
+// ecdsStore persists the last ECDS resources ACKed by Envoy, after the Wasm remote load conversion, so that Envoy can
+// be seeded with them when the agent restarts while istiod is unreachable, rather than waiting indefinitely for the
+// Wasm filter configs. Only the state of the world ADS stream is supported.
+type ecdsStore struct {
+	path   string
+	nonces atomic.Uint64
+
+	mu sync.Mutex
+	// pending is the last ECDS response forwarded to Envoy, waiting for its ACK.
+	pending   *discovery.DiscoveryResponse
+	version   string
+	resources []*anypb.Any
+}
+
+// newECDSStore returns a store persisting the ECDS resources to the file of the path, loading the resources
+// persisted by a previous agent.
+func newECDSStore(path string) *ecdsStore {
+	s := &ecdsStore{path: path}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			proxyLog.Warnf("failed to read persisted ECDS resources from %s: %v", path, err)
+		}
+		return s
+	}
+	resp := &discovery.DiscoveryResponse{}
+	if err := proto.Unmarshal(b, resp); err != nil {
+		proxyLog.Warnf("failed to parse persisted ECDS resources from %s: %v", path, err)
+		return s
+	}
+	s.version, s.resources = resp.VersionInfo, resp.Resources
+	proxyLog.Infof("loaded %d persisted ECDS resources of version %s", len(s.resources), s.version)
+	return s
+}
+
+// forwarded records the ECDS response forwarded to Envoy, to be persisted once Envoy ACKs it.
+func (s *ecdsStore) forwarded(resp *discovery.DiscoveryResponse) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = resp
+}
+
+// acked persists the resources of the forwarded ECDS response if the request of Envoy ACKs it.
+func (s *ecdsStore) acked(req *discovery.DiscoveryRequest) {
+	if s == nil || req.ErrorDetail != nil || req.ResponseNonce == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil || s.pending.Nonce != req.ResponseNonce {
+		return
+	}
+	s.version, s.resources = s.pending.VersionInfo, s.pending.Resources
+	s.pending = nil
+	if err := s.persist(); err != nil {
+		proxyLog.Warnf("failed to persist ECDS resources to %s: %v", s.path, err)
+	}
+}
+
+// persist atomically writes the resources to the file of the store.
+func (s *ecdsStore) persist() error {
+	b, err := proto.Marshal(&discovery.DiscoveryResponse{
+		VersionInfo: s.version,
+		TypeUrl:     v3.ExtensionConfigurationType,
+		Resources:   s.resources,
+	})
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// response returns a response with the persisted resources of the names, or nil if none is persisted.
+func (s *ecdsStore) response(names []string) *discovery.DiscoveryResponse {
+	if s == nil {
+		return nil
+	}
+	requested := make(map[string]bool, len(names))
+	for _, name := range names {
+		requested[name] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var resources []*anypb.Any
+	for _, r := range s.resources {
+		tec := &core.TypedExtensionConfig{}
+		if err := r.UnmarshalTo(tec); err != nil {
+			continue
+		}
+		if requested[tec.Name] {
+			resources = append(resources, r)
+		}
+	}
+	if len(resources) == 0 {
+		return nil
+	}
+	return &discovery.DiscoveryResponse{
+		VersionInfo: s.version,
+		TypeUrl:     v3.ExtensionConfigurationType,
+		Resources:   resources,
+		Nonce:       fmt.Sprintf("ecds-fallback-%d", s.nonces.Inc()),
+	}
+}
+
+// empty returns true if no ECDS resources are persisted.
+func (s *ecdsStore) empty() bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.resources) == 0
+}
+
+// serveECDSFallback serves Envoy the persisted ECDS resources while istiod is unreachable, so that the listeners
+// waiting for their Wasm filter configs are not blocked indefinitely. The stream is closed with the upstream error
+// after ecdsFallbackRetryInterval, so that Envoy reconnects and istiod is tried again.
+func (p *XdsProxy) serveECDSFallback(con *ProxyConnection, upstreamErr error) error {
+	if p.ecdsStore.empty() {
+		return upstreamErr
+	}
+	proxyLog.Warnf("upstream [%d] unavailable, serving persisted ECDS resources: %v", con.conID, upstreamErr)
+	go func() {
+		for {
+			req, err := con.downstream.Recv()
+			if err != nil {
+				select {
+				case con.downstreamError <- err:
+				case <-con.stopChan:
+				}
+				return
+			}
+			// Only the initial requests are answered, the ACKs and NACKs of the persisted resources are dropped.
+			if req.TypeUrl != v3.ExtensionConfigurationType || req.ResponseNonce != "" {
+				continue
+			}
+			resp := p.ecdsStore.response(req.ResourceNames)
+			if resp == nil {
+				continue
+			}
+			if err := sendDownstream(con.downstream, resp); err != nil {
+				select {
+				case con.downstreamError <- err:
+				case <-con.stopChan:
+				}
+				return
+			}
+			proxyLog.Infof("downstream [%d] seeded with %d persisted ECDS resources of version %s",
+				con.conID, len(resp.Resources), resp.VersionInfo)
+		}
+	}()
+
+	timer := time.NewTimer(ecdsFallbackRetryInterval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return upstreamErr
+	case err := <-con.downstreamError:
+		return err
+	case <-con.stopChan:
+		return nil
+	}
+}
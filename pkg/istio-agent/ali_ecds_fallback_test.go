@@ -0,0 +1,128 @@
+package istioagent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/util/retry"
+	wasmcache "istio.io/istio/pkg/wasm"
+)
+
+// fixtureAckCache serves the fixture module for all the fetches.
+type fixtureAckCache struct {
+	module string
+}
+
+func (f *fixtureAckCache) Get(string, wasmcache.GetOptions) (string, error) {
+	return f.module, nil
+}
+func (f *fixtureAckCache) Cleanup() {}
+
+// streamWithTimeout opens an ADS stream failing the calls blocked once the timeout expires, instead of hanging the test.
+func streamWithTimeout(t *testing.T, conn *grpc.ClientConn, timeout time.Duration) discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient {
+	t.Helper()
+	sctx, cancel := context.WithTimeout(ctx, timeout)
+	t.Cleanup(cancel)
+	downstream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(sctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return downstream
+}
+
+func TestECDSFallback(t *testing.T) {
+	node := model.NodeMetadata{
+		Namespace:   "default",
+		InstanceIPs: []string{"1.1.1.1"},
+		ClusterID:   "Kubernetes",
+	}
+	req := &discovery.DiscoveryRequest{
+		TypeUrl:       v3.ExtensionConfigurationType,
+		ResourceNames: []string{"extension-config"},
+		Node: &core.Node{
+			Id:       "sidecar~1.1.1.1~debug~cluster.local",
+			Metadata: node.ToStruct(),
+		},
+	}
+	storePath := filepath.Join(t.TempDir(), "ecds.pb")
+	// An empty Wasm module, declaring no ABI version.
+	module := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(module, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := setupXdsProxy(t)
+	proxy.wasmCache.Cleanup()
+	proxy.wasmCache = &fixtureAckCache{module: module}
+	proxy.ecdsStore = newECDSStore(storePath)
+	ef, err := os.ReadFile(path.Join(env.IstioSrc, "pilot/pkg/xds/testdata/ecds.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		ConfigString: string(ef),
+	})
+	setDialOptions(proxy, f.BufListener)
+	downstream := streamWithTimeout(t, setupDownstreamConnection(t, proxy), 10*time.Second)
+	if err := downstream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := downstream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The converted resources are persisted once Envoy ACKs them.
+	if _, err := os.Stat(storePath); !os.IsNotExist(err) {
+		t.Fatalf("expected no persisted resources before the ACK, got %v", err)
+	}
+	err = downstream.Send(&discovery.DiscoveryRequest{
+		VersionInfo:   resp.VersionInfo,
+		ResponseNonce: resp.Nonce,
+		TypeUrl:       v3.ExtensionConfigurationType,
+		ResourceNames: []string{"extension-config"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry.UntilOrFail(t, func() bool {
+		_, err := os.Stat(storePath)
+		return err == nil
+	}, retry.Timeout(time.Second), retry.Delay(time.Millisecond), retry.Message("ECDS resources are not persisted"))
+
+	// A restarted agent seeds Envoy with the persisted resources while istiod is unreachable.
+	proxy = setupXdsProxy(t)
+	proxy.ecdsStore = newECDSStore(storePath)
+	proxy.dialOptions = []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return nil, errors.New("istiod is unreachable")
+		}),
+	}
+	downstream = streamWithTimeout(t, setupDownstreamConnection(t, proxy), 10*time.Second)
+	if err := downstream.Send(req); err != nil {
+		t.Fatal(err)
+	}
+	got, err := downstream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VersionInfo != resp.VersionInfo || len(got.Resources) != 1 || !proto.Equal(got.Resources[0], resp.Resources[0]) {
+		t.Fatalf("expected the persisted ECDS resources %v, got %v", resp, got)
+	}
+}
@@ -0,0 +1,126 @@
+package istioagent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	// outlierReportInterval is how often the new outlier detection events are reported to istiod.
+	outlierReportInterval = 10 * time.Second
+	// maxOutlierReportSize bounds the size of the events reported at once.
+	maxOutlierReportSize = 1024 * 1024
+)
+
+// outlierReporter tails the outlier detection event log written by Envoy, and reports the new events to the
+// /debug/outlierz endpoint of istiod, which aggregates the hosts ejected by the gateways. The log must be a regular
+// file: it is read from where the last report ended, from its start again once it is truncated.
+type outlierReporter struct {
+	path        string
+	url         string
+	credFetcher security.CredFetcher
+	client      *http.Client
+
+	offset int64
+}
+
+func newOutlierReporter(path, reportURL, proxyID string, credFetcher security.CredFetcher) (*outlierReporter, error) {
+	u, err := url.Parse(reportURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outlier event report URL %q: %v", reportURL, err)
+	}
+	query := u.Query()
+	query.Set("proxyID", proxyID)
+	u.RawQuery = query.Encode()
+	return &outlierReporter{
+		path:        path,
+		url:         u.String(),
+		credFetcher: credFetcher,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// run reports the new events periodically until the context is done.
+func (r *outlierReporter) run(ctx context.Context) {
+	ticker := time.NewTicker(outlierReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.report(ctx); err != nil {
+				log.Warnf("failed to report outlier detection events: %v", err)
+			}
+		}
+	}
+}
+
+// report sends the complete lines written to the event log since the last report.
+func (r *outlierReporter) report(ctx context.Context) error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < r.offset {
+		// The log was truncated or rotated.
+		r.offset = 0
+	}
+	if info.Size() == r.offset {
+		return nil
+	}
+	if _, err := f.Seek(r.offset, io.SeekStart); err != nil {
+		return err
+	}
+	b, err := io.ReadAll(io.LimitReader(f, maxOutlierReportSize))
+	if err != nil {
+		return err
+	}
+	// Only report the complete lines, the last one may still be being written.
+	end := bytes.LastIndexByte(b, '\n')
+	if end < 0 {
+		return nil
+	}
+	b = b[:end+1]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if r.credFetcher != nil {
+		token, err := r.credFetcher.GetPlatformCredential()
+		if err != nil {
+			return fmt.Errorf("failed to get the credential: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("istiod responded %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	r.offset += int64(len(b))
+	return nil
+}
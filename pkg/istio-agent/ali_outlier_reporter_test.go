@@ -0,0 +1,85 @@
+package istioagent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type fakeCredFetcher struct{}
+
+func (fakeCredFetcher) GetPlatformCredential() (string, error) { return "token", nil }
+func (fakeCredFetcher) GetIdentityProvider() string            { return "" }
+func (fakeCredFetcher) Stop()                                  {}
+
+func TestOutlierReporter(t *testing.T) {
+	var reports []string
+	var authorization, proxyID string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		reports = append(reports, string(b))
+		authorization = req.Header.Get("Authorization")
+		proxyID = req.URL.Query().Get("proxyID")
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "outlier.log")
+	r, err := newOutlierReporter(path, server.URL+"/debug/outlierz", "router~10.0.0.1~gateway.istio-system~cluster.local",
+		fakeCredFetcher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	write := func(s string, flag int) {
+		f, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	// No log yet.
+	if err := r.report(ctx); err != nil || len(reports) != 0 {
+		t.Fatalf("expected nothing to be reported, got %v %v", reports, err)
+	}
+
+	write("{\"action\":\"EJECT\"}\n{\"action\":", os.O_APPEND)
+	if err := r.report(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0] != "{\"action\":\"EJECT\"}\n" {
+		t.Fatalf("expected only the complete line to be reported, got %q", reports)
+	}
+	if authorization != "Bearer token" || proxyID != "router~10.0.0.1~gateway.istio-system~cluster.local" {
+		t.Errorf("unexpected authorization %q and proxyID %q", authorization, proxyID)
+	}
+
+	write("\"UNEJECT\"}\n", os.O_APPEND)
+	if err := r.report(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[1] != "{\"action\":\"UNEJECT\"}\n" {
+		t.Fatalf("expected the rest of the line to be reported, got %q", reports)
+	}
+
+	// Nothing new.
+	if err := r.report(ctx); err != nil || len(reports) != 2 {
+		t.Fatalf("expected nothing new to be reported, got %q %v", reports, err)
+	}
+
+	// Truncated log.
+	write("{}\n", os.O_TRUNC)
+	if err := r.report(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 || reports[2] != "{}\n" {
+		t.Fatalf("expected the truncated log to be reported from its start, got %q", reports)
+	}
+}
@@ -0,0 +1,13 @@
+package istioagent
+
+import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+)
+
+// recordProxyVersion records the Istio version of the proxy from the node of its request, if set. Envoy sets the node
+// on the first request of each stream.
+func (p *XdsProxy) recordProxyVersion(node *core.Node) {
+	if v := node.GetMetadata().GetFields()["ISTIO_VERSION"].GetStringValue(); v != "" {
+		p.proxyVersion.Store(v)
+	}
+}
@@ -0,0 +1,20 @@
+package istioagent
+
+import (
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestRecordProxyVersion(t *testing.T) {
+	p := &XdsProxy{}
+	p.recordProxyVersion(&core.Node{Metadata: model.NodeMetadata{IstioVersion: "1.19.0"}.ToStruct()})
+	// The requests without a node keep the version of the first request.
+	p.recordProxyVersion(nil)
+	p.recordProxyVersion(&core.Node{Metadata: model.NodeMetadata{Namespace: "default"}.ToStruct()})
+	if got := p.proxyVersion.Load(); got != "1.19.0" {
+		t.Errorf("got proxy version %q, want 1.19.0", got)
+	}
+}
@@ -0,0 +1,134 @@
+package istioagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/security"
+)
+
+const (
+	// wasmStatsReportInterval is how often the Wasm stats of Envoy are reported to istiod.
+	wasmStatsReportInterval = 30 * time.Second
+	// wasmStatsFilter selects the stats of the Wasm runtimes and plugins, and of the extension configs.
+	wasmStatsFilter = "^(wasm|extension_config_discovery)"
+)
+
+// wasmStatsReporter periodically reads the Wasm stats of Envoy from its admin endpoint, and reports them to the
+// /debug/wasm_statsz endpoint of istiod, which summarizes the health of the WasmPlugins across the proxies.
+type wasmStatsReporter struct {
+	statsURL    string
+	url         string
+	credFetcher security.CredFetcher
+	client      *http.Client
+}
+
+func newWasmStatsReporter(adminPort int32, reportURL, proxyID string, credFetcher security.CredFetcher) (*wasmStatsReporter, error) {
+	u, err := url.Parse(reportURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Wasm stats report URL %q: %v", reportURL, err)
+	}
+	query := u.Query()
+	query.Set("proxyID", proxyID)
+	u.RawQuery = query.Encode()
+	return &wasmStatsReporter{
+		statsURL: fmt.Sprintf("http://localhost:%d/stats?format=json&filter=%s", adminPort,
+			url.QueryEscape(wasmStatsFilter)),
+		url:         u.String(),
+		credFetcher: credFetcher,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// run reports the stats periodically until the context is done.
+func (r *wasmStatsReporter) run(ctx context.Context) {
+	ticker := time.NewTicker(wasmStatsReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.report(ctx); err != nil {
+				log.Warnf("failed to report Wasm stats: %v", err)
+			}
+		}
+	}
+}
+
+// report sends the current Wasm stats of Envoy, as a JSON object of the values by stat name. Nothing is sent while
+// Envoy has no Wasm stats.
+func (r *wasmStatsReporter) report(ctx context.Context) error {
+	stats, err := r.envoyStats(ctx)
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.credFetcher != nil {
+		token, err := r.credFetcher.GetPlatformCredential()
+		if err != nil {
+			return fmt.Errorf("failed to get the credential: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("istiod responded %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// envoyStats returns the counters and gauges of the Wasm stats of Envoy, leaving out the histograms.
+func (r *wasmStatsReporter) envoyStats(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.statsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the stats of envoy: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("envoy responded %d to the stats request", resp.StatusCode)
+	}
+	var out struct {
+		Stats []struct {
+			Name  string   `json:"name"`
+			Value *float64 `json:"value"`
+		} `json:"stats"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("invalid stats of envoy: %v", err)
+	}
+	stats := map[string]float64{}
+	for _, s := range out.Stats {
+		if s.Name != "" && s.Value != nil {
+			stats[s.Name] = *s.Value
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,55 @@
+package istioagent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWasmStatsReporter(t *testing.T) {
+	envoyStats := `{"stats":[{"name":"wasm.envoy.wasm.runtime.v8.active","value":2},` +
+		`{"name":"extension_config_discovery.http_filter.default.auth.config_fail","value":0},` +
+		`{"histograms":{"supported_quantiles":[0.5]}}]}`
+	var filter string
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		filter = req.URL.Query().Get("filter")
+		_, _ = w.Write([]byte(envoyStats))
+	}))
+	defer envoy.Close()
+	var report, authorization, proxyID string
+	istiod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		report = string(b)
+		authorization = req.Header.Get("Authorization")
+		proxyID = req.URL.Query().Get("proxyID")
+	}))
+	defer istiod.Close()
+
+	r, err := newWasmStatsReporter(15000, istiod.URL+"/debug/wasm_statsz", "router~10.0.0.1~gateway.istio-system~cluster.local",
+		fakeCredFetcher{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.statsURL = envoy.URL + "/stats?format=json&filter=" + wasmStatsFilter
+	if err := r.report(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if filter != wasmStatsFilter {
+		t.Errorf("got filter %q, want %q", filter, wasmStatsFilter)
+	}
+	want := `{"extension_config_discovery.http_filter.default.auth.config_fail":0,"wasm.envoy.wasm.runtime.v8.active":2}`
+	if report != want {
+		t.Errorf("got report %s, want %s", report, want)
+	}
+	if authorization != "Bearer token" || proxyID != "router~10.0.0.1~gateway.istio-system~cluster.local" {
+		t.Errorf("unexpected authorization %q and proxyID %q", authorization, proxyID)
+	}
+
+	envoyStats = `{"stats":[]}`
+	report = ""
+	if err := r.report(context.Background()); err != nil || report != "" {
+		t.Errorf("expected nothing to be reported without stats, got %q %v", report, err)
+	}
+}
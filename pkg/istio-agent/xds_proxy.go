@@ -114,6 +114,14 @@ type XdsProxy struct {
 	ecdsLastNonce         atomic.String
 	downstreamGrpcOptions []grpc.ServerOption
 	istiodSAN             string
+
+	// Added by ingress
+	// ecdsStore persists the ECDS resources ACKed by Envoy, to serve them while istiod is unreachable.
+	ecdsStore *ecdsStore
+	// proxyVersion is the Istio version of the proxy, from the node of its requests, which the proxy-wasm ABI versions
+	// of the Wasm modules are checked against.
+	proxyVersion atomic.String
+	// End added by ingress
 }
 
 var proxyLog = log.RegisterScope("xdsproxy", "XDS Proxy in Istio Agent")
@@ -152,6 +160,11 @@ func initXdsProxy(ia *Agent) (*XdsProxy, error) {
 		ia:                    ia,
 		downstreamGrpcOptions: ia.cfg.DownstreamGrpcOptions,
 	}
+	// Added by ingress
+	if ia.cfg.ECDSFallbackPath != "" {
+		proxy.ecdsStore = newECDSStore(ia.cfg.ECDSFallbackPath)
+	}
+	// End added by ingress
 
 	if ia.localDNSServer != nil {
 		proxy.handlers[v3.NameTableType] = func(resp *anypb.Any) error {
@@ -338,7 +351,7 @@ func (p *XdsProxy) handleStream(downstream adsStream) error {
 	if err != nil {
 		proxyLog.Errorf("failed to connect to upstream %s: %v", p.istiodAddress, err)
 		metrics.IstiodConnectionFailures.Increment()
-		return err
+		return p.serveECDSFallback(con, err) // Modified by ingress
 	}
 	defer upstreamConn.Close()
 
@@ -366,7 +379,7 @@ func (p *XdsProxy) handleUpstream(ctx context.Context, con *ProxyConnection, xds
 		proxyLog.Debugf("failed to create upstream grpc client: %v", err)
 		// Increase metric when xds connection error, for example: forgot to restart ingressgateway or sidecar after changing root CA.
 		metrics.IstiodConnectionErrors.Increment()
-		return err
+		return p.serveECDSFallback(con, err) // Modified by ingress
 	}
 	proxyLog.Infof("connected to upstream XDS server: %s", p.istiodAddress)
 	defer proxyLog.Debugf("disconnected from XDS server: %s", p.istiodAddress)
@@ -478,11 +491,13 @@ func (p *XdsProxy) handleUpstreamRequest(con *ProxyConnection) {
 			}
 			proxyLog.Debugf("request for type url %s", req.TypeUrl)
 			metrics.XdsProxyRequests.Increment()
+			p.recordProxyVersion(req.Node) // Added by ingress
 			if req.TypeUrl == v3.ExtensionConfigurationType {
 				if req.VersionInfo != "" {
 					p.ecdsLastAckVersion.Store(req.VersionInfo)
 				}
 				p.ecdsLastNonce.Store(req.ResponseNonce)
+				p.ecdsStore.acked(req) // Added by ingress
 			}
 			if err := sendUpstream(con.upstream, req); err != nil {
 				err = fmt.Errorf("upstream [%d] send error for type url %s: %v", con.conID, req.TypeUrl, err)
@@ -540,6 +555,7 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 					})
 				} else {
 					// Otherwise, forward ECDS resource update directly to Envoy.
+					p.ecdsStore.forwarded(resp) // Added by ingress
 					forwardToEnvoy(con, resp)
 				}
 			default:
@@ -550,6 +566,7 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 				}
 			}
 		case resp := <-forwardEnvoyCh:
+			p.ecdsStore.forwarded(resp) // Added by ingress
 			forwardToEnvoy(con, resp)
 		case <-con.stopChan:
 			return
@@ -558,7 +575,7 @@ func (p *XdsProxy) handleUpstreamResponse(con *ProxyConnection) {
 }
 
 func (p *XdsProxy) rewriteAndForward(con *ProxyConnection, resp *discovery.DiscoveryResponse, forward func(resp *discovery.DiscoveryResponse)) {
-	if err := wasm.MaybeConvertWasmExtensionConfig(resp.Resources, p.wasmCache); err != nil {
+	if err := wasm.MaybeConvertWasmExtensionConfigForProxy(resp.Resources, p.wasmCache, p.proxyVersion.Load()); err != nil { // Modified by ingress
 		proxyLog.Debugf("sending NACK for ECDS resources %+v", resp.Resources)
 		con.sendRequest(&discovery.DiscoveryRequest{
 			VersionInfo:   p.ecdsLastAckVersion.Load(),
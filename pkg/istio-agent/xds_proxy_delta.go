@@ -199,6 +199,7 @@ func (p *XdsProxy) handleUpstreamDeltaRequest(con *ProxyConnection) {
 			con.deltaRequestsChan.Load()
 			proxyLog.Debugf("delta request for type url %s", req.TypeUrl)
 			metrics.XdsProxyRequests.Increment()
+			p.recordProxyVersion(req.Node) // Added by ingress
 			if req.TypeUrl == v3.ExtensionConfigurationType {
 				p.ecdsLastNonce.Store(req.ResponseNonce)
 			}
@@ -285,7 +286,7 @@ func (p *XdsProxy) deltaRewriteAndForward(con *ProxyConnection, resp *discovery.
 		resources = append(resources, resp.Resources[i].Resource)
 	}
 
-	if err := wasm.MaybeConvertWasmExtensionConfig(resources, p.wasmCache); err != nil {
+	if err := wasm.MaybeConvertWasmExtensionConfigForProxy(resources, p.wasmCache, p.proxyVersion.Load()); err != nil { // Modified by ingress
 		proxyLog.Debugf("sending NACK for ECDS resources %+v", resp.Resources)
 		con.sendDeltaRequest(&discovery.DeltaDiscoveryRequest{
 			TypeUrl:       v3.ExtensionConfigurationType,
@@ -91,7 +91,7 @@ func newSMT(hash func(data ...[]byte) []byte, updateCache cache.ExpiringCache, r
 		retentionDuration: retentionDuration,
 	}
 	s.db = &cacheDB{
-		updatedNodes: byteCache{cache: updateCache},
+		updatedNodes: newUpdatedNodes(updateCache),
 	}
 	s.loadDefaultHashes()
 	return s
@@ -231,7 +231,7 @@ func TestSmtRaisesError(t *testing.T) {
 	values := getFreshData(10)
 	_, err := smt.Update(keys, values)
 	assert.NoError(t, err)
-	smt.db.updatedNodes = byteCache{cache: cache.NewTTL(forever, time.Minute)}
+	smt.db.updatedNodes = newUpdatedNodes(cache.NewTTL(forever, time.Minute))
 	smt.loadDefaultHashes()
 
 	// Check errors are raised is a keys is not in cache nor db
@@ -16,46 +16,18 @@ package ledger
 
 import (
 	"sync"
-	"time"
 
 	"istio.io/istio/pkg/cache"
 )
 
 type cacheDB struct {
 	// updatedNodes that have will be flushed to disk
-	updatedNodes byteCache
+	updatedNodes cache.TypedExpiring[hash, [][]byte]
 	// updatedMux is a lock for updatedNodes
 	updatedMux sync.RWMutex
 }
 
-// byteCache implements a modified ExpiringCache interface, returning byte arrays
-// for ease of integration with smt calls.
-type byteCache struct {
-	cache cache.ExpiringCache
-}
-
-// Set inserts an entry in the cache. This will replace any entry with
-// the same key that is already in the cache. The entry may be automatically
-// expunged from the cache at some point, depending on the eviction policies
-// of the cache and the options specified when the cache was created.
-func (b *byteCache) Set(key hash, value [][]byte) {
-	b.cache.Set(key, value)
-}
-
-// Get retrieves the value associated with the supplied key if the key
-// is present in the cache.
-func (b *byteCache) Get(key hash) (value [][]byte, ok bool) {
-	ivalue, ok := b.cache.Get(key)
-	if ok {
-		value, _ = ivalue.([][]byte)
-	}
-	return
-}
-
-// SetWithExpiration inserts an entry in the cache with a requested expiration time.
-// This will replace any entry with the same key that is already in the cache.
-// The entry will be automatically expunged from the cache at or slightly after the
-// requested expiration time.
-func (b *byteCache) SetWithExpiration(key hash, value [][]byte, expiration time.Duration) {
-	b.cache.SetWithExpiration(key, value, expiration)
+// newUpdatedNodes wraps the cache of the nodes to be flushed to disk.
+func newUpdatedNodes(c cache.ExpiringCache) cache.TypedExpiring[hash, [][]byte] {
+	return cache.NewTypedExpiring[hash, [][]byte](c)
 }
@@ -0,0 +1,51 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	api "go.opentelemetry.io/otel/metric"
+
+	"istio.io/istio/pkg/log"
+)
+
+// Added by ingress
+
+// newDerivedSum creates a derived metric exported as a monotonic sum. Its functions return the
+// cumulative totals, such as the number of hits of a cache since it was created.
+func newDerivedSum(name, description string) DerivedMetric {
+	dm := &derivedGauge{
+		name:  name,
+		attrs: map[attribute.Set]func() float64{},
+	}
+	_, err := meter().Float64ObservableCounter(name,
+		api.WithDescription(description),
+		api.WithFloat64Callback(func(ctx context.Context, observer api.Float64Observer) error {
+			dm.mu.RLock()
+			defer dm.mu.RUnlock()
+			for kv, compute := range dm.attrs {
+				observer.Observe(compute(), api.WithAttributeSet(kv))
+			}
+			return nil
+		}))
+	if err != nil {
+		log.Fatalf("failed to create derived sum: %v", err)
+	}
+	return dm
+}
+
+// End added by ingress
@@ -226,6 +226,22 @@ func NewDerivedGauge(name, description string) DerivedMetric {
 	return newDerivedGauge(name, description)
 }
 
+// Added by ingress
+
+// NewDerivedSum creates a new Sum Metric whose values are cumulative totals.
+// Like NewDerivedGauge, it accepts functions which are called to get the current total, which
+// must never decrease.
+func NewDerivedSum(name, description string) DerivedMetric {
+	knownMetrics.register(MetricDefinition{
+		Name:        name,
+		Type:        "Sum",
+		Description: description,
+	})
+	return newDerivedSum(name, description)
+}
+
+// End added by ingress
+
 // NewDistribution creates a new Metric with an aggregation type of Distribution. This means that the
 // data collected by the Metric will be collected and exported as a histogram, with the specified bounds.
 func NewDistribution(name, description string, bounds []float64, opts ...Options) Metric {
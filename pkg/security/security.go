@@ -16,6 +16,7 @@ package security
 
 import (
 	"context"
+	"crypto"
 	"fmt"
 	"net/http"
 	"os"
@@ -24,6 +25,7 @@ import (
 
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/anypb"
 
 	"istio.io/istio/pkg/env"
 	istiolog "istio.io/istio/pkg/log"
@@ -259,8 +261,31 @@ type Options struct {
 	KeyFilePath string
 	// The path for an existing root certificate bundle
 	RootCertFilePath string
+
+	// Added by ingress
+	// KeyProvider, if set, holds the private keys of the workload certificates instead of the agent.
+	KeyProvider KeyProvider
+	// End added by ingress
+}
+
+// Added by ingress
+
+// KeyProvider holds the private keys of the workload certificates outside of the agent, such as in a PKCS#11
+// token or a TPM, so they never leave it. Envoy signs with them through a private key provider.
+type KeyProvider interface {
+	// GenerateKey generates a key for the certificate of the resource.
+	GenerateKey(ctx context.Context, resourceName string) (HardwareKey, error)
 }
 
+// HardwareKey is a private key held by a KeyProvider, signing the CSR of its certificate.
+type HardwareKey interface {
+	crypto.Signer
+	// PrivateKeyProvider returns the name and config of the private key provider of Envoy signing with the key.
+	PrivateKeyProvider() (name string, config *anypb.Any)
+}
+
+// End added by ingress
+
 // TokenManager contains methods for generating token.
 type TokenManager interface {
 	// GenerateToken takes STS request parameters and generates token. Returns
@@ -345,6 +370,11 @@ type SecretItem struct {
 	CreatedTime time.Time
 
 	ExpireTime time.Time
+
+	// Added by ingress
+	// HardwareKey is the key of the certificate held by the KeyProvider, set instead of PrivateKey.
+	HardwareKey HardwareKey
+	// End added by ingress
 }
 
 type CredFetcher interface {
@@ -0,0 +1,59 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const (
+	// abiVersionExportPrefix prefixes the function a proxy-wasm module exports to declare its ABI version, such as
+	// proxy_abi_version_0_2_1.
+	abiVersionExportPrefix = "proxy_abi_version_"
+	// abiVersionSection is the custom section a module may declare its ABI version in, such as 0.2.1.
+	abiVersionSection = "proxy_abi_version"
+)
+
+// abiMinProxyVersions are the first Istio minors whose Envoy implements each proxy-wasm ABI version, from the
+// proxy-wasm spec and the Envoy of the Istio releases: 0.1.0 came with the Wasm runtime of 1.5, 0.2.0 with the
+// Envoy of 1.6, and 0.2.1 with the Envoy of 1.9. The other ABI versions, such as the 0.3.0 draft, are implemented
+// by no Envoy yet. A new ABI version implemented by Envoy must be added here, or its modules are rejected.
+var abiMinProxyVersions = map[string]string{
+	"0.1.0": "1.5",
+	"0.2.0": "1.6",
+	"0.2.1": "1.9",
+}
+
+func abiVersions(compiledModule wazero.CompiledModule) []string {
+	var versions []string
+	for _, section := range compiledModule.CustomSections() {
+		if section.Name() == abiVersionSection {
+			versions = append(versions, strings.TrimSpace(string(section.Data())))
+		}
+	}
+	for name := range compiledModule.ExportedFunctions() {
+		if v, ok := strings.CutPrefix(name, abiVersionExportPrefix); ok {
+			versions = append(versions, strings.ReplaceAll(v, "_", "."))
+		}
+	}
+	return versions
+}
+
+// CheckABIVersions checks that the proxy of the Istio version supports the proxy-wasm ABI versions.
+func CheckABIVersions(versions []string, proxyVersion string) error {
+	proxy := model.ParseIstioVersion(proxyVersion)
+	for _, v := range versions {
+		minVersion, ok := abiMinProxyVersions[v]
+		if !ok {
+			return fmt.Errorf("proxy-wasm ABI version %v is not supported", v)
+		}
+		if proxy.Compare(model.ParseIstioVersion(minVersion)) < 0 {
+			return fmt.Errorf("proxy-wasm ABI version %v is not supported by proxy version %v, which must be at least %v",
+				v, proxyVersion, minVersion)
+		}
+	}
+	return nil
+}
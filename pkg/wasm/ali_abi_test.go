@@ -0,0 +1,170 @@
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"google.golang.org/protobuf/proto"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+// moduleExporting returns a Wasm module exporting an empty function under the name.
+func moduleExporting(name string) []byte {
+	module := append([]byte{}, wasmHeader...)
+	// Type section with a () -> () function type, and function section with a function of that type.
+	module = append(module, 0x01, 0x04, 0x01, 0x60, 0x00, 0x00, 0x03, 0x02, 0x01, 0x00)
+	// Export section exporting the function under the name.
+	module = append(module, 0x07, byte(len(name)+4), 0x01, byte(len(name)))
+	module = append(module, name...)
+	module = append(module, 0x00, 0x00)
+	// Code section with the empty body of the function.
+	return append(module, 0x0a, 0x04, 0x01, 0x02, 0x00, 0x0b)
+}
+
+func TestModuleABIVersions(t *testing.T) {
+	cases := []struct {
+		name   string
+		module []byte
+		want   []string
+	}{
+		{
+			name:   "no ABI version",
+			module: moduleExporting("run"),
+		},
+		{
+			name:   "exported ABI version",
+			module: moduleExporting("proxy_abi_version_0_2_1"),
+			want:   []string{"0.2.1"},
+		},
+		{
+			name:   "custom section ABI version",
+			module: appendCustomSection(moduleExporting("run"), abiVersionSection, []byte("0.3.0\n")),
+			want:   []string{"0.3.0"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			info, err := InspectModule(c.module)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(info.ABIVersions, c.want) {
+				t.Errorf("got ABI versions %v, want %v", info.ABIVersions, c.want)
+			}
+		})
+	}
+}
+
+func TestCheckABIVersions(t *testing.T) {
+	cases := []struct {
+		name         string
+		proxyVersion string
+		versions     []string
+		wantErr      bool
+	}{
+		{name: "supported", proxyVersion: "1.19.0", versions: []string{"0.2.1"}},
+		{name: "proxy too old", proxyVersion: "1.8.2", versions: []string{"0.2.1"}, wantErr: true},
+		{name: "unknown ABI version", proxyVersion: "1.19.0", versions: []string{"0.3.0"}, wantErr: true},
+		{name: "no ABI version", proxyVersion: "1.8.2"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := CheckABIVersions(c.versions, c.proxyVersion); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestWasmConvertABIVersion(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "test.wasm")
+	if err := os.WriteFile(module, moduleExporting("proxy_abi_version_0_2_1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failOpen := buildTypedStructExtensionConfig("remote-load-fail-open", &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Remote{
+						Remote: &core.RemoteDataSource{
+							HttpUri: &core.HttpUri{
+								Uri: "http://test?module=test.wasm",
+							},
+						},
+					}},
+				},
+			},
+			FailOpen: true,
+		},
+	})
+	cases := []struct {
+		name         string
+		module       string
+		proxyVersion string
+		input        *core.TypedExtensionConfig
+		wantOutput   *core.TypedExtensionConfig
+		wantErr      bool
+	}{
+		{
+			name:         "supported",
+			module:       module,
+			proxyVersion: "1.19.0",
+			input:        extensionConfigMap["remote-load-success"],
+			wantOutput:   localWasmConfig("remote-load-success", module),
+		},
+		{
+			name:         "proxy too old",
+			module:       module,
+			proxyVersion: "1.8.2",
+			input:        extensionConfigMap["remote-load-success"],
+			wantOutput:   extensionConfigMap["remote-load-success"],
+			wantErr:      true,
+		},
+		{
+			name:         "proxy too old fail open",
+			module:       module,
+			proxyVersion: "1.8.2",
+			input:        failOpen,
+			wantOutput:   buildAnyExtensionConfig("remote-load-fail-open", &rbac.RBAC{}),
+		},
+		{
+			name:       "unknown proxy version",
+			module:     module,
+			input:      extensionConfigMap["remote-load-success"],
+			wantOutput: localWasmConfig("remote-load-success", module),
+		},
+		{
+			// The modules that can not be read are not checked.
+			name:         "missing module",
+			module:       filepath.Join(dir, "missing.wasm"),
+			proxyVersion: "1.8.2",
+			input:        extensionConfigMap["remote-load-success"],
+			wantOutput:   localWasmConfig("remote-load-success", filepath.Join(dir, "missing.wasm")),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resources := []*anypb.Any{protoconv.MessageToAny(c.input)}
+			gotErr := MaybeConvertWasmExtensionConfigForProxy(resources, &fixtureCache{module: c.module}, c.proxyVersion)
+			if (gotErr != nil) != c.wantErr {
+				t.Fatalf("wasm config conversion got error %v, want error %v", gotErr, c.wantErr)
+			}
+			ec := &core.TypedExtensionConfig{}
+			if err := resources[0].UnmarshalTo(ec); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(ec, c.wantOutput) {
+				t.Errorf("wasm config conversion got %v want %v", ec, c.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package wasm
+
+import (
+	"fmt"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pkg/config/xds"
+)
+
+// tryUnmarshalWithMatcher returns the extension config wrapping a Wasm config with a matcher, such as the one of
+// the authentication state a WasmPlugin runs for, along with the wrapped Wasm config if it loads a remote module.
+func tryUnmarshalWithMatcher(ec *core.TypedExtensionConfig) (*core.TypedExtensionConfig, *wasm.Wasm, error) {
+	m := &matching.ExtensionWithMatcher{}
+	if err := ec.GetTypedConfig().UnmarshalTo(m); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal extension config resource with matcher: %w", err)
+	}
+	wrapped, err := anypb.New(m.GetExtensionConfig())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal extension config wrapped by matcher: %w", err)
+	}
+	wrappedConfig, wasmHTTPFilterConfig, err := tryUnmarshal(wrapped)
+	if err != nil || wrappedConfig == nil {
+		return nil, nil, err
+	}
+	return ec, wasmHTTPFilterConfig, nil
+}
+
+// setWasmTypedConfig sets the converted Wasm config to the extension config, or to the one it wraps with a matcher.
+func setWasmTypedConfig(ec *core.TypedExtensionConfig, wasmTypedConfig *anypb.Any) error {
+	if ec.GetTypedConfig().GetTypeUrl() != xds.ExtensionWithMatcherType {
+		ec.TypedConfig = wasmTypedConfig
+		return nil
+	}
+	m := &matching.ExtensionWithMatcher{}
+	if err := ec.GetTypedConfig().UnmarshalTo(m); err != nil {
+		return fmt.Errorf("failed to unmarshal extension config resource with matcher: %w", err)
+	}
+	m.ExtensionConfig.TypedConfig = wasmTypedConfig
+	typedConfig, err := anypb.New(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal new extension config with matcher %+v to protobuf Any: %w", m, err)
+	}
+	ec.TypedConfig = typedConfig
+	return nil
+}
@@ -0,0 +1,117 @@
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	xdscore "github.com/cncf/xds/go/xds/core/v3"
+	matcher "github.com/cncf/xds/go/xds/type/matcher/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	matching "github.com/envoyproxy/go-control-plane/envoy/extensions/common/matching/v3"
+	action "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/common/matcher/action/v3"
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"google.golang.org/protobuf/proto"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+func withMatcher(ec *core.TypedExtensionConfig) *core.TypedExtensionConfig {
+	return buildAnyExtensionConfig(ec.Name, &matching.ExtensionWithMatcher{
+		ExtensionConfig: ec,
+		XdsMatcher: &matcher.Matcher{
+			OnNoMatch: &matcher.Matcher_OnMatch{
+				OnMatch: &matcher.Matcher_OnMatch_Action{
+					Action: &xdscore.TypedExtensionConfig{
+						Name:        "skip",
+						TypedConfig: protoconv.MessageToAny(&action.SkipFilter{}),
+					},
+				},
+			},
+		},
+	})
+}
+
+// fixtureCache serves the fixture module for the fetches the mock cache accepts.
+type fixtureCache struct {
+	mockCache
+	module string
+}
+
+func (c *fixtureCache) Get(downloadURL string, opts GetOptions) (string, error) {
+	if _, err := c.mockCache.Get(downloadURL, opts); err != nil {
+		return "", err
+	}
+	return c.module, nil
+}
+
+func localWasmConfig(name, filename string) *core.TypedExtensionConfig {
+	return buildAnyExtensionConfig(name, &wasm.Wasm{
+		Config: &v3.PluginConfig{
+			Vm: &v3.PluginConfig_VmConfig{
+				VmConfig: &v3.VmConfig{
+					Code: &core.AsyncDataSource{Specifier: &core.AsyncDataSource_Local{
+						Local: &core.DataSource{
+							Specifier: &core.DataSource_Filename{
+								Filename: filename,
+							},
+						},
+					}},
+				},
+			},
+		},
+	})
+}
+
+func TestWasmConvertWithMatcher(t *testing.T) {
+	module := filepath.Join(t.TempDir(), "test.wasm")
+	if err := os.WriteFile(module, moduleExporting("_start"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name       string
+		input      *core.TypedExtensionConfig
+		wantOutput *core.TypedExtensionConfig
+		wantErr    bool
+	}{
+		{
+			name:       "remote load success",
+			input:      withMatcher(extensionConfigMap["remote-load-success"]),
+			wantOutput: withMatcher(localWasmConfig("remote-load-success", module)),
+		},
+		{
+			name:       "secret",
+			input:      withMatcher(extensionConfigMap["remote-load-secret"]),
+			wantOutput: withMatcher(localWasmConfig("remote-load-success", module)),
+		},
+		{
+			name:       "remote load fail",
+			input:      withMatcher(extensionConfigMap["remote-load-fail"]),
+			wantOutput: withMatcher(extensionConfigMap["remote-load-fail"]),
+			wantErr:    true,
+		},
+		{
+			name:       "no remote load",
+			input:      withMatcher(extensionConfigMap["no-remote-load"]),
+			wantOutput: withMatcher(extensionConfigMap["no-remote-load"]),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resources := []*anypb.Any{protoconv.MessageToAny(c.input)}
+			gotErr := MaybeConvertWasmExtensionConfig(resources, &fixtureCache{module: module})
+			if (gotErr != nil) != c.wantErr {
+				t.Fatalf("wasm config conversion got error %v, want error %v", gotErr, c.wantErr)
+			}
+			ec := &core.TypedExtensionConfig{}
+			if err := resources[0].UnmarshalTo(ec); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(ec, c.wantOutput) {
+				t.Errorf("wasm config conversion got %v want %v", ec, c.wantOutput)
+			}
+		})
+	}
+}
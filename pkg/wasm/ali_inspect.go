@@ -0,0 +1,112 @@
+package wasm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// CustomSection describes a custom section of a Wasm module.
+type CustomSection struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+// ModuleInfo describes a Wasm module the way the agent sees it when it converts a remote module for the proxy.
+type ModuleInfo struct {
+	// Size of the module in bytes.
+	Size int `json:"size"`
+	// SHA256 is the hex-encoded sha256 checksum of the module.
+	SHA256 string `json:"sha256"`
+	// ABIVersions are the proxy-wasm ABI versions the module declares.
+	ABIVersions []string `json:"abiVersions,omitempty"`
+	// WamrAot is set when the module holds precompiled code the agent runs with the WAMR runtime.
+	WamrAot bool `json:"wamrAot"`
+	// ConfigSchema is the JSON Schema of the plugin config embedded in the module, if any.
+	ConfigSchema string `json:"configSchema,omitempty"`
+	// CustomSections are the custom sections of the module, in order.
+	CustomSections []CustomSection `json:"customSections,omitempty"`
+	// Exports are the names of the functions the module exports, sorted.
+	Exports []string `json:"exports,omitempty"`
+}
+
+// InspectModule describes the Wasm module, with the same parsing as the conversion of remote modules.
+func InspectModule(wasmBinary []byte) (*ModuleInfo, error) {
+	if !isValidWasmBinary(wasmBinary) {
+		return nil, fmt.Errorf("module is not a valid Wasm binary")
+	}
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCustomSections(true))
+	defer r.Close(ctx)
+	compiledModule, err := r.CompileModule(ctx, wasmBinary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile Wasm module: %w", err)
+	}
+	sha := sha256.Sum256(wasmBinary)
+	info := &ModuleInfo{
+		Size:        len(wasmBinary),
+		SHA256:      hex.EncodeToString(sha[:]),
+		ABIVersions: abiVersions(compiledModule),
+		WamrAot:     hasWamrAotSection(compiledModule),
+	}
+	for _, section := range compiledModule.CustomSections() {
+		info.CustomSections = append(info.CustomSections, CustomSection{Name: section.Name(), Size: len(section.Data())})
+		if section.Name() == PluginConfigSchemaSection {
+			info.ConfigSchema = string(section.Data())
+		}
+	}
+	for name := range compiledModule.ExportedFunctions() {
+		info.Exports = append(info.Exports, name)
+	}
+	sort.Strings(info.Exports)
+	return info, nil
+}
+
+// FetchOptions configures the fetch of a Wasm module.
+type FetchOptions struct {
+	// PullSecret is the docker config used to pull images, the default keychain is used when unset.
+	PullSecret []byte
+	// Insecure allows fetching the module from registries and servers with untrusted certificates or over plain HTTP.
+	Insecure bool
+	// Timeout of each request.
+	Timeout time.Duration
+	// MaxRetries of HTTP requests.
+	MaxRetries int
+}
+
+// Fetch fetches the Wasm module of the http, https or oci URL with the fetchers of the agent.
+func Fetch(ctx context.Context, downloadURL string, opts FetchOptions) ([]byte, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse Wasm module fetch url: %s, error: %v", downloadURL, err)
+	}
+	var b []byte
+	switch u.Scheme {
+	case "http", "https":
+		b, err = NewHTTPFetcher(opts.Timeout, opts.MaxRetries).Fetch(ctx, downloadURL, opts.Insecure)
+		if err != nil {
+			return nil, err
+		}
+	case "oci":
+		fetcher := NewImageFetcher(ctx, ImageFetcherOption{PullSecret: opts.PullSecret, Insecure: opts.Insecure})
+		binaryFetcher, _, err := fetcher.PrepareFetch(u.Host + u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch Wasm OCI image: %v", err)
+		}
+		if b, err = binaryFetcher(); err != nil {
+			return nil, fmt.Errorf("could not fetch Wasm binary: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Wasm module downloading URL scheme: %v", u.Scheme)
+	}
+	if !isValidWasmBinary(b) {
+		return nil, fmt.Errorf("fetched Wasm binary from %s is invalid", downloadURL)
+	}
+	return b, nil
+}
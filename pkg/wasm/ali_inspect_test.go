@@ -0,0 +1,38 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInspectModule(t *testing.T) {
+	module := appendCustomSection(moduleExporting("proxy_abi_version_0_2_1"), "wamr-aot", []byte("aot"))
+	module = appendCustomSection(module, PluginConfigSchemaSection, []byte(testSchema))
+	info, err := InspectModule(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != len(module) || len(info.SHA256) != 64 {
+		t.Errorf("unexpected size %d and checksum %q", info.Size, info.SHA256)
+	}
+	if !reflect.DeepEqual(info.ABIVersions, []string{"0.2.1"}) {
+		t.Errorf("got ABI versions %v", info.ABIVersions)
+	}
+	if !info.WamrAot {
+		t.Errorf("expected the module to be run with the WAMR runtime")
+	}
+	if info.ConfigSchema != testSchema {
+		t.Errorf("got config schema %q", info.ConfigSchema)
+	}
+	wantSections := []CustomSection{{Name: "wamr-aot", Size: 3}, {Name: PluginConfigSchemaSection, Size: len(testSchema)}}
+	if !reflect.DeepEqual(info.CustomSections, wantSections) {
+		t.Errorf("got custom sections %v, want %v", info.CustomSections, wantSections)
+	}
+	if !reflect.DeepEqual(info.Exports, []string{"proxy_abi_version_0_2_1"}) {
+		t.Errorf("got exports %v", info.Exports)
+	}
+
+	if _, err := InspectModule([]byte("not wasm")); err == nil {
+		t.Errorf("expected an invalid module to fail inspection")
+	}
+}
@@ -0,0 +1,95 @@
+package wasm
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+)
+
+func encodePublicKey(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerifySignature(t *testing.T) {
+	module := moduleExporting("run")
+	digest := sha256.Sum256(module)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecSignature, err := ecdsa.SignASN1(rand.Reader, ecKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		module    []byte
+		signature []byte
+		key       []byte
+		wantErr   bool
+	}{
+		{
+			name:      "ecdsa",
+			module:    module,
+			signature: ecSignature,
+			key:       encodePublicKey(t, &ecKey.PublicKey),
+		},
+		{
+			name:      "base64 encoded ecdsa",
+			module:    module,
+			signature: []byte(base64.StdEncoding.EncodeToString(ecSignature) + "\n"),
+			key:       encodePublicKey(t, &ecKey.PublicKey),
+		},
+		{
+			name:      "ed25519",
+			module:    module,
+			signature: ed25519.Sign(edKey, module),
+			key:       encodePublicKey(t, edPublic),
+		},
+		{
+			name:      "tampered module",
+			module:    appendCustomSection(module, "tampered", nil),
+			signature: ecSignature,
+			key:       encodePublicKey(t, &ecKey.PublicKey),
+			wantErr:   true,
+		},
+		{
+			name:      "other key",
+			module:    module,
+			signature: ecSignature,
+			key:       encodePublicKey(t, edPublic),
+			wantErr:   true,
+		},
+		{
+			name:      "not a PEM key",
+			module:    module,
+			signature: ecSignature,
+			key:       []byte("key"),
+			wantErr:   true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := VerifySignature(c.module, c.signature, c.key); (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
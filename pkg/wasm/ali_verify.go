@@ -0,0 +1,174 @@
+package wasm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// moduleChecksumSuffix is the suffix of the file recording the checksum of a Wasm module file next to it, so a
+// module left on the node by a previous agent can be verified before it is reused.
+const moduleChecksumSuffix = ".sha256"
+
+func checksumPath(modulePath string) string {
+	return modulePath + moduleChecksumSuffix
+}
+
+// writeModule materializes the Wasm module into the module file along with its checksum file, and returns the
+// hex-encoded sha256 checksum of the module. A module file already holding the module is not written again, as
+// Envoy may be loading it.
+func writeModule(modulePath string, wasmModule []byte) (string, error) {
+	sha := sha256.Sum256(wasmModule)
+	sum := hex.EncodeToString(sha[:])
+	if _, err := checkModule(modulePath, sum); err != nil {
+		if err := os.WriteFile(modulePath, wasmModule, 0o644); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(checksumPath(modulePath), []byte(sum), 0o644); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// checkModule reads the module file, and checks that it is a Wasm binary matching the checksum.
+func checkModule(modulePath, sum string) ([]byte, error) {
+	b, err := os.ReadFile(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	sha := sha256.Sum256(b)
+	if got := hex.EncodeToString(sha[:]); got != sum {
+		return nil, fmt.Errorf("module has checksum %v, which does not match the recorded %v", got, sum)
+	}
+	if !isValidWasmBinary(b) {
+		return nil, fmt.Errorf("module is not a valid Wasm binary")
+	}
+	return b, nil
+}
+
+// readModule reads the module file, checking it against the checksum recorded by writeModule.
+func readModule(modulePath string) ([]byte, error) {
+	sum, err := os.ReadFile(checksumPath(modulePath))
+	if err != nil {
+		return nil, err
+	}
+	return checkModule(modulePath, strings.TrimSpace(string(sum)))
+}
+
+// removeModule removes the module file and its checksum file.
+func removeModule(modulePath string) error {
+	if err := os.Remove(checksumPath(modulePath)); err != nil && !os.IsNotExist(err) {
+		wasmLog.Warnf("failed to remove checksum of Wasm module %v: %v", modulePath, err)
+	}
+	return os.Remove(modulePath)
+}
+
+// verifyDir removes the module files left in the cache directory by a previous agent which no longer match their
+// recorded checksums, so tampered or corrupted modules are fetched again rather than loaded into Envoy.
+func (c *LocalFileCache) verifyDir() {
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch {
+		case strings.HasSuffix(path, ".wasm"):
+			if _, err := readModule(path); err != nil {
+				wasmCacheVerificationCount.With(resultTag.Value(verificationFailure)).Increment()
+				wasmLog.Warnf("removing cached Wasm module %v failing verification: %v", path, err)
+				if err := removeModule(path); err != nil {
+					wasmLog.Errorf("failed to remove Wasm module %v: %v", path, err)
+				}
+				return nil
+			}
+			wasmCacheVerificationCount.With(resultTag.Value(verificationSuccess)).Increment()
+		case strings.HasSuffix(path, moduleChecksumSuffix):
+			// Remove the checksum files of modules which are gone.
+			if _, err := os.Stat(strings.TrimSuffix(path, moduleChecksumSuffix)); os.IsNotExist(err) {
+				_ = os.Remove(path)
+			}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		wasmLog.Warnf("failed to verify Wasm modules in %v: %v", c.dir, err)
+	}
+}
+
+// reuseModule adds the module of the key left in the cache directory by a previous agent to the cache, if it still
+// matches its recorded checksum, sparing a fetch.
+func (c *LocalFileCache) reuseModule(key cacheKey) *cacheEntry {
+	if key.checksum == "" {
+		return nil
+	}
+	modulePath, err := getModulePath(c.dir, key.moduleKey)
+	if err != nil {
+		return nil
+	}
+	b, err := readModule(modulePath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			wasmCacheVerificationCount.With(resultTag.Value(verificationFailure)).Increment()
+			wasmLog.Warnf("not reusing cached Wasm module %v failing verification: %v", modulePath, err)
+		}
+		return nil
+	}
+	ce, err := c.addEntry(key, b)
+	if err != nil {
+		return nil
+	}
+	wasmCacheVerificationCount.With(resultTag.Value(verificationSuccess)).Increment()
+	wasmLog.Debugf("reusing cached Wasm module %v", modulePath)
+	return ce
+}
+
+// verifyEntry re-hashes the module file of the cache entry against the checksum recorded when it was written. An
+// entry failing verification is removed along with its file, so the module is fetched again.
+func (c *LocalFileCache) verifyEntry(mkey moduleKey, ce *cacheEntry) bool {
+	// Entries without a recorded checksum cannot be verified.
+	if ce.fileChecksum == "" {
+		return true
+	}
+	if _, err := checkModule(ce.modulePath, ce.fileChecksum); err != nil {
+		wasmCacheVerificationCount.With(resultTag.Value(verificationFailure)).Increment()
+		wasmLog.Warnf("removing cached Wasm module %v failing verification: %v", ce.modulePath, err)
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		// The entry may have been purged or replaced in the meantime.
+		if c.modules[mkey] == ce {
+			if err := removeModule(ce.modulePath); err != nil && !os.IsNotExist(err) {
+				wasmLog.Errorf("failed to remove Wasm module %v: %v", ce.modulePath, err)
+			}
+			for downloadURL := range ce.referencingURLs {
+				delete(c.checksums, downloadURL)
+			}
+			delete(c.modules, mkey)
+			wasmCacheEntries.Record(float64(len(c.modules)))
+		}
+		return false
+	}
+	wasmCacheVerificationCount.With(resultTag.Value(verificationSuccess)).Increment()
+	return true
+}
+
+// verifyEntries periodically verifies the module files of the cache entries, so modules tampered or corrupted on the
+// node after they were fetched are not handed to Envoy again.
+func (c *LocalFileCache) verifyEntries() {
+	c.mux.Lock()
+	entries := make(map[moduleKey]*cacheEntry, len(c.modules))
+	for k, ce := range c.modules {
+		entries[k] = ce
+	}
+	c.mux.Unlock()
+	for k, ce := range entries {
+		c.verifyEntry(k, ce)
+	}
+}
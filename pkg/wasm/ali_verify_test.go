@@ -0,0 +1,88 @@
+package wasm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func TestWasmCacheVerification(t *testing.T) {
+	binary := append(wasmHeader, []byte("data")...)
+	sha := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sha[:])
+	var numRequest int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&numRequest, 1)
+		w.Write(binary)
+	}))
+	defer ts.Close()
+
+	tmpDir := t.TempDir()
+	options := defaultOptions()
+	options.PurgeInterval = 10 * time.Millisecond
+	get := func(cache *LocalFileCache, wantNumRequest int32) string {
+		t.Helper()
+		path, err := cache.Get(ts.URL, GetOptions{
+			Checksum:        checksum,
+			ResourceName:    "namespace.resource",
+			ResourceVersion: "0",
+			RequestTimeout:  time.Second * 10,
+		})
+		if err != nil {
+			t.Fatalf("failed to get Wasm module: %v", err)
+		}
+		if got := atomic.LoadInt32(&numRequest); got != wantNumRequest {
+			t.Fatalf("wasm download call got %v want %v", got, wantNumRequest)
+		}
+		if b, err := os.ReadFile(path); err != nil || string(b) != string(binary) {
+			t.Fatalf("unexpected Wasm module file %v: %v", path, err)
+		}
+		return path
+	}
+	tamper := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, append(wasmHeader, []byte("tampered")...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache := NewLocalFileCache(tmpDir, defaultOptions())
+	path := get(cache, 1)
+	// A tampered module is fetched again rather than reused.
+	tamper(path)
+	get(cache, 2)
+	close(cache.stopChan)
+
+	// A module left by a previous agent is reused without fetching it.
+	cache = NewLocalFileCache(tmpDir, defaultOptions())
+	get(cache, 2)
+	close(cache.stopChan)
+
+	// A module tampered with before the agent starts is removed.
+	tamper(path)
+	cache = NewLocalFileCache(tmpDir, defaultOptions())
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the tampered module to be removed, got %v", err)
+	}
+	get(cache, 3)
+	close(cache.stopChan)
+
+	// A module tampered with while cached is removed by the periodic verification.
+	cache = NewLocalFileCache(tmpDir, options)
+	defer close(cache.stopChan)
+	get(cache, 3)
+	tamper(path)
+	retry.UntilOrFail(t, func() bool {
+		cache.mux.Lock()
+		defer cache.mux.Unlock()
+		return len(cache.modules) == 0
+	}, retry.Timeout(5*time.Second), retry.Message("tampered module is still cached"))
+	get(cache, 4)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/name"
 
 	extensions "istio.io/api/extensions/v1alpha1"
+	"istio.io/istio/pkg/cache"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/util/sets"
 )
@@ -59,6 +60,8 @@ type LocalFileCache struct {
 	checksums map[string]*checksumEntry
 	// http fetcher fetches Wasm module with HTTP get.
 	httpFetcher *HTTPFetcher
+	// fetches deduplicates concurrent fetches of the same module.
+	fetches cache.TypedLoading[fetchKey, *cacheEntry]
 
 	// directory path used to store Wasm module.
 	dir string
@@ -98,6 +101,13 @@ type cacheKey struct {
 	resourceVersion string
 }
 
+// fetchKey identifies a fetch of a Wasm module. Concurrent fetches are only deduplicated if they have the
+// same pull policy, so a fetch pulling the module always is never served by one reusing the cached module.
+type fetchKey struct {
+	cacheKey
+	pullPolicy extensions.PullPolicy
+}
+
 // cacheEntry contains information about a Wasm module cache entry.
 type cacheEntry struct {
 	// File path to the downloaded wasm modules.
@@ -106,6 +116,10 @@ type cacheEntry struct {
 	last time.Time
 	// set of URLs referencing this entry
 	referencingURLs sets.String
+	// Added by ingress
+	// Hex-encoded sha256 checksum of the module file, recorded when it was written.
+	fileChecksum string
+	// End added by ingress
 }
 
 type cacheOptions struct {
@@ -149,12 +163,16 @@ func NewLocalFileCache(dir string, options Options) *LocalFileCache {
 	cacheOptions := cacheOptions{Options: options}
 	cache := &LocalFileCache{
 		httpFetcher:  NewHTTPFetcher(options.HTTPRequestTimeout, options.HTTPRequestMaxRetries),
+		fetches:      cache.NewTypedLoading[fetchKey, *cacheEntry](cache.WithSingleflight(cache.NewTTL(0, 0))),
 		modules:      make(map[moduleKey]*cacheEntry),
 		checksums:    make(map[string]*checksumEntry),
 		dir:          dir,
 		cacheOptions: cacheOptions.sanitize(),
 		stopChan:     make(chan struct{}),
 	}
+	// Added by ingress
+	cache.verifyDir()
+	// End added by ingress
 
 	go func() {
 		cache.purge()
@@ -209,7 +227,12 @@ func (c *LocalFileCache) Get(downloadURL string, opts GetOptions) (string, error
 		resourceVersion: opts.ResourceVersion,
 	}
 
-	entry, err := c.getOrFetch(key, opts)
+	fk := fetchKey{cacheKey: key, pullPolicy: opts.PullPolicy}
+	entry, err := c.fetches.GetOrLoad(fk, func() (*cacheEntry, error) {
+		return c.getOrFetch(key, opts)
+	})
+	// Modules are tracked by getOrFetch, the loading cache only needs to hold in-flight fetches.
+	c.fetches.Remove(fk)
 	if err != nil {
 		return "", err
 	}
@@ -225,10 +248,17 @@ func (c *LocalFileCache) getOrFetch(key cacheKey, opts GetOptions) (*cacheEntry,
 
 	// First check if the cache entry is already downloaded and policy does not require to pull always.
 	ce, checksum := c.getEntry(key, shouldIgnoreResourceVersion(opts.PullPolicy, u))
-	if ce != nil {
+	// Modified by ingress
+	if ce != nil && c.verifyEntry(moduleKey{name: key.name, checksum: checksum}, ce) {
 		return ce, nil
 	}
+	// End modified by ingress
 	key.checksum = checksum
+	// Added by ingress
+	if ce := c.reuseModule(key); ce != nil {
+		return ce, nil
+	}
+	// End added by ingress
 	// Fetch the image now as it is not available in cache.
 	var b []byte         // Byte array of Wasm binary.
 	var dChecksum string // Hex-Encoded sha256 checksum of binary.
@@ -270,9 +300,14 @@ func (c *LocalFileCache) getOrFetch(key cacheKey, opts GetOptions) (*cacheEntry,
 	if key.checksum == "" {
 		key.checksum = dChecksum
 		// check again if the cache is having the checksum.
-		if ce, _ := c.getEntry(key, true); ce != nil {
+		// Modified by ingress
+		if ce, _ := c.getEntry(key, true); ce != nil && c.verifyEntry(key.moduleKey, ce) {
 			return ce, nil
 		}
+		if ce := c.reuseModule(key); ce != nil {
+			return ce, nil
+		}
+		// End modified by ingress
 	} else if dChecksum != key.checksum {
 		wasmRemoteFetchCount.With(resultTag.Value(checksumMismatch)).Increment()
 		return nil, fmt.Errorf("module downloaded from %v has checksum %v, which does not match: %v", key.downloadURL, dChecksum, key.checksum)
@@ -340,14 +375,18 @@ func (c *LocalFileCache) addEntry(key cacheKey, wasmModule []byte) (*cacheEntry,
 		return nil, err
 	}
 	// Materialize the Wasm module into a local file. Use checksum as name of the module.
-	if err := os.WriteFile(modulePath, wasmModule, 0o644); err != nil {
+	// Modified by ingress
+	fileChecksum, err := writeModule(modulePath, wasmModule)
+	if err != nil {
 		return nil, err
 	}
+	// End modified by ingress
 
 	ce := cacheEntry{
 		modulePath:      modulePath,
 		last:            time.Now(),
 		referencingURLs: sets.New[string](),
+		fileChecksum:    fileChecksum, // Added by ingress
 	}
 	if needChecksumUpdate {
 		ce.referencingURLs.Insert(key.downloadURL)
@@ -414,7 +453,7 @@ func (c *LocalFileCache) purge() {
 					continue
 				}
 				// The module has not be touched for expiry duration, delete it from the map as well as the local dir.
-				if err := os.Remove(m.modulePath); err != nil {
+				if err := removeModule(m.modulePath); err != nil { // Modified by ingress
 					wasmLog.Errorf("failed to purge Wasm module %v: %v", m.modulePath, err)
 				} else {
 					for downloadURL := range m.referencingURLs {
@@ -426,6 +465,7 @@ func (c *LocalFileCache) purge() {
 			}
 			wasmCacheEntries.Record(float64(len(c.modules)))
 			c.mux.Unlock()
+			c.verifyEntries() // Added by ingress
 		case <-c.stopChan:
 			// Currently this will only happen in test.
 			return
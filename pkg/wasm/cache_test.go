@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -748,7 +749,7 @@ func TestWasmCache(t *testing.T) {
 			}
 
 			if diff := cmp.Diff(c.wantCachedModules, cache.modules,
-				cmpopts.IgnoreFields(cacheEntry{}, "last", "referencingURLs"),
+				cmpopts.IgnoreFields(cacheEntry{}, "last", "referencingURLs", "fileChecksum"),
 				cmp.AllowUnexported(cacheEntry{}),
 			); diff != "" {
 				t.Errorf("unexpected module cache: (-want, +got)\n%v", diff)
@@ -909,6 +910,54 @@ func TestWasmCachePolicyChangesUsingHTTP(t *testing.T) {
 	testWasmGet(url2, extensions.PullPolicy_Always, "4", wantFilePath2, 3)
 }
 
+func TestWasmCacheFetchDedupByPullPolicy(t *testing.T) {
+	cache := NewLocalFileCache(t.TempDir(), defaultOptions())
+	defer close(cache.stopChan)
+
+	var requests atomic.Int32
+	received := make(chan struct{}, 2)
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		received <- struct{}{}
+		<-release
+		w.Write(append(wasmHeader, 1))
+	}))
+	defer ts.Close()
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+
+	errs := make(chan error, 2)
+	for _, policy := range []extensions.PullPolicy{extensions.PullPolicy_IfNotPresent, extensions.PullPolicy_Always} {
+		policy := policy
+		go func() {
+			_, err := cache.Get(ts.URL, GetOptions{
+				ResourceName:    "namespace.resource",
+				ResourceVersion: "1",
+				RequestTimeout:  time.Second * 10,
+				PullPolicy:      policy,
+			})
+			errs <- err
+		}()
+		// A fetch pulling always is not deduplicated with the one in flight reusing the cached module.
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fetch with pull policy %v was not issued", policy)
+		}
+	}
+	unblock()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("got %d requests, want 2", got)
+	}
+}
+
 func TestAllInsecureServer(t *testing.T) {
 	tmpDir := t.TempDir()
 	options := defaultOptions()
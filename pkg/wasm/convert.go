@@ -30,6 +30,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/tetratelabs/wazero"
 	anypb "google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/hashicorp/go-version"
 	extensions "istio.io/api/extensions/v1alpha1"
@@ -59,9 +60,17 @@ func createAllowAllFilter(name string) (*anypb.Any, error) {
 	return anypb.New(ec)
 }
 
+// Modified by Ingress
 // MaybeConvertWasmExtensionConfig converts any presence of module remote download to local file.
 // It downloads the Wasm module and stores the module locally in the file system.
 func MaybeConvertWasmExtensionConfig(resources []*anypb.Any, cache Cache) error {
+	return MaybeConvertWasmExtensionConfigForProxy(resources, cache, "")
+}
+
+// MaybeConvertWasmExtensionConfigForProxy converts the remote modules like MaybeConvertWasmExtensionConfig, and
+// checks that the proxy of the Istio version supports their proxy-wasm ABI versions, unless the version is unknown.
+func MaybeConvertWasmExtensionConfigForProxy(resources []*anypb.Any, cache Cache, proxyVersion string) error {
+	// End modified by Ingress
 	var wg sync.WaitGroup
 
 	numResources := len(resources)
@@ -94,7 +103,9 @@ func MaybeConvertWasmExtensionConfig(resources []*anypb.Any, cache Cache) error
 				return
 			}
 
-			newExtensionConfig, err := convertWasmConfigFromRemoteToLocal(extConfig, wasmConfig, cache)
+			// Modified by Ingress
+			newExtensionConfig, err := convertWasmConfigFromRemoteToLocal(extConfig, wasmConfig, cache, proxyVersion)
+			// End modified by Ingress
 			if err != nil {
 				convertErrs[i] = err
 				return
@@ -122,6 +133,11 @@ func tryUnmarshal(resource *anypb.Any) (*core.TypedExtensionConfig, *wasm.Wasm,
 	if err := resource.UnmarshalTo(ec); err != nil {
 		return nil, nil, fmt.Errorf("failed to unmarshal extension config resource: %w", err)
 	}
+	// Added by Ingress
+	if ec.GetTypedConfig().GetTypeUrl() == xds.ExtensionWithMatcherType {
+		return tryUnmarshalWithMatcher(ec)
+	}
+	// End added by Ingress
 
 	// Wasm filter can be configured using typed struct and Wasm filter type
 	switch {
@@ -166,7 +182,11 @@ func tryUnmarshal(resource *anypb.Any) (*core.TypedExtensionConfig, *wasm.Wasm,
 	return ec, wasmHTTPFilterConfig, nil
 }
 
-func convertWasmConfigFromRemoteToLocal(ec *core.TypedExtensionConfig, wasmHTTPFilterConfig *wasm.Wasm, cache Cache) (*anypb.Any, error) {
+// Modified by Ingress
+func convertWasmConfigFromRemoteToLocal(ec *core.TypedExtensionConfig, wasmHTTPFilterConfig *wasm.Wasm, cache Cache,
+	proxyVersion string,
+) (*anypb.Any, error) {
+	// End modified by Ingress
 	status := conversionSuccess
 	defer func() {
 		wasmConfigConversionCount.
@@ -243,11 +263,31 @@ func convertWasmConfigFromRemoteToLocal(ec *core.TypedExtensionConfig, wasmHTTPF
 	}
 
 	// Added by Ingress
-	// Check for wamr-aot custom section
-	hasWamrAotSection := containsWamrAotInCustomSection(f)
-	if hasWamrAotSection {
-		vm.Runtime = wamrRuntime
-		vm.AllowPrecompiled = true
+	if info := inspectFetchedModule(f); info != nil {
+		if proxyVersion != "" {
+			if err := CheckABIVersions(info.ABIVersions, proxyVersion); err != nil {
+				status = abiVersionFailure
+				if wasmHTTPFilterConfig.Config.GetFailOpen() {
+					wasmLog.Warnf("serving an allow all filter for fail open Wasm module %v: %v", remote.GetHttpUri().GetUri(), err)
+					return createAllowAllFilter(ec.Name)
+				}
+				return nil, fmt.Errorf("unsupported Wasm module %v: %w", remote.GetHttpUri().GetUri(), err)
+			}
+		}
+		if err := validatePluginConfig([]byte(info.ConfigSchema), wasmHTTPFilterConfig); err != nil {
+			status = schemaValidationFailure
+			if wasmHTTPFilterConfig.Config.GetFailOpen() {
+				wasmLog.Warnf("serving an allow all filter for fail open Wasm module %v: %v", remote.GetHttpUri().GetUri(), err)
+				return createAllowAllFilter(ec.Name)
+			}
+			return nil, fmt.Errorf("invalid plugin config for Wasm module %v: %w", remote.GetHttpUri().GetUri(), err)
+		}
+
+		// Check for wamr-aot custom section
+		if info.WamrAot {
+			vm.Runtime = wamrRuntime
+			vm.AllowPrecompiled = true
+		}
 	}
 	// End added by Ingress
 
@@ -267,7 +307,12 @@ func convertWasmConfigFromRemoteToLocal(ec *core.TypedExtensionConfig, wasmHTTPF
 		status = marshalFailure
 		return nil, fmt.Errorf("failed to marshal new wasm HTTP filter %+v to protobuf Any: %w", wasmHTTPFilterConfig, err)
 	}
-	ec.TypedConfig = wasmTypedConfig
+	// Modified by Ingress
+	if err := setWasmTypedConfig(ec, wasmTypedConfig); err != nil {
+		status = marshalFailure
+		return nil, err
+	}
+	// End modified by Ingress
 	wasmLog.Debugf("new extension config resource %+v", ec)
 
 	nec, err := anypb.New(ec)
@@ -282,6 +327,36 @@ func convertWasmConfigFromRemoteToLocal(ec *core.TypedExtensionConfig, wasmHTTPF
 }
 
 // Added by Ingress
+// inspectFetchedModule parses the fetched module once for the checks of the conversion. The modules that can not be
+// read or parsed here are not checked, and are left for the runtime to reject.
+func inspectFetchedModule(wasmModulePath string) *ModuleInfo {
+	wasmBinary, err := os.ReadFile(wasmModulePath)
+	if err != nil {
+		wasmLog.Debugf("cannot check Wasm module %v: %v", wasmModulePath, err)
+		return nil
+	}
+	info, err := InspectModule(wasmBinary)
+	if err != nil {
+		wasmLog.Debugf("cannot check Wasm module %v: %v", wasmModulePath, err)
+		return nil
+	}
+	return info
+}
+
+// validatePluginConfig validates the plugin configuration against the JSON Schema embedded in the module, if any.
+func validatePluginConfig(schema []byte, wasmHTTPFilterConfig *wasm.Wasm) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	cfg := &wrapperspb.StringValue{}
+	if c := wasmHTTPFilterConfig.GetConfig().GetConfiguration(); c != nil && c.GetTypeUrl() != "" {
+		if err := c.UnmarshalTo(cfg); err != nil {
+			return fmt.Errorf("failed to unmarshal plugin config: %w", err)
+		}
+	}
+	return ValidatePluginConfig(schema, cfg.GetValue())
+}
+
 func containsWamrAotInCustomSection(wasmModulePath string) bool {
 	wasmBinary, err := os.ReadFile(wasmModulePath)
 	if err != nil {
@@ -289,8 +364,8 @@ func containsWamrAotInCustomSection(wasmModulePath string) bool {
 		return false
 	}
 	ctx := context.Background()
-	// Create Runtime
-	r := wazero.NewRuntime(ctx)
+	// Create Runtime, keeping the custom sections of the modules it compiles
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCustomSections(true))
 	defer r.Close(ctx)
 	// Compile Module
 	compiledModule, err := r.CompileModule(ctx, wasmBinary)
@@ -298,6 +373,11 @@ func containsWamrAotInCustomSection(wasmModulePath string) bool {
 		wasmLog.Debugf("Failed to compile WASM module: %v\n", err)
 		return false
 	}
+	return hasWamrAotSection(compiledModule)
+}
+
+// hasWamrAotSection returns whether the module holds code precompiled for a WAMR version the proxy can run.
+func hasWamrAotSection(compiledModule wazero.CompiledModule) bool {
 	// Get Wasm Custom Sections
 	sections := compiledModule.CustomSections()
 	for _, section := range sections {
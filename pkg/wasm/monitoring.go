@@ -31,6 +31,15 @@ const (
 	unmarshalFailure    = "unmarshal_failure"
 	fetchFailure        = "fetch_failure"
 	missRemoteFetchHint = "miss_remote_fetch_hint"
+
+	// Added by Ingress
+	schemaValidationFailure = "schema_validation_failure"
+	abiVersionFailure       = "abi_version_failure"
+
+	// For Wasm cache verification metric.
+	verificationSuccess = "success"
+	verificationFailure = "verification_failure"
+	// End added by Ingress
 )
 
 var (
@@ -52,6 +61,13 @@ var (
 		"number of Wasm remote fetches and results, including success, download failure, and checksum mismatch.",
 	)
 
+	// Added by Ingress
+	wasmCacheVerificationCount = monitoring.NewSum(
+		"wasm_cache_verification_count",
+		"number of verifications of cached Wasm module files against their recorded checksums, and results.",
+	)
+	// End added by Ingress
+
 	wasmConfigConversionCount = monitoring.NewSum(
 		"wasm_config_conversion_count",
 		"number of Wasm config conversion count and results, including success, no remote load, marshal failure, remote fetch failure, miss remote fetch hint.",
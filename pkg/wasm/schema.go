@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// PluginConfigSchemaSection is the name of the Wasm custom section in which a plugin may embed
+// a JSON Schema describing the pluginConfig it accepts.
+const PluginConfigSchemaSection = "plugin-config-schema"
+
+// ExtractPluginConfigSchema returns the JSON Schema embedded in the plugin-config-schema custom section
+// of the given Wasm binary. It returns nil without error if the module does not embed a schema.
+func ExtractPluginConfigSchema(module []byte) ([]byte, error) {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCustomSections(true))
+	defer r.Close(ctx)
+	compiledModule, err := r.CompileModule(ctx, module)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile Wasm module: %w", err)
+	}
+	return pluginConfigSchema(compiledModule), nil
+}
+
+// pluginConfigSchema returns the JSON Schema embedded in the custom sections of the compiled module, if any.
+func pluginConfigSchema(compiledModule wazero.CompiledModule) []byte {
+	for _, section := range compiledModule.CustomSections() {
+		if section.Name() == PluginConfigSchemaSection {
+			return section.Data()
+		}
+	}
+	return nil
+}
+
+// ValidatePluginConfig validates the JSON encoded pluginConfig against the given JSON Schema.
+// An empty config is validated as an empty object.
+func ValidatePluginConfig(schema []byte, config string) error {
+	if config == "" {
+		config = "{}"
+	}
+	s, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schema))
+	if err != nil {
+		return fmt.Errorf("invalid plugin config schema: %v", err)
+	}
+	res, err := s.Validate(gojsonschema.NewStringLoader(config))
+	if err != nil {
+		return fmt.Errorf("failed to validate plugin config: %v", err)
+	}
+	if res.Valid() {
+		return nil
+	}
+	msgs := make([]string, 0, len(res.Errors()))
+	for _, e := range res.Errors() {
+		msgs = append(msgs, e.String())
+	}
+	return fmt.Errorf("plugin config does not match the schema embedded in the module: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,171 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wasm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/wasm/v3"
+	v3 "github.com/envoyproxy/go-control-plane/envoy/extensions/wasm/v3"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+)
+
+func appendCustomSection(module []byte, name string, payload []byte) []byte {
+	body := binary.AppendUvarint(nil, uint64(len(name)))
+	body = append(body, name...)
+	body = append(body, payload...)
+	module = append(module, 0) // The id of the custom sections.
+	module = binary.AppendUvarint(module, uint64(len(body)))
+	return append(module, body...)
+}
+
+const testSchema = `{
+  "type": "object",
+  "properties": {"block_urls": {"type": "array", "items": {"type": "string"}}},
+  "required": ["block_urls"]
+}`
+
+func TestExtractPluginConfigSchema(t *testing.T) {
+	header := append([]byte{}, wasmHeader...)
+	cases := []struct {
+		name    string
+		module  []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "no custom section",
+			module: header,
+		},
+		{
+			name:   "unrelated custom section",
+			module: appendCustomSection(header, "wamr-aot", []byte("aot")),
+		},
+		{
+			name:   "schema section",
+			module: appendCustomSection(appendCustomSection(header, "wamr-aot", []byte("aot")), PluginConfigSchemaSection, []byte(testSchema)),
+			want:   testSchema,
+		},
+		{
+			name:    "not a wasm module",
+			module:  []byte("this is not wasm"),
+			wantErr: true,
+		},
+		{
+			name:    "truncated section",
+			module:  append(append([]byte{}, header...), 0, 0x7f),
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ExtractPluginConfigSchema(c.module)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("ExtractPluginConfigSchema() error = %v, wantErr %v", err, c.wantErr)
+			}
+			if string(got) != c.want {
+				t.Errorf("ExtractPluginConfigSchema() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidatePluginConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			config: `{"block_urls":["/foo"]}`,
+		},
+		{
+			name:    "missing required field",
+			config:  `{}`,
+			wantErr: "block_urls is required",
+		},
+		{
+			name:    "empty config",
+			config:  "",
+			wantErr: "block_urls is required",
+		},
+		{
+			name:    "wrong type",
+			config:  `{"block_urls":"/foo"}`,
+			wantErr: "Invalid type",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidatePluginConfig([]byte(testSchema), c.config)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v, want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePluginConfigOfModule(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "plugin.wasm")
+	if err := os.WriteFile(module, appendCustomSection(append([]byte{}, wasmHeader...), PluginConfigSchemaSection, []byte(testSchema)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.wasm")
+	if err := os.WriteFile(invalid, []byte("this is not wasm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wasmConfig := func(config string) *wasm.Wasm {
+		return &wasm.Wasm{Config: &v3.PluginConfig{
+			Configuration: protoconv.MessageToAny(&wrapperspb.StringValue{Value: config}),
+		}}
+	}
+	cases := []struct {
+		name    string
+		module  string
+		config  string
+		wantErr bool
+	}{
+		{name: "valid", module: module, config: `{"block_urls":["/foo"]}`},
+		{name: "invalid", module: module, config: `{}`, wantErr: true},
+		// The modules that can not be read or parsed are not validated.
+		{name: "missing module", module: filepath.Join(dir, "missing.wasm"), config: `{}`},
+		{name: "invalid module", module: invalid, config: `{}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var err error
+			if info := inspectFetchedModule(c.module); info != nil {
+				err = validatePluginConfig([]byte(info.ConfigSchema), wasmConfig(c.config))
+			}
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
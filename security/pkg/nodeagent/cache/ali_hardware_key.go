@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/security"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
+)
+
+// hardwareKeyTimeout bounds the generation of a key by the KeyProvider, and the signature of its CSR.
+const hardwareKeyTimeout = 30 * time.Second
+
+// generateHardwareKeyCSR generates a key for the resource with the KeyProvider, and the CSR of its certificate signed
+// with it.
+func (sc *SecretManagerClient) generateHardwareKeyCSR(resourceName string, options pkiutil.CertOptions) ([]byte, security.HardwareKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hardwareKeyTimeout)
+	defer cancel()
+	key, err := sc.configOptions.KeyProvider.GenerateKey(ctx, resourceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate hardware key: %v", err)
+	}
+	template, err := pkiutil.GenCSRTemplate(options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSR template creation failed (%v)", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("CSR creation with hardware key failed (%v)", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), key, nil
+}
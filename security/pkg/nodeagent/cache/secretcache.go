@@ -230,6 +230,7 @@ func (sc *SecretManagerClient) getCachedSecret(resourceName string) (secret *sec
 				ResourceName:     resourceName,
 				CertificateChain: c.CertificateChain,
 				PrivateKey:       c.PrivateKey,
+				HardwareKey:      c.HardwareKey, // Added by ingress
 				ExpireTime:       c.ExpireTime,
 				CreatedTime:      c.CreatedTime,
 			}
@@ -577,7 +578,16 @@ func (sc *SecretManagerClient) generateNewSecret(resourceName string) (*security
 	}
 
 	// Generate the cert/key, send CSR to CA.
-	csrPEM, keyPEM, err := pkiutil.GenCSR(options)
+	// Modified by ingress
+	var csrPEM, keyPEM []byte
+	var hardwareKey security.HardwareKey
+	var err error
+	if sc.configOptions.KeyProvider != nil {
+		csrPEM, hardwareKey, err = sc.generateHardwareKeyCSR(resourceName, options)
+	} else {
+		csrPEM, keyPEM, err = pkiutil.GenCSR(options)
+	}
+	// End modified by ingress
 	if err != nil {
 		cacheLog.Errorf("%s failed to generate key and certificate for CSR: %v", logPrefix, err)
 		return nil, err
@@ -624,6 +634,7 @@ func (sc *SecretManagerClient) generateNewSecret(resourceName string) (*security
 		CreatedTime:      time.Now(),
 		ExpireTime:       expireTime,
 		RootCert:         rootCertPEM,
+		HardwareKey:      hardwareKey, // Added by ingress
 	}, nil
 }
 
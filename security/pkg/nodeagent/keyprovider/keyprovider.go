@@ -0,0 +1,200 @@
+// Package keyprovider holds the private keys of the workload certificates in a key service, such as one fronting
+// a PKCS#11 token or a TPM, so they never leave it.
+package keyprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"istio.io/istio/pilot/pkg/util/protoconv"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/security"
+)
+
+var keyLog = log.RegisterScope("keyprovider", "hardware key provider debugging")
+
+// signTimeout bounds the signatures of the key service, as crypto.Signer has no context.
+const signTimeout = 10 * time.Second
+
+// GenerateKeyRequest is posted to /v1/keys to generate a key for the certificate of a resource.
+type GenerateKeyRequest struct {
+	ResourceName string `json:"resourceName"`
+	// Algorithm is RSA_<size>, or ECDSA_P256 or ECDSA_P384.
+	Algorithm string `json:"algorithm"`
+}
+
+// GenerateKeyResponse is the key generated by the key service.
+type GenerateKeyResponse struct {
+	ID string `json:"id"`
+	// PublicKey is the PEM encoded PKIX public key.
+	PublicKey string `json:"publicKey"`
+	// Provider is the private key provider of Envoy signing with the key.
+	Provider EnvoyProvider `json:"provider"`
+}
+
+// EnvoyProvider is a private key provider of Envoy, such as a PKCS#11 one, configured with a typed struct.
+type EnvoyProvider struct {
+	Name    string         `json:"name"`
+	TypeURL string         `json:"typeUrl"`
+	Config  map[string]any `json:"config,omitempty"`
+}
+
+// SignRequest is posted to /v1/keys/{id}/sign to sign a digest with a key.
+type SignRequest struct {
+	Digest []byte `json:"digest"`
+	// Hash is the hash function of the digest: SHA-256, SHA-384 or SHA-512.
+	Hash string `json:"hash"`
+	// PSS is true for RSA-PSS signatures, and false for PKCS #1 v1.5 ones.
+	PSS bool `json:"pss,omitempty"`
+}
+
+// SignResponse is the signature of the digest.
+type SignResponse struct {
+	Signature []byte `json:"signature"`
+}
+
+// Service is a KeyProvider generating the keys with a key service listening on a unix socket, with a JSON API:
+// a GenerateKeyRequest posted to /v1/keys generates a key, and a SignRequest posted to /v1/keys/{id}/sign signs the
+// CSR of its certificate. The service owns the lifecycle of the keys, keeping the previous key of a resource while
+// Envoy may still use it.
+type Service struct {
+	algorithm string
+	client    *http.Client
+}
+
+var _ security.KeyProvider = &Service{}
+
+// NewService returns a KeyProvider generating keys of the algorithm with the key service of the socket.
+func NewService(socket, algorithm string) *Service {
+	return &Service{
+		algorithm: algorithm,
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}},
+	}
+}
+
+// Algorithm returns the algorithm of the keys of the options: an ECDSA key if an EC signature algorithm is set,
+// otherwise an RSA key.
+func Algorithm(options *security.Options) string {
+	if options.ECCSigAlg != "" {
+		if options.ECCCurve == "P384" {
+			return "ECDSA_P384"
+		}
+		return "ECDSA_P256"
+	}
+	return fmt.Sprintf("RSA_%d", options.WorkloadRSAKeySize)
+}
+
+// post posts the JSON request to the path of the key service, decoding the JSON response.
+func (s *Service) post(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://keyprovider"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("key service returned %s: %s", resp.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, out)
+}
+
+func (s *Service) GenerateKey(ctx context.Context, resourceName string) (security.HardwareKey, error) {
+	var resp GenerateKeyResponse
+	if err := s.post(ctx, "/v1/keys", GenerateKeyRequest{ResourceName: resourceName, Algorithm: s.algorithm}, &resp); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(resp.PublicKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key of key %s", resp.ID)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of key %s: %v", resp.ID, err)
+	}
+	if resp.Provider.Name == "" || resp.Provider.TypeURL == "" {
+		return nil, fmt.Errorf("no private key provider for key %s", resp.ID)
+	}
+	config := protoconv.TypedStructWithFields(resp.Provider.TypeURL, resp.Provider.Config)
+	if config == nil {
+		return nil, fmt.Errorf("invalid private key provider config for key %s", resp.ID)
+	}
+	keyLog.Infof("generated hardware key %s for %s", resp.ID, resourceName)
+	return &key{service: s, id: resp.ID, public: public, providerName: resp.Provider.Name, providerConfig: config}, nil
+}
+
+// key is a key of the key service.
+type key struct {
+	service        *Service
+	id             string
+	public         crypto.PublicKey
+	providerName   string
+	providerConfig *anypb.Any
+}
+
+var _ security.HardwareKey = &key{}
+
+func (k *key) Public() crypto.PublicKey {
+	return k.public
+}
+
+func (k *key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, err := hashName(opts.HashFunc())
+	if err != nil {
+		return nil, err
+	}
+	_, pss := opts.(*rsa.PSSOptions)
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	var resp SignResponse
+	if err := k.service.post(ctx, "/v1/keys/"+url.PathEscape(k.id)+"/sign", SignRequest{Digest: digest, Hash: hash, PSS: pss}, &resp); err != nil {
+		return nil, fmt.Errorf("failed to sign with key %s: %v", k.id, err)
+	}
+	return resp.Signature, nil
+}
+
+func (k *key) PrivateKeyProvider() (string, *anypb.Any) {
+	return k.providerName, k.providerConfig
+}
+
+func hashName(h crypto.Hash) (string, error) {
+	switch h {
+	case crypto.SHA256:
+		return "SHA-256", nil
+	case crypto.SHA384:
+		return "SHA-384", nil
+	case crypto.SHA512:
+		return "SHA-512", nil
+	default:
+		return "", fmt.Errorf("unsupported hash function %v", h)
+	}
+}
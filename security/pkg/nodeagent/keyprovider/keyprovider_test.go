@@ -0,0 +1,98 @@
+package keyprovider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"istio.io/istio/pkg/security"
+)
+
+func TestService(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/keys", func(w http.ResponseWriter, r *http.Request) {
+		var req GenerateKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Algorithm != "ECDSA_P256" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(GenerateKeyResponse{
+			ID:        "key-1",
+			PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})),
+			Provider: EnvoyProvider{
+				Name:    "pkcs11",
+				TypeURL: "type.googleapis.com/envoy.extensions.private_key_providers.pkcs11.v3alpha.Pkcs11PrivateKeyMethodConfig",
+				Config:  map[string]any{"token_label": "workload", "key_id": "key-1"},
+			},
+		})
+	})
+	mux.HandleFunc("/v1/keys/key-1/sign", func(w http.ResponseWriter, r *http.Request) {
+		var req SignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Hash != "SHA-256" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		signature, err := ecdsa.SignASN1(rand.Reader, priv, req.Digest)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(SignResponse{Signature: signature})
+	})
+	socket := filepath.Join(t.TempDir(), "key.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: mux}
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+
+	s := NewService(socket, Algorithm(&security.Options{ECCSigAlg: "ECDSA", ECCCurve: "P256"}))
+	key, err := s.GenerateKey(context.Background(), security.WorkloadKeyCertResourceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{Organization: []string{"test"}}}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("expected the CSR to be signed with the key: %v", err)
+	}
+	name, config := key.PrivateKeyProvider()
+	if name != "pkcs11" || config == nil {
+		t.Fatalf("unexpected private key provider %q %v", name, config)
+	}
+
+	// Keys of another algorithm are refused by the key service.
+	s = NewService(socket, Algorithm(&security.Options{WorkloadRSAKeySize: 2048}))
+	if _, err := s.GenerateKey(context.Background(), security.WorkloadKeyCertResourceName); err == nil {
+		t.Fatalf("expected an error generating an RSA key")
+	}
+}
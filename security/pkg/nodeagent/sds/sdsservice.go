@@ -236,6 +236,28 @@ func toEnvoySecret(s *security.SecretItem, caRootPath string, pkpConf *mesh.Priv
 			},
 		}
 	} else {
+		// Added by ingress
+		if s.HardwareKey != nil {
+			// The key is held by the KeyProvider, which tells how Envoy signs with it.
+			name, config := s.HardwareKey.PrivateKeyProvider()
+			secret.Type = &tls.Secret_TlsCertificate{
+				TlsCertificate: &tls.TlsCertificate{
+					CertificateChain: &core.DataSource{
+						Specifier: &core.DataSource_InlineBytes{
+							InlineBytes: s.CertificateChain,
+						},
+					},
+					PrivateKeyProvider: &tls.PrivateKeyProvider{
+						ProviderName: name,
+						ConfigType: &tls.PrivateKeyProvider_TypedConfig{
+							TypedConfig: config,
+						},
+					},
+				},
+			}
+			return secret
+		}
+		// End added by ingress
 		switch pkpConf.GetProvider().(type) {
 		case *mesh.PrivateKeyProvider_Cryptomb:
 			crypto := pkpConf.GetCryptomb()